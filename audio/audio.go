@@ -0,0 +1,149 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The audio module is a pure Go software mixer for positional sound. Sources
+can be placed in the world or attached to Renderables, the listener follows
+the camera, and each source is attenuated by distance, panned between the
+speakers and pitch shifted for doppler before being mixed.
+
+The Mixer doesn't open an audio device itself. It produces interleaved
+stereo samples through Mix, or 16-bit little endian PCM through its
+io.Reader implementation, which can be handed to any playback library
+such as github.com/hajimehoshi/oto.
+
+WAV files are decoded natively and OGG Vorbis files are decoded with
+github.com/jfreymuth/oggvorbis. Sounds can be loaded into memory with
+LoadSound for short effects or streamed from disk with OpenStream for
+music and ambience.
+
+*/
+
+package audio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Stream is a source of interleaved audio samples.
+type Stream interface {
+	// Read fills samples with interleaved samples in the range [-1, 1] and
+	// returns the number of samples written. io.EOF is returned at the end
+	// of the stream. Only whole frames are returned.
+	Read(samples []float32) (int, error)
+
+	// SampleRate returns the number of frames per second.
+	SampleRate() int
+
+	// Channels returns the number of samples in a frame.
+	Channels() int
+
+	// Rewind moves the stream back to the first frame.
+	Rewind() error
+
+	// Close releases the resources held by the stream.
+	Close() error
+}
+
+// Sound is audio that has been fully decoded into memory. Any number of
+// sources can play the same Sound at once through their own streams.
+type Sound struct {
+	// Samples are the interleaved samples of the sound.
+	Samples []float32
+
+	// Rate is the number of frames per second.
+	Rate int
+
+	// ChannelCount is the number of samples in a frame.
+	ChannelCount int
+}
+
+// LoadSound decodes the whole WAV or OGG file into memory.
+func LoadSound(path string) (*Sound, error) {
+	stream, err := OpenStream(path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	s := new(Sound)
+	s.Rate = stream.SampleRate()
+	s.ChannelCount = stream.Channels()
+	s.Samples = make([]float32, 0, s.Rate*s.ChannelCount)
+	chunk := make([]float32, 4096*s.ChannelCount)
+	for {
+		n, err := stream.Read(chunk)
+		s.Samples = append(s.Samples, chunk[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+		}
+	}
+	return s, nil
+}
+
+// OpenStream opens the WAV or OGG file for streaming from disk. The file
+// stays open until the stream is closed.
+func OpenStream(path string) (Stream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var stream Stream
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		stream, err = NewWAVStream(f)
+	case ".ogg":
+		stream, err = NewOGGStream(f)
+	default:
+		err = fmt.Errorf("unsupported audio file type")
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	return stream, nil
+}
+
+// NewStream returns a stream that plays the sound from the beginning.
+func (s *Sound) NewStream() Stream {
+	return &soundStream{sound: s}
+}
+
+// Duration returns the length of the sound in seconds.
+func (s *Sound) Duration() float64 {
+	if s.Rate == 0 || s.ChannelCount == 0 {
+		return 0.0
+	}
+	return float64(len(s.Samples)/s.ChannelCount) / float64(s.Rate)
+}
+
+// soundStream reads the samples of a Sound.
+type soundStream struct {
+	sound  *Sound
+	offset int
+}
+
+func (ss *soundStream) Read(samples []float32) (int, error) {
+	remaining := ss.sound.Samples[ss.offset:]
+	if len(remaining) == 0 {
+		return 0, io.EOF
+	}
+	n := len(samples) - len(samples)%ss.sound.ChannelCount
+	n = copy(samples[:n], remaining)
+	ss.offset += n
+	return n, nil
+}
+
+func (ss *soundStream) SampleRate() int { return ss.sound.Rate }
+func (ss *soundStream) Channels() int   { return ss.sound.ChannelCount }
+func (ss *soundStream) Rewind() error   { ss.offset = 0; return nil }
+func (ss *soundStream) Close() error    { return nil }
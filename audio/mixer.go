@@ -0,0 +1,250 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+const (
+	// DefaultSampleRate is the default output rate of a Mixer.
+	DefaultSampleRate = 44100
+
+	// DefaultSpeedOfSound is the speed of sound in meters per second used
+	// for the doppler effect.
+	DefaultSpeedOfSound = 343.3
+)
+
+// Listener is the point in the world sounds are heard from.
+type Listener struct {
+	Position mgl.Vec3
+	Velocity mgl.Vec3
+	Forward  mgl.Vec3
+	Up       mgl.Vec3
+
+	hasLastPos bool
+	lastPos    mgl.Vec3
+}
+
+// SetFromCamera places the listener at the camera looking the same way.
+// The velocity is derived from the camera movement since the last call.
+func (l *Listener) SetFromCamera(cam fizzle.Camera, frameDelta float64) {
+	view := cam.GetViewMatrix()
+	pos := cam.GetPosition()
+	l.Forward = view.Row(2).Vec3().Mul(-1.0)
+	l.Up = view.Row(1).Vec3()
+	if l.hasLastPos && frameDelta > 0.0 {
+		l.Velocity = pos.Sub(l.lastPos).Mul(float32(1.0 / frameDelta))
+	}
+	l.Position = pos
+	l.lastPos = pos
+	l.hasLastPos = true
+}
+
+// Mixer mixes all of the playing sources into stereo output.
+//
+// Mix and Read are meant to be called from the audio device's goroutine
+// while Update is called from the game loop. Both lock the Mixer, and any
+// other changes to the Mixer or its sources from the game loop should
+// be made between Lock and Unlock.
+type Mixer struct {
+	// Volume is the master gain applied to the output.
+	Volume float32
+
+	// SpeedOfSound is used with DopplerFactor to calculate the doppler shift.
+	SpeedOfSound float32
+
+	// DopplerFactor scales the doppler effect; 0 disables it.
+	DopplerFactor float32
+
+	// Listener is where the sources are heard from.
+	Listener Listener
+
+	sampleRate int
+	sources    []*Source
+	mixBuffer  []float32
+	lock       sync.Mutex
+}
+
+// NewMixer creates a new Mixer producing output at the sample rate.
+func NewMixer(sampleRate int) *Mixer {
+	m := new(Mixer)
+	m.Volume = 1.0
+	m.SpeedOfSound = DefaultSpeedOfSound
+	m.DopplerFactor = 1.0
+	m.Listener.Forward = mgl.Vec3{0.0, 0.0, -1.0}
+	m.Listener.Up = mgl.Vec3{0.0, 1.0, 0.0}
+	m.sampleRate = sampleRate
+	m.sources = make([]*Source, 0, 32)
+	return m
+}
+
+// SampleRate returns the output rate of the mixer.
+func (m *Mixer) SampleRate() int {
+	return m.sampleRate
+}
+
+// Lock locks the mixer so that sources can be changed safely.
+func (m *Mixer) Lock() {
+	m.lock.Lock()
+}
+
+// Unlock unlocks the mixer after a Lock.
+func (m *Mixer) Unlock() {
+	m.lock.Unlock()
+}
+
+// AddSource adds the source to the mix.
+func (m *Mixer) AddSource(s *Source) {
+	m.lock.Lock()
+	m.sources = append(m.sources, s)
+	m.lock.Unlock()
+}
+
+// RemoveSource takes the source out of the mix. The source's stream is
+// not closed.
+func (m *Mixer) RemoveSource(s *Source) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i, src := range m.sources {
+		if src == s {
+			copy(m.sources[i:], m.sources[i+1:])
+			m.sources[len(m.sources)-1] = nil
+			m.sources = m.sources[:len(m.sources)-1]
+			return
+		}
+	}
+}
+
+// GetSources returns the sources in the mix.
+func (m *Mixer) GetSources() []*Source {
+	return m.sources
+}
+
+// PlayAt creates a source for the sound, places it at the position and
+// starts it playing. The source stays in the mixer until it's removed.
+func (m *Mixer) PlayAt(sound *Sound, position mgl.Vec3) *Source {
+	s := NewSource(sound.NewStream())
+	s.Position = position
+	s.Play()
+	m.AddSource(s)
+	return s
+}
+
+// UpdateWithClock updates the mixer with the clock's unscaled frame delta
+// so that pausing the game doesn't distort the doppler effect.
+func (m *Mixer) UpdateWithClock(clock *fizzle.Clock, cam fizzle.Camera) {
+	m.Update(clock.UnscaledDelta(), cam)
+}
+
+// Update moves the listener to the camera, if cam is non-nil, and the
+// attached sources to their Renderables.
+func (m *Mixer) Update(frameDelta float64, cam fizzle.Camera) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if cam != nil {
+		m.Listener.SetFromCamera(cam, frameDelta)
+	}
+	for _, s := range m.sources {
+		s.updateAttached(frameDelta)
+	}
+}
+
+// Mix fills out with interleaved stereo samples of all the playing sources.
+func (m *Mixer) Mix(out []float32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i := range out {
+		out[i] = 0.0
+	}
+
+	for _, s := range m.sources {
+		if !s.playing {
+			continue
+		}
+
+		gainL, gainR := s.Volume, s.Volume
+		rate := float64(s.stream.SampleRate()) / float64(m.sampleRate) * float64(s.Pitch)
+		if s.Spatial {
+			gain, pan, doppler := m.spatialize(s)
+			angle := float64(pan+1.0) * math.Pi / 4.0
+			gainL *= gain * float32(math.Cos(angle))
+			gainR *= gain * float32(math.Sin(angle))
+			rate *= float64(doppler)
+		}
+		if rate <= 0.0 {
+			continue
+		}
+		s.mix(out, rate, gainL*m.Volume, gainR*m.Volume)
+	}
+}
+
+// Read implements io.Reader, filling p with interleaved stereo 16-bit
+// little endian samples.
+func (m *Mixer) Read(p []byte) (int, error) {
+	samples := len(p) / 4 * 2
+	if cap(m.mixBuffer) < samples {
+		m.mixBuffer = make([]float32, samples)
+	}
+	buffer := m.mixBuffer[:samples]
+	m.Mix(buffer)
+
+	for i, v := range buffer {
+		if v > 1.0 {
+			v = 1.0
+		} else if v < -1.0 {
+			v = -1.0
+		}
+		binary.LittleEndian.PutUint16(p[i*2:], uint16(int16(v*32767.0)))
+	}
+	return samples * 2, nil
+}
+
+// spatialize returns the distance attenuation, the pan from -1 (left) to
+// 1 (right) and the doppler pitch shift for the source.
+func (m *Mixer) spatialize(s *Source) (float32, float32, float32) {
+	l := &m.Listener
+	toListener := l.Position.Sub(s.Position)
+	dist := toListener.Len()
+
+	// inverse distance clamped attenuation
+	gain := float32(1.0)
+	if s.MinDistance > 0.0 {
+		d := dist
+		if d < s.MinDistance {
+			d = s.MinDistance
+		} else if s.MaxDistance > s.MinDistance && d > s.MaxDistance {
+			d = s.MaxDistance
+		}
+		gain = s.MinDistance / (s.MinDistance + s.Rolloff*(d-s.MinDistance))
+	}
+
+	if dist < 1e-4 {
+		return gain, 0.0, 1.0
+	}
+
+	var pan float32
+	right := l.Forward.Cross(l.Up)
+	if right.Len() > 1e-6 {
+		pan = toListener.Mul(-1.0 / dist).Dot(right.Normalize())
+	}
+
+	doppler := float32(1.0)
+	if m.DopplerFactor > 0.0 && m.SpeedOfSound > 0.0 {
+		// velocities toward the listener, clamped below the speed of sound
+		limit := m.SpeedOfSound / m.DopplerFactor * 0.99
+		vls := float32(math.Min(float64(l.Velocity.Dot(toListener)/dist), float64(limit)))
+		vss := float32(math.Min(float64(s.Velocity.Dot(toListener)/dist), float64(limit)))
+		doppler = (m.SpeedOfSound - m.DopplerFactor*vls) / (m.SpeedOfSound - m.DopplerFactor*vss)
+	}
+
+	return gain, pan, doppler
+}
@@ -0,0 +1,63 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package audio
+
+import (
+	"io"
+
+	"github.com/jfreymuth/oggvorbis"
+)
+
+// OGGStream decodes OGG Vorbis data as it's read.
+type OGGStream struct {
+	r       io.ReadSeeker
+	decoder *oggvorbis.Reader
+}
+
+// NewOGGStream creates a decoder for the OGG Vorbis data. If r is an
+// io.Closer it gets closed with the stream.
+func NewOGGStream(r io.ReadSeeker) (*OGGStream, error) {
+	decoder, err := oggvorbis.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(OGGStream)
+	s.r = r
+	s.decoder = decoder
+	return s, nil
+}
+
+// Read implements Stream.
+func (s *OGGStream) Read(samples []float32) (int, error) {
+	ch := s.decoder.Channels()
+	n, err := s.decoder.Read(samples[:len(samples)-len(samples)%ch])
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// SampleRate implements Stream.
+func (s *OGGStream) SampleRate() int {
+	return s.decoder.SampleRate()
+}
+
+// Channels implements Stream.
+func (s *OGGStream) Channels() int {
+	return s.decoder.Channels()
+}
+
+// Rewind implements Stream.
+func (s *OGGStream) Rewind() error {
+	return s.decoder.SetPosition(0)
+}
+
+// Close implements Stream.
+func (s *OGGStream) Close() error {
+	if c, okay := s.r.(io.Closer); okay {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package audio
+
+import (
+	"io"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/groggy"
+)
+
+const (
+	// sourceBufferFrames is the number of frames decoded ahead for a source.
+	sourceBufferFrames = 2048
+)
+
+// Source plays a stream, either positioned in the world or directly to
+// the speakers. The fields and methods of a Source that has been added to
+// a Mixer should only be changed while the Mixer is locked or from the
+// goroutine calling Update.
+type Source struct {
+	// Position and Velocity place the source in the world. They are
+	// updated by the Mixer if the source is attached to a Renderable.
+	Position mgl.Vec3
+	Velocity mgl.Vec3
+
+	// Volume is the gain applied to the source.
+	Volume float32
+
+	// Pitch scales the playback rate; 1.0 is the original pitch.
+	Pitch float32
+
+	// Looping restarts the stream when it ends.
+	Looping bool
+
+	// Spatial enables distance attenuation, panning and doppler. Sources
+	// that aren't spatial, like music, play at full volume on both speakers.
+	Spatial bool
+
+	// MinDistance is the distance at which attenuation begins.
+	MinDistance float32
+
+	// MaxDistance is the distance past which the source gets no quieter.
+	MaxDistance float32
+
+	// Rolloff controls how quickly the volume drops between MinDistance
+	// and MaxDistance using the inverse distance model.
+	Rolloff float32
+
+	stream  Stream
+	playing bool
+
+	attached    *fizzle.Renderable
+	hasLastPos  bool
+	lastAttachP mgl.Vec3
+
+	// buf holds the decoded frames and cursor is the fractional frame
+	// being played within it
+	buf    []float32
+	frames int
+	cursor float64
+	eof    bool
+}
+
+// NewSource creates a new spatial source for the stream. The source does
+// not start playing until Play is called.
+func NewSource(stream Stream) *Source {
+	s := new(Source)
+	s.Volume = 1.0
+	s.Pitch = 1.0
+	s.Spatial = true
+	s.MinDistance = 1.0
+	s.MaxDistance = 100.0
+	s.Rolloff = 1.0
+	s.stream = stream
+	s.buf = make([]float32, sourceBufferFrames*stream.Channels())
+	return s
+}
+
+// GetStream returns the stream the source plays.
+func (s *Source) GetStream() Stream {
+	return s.stream
+}
+
+// Attach makes the source follow the Renderable's world position. The
+// velocity is derived from the movement between updates. Passing nil
+// detaches the source.
+func (s *Source) Attach(r *fizzle.Renderable) {
+	s.attached = r
+	s.hasLastPos = false
+	if r == nil {
+		s.Velocity = mgl.Vec3{}
+	}
+}
+
+// GetAttached returns the Renderable the source follows, or nil.
+func (s *Source) GetAttached() *fizzle.Renderable {
+	return s.attached
+}
+
+// Play starts or resumes playing the source.
+func (s *Source) Play() {
+	s.playing = true
+}
+
+// Pause stops playing the source but keeps its position in the stream.
+func (s *Source) Pause() {
+	s.playing = false
+}
+
+// Stop stops playing the source and rewinds the stream.
+func (s *Source) Stop() {
+	s.playing = false
+	s.rewind()
+}
+
+// IsPlaying returns true if the source is playing.
+func (s *Source) IsPlaying() bool {
+	return s.playing
+}
+
+// updateAttached moves the source to the Renderable it's attached to.
+func (s *Source) updateAttached(frameDelta float64) {
+	if s.attached == nil {
+		return
+	}
+
+	pos := s.attached.GetTransformMat4().Col(3).Vec3()
+	if s.hasLastPos && frameDelta > 0.0 {
+		s.Velocity = pos.Sub(s.lastAttachP).Mul(float32(1.0 / frameDelta))
+	}
+	s.Position = pos
+	s.lastAttachP = pos
+	s.hasLastPos = true
+}
+
+// rewind clears the decoded frames and moves the stream back to the start.
+func (s *Source) rewind() {
+	s.frames = 0
+	s.cursor = 0.0
+	s.eof = false
+	if err := s.stream.Rewind(); err != nil {
+		groggy.Logsf("ERROR", "Audio source failed to rewind its stream: %v", err)
+	}
+}
+
+// fill drops the frames that have been played and decodes more, keeping
+// the current frame for interpolation.
+func (s *Source) fill() {
+	ch := s.stream.Channels()
+	drop := int(s.cursor)
+	if drop > s.frames {
+		drop = s.frames
+	}
+	copy(s.buf, s.buf[drop*ch:s.frames*ch])
+	s.frames -= drop
+	s.cursor -= float64(drop)
+
+	rewoundEmpty := false
+	for !s.eof && s.frames < sourceBufferFrames {
+		n, err := s.stream.Read(s.buf[s.frames*ch:])
+		s.frames += n / ch
+		if n > 0 {
+			rewoundEmpty = false
+		}
+
+		if err == io.EOF {
+			// a stream with nothing in it would loop forever
+			if !s.Looping || rewoundEmpty {
+				s.eof = true
+				break
+			}
+			if err := s.stream.Rewind(); err != nil {
+				groggy.Logsf("ERROR", "Audio source failed to loop its stream: %v", err)
+				s.eof = true
+				break
+			}
+			rewoundEmpty = true
+		} else if err != nil {
+			groggy.Logsf("ERROR", "Audio source failed to read its stream: %v", err)
+			s.eof = true
+		} else if n == 0 {
+			break
+		}
+	}
+}
+
+// mix adds the source to the stereo output at the rate, in source frames per
+// output frame, with the gains for each speaker.
+func (s *Source) mix(out []float32, rate float64, gainL float32, gainR float32) {
+	ch := s.stream.Channels()
+	for f := 0; f+1 < len(out); f += 2 {
+		i := int(s.cursor)
+		if i+1 >= s.frames && !s.eof {
+			s.fill()
+			i = int(s.cursor)
+		}
+		if i >= s.frames {
+			// the stream has ended
+			s.playing = false
+			s.rewind()
+			return
+		}
+
+		next := i + 1
+		if next >= s.frames {
+			next = i
+		}
+		t := float32(s.cursor - float64(i))
+		a := s.buf[i*ch : i*ch+ch]
+		b := s.buf[next*ch : next*ch+ch]
+
+		var left, right float32
+		switch {
+		case ch == 1:
+			left = a[0] + (b[0]-a[0])*t
+			right = left
+		case s.Spatial:
+			// spatial sources are panned as mono
+			left = (a[0]+a[1])*0.5 + ((b[0]+b[1])*0.5-(a[0]+a[1])*0.5)*t
+			right = left
+		default:
+			left = a[0] + (b[0]-a[0])*t
+			right = a[1] + (b[1]-a[1])*t
+		}
+
+		out[f] += left * gainL
+		out[f+1] += right * gainR
+		s.cursor += rate
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	wavFormatPCM   = 1
+	wavFormatFloat = 3
+)
+
+// WAVStream decodes PCM or floating point WAV data as it's read.
+type WAVStream struct {
+	r             io.ReadSeeker
+	format        uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	dataOffset    int64
+	dataSize      int64
+	position      int64
+	raw           []byte
+}
+
+// NewWAVStream parses the WAV header and returns a stream positioned at the
+// start of the sample data. 8, 16, 24 and 32 bit integer and 32 bit float
+// data are supported. If r is an io.Closer it gets closed with the stream.
+func NewWAVStream(r io.ReadSeeker) (*WAVStream, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a RIFF WAVE file")
+	}
+
+	ws := new(WAVStream)
+	foundFormat := false
+	offset := int64(12)
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("no data chunk found")
+		}
+		offset += 8
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("the fmt chunk is too small")
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, err
+			}
+			ws.format = binary.LittleEndian.Uint16(chunk[0:2])
+			ws.channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+			ws.sampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+			ws.bitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:16]))
+
+			// the extensible format keeps the real format in the sub format guid
+			if ws.format == 0xFFFE && size >= 26 {
+				ws.format = binary.LittleEndian.Uint16(chunk[24:26])
+			}
+			foundFormat = true
+
+		case "data":
+			if !foundFormat {
+				return nil, fmt.Errorf("the data chunk comes before the fmt chunk")
+			}
+			ws.dataOffset = offset
+			ws.dataSize = size
+			if err := ws.validate(); err != nil {
+				return nil, err
+			}
+			ws.r = r
+			return ws, nil
+
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+
+		// chunks are padded to an even size
+		offset += size
+		if size%2 == 1 {
+			if _, err := r.Seek(1, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			offset++
+		}
+	}
+}
+
+// validate checks that the format is one the stream can decode.
+func (ws *WAVStream) validate() error {
+	if ws.channels < 1 || ws.sampleRate < 1 {
+		return fmt.Errorf("invalid channel count or sample rate")
+	}
+	switch ws.format {
+	case wavFormatPCM:
+		if ws.bitsPerSample != 8 && ws.bitsPerSample != 16 && ws.bitsPerSample != 24 && ws.bitsPerSample != 32 {
+			return fmt.Errorf("unsupported PCM sample size of %d bits", ws.bitsPerSample)
+		}
+	case wavFormatFloat:
+		if ws.bitsPerSample != 32 {
+			return fmt.Errorf("unsupported float sample size of %d bits", ws.bitsPerSample)
+		}
+	default:
+		return fmt.Errorf("unsupported WAV format %d", ws.format)
+	}
+	return nil
+}
+
+// Read implements Stream.
+func (ws *WAVStream) Read(samples []float32) (int, error) {
+	bytesPerSample := ws.bitsPerSample / 8
+	frameBytes := int64(bytesPerSample * ws.channels)
+	remaining := (ws.dataSize - ws.position) / frameBytes
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+
+	frames := int64(len(samples) / ws.channels)
+	if frames > remaining {
+		frames = remaining
+	}
+	size := int(frames * frameBytes)
+	if cap(ws.raw) < size {
+		ws.raw = make([]byte, size)
+	}
+	raw := ws.raw[:size]
+	n, err := io.ReadFull(ws.r, raw)
+	ws.position += int64(n)
+	n -= n % int(frameBytes)
+
+	count := n / bytesPerSample
+	for i := 0; i < count; i++ {
+		b := raw[i*bytesPerSample:]
+		switch {
+		case ws.format == wavFormatFloat:
+			samples[i] = math.Float32frombits(binary.LittleEndian.Uint32(b))
+		case bytesPerSample == 1:
+			samples[i] = float32(int(b[0])-128) / 128.0
+		case bytesPerSample == 2:
+			samples[i] = float32(int16(binary.LittleEndian.Uint16(b))) / 32768.0
+		case bytesPerSample == 3:
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			samples[i] = float32(v) / 8388608.0
+		default:
+			samples[i] = float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648.0
+		}
+	}
+
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err == io.EOF && count > 0 {
+		err = nil
+	}
+	return count, err
+}
+
+// SampleRate implements Stream.
+func (ws *WAVStream) SampleRate() int {
+	return ws.sampleRate
+}
+
+// Channels implements Stream.
+func (ws *WAVStream) Channels() int {
+	return ws.channels
+}
+
+// Rewind implements Stream.
+func (ws *WAVStream) Rewind() error {
+	_, err := ws.r.Seek(ws.dataOffset, io.SeekStart)
+	ws.position = 0
+	return err
+}
+
+// Close implements Stream.
+func (ws *WAVStream) Close() error {
+	if c, okay := ws.r.(io.Closer); okay {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	"fmt"
+	"math"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// BakedClip locates one baked animation in the rows of the bone texture.
+type BakedClip struct {
+	// Name is the name of the source animation.
+	Name string
+
+	// FirstFrame is the texture row holding the pose at time zero.
+	FirstFrame int
+
+	// FrameCount is the number of rows baked for the clip, including
+	// the pose at the end of the animation.
+	FrameCount int
+
+	// Duration is the length of the source animation.
+	Duration float32
+}
+
+// BakedAnimations holds the pose transforms of every animation of a Skeleton
+// sampled at a fixed rate and stored in a floating point texture so that
+// skinned instances can each play their own frame without a uniform upload
+// per character. Each texture row is one frame and each bone takes up four
+// texels, one per matrix column.
+//
+// Renderables draw with it when it's set as their Core's BakedAnimations.
+type BakedAnimations struct {
+	// Texture is the RGBA32F texture holding the bone matrices.
+	Texture graphics.Texture
+
+	// FPS is the number of frames baked per second of animation.
+	FPS float32
+
+	// BoneCount is the number of bones in each frame.
+	BoneCount int
+
+	// Clips are the baked animations in the order of the Skeleton's Animations.
+	Clips []BakedClip
+}
+
+// BakeAnimations samples all of the animations of the skeleton at fps frames
+// per second and uploads the poses to a new texture. The skeleton itself
+// isn't animated by this.
+func BakeAnimations(skel *Skeleton, fps float32) (*BakedAnimations, error) {
+	if skel == nil || len(skel.Bones) == 0 {
+		return nil, fmt.Errorf("cannot bake animations for a skeleton without bones")
+	}
+	if len(skel.Animations) == 0 {
+		return nil, fmt.Errorf("cannot bake a skeleton without animations")
+	}
+	if fps <= 0.0 {
+		return nil, fmt.Errorf("invalid bake rate of %f frames per second", fps)
+	}
+
+	ba := new(BakedAnimations)
+	ba.FPS = fps
+	ba.BoneCount = len(skel.Bones)
+	ba.Clips = make([]BakedClip, len(skel.Animations))
+
+	// lay out the clips first so the texture data can be allocated once
+	frames := 0
+	for i := range skel.Animations {
+		anim := &skel.Animations[i]
+		clip := &ba.Clips[i]
+		clip.Name = anim.Name
+		clip.Duration = anim.Duration
+		clip.FirstFrame = frames
+		clip.FrameCount = int(math.Ceil(float64(anim.Duration*fps))) + 1
+		frames += clip.FrameCount
+	}
+
+	// animate a scratch skeleton so that the source keeps its pose
+	scratch := NewSkeleton(skel.Bones, skel.Animations)
+	rowSize := ba.BoneCount * 16
+	data := make([]float32, rowSize*frames)
+	for i := range skel.Animations {
+		clip := &ba.Clips[i]
+		for f := 0; f < clip.FrameCount; f++ {
+			t := float32(f) / fps
+			if t > clip.Duration {
+				t = clip.Duration
+			}
+			scratch.Animate(&skel.Animations[i], t)
+
+			row := data[(clip.FirstFrame+f)*rowSize:]
+			for b, pose := range scratch.PoseTransforms {
+				copy(row[b*16:b*16+16], pose[:])
+			}
+		}
+	}
+
+	ba.Texture = gfx.GenTexture()
+	if ba.Texture == 0 {
+		return nil, fmt.Errorf("Failed to generate a texture object: %v", CheckForError("generating a texture"))
+	}
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, ba.Texture)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, int32(ba.BoneCount*4), int32(frames), 0,
+		graphics.RGBA, graphics.FLOAT, gfx.Ptr(data), len(data)*4)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	if err := CheckForError("uploading the baked animations"); err != nil {
+		gfx.DeleteTexture(ba.Texture)
+		return nil, err
+	}
+	return ba, nil
+}
+
+// Destroy releases the bone texture.
+func (ba *BakedAnimations) Destroy() {
+	if ba.Texture != 0 {
+		gfx.DeleteTexture(ba.Texture)
+		ba.Texture = 0
+	}
+}
+
+// FindClip returns the index of the clip with the given name or -1 if
+// there is no such clip.
+func (ba *BakedAnimations) FindClip(name string) int {
+	for i := range ba.Clips {
+		if ba.Clips[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Frame returns the texture row to sample for the clip at the given time,
+// looping at the end of the clip. The fraction is used by the shader to
+// interpolate between the two nearest rows.
+func (ba *BakedAnimations) Frame(clip int, time float32) float32 {
+	if clip < 0 || clip >= len(ba.Clips) {
+		return 0.0
+	}
+	c := &ba.Clips[clip]
+	if c.Duration > 0.0 {
+		time = float32(math.Mod(float64(time), float64(c.Duration)))
+		if time < 0.0 {
+			time += c.Duration
+		}
+	} else {
+		time = 0.0
+	}
+
+	frame := time * ba.FPS
+	last := float32(c.FrameCount - 1)
+	if frame > last {
+		frame = last
+	}
+	return float32(c.FirstFrame) + frame
+}
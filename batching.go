@@ -0,0 +1,243 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// materialKey is used to group renderables that can be drawn with the
+// same shader and material settings. Renderables with a shared Material,
+// or whose core material has extra Uniforms, are grouped by its address.
+type materialKey struct {
+	Shared        *Material
+	Shader        *RenderShader
+	Tex0          graphics.Texture
+	Tex1          graphics.Texture
+	Lightmap      graphics.Texture
+	DiffuseColor  mgl.Vec4
+	SpecularColor mgl.Vec4
+	Shininess     float32
+	PBR           PBRMaterial
+	AlphaCutoff   float32
+	Transparent   bool
+	Layer         uint8
+	Blend         BlendMode
+	Cull          CullMode
+	NoDepthTest   bool
+	NoDepthWrite  bool
+}
+
+// chunkKey identifies a batch by material, shadow flags and spatial grid cell.
+type chunkKey struct {
+	material      materialKey
+	castShadow    bool
+	receiveShadow bool
+	cell          [3]int32
+}
+
+// BatchChunk is one merged Renderable produced by a StaticBatcher.
+type BatchChunk struct {
+	// Renderable is the merged renderable with its vertices in world space.
+	Renderable *Renderable
+
+	// Bounds is the world space bounding rectangle of the chunk, which can
+	// be used for culling the chunk as a whole.
+	Bounds Rectangle3D
+
+	// SourceCount is the number of source renderables merged into the chunk.
+	SourceCount int
+}
+
+// StaticBatcher merges static renderables that share the same material
+// into combined vertex and index buffers to reduce the number of draw calls.
+// Source renderables need to be created while RetainGeometry is true so that
+// their vertex data is available on the CPU side.
+type StaticBatcher struct {
+	// ChunkSize is the size of the spatial grid cell used to split up the
+	// batches so that the chunks can still be culled. A value <= 0 puts
+	// everything with the same material into one chunk.
+	ChunkSize float32
+
+	sources []*Renderable
+}
+
+// NewStaticBatcher creates a new StaticBatcher with no spatial chunking.
+func NewStaticBatcher() *StaticBatcher {
+	sb := new(StaticBatcher)
+	sb.sources = make([]*Renderable, 0, 16)
+	return sb
+}
+
+// Add queues the renderable, and all of its children, to be merged during
+// Build. An error is returned if any of the renderables to be drawn doesn't
+// have a retained Geometry, uses a skeleton or isn't a triangle list.
+func (sb *StaticBatcher) Add(r *Renderable) error {
+	var err error
+	leaves := make([]*Renderable, 0, 1)
+	r.Map(func(node *Renderable) {
+		if err != nil || node.IsGroup {
+			return
+		}
+		if node.Core == nil || node.Core.Geometry == nil {
+			err = fmt.Errorf("renderable has no retained geometry; set RetainGeometry before creating it")
+			return
+		}
+		if node.Core.Skeleton != nil {
+			err = fmt.Errorf("renderables with a skeleton cannot be statically batched")
+			return
+		}
+		if node.Core.Topology != TopologyTriangles {
+			err = fmt.Errorf("only triangle list renderables can be statically batched")
+			return
+		}
+		leaves = append(leaves, node)
+	})
+	if err != nil {
+		return err
+	}
+
+	sb.sources = append(sb.sources, leaves...)
+	return nil
+}
+
+// Build merges all of the queued renderables into chunks grouped by material
+// and spatial grid cell and creates a new Renderable for each chunk. The
+// queued renderables are then cleared, but they are not destroyed.
+func (sb *StaticBatcher) Build() []*BatchChunk {
+	// keys tracks the order in which the chunks were first seen so that
+	// the output is stable between builds
+	groups := make(map[chunkKey][]*Renderable)
+	keys := make([]chunkKey, 0)
+	for _, r := range sb.sources {
+		if !r.IsVisible {
+			continue
+		}
+
+		key := chunkKey{material: getMaterialKey(r), castShadow: r.CastShadow, receiveShadow: r.ReceiveShadow}
+		if sb.ChunkSize > 0.0 {
+			key.cell = sb.getCell(r)
+		}
+		if _, okay := groups[key]; !okay {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	chunks := make([]*BatchChunk, 0, len(keys))
+	for _, key := range keys {
+		srcs := groups[key]
+		merged := new(Geometry)
+		for _, r := range srcs {
+			merged.Append(r.Core.Geometry.Transform(r.GetTransformMat4()))
+		}
+		if len(merged.Indexes) == 0 {
+			continue
+		}
+
+		chunk := new(BatchChunk)
+		chunk.Renderable = CreateFromGeometry(merged)
+		chunk.Bounds = chunk.Renderable.BoundingRect
+		chunk.SourceCount = len(srcs)
+
+		chunk.Renderable.Material = key.material.Shared
+		core := chunk.Renderable.Core
+		core.Shader = key.material.Shader
+		core.Tex0 = key.material.Tex0
+		core.Tex1 = key.material.Tex1
+		core.Lightmap = key.material.Lightmap
+		core.DiffuseColor = key.material.DiffuseColor
+		core.SpecularColor = key.material.SpecularColor
+		core.Shininess = key.material.Shininess
+		core.PBR = key.material.PBR
+		core.AlphaCutoff = key.material.AlphaCutoff
+		core.Transparent = key.material.Transparent
+		core.Layer = key.material.Layer
+		core.Blend = key.material.Blend
+		core.Cull = key.material.Cull
+		core.NoDepthTest = key.material.NoDepthTest
+		core.NoDepthWrite = key.material.NoDepthWrite
+		chunk.Renderable.CastShadow = key.castShadow
+		chunk.Renderable.ReceiveShadow = key.receiveShadow
+
+		chunks = append(chunks, chunk)
+	}
+
+	sb.sources = sb.sources[:0]
+	return chunks
+}
+
+// BuildRenderable works like Build but returns the chunks as the children
+// of one group Renderable, so that the whole static level can be drawn
+// with a single call while the chunks are still culled and drawn one per
+// material. The group's BoundingRect encloses all of the chunks.
+// Nil is returned if there was nothing to merge.
+func (sb *StaticBatcher) BuildRenderable() *Renderable {
+	chunks := sb.Build()
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	group := NewRenderable()
+	group.IsGroup = true
+	group.BoundingRect = chunks[0].Bounds
+	for _, chunk := range chunks {
+		group.AddChild(chunk.Renderable)
+		for i := 0; i < 3; i++ {
+			if chunk.Bounds.Bottom[i] < group.BoundingRect.Bottom[i] {
+				group.BoundingRect.Bottom[i] = chunk.Bounds.Bottom[i]
+			}
+			if chunk.Bounds.Top[i] > group.BoundingRect.Top[i] {
+				group.BoundingRect.Top[i] = chunk.Bounds.Top[i]
+			}
+		}
+	}
+	return group
+}
+
+// getCell returns the grid cell that the world space center of the
+// renderable's bounding rectangle falls into.
+func (sb *StaticBatcher) getCell(r *Renderable) [3]int32 {
+	b := r.BoundingRect
+	center := b.Bottom.Add(b.Top).Mul(0.5)
+	world := r.GetTransformMat4().Mul4x1(center.Vec4(1.0))
+	var cell [3]int32
+	for i := 0; i < 3; i++ {
+		cell[i] = int32(math.Floor(float64(world[i] / sb.ChunkSize)))
+	}
+	return cell
+}
+
+// getMaterialKey builds the material key for a renderable.
+func getMaterialKey(r *Renderable) materialKey {
+	if r.Material != nil {
+		return materialKey{Shared: r.Material, Lightmap: r.Core.Lightmap}
+	}
+
+	rc := r.Core
+	if len(rc.Uniforms) > 0 {
+		return materialKey{Shared: &rc.Material, Lightmap: rc.Lightmap}
+	}
+	return materialKey{
+		Shader:        rc.Shader,
+		Tex0:          rc.Tex0,
+		Tex1:          rc.Tex1,
+		Lightmap:      rc.Lightmap,
+		DiffuseColor:  rc.DiffuseColor,
+		SpecularColor: rc.SpecularColor,
+		Shininess:     rc.Shininess,
+		PBR:           rc.PBR,
+		AlphaCutoff:   rc.AlphaCutoff,
+		Transparent:   rc.Transparent,
+		Layer:         rc.Layer,
+		Blend:         rc.Blend,
+		Cull:          rc.Cull,
+		NoDepthTest:   rc.NoDepthTest,
+		NoDepthWrite:  rc.NoDepthWrite,
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	"time"
+)
+
+// Clock keeps track of the engine time for a frame so that subsystems like
+// animation, particles and tweens all advance by the same amount. The
+// scaled time can be slowed down, sped up or paused while the unscaled time
+// keeps running for things like menus.
+type Clock struct {
+	// TimeScale is multiplied against the frame delta to get the scaled
+	// delta; 1.0 is normal speed and 0.5 would be half speed.
+	TimeScale float64
+
+	isPaused      bool
+	delta         float64
+	unscaledDelta float64
+	time          float64
+	unscaledTime  float64
+	frameCount    uint64
+	lastTick      time.Time
+}
+
+// NewClock creates a new clock running at normal speed.
+func NewClock() *Clock {
+	c := new(Clock)
+	c.TimeScale = 1.0
+	return c
+}
+
+// Tick advances the clock by the wall time since the last call to Tick.
+// It should be called once at the start of every frame. The first call
+// advances the clock by zero.
+func (c *Clock) Tick() {
+	now := time.Now()
+	var frameDelta float64
+	if !c.lastTick.IsZero() {
+		frameDelta = now.Sub(c.lastTick).Seconds()
+	}
+	c.lastTick = now
+	c.Advance(frameDelta)
+}
+
+// Advance moves the clock forward by frameDelta seconds of unscaled time.
+// This can be used instead of Tick when the frame time comes from
+// elsewhere, such as a renderer's BeginFrame.
+func (c *Clock) Advance(frameDelta float64) {
+	c.unscaledDelta = frameDelta
+	c.unscaledTime += frameDelta
+	if c.isPaused {
+		c.delta = 0.0
+	} else {
+		c.delta = frameDelta * c.TimeScale
+	}
+	c.time += c.delta
+	c.frameCount++
+}
+
+// Delta returns the scaled time in seconds of the last frame. It is zero
+// while the clock is paused.
+func (c *Clock) Delta() float64 {
+	return c.delta
+}
+
+// UnscaledDelta returns the real time in seconds of the last frame,
+// ignoring the time scale and pausing.
+func (c *Clock) UnscaledDelta() float64 {
+	return c.unscaledDelta
+}
+
+// Time returns the total scaled time in seconds.
+func (c *Clock) Time() float64 {
+	return c.time
+}
+
+// UnscaledTime returns the total real time in seconds.
+func (c *Clock) UnscaledTime() float64 {
+	return c.unscaledTime
+}
+
+// FrameCount returns the number of times the clock has been advanced.
+func (c *Clock) FrameCount() uint64 {
+	return c.frameCount
+}
+
+// Pause stops the scaled time from advancing.
+func (c *Clock) Pause() {
+	c.isPaused = true
+}
+
+// Resume lets the scaled time advance again after a Pause.
+func (c *Clock) Resume() {
+	c.isPaused = false
+}
+
+// IsPaused returns true if the clock is paused.
+func (c *Clock) IsPaused() bool {
+	return c.isPaused
+}
@@ -0,0 +1,78 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+gombzconv converts OBJ, glTF and Collada files into gombz meshes and a
+fizzle component file that references them.
+
+Usage:
+
+	gombzconv [-o outdir] [-shader name] file ...
+
+Each input file becomes a component named after the file in the output
+directory, which defaults to the directory of the input file.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tbogdala/fizzle/convert"
+)
+
+var (
+	flagOutDir = flag.String("o", "", "the directory to write the converted files to")
+	flagShader = flag.String("shader", "Diffuse", "the shader name to set in the component material")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-o outdir] [-shader name] file ...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, filename := range flag.Args() {
+		if err := convertFile(filename); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// convertFile imports the file and exports it as a component.
+func convertFile(filename string) error {
+	scene, err := convert.ImportFile(filename)
+	if err != nil {
+		return err
+	}
+
+	outDir := *flagOutDir
+	if outDir == "" {
+		outDir = filepath.Dir(filename)
+	}
+	componentFile, err := convert.ExportComponent(scene, outDir, *flagShader)
+	if err != nil {
+		return fmt.Errorf("Failed to export %s.\n%v", filename, err)
+	}
+
+	bones, animations := 0, 0
+	for _, sm := range scene.Meshes {
+		bones += len(sm.Mesh.Bones)
+		animations += len(sm.Mesh.Animations)
+	}
+	fmt.Printf("%s -> %s (%d meshes, %d materials, %d bones, %d animations)\n", filename, componentFile,
+		len(scene.Meshes), len(scene.Materials), bones, animations)
+	return nil
+}
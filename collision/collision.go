@@ -0,0 +1,135 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The collision module builds collision shapes out of Renderable geometry:
+convex hulls with quickhull, simplified triangle meshes, and fitted boxes
+and capsules. The shapes are plain data in the Renderable's model space
+with support functions and ray casts, so physics integrations can convert
+them to their own collider types and ray queries can test them directly.
+
+The Renderables need to be created with fizzle.RetainGeometry turned on
+so that the vertex data is still available on the CPU.
+
+*/
+
+package collision
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// RaycastHit is where a ray hit a shape.
+type RaycastHit struct {
+	// Distance is how far along the ray the hit is, in multiples of the
+	// ray direction's length.
+	Distance float32
+	Point    mgl.Vec3
+	Normal   mgl.Vec3
+}
+
+// Shape is the common interface of the collision shapes.
+type Shape interface {
+	// Support returns the point of the shape furthest in the direction,
+	// which is what GJK style collision detection needs.
+	Support(direction mgl.Vec3) mgl.Vec3
+
+	// Raycast returns the first hit of the ray with the shape.
+	Raycast(origin mgl.Vec3, direction mgl.Vec3) (RaycastHit, bool)
+
+	// Bounds returns the axis aligned bounding box of the shape.
+	Bounds() (mgl.Vec3, mgl.Vec3)
+}
+
+// GatherPoints returns all of the vertex positions of the Renderable and
+// its children in the Renderable's model space.
+func GatherPoints(r *fizzle.Renderable) ([]mgl.Vec3, error) {
+	points := make([]mgl.Vec3, 0, 256)
+	err := mapGeometry(r, func(g *fizzle.Geometry, transform mgl.Mat4) {
+		for i := 0; i+2 < len(g.Vertices); i += 3 {
+			v := mgl.Vec4{g.Vertices[i], g.Vertices[i+1], g.Vertices[i+2], 1.0}
+			points = append(points, transform.Mul4x1(v).Vec3())
+		}
+	})
+	return points, err
+}
+
+// mapGeometry calls f with the geometry of every node in the Renderable
+// tree and the transform from that node's space to the root's model space.
+func mapGeometry(root *fizzle.Renderable, f func(g *fizzle.Geometry, transform mgl.Mat4)) error {
+	rootInverse := root.GetTransformMat4().Inv()
+	var err error
+	root.Map(func(r *fizzle.Renderable) {
+		if r.IsGroup || err != nil {
+			return
+		}
+		if r.Core == nil || r.Core.Geometry == nil {
+			err = fmt.Errorf("the Renderable has no geometry; fizzle.RetainGeometry must be set before creating it")
+			return
+		}
+		f(r.Core.Geometry, rootInverse.Mul4(r.GetTransformMat4()))
+	})
+	return err
+}
+
+// pointBounds returns the axis aligned box around the points.
+func pointBounds(points []mgl.Vec3) (mgl.Vec3, mgl.Vec3) {
+	if len(points) == 0 {
+		return mgl.Vec3{}, mgl.Vec3{}
+	}
+	min, max := points[0], points[0]
+	for _, p := range points[1:] {
+		for j := 0; j < 3; j++ {
+			if p[j] < min[j] {
+				min[j] = p[j]
+			}
+			if p[j] > max[j] {
+				max[j] = p[j]
+			}
+		}
+	}
+	return min, max
+}
+
+// supportPoint returns the point with the largest projection on the direction.
+func supportPoint(points []mgl.Vec3, direction mgl.Vec3) mgl.Vec3 {
+	var best mgl.Vec3
+	bestDot := float32(0.0)
+	for i, p := range points {
+		d := p.Dot(direction)
+		if i == 0 || d > bestDot {
+			best, bestDot = p, d
+		}
+	}
+	return best
+}
+
+// rayTriangle intersects the ray with the triangle using the Möller-Trumbore
+// algorithm and returns the distance along the ray.
+func rayTriangle(origin, direction, a, b, c mgl.Vec3) (float32, bool) {
+	const epsilon = 1e-7
+	e1 := b.Sub(a)
+	e2 := c.Sub(a)
+	p := direction.Cross(e2)
+	det := e1.Dot(p)
+	if det > -epsilon && det < epsilon {
+		return 0.0, false
+	}
+	invDet := 1.0 / det
+	s := origin.Sub(a)
+	u := s.Dot(p) * invDet
+	if u < 0.0 || u > 1.0 {
+		return 0.0, false
+	}
+	q := s.Cross(e1)
+	v := direction.Dot(q) * invDet
+	if v < 0.0 || u+v > 1.0 {
+		return 0.0, false
+	}
+	t := e2.Dot(q) * invDet
+	return t, t >= 0.0
+}
@@ -0,0 +1,341 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package collision
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Box is an oriented box.
+type Box struct {
+	Center   mgl.Vec3
+	HalfSize mgl.Vec3
+	Rotation mgl.Quat
+}
+
+// FitAABB returns the axis aligned box around the points.
+func FitAABB(points []mgl.Vec3) Box {
+	min, max := pointBounds(points)
+	return Box{
+		Center:   min.Add(max).Mul(0.5),
+		HalfSize: max.Sub(min).Mul(0.5),
+		Rotation: mgl.QuatIdent(),
+	}
+}
+
+// FitBox returns an oriented box around the points aligned to their
+// principal axes. For long or diagonal shapes this is much tighter than an
+// axis aligned box. Passing the points of a Hull gives a better fit than
+// all of the mesh vertices since interior points don't skew the axes.
+func FitBox(points []mgl.Vec3) Box {
+	if len(points) == 0 {
+		return Box{Rotation: mgl.QuatIdent()}
+	}
+	axes, _ := principalAxes(points)
+
+	var min, max mgl.Vec3
+	for i, p := range points {
+		for j := 0; j < 3; j++ {
+			d := p.Dot(axes[j])
+			if i == 0 || d < min[j] {
+				min[j] = d
+			}
+			if i == 0 || d > max[j] {
+				max[j] = d
+			}
+		}
+	}
+
+	var b Box
+	for j := 0; j < 3; j++ {
+		b.Center = b.Center.Add(axes[j].Mul((min[j] + max[j]) * 0.5))
+		b.HalfSize[j] = (max[j] - min[j]) * 0.5
+	}
+	b.Rotation = mgl.Mat4ToQuat(mgl.Mat3FromCols(axes[0], axes[1], axes[2]).Mat4())
+	return b
+}
+
+// Support returns the corner of the box furthest in the direction.
+func (b Box) Support(direction mgl.Vec3) mgl.Vec3 {
+	local := b.Rotation.Conjugate().Rotate(direction)
+	corner := b.HalfSize
+	for j := 0; j < 3; j++ {
+		if local[j] < 0.0 {
+			corner[j] = -corner[j]
+		}
+	}
+	return b.Center.Add(b.Rotation.Rotate(corner))
+}
+
+// Bounds returns the axis aligned bounding box around the oriented box.
+func (b Box) Bounds() (mgl.Vec3, mgl.Vec3) {
+	var extent mgl.Vec3
+	for j := 0; j < 3; j++ {
+		var axis mgl.Vec3
+		axis[j] = 1.0
+		extent[j] = b.Support(axis).Sub(b.Center).Dot(axis)
+	}
+	return b.Center.Sub(extent), b.Center.Add(extent)
+}
+
+// Raycast intersects the ray with the box in the box's space.
+func (b Box) Raycast(origin mgl.Vec3, direction mgl.Vec3) (RaycastHit, bool) {
+	var hit RaycastHit
+	inv := b.Rotation.Conjugate()
+	o := inv.Rotate(origin.Sub(b.Center))
+	d := inv.Rotate(direction)
+
+	enter := float32(0.0)
+	exit := float32(math.MaxFloat32)
+	var normal mgl.Vec3
+	for j := 0; j < 3; j++ {
+		if d[j] > -1e-7 && d[j] < 1e-7 {
+			if o[j] < -b.HalfSize[j] || o[j] > b.HalfSize[j] {
+				return hit, false
+			}
+			continue
+		}
+		t1 := (-b.HalfSize[j] - o[j]) / d[j]
+		t2 := (b.HalfSize[j] - o[j]) / d[j]
+		sign := float32(-1.0)
+		if t1 > t2 {
+			t1, t2 = t2, t1
+			sign = 1.0
+		}
+		if t1 > enter {
+			enter = t1
+			normal = mgl.Vec3{}
+			normal[j] = sign
+		}
+		if t2 < exit {
+			exit = t2
+		}
+		if enter > exit {
+			return hit, false
+		}
+	}
+
+	hit.Distance = enter
+	hit.Point = origin.Add(direction.Mul(enter))
+	hit.Normal = b.Rotation.Rotate(normal)
+	return hit, true
+}
+
+// Capsule is a line segment swept by a sphere.
+type Capsule struct {
+	A      mgl.Vec3
+	B      mgl.Vec3
+	Radius float32
+}
+
+// FitCapsule returns a capsule around the points aligned to their longest
+// principal axis, which suits characters and limbs.
+func FitCapsule(points []mgl.Vec3) Capsule {
+	if len(points) == 0 {
+		return Capsule{}
+	}
+	axes, mean := principalAxes(points)
+	axis := axes[0]
+
+	// the radius covers the point furthest from the axis
+	var radius float32
+	for _, p := range points {
+		v := p.Sub(mean)
+		if d := v.Sub(axis.Mul(v.Dot(axis))).Len(); d > radius {
+			radius = d
+		}
+	}
+
+	// pull the end points in as far as the hemispheres still cover the points
+	top := float32(-math.MaxFloat32)
+	bottom := float32(math.MaxFloat32)
+	for _, p := range points {
+		v := p.Sub(mean)
+		t := v.Dot(axis)
+		d := v.Sub(axis.Mul(t)).Len()
+		reach := float32(math.Sqrt(math.Max(0.0, float64(radius*radius-d*d))))
+		if t-reach > top {
+			top = t - reach
+		}
+		if t+reach < bottom {
+			bottom = t + reach
+		}
+	}
+	if top < bottom {
+		top = (top + bottom) * 0.5
+		bottom = top
+	}
+
+	return Capsule{A: mean.Add(axis.Mul(bottom)), B: mean.Add(axis.Mul(top)), Radius: radius}
+}
+
+// Support returns the point of the capsule furthest in the direction.
+func (c Capsule) Support(direction mgl.Vec3) mgl.Vec3 {
+	end := c.A
+	if direction.Dot(c.B.Sub(c.A)) > 0.0 {
+		end = c.B
+	}
+	if direction.Len() < 1e-7 {
+		return end
+	}
+	return end.Add(direction.Normalize().Mul(c.Radius))
+}
+
+// Bounds returns the axis aligned bounding box of the capsule.
+func (c Capsule) Bounds() (mgl.Vec3, mgl.Vec3) {
+	r := mgl.Vec3{c.Radius, c.Radius, c.Radius}
+	min, max := pointBounds([]mgl.Vec3{c.A, c.B})
+	return min.Sub(r), max.Add(r)
+}
+
+// Raycast intersects the ray with the capsule's cylinder and end spheres.
+func (c Capsule) Raycast(origin mgl.Vec3, direction mgl.Vec3) (RaycastHit, bool) {
+	var hit RaycastHit
+	found := false
+	try := func(t float32, normal mgl.Vec3) {
+		if t >= 0.0 && (!found || t < hit.Distance) {
+			found = true
+			hit.Distance = t
+			hit.Normal = normal
+		}
+	}
+
+	// the cylinder between the end points
+	axis := c.B.Sub(c.A)
+	length := axis.Len()
+	if length > 1e-7 {
+		axis = axis.Mul(1.0 / length)
+		oc := origin.Sub(c.A)
+		dPerp := direction.Sub(axis.Mul(direction.Dot(axis)))
+		oPerp := oc.Sub(axis.Mul(oc.Dot(axis)))
+		qa := dPerp.Dot(dPerp)
+		qb := 2.0 * dPerp.Dot(oPerp)
+		qc := oPerp.Dot(oPerp) - c.Radius*c.Radius
+		if t, okay := smallestRoot(qa, qb, qc); okay {
+			p := origin.Add(direction.Mul(t))
+			s := p.Sub(c.A).Dot(axis)
+			if s >= 0.0 && s <= length {
+				try(t, p.Sub(c.A.Add(axis.Mul(s))).Normalize())
+			}
+		}
+	}
+
+	// the end spheres
+	for _, center := range [2]mgl.Vec3{c.A, c.B} {
+		oc := origin.Sub(center)
+		if t, okay := smallestRoot(direction.Dot(direction), 2.0*oc.Dot(direction), oc.Dot(oc)-c.Radius*c.Radius); okay {
+			try(t, origin.Add(direction.Mul(t)).Sub(center).Normalize())
+		}
+	}
+
+	if found {
+		hit.Point = origin.Add(direction.Mul(hit.Distance))
+	}
+	return hit, found
+}
+
+// smallestRoot returns the smallest non-negative root of a*t^2 + b*t + c.
+func smallestRoot(a, b, c float32) (float32, bool) {
+	if a < 1e-12 {
+		return 0.0, false
+	}
+	disc := b*b - 4.0*a*c
+	if disc < 0.0 {
+		return 0.0, false
+	}
+	sq := float32(math.Sqrt(float64(disc)))
+	t0 := (-b - sq) / (2.0 * a)
+	t1 := (-b + sq) / (2.0 * a)
+	if t0 >= 0.0 {
+		return t0, true
+	}
+	return t1, t1 >= 0.0
+}
+
+// principalAxes returns the unit eigenvectors of the covariance of the
+// points sorted from the largest spread to the smallest, as a right handed
+// basis, along with the mean of the points.
+func principalAxes(points []mgl.Vec3) ([3]mgl.Vec3, mgl.Vec3) {
+	var mean mgl.Vec3
+	for _, p := range points {
+		mean = mean.Add(p)
+	}
+	mean = mean.Mul(1.0 / float32(len(points)))
+
+	var cov [3][3]float64
+	for _, p := range points {
+		v := p.Sub(mean)
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				cov[i][j] += float64(v[i] * v[j])
+			}
+		}
+	}
+
+	values, vectors := jacobiEigen(cov)
+
+	// sort by eigenvalue, largest first
+	order := [3]int{0, 1, 2}
+	for i := 0; i < 3; i++ {
+		for j := i + 1; j < 3; j++ {
+			if values[order[j]] > values[order[i]] {
+				order[i], order[j] = order[j], order[i]
+			}
+		}
+	}
+
+	var axes [3]mgl.Vec3
+	for i := 0; i < 2; i++ {
+		k := order[i]
+		axes[i] = mgl.Vec3{float32(vectors[0][k]), float32(vectors[1][k]), float32(vectors[2][k])}.Normalize()
+	}
+	axes[2] = axes[0].Cross(axes[1]).Normalize()
+	return axes, mean
+}
+
+// jacobiEigen diagonalizes the symmetric matrix with Jacobi rotations and
+// returns the eigenvalues and the eigenvectors as the matrix columns.
+func jacobiEigen(a [3][3]float64) ([3]float64, [3][3]float64) {
+	v := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	for sweep := 0; sweep < 32; sweep++ {
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		if off < 1e-18 {
+			break
+		}
+		for p := 0; p < 2; p++ {
+			for q := p + 1; q < 3; q++ {
+				if math.Abs(a[p][q]) < 1e-18 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2.0 * a[p][q])
+				t := 1.0 / (math.Abs(theta) + math.Sqrt(theta*theta+1.0))
+				if theta < 0.0 {
+					t = -t
+				}
+				c := 1.0 / math.Sqrt(t*t+1.0)
+				s := t * c
+
+				// a = J^T * a * J
+				for k := 0; k < 3; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 3; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 3; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+	return [3]float64{a[0][0], a[1][1], a[2][2]}, v
+}
@@ -0,0 +1,343 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package collision
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// Hull is a convex polyhedron with outward facing triangles.
+type Hull struct {
+	// Points are the vertices of the hull.
+	Points []mgl.Vec3
+
+	// Faces are the triangles of the hull as indexes into Points, wound
+	// counter-clockwise when seen from outside.
+	Faces [][3]int
+
+	// Normals are the outward unit normals of the faces.
+	Normals []mgl.Vec3
+
+	// epsilon is the distance from a face plane within which points count
+	// as on the plane; it's set by QuickHull from the size of the input.
+	epsilon float32
+}
+
+// hullFace is a triangle of the hull under construction.
+type hullFace struct {
+	v       [3]int
+	normal  mgl.Vec3
+	offset  float32
+	outside []int
+	removed bool
+}
+
+// distance returns the signed distance of the point above the face plane.
+func (f *hullFace) distance(p mgl.Vec3) float32 {
+	return f.normal.Dot(p) - f.offset
+}
+
+// NewHullFromRenderable builds the convex hull of all of the vertices of
+// the Renderable and its children in its model space.
+func NewHullFromRenderable(r *fizzle.Renderable) (*Hull, error) {
+	points, err := GatherPoints(r)
+	if err != nil {
+		return nil, err
+	}
+	return QuickHull(points)
+}
+
+// QuickHull builds the convex hull of the points. An error is returned if
+// the points are all on a plane, since they don't enclose a volume.
+func QuickHull(points []mgl.Vec3) (*Hull, error) {
+	if len(points) < 4 {
+		return nil, fmt.Errorf("a convex hull needs at least 4 points, got %d", len(points))
+	}
+
+	min, max := pointBounds(points)
+	extent := max.Sub(min)
+	epsilon := 1e-5 * (float32(math.Abs(float64(extent[0]))) + float32(math.Abs(float64(extent[1]))) + float32(math.Abs(float64(extent[2]))))
+
+	initial, err := hullSimplex(points, epsilon)
+	if err != nil {
+		return nil, err
+	}
+
+	// edges maps each directed edge to the live face that has it, so the
+	// neighbor across an edge is the face with the reversed edge
+	faces := make([]*hullFace, 0, 64)
+	edges := make(map[[2]int]*hullFace)
+	newFace := func(a, b, c int) *hullFace {
+		f := &hullFace{v: [3]int{a, b, c}}
+		f.normal = points[b].Sub(points[a]).Cross(points[c].Sub(points[a])).Normalize()
+		f.offset = f.normal.Dot(points[a])
+		faces = append(faces, f)
+		return f
+	}
+	link := func(f *hullFace) {
+		for e := 0; e < 3; e++ {
+			edges[[2]int{f.v[e], f.v[(e+1)%3]}] = f
+		}
+	}
+
+	// make the tetrahedron with its faces pointing away from its center
+	i0, i1, i2, i3 := initial[0], initial[1], initial[2], initial[3]
+	center := points[i0].Add(points[i1]).Add(points[i2]).Add(points[i3]).Mul(0.25)
+	for _, tri := range [4][3]int{{i0, i1, i2}, {i0, i3, i1}, {i0, i2, i3}, {i1, i3, i2}} {
+		f := newFace(tri[0], tri[1], tri[2])
+		if f.distance(center) > 0.0 {
+			f.v[1], f.v[2] = f.v[2], f.v[1]
+			f.normal = f.normal.Mul(-1.0)
+			f.offset = -f.offset
+		}
+		link(f)
+	}
+
+	// assign every point to the first face it is in front of
+	assign := func(candidates []int, targets []*hullFace) {
+		for _, pi := range candidates {
+			for _, f := range targets {
+				if f.distance(points[pi]) > epsilon {
+					f.outside = append(f.outside, pi)
+					break
+				}
+			}
+		}
+	}
+	all := make([]int, 0, len(points))
+	for i := range points {
+		if i != i0 && i != i1 && i != i2 && i != i3 {
+			all = append(all, i)
+		}
+	}
+	assign(all, faces)
+
+	for {
+		// find a face that still has points outside of it
+		var current *hullFace
+		for _, f := range faces {
+			if !f.removed && len(f.outside) > 0 {
+				current = f
+				break
+			}
+		}
+		if current == nil {
+			break
+		}
+
+		// the furthest point is guaranteed to be on the final hull
+		eye := current.outside[0]
+		eyeDist := current.distance(points[eye])
+		for _, pi := range current.outside[1:] {
+			if d := current.distance(points[pi]); d > eyeDist {
+				eye, eyeDist = pi, d
+			}
+		}
+
+		// flood out from the current face to find the faces the eye point
+		// can see; only following neighbors keeps the visible region in one
+		// piece when rounding makes faces elsewhere look visible too. Faces
+		// the eye is just barely in front of are replaced as well, since
+		// keeping them would leave a slight concavity that hides the faces
+		// beyond it from the flood.
+		visible := []*hullFace{current}
+		current.removed = true
+		horizon := make([][2]int, 0, 16)
+		for i := 0; i < len(visible); i++ {
+			f := visible[i]
+			for e := 0; e < 3; e++ {
+				a, b := f.v[e], f.v[(e+1)%3]
+				neighbor := edges[[2]int{b, a}]
+				if neighbor == nil || neighbor.removed {
+					continue
+				}
+				if neighbor.distance(points[eye]) > 0.0 {
+					neighbor.removed = true
+					visible = append(visible, neighbor)
+				}
+			}
+		}
+
+		// the horizon is made of the visible edges whose neighbor stayed
+		orphans := make([]int, 0, 16)
+		for _, f := range visible {
+			for e := 0; e < 3; e++ {
+				a, b := f.v[e], f.v[(e+1)%3]
+				delete(edges, [2]int{a, b})
+				if neighbor := edges[[2]int{b, a}]; neighbor != nil && !neighbor.removed {
+					horizon = append(horizon, [2]int{a, b})
+				}
+			}
+			for _, pi := range f.outside {
+				if pi != eye {
+					orphans = append(orphans, pi)
+				}
+			}
+			f.outside = nil
+		}
+
+		created := make([]*hullFace, 0, len(horizon))
+		for _, edge := range horizon {
+			f := newFace(edge[0], edge[1], eye)
+			link(f)
+			created = append(created, f)
+		}
+		assign(orphans, created)
+
+		// compact the face list now and then so it doesn't fill with removed faces
+		if len(faces) > 256 {
+			live := faces[:0]
+			for _, f := range faces {
+				if !f.removed {
+					live = append(live, f)
+				}
+			}
+			faces = live
+		}
+	}
+
+	// build the final hull with only the points that are used
+	hull := new(Hull)
+	hull.epsilon = epsilon
+	remap := make(map[int]int)
+	for _, f := range faces {
+		if f.removed {
+			continue
+		}
+		var tri [3]int
+		for j, pi := range f.v {
+			ni, okay := remap[pi]
+			if !okay {
+				ni = len(hull.Points)
+				remap[pi] = ni
+				hull.Points = append(hull.Points, points[pi])
+			}
+			tri[j] = ni
+		}
+		hull.Faces = append(hull.Faces, tri)
+		hull.Normals = append(hull.Normals, f.normal)
+	}
+	return hull, nil
+}
+
+// hullSimplex finds four points that make a tetrahedron with a volume to
+// start the hull from.
+func hullSimplex(points []mgl.Vec3, epsilon float32) ([4]int, error) {
+	var result [4]int
+
+	// the two most distant of the extreme points on each axis
+	var extremes [6]int
+	for i, p := range points {
+		for axis := 0; axis < 3; axis++ {
+			if p[axis] < points[extremes[axis*2]][axis] {
+				extremes[axis*2] = i
+			}
+			if p[axis] > points[extremes[axis*2+1]][axis] {
+				extremes[axis*2+1] = i
+			}
+		}
+	}
+	best := float32(-1.0)
+	for _, a := range extremes {
+		for _, b := range extremes {
+			if d := points[a].Sub(points[b]).LenSqr(); d > best {
+				best = d
+				result[0], result[1] = a, b
+			}
+		}
+	}
+	if best <= epsilon*epsilon {
+		return result, fmt.Errorf("the points are all in the same place")
+	}
+
+	// the point furthest from the line
+	a, b := points[result[0]], points[result[1]]
+	dir := b.Sub(a).Normalize()
+	best = -1.0
+	for i, p := range points {
+		ap := p.Sub(a)
+		if d := ap.Sub(dir.Mul(ap.Dot(dir))).LenSqr(); d > best {
+			best = d
+			result[2] = i
+		}
+	}
+	if best <= epsilon*epsilon {
+		return result, fmt.Errorf("the points are all on a line")
+	}
+
+	// the point furthest from the plane
+	normal := b.Sub(a).Cross(points[result[2]].Sub(a)).Normalize()
+	best = -1.0
+	for i, p := range points {
+		if d := float32(math.Abs(float64(normal.Dot(p.Sub(a))))); d > best {
+			best = d
+			result[3] = i
+		}
+	}
+	if best <= epsilon {
+		return result, fmt.Errorf("the points are all on a plane")
+	}
+	return result, nil
+}
+
+// Support returns the hull point furthest in the direction.
+func (h *Hull) Support(direction mgl.Vec3) mgl.Vec3 {
+	return supportPoint(h.Points, direction)
+}
+
+// Bounds returns the axis aligned bounding box of the hull.
+func (h *Hull) Bounds() (mgl.Vec3, mgl.Vec3) {
+	return pointBounds(h.Points)
+}
+
+// Contains returns true if the point is inside the hull. Points within the
+// tolerance the hull was built with count as inside, so the hull contains
+// its own vertices and the points it was built from.
+func (h *Hull) Contains(p mgl.Vec3) bool {
+	for i, n := range h.Normals {
+		if n.Dot(p.Sub(h.Points[h.Faces[i][0]])) > h.epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// Raycast clips the ray against the face planes of the hull. A ray that
+// starts inside the hull hits at distance zero with a zero normal.
+func (h *Hull) Raycast(origin mgl.Vec3, direction mgl.Vec3) (RaycastHit, bool) {
+	var hit RaycastHit
+	enter := float32(0.0)
+	exit := float32(math.MaxFloat32)
+	for i, n := range h.Normals {
+		dist := n.Dot(origin.Sub(h.Points[h.Faces[i][0]]))
+		denom := n.Dot(direction)
+		if denom > -1e-7 && denom < 1e-7 {
+			// parallel to the plane, so it's either always in or out
+			if dist > 0.0 {
+				return hit, false
+			}
+			continue
+		}
+		t := -dist / denom
+		if denom < 0.0 {
+			if t > enter {
+				enter = t
+				hit.Normal = n
+			}
+		} else if t < exit {
+			exit = t
+		}
+		if enter > exit {
+			return hit, false
+		}
+	}
+
+	hit.Distance = enter
+	hit.Point = origin.Add(direction.Mul(enter))
+	return hit, true
+}
@@ -0,0 +1,33 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package collision
+
+import (
+	"math/rand"
+	"testing"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// TestQuickHullContainsInput checks that hulls of random point clouds
+// contain every one of the points they were built from.
+func TestQuickHullContainsInput(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		points := make([]mgl.Vec3, 100+rng.Intn(400))
+		for i := range points {
+			points[i] = mgl.Vec3{rng.Float32()*2.0 - 1.0, rng.Float32()*2.0 - 1.0, rng.Float32()*2.0 - 1.0}
+		}
+
+		hull, err := QuickHull(points)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		for i, p := range points {
+			if !hull.Contains(p) {
+				t.Fatalf("seed %d: input point %d %v is outside of the hull", seed, i, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package collision
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// TriMesh is a triangle mesh collider, normally used for static level geometry.
+type TriMesh struct {
+	Vertices []mgl.Vec3
+
+	// Indexes are the vertex indexes of each triangle.
+	Indexes []uint32
+
+	min mgl.Vec3
+	max mgl.Vec3
+}
+
+// NewTriMesh creates a triangle mesh from the vertices and indexes.
+func NewTriMesh(vertices []mgl.Vec3, indexes []uint32) *TriMesh {
+	tm := new(TriMesh)
+	tm.Vertices = vertices
+	tm.Indexes = indexes
+	tm.min, tm.max = pointBounds(vertices)
+	return tm
+}
+
+// NewTriMeshFromRenderable combines the triangles of the Renderable and its
+// children into one mesh in the Renderable's model space.
+func NewTriMeshFromRenderable(r *fizzle.Renderable) (*TriMesh, error) {
+	vertices := make([]mgl.Vec3, 0, 256)
+	indexes := make([]uint32, 0, 256)
+	err := mapGeometry(r, func(g *fizzle.Geometry, transform mgl.Mat4) {
+		base := uint32(len(vertices))
+		for i := 0; i+2 < len(g.Vertices); i += 3 {
+			v := mgl.Vec4{g.Vertices[i], g.Vertices[i+1], g.Vertices[i+2], 1.0}
+			vertices = append(vertices, transform.Mul4x1(v).Vec3())
+		}
+		for _, idx := range g.Indexes {
+			indexes = append(indexes, base+idx)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewTriMesh(vertices, indexes), nil
+}
+
+// TriangleCount returns the number of triangles in the mesh.
+func (tm *TriMesh) TriangleCount() int {
+	return len(tm.Indexes) / 3
+}
+
+// Simplify returns a new mesh with the vertices clustered into a grid of
+// cells of the given size. Each cell's vertices are merged into their
+// average and triangles that collapse are dropped, which gives a cheaper
+// collider that still follows the shape to within about the cell size.
+func (tm *TriMesh) Simplify(cellSize float32) *TriMesh {
+	type cell [3]int32
+	cellIndex := make(map[cell]uint32)
+	sums := make([]mgl.Vec3, 0, len(tm.Vertices)/2)
+	counts := make([]float32, 0, len(tm.Vertices)/2)
+	remap := make([]uint32, len(tm.Vertices))
+
+	for i, v := range tm.Vertices {
+		c := cell{
+			int32(math.Floor(float64(v[0] / cellSize))),
+			int32(math.Floor(float64(v[1] / cellSize))),
+			int32(math.Floor(float64(v[2] / cellSize))),
+		}
+		ci, okay := cellIndex[c]
+		if !okay {
+			ci = uint32(len(sums))
+			cellIndex[c] = ci
+			sums = append(sums, mgl.Vec3{})
+			counts = append(counts, 0.0)
+		}
+		sums[ci] = sums[ci].Add(v)
+		counts[ci]++
+		remap[i] = ci
+	}
+
+	vertices := make([]mgl.Vec3, len(sums))
+	for i := range sums {
+		vertices[i] = sums[i].Mul(1.0 / counts[i])
+	}
+
+	type triKey [3]uint32
+	seen := make(map[triKey]bool)
+	indexes := make([]uint32, 0, len(tm.Indexes))
+	for i := 0; i+2 < len(tm.Indexes); i += 3 {
+		a, b, c := remap[tm.Indexes[i]], remap[tm.Indexes[i+1]], remap[tm.Indexes[i+2]]
+		if a == b || b == c || a == c {
+			continue
+		}
+
+		// the same triangle can come out of several source triangles, in
+		// any rotation of its winding
+		key := triKey{a, b, c}
+		for key[0] > key[1] || key[0] > key[2] {
+			key = triKey{key[1], key[2], key[0]}
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		indexes = append(indexes, a, b, c)
+	}
+
+	return NewTriMesh(vertices, indexes)
+}
+
+// Support returns the mesh vertex furthest in the direction.
+func (tm *TriMesh) Support(direction mgl.Vec3) mgl.Vec3 {
+	return supportPoint(tm.Vertices, direction)
+}
+
+// Bounds returns the axis aligned bounding box of the mesh.
+func (tm *TriMesh) Bounds() (mgl.Vec3, mgl.Vec3) {
+	return tm.min, tm.max
+}
+
+// Raycast returns the closest triangle hit by the ray. Triangles are hit
+// from both sides and the normal faces the ray.
+func (tm *TriMesh) Raycast(origin mgl.Vec3, direction mgl.Vec3) (RaycastHit, bool) {
+	var hit RaycastHit
+	found := false
+	for i := 0; i+2 < len(tm.Indexes); i += 3 {
+		a := tm.Vertices[tm.Indexes[i]]
+		b := tm.Vertices[tm.Indexes[i+1]]
+		c := tm.Vertices[tm.Indexes[i+2]]
+		t, okay := rayTriangle(origin, direction, a, b, c)
+		if !okay || (found && t >= hit.Distance) {
+			continue
+		}
+		found = true
+		hit.Distance = t
+		hit.Normal = b.Sub(a).Cross(c.Sub(a)).Normalize()
+	}
+	if !found {
+		return hit, false
+	}
+
+	if hit.Normal.Dot(direction) > 0.0 {
+		hit.Normal = hit.Normal.Mul(-1.0)
+	}
+	hit.Point = origin.Add(direction.Mul(hit.Distance))
+	return hit, true
+}
@@ -0,0 +1,44 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// SRGBToLinear converts a color picked in sRGB, like the colors of color
+// pickers and image editors, to linear space for shaders that light in
+// linear space. The alpha is left as it is.
+func SRGBToLinear(c mgl.Vec4) mgl.Vec4 {
+	for i := 0; i < 3; i++ {
+		c[i] = srgbToLinear(c[i])
+	}
+	return c
+}
+
+// LinearToSRGB converts a linear color to sRGB. The alpha is left as it is.
+func LinearToSRGB(c mgl.Vec4) mgl.Vec4 {
+	for i := 0; i < 3; i++ {
+		c[i] = linearToSRGB(c[i])
+	}
+	return c
+}
+
+// srgbToLinear applies the sRGB decoding curve to a channel.
+func srgbToLinear(v float32) float32 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return float32(math.Pow(float64((v+0.055)/1.055), 2.4))
+}
+
+// linearToSRGB applies the sRGB encoding curve to a channel.
+func linearToSRGB(v float32) float32 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return float32(1.055*math.Pow(float64(v), 1.0/2.4) - 0.055)
+}
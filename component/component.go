@@ -26,10 +26,10 @@ type ComponentMesh struct {
 	Offset mgl.Vec3
 
 	// Parent is the owning Component object
-	Parent *Component
+	Parent *Component `json:"-"`
 
 	// SrcMesh is the cached mesh data either from SrcFile or BinFile
-	SrcMesh *gombz.Mesh
+	SrcMesh *gombz.Mesh `json:"-"`
 }
 
 // ComponentChildRef defines a reference to another component JSON file
@@ -176,7 +176,6 @@ func (c *Component) GetRenderable(tm *fizzle.TextureManager, shaders map[string]
 		// assign material properties if specified
 		if c.Material != nil {
 			cmRenderable.Core.DiffuseColor = c.Material.Diffuse
-			cmRenderable.ShaderName = c.Material.ShaderName
 			loadedShader, okay := shaders[c.Material.ShaderName]
 			if okay {
 				cmRenderable.Core.Shader = loadedShader
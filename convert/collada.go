@@ -0,0 +1,541 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package convert
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+type daeDocument struct {
+	UpAxis       string           `xml:"asset>up_axis"`
+	Images       []daeImage       `xml:"library_images>image"`
+	Effects      []daeEffect      `xml:"library_effects>effect"`
+	Materials    []daeMaterial    `xml:"library_materials>material"`
+	Geometries   []daeGeometry    `xml:"library_geometries>geometry"`
+	Controllers  []daeController  `xml:"library_controllers>controller"`
+	VisualScenes []daeVisualScene `xml:"library_visual_scenes>visual_scene"`
+}
+
+type daeImage struct {
+	ID       string `xml:"id,attr"`
+	InitFrom struct {
+		Path string `xml:",chardata"`
+		Ref  string `xml:"ref"`
+	} `xml:"init_from"`
+}
+
+type daeMaterial struct {
+	ID             string `xml:"id,attr"`
+	Name           string `xml:"name,attr"`
+	InstanceEffect struct {
+		URL string `xml:"url,attr"`
+	} `xml:"instance_effect"`
+}
+
+type daeEffect struct {
+	ID        string        `xml:"id,attr"`
+	NewParams []daeNewParam `xml:"profile_COMMON>newparam"`
+	Phong     *daeShading   `xml:"profile_COMMON>technique>phong"`
+	Blinn     *daeShading   `xml:"profile_COMMON>technique>blinn"`
+	Lambert   *daeShading   `xml:"profile_COMMON>technique>lambert"`
+}
+
+type daeNewParam struct {
+	Sid             string `xml:"sid,attr"`
+	SurfaceInitFrom string `xml:"surface>init_from"`
+	SamplerSource   string `xml:"sampler2D>source"`
+	SamplerImage    struct {
+		URL string `xml:"url,attr"`
+	} `xml:"sampler2D>instance_image"`
+}
+
+type daeShading struct {
+	Diffuse   daeColorOrTexture `xml:"diffuse"`
+	Specular  daeColorOrTexture `xml:"specular"`
+	Shininess string            `xml:"shininess>float"`
+}
+
+type daeColorOrTexture struct {
+	Color   string `xml:"color"`
+	Texture *struct {
+		Texture string `xml:"texture,attr"`
+	} `xml:"texture"`
+}
+
+type daeGeometry struct {
+	ID       string          `xml:"id,attr"`
+	Name     string          `xml:"name,attr"`
+	Sources  []daeSource     `xml:"mesh>source"`
+	Vertices daeVertices     `xml:"mesh>vertices"`
+	Tris     []daePrimitives `xml:"mesh>triangles"`
+	Polys    []daePrimitives `xml:"mesh>polylist"`
+}
+
+type daeSource struct {
+	ID         string `xml:"id,attr"`
+	FloatArray string `xml:"float_array"`
+	Accessor   struct {
+		Stride int `xml:"stride,attr"`
+	} `xml:"technique_common>accessor"`
+}
+
+type daeVertices struct {
+	ID     string     `xml:"id,attr"`
+	Inputs []daeInput `xml:"input"`
+}
+
+type daeInput struct {
+	Semantic string `xml:"semantic,attr"`
+	Source   string `xml:"source,attr"`
+	Offset   int    `xml:"offset,attr"`
+	Set      int    `xml:"set,attr"`
+}
+
+type daePrimitives struct {
+	Material string     `xml:"material,attr"`
+	Count    int        `xml:"count,attr"`
+	Inputs   []daeInput `xml:"input"`
+	VCount   string     `xml:"vcount"`
+	P        string     `xml:"p"`
+}
+
+type daeController struct {
+	ID         string `xml:"id,attr"`
+	SkinSource struct {
+		Source string `xml:"source,attr"`
+	} `xml:"skin"`
+}
+
+type daeVisualScene struct {
+	Nodes []daeNode `xml:"node"`
+}
+
+type daeInstance struct {
+	URL       string `xml:"url,attr"`
+	Materials []struct {
+		Symbol string `xml:"symbol,attr"`
+		Target string `xml:"target,attr"`
+	} `xml:"bind_material>technique_common>instance_material"`
+}
+
+type daeNode struct {
+	Name                string         `xml:"name,attr"`
+	ID                  string         `xml:"id,attr"`
+	InstanceGeometries  []daeInstance  `xml:"instance_geometry"`
+	InstanceControllers []daeInstance  `xml:"instance_controller"`
+	Nodes               []daeNode      `xml:"node"`
+	Transforms          []daeTransform `xml:",any"`
+}
+
+// daeTransform is any other child of a node, which includes the transform
+// elements in the order they need to be applied.
+type daeTransform struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// colladaImporter holds the state of a Collada file being imported.
+type colladaImporter struct {
+	doc       daeDocument
+	scene     *Scene
+	materials map[string]int
+}
+
+// ImportCollada imports the triangle and polylist geometry of a Collada
+// file with the materials bound to it in the visual scene. Skinned
+// geometry is imported in its bind pose; Collada skins and animations are
+// not converted.
+func ImportCollada(filename string) (*Scene, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	imp := new(colladaImporter)
+	if err = xml.NewDecoder(f).Decode(&imp.doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the Collada XML: %v", err)
+	}
+	imp.scene = new(Scene)
+	imp.scene.Dir = filepath.Dir(filename)
+	imp.convertMaterials()
+
+	// convert the up axis to +Y
+	root := mgl.Ident4()
+	switch imp.doc.UpAxis {
+	case "Z_UP":
+		root = mgl.HomogRotate3DX(-math.Pi / 2.0)
+	case "X_UP":
+		root = mgl.HomogRotate3DZ(math.Pi / 2.0)
+	}
+
+	if len(imp.doc.VisualScenes) == 0 {
+		// without a scene just take all of the geometry as it is
+		for gi := range imp.doc.Geometries {
+			if err = imp.convertGeometry(&imp.doc.Geometries[gi], nil, root); err != nil {
+				return nil, err
+			}
+		}
+		return imp.scene, nil
+	}
+
+	for _, vs := range imp.doc.VisualScenes {
+		for ni := range vs.Nodes {
+			if err = imp.convertNode(&vs.Nodes[ni], root); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return imp.scene, nil
+}
+
+// convertMaterials adds every material of the document to the scene.
+func (imp *colladaImporter) convertMaterials() {
+	imp.materials = make(map[string]int)
+	for _, dm := range imp.doc.Materials {
+		name := dm.Name
+		if name == "" {
+			name = dm.ID
+		}
+		mat := newMaterial(name)
+
+		if effect := imp.findEffect(dm.InstanceEffect.URL); effect != nil {
+			shading := effect.Phong
+			if shading == nil {
+				shading = effect.Blinn
+			}
+			if shading == nil {
+				shading = effect.Lambert
+			}
+			if shading != nil {
+				if c, err := parseFloats(strings.Fields(shading.Diffuse.Color), 4); err == nil {
+					mat.Diffuse = mgl.Vec4{c[0], c[1], c[2], c[3]}
+				}
+				if c, err := parseFloats(strings.Fields(shading.Specular.Color), 4); err == nil {
+					mat.Specular = mgl.Vec4{c[0], c[1], c[2], c[3]}
+				}
+				if s, err := parseFloats(strings.Fields(shading.Shininess), 1); err == nil {
+					mat.Shininess = s[0]
+				}
+				if shading.Diffuse.Texture != nil {
+					mat.DiffuseTexture = imp.texturePath(effect, shading.Diffuse.Texture.Texture)
+				}
+			}
+		}
+
+		imp.materials[dm.ID] = len(imp.scene.Materials)
+		imp.scene.Materials = append(imp.scene.Materials, mat)
+	}
+}
+
+// findEffect returns the effect with the id of the URL fragment.
+func (imp *colladaImporter) findEffect(ref string) *daeEffect {
+	id := strings.TrimPrefix(ref, "#")
+	for i := range imp.doc.Effects {
+		if imp.doc.Effects[i].ID == id {
+			return &imp.doc.Effects[i]
+		}
+	}
+	return nil
+}
+
+// texturePath follows a texture reference through the effect's sampler and
+// surface parameters to the file name of the image.
+func (imp *colladaImporter) texturePath(effect *daeEffect, texture string) string {
+	imageID := texture
+	for _, sampler := range effect.NewParams {
+		if sampler.Sid != texture {
+			continue
+		}
+		if sampler.SamplerImage.URL != "" {
+			imageID = strings.TrimPrefix(sampler.SamplerImage.URL, "#")
+			break
+		}
+		for _, surface := range effect.NewParams {
+			if surface.Sid == sampler.SamplerSource {
+				imageID = surface.SurfaceInitFrom
+			}
+		}
+	}
+
+	for _, img := range imp.doc.Images {
+		if img.ID != imageID {
+			continue
+		}
+		path := strings.TrimSpace(img.InitFrom.Path)
+		if path == "" {
+			path = strings.TrimSpace(img.InitFrom.Ref)
+		}
+		path = strings.TrimPrefix(path, "file://")
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			path = unescaped
+		}
+		return filepath.FromSlash(path)
+	}
+	return ""
+}
+
+// convertNode adds the geometry instanced by the node and its children.
+func (imp *colladaImporter) convertNode(node *daeNode, parent mgl.Mat4) error {
+	world := parent.Mul4(node.localTransform())
+
+	for _, inst := range node.InstanceGeometries {
+		if err := imp.convertInstance(inst, strings.TrimPrefix(inst.URL, "#"), world); err != nil {
+			return err
+		}
+	}
+	for _, inst := range node.InstanceControllers {
+		// skinned geometry is imported in its bind pose
+		controllerID := strings.TrimPrefix(inst.URL, "#")
+		for _, c := range imp.doc.Controllers {
+			if c.ID == controllerID {
+				if err := imp.convertInstance(inst, strings.TrimPrefix(c.SkinSource.Source, "#"), world); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for ci := range node.Nodes {
+		if err := imp.convertNode(&node.Nodes[ci], world); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertInstance adds the geometry with the id using the instance's material bindings.
+func (imp *colladaImporter) convertInstance(inst daeInstance, geometryID string, transform mgl.Mat4) error {
+	bindings := make(map[string]string, len(inst.Materials))
+	for _, m := range inst.Materials {
+		bindings[m.Symbol] = strings.TrimPrefix(m.Target, "#")
+	}
+	for gi := range imp.doc.Geometries {
+		if imp.doc.Geometries[gi].ID == geometryID {
+			return imp.convertGeometry(&imp.doc.Geometries[gi], bindings, transform)
+		}
+	}
+	return fmt.Errorf("reference to the missing geometry %s", geometryID)
+}
+
+// convertGeometry adds a mesh for every triangles and polylist element of
+// the geometry. Materials are looked up through the bindings, if supplied,
+// or used directly by id otherwise.
+func (imp *colladaImporter) convertGeometry(geom *daeGeometry, bindings map[string]string, transform mgl.Mat4) error {
+	sources := make(map[string][]float32, len(geom.Sources))
+	strides := make(map[string]int, len(geom.Sources))
+	for _, s := range geom.Sources {
+		values, err := parseFloats(strings.Fields(s.FloatArray), len(strings.Fields(s.FloatArray)))
+		if err != nil {
+			return fmt.Errorf("geometry %s source %s: %v", geom.ID, s.ID, err)
+		}
+		sources[s.ID] = values
+		strides[s.ID] = s.Accessor.Stride
+		if strides[s.ID] <= 0 {
+			strides[s.ID] = 1
+		}
+	}
+
+	name := geom.Name
+	if name == "" {
+		name = geom.ID
+	}
+	prims := append(append([]daePrimitives(nil), geom.Tris...), geom.Polys...)
+	for pi, prim := range prims {
+		primName := name
+		if len(prims) > 1 {
+			primName = fmt.Sprintf("%s_%d", name, pi)
+		}
+		mesh, err := imp.convertPrimitives(primName, geom, &prim, sources, strides)
+		if err != nil {
+			return fmt.Errorf("geometry %s: %v", geom.ID, err)
+		}
+		if mesh == nil {
+			continue
+		}
+		transformMesh(mesh.Mesh, transform)
+
+		materialID := prim.Material
+		if bound, okay := bindings[prim.Material]; okay {
+			materialID = bound
+		}
+		if idx, okay := imp.materials[materialID]; okay {
+			mesh.Material = idx
+		}
+		imp.scene.Meshes = append(imp.scene.Meshes, *mesh)
+	}
+	return nil
+}
+
+// convertPrimitives builds a mesh out of a triangles or polylist element.
+func (imp *colladaImporter) convertPrimitives(name string, geom *daeGeometry, prim *daePrimitives,
+	sources map[string][]float32, strides map[string]int) (*SceneMesh, error) {
+	// find the streams and their offsets within each corner of p
+	posOffset, uvOffset, normalOffset := -1, -1, -1
+	var posSource, uvSource, normalSource string
+	inputCount := 0
+	uvSet := -1
+	for _, in := range prim.Inputs {
+		if in.Offset+1 > inputCount {
+			inputCount = in.Offset + 1
+		}
+		source := strings.TrimPrefix(in.Source, "#")
+		switch in.Semantic {
+		case "VERTEX":
+			posOffset = in.Offset
+			for _, vin := range geom.Vertices.Inputs {
+				vsource := strings.TrimPrefix(vin.Source, "#")
+				switch vin.Semantic {
+				case "POSITION":
+					posSource = vsource
+				case "NORMAL":
+					normalOffset, normalSource = in.Offset, vsource
+				case "TEXCOORD":
+					uvOffset, uvSource = in.Offset, vsource
+				}
+			}
+		case "NORMAL":
+			normalOffset, normalSource = in.Offset, source
+		case "TEXCOORD":
+			if uvSet < 0 || in.Set < uvSet {
+				uvSet = in.Set
+				uvOffset, uvSource = in.Offset, source
+			}
+		}
+	}
+	if posOffset < 0 || sources[posSource] == nil {
+		return nil, fmt.Errorf("primitives without positions")
+	}
+
+	positions := daeVec3s(sources[posSource], strides[posSource])
+	var normals []mgl.Vec3
+	if normalOffset >= 0 {
+		normals = daeVec3s(sources[normalSource], strides[normalSource])
+	}
+	var uvs []mgl.Vec2
+	if uvOffset >= 0 {
+		values, stride := sources[uvSource], strides[uvSource]
+		if stride < 2 {
+			stride = 2
+		}
+		uvs = make([]mgl.Vec2, len(values)/stride)
+		for i := range uvs {
+			uvs[i] = mgl.Vec2{values[i*stride], values[i*stride+1]}
+		}
+	}
+
+	p, err := parseInts(strings.Fields(prim.P))
+	if err != nil {
+		return nil, err
+	}
+	var vcounts []int
+	if prim.VCount != "" {
+		if vcounts, err = parseInts(strings.Fields(prim.VCount)); err != nil {
+			return nil, err
+		}
+	} else {
+		vcounts = make([]int, len(p)/(inputCount*3))
+		for i := range vcounts {
+			vcounts[i] = 3
+		}
+	}
+
+	mb := newMeshBuilder(name, positions, uvs, normals)
+	corner := 0
+	for _, vc := range vcounts {
+		keys := make([]vertexKey, vc)
+		for k := range keys {
+			base := (corner + k) * inputCount
+			if base+inputCount > len(p) {
+				return nil, fmt.Errorf("primitive index list is too short")
+			}
+			keys[k] = vertexKey{position: p[base+posOffset], uv: -1, normal: -1}
+			if uvOffset >= 0 {
+				keys[k].uv = p[base+uvOffset]
+			}
+			if normalOffset >= 0 {
+				keys[k].normal = p[base+normalOffset]
+			}
+			if keys[k].position >= len(positions) || keys[k].uv >= len(uvs) || keys[k].normal >= len(normals) {
+				return nil, fmt.Errorf("primitive index out of range")
+			}
+		}
+		mb.polygon(keys)
+		corner += vc
+	}
+	if mb.empty() {
+		return nil, nil
+	}
+	return &SceneMesh{Mesh: mb.finish(uvOffset >= 0, normalOffset >= 0), Material: -1}, nil
+}
+
+// localTransform combines the transform elements of the node in order.
+func (node *daeNode) localTransform() mgl.Mat4 {
+	m := mgl.Ident4()
+	for _, t := range node.Transforms {
+		values, err := parseFloats(strings.Fields(t.Value), len(strings.Fields(t.Value)))
+		if err != nil {
+			continue
+		}
+		switch t.XMLName.Local {
+		case "matrix":
+			if len(values) == 16 {
+				// Collada matrices are row major
+				var mat mgl.Mat4
+				copy(mat[:], values)
+				m = m.Mul4(mat.Transpose())
+			}
+		case "translate":
+			if len(values) == 3 {
+				m = m.Mul4(mgl.Translate3D(values[0], values[1], values[2]))
+			}
+		case "rotate":
+			if len(values) == 4 {
+				axis := mgl.Vec3{values[0], values[1], values[2]}
+				if axis.Len() > 0.0 {
+					m = m.Mul4(mgl.HomogRotate3D(mgl.DegToRad(values[3]), axis.Normalize()))
+				}
+			}
+		case "scale":
+			if len(values) == 3 {
+				m = m.Mul4(mgl.Scale3D(values[0], values[1], values[2]))
+			}
+		}
+	}
+	return m
+}
+
+// daeVec3s groups the first three values of every stride of the source into vectors.
+func daeVec3s(values []float32, stride int) []mgl.Vec3 {
+	if stride < 3 {
+		stride = 3
+	}
+	result := make([]mgl.Vec3, len(values)/stride)
+	for i := range result {
+		result[i] = mgl.Vec3{values[i*stride], values[i*stride+1], values[i*stride+2]}
+	}
+	return result
+}
+
+// parseInts parses all of the fields as integers.
+func parseInts(fields []string) ([]int, error) {
+	values := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+Package convert imports meshes from standard interchange formats into gombz
+meshes and exports them as fizzle components.
+
+Wavefront OBJ (.obj with .mtl materials), glTF 2.0 (.gltf and .glb) and
+Collada (.dae) files are supported out of the box. glTF skins and animations
+are converted to gombz bones and animations; the other formats import static
+geometry and materials. More formats can be added with RegisterImporter.
+*/
+package convert
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/gombz"
+)
+
+// Material is the appearance of a mesh as described by the source file.
+type Material struct {
+	// Name is the name of the material in the source file.
+	Name string
+
+	// Diffuse is the diffuse color of the material.
+	Diffuse mgl.Vec4
+
+	// Specular is the specular color of the material.
+	Specular mgl.Vec4
+
+	// Shininess is the specular exponent of the material.
+	Shininess float32
+
+	// DiffuseTexture is the path of the diffuse texture, if any, relative
+	// to the directory of the source file.
+	DiffuseTexture string
+
+	// NormalTexture is the path of the normal map, if any, relative to the
+	// directory of the source file.
+	NormalTexture string
+
+	// Metallic and Roughness are the factors of the metallic-roughness
+	// material model; see fizzle.PBRMaterial.
+	Metallic  float32
+	Roughness float32
+
+	// Emissive is the color of the light the material gives off.
+	Emissive mgl.Vec3
+
+	// MetalRoughTexture, OcclusionTexture and EmissiveTexture are the
+	// paths of the material's PBR maps, if any, relative to the directory
+	// of the source file.
+	MetalRoughTexture string
+	OcclusionTexture  string
+	EmissiveTexture   string
+}
+
+// SceneMesh is one mesh of an imported Scene.
+type SceneMesh struct {
+	// Mesh is the converted geometry, bones and animations.
+	Mesh *gombz.Mesh
+
+	// Material is the index of the mesh's material in the Scene's
+	// Materials or -1 if it has none.
+	Material int
+}
+
+// Scene is the result of importing a file.
+type Scene struct {
+	// Name is the name of the scene; defaults to the base name of the file.
+	Name string
+
+	// Dir is the directory of the source file that the texture paths of
+	// the materials are relative to.
+	Dir string
+
+	// Meshes are the meshes of the scene with any node transforms applied.
+	Meshes []SceneMesh
+
+	// Materials are the materials referenced by the meshes.
+	Materials []Material
+
+	// EmbeddedTextures holds the image data of textures stored inside the
+	// source file, keyed by the file name the materials use for them.
+	EmbeddedTextures map[string][]byte
+}
+
+// Importer reads the file at filename and converts it to a Scene.
+type Importer func(filename string) (*Scene, error)
+
+// importers maps the lower case file extensions to their importers.
+var importers = map[string]Importer{
+	".obj":  ImportOBJ,
+	".gltf": ImportGLTF,
+	".glb":  ImportGLTF,
+	".dae":  ImportCollada,
+}
+
+// RegisterImporter sets the importer used by ImportFile for files with the
+// extension, such as ".fbx". It replaces any existing importer for it.
+func RegisterImporter(ext string, importer Importer) {
+	importers[strings.ToLower(ext)] = importer
+}
+
+// ImportFile imports the file with the importer registered for its extension.
+// Normals are generated for meshes that have none and tangents for meshes
+// that have normals and texture coordinates.
+func ImportFile(filename string) (*Scene, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	importer, okay := importers[ext]
+	if !okay {
+		return nil, fmt.Errorf("no importer is registered for %s files", ext)
+	}
+
+	scene, err := importer(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to import %s.\n%v", filename, err)
+	}
+	if scene.Name == "" {
+		scene.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if scene.Dir == "" {
+		scene.Dir = filepath.Dir(filename)
+	}
+
+	for _, sm := range scene.Meshes {
+		if len(sm.Mesh.Normals) == 0 {
+			GenerateNormals(sm.Mesh)
+		}
+		if len(sm.Mesh.Tangents) == 0 && len(sm.Mesh.UVChannels[0]) > 0 {
+			GenerateTangents(sm.Mesh)
+		}
+	}
+	return scene, nil
+}
+
+// newMaterial returns a Material with the default white appearance.
+func newMaterial(name string) Material {
+	return Material{
+		Name:      name,
+		Diffuse:   mgl.Vec4{1.0, 1.0, 1.0, 1.0},
+		Specular:  mgl.Vec4{1.0, 1.0, 1.0, 1.0},
+		Shininess: 1.0,
+		Roughness: 1.0,
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tbogdala/fizzle/component"
+)
+
+// ExportComponent writes every mesh of the scene as a gombz file into dir
+// along with a component file named after the scene that references them,
+// returning the path of the component file. The textures of the materials
+// are copied into dir so that the component is self contained.
+//
+// Components have a single material, so the component uses shaderName and
+// the diffuse color of the first mesh's material; each mesh still gets its
+// own textures.
+func ExportComponent(scene *Scene, dir string, shaderName string) (string, error) {
+	if len(scene.Meshes) == 0 {
+		return "", fmt.Errorf("the scene %s has no meshes to export", scene.Name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	comp := new(component.Component)
+	comp.Name = scene.Name
+	comp.Material = new(component.ComponentMaterial)
+	comp.Material.ShaderName = shaderName
+	comp.Material.Diffuse = newMaterial("").Diffuse
+	if first := scene.Meshes[0].Material; first >= 0 {
+		comp.Material.Diffuse = scene.Materials[first].Diffuse
+	}
+
+	copied := make(map[string]string)
+	for i, sm := range scene.Meshes {
+		meshBytes, err := sm.Mesh.Encode()
+		if err != nil {
+			return "", fmt.Errorf("Failed to encode mesh %s.\n%v", sm.Mesh.Name, err)
+		}
+		binFile := fmt.Sprintf("%s_%d.gombz", scene.Name, i)
+		if err = ioutil.WriteFile(filepath.Join(dir, binFile), meshBytes, 0644); err != nil {
+			return "", err
+		}
+
+		compMesh := new(component.ComponentMesh)
+		compMesh.BinFile = binFile
+		if sm.Material >= 0 {
+			mat := &scene.Materials[sm.Material]
+			for _, tex := range []string{mat.DiffuseTexture, mat.NormalTexture} {
+				if tex == "" {
+					continue
+				}
+				name, err := exportTexture(scene, tex, dir, copied)
+				if err != nil {
+					return "", err
+				}
+				compMesh.Textures = append(compMesh.Textures, name)
+			}
+		}
+		comp.Meshes = append(comp.Meshes, compMesh)
+	}
+
+	jsonBytes, err := json.MarshalIndent(comp, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	componentFile := filepath.Join(dir, scene.Name+".json")
+	if err = ioutil.WriteFile(componentFile, jsonBytes, 0644); err != nil {
+		return "", err
+	}
+	return componentFile, nil
+}
+
+// exportTexture writes the texture, either embedded in the scene or a file
+// relative to the scene's directory, into dir once and returns its file name.
+func exportTexture(scene *Scene, tex string, dir string, copied map[string]string) (string, error) {
+	if name, okay := copied[tex]; okay {
+		return name, nil
+	}
+
+	data, okay := scene.EmbeddedTextures[tex]
+	if !okay {
+		src := tex
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(scene.Dir, src)
+		}
+		var err error
+		if data, err = ioutil.ReadFile(src); err != nil {
+			return "", fmt.Errorf("Failed to read the texture %s.\n%v", tex, err)
+		}
+	}
+
+	name := filepath.Base(tex)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return "", err
+	}
+	copied[tex] = name
+	return name, nil
+}
@@ -0,0 +1,944 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package convert
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/gombz"
+	"github.com/tbogdala/groggy"
+)
+
+const (
+	// glTF accessor component types
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+
+	// gltfTriangles is the only primitive mode that gets imported
+	gltfTriangles = 4
+
+	// GLB container magic numbers
+	glbMagic     = 0x46546C67
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+// gltfComponentCounts is the number of components for each accessor type.
+var gltfComponentCounts = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT4":   16,
+}
+
+type gltfDocument struct {
+	Scene  *int
+	Scenes []struct {
+		Nodes []int
+	}
+	Nodes     []gltfNode
+	Meshes    []gltfMesh
+	Materials []gltfMaterial
+	Textures  []struct {
+		Source *int
+	}
+	Images      []gltfImage
+	Accessors   []gltfAccessor
+	BufferViews []gltfBufferView
+	Buffers     []struct {
+		ByteLength int
+		URI        string
+	}
+	Skins      []gltfSkin
+	Animations []gltfAnimation
+}
+
+type gltfNode struct {
+	Name        string
+	Children    []int
+	Mesh        *int
+	Skin        *int
+	Matrix      []float32
+	Translation []float32
+	Rotation    []float32
+	Scale       []float32
+}
+
+type gltfMesh struct {
+	Name       string
+	Primitives []struct {
+		Attributes map[string]int
+		Indices    *int
+		Material   *int
+		Mode       *int
+	}
+}
+
+type gltfTextureRef struct {
+	Index int
+}
+
+type gltfMaterial struct {
+	Name                 string
+	PbrMetallicRoughness *struct {
+		BaseColorFactor          []float32
+		BaseColorTexture         *gltfTextureRef
+		MetallicFactor           *float32
+		RoughnessFactor          *float32
+		MetallicRoughnessTexture *gltfTextureRef
+	}
+	NormalTexture    *gltfTextureRef
+	OcclusionTexture *gltfTextureRef
+	EmissiveTexture  *gltfTextureRef
+	EmissiveFactor   []float32
+}
+
+type gltfImage struct {
+	Name       string
+	URI        string
+	MimeType   string
+	BufferView *int
+}
+
+type gltfAccessor struct {
+	BufferView    *int
+	ByteOffset    int
+	ComponentType int
+	Normalized    bool
+	Count         int
+	Type          string
+	Sparse        *json.RawMessage
+}
+
+type gltfBufferView struct {
+	Buffer     int
+	ByteOffset int
+	ByteLength int
+	ByteStride int
+}
+
+type gltfSkin struct {
+	InverseBindMatrices *int
+	Joints              []int
+}
+
+type gltfAnimation struct {
+	Name     string
+	Channels []struct {
+		Sampler int
+		Target  struct {
+			Node *int
+			Path string
+		}
+	}
+	Samplers []struct {
+		Input         int
+		Output        int
+		Interpolation string
+	}
+}
+
+// gltfImporter holds the state of a glTF file being imported.
+type gltfImporter struct {
+	doc     gltfDocument
+	dir     string
+	buffers [][]byte
+	scene   *Scene
+
+	// parents is the parent node index of every node or -1
+	parents []int
+
+	// world is the world transform of every node
+	world []mgl.Mat4
+
+	// skins caches the converted bones and animations of the skins
+	skins map[int]*gltfSkinData
+}
+
+// gltfSkinData is a skin converted to gombz bones and animations.
+type gltfSkinData struct {
+	bones      []gombz.Bone
+	animations []gombz.Animation
+}
+
+// ImportGLTF imports a glTF 2.0 file, either the JSON .gltf form with
+// external or embedded buffers or the binary .glb form. Every triangle
+// primitive becomes a mesh; static meshes have their node transforms
+// applied while skinned meshes are left in their bind pose and get the bones
+// of their skin along with every animation that moves its joints.
+func ImportGLTF(filename string) (*Scene, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	imp := new(gltfImporter)
+	imp.dir = filepath.Dir(filename)
+	imp.skins = make(map[int]*gltfSkinData)
+	imp.scene = new(Scene)
+	imp.scene.Dir = imp.dir
+
+	var binChunk []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == glbMagic {
+		data, binChunk, err = splitGLB(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = json.Unmarshal(data, &imp.doc); err != nil {
+		return nil, fmt.Errorf("failed to decode the glTF JSON: %v", err)
+	}
+	if err = imp.loadBuffers(binChunk); err != nil {
+		return nil, err
+	}
+
+	imp.calculateTransforms()
+	imp.convertMaterials()
+	for ni := range imp.doc.Nodes {
+		if imp.doc.Nodes[ni].Mesh == nil {
+			continue
+		}
+		if err = imp.convertNodeMesh(ni); err != nil {
+			return nil, err
+		}
+	}
+	return imp.scene, nil
+}
+
+// splitGLB returns the JSON and binary chunks of a GLB container.
+func splitGLB(data []byte) ([]byte, []byte, error) {
+	var jsonChunk, binChunk []byte
+	offset := 12
+	for offset+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		offset += 8
+		if offset+length > len(data) {
+			return nil, nil, fmt.Errorf("truncated GLB chunk")
+		}
+		switch chunkType {
+		case glbChunkJSON:
+			jsonChunk = data[offset : offset+length]
+		case glbChunkBIN:
+			binChunk = data[offset : offset+length]
+		}
+		offset += length
+	}
+	if jsonChunk == nil {
+		return nil, nil, fmt.Errorf("the GLB file has no JSON chunk")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+// loadBuffers reads all of the buffers from data URIs, external files or
+// the GLB binary chunk.
+func (imp *gltfImporter) loadBuffers(binChunk []byte) error {
+	imp.buffers = make([][]byte, len(imp.doc.Buffers))
+	for i, b := range imp.doc.Buffers {
+		var data []byte
+		var err error
+		switch {
+		case b.URI == "":
+			if binChunk == nil {
+				return fmt.Errorf("buffer %d has no data", i)
+			}
+			data = binChunk
+		case strings.HasPrefix(b.URI, "data:"):
+			data, err = decodeDataURI(b.URI)
+		default:
+			data, err = ioutil.ReadFile(imp.resolvePath(b.URI))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load buffer %d: %v", i, err)
+		}
+		if len(data) < b.ByteLength {
+			return fmt.Errorf("buffer %d is shorter than its byteLength", i)
+		}
+		imp.buffers[i] = data
+	}
+	return nil
+}
+
+// resolvePath turns a relative URI into a file path.
+func (imp *gltfImporter) resolvePath(uri string) string {
+	if unescaped, err := url.PathUnescape(uri); err == nil {
+		uri = unescaped
+	}
+	return filepath.Join(imp.dir, filepath.FromSlash(uri))
+}
+
+// decodeDataURI returns the data of a base64 data URI.
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.IndexByte(uri, ',')
+	if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+		return nil, fmt.Errorf("only base64 data URIs are supported")
+	}
+	return base64.StdEncoding.DecodeString(uri[comma+1:])
+}
+
+// calculateTransforms finds the parent and world transform of every node.
+func (imp *gltfImporter) calculateTransforms() {
+	nodes := imp.doc.Nodes
+	imp.parents = make([]int, len(nodes))
+	imp.world = make([]mgl.Mat4, len(nodes))
+	for i := range imp.parents {
+		imp.parents[i] = -1
+	}
+	for i, n := range nodes {
+		for _, c := range n.Children {
+			if c >= 0 && c < len(nodes) {
+				imp.parents[c] = i
+			}
+		}
+	}
+
+	var visit func(ni int, parent mgl.Mat4)
+	visit = func(ni int, parent mgl.Mat4) {
+		imp.world[ni] = parent.Mul4(nodes[ni].localTransform())
+		for _, c := range nodes[ni].Children {
+			visit(c, imp.world[ni])
+		}
+	}
+	for i := range nodes {
+		if imp.parents[i] < 0 {
+			visit(i, mgl.Ident4())
+		}
+	}
+}
+
+// localTransform returns the transform of the node relative to its parent.
+func (n *gltfNode) localTransform() mgl.Mat4 {
+	if len(n.Matrix) == 16 {
+		var m mgl.Mat4
+		copy(m[:], n.Matrix)
+		return m
+	}
+	t, r, s := n.trs()
+	return mgl.Translate3D(t[0], t[1], t[2]).Mul4(r.Mat4()).Mul4(mgl.Scale3D(s[0], s[1], s[2]))
+}
+
+// trs returns the translation, rotation and scale of the node, decomposing
+// the matrix if the node uses one.
+func (n *gltfNode) trs() (mgl.Vec3, mgl.Quat, mgl.Vec3) {
+	t := mgl.Vec3{}
+	r := mgl.QuatIdent()
+	s := mgl.Vec3{1.0, 1.0, 1.0}
+	if len(n.Matrix) == 16 {
+		var m mgl.Mat4
+		copy(m[:], n.Matrix)
+		t = m.Col(3).Vec3()
+		s[0], s[1], s[2] = mgl.Extract3DScale(m)
+		rot := mgl.Mat4FromCols(m.Col(0).Mul(1.0/s[0]), m.Col(1).Mul(1.0/s[1]), m.Col(2).Mul(1.0/s[2]), mgl.Vec4{0.0, 0.0, 0.0, 1.0})
+		return t, mgl.Mat4ToQuat(rot), s
+	}
+	if len(n.Translation) == 3 {
+		t = mgl.Vec3{n.Translation[0], n.Translation[1], n.Translation[2]}
+	}
+	if len(n.Rotation) == 4 {
+		r = mgl.Quat{W: n.Rotation[3], V: mgl.Vec3{n.Rotation[0], n.Rotation[1], n.Rotation[2]}}
+	}
+	if len(n.Scale) == 3 {
+		s = mgl.Vec3{n.Scale[0], n.Scale[1], n.Scale[2]}
+	}
+	return t, r, s
+}
+
+// convertMaterials adds every material of the document to the scene in order.
+func (imp *gltfImporter) convertMaterials() {
+	for i, gm := range imp.doc.Materials {
+		name := gm.Name
+		if name == "" {
+			name = fmt.Sprintf("material%d", i)
+		}
+		mat := newMaterial(name)
+
+		// glTF defaults to a fully metallic and rough material
+		mat.Metallic = 1.0
+		if pbr := gm.PbrMetallicRoughness; pbr != nil {
+			if len(pbr.BaseColorFactor) == 4 {
+				copy(mat.Diffuse[:], pbr.BaseColorFactor)
+			}
+			if pbr.BaseColorTexture != nil {
+				mat.DiffuseTexture = imp.texturePath(pbr.BaseColorTexture.Index)
+			}
+			if pbr.MetallicFactor != nil {
+				mat.Metallic = *pbr.MetallicFactor
+			}
+			if pbr.RoughnessFactor != nil {
+				mat.Roughness = *pbr.RoughnessFactor
+			}
+			if pbr.MetallicRoughnessTexture != nil {
+				mat.MetalRoughTexture = imp.texturePath(pbr.MetallicRoughnessTexture.Index)
+			}
+		}
+		if gm.NormalTexture != nil {
+			mat.NormalTexture = imp.texturePath(gm.NormalTexture.Index)
+		}
+		if gm.OcclusionTexture != nil {
+			mat.OcclusionTexture = imp.texturePath(gm.OcclusionTexture.Index)
+		}
+		if gm.EmissiveTexture != nil {
+			mat.EmissiveTexture = imp.texturePath(gm.EmissiveTexture.Index)
+		}
+		if len(gm.EmissiveFactor) == 3 {
+			copy(mat.Emissive[:], gm.EmissiveFactor)
+		}
+
+		// approximate the specular highlight from the roughness for the
+		// non-PBR shaders
+		roughness := mat.Roughness
+		mat.Specular = mgl.Vec4{1.0 - roughness, 1.0 - roughness, 1.0 - roughness, 1.0}
+		mat.Shininess = 1.0 + (1.0-roughness)*127.0
+		imp.scene.Materials = append(imp.scene.Materials, mat)
+	}
+}
+
+// texturePath returns the path of the image of the texture relative to the
+// source file. Images embedded in data URIs or buffer views are added to
+// the scene's EmbeddedTextures under a generated file name.
+func (imp *gltfImporter) texturePath(texture int) string {
+	if texture < 0 || texture >= len(imp.doc.Textures) || imp.doc.Textures[texture].Source == nil {
+		return ""
+	}
+	source := *imp.doc.Textures[texture].Source
+	if source < 0 || source >= len(imp.doc.Images) {
+		return ""
+	}
+	img := imp.doc.Images[source]
+	if img.URI != "" && !strings.HasPrefix(img.URI, "data:") {
+		if unescaped, err := url.PathUnescape(img.URI); err == nil {
+			return unescaped
+		}
+		return img.URI
+	}
+
+	var data []byte
+	mimeType := img.MimeType
+	if img.BufferView != nil {
+		bv := *img.BufferView
+		if bv < 0 || bv >= len(imp.doc.BufferViews) || imp.doc.BufferViews[bv].Buffer >= len(imp.buffers) {
+			groggy.Logsf("ERROR", "ImportGLTF image %d references a missing buffer view", source)
+			return ""
+		}
+		view := &imp.doc.BufferViews[bv]
+		buffer := imp.buffers[view.Buffer]
+		if view.ByteOffset+view.ByteLength > len(buffer) {
+			groggy.Logsf("ERROR", "ImportGLTF image %d reads past the end of its buffer", source)
+			return ""
+		}
+		data = buffer[view.ByteOffset : view.ByteOffset+view.ByteLength]
+	} else {
+		var err error
+		if data, err = decodeDataURI(img.URI); err != nil {
+			groggy.Logsf("ERROR", "ImportGLTF failed to decode image %d: %v", source, err)
+			return ""
+		}
+		if semi := strings.IndexByte(img.URI, ';'); semi > 5 {
+			mimeType = img.URI[5:semi]
+		}
+	}
+
+	ext := ".png"
+	if mimeType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	name := fmt.Sprintf("image%d%s", source, ext)
+	if img.Name != "" {
+		name = strings.TrimSuffix(filepath.Base(img.Name), filepath.Ext(img.Name)) + ext
+	}
+	if imp.scene.EmbeddedTextures == nil {
+		imp.scene.EmbeddedTextures = make(map[string][]byte)
+	}
+	imp.scene.EmbeddedTextures[name] = data
+	return name
+}
+
+// convertNodeMesh adds the triangle primitives of the node's mesh to the scene.
+func (imp *gltfImporter) convertNodeMesh(ni int) error {
+	node := &imp.doc.Nodes[ni]
+	if *node.Mesh < 0 || *node.Mesh >= len(imp.doc.Meshes) {
+		return fmt.Errorf("node %d references a missing mesh", ni)
+	}
+	gm := &imp.doc.Meshes[*node.Mesh]
+
+	var skin *gltfSkinData
+	if node.Skin != nil {
+		var err error
+		if skin, err = imp.convertSkin(*node.Skin); err != nil {
+			return err
+		}
+	}
+
+	for pi, prim := range gm.Primitives {
+		if prim.Mode != nil && *prim.Mode != gltfTriangles {
+			groggy.Logsf("DEBUG", "ImportGLTF skipping primitive %d of mesh %s with mode %d", pi, gm.Name, *prim.Mode)
+			continue
+		}
+
+		name := gm.Name
+		if name == "" {
+			name = node.Name
+		}
+		if len(gm.Primitives) > 1 {
+			name = fmt.Sprintf("%s_%d", name, pi)
+		}
+		mesh, err := imp.convertPrimitive(name, prim.Attributes, prim.Indices)
+		if err != nil {
+			return fmt.Errorf("mesh %s: %v", name, err)
+		}
+
+		if skin != nil {
+			mesh.Bones = skin.bones
+			mesh.BoneCount = uint32(len(skin.bones))
+			mesh.Animations = skin.animations
+			mesh.AnimationCount = uint32(len(skin.animations))
+		} else {
+			transformMesh(mesh, imp.world[ni])
+		}
+
+		material := -1
+		if prim.Material != nil && *prim.Material >= 0 && *prim.Material < len(imp.scene.Materials) {
+			material = *prim.Material
+		}
+		imp.scene.Meshes = append(imp.scene.Meshes, SceneMesh{Mesh: mesh, Material: material})
+	}
+	return nil
+}
+
+// convertPrimitive builds a gombz mesh from the vertex attributes and indices.
+func (imp *gltfImporter) convertPrimitive(name string, attributes map[string]int, indices *int) (*gombz.Mesh, error) {
+	posIdx, okay := attributes["POSITION"]
+	if !okay {
+		return nil, fmt.Errorf("primitive has no POSITION attribute")
+	}
+
+	m := new(gombz.Mesh)
+	m.Name = name
+	positions, err := imp.readVec3s(posIdx)
+	if err != nil {
+		return nil, err
+	}
+	m.Vertices = positions
+	m.VertexCount = uint32(len(positions))
+
+	if idx, okay := attributes["NORMAL"]; okay {
+		if m.Normals, err = imp.readVec3s(idx); err != nil {
+			return nil, err
+		}
+	}
+	if idx, okay := attributes["TEXCOORD_0"]; okay {
+		values, count, err := imp.readAccessor(idx)
+		if err != nil {
+			return nil, err
+		}
+		uvs := make([]mgl.Vec2, len(values)/count)
+		for i := range uvs {
+			// glTF puts the origin of the texture at the top left
+			uvs[i] = mgl.Vec2{values[i*count], 1.0 - values[i*count+1]}
+		}
+		m.UVChannels[0] = uvs
+	}
+	if idx, okay := attributes["JOINTS_0"]; okay {
+		if m.VertexWeightIds, err = imp.readVec4s(idx); err != nil {
+			return nil, err
+		}
+	}
+	if idx, okay := attributes["WEIGHTS_0"]; okay {
+		if m.VertexWeights, err = imp.readVec4s(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	if indices != nil {
+		values, err := imp.readIndices(*indices)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+2 < len(values); i += 3 {
+			m.Faces = append(m.Faces, [3]uint32{values[i], values[i+1], values[i+2]})
+		}
+	} else {
+		for i := uint32(0); i+2 < m.VertexCount; i += 3 {
+			m.Faces = append(m.Faces, [3]uint32{i, i + 1, i + 2})
+		}
+	}
+	m.FaceCount = uint32(len(m.Faces))
+	return m, nil
+}
+
+// convertSkin converts the joints of the skin into bones and the animations
+// that target them.
+func (imp *gltfImporter) convertSkin(si int) (*gltfSkinData, error) {
+	if data, okay := imp.skins[si]; okay {
+		return data, nil
+	}
+	if si < 0 || si >= len(imp.doc.Skins) {
+		return nil, fmt.Errorf("reference to the missing skin %d", si)
+	}
+	skin := &imp.doc.Skins[si]
+
+	var inverseBinds []float32
+	if skin.InverseBindMatrices != nil {
+		var err error
+		if inverseBinds, _, err = imp.readAccessor(*skin.InverseBindMatrices); err != nil {
+			return nil, err
+		}
+	}
+
+	// map the joint nodes to bone ids
+	boneIds := make(map[int]int32, len(skin.Joints))
+	for i, ni := range skin.Joints {
+		boneIds[ni] = int32(i)
+	}
+
+	data := new(gltfSkinData)
+	data.bones = make([]gombz.Bone, len(skin.Joints))
+	rootParent := -1
+	for i, ni := range skin.Joints {
+		bone := &data.bones[i]
+		bone.Id = int32(i)
+		bone.Name = imp.doc.Nodes[ni].Name
+		bone.Transform = imp.doc.Nodes[ni].localTransform()
+		bone.Offset = mgl.Ident4()
+		if len(inverseBinds) >= (i+1)*16 {
+			copy(bone.Offset[:], inverseBinds[i*16:(i+1)*16])
+		}
+
+		// the parent is the closest ancestor that's also a joint
+		bone.Parent = -1
+		for p := imp.parents[ni]; p >= 0; p = imp.parents[p] {
+			if id, okay := boneIds[p]; okay {
+				bone.Parent = id
+				break
+			}
+		}
+		if bone.Parent < 0 && rootParent < 0 {
+			rootParent = imp.parents[ni]
+		}
+	}
+
+	// the transforms of the nodes above the root joint apply to the whole skeleton
+	rootTransform := mgl.Ident4()
+	if rootParent >= 0 {
+		rootTransform = imp.world[rootParent]
+	}
+
+	for ai := range imp.doc.Animations {
+		anim, err := imp.convertAnimation(ai, skin.Joints, boneIds, rootTransform)
+		if err != nil {
+			return nil, err
+		}
+		if anim != nil {
+			data.animations = append(data.animations, *anim)
+		}
+	}
+
+	imp.skins[si] = data
+	return data, nil
+}
+
+// convertAnimation converts the channels of the animation that target the
+// joints. It returns nil if none of them are animated. Every bone gets a
+// channel and every channel gets position, rotation and scale keys, using
+// the rest pose of the joint for anything that isn't animated, since the
+// skeleton expects them all to be present.
+func (imp *gltfImporter) convertAnimation(ai int, joints []int, boneIds map[int]int32, rootTransform mgl.Mat4) (*gombz.Animation, error) {
+	ga := &imp.doc.Animations[ai]
+	anim := new(gombz.Animation)
+	anim.Name = ga.Name
+	if anim.Name == "" {
+		anim.Name = fmt.Sprintf("animation%d", ai)
+	}
+	anim.TicksPerSecond = 1.0
+	anim.Transform = rootTransform
+	anim.Channels = make([]gombz.AnimationChannel, len(joints))
+	for i := range anim.Channels {
+		anim.Channels[i].BoneId = int32(i)
+	}
+
+	animated := false
+	for _, gc := range ga.Channels {
+		if gc.Target.Node == nil {
+			continue
+		}
+		boneID, okay := boneIds[*gc.Target.Node]
+		if !okay {
+			continue
+		}
+		if gc.Sampler < 0 || gc.Sampler >= len(ga.Samplers) {
+			return nil, fmt.Errorf("animation %s references a missing sampler", anim.Name)
+		}
+		sampler := ga.Samplers[gc.Sampler]
+
+		times, _, err := imp.readAccessor(sampler.Input)
+		if err != nil {
+			return nil, err
+		}
+		values, count, err := imp.readAccessor(sampler.Output)
+		if err != nil {
+			return nil, err
+		}
+
+		// cubic splines store an in tangent, value and out tangent per key
+		stride, first := count, 0
+		if sampler.Interpolation == "CUBICSPLINE" {
+			stride, first = count*3, count
+		}
+		if len(values) < len(times)*stride {
+			return nil, fmt.Errorf("animation %s has too few output values", anim.Name)
+		}
+
+		channel := &anim.Channels[boneID]
+		step := sampler.Interpolation == "STEP"
+		for k, t := range times {
+			if t > anim.Duration {
+				anim.Duration = t
+			}
+			v := values[k*stride+first : k*stride+first+count]
+
+			// hold the previous value right up to the next key for step interpolation
+			var holdTime float32
+			hold := step && k > 0
+			if hold {
+				holdTime = t - 0.0001
+			}
+
+			switch gc.Target.Path {
+			case "translation":
+				if hold {
+					channel.PositionKeys = append(channel.PositionKeys, gombz.AnimationVec3Key{Time: holdTime, Key: channel.PositionKeys[len(channel.PositionKeys)-1].Key})
+				}
+				channel.PositionKeys = append(channel.PositionKeys, gombz.AnimationVec3Key{Time: t, Key: mgl.Vec3{v[0], v[1], v[2]}})
+			case "scale":
+				if hold {
+					channel.ScaleKeys = append(channel.ScaleKeys, gombz.AnimationVec3Key{Time: holdTime, Key: channel.ScaleKeys[len(channel.ScaleKeys)-1].Key})
+				}
+				channel.ScaleKeys = append(channel.ScaleKeys, gombz.AnimationVec3Key{Time: t, Key: mgl.Vec3{v[0], v[1], v[2]}})
+			case "rotation":
+				if hold {
+					channel.RotationKeys = append(channel.RotationKeys, gombz.AnimationQuatKey{Time: holdTime, Key: channel.RotationKeys[len(channel.RotationKeys)-1].Key})
+				}
+				q := mgl.Quat{W: v[3], V: mgl.Vec3{v[0], v[1], v[2]}}
+				channel.RotationKeys = append(channel.RotationKeys, gombz.AnimationQuatKey{Time: t, Key: q.Normalize()})
+			default:
+				continue
+			}
+			animated = true
+		}
+	}
+	if !animated {
+		return nil, nil
+	}
+
+	// fill in the rest pose for everything not animated
+	for i, ni := range joints {
+		channel := &anim.Channels[i]
+		t, r, s := imp.doc.Nodes[ni].trs()
+		if len(channel.PositionKeys) == 0 {
+			channel.PositionKeys = []gombz.AnimationVec3Key{{Time: 0.0, Key: t}}
+		}
+		if len(channel.RotationKeys) == 0 {
+			channel.RotationKeys = []gombz.AnimationQuatKey{{Time: 0.0, Key: r}}
+		}
+		if len(channel.ScaleKeys) == 0 {
+			channel.ScaleKeys = []gombz.AnimationVec3Key{{Time: 0.0, Key: s}}
+		}
+	}
+	return anim, nil
+}
+
+// readVec3s reads a VEC3 accessor.
+func (imp *gltfImporter) readVec3s(ai int) ([]mgl.Vec3, error) {
+	values, count, err := imp.readAccessor(ai)
+	if err != nil {
+		return nil, err
+	}
+	if count != 3 {
+		return nil, fmt.Errorf("accessor %d is not a VEC3", ai)
+	}
+	result := make([]mgl.Vec3, len(values)/3)
+	for i := range result {
+		copy(result[i][:], values[i*3:i*3+3])
+	}
+	return result, nil
+}
+
+// readVec4s reads a VEC4 accessor.
+func (imp *gltfImporter) readVec4s(ai int) ([]mgl.Vec4, error) {
+	values, count, err := imp.readAccessor(ai)
+	if err != nil {
+		return nil, err
+	}
+	if count != 4 {
+		return nil, fmt.Errorf("accessor %d is not a VEC4", ai)
+	}
+	result := make([]mgl.Vec4, len(values)/4)
+	for i := range result {
+		copy(result[i][:], values[i*4:i*4+4])
+	}
+	return result, nil
+}
+
+// readIndices reads a SCALAR accessor of unsigned integers without going
+// through floats so that large indexes keep their precision.
+func (imp *gltfImporter) readIndices(ai int) ([]uint32, error) {
+	layout, err := imp.accessorLayout(ai)
+	if err != nil {
+		return nil, err
+	}
+	indices := make([]uint32, layout.elements)
+	for e := range indices {
+		b := layout.element(e)
+		switch layout.componentType {
+		case gltfUnsignedByte:
+			indices[e] = uint32(b[0])
+		case gltfUnsignedShort:
+			indices[e] = uint32(binary.LittleEndian.Uint16(b))
+		case gltfUnsignedInt:
+			indices[e] = binary.LittleEndian.Uint32(b)
+		default:
+			return nil, fmt.Errorf("accessor %d is not an unsigned integer index accessor", ai)
+		}
+	}
+	return indices, nil
+}
+
+// readAccessor reads all of the elements of the accessor as floats, scaling
+// normalized integers to [0..1] or [-1..1]. It returns the values and the
+// number of components per element.
+func (imp *gltfImporter) readAccessor(ai int) ([]float32, int, error) {
+	layout, err := imp.accessorLayout(ai)
+	if err != nil {
+		return nil, 0, err
+	}
+	count := layout.components
+	values := make([]float32, layout.elements*count)
+	for e := 0; e < layout.elements; e++ {
+		b := layout.element(e)
+		for c := 0; c < count; c++ {
+			values[e*count+c] = readComponent(b[c*layout.size:], layout.componentType, layout.normalized)
+		}
+	}
+	return values, count, nil
+}
+
+// gltfLayout describes where the elements of an accessor are in its buffer.
+type gltfLayout struct {
+	buffer        []byte
+	base          int
+	stride        int
+	size          int
+	elements      int
+	components    int
+	componentType int
+	normalized    bool
+}
+
+// element returns the bytes of the element at index e. Accessors without a
+// buffer view read as zeros.
+func (l *gltfLayout) element(e int) []byte {
+	if l.buffer == nil {
+		return make([]byte, l.size*l.components)
+	}
+	return l.buffer[l.base+e*l.stride:]
+}
+
+// accessorLayout validates the accessor and returns the layout of its elements.
+func (imp *gltfImporter) accessorLayout(ai int) (*gltfLayout, error) {
+	if ai < 0 || ai >= len(imp.doc.Accessors) {
+		return nil, fmt.Errorf("reference to the missing accessor %d", ai)
+	}
+	acc := &imp.doc.Accessors[ai]
+	if acc.Sparse != nil {
+		return nil, fmt.Errorf("accessor %d is sparse, which is not supported", ai)
+	}
+
+	l := new(gltfLayout)
+	l.elements = acc.Count
+	l.componentType = acc.ComponentType
+	l.normalized = acc.Normalized
+	var okay bool
+	if l.components, okay = gltfComponentCounts[acc.Type]; !okay {
+		return nil, fmt.Errorf("accessor %d has the unsupported type %s", ai, acc.Type)
+	}
+	switch acc.ComponentType {
+	case gltfByte, gltfUnsignedByte:
+		l.size = 1
+	case gltfShort, gltfUnsignedShort:
+		l.size = 2
+	case gltfUnsignedInt, gltfFloat:
+		l.size = 4
+	default:
+		return nil, fmt.Errorf("accessor %d has the unsupported component type %d", ai, acc.ComponentType)
+	}
+	if acc.BufferView == nil {
+		return l, nil
+	}
+
+	if *acc.BufferView < 0 || *acc.BufferView >= len(imp.doc.BufferViews) {
+		return nil, fmt.Errorf("accessor %d references a missing buffer view", ai)
+	}
+	view := &imp.doc.BufferViews[*acc.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(imp.buffers) {
+		return nil, fmt.Errorf("buffer view %d references a missing buffer", *acc.BufferView)
+	}
+	l.buffer = imp.buffers[view.Buffer]
+	l.base = view.ByteOffset + acc.ByteOffset
+	l.stride = view.ByteStride
+	if l.stride == 0 {
+		l.stride = l.size * l.components
+	}
+	if l.elements > 0 && l.base+(l.elements-1)*l.stride+l.size*l.components > len(l.buffer) {
+		return nil, fmt.Errorf("accessor %d reads past the end of its buffer", ai)
+	}
+	return l, nil
+}
+
+// readComponent decodes a single little endian component.
+func readComponent(b []byte, componentType int, normalized bool) float32 {
+	switch componentType {
+	case gltfByte:
+		v := float32(int8(b[0]))
+		if normalized {
+			return float32(math.Max(float64(v/127.0), -1.0))
+		}
+		return v
+	case gltfUnsignedByte:
+		v := float32(b[0])
+		if normalized {
+			return v / 255.0
+		}
+		return v
+	case gltfShort:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return float32(math.Max(float64(v/32767.0), -1.0))
+		}
+		return v
+	case gltfUnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535.0
+		}
+		return v
+	case gltfUnsignedInt:
+		return float32(binary.LittleEndian.Uint32(b))
+	default:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+}
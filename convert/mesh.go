@@ -0,0 +1,172 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package convert
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/gombz"
+)
+
+// vertexKey identifies a unique combination of the position, texture
+// coordinate and normal indexes of a face corner; -1 marks a missing index.
+type vertexKey struct {
+	position int
+	uv       int
+	normal   int
+}
+
+// meshBuilder welds the separately indexed position, texture coordinate and
+// normal streams of formats like OBJ and Collada into a gombz mesh.
+type meshBuilder struct {
+	positions []mgl.Vec3
+	uvs       []mgl.Vec2
+	normals   []mgl.Vec3
+
+	mesh     *gombz.Mesh
+	meshUvs  []mgl.Vec2
+	vertices map[vertexKey]uint32
+}
+
+// newMeshBuilder creates a builder that indexes into the streams supplied.
+func newMeshBuilder(name string, positions []mgl.Vec3, uvs []mgl.Vec2, normals []mgl.Vec3) *meshBuilder {
+	mb := new(meshBuilder)
+	mb.positions = positions
+	mb.uvs = uvs
+	mb.normals = normals
+	mb.mesh = new(gombz.Mesh)
+	mb.mesh.Name = name
+	mb.vertices = make(map[vertexKey]uint32)
+	return mb
+}
+
+// vertex returns the mesh index of the corner, adding a new vertex if the
+// combination hasn't been seen before.
+func (mb *meshBuilder) vertex(key vertexKey) uint32 {
+	if idx, okay := mb.vertices[key]; okay {
+		return idx
+	}
+
+	idx := uint32(len(mb.mesh.Vertices))
+	mb.mesh.Vertices = append(mb.mesh.Vertices, mb.positions[key.position])
+	if key.uv >= 0 {
+		mb.meshUvs = append(mb.meshUvs, mb.uvs[key.uv])
+	} else {
+		mb.meshUvs = append(mb.meshUvs, mgl.Vec2{})
+	}
+	if key.normal >= 0 {
+		mb.mesh.Normals = append(mb.mesh.Normals, mb.normals[key.normal])
+	} else {
+		mb.mesh.Normals = append(mb.mesh.Normals, mgl.Vec3{})
+	}
+	mb.vertices[key] = idx
+	return idx
+}
+
+// polygon adds the polygon as a fan of triangles.
+func (mb *meshBuilder) polygon(corners []vertexKey) {
+	for i := 2; i < len(corners); i++ {
+		a := mb.vertex(corners[0])
+		b := mb.vertex(corners[i-1])
+		c := mb.vertex(corners[i])
+		mb.mesh.Faces = append(mb.mesh.Faces, [3]uint32{a, b, c})
+	}
+}
+
+// empty returns true if no faces were added.
+func (mb *meshBuilder) empty() bool {
+	return len(mb.mesh.Faces) == 0
+}
+
+// finish sets the counts of the mesh and drops the streams that no vertex
+// had data for so that they get generated later.
+func (mb *meshBuilder) finish(hasUvs, hasNormals bool) *gombz.Mesh {
+	m := mb.mesh
+	m.VertexCount = uint32(len(m.Vertices))
+	m.FaceCount = uint32(len(m.Faces))
+	if hasUvs {
+		m.UVChannels[0] = mb.meshUvs
+	}
+	if !hasNormals {
+		m.Normals = nil
+	}
+	return m
+}
+
+// GenerateNormals calculates smooth vertex normals for the mesh by averaging
+// the normals of the faces that share each vertex, weighted by face area.
+func GenerateNormals(m *gombz.Mesh) {
+	normals := make([]mgl.Vec3, len(m.Vertices))
+	for _, f := range m.Faces {
+		v0 := m.Vertices[f[0]]
+		faceNormal := m.Vertices[f[1]].Sub(v0).Cross(m.Vertices[f[2]].Sub(v0))
+		for _, idx := range f {
+			normals[idx] = normals[idx].Add(faceNormal)
+		}
+	}
+	for i, n := range normals {
+		if n.Len() > 0.0 {
+			normals[i] = n.Normalize()
+		} else {
+			normals[i] = mgl.Vec3{0.0, 1.0, 0.0}
+		}
+	}
+	m.Normals = normals
+}
+
+// GenerateTangents calculates vertex tangents for the mesh from its normals
+// and first channel of texture coordinates for use with normal maps.
+func GenerateTangents(m *gombz.Mesh) {
+	uvs := m.UVChannels[0]
+	if len(uvs) != len(m.Vertices) || len(m.Normals) != len(m.Vertices) {
+		return
+	}
+
+	tangents := make([]mgl.Vec3, len(m.Vertices))
+	for _, f := range m.Faces {
+		edge1 := m.Vertices[f[1]].Sub(m.Vertices[f[0]])
+		edge2 := m.Vertices[f[2]].Sub(m.Vertices[f[0]])
+		duv1 := uvs[f[1]].Sub(uvs[f[0]])
+		duv2 := uvs[f[2]].Sub(uvs[f[0]])
+
+		det := duv1[0]*duv2[1] - duv2[0]*duv1[1]
+		if det == 0.0 {
+			continue
+		}
+		r := 1.0 / det
+		tangent := edge1.Mul(duv2[1] * r).Sub(edge2.Mul(duv1[1] * r))
+		for _, idx := range f {
+			tangents[idx] = tangents[idx].Add(tangent)
+		}
+	}
+
+	// orthogonalize against the normal
+	for i, t := range tangents {
+		n := m.Normals[i]
+		t = t.Sub(n.Mul(n.Dot(t)))
+		if t.Len() > 0.0 {
+			tangents[i] = t.Normalize()
+		} else {
+			tangents[i] = mgl.Vec3{1.0, 0.0, 0.0}
+		}
+	}
+	m.Tangents = tangents
+}
+
+// transformMesh applies the transform to the vertices, normals and tangents of the mesh.
+func transformMesh(m *gombz.Mesh, transform mgl.Mat4) {
+	normalMat := transform.Mat3().Inv().Transpose()
+	for i, v := range m.Vertices {
+		m.Vertices[i] = transform.Mul4x1(v.Vec4(1.0)).Vec3()
+	}
+	for i, n := range m.Normals {
+		if n.Len() > 0.0 {
+			m.Normals[i] = normalMat.Mul3x1(n).Normalize()
+		}
+	}
+	for i, t := range m.Tangents {
+		if t.Len() > 0.0 {
+			m.Tangents[i] = transform.Mat3().Mul3x1(t).Normalize()
+		}
+	}
+}
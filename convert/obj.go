@@ -0,0 +1,269 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/groggy"
+)
+
+// objParser holds the state of an OBJ file being parsed.
+type objParser struct {
+	scene     *Scene
+	positions []mgl.Vec3
+	uvs       []mgl.Vec2
+	normals   []mgl.Vec3
+
+	// materials maps the material names of the mtl libraries to Scene indexes
+	materials map[string]int
+
+	// the mesh that faces are currently added to
+	builder    *meshBuilder
+	material   int
+	objectName string
+	hasUvs     bool
+	hasNormals bool
+}
+
+// ImportOBJ imports a Wavefront OBJ file along with the materials of the
+// mtl libraries it references. A new mesh is started for every object,
+// group or material change. Faces using a material that isn't in the
+// libraries, like the "None" Blender writes, and libraries that are missing
+// are logged and get the default material instead of failing the import.
+func ImportOBJ(filename string) (*Scene, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := new(objParser)
+	p.scene = new(Scene)
+	p.scene.Dir = filepath.Dir(filename)
+	p.materials = make(map[string]int)
+	p.material = -1
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNumber++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := p.parseLine(fields); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	p.flush()
+
+	return p.scene, nil
+}
+
+// parseLine handles one statement of the OBJ file.
+func (p *objParser) parseLine(fields []string) error {
+	switch fields[0] {
+	case "v":
+		v, err := parseFloats(fields[1:], 3)
+		if err != nil {
+			return err
+		}
+		p.positions = append(p.positions, mgl.Vec3{v[0], v[1], v[2]})
+	case "vt":
+		v, err := parseFloats(fields[1:], 2)
+		if err != nil {
+			return err
+		}
+		p.uvs = append(p.uvs, mgl.Vec2{v[0], v[1]})
+	case "vn":
+		v, err := parseFloats(fields[1:], 3)
+		if err != nil {
+			return err
+		}
+		p.normals = append(p.normals, mgl.Vec3{v[0], v[1], v[2]})
+	case "f":
+		return p.parseFace(fields[1:])
+	case "o", "g":
+		p.flush()
+		if len(fields) > 1 {
+			p.objectName = strings.Join(fields[1:], " ")
+		}
+	case "usemtl":
+		p.flush()
+		if len(fields) < 2 {
+			p.material = -1
+			return nil
+		}
+		idx, okay := p.materials[fields[1]]
+		if !okay {
+			groggy.Logsf("ERROR", "ImportOBJ using the default material in place of the unknown material %s", fields[1])
+			p.material = -1
+			return nil
+		}
+		p.material = idx
+	case "mtllib":
+		for _, lib := range fields[1:] {
+			libPath := filepath.Join(p.scene.Dir, lib)
+			err := p.loadMaterialLibrary(libPath)
+			if os.IsNotExist(err) {
+				groggy.Logsf("ERROR", "ImportOBJ skipping the missing material library %s", libPath)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseFace adds a polygon using the v, v/vt, v//vn or v/vt/vn corner forms.
+func (p *objParser) parseFace(corners []string) error {
+	if len(corners) < 3 {
+		return fmt.Errorf("face with fewer than three corners")
+	}
+	if p.builder == nil {
+		p.builder = newMeshBuilder(p.objectName, p.positions, p.uvs, p.normals)
+	}
+
+	// the streams may have grown since the builder was made
+	p.builder.positions = p.positions
+	p.builder.uvs = p.uvs
+	p.builder.normals = p.normals
+
+	keys := make([]vertexKey, len(corners))
+	for i, corner := range corners {
+		parts := strings.Split(corner, "/")
+		var err error
+		keys[i].position, err = objIndex(parts[0], len(p.positions))
+		if err != nil {
+			return err
+		}
+		keys[i].uv, keys[i].normal = -1, -1
+		if len(parts) > 1 && parts[1] != "" {
+			if keys[i].uv, err = objIndex(parts[1], len(p.uvs)); err != nil {
+				return err
+			}
+			p.hasUvs = true
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			if keys[i].normal, err = objIndex(parts[2], len(p.normals)); err != nil {
+				return err
+			}
+			p.hasNormals = true
+		}
+	}
+	p.builder.polygon(keys)
+	return nil
+}
+
+// flush finishes the current mesh, if it has any faces, and adds it to the scene.
+func (p *objParser) flush() {
+	if p.builder != nil && !p.builder.empty() {
+		mesh := p.builder.finish(p.hasUvs, p.hasNormals)
+		p.scene.Meshes = append(p.scene.Meshes, SceneMesh{Mesh: mesh, Material: p.material})
+	}
+	p.builder = nil
+	p.hasUvs = false
+	p.hasNormals = false
+}
+
+// loadMaterialLibrary adds the materials of an mtl file to the scene.
+func (p *objParser) loadMaterialLibrary(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var mat *Material
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "newmtl" {
+			if len(fields) < 2 {
+				return fmt.Errorf("unnamed material in %s", filename)
+			}
+			p.materials[fields[1]] = len(p.scene.Materials)
+			p.scene.Materials = append(p.scene.Materials, newMaterial(fields[1]))
+			mat = &p.scene.Materials[len(p.scene.Materials)-1]
+			continue
+		}
+		if mat == nil || len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "Kd":
+			if v, err := parseFloats(fields[1:], 3); err == nil {
+				mat.Diffuse = mgl.Vec4{v[0], v[1], v[2], mat.Diffuse[3]}
+			}
+		case "Ks":
+			if v, err := parseFloats(fields[1:], 3); err == nil {
+				mat.Specular = mgl.Vec4{v[0], v[1], v[2], 1.0}
+			}
+		case "Ns":
+			if v, err := parseFloats(fields[1:], 1); err == nil {
+				mat.Shininess = v[0]
+			}
+		case "d":
+			if v, err := parseFloats(fields[1:], 1); err == nil {
+				mat.Diffuse[3] = v[0]
+			}
+		case "map_Kd":
+			// the file name is last, after any options
+			mat.DiffuseTexture = fields[len(fields)-1]
+		case "map_Bump", "map_bump", "bump", "norm":
+			mat.NormalTexture = fields[len(fields)-1]
+		}
+	}
+	return scanner.Err()
+}
+
+// objIndex converts a one based, possibly negative, OBJ index into a zero
+// based index into a stream of the given length.
+func objIndex(s string, length int) (int, error) {
+	idx, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if idx < 0 {
+		idx = length + idx
+	} else {
+		idx--
+	}
+	if idx < 0 || idx >= length {
+		return 0, fmt.Errorf("index %s is out of range", s)
+	}
+	return idx, nil
+}
+
+// parseFloats parses the first count fields as floats.
+func parseFloats(fields []string, count int) ([]float32, error) {
+	if len(fields) < count {
+		return nil, fmt.Errorf("expected %d values but found %d", count, len(fields))
+	}
+	values := make([]float32, count)
+	for i := 0; i < count; i++ {
+		v, err := strconv.ParseFloat(fields[i], 32)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
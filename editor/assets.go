@@ -0,0 +1,295 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/component"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/gombz"
+	"github.com/tbogdala/groggy"
+)
+
+// AssetKind is the type of an asset file.
+type AssetKind int
+
+const (
+	// AssetUnknown is a file the browser doesn't know how to use.
+	AssetUnknown AssetKind = iota
+
+	// AssetMesh is a gombz mesh file.
+	AssetMesh
+
+	// AssetTexture is an image file.
+	AssetTexture
+
+	// AssetComponent is a JSON component file.
+	AssetComponent
+)
+
+const (
+	// defaultDropDistance is how far along the mouse ray a dropped asset is
+	// placed if the ray doesn't hit the ground plane.
+	defaultDropDistance = 10.0
+)
+
+// GetAssetKind returns the kind of asset based on the file extension.
+func GetAssetKind(path string) AssetKind {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gombz":
+		return AssetMesh
+	case ".png", ".jpg", ".jpeg":
+		return AssetTexture
+	case ".json":
+		return AssetComponent
+	}
+	return AssetUnknown
+}
+
+// Asset is a file found by the AssetBrowser.
+type Asset struct {
+	Path    string
+	Name    string
+	Kind    AssetKind
+	ModTime time.Time
+
+	// Thumbnail is the preview texture, created by GetThumbnail; 0 until then.
+	Thumbnail graphics.Texture
+}
+
+// AssetBrowser is the model behind an asset panel. It scans directories for
+// meshes, textures and components, creates thumbnails for them on demand
+// and places them into the scene when dragged onto the viewport.
+type AssetBrowser struct {
+	// Directories are scanned recursively for assets.
+	Directories []string
+
+	// Assets are the assets found by the last Scan in lexical order for
+	// each directory.
+	Assets []*Asset
+
+	// Thumbnails draws the thumbnails of meshes and components. If nil,
+	// only textures get thumbnails.
+	Thumbnails *ThumbnailRenderer
+
+	// Components loads the component assets. If nil, components can't be
+	// instantiated or previewed.
+	Components *component.ComponentManager
+
+	// Shader is assigned to the meshes created from mesh assets.
+	Shader *fizzle.RenderShader
+
+	dragging *Asset
+}
+
+// NewAssetBrowser creates a new browser for the directories. Scan has to be
+// called to find the assets.
+func NewAssetBrowser(directories ...string) *AssetBrowser {
+	ab := new(AssetBrowser)
+	ab.Directories = directories
+	ab.Assets = make([]*Asset, 0, 64)
+	return ab
+}
+
+// Destroy deletes all of the thumbnail textures.
+func (ab *AssetBrowser) Destroy() {
+	for _, a := range ab.Assets {
+		ab.deleteThumbnail(a)
+	}
+}
+
+// Scan walks the directories and updates the list of assets. Assets that
+// haven't changed since the last scan keep their thumbnails.
+func (ab *AssetBrowser) Scan() error {
+	existing := make(map[string]*Asset, len(ab.Assets))
+	for _, a := range ab.Assets {
+		existing[a.Path] = a
+	}
+
+	found := make([]*Asset, 0, len(ab.Assets))
+	for _, dir := range ab.Directories {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			kind := GetAssetKind(path)
+			if kind == AssetUnknown {
+				return nil
+			}
+
+			if a, okay := existing[path]; okay {
+				delete(existing, path)
+				if !info.ModTime().After(a.ModTime) {
+					found = append(found, a)
+					return nil
+				}
+				ab.deleteThumbnail(a)
+			}
+
+			a := new(Asset)
+			a.Path = path
+			a.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			a.Kind = kind
+			a.ModTime = info.ModTime()
+			found = append(found, a)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan the asset directory %s: %v", dir, err)
+		}
+	}
+
+	// whatever wasn't found again has been removed
+	for _, a := range existing {
+		ab.deleteThumbnail(a)
+	}
+
+	ab.Assets = found
+	return nil
+}
+
+// Filter returns the assets of the kind, or all kinds for AssetUnknown,
+// whose names contain the text, ignoring case.
+func (ab *AssetBrowser) Filter(kind AssetKind, text string) []*Asset {
+	text = strings.ToLower(text)
+	result := make([]*Asset, 0, len(ab.Assets))
+	for _, a := range ab.Assets {
+		if kind != AssetUnknown && a.Kind != kind {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.ToLower(a.Name), text) {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+// GetThumbnail returns the thumbnail texture for the asset, creating it the
+// first time. Textures are their own thumbnails. It returns 0 if no
+// thumbnail could be made.
+func (ab *AssetBrowser) GetThumbnail(a *Asset) graphics.Texture {
+	if a.Thumbnail != 0 {
+		return a.Thumbnail
+	}
+
+	var err error
+	switch a.Kind {
+	case AssetTexture:
+		a.Thumbnail, err = fizzle.LoadImageToTexture(a.Path)
+
+	case AssetMesh, AssetComponent:
+		if ab.Thumbnails == nil {
+			return 0
+		}
+		var r *fizzle.Renderable
+		r, err = ab.Instantiate(a)
+		if err == nil {
+			a.Thumbnail, err = ab.Thumbnails.Render(r)
+
+			// component instances share their cores with the component so
+			// only meshes created just for the thumbnail get destroyed
+			if a.Kind == AssetMesh {
+				r.Destroy()
+			}
+		}
+	}
+
+	if err != nil {
+		groggy.Logsf("ERROR", "AssetBrowser failed to create a thumbnail for %s: %v", a.Path, err)
+		return 0
+	}
+	return a.Thumbnail
+}
+
+// Instantiate creates a new Renderable for a mesh or component asset.
+func (ab *AssetBrowser) Instantiate(a *Asset) (*fizzle.Renderable, error) {
+	switch a.Kind {
+	case AssetMesh:
+		meshBytes, err := ioutil.ReadFile(a.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the mesh file %s: %v", a.Path, err)
+		}
+		mesh, err := gombz.DecodeMesh(meshBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the mesh file %s: %v", a.Path, err)
+		}
+		r := fizzle.CreateFromGombz(mesh)
+		r.Core.Shader = ab.Shader
+		return r, nil
+
+	case AssetComponent:
+		if ab.Components == nil {
+			return nil, fmt.Errorf("no component manager to load %s", a.Path)
+		}
+		comp, err := ab.Components.LoadComponentFromFile(a.Path, a.Path)
+		if err != nil {
+			return nil, err
+		}
+		return ab.Components.GetRenderableInstance(comp), nil
+	}
+
+	return nil, fmt.Errorf("%s can't be placed in the scene", a.Path)
+}
+
+// BeginDrag starts dragging the asset from the panel.
+func (ab *AssetBrowser) BeginDrag(a *Asset) {
+	ab.dragging = a
+}
+
+// GetDraggedAsset returns the asset being dragged or nil.
+func (ab *AssetBrowser) GetDraggedAsset() *Asset {
+	return ab.dragging
+}
+
+// CancelDrag stops dragging without placing anything, such as when the
+// mouse is released outside of the viewport.
+func (ab *AssetBrowser) CancelDrag() {
+	ab.dragging = nil
+}
+
+// Drop finishes dragging by creating the dragged asset where the mouse ray
+// hits the ground plane (Y = 0), adding it to the hierarchy and selecting it.
+func (ab *AssetBrowser) Drop(ray Ray, h *Hierarchy) (*fizzle.Renderable, error) {
+	a := ab.dragging
+	ab.dragging = nil
+	if a == nil {
+		return nil, nil
+	}
+
+	r, err := ab.Instantiate(a)
+	if err != nil {
+		return nil, err
+	}
+
+	dist, okay := ray.IntersectPlane(mgl.Vec3{}, mgl.Vec3{0.0, 1.0, 0.0})
+	if !okay {
+		dist = defaultDropDistance
+	}
+	r.Location = ray.At(dist)
+
+	h.Add(r, a.Name)
+	h.Selection.Set(r)
+	return r, nil
+}
+
+// deleteThumbnail deletes the asset's thumbnail texture if it has one.
+func (ab *AssetBrowser) deleteThumbnail(a *Asset) {
+	if a.Thumbnail == 0 {
+		return
+	}
+	fizzle.GetGraphics().DeleteTexture(a.Thumbnail)
+	a.Thumbnail = 0
+}
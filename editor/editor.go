@@ -0,0 +1,136 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The editor module contains the building blocks for editing scenes visually,
+such as transform gizmos, the scene hierarchy, asset browsing, undo and
+the reference grid, which are independent of the user interface library
+the editor application uses for its panels.
+
+*/
+
+package editor
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Ray is a half line used for picking things in the viewport with the mouse.
+type Ray struct {
+	Origin    mgl.Vec3
+	Direction mgl.Vec3
+}
+
+// ScreenRay returns the ray from the camera through the window position in
+// pixels, with the origin in the top left corner of the window like the
+// cursor positions reported by GLFW.
+func ScreenRay(x, y float32, width, height int32, perspective mgl.Mat4, view mgl.Mat4) Ray {
+	// OpenGL window coordinates start in the bottom left corner
+	winY := float32(height) - y
+	near, errNear := mgl.UnProject(mgl.Vec3{x, winY, 0.0}, view, perspective, 0, 0, int(width), int(height))
+	far, errFar := mgl.UnProject(mgl.Vec3{x, winY, 1.0}, view, perspective, 0, 0, int(width), int(height))
+	if errNear != nil || errFar != nil {
+		return Ray{Direction: mgl.Vec3{0.0, 0.0, -1.0}}
+	}
+	return Ray{Origin: near, Direction: far.Sub(near).Normalize()}
+}
+
+// At returns the point at the distance along the ray.
+func (r Ray) At(distance float32) mgl.Vec3 {
+	return r.Origin.Add(r.Direction.Mul(distance))
+}
+
+// IntersectPlane returns the distance along the ray to the plane through
+// the point with the normal, and false if the ray is parallel to the plane
+// or the plane is behind the ray.
+func (r Ray) IntersectPlane(point mgl.Vec3, normal mgl.Vec3) (float32, bool) {
+	denom := normal.Dot(r.Direction)
+	if denom > -1e-6 && denom < 1e-6 {
+		return 0.0, false
+	}
+	distance := point.Sub(r.Origin).Dot(normal) / denom
+	return distance, distance >= 0.0
+}
+
+// ClosestToLine returns the parameter along the line through the point
+// in the direction (unit length) that is closest to the ray and the
+// distance between the ray and the line at that point.
+func (r Ray) ClosestToLine(point mgl.Vec3, direction mgl.Vec3) (float32, float32) {
+	w := r.Origin.Sub(point)
+	b := r.Direction.Dot(direction)
+	d := r.Direction.Dot(w)
+	e := direction.Dot(w)
+	denom := 1.0 - b*b
+	if denom < 1e-6 {
+		// parallel, so any point on the line is as close as the next
+		return e, w.Sub(direction.Mul(e)).Len()
+	}
+
+	rayT := (b*e - d) / denom
+	if rayT < 0.0 {
+		rayT = 0.0
+	}
+	lineT := e + b*rayT
+	closest := point.Add(direction.Mul(lineT))
+	return lineT, r.At(rayT).Sub(closest).Len()
+}
+
+// DistanceToPoint returns the distance from the point to the closest point
+// on the ray.
+func (r Ray) DistanceToPoint(point mgl.Vec3) float32 {
+	w := point.Sub(r.Origin)
+	t := w.Dot(r.Direction)
+	if t < 0.0 {
+		return w.Len()
+	}
+	return w.Sub(r.Direction.Mul(t)).Len()
+}
+
+// IntersectBox returns the distance along the ray to the axis aligned box
+// and false if the ray misses it.
+func (r Ray) IntersectBox(min mgl.Vec3, max mgl.Vec3) (float32, bool) {
+	near := float32(-math.MaxFloat32)
+	far := float32(math.MaxFloat32)
+	for i := 0; i < 3; i++ {
+		if r.Direction[i] > -1e-6 && r.Direction[i] < 1e-6 {
+			if r.Origin[i] < min[i] || r.Origin[i] > max[i] {
+				return 0.0, false
+			}
+			continue
+		}
+		t1 := (min[i] - r.Origin[i]) / r.Direction[i]
+		t2 := (max[i] - r.Origin[i]) / r.Direction[i]
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		if t1 > near {
+			near = t1
+		}
+		if t2 < far {
+			far = t2
+		}
+		if near > far || far < 0.0 {
+			return 0.0, false
+		}
+	}
+	if near < 0.0 {
+		near = 0.0
+	}
+	return near, true
+}
+
+// Snap rounds the value to the nearest multiple of the step; a step of zero
+// or less disables snapping.
+func Snap(value float32, step float32) float32 {
+	if step <= 0.0 {
+		return value
+	}
+	n := value / step
+	if n < 0.0 {
+		return float32(int(n-0.5)) * step
+	}
+	return float32(int(n+0.5)) * step
+}
@@ -0,0 +1,445 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+// GizmoMode is the kind of transform the gizmo edits.
+type GizmoMode int
+
+const (
+	// GizmoTranslate moves objects along the axes or planes.
+	GizmoTranslate GizmoMode = iota
+
+	// GizmoRotate rotates objects around the axes.
+	GizmoRotate
+
+	// GizmoScale scales objects along the axes or uniformly.
+	GizmoScale
+)
+
+// GizmoHandle is a part of the gizmo that can be dragged.
+type GizmoHandle int
+
+const (
+	// HandleNone means no handle.
+	HandleNone GizmoHandle = iota
+	HandleX
+	HandleY
+	HandleZ
+	HandleXY
+	HandleXZ
+	HandleYZ
+
+	// HandleUniform is the center handle that scales on all axes.
+	HandleUniform
+)
+
+const (
+	// DefaultGizmoSize is the default size of the gizmo as a fraction of
+	// the viewport height.
+	DefaultGizmoSize = 0.15
+
+	// gizmoPickTolerance is how close, relative to the gizmo size, the
+	// mouse ray has to pass to a handle to pick it.
+	gizmoPickTolerance = 0.08
+
+	// the range along both axes, relative to the gizmo size, of the plane handles
+	gizmoPlaneMin = 0.2
+	gizmoPlaneMax = 0.45
+)
+
+var (
+	gizmoAxes = [3]mgl.Vec3{{1.0, 0.0, 0.0}, {0.0, 1.0, 0.0}, {0.0, 0.0, 1.0}}
+
+	gizmoColors = [3]mgl.Vec4{{0.9, 0.1, 0.1, 1.0}, {0.1, 0.9, 0.1, 1.0}, {0.1, 0.2, 0.9, 1.0}}
+
+	gizmoHotColor = mgl.Vec4{1.0, 0.9, 0.1, 1.0}
+)
+
+// TransformDelta is a change made with the gizmo. The rotation is in world
+// space and the scale is multiplied against the current scale.
+type TransformDelta struct {
+	Handle      GizmoHandle
+	Translation mgl.Vec3
+	Rotation    mgl.Quat
+	Scale       mgl.Vec3
+}
+
+// identityDelta returns a delta that changes nothing.
+func identityDelta(h GizmoHandle) TransformDelta {
+	return TransformDelta{Handle: h, Rotation: mgl.QuatIdent(), Scale: mgl.Vec3{1.0, 1.0, 1.0}}
+}
+
+// Apply applies the change to the Renderable's transform.
+func (d TransformDelta) Apply(r *fizzle.Renderable) {
+	r.Location = r.Location.Add(d.Translation)
+	r.Rotation = d.Rotation.Mul(r.Rotation)
+	r.Scale = mgl.Vec3{r.Scale[0] * d.Scale[0], r.Scale[1] * d.Scale[1], r.Scale[2] * d.Scale[2]}
+}
+
+// GizmoCallback is the type of the function called with the changes made
+// with the gizmo.
+type GizmoCallback func(delta TransformDelta)
+
+// Gizmo is a translate, rotate or scale manipulator drawn at an object's
+// location. It stays the same size on screen regardless of the distance
+// to the camera. While a handle is dragged, OnTransform is called with
+// the change since the last call and once the drag ends, OnDragEnd is
+// called with the change over the whole drag, which is what an undo
+// system should record.
+type Gizmo struct {
+	Mode GizmoMode
+
+	// Position is the location of the gizmo, normally the selected
+	// object's location. It follows translation drags.
+	Position mgl.Vec3
+
+	// Size is the size of the gizmo as a fraction of the viewport height.
+	Size float32
+
+	// TranslateSnap, RotateSnap (in radians) and ScaleSnap are the snapping
+	// increments for each mode; zero disables snapping.
+	TranslateSnap float32
+	RotateSnap    float32
+	ScaleSnap     float32
+
+	// OnTransform is called with the change since the last call during a drag.
+	OnTransform GizmoCallback
+
+	// OnDragEnd is called with the total change when a drag finishes.
+	OnDragEnd GizmoCallback
+
+	hot        GizmoHandle
+	active     GizmoHandle
+	wasDown    bool
+	worldScale float32
+	viewNormal mgl.Vec3
+
+	dragStartPos   mgl.Vec3
+	dragStartParam float32
+	dragStartPoint mgl.Vec3
+	total          TransformDelta
+
+	axisLines  [3]*fizzle.Renderable
+	planeLines [3][2]*fizzle.Renderable
+	rings      [3]*fizzle.Renderable
+	center     *fizzle.Renderable
+}
+
+// NewGizmo creates a new translation gizmo at the position.
+func NewGizmo(position mgl.Vec3) *Gizmo {
+	g := new(Gizmo)
+	g.Mode = GizmoTranslate
+	g.Position = position
+	g.Size = DefaultGizmoSize
+	return g
+}
+
+// Destroy releases the Renderables used to draw the gizmo.
+func (g *Gizmo) Destroy() {
+	for i := 0; i < 3; i++ {
+		destroyRenderable(g.axisLines[i])
+		destroyRenderable(g.planeLines[i][0])
+		destroyRenderable(g.planeLines[i][1])
+		destroyRenderable(g.rings[i])
+	}
+	destroyRenderable(g.center)
+	g.axisLines = [3]*fizzle.Renderable{}
+	g.planeLines = [3][2]*fizzle.Renderable{}
+	g.rings = [3]*fizzle.Renderable{}
+	g.center = nil
+}
+
+// GetHotHandle returns the handle under the mouse or being dragged.
+func (g *Gizmo) GetHotHandle() GizmoHandle {
+	if g.active != HandleNone {
+		return g.active
+	}
+	return g.hot
+}
+
+// IsDragging returns true while a handle is being dragged.
+func (g *Gizmo) IsDragging() bool {
+	return g.active != HandleNone
+}
+
+// Update handles the mouse interaction for the frame. The ray is the mouse
+// ray from ScreenRay and mouseDown is the state of the button used to drag
+// handles. It returns true if the gizmo is using the mouse, in which case
+// the click shouldn't also select objects in the scene.
+func (g *Gizmo) Update(ray Ray, cameraPosition mgl.Vec3, perspective mgl.Mat4, mouseDown bool) bool {
+	toCamera := cameraPosition.Sub(g.Position)
+	distance := toCamera.Len()
+	g.worldScale = g.Size * 2.0 * distance / perspective[5]
+	if distance > 0.0 {
+		g.viewNormal = toCamera.Mul(1.0 / distance)
+	}
+
+	pressed := mouseDown && !g.wasDown
+	g.wasDown = mouseDown
+
+	if g.active == HandleNone {
+		g.hot = g.pick(ray)
+		if pressed && g.hot != HandleNone {
+			g.beginDrag(ray)
+		}
+		return g.hot != HandleNone
+	}
+
+	if !mouseDown {
+		g.active = HandleNone
+		if g.OnDragEnd != nil {
+			g.OnDragEnd(g.total)
+		}
+		return true
+	}
+
+	g.drag(ray)
+	return true
+}
+
+// pick returns the handle the ray passes over.
+func (g *Gizmo) pick(ray Ray) GizmoHandle {
+	s := g.worldScale
+	tolerance := gizmoPickTolerance * s
+	best := HandleNone
+	bestDist := tolerance
+
+	switch g.Mode {
+	case GizmoRotate:
+		for i, axis := range gizmoAxes {
+			hit, okay := ray.IntersectPlane(g.Position, axis)
+			if !okay {
+				continue
+			}
+			d := float32(math.Abs(float64(ray.At(hit).Sub(g.Position).Len() - s)))
+			if d < bestDist {
+				best, bestDist = HandleX+GizmoHandle(i), d
+			}
+		}
+		return best
+
+	case GizmoScale:
+		if ray.DistanceToPoint(g.Position) < tolerance*1.5 {
+			return HandleUniform
+		}
+
+	case GizmoTranslate:
+		// the plane handles are checked first since they sit between the axes
+		for i, plane := range [3]GizmoHandle{HandleYZ, HandleXZ, HandleXY} {
+			hit, okay := ray.IntersectPlane(g.Position, gizmoAxes[i])
+			if !okay {
+				continue
+			}
+			local := ray.At(hit).Sub(g.Position)
+			a, b := gizmoAxes[(i+1)%3], gizmoAxes[(i+2)%3]
+			u, v := local.Dot(a)/s, local.Dot(b)/s
+			if u >= gizmoPlaneMin && u <= gizmoPlaneMax && v >= gizmoPlaneMin && v <= gizmoPlaneMax {
+				return plane
+			}
+		}
+	}
+
+	for i, axis := range gizmoAxes {
+		t, d := ray.ClosestToLine(g.Position, axis)
+		if t >= 0.0 && t <= s && d < bestDist {
+			best, bestDist = HandleX+GizmoHandle(i), d
+		}
+	}
+	return best
+}
+
+// beginDrag records the starting state for dragging the hot handle.
+func (g *Gizmo) beginDrag(ray Ray) {
+	g.active = g.hot
+	g.dragStartPos = g.Position
+	g.total = identityDelta(g.active)
+
+	switch {
+	case g.active == HandleUniform:
+		g.dragStartParam = g.uniformDistance(ray)
+	case g.isPlaneHandle(g.active):
+		normal := gizmoAxes[g.planeNormalAxis(g.active)]
+		hit, _ := ray.IntersectPlane(g.dragStartPos, normal)
+		g.dragStartPoint = ray.At(hit)
+	case g.Mode == GizmoRotate:
+		axis := gizmoAxes[g.active-HandleX]
+		hit, _ := ray.IntersectPlane(g.dragStartPos, axis)
+		g.dragStartPoint = ray.At(hit).Sub(g.dragStartPos)
+	default:
+		g.dragStartParam, _ = ray.ClosestToLine(g.dragStartPos, gizmoAxes[g.active-HandleX])
+	}
+}
+
+// drag updates the transform for the active handle and emits the change.
+func (g *Gizmo) drag(ray Ray) {
+	next := identityDelta(g.active)
+
+	switch {
+	case g.active == HandleUniform:
+		if g.dragStartParam <= 0.0 {
+			return
+		}
+		f := Snap(g.uniformDistance(ray)/g.dragStartParam, g.ScaleSnap)
+		if f <= 0.0 {
+			return
+		}
+		next.Scale = mgl.Vec3{f, f, f}
+
+	case g.isPlaneHandle(g.active):
+		i := g.planeNormalAxis(g.active)
+		hit, okay := ray.IntersectPlane(g.dragStartPos, gizmoAxes[i])
+		if !okay {
+			return
+		}
+		diff := ray.At(hit).Sub(g.dragStartPoint)
+		a, b := gizmoAxes[(i+1)%3], gizmoAxes[(i+2)%3]
+		next.Translation = a.Mul(Snap(diff.Dot(a), g.TranslateSnap)).Add(b.Mul(Snap(diff.Dot(b), g.TranslateSnap)))
+
+	case g.Mode == GizmoRotate:
+		axis := gizmoAxes[g.active-HandleX]
+		hit, okay := ray.IntersectPlane(g.dragStartPos, axis)
+		if !okay {
+			return
+		}
+		v := ray.At(hit).Sub(g.dragStartPos)
+		angle := float32(math.Atan2(float64(axis.Dot(g.dragStartPoint.Cross(v))), float64(g.dragStartPoint.Dot(v))))
+		next.Rotation = mgl.QuatRotate(Snap(angle, g.RotateSnap), axis)
+
+	case g.Mode == GizmoScale:
+		i := g.active - HandleX
+		t, _ := ray.ClosestToLine(g.dragStartPos, gizmoAxes[i])
+		f := Snap(1.0+(t-g.dragStartParam)/g.worldScale, g.ScaleSnap)
+		if f <= 0.0 {
+			return
+		}
+		next.Scale[i] = f
+
+	default:
+		axis := gizmoAxes[g.active-HandleX]
+		t, _ := ray.ClosestToLine(g.dragStartPos, axis)
+		next.Translation = axis.Mul(Snap(t-g.dragStartParam, g.TranslateSnap))
+	}
+
+	// the change since the last update
+	delta := identityDelta(g.active)
+	delta.Translation = next.Translation.Sub(g.total.Translation)
+	delta.Rotation = next.Rotation.Mul(g.total.Rotation.Inverse())
+	for i := 0; i < 3; i++ {
+		delta.Scale[i] = next.Scale[i] / g.total.Scale[i]
+	}
+
+	g.total = next
+	g.Position = g.dragStartPos.Add(next.Translation)
+	if g.OnTransform != nil {
+		g.OnTransform(delta)
+	}
+}
+
+// uniformDistance returns how far from the gizmo the ray passes in the
+// plane facing the camera.
+func (g *Gizmo) uniformDistance(ray Ray) float32 {
+	hit, okay := ray.IntersectPlane(g.dragStartPos, g.viewNormal)
+	if !okay {
+		return 0.0
+	}
+	return ray.At(hit).Sub(g.dragStartPos).Len()
+}
+
+// isPlaneHandle returns true for the handles that move on a plane.
+func (g *Gizmo) isPlaneHandle(h GizmoHandle) bool {
+	return h == HandleXY || h == HandleXZ || h == HandleYZ
+}
+
+// planeNormalAxis returns the index of the axis normal to the plane handle.
+func (g *Gizmo) planeNormalAxis(h GizmoHandle) int {
+	switch h {
+	case HandleYZ:
+		return 0
+	case HandleXZ:
+		return 1
+	}
+	return 2
+}
+
+// createRenderables makes the line Renderables for drawing the gizmo at unit size.
+func (g *Gizmo) createRenderables() {
+	g.axisLines[0] = fizzle.CreateLine(0.0, 0.0, 0.0, 1.0, 0.0, 0.0)
+	g.axisLines[1] = fizzle.CreateLine(0.0, 0.0, 0.0, 0.0, 1.0, 0.0)
+	g.axisLines[2] = fizzle.CreateLine(0.0, 0.0, 0.0, 0.0, 0.0, 1.0)
+
+	g.rings[0] = fizzle.CreateWireframeCircle(0.0, 0.0, 0.0, 1.0, 48, fizzle.Z|fizzle.Y)
+	g.rings[1] = fizzle.CreateWireframeCircle(0.0, 0.0, 0.0, 1.0, 48, fizzle.X|fizzle.Z)
+	g.rings[2] = fizzle.CreateWireframeCircle(0.0, 0.0, 0.0, 1.0, 48, fizzle.X|fizzle.Y)
+
+	// each plane handle is drawn as the outer corner of its square
+	for i := 0; i < 3; i++ {
+		a, b := gizmoAxes[(i+1)%3], gizmoAxes[(i+2)%3]
+		corner := a.Add(b).Mul(gizmoPlaneMax)
+		pa := a.Mul(gizmoPlaneMax).Add(b.Mul(gizmoPlaneMin))
+		pb := b.Mul(gizmoPlaneMax).Add(a.Mul(gizmoPlaneMin))
+		g.planeLines[i][0] = fizzle.CreateLine(pa[0], pa[1], pa[2], corner[0], corner[1], corner[2])
+		g.planeLines[i][1] = fizzle.CreateLine(pb[0], pb[1], pb[2], corner[0], corner[1], corner[2])
+	}
+
+	const c = gizmoPickTolerance
+	g.center = fizzle.CreateWireframeCube(-c, -c, -c, c, c, c)
+}
+
+// Draw draws the gizmo with a solid color shader that uses the
+// MATERIAL_DIFFUSE uniform. Depth testing is disabled while drawing so the
+// gizmo is always visible and is enabled again afterwards.
+func (g *Gizmo) Draw(rend renderer.Renderer, shader *fizzle.RenderShader, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	if g.center == nil {
+		g.createRenderables()
+	}
+
+	gfx := rend.GetGraphics()
+	gfx.Disable(graphics.DEPTH_TEST)
+	hot := g.GetHotHandle()
+
+	draw := func(r *fizzle.Renderable, color mgl.Vec4, highlighted bool) {
+		if highlighted {
+			color = gizmoHotColor
+		}
+		r.Location = g.Position
+		r.Scale = mgl.Vec3{g.worldScale, g.worldScale, g.worldScale}
+		r.Core.DiffuseColor = color
+		rend.DrawLines(r, shader, nil, perspective, view, camera)
+	}
+
+	for i := 0; i < 3; i++ {
+		h := HandleX + GizmoHandle(i)
+		if g.Mode == GizmoRotate {
+			draw(g.rings[i], gizmoColors[i], hot == h)
+			continue
+		}
+		draw(g.axisLines[i], gizmoColors[i], hot == h)
+		if g.Mode == GizmoTranslate {
+			plane := [3]GizmoHandle{HandleYZ, HandleXZ, HandleXY}[i]
+			draw(g.planeLines[i][0], gizmoColors[i], hot == plane)
+			draw(g.planeLines[i][1], gizmoColors[i], hot == plane)
+		}
+	}
+	if g.Mode == GizmoScale {
+		draw(g.center, mgl.Vec4{0.9, 0.9, 0.9, 1.0}, hot == HandleUniform)
+	}
+
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// destroyRenderable destroys the Renderable if it isn't nil.
+func destroyRenderable(r *fizzle.Renderable) {
+	if r != nil {
+		r.Destroy()
+	}
+}
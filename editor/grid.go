@@ -0,0 +1,166 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+var (
+	// GridVertShader330 is the GLSL vertex shader for the reference grid. It
+	// draws a full screen quad and unprojects each corner to a ray.
+	GridVertShader330 = `#version 330
+  uniform mat4 VP_MATRIX;
+  in vec3 VERTEX_POSITION;
+
+  out vec3 near_point;
+  out vec3 far_point;
+
+  vec3 unproject(vec3 p, mat4 invVP) {
+    vec4 world = invVP * vec4(p, 1.0);
+    return world.xyz / world.w;
+  }
+
+  void main()
+  {
+    mat4 invVP = inverse(VP_MATRIX);
+    near_point = unproject(vec3(VERTEX_POSITION.xy, -1.0), invVP);
+    far_point = unproject(vec3(VERTEX_POSITION.xy, 1.0), invVP);
+    gl_Position = vec4(VERTEX_POSITION.xy, 0.0, 1.0);
+  }`
+
+	// GridFragShader330 is the GLSL fragment shader for the reference grid. It
+	// intersects the view ray with the Y = 0 plane and draws anti-aliased
+	// minor and major lines that fade out with distance.
+	GridFragShader330 = `#version 330
+  uniform mat4 VP_MATRIX;
+  uniform float GRID_SPACING;
+  uniform float GRID_MAJOR;
+  uniform float GRID_FADE;
+  uniform vec4 GRID_COLOR;
+  uniform vec4 GRID_MAJOR_COLOR;
+
+  in vec3 near_point;
+  in vec3 far_point;
+
+  out vec4 frag_color;
+
+  float gridLine(vec2 coord, float spacing) {
+    vec2 c = coord / spacing;
+    vec2 lines = abs(fract(c - 0.5) - 0.5) / fwidth(c);
+    return 1.0 - min(min(lines.x, lines.y), 1.0);
+  }
+
+  void main()
+  {
+    float t = -near_point.y / (far_point.y - near_point.y);
+    if (t <= 0.0) {
+      discard;
+    }
+    vec3 p = near_point + t * (far_point - near_point);
+
+    vec4 clip = VP_MATRIX * vec4(p, 1.0);
+    gl_FragDepth = (clip.z / clip.w) * 0.5 + 0.5;
+
+    vec4 color = GRID_COLOR * gridLine(p.xz, GRID_SPACING);
+    color = mix(color, GRID_MAJOR_COLOR, gridLine(p.xz, GRID_SPACING * GRID_MAJOR));
+
+    // the world axes
+    vec2 width = fwidth(p.xz);
+    if (abs(p.x) < width.x) {
+      color = vec4(0.1, 0.2, 0.9, 1.0);
+    }
+    if (abs(p.z) < width.y) {
+      color = vec4(0.9, 0.1, 0.1, 1.0);
+    }
+
+    color.a *= 1.0 - clamp(length(p - near_point) / GRID_FADE, 0.0, 1.0);
+    if (color.a <= 0.0) {
+      discard;
+    }
+    frag_color = color;
+  }`
+)
+
+// Grid draws an infinite reference grid on the Y = 0 plane.
+type Grid struct {
+	// Spacing is the distance between the minor grid lines.
+	Spacing float32
+
+	// MajorEvery is the number of minor cells between major lines.
+	MajorEvery int
+
+	// FadeDistance is the distance from the camera where the grid has faded out.
+	FadeDistance float32
+
+	// Color and MajorColor are the colors of the minor and major lines.
+	Color      mgl.Vec4
+	MajorColor mgl.Vec4
+
+	shader *fizzle.RenderShader
+	quad   *fizzle.Renderable
+}
+
+// NewGrid compiles the grid shader and creates a new grid with 1 unit spacing.
+func NewGrid() (*Grid, error) {
+	shader, err := fizzle.LoadShaderProgram(GridVertShader330, GridFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	g := new(Grid)
+	g.Spacing = 1.0
+	g.MajorEvery = 10
+	g.FadeDistance = 100.0
+	g.Color = mgl.Vec4{0.5, 0.5, 0.5, 0.4}
+	g.MajorColor = mgl.Vec4{0.7, 0.7, 0.7, 0.7}
+	g.shader = shader
+	g.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	return g, nil
+}
+
+// Destroy releases the shader and quad used to draw the grid.
+func (g *Grid) Destroy() {
+	g.shader.Destroy()
+	g.quad.Destroy()
+}
+
+// SnapPoint returns the point with X and Z snapped to the nearest grid line.
+func (g *Grid) SnapPoint(p mgl.Vec3) mgl.Vec3 {
+	return mgl.Vec3{Snap(p[0], g.Spacing), p[1], Snap(p[2], g.Spacing)}
+}
+
+// Draw draws the grid with alpha blending. Depth is written where the grid
+// is so that it's hidden behind objects drawn before it and hides ones drawn
+// after, so it should be drawn after the opaque scene.
+func (g *Grid) Draw(rend renderer.Renderer, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	gfx := rend.GetGraphics()
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE_MINUS_SRC_ALPHA)
+	rend.DrawRenderableWithShader(g.quad, g.shader, g.bindUniforms, perspective, view, camera)
+	gfx.Disable(graphics.BLEND)
+}
+
+// bindUniforms sets the grid uniforms of the shader.
+func (g *Grid) bindUniforms(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := rend.GetGraphics()
+	if loc := shader.GetUniformLocation("GRID_SPACING"); loc >= 0 {
+		gfx.Uniform1f(loc, g.Spacing)
+	}
+	if loc := shader.GetUniformLocation("GRID_MAJOR"); loc >= 0 {
+		gfx.Uniform1f(loc, float32(g.MajorEvery))
+	}
+	if loc := shader.GetUniformLocation("GRID_FADE"); loc >= 0 {
+		gfx.Uniform1f(loc, g.FadeDistance)
+	}
+	if loc := shader.GetUniformLocation("GRID_COLOR"); loc >= 0 {
+		gfx.Uniform4f(loc, g.Color[0], g.Color[1], g.Color[2], g.Color[3])
+	}
+	if loc := shader.GetUniformLocation("GRID_MAJOR_COLOR"); loc >= 0 {
+		gfx.Uniform4f(loc, g.MajorColor[0], g.MajorColor[1], g.MajorColor[2], g.MajorColor[3])
+	}
+}
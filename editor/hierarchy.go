@@ -0,0 +1,368 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// HierarchyRow is one visible line of the scene tree.
+type HierarchyRow struct {
+	Renderable  *fizzle.Renderable
+	Name        string
+	Depth       int
+	HasChildren bool
+	IsExpanded  bool
+	IsSelected  bool
+}
+
+// Hierarchy is the model behind a scene tree panel. It holds the root
+// Renderables of the scene, their display names and which branches are
+// expanded, and performs the tree edits on the Renderable Parent and
+// Children fields. Selection is shared with the viewport through Pick.
+type Hierarchy struct {
+	// Roots are the top level Renderables of the scene.
+	Roots []*fizzle.Renderable
+
+	// Selection is the current selection.
+	Selection *Selection
+
+	// OnChanged is called after the structure of the tree changes or a
+	// Renderable is renamed.
+	OnChanged func()
+
+	names     map[*fizzle.Renderable]string
+	collapsed map[*fizzle.Renderable]bool
+}
+
+// NewHierarchy creates a new empty hierarchy with its own selection.
+func NewHierarchy() *Hierarchy {
+	h := new(Hierarchy)
+	h.Roots = make([]*fizzle.Renderable, 0, 16)
+	h.Selection = NewSelection()
+	h.names = make(map[*fizzle.Renderable]string)
+	h.collapsed = make(map[*fizzle.Renderable]bool)
+	return h
+}
+
+// Add adds the Renderable as a new root with the name.
+func (h *Hierarchy) Add(r *fizzle.Renderable, name string) {
+	h.names[r] = name
+	h.Roots = append(h.Roots, r)
+	h.changed()
+}
+
+// GetName returns the display name of the Renderable.
+func (h *Hierarchy) GetName(r *fizzle.Renderable) string {
+	if name, okay := h.names[r]; okay {
+		return name
+	}
+	if r.IsGroup {
+		return "Group"
+	}
+	return "Renderable"
+}
+
+// Rename changes the display name of the Renderable.
+func (h *Hierarchy) Rename(r *fizzle.Renderable, name string) {
+	h.names[r] = name
+	h.changed()
+}
+
+// SetExpanded shows or hides the children of the Renderable in Rows.
+func (h *Hierarchy) SetExpanded(r *fizzle.Renderable, expanded bool) {
+	if expanded {
+		delete(h.collapsed, r)
+	} else {
+		h.collapsed[r] = true
+	}
+}
+
+// IsExpanded returns true if the children of the Renderable are shown in Rows.
+func (h *Hierarchy) IsExpanded(r *fizzle.Renderable) bool {
+	return !h.collapsed[r]
+}
+
+// Rows returns the visible rows of the tree in display order for the UI to draw.
+func (h *Hierarchy) Rows() []HierarchyRow {
+	rows := make([]HierarchyRow, 0, len(h.Roots))
+	var add func(r *fizzle.Renderable, depth int)
+	add = func(r *fizzle.Renderable, depth int) {
+		expanded := h.IsExpanded(r)
+		rows = append(rows, HierarchyRow{
+			Renderable:  r,
+			Name:        h.GetName(r),
+			Depth:       depth,
+			HasChildren: len(r.Children) > 0,
+			IsExpanded:  expanded,
+			IsSelected:  h.Selection.Contains(r),
+		})
+		if expanded {
+			for _, child := range r.Children {
+				add(child, depth+1)
+			}
+		}
+	}
+	for _, r := range h.Roots {
+		add(r, 0)
+	}
+	return rows
+}
+
+// IsAncestor returns true if ancestor is r or one of its parents.
+func IsAncestor(ancestor *fizzle.Renderable, r *fizzle.Renderable) bool {
+	for ; r != nil; r = r.Parent {
+		if r == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Reparent moves the Renderable to the end of the parent's children, or to
+// the end of the roots if parent is nil. This is what dropping a row onto
+// another row should do. The local transform of the Renderable is kept,
+// so it's now relative to the new parent.
+func (h *Hierarchy) Reparent(r *fizzle.Renderable, parent *fizzle.Renderable) error {
+	return h.Move(r, parent, -1)
+}
+
+// Move places the Renderable at the index among the parent's children, or
+// among the roots if parent is nil. An index out of range places it at the end.
+// An error is returned if the parent is the Renderable or one of its children.
+func (h *Hierarchy) Move(r *fizzle.Renderable, parent *fizzle.Renderable, index int) error {
+	if parent != nil && IsAncestor(r, parent) {
+		return fmt.Errorf("can't move %s under itself", h.GetName(r))
+	}
+
+	h.detach(r)
+	if parent == nil {
+		r.Parent = nil
+		h.Roots = insertRenderable(h.Roots, r, index)
+	} else {
+		r.Parent = parent
+		parent.Children = insertRenderable(parent.Children, r, index)
+	}
+	h.changed()
+	return nil
+}
+
+// IndexOf returns the parent of the Renderable and its index among the
+// parent's children, or among the roots if the parent is nil. The index is
+// -1 if the Renderable isn't in the tree.
+func (h *Hierarchy) IndexOf(r *fizzle.Renderable) (*fizzle.Renderable, int) {
+	siblings := h.Roots
+	if r.Parent != nil {
+		siblings = r.Parent.Children
+	}
+	for i, s := range siblings {
+		if s == r {
+			return r.Parent, i
+		}
+	}
+	return r.Parent, -1
+}
+
+// Duplicate clones the Renderable and its children, places the copy right
+// after the original and selects it. The copy shares the RenderableCore of
+// the original like Renderable.Clone does.
+func (h *Hierarchy) Duplicate(r *fizzle.Renderable) *fizzle.Renderable {
+	dup := r.Clone()
+	h.copyNames(r, dup)
+	h.names[dup] = h.GetName(r) + " copy"
+
+	parent, index := h.IndexOf(r)
+	h.Move(dup, parent, index+1)
+	h.Selection.Set(dup)
+	return dup
+}
+
+// Delete removes the Renderable and its children from the tree and the
+// selection. The Renderables aren't destroyed, since duplicates share
+// their cores and an undo may put them back with Move, so it's up to the
+// caller to destroy them when they're no longer needed. The old parent and
+// index are returned for that purpose.
+func (h *Hierarchy) Delete(r *fizzle.Renderable) (*fizzle.Renderable, int) {
+	parent, index := h.IndexOf(r)
+	h.detach(r)
+
+	deselected := false
+	r.Map(func(rr *fizzle.Renderable) {
+		if i := h.Selection.indexOf(rr); i >= 0 {
+			h.Selection.items = append(h.Selection.items[:i], h.Selection.items[i+1:]...)
+			deselected = true
+		}
+	})
+	if deselected {
+		h.Selection.changed()
+	}
+
+	h.changed()
+	return parent, index
+}
+
+// DeleteSelected deletes all of the selected Renderables and returns them.
+// Renderables whose parent is also selected go along with the parent.
+func (h *Hierarchy) DeleteSelected() []*fizzle.Renderable {
+	tops := h.selectedTops()
+	for _, r := range tops {
+		h.Delete(r)
+	}
+	return tops
+}
+
+// DuplicateSelected duplicates all of the selected Renderables and selects the copies.
+func (h *Hierarchy) DuplicateSelected() []*fizzle.Renderable {
+	tops := h.selectedTops()
+	dups := make([]*fizzle.Renderable, 0, len(tops))
+	for _, r := range tops {
+		dups = append(dups, h.Duplicate(r))
+	}
+	h.Selection.Set(dups...)
+	return dups
+}
+
+// Pick returns the closest visible Renderable whose bounding box the ray
+// hits, or nil if it hits none. The bounding boxes are transformed into
+// world space and enclosed in a new axis aligned box, so the test is loose
+// for rotated objects.
+func (h *Hierarchy) Pick(ray Ray) *fizzle.Renderable {
+	var closest *fizzle.Renderable
+	closestDist := float32(0.0)
+	for _, root := range h.Roots {
+		root.Map(func(r *fizzle.Renderable) {
+			if !r.IsVisible || r.IsGroup {
+				return
+			}
+			min, max := worldBounds(r)
+			dist, hit := ray.IntersectBox(min, max)
+			if hit && (closest == nil || dist < closestDist) {
+				closest, closestDist = r, dist
+			}
+		})
+	}
+	return closest
+}
+
+// Click updates the selection for a click in the viewport: the picked
+// Renderable replaces the selection, or is toggled in it if additive is
+// set. Clicking on nothing clears the selection unless additive is set.
+func (h *Hierarchy) Click(ray Ray, additive bool) *fizzle.Renderable {
+	r := h.Pick(ray)
+	switch {
+	case r != nil && additive:
+		h.Selection.Toggle(r)
+	case r != nil:
+		h.Selection.Set(r)
+	case !additive:
+		h.Selection.Clear()
+	}
+	return r
+}
+
+// selectedTops returns the selected Renderables that don't have a selected ancestor.
+func (h *Hierarchy) selectedTops() []*fizzle.Renderable {
+	tops := make([]*fizzle.Renderable, 0, h.Selection.Len())
+	for _, r := range h.Selection.Items() {
+		nested := false
+		for p := r.Parent; p != nil; p = p.Parent {
+			if h.Selection.Contains(p) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			tops = append(tops, r)
+		}
+	}
+	return tops
+}
+
+// detach removes the Renderable from its parent's children or the roots.
+func (h *Hierarchy) detach(r *fizzle.Renderable) {
+	if r.Parent != nil {
+		r.Parent.Children = removeRenderable(r.Parent.Children, r)
+		return
+	}
+	h.Roots = removeRenderable(h.Roots, r)
+}
+
+// copyNames gives the Renderables in the cloned tree the names of the
+// ones they were cloned from.
+func (h *Hierarchy) copyNames(src *fizzle.Renderable, dst *fizzle.Renderable) {
+	if name, okay := h.names[src]; okay {
+		h.names[dst] = name
+	}
+	for i := 0; i < len(src.Children) && i < len(dst.Children); i++ {
+		h.copyNames(src.Children[i], dst.Children[i])
+	}
+}
+
+// changed calls the OnChanged callback if one is set.
+func (h *Hierarchy) changed() {
+	if h.OnChanged != nil {
+		h.OnChanged()
+	}
+}
+
+// insertRenderable inserts the Renderable into the slice at the index,
+// or at the end if the index is out of range.
+func insertRenderable(list []*fizzle.Renderable, r *fizzle.Renderable, index int) []*fizzle.Renderable {
+	if index < 0 || index >= len(list) {
+		return append(list, r)
+	}
+	list = append(list, nil)
+	copy(list[index+1:], list[index:])
+	list[index] = r
+	return list
+}
+
+// removeRenderable removes the Renderable from the slice if it's there.
+func removeRenderable(list []*fizzle.Renderable, r *fizzle.Renderable) []*fizzle.Renderable {
+	for i, item := range list {
+		if item == r {
+			copy(list[i:], list[i+1:])
+			list[len(list)-1] = nil
+			return list[:len(list)-1]
+		}
+	}
+	return list
+}
+
+// worldBounds returns the world space axis aligned box that encloses the
+// transformed bounding rectangle of the Renderable.
+func worldBounds(r *fizzle.Renderable) (mgl.Vec3, mgl.Vec3) {
+	transform := r.GetTransformMat4()
+	b, t := r.BoundingRect.Bottom, r.BoundingRect.Top
+	var min, max mgl.Vec3
+	for i := 0; i < 8; i++ {
+		corner := mgl.Vec4{b[0], b[1], b[2], 1.0}
+		if i&1 != 0 {
+			corner[0] = t[0]
+		}
+		if i&2 != 0 {
+			corner[1] = t[1]
+		}
+		if i&4 != 0 {
+			corner[2] = t[2]
+		}
+		p := transform.Mul4x1(corner).Vec3()
+		if i == 0 {
+			min, max = p, p
+			continue
+		}
+		for j := 0; j < 3; j++ {
+			if p[j] < min[j] {
+				min[j] = p[j]
+			}
+			if p[j] > max[j] {
+				max[j] = p[j]
+			}
+		}
+	}
+	return min, max
+}
@@ -0,0 +1,150 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+// MeasureTool measures the distance between two points clicked in the
+// viewport. Points land on the bounding boxes of Renderables in the
+// hierarchy or on the ground plane (Y = 0).
+type MeasureTool struct {
+	// Snap, if set, snaps the measured points to the translation increment.
+	Snap *SnapSettings
+
+	// Color is the color of the measurement line.
+	Color mgl.Vec4
+
+	// OnMeasured is called with the distance when the second point is clicked.
+	OnMeasured func(distance float32)
+
+	points [2]mgl.Vec3
+	count  int
+	line   *fizzle.Renderable
+}
+
+// NewMeasureTool creates a new measuring tool with no points.
+func NewMeasureTool() *MeasureTool {
+	mt := new(MeasureTool)
+	mt.Color = mgl.Vec4{1.0, 0.9, 0.1, 1.0}
+	return mt
+}
+
+// Destroy releases the line Renderable.
+func (mt *MeasureTool) Destroy() {
+	destroyRenderable(mt.line)
+	mt.line = nil
+}
+
+// Reset clears the measured points.
+func (mt *MeasureTool) Reset() {
+	mt.count = 0
+}
+
+// Click places the next point where the ray hits. A click after a finished
+// measurement starts a new one. It returns true if the click finished a measurement.
+func (mt *MeasureTool) Click(ray Ray, h *Hierarchy) bool {
+	p, okay := mt.pickPoint(ray, h)
+	if !okay {
+		return false
+	}
+
+	if mt.count != 1 {
+		mt.points[0] = p
+		mt.points[1] = p
+		mt.count = 1
+		return false
+	}
+
+	mt.points[1] = p
+	mt.count = 2
+	if mt.OnMeasured != nil {
+		mt.OnMeasured(mt.Distance())
+	}
+	return true
+}
+
+// Hover moves the second point to where the ray hits while the first point
+// is placed, so the distance can be shown before clicking.
+func (mt *MeasureTool) Hover(ray Ray, h *Hierarchy) {
+	if mt.count != 1 {
+		return
+	}
+	if p, okay := mt.pickPoint(ray, h); okay {
+		mt.points[1] = p
+	}
+}
+
+// IsMeasuring returns true if at least the first point has been placed.
+func (mt *MeasureTool) IsMeasuring() bool {
+	return mt.count > 0
+}
+
+// GetPoints returns the two measured points. While only the first point is
+// placed, the second follows Hover.
+func (mt *MeasureTool) GetPoints() (mgl.Vec3, mgl.Vec3) {
+	return mt.points[0], mt.points[1]
+}
+
+// Delta returns the vector from the first point to the second.
+func (mt *MeasureTool) Delta() mgl.Vec3 {
+	return mt.points[1].Sub(mt.points[0])
+}
+
+// Distance returns the distance between the points.
+func (mt *MeasureTool) Distance() float32 {
+	return mt.Delta().Len()
+}
+
+// Draw draws the measurement line with a solid color shader that uses the
+// MATERIAL_DIFFUSE uniform, on top of the scene.
+func (mt *MeasureTool) Draw(rend renderer.Renderer, shader *fizzle.RenderShader, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	length := mt.Distance()
+	if mt.count == 0 || length <= 0.0 {
+		return
+	}
+	if mt.line == nil {
+		mt.line = fizzle.CreateLine(0.0, 0.0, 0.0, 1.0, 0.0, 0.0)
+	}
+
+	// the unit line is stretched and turned to span the points
+	mt.line.Location = mt.points[0]
+	mt.line.LocalRotation = mgl.QuatBetweenVectors(mgl.Vec3{1.0, 0.0, 0.0}, mt.Delta().Mul(1.0/length))
+	mt.line.Scale = mgl.Vec3{length, 1.0, 1.0}
+	mt.line.Core.DiffuseColor = mt.Color
+
+	gfx := rend.GetGraphics()
+	gfx.Disable(graphics.DEPTH_TEST)
+	rend.DrawLines(mt.line, shader, nil, perspective, view, camera)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// pickPoint returns where the ray hits the closest Renderable or the ground plane.
+func (mt *MeasureTool) pickPoint(ray Ray, h *Hierarchy) (mgl.Vec3, bool) {
+	var p mgl.Vec3
+	hit := false
+	if h != nil {
+		if r := h.Pick(ray); r != nil {
+			min, max := worldBounds(r)
+			dist, _ := ray.IntersectBox(min, max)
+			p, hit = ray.At(dist), true
+		}
+	}
+	if !hit {
+		dist, okay := ray.IntersectPlane(mgl.Vec3{}, mgl.Vec3{0.0, 1.0, 0.0})
+		if !okay {
+			return p, false
+		}
+		p = ray.At(dist)
+	}
+
+	if mt.Snap != nil {
+		p = mt.Snap.SnapPoint(p)
+	}
+	return p, true
+}
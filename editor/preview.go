@@ -0,0 +1,348 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/component"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/renderer/forward"
+	"github.com/tbogdala/groggy"
+)
+
+// PreviewShape is the stand-in mesh a material is previewed on.
+type PreviewShape int
+
+const (
+	// PreviewSphere previews the material on a sphere.
+	PreviewSphere PreviewShape = iota
+
+	// PreviewCube previews the material on a cube.
+	PreviewCube
+)
+
+const (
+	// previewComponentName is the storage name used for previewed components.
+	previewComponentName = "editor.preview"
+
+	// previewWatchInterval is how often, in seconds, a watched file is checked for changes.
+	previewWatchInterval = 0.25
+)
+
+// Preview renders a material or component into a texture under a three
+// light rig that turns around the subject, for showing in an editor panel.
+// Changes made with SetMaterial, SetMaterialJSON or SetComponentJSON show
+// up on the next Render, and a component file can be watched so that edits
+// saved from a text editor show up too.
+type Preview struct {
+	// ClearColor is the background color of the preview.
+	ClearColor mgl.Vec4
+
+	// TurntableSpeed is how fast the light rig turns in radians per second.
+	TurntableSpeed float32
+
+	// Lights are the key, fill and rim lights of the rig.
+	Lights [3]*forward.Light
+
+	// Components loads the previewed components.
+	Components *component.ComponentManager
+
+	renderer *forward.ForwardRenderer
+	shaders  map[string]*fizzle.RenderShader
+	width    int32
+	height   int32
+	fbo      graphics.Buffer
+	colorTex graphics.Texture
+	depthRB  graphics.Buffer
+
+	subject     *fizzle.Renderable
+	ownsSubject bool
+	material    *component.ComponentMaterial
+	comp        *component.Component
+	angle       float32
+
+	watchPath    string
+	watchModTime time.Time
+	watchTimer   float32
+}
+
+// NewPreview creates a preview of the given size drawn with the forward
+// renderer. The shaders are looked up by the ShaderName of the materials.
+func NewPreview(fr *forward.ForwardRenderer, width, height int32, shaders map[string]*fizzle.RenderShader, cm *component.ComponentManager) (*Preview, error) {
+	p := new(Preview)
+	p.ClearColor = mgl.Vec4{0.15, 0.15, 0.15, 1.0}
+	p.TurntableSpeed = 0.5
+	p.Components = cm
+	p.renderer = fr
+	p.shaders = shaders
+
+	// key, fill and rim lights
+	intensities := [3]float32{0.9, 0.4, 0.6}
+	for i := range p.Lights {
+		l := fr.NewLight()
+		l.DiffuseColor = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
+		l.DiffuseIntensity = intensities[i]
+		l.SpecularIntensity = intensities[i] * 0.5
+		p.Lights[i] = l
+	}
+	p.Lights[0].AmbientIntensity = 0.2
+
+	err := p.Resize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	p.SetShape(PreviewSphere)
+	return p, nil
+}
+
+// Destroy releases the framebuffer and the preview subject.
+func (p *Preview) Destroy() {
+	p.destroyFramebuffer()
+	p.releaseSubject()
+}
+
+// GetTexture returns the texture the preview is rendered to.
+func (p *Preview) GetTexture() graphics.Texture {
+	return p.colorTex
+}
+
+// Resize recreates the framebuffer at the new size.
+func (p *Preview) Resize(width, height int32) error {
+	p.destroyFramebuffer()
+	p.width = width
+	p.height = height
+
+	gfx := p.renderer.GetGraphics()
+	p.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, p.fbo)
+
+	p.colorTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, p.colorTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA8, width, height, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, p.colorTex, 0)
+
+	p.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, p.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH24_STENCIL8, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_STENCIL_ATTACHMENT, graphics.RENDERBUFFER, p.depthRB)
+
+	status := gfx.CheckFramebufferStatus(graphics.FRAMEBUFFER)
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	if status != graphics.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("failed to create the preview framebuffer (status 0x%x)", uint32(status))
+	}
+	return nil
+}
+
+// SetShape previews the current material on one of the stand-in shapes.
+func (p *Preview) SetShape(shape PreviewShape) {
+	var r *fizzle.Renderable
+	switch shape {
+	case PreviewCube:
+		r = fizzle.CreateCube(-0.7, -0.7, -0.7, 0.7, 0.7, 0.7)
+	default:
+		r = fizzle.CreateSphere(1.0, 32, 32)
+	}
+	p.setSubject(r, true)
+}
+
+// SetSubject previews the current material on a custom Renderable. The
+// material is applied to its cores but it is not destroyed by the preview.
+func (p *Preview) SetSubject(r *fizzle.Renderable) {
+	p.setSubject(r, false)
+}
+
+// SetMaterial applies the material to the subject.
+func (p *Preview) SetMaterial(m *component.ComponentMaterial) {
+	p.material = m
+	p.applyMaterial()
+}
+
+// SetMaterialJSON decodes the material JSON, as it appears in a component
+// file, and applies it to the subject.
+func (p *Preview) SetMaterialJSON(jsonBytes []byte) error {
+	m := new(component.ComponentMaterial)
+	err := json.Unmarshal(jsonBytes, m)
+	if err != nil {
+		return fmt.Errorf("failed to decode the material JSON: %v", err)
+	}
+	p.SetMaterial(m)
+	return nil
+}
+
+// SetComponentJSON loads the component JSON and previews it. Relative file
+// paths in the component are resolved against componentDirPath.
+func (p *Preview) SetComponentJSON(jsonBytes []byte, componentDirPath string) error {
+	if p.Components == nil {
+		return fmt.Errorf("no component manager to load the preview component")
+	}
+	comp, err := p.Components.LoadComponentFromBytes(jsonBytes, previewComponentName, componentDirPath)
+	if err != nil {
+		return err
+	}
+
+	// the previous component's cached renderable was only used by the preview
+	if p.comp != nil {
+		p.comp.Destroy()
+	}
+	p.comp = comp
+	p.material = comp.Material
+	p.setSubject(p.Components.GetRenderableInstance(comp), false)
+	return nil
+}
+
+// Watch previews the component file and reloads it whenever it changes on
+// disk. Pass an empty path to stop watching.
+func (p *Preview) Watch(path string) error {
+	p.watchPath = path
+	p.watchModTime = time.Time{}
+	if path == "" {
+		return nil
+	}
+	return p.reloadWatched()
+}
+
+// Update turns the light rig and checks the watched file for changes.
+func (p *Preview) Update(frameDelta float32) {
+	p.angle += p.TurntableSpeed * frameDelta
+	if p.angle > 2.0*math.Pi {
+		p.angle -= 2.0 * math.Pi
+	}
+
+	if p.watchPath == "" {
+		return
+	}
+	p.watchTimer += frameDelta
+	if p.watchTimer < previewWatchInterval {
+		return
+	}
+	p.watchTimer = 0.0
+	info, err := os.Stat(p.watchPath)
+	if err != nil || !info.ModTime().After(p.watchModTime) {
+		return
+	}
+	err = p.reloadWatched()
+	if err != nil {
+		groggy.Logsf("ERROR", "Preview failed to reload %s: %v", p.watchPath, err)
+	}
+}
+
+// Render draws the subject into the preview texture. The renderer's active
+// lights and viewport are restored afterwards.
+func (p *Preview) Render() {
+	if p.subject == nil {
+		return
+	}
+
+	// place the rig around the subject
+	perspective, camera := FrameCamera(p.subject, float32(p.width)/float32(p.height))
+	min, max := worldBounds(p.subject)
+	center := min.Add(max).Mul(0.5)
+	radius := max.Sub(min).Len()
+	offsets := [3]float32{0.0, 2.2, math.Pi}
+	heights := [3]float32{0.8, 0.2, 1.0}
+	for i, l := range p.Lights {
+		a := float64(p.angle + offsets[i])
+		l.Position = center.Add(mgl.Vec3{float32(math.Cos(a)), heights[i], float32(math.Sin(a))}.Mul(radius * 2.0))
+		l.Direction = center.Sub(l.Position).Normalize()
+	}
+
+	fr := p.renderer
+	gfx := fr.GetGraphics()
+	oldLights := fr.ActiveLights
+	fr.ActiveLights = [forward.MaxForwardLights]*forward.Light{}
+	copy(fr.ActiveLights[:], p.Lights[:])
+
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, p.fbo)
+	gfx.Viewport(0, 0, p.width, p.height)
+	gfx.ClearColor(p.ClearColor[0], p.ClearColor[1], p.ClearColor[2], p.ClearColor[3])
+	gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
+	fr.DrawRenderable(p.subject, nil, perspective, camera.GetViewMatrix(), camera)
+
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	width, height := fr.GetResolution()
+	gfx.Viewport(0, 0, width, height)
+	fr.ActiveLights = oldLights
+}
+
+// reloadWatched loads the watched component file.
+func (p *Preview) reloadWatched() error {
+	info, err := os.Stat(p.watchPath)
+	if err != nil {
+		return err
+	}
+	p.watchModTime = info.ModTime()
+
+	jsonBytes, err := ioutil.ReadFile(p.watchPath)
+	if err != nil {
+		return err
+	}
+	dir, _ := filepath.Split(p.watchPath)
+	return p.SetComponentJSON(jsonBytes, dir)
+}
+
+// setSubject replaces the previewed Renderable.
+func (p *Preview) setSubject(r *fizzle.Renderable, owned bool) {
+	p.releaseSubject()
+	p.subject = r
+	p.ownsSubject = owned
+	p.applyMaterial()
+}
+
+// releaseSubject destroys the subject if the preview created it.
+func (p *Preview) releaseSubject() {
+	if p.subject != nil && p.ownsSubject {
+		p.subject.Destroy()
+	}
+	p.subject = nil
+}
+
+// applyMaterial sets the diffuse color and shader of the material on the subject.
+func (p *Preview) applyMaterial() {
+	if p.subject == nil || p.material == nil {
+		return
+	}
+	shader := p.shaders[p.material.ShaderName]
+	p.subject.Map(func(r *fizzle.Renderable) {
+		r.Core.DiffuseColor = p.material.Diffuse
+		if shader != nil {
+			r.Core.Shader = shader
+		}
+	})
+}
+
+// destroyFramebuffer deletes the framebuffer and its attachments.
+func (p *Preview) destroyFramebuffer() {
+	gfx := p.renderer.GetGraphics()
+	if p.fbo != 0 {
+		gfx.DeleteFramebuffer(p.fbo)
+		p.fbo = 0
+	}
+	if p.colorTex != 0 {
+		gfx.DeleteTexture(p.colorTex)
+		p.colorTex = 0
+	}
+	if p.depthRB != 0 {
+		gfx.DeleteRenderbuffer(p.depthRB)
+		p.depthRB = 0
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+var (
+	// SelectionOutlineColor is the outline color of the selected Renderables.
+	SelectionOutlineColor = mgl.Vec4{1.0, 0.6, 0.1, 1.0}
+
+	// PrimaryOutlineColor is the outline color of the primary selection.
+	PrimaryOutlineColor = mgl.Vec4{1.0, 0.9, 0.3, 1.0}
+)
+
+// SelectionCallback is the type of the function called when the selection changes.
+type SelectionCallback func(sel *Selection)
+
+// Selection is the set of selected Renderables shared between the
+// hierarchy panel and the viewport so that selecting in one shows in the other.
+type Selection struct {
+	// OnChanged is called whenever Renderables are selected or deselected.
+	OnChanged SelectionCallback
+
+	items []*fizzle.Renderable
+}
+
+// NewSelection creates a new empty selection.
+func NewSelection() *Selection {
+	sel := new(Selection)
+	sel.items = make([]*fizzle.Renderable, 0, 4)
+	return sel
+}
+
+// Items returns the selected Renderables in the order they were selected.
+func (sel *Selection) Items() []*fizzle.Renderable {
+	return sel.items
+}
+
+// Len returns the number of selected Renderables.
+func (sel *Selection) Len() int {
+	return len(sel.items)
+}
+
+// Primary returns the most recently selected Renderable or nil if nothing
+// is selected. This is the one the gizmo should be placed on.
+func (sel *Selection) Primary() *fizzle.Renderable {
+	if len(sel.items) == 0 {
+		return nil
+	}
+	return sel.items[len(sel.items)-1]
+}
+
+// Contains returns true if the Renderable is selected.
+func (sel *Selection) Contains(r *fizzle.Renderable) bool {
+	return sel.indexOf(r) >= 0
+}
+
+// Set replaces the selection with the Renderables.
+func (sel *Selection) Set(rs ...*fizzle.Renderable) {
+	sel.items = sel.items[:0]
+	for _, r := range rs {
+		if r != nil && sel.indexOf(r) < 0 {
+			sel.items = append(sel.items, r)
+		}
+	}
+	sel.changed()
+}
+
+// Add selects the Renderable in addition to the current selection, making
+// it the primary one.
+func (sel *Selection) Add(r *fizzle.Renderable) {
+	if r == nil {
+		return
+	}
+	if i := sel.indexOf(r); i >= 0 {
+		sel.items = append(sel.items[:i], sel.items[i+1:]...)
+	}
+	sel.items = append(sel.items, r)
+	sel.changed()
+}
+
+// Remove deselects the Renderable.
+func (sel *Selection) Remove(r *fizzle.Renderable) {
+	i := sel.indexOf(r)
+	if i < 0 {
+		return
+	}
+	sel.items = append(sel.items[:i], sel.items[i+1:]...)
+	sel.changed()
+}
+
+// Toggle selects the Renderable if it isn't selected and deselects it otherwise.
+func (sel *Selection) Toggle(r *fizzle.Renderable) {
+	if sel.Contains(r) {
+		sel.Remove(r)
+	} else {
+		sel.Add(r)
+	}
+}
+
+// Clear deselects everything.
+func (sel *Selection) Clear() {
+	if len(sel.items) == 0 {
+		return
+	}
+	sel.items = sel.items[:0]
+	sel.changed()
+}
+
+// DrawOutline highlights the selection with the outline, drawing the
+// primary selection in PrimaryOutlineColor and the rest in
+// SelectionOutlineColor over the default framebuffer.
+func (sel *Selection) DrawOutline(o *renderer.Outline, rend renderer.Renderer, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	if len(sel.items) == 0 {
+		return
+	}
+
+	primary := sel.Primary()
+	o.Begin()
+	for _, r := range sel.items {
+		color := SelectionOutlineColor
+		if r == primary {
+			color = PrimaryOutlineColor
+		}
+		o.AddWithColor(rend, r, color, perspective, view, camera)
+	}
+	o.End()
+	o.Draw(rend)
+}
+
+// indexOf returns the index of the Renderable in the selection or -1.
+func (sel *Selection) indexOf(r *fizzle.Renderable) int {
+	for i, item := range sel.items {
+		if item == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// changed calls the OnChanged callback if one is set.
+func (sel *Selection) changed() {
+	if sel.OnChanged != nil {
+		sel.OnChanged(sel)
+	}
+}
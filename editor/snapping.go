@@ -0,0 +1,69 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// SnapSettings are the snapping increments the user has chosen for the
+// editor. They can be toggled as a whole without losing the increments.
+type SnapSettings struct {
+	// Enabled turns all snapping on or off.
+	Enabled bool
+
+	// Translate is the translation increment in world units.
+	Translate float32
+
+	// RotateDegrees is the rotation increment in degrees.
+	RotateDegrees float32
+
+	// Scale is the scale factor increment.
+	Scale float32
+}
+
+// NewSnapSettings creates enabled snap settings with 0.5 unit, 15 degree
+// and 0.1 scale increments.
+func NewSnapSettings() *SnapSettings {
+	s := new(SnapSettings)
+	s.Enabled = true
+	s.Translate = 0.5
+	s.RotateDegrees = 15.0
+	s.Scale = 0.1
+	return s
+}
+
+// Toggle flips Enabled.
+func (s *SnapSettings) Toggle() {
+	s.Enabled = !s.Enabled
+}
+
+// ApplyToGizmo sets the gizmo snapping increments from the settings, or
+// disables its snapping if the settings are disabled.
+func (s *SnapSettings) ApplyToGizmo(g *Gizmo) {
+	if !s.Enabled {
+		g.TranslateSnap, g.RotateSnap, g.ScaleSnap = 0.0, 0.0, 0.0
+		return
+	}
+	g.TranslateSnap = s.Translate
+	g.RotateSnap = mgl.DegToRad(s.RotateDegrees)
+	g.ScaleSnap = s.Scale
+}
+
+// SnapPoint snaps each component of the point to the translation increment
+// if snapping is enabled.
+func (s *SnapSettings) SnapPoint(p mgl.Vec3) mgl.Vec3 {
+	if !s.Enabled {
+		return p
+	}
+	return mgl.Vec3{Snap(p[0], s.Translate), Snap(p[1], s.Translate), Snap(p[2], s.Translate)}
+}
+
+// SnapAngle snaps the angle in radians to the rotation increment if snapping is enabled.
+func (s *SnapSettings) SnapAngle(radians float32) float32 {
+	if !s.Enabled {
+		return radians
+	}
+	return Snap(radians, mgl.DegToRad(s.RotateDegrees))
+}
@@ -0,0 +1,119 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+const (
+	// DefaultThumbnailSize is the default width and height of thumbnails in pixels.
+	DefaultThumbnailSize = 128
+)
+
+// ThumbnailRenderer draws Renderables into small textures offscreen
+// through a framebuffer object, framing the camera around their bounds.
+type ThumbnailRenderer struct {
+	// Size is the width and height of the thumbnails in pixels.
+	Size int32
+
+	// ClearColor is the background color of the thumbnails.
+	ClearColor mgl.Vec4
+
+	// Binder is passed to DrawRenderable and can be nil.
+	Binder renderer.RenderBinder
+
+	renderer renderer.Renderer
+	fbo      graphics.Buffer
+	depthRB  graphics.Buffer
+}
+
+// NewThumbnailRenderer creates the framebuffer used to draw thumbnails of
+// the given size with the renderer.
+func NewThumbnailRenderer(rend renderer.Renderer, size int32) (*ThumbnailRenderer, error) {
+	tr := new(ThumbnailRenderer)
+	tr.Size = size
+	tr.ClearColor = mgl.Vec4{0.2, 0.2, 0.2, 1.0}
+	tr.renderer = rend
+
+	gfx := rend.GetGraphics()
+	tr.fbo = gfx.GenFramebuffer()
+	tr.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, tr.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH24_STENCIL8, size, size)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, tr.fbo)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_STENCIL_ATTACHMENT, graphics.RENDERBUFFER, tr.depthRB)
+
+	// a safety unbind
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	return tr, nil
+}
+
+// Destroy releases the framebuffer. Thumbnails already rendered are not deleted.
+func (tr *ThumbnailRenderer) Destroy() {
+	gfx := tr.renderer.GetGraphics()
+	gfx.DeleteFramebuffer(tr.fbo)
+	gfx.DeleteRenderbuffer(tr.depthRB)
+}
+
+// Render draws the Renderable into a new texture and returns it. The caller
+// owns the texture and should delete it when it's no longer needed.
+func (tr *ThumbnailRenderer) Render(r *fizzle.Renderable) (graphics.Texture, error) {
+	gfx := tr.renderer.GetGraphics()
+	size := tr.Size
+
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA8, size, size, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, tr.fbo)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, tex, 0)
+	status := gfx.CheckFramebufferStatus(graphics.FRAMEBUFFER)
+	if status != graphics.FRAMEBUFFER_COMPLETE {
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+		gfx.DeleteTexture(tex)
+		return 0, fmt.Errorf("failed to create the thumbnail framebuffer (status 0x%x)", uint32(status))
+	}
+
+	gfx.Viewport(0, 0, size, size)
+	gfx.ClearColor(tr.ClearColor[0], tr.ClearColor[1], tr.ClearColor[2], tr.ClearColor[3])
+	gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
+
+	perspective, camera := FrameCamera(r, 1.0)
+	tr.renderer.DrawRenderable(r, tr.Binder, perspective, camera.GetViewMatrix(), camera)
+
+	// restore the default framebuffer and the renderer's viewport
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	width, height := tr.renderer.GetResolution()
+	gfx.Viewport(0, 0, width, height)
+	return tex, nil
+}
+
+// FrameCamera returns a perspective matrix and a camera looking down at the
+// Renderable from an angle so that its whole bounding rectangle is in view.
+func FrameCamera(r *fizzle.Renderable, aspect float32) (mgl.Mat4, *fizzle.YawPitchCamera) {
+	min, max := worldBounds(r)
+	center := min.Add(max).Mul(0.5)
+	radius := max.Sub(min).Len() * 0.5
+	if radius < 0.001 {
+		radius = 1.0
+	}
+
+	eye := center.Add(mgl.Vec3{1.0, 0.8, 1.0}.Normalize().Mul(radius * 2.5))
+	camera := fizzle.NewYawPitchCamera(eye)
+	camera.LookAtDirect(center)
+	perspective := mgl.Perspective(mgl.DegToRad(45.0), aspect, radius*0.1, radius*10.0)
+	return perspective, camera
+}
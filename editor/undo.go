@@ -0,0 +1,400 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package editor
+
+import (
+	"reflect"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+const (
+	// DefaultUndoDepth is the default number of steps kept on the undo stack.
+	DefaultUndoDepth = 100
+)
+
+// Command is an edit that can be undone and redone.
+type Command interface {
+	// Do applies the edit.
+	Do()
+
+	// Undo reverts the edit.
+	Undo()
+
+	// Name describes the edit for menus, such as "Undo Move".
+	Name() string
+}
+
+// UndoStack records the edits made in the editor. Edits made between
+// BeginGroup and EndGroup, like all of the steps of a gizmo drag, are
+// undone and redone as a single step.
+type UndoStack struct {
+	// MaxDepth is the number of steps kept; the oldest are dropped past it.
+	// Zero means no limit.
+	MaxDepth int
+
+	// OnChanged is called whenever the undo or redo steps change.
+	OnChanged func()
+
+	undo       []Command
+	redo       []Command
+	group      *GroupCommand
+	groupDepth int
+}
+
+// NewUndoStack creates a new empty undo stack keeping maxDepth steps.
+func NewUndoStack(maxDepth int) *UndoStack {
+	us := new(UndoStack)
+	us.MaxDepth = maxDepth
+	us.undo = make([]Command, 0, 16)
+	us.redo = make([]Command, 0, 16)
+	return us
+}
+
+// Execute applies the command and records it.
+func (us *UndoStack) Execute(cmd Command) {
+	cmd.Do()
+	us.Push(cmd)
+}
+
+// Push records a command whose edit has already been applied.
+func (us *UndoStack) Push(cmd Command) {
+	if us.group != nil {
+		us.group.Commands = append(us.group.Commands, cmd)
+		return
+	}
+
+	us.undo = append(us.undo, cmd)
+	if us.MaxDepth > 0 && len(us.undo) > us.MaxDepth {
+		over := len(us.undo) - us.MaxDepth
+		copy(us.undo, us.undo[over:])
+		for i := len(us.undo) - over; i < len(us.undo); i++ {
+			us.undo[i] = nil
+		}
+		us.undo = us.undo[:us.MaxDepth]
+	}
+	us.clearRedo()
+	us.changed()
+}
+
+// BeginGroup starts collecting commands into a single undo step with the
+// name. Groups can nest, in which case the outermost one is recorded.
+func (us *UndoStack) BeginGroup(name string) {
+	us.groupDepth++
+	if us.group == nil {
+		us.group = &GroupCommand{Label: name}
+	}
+}
+
+// EndGroup finishes the group started with BeginGroup and records it if
+// any commands were added to it.
+func (us *UndoStack) EndGroup() {
+	if us.groupDepth == 0 {
+		return
+	}
+	us.groupDepth--
+	if us.groupDepth > 0 {
+		return
+	}
+
+	group := us.group
+	us.group = nil
+	if len(group.Commands) > 0 {
+		us.Push(group)
+	}
+}
+
+// CanUndo returns true if there is a step to undo.
+func (us *UndoStack) CanUndo() bool {
+	return len(us.undo) > 0
+}
+
+// CanRedo returns true if there is a step to redo.
+func (us *UndoStack) CanRedo() bool {
+	return len(us.redo) > 0
+}
+
+// UndoName returns the name of the step Undo would revert or an empty string.
+func (us *UndoStack) UndoName() string {
+	if len(us.undo) == 0 {
+		return ""
+	}
+	return us.undo[len(us.undo)-1].Name()
+}
+
+// RedoName returns the name of the step Redo would apply or an empty string.
+func (us *UndoStack) RedoName() string {
+	if len(us.redo) == 0 {
+		return ""
+	}
+	return us.redo[len(us.redo)-1].Name()
+}
+
+// Undo reverts the last step and returns false if there was nothing to undo.
+func (us *UndoStack) Undo() bool {
+	if len(us.undo) == 0 || us.group != nil {
+		return false
+	}
+	last := len(us.undo) - 1
+	cmd := us.undo[last]
+	us.undo[last] = nil
+	us.undo = us.undo[:last]
+
+	cmd.Undo()
+	us.redo = append(us.redo, cmd)
+	us.changed()
+	return true
+}
+
+// Redo applies the last undone step again and returns false if there was
+// nothing to redo.
+func (us *UndoStack) Redo() bool {
+	if len(us.redo) == 0 || us.group != nil {
+		return false
+	}
+	last := len(us.redo) - 1
+	cmd := us.redo[last]
+	us.redo[last] = nil
+	us.redo = us.redo[:last]
+
+	cmd.Do()
+	us.undo = append(us.undo, cmd)
+	us.changed()
+	return true
+}
+
+// Clear removes all of the steps.
+func (us *UndoStack) Clear() {
+	for i := range us.undo {
+		us.undo[i] = nil
+	}
+	us.undo = us.undo[:0]
+	us.clearRedo()
+	us.group = nil
+	us.groupDepth = 0
+	us.changed()
+}
+
+// AttachGizmo sets the gizmo callbacks so that dragging it transforms the
+// selected Renderables and each drag is recorded as a single undo step.
+func (us *UndoStack) AttachGizmo(g *Gizmo, sel *Selection) {
+	var before map[*fizzle.Renderable]Transform
+	g.OnTransform = func(delta TransformDelta) {
+		if before == nil {
+			before = make(map[*fizzle.Renderable]Transform, sel.Len())
+			for _, r := range sel.Items() {
+				before[r] = GetTransform(r)
+			}
+		}
+		for _, r := range sel.Items() {
+			delta.Apply(r)
+		}
+	}
+	g.OnDragEnd = func(total TransformDelta) {
+		if before == nil {
+			return
+		}
+		us.BeginGroup(gizmoUndoNames[g.Mode])
+		for r, t := range before {
+			us.Push(&TransformCommand{Renderable: r, Before: t, After: GetTransform(r)})
+		}
+		us.EndGroup()
+		before = nil
+	}
+}
+
+var gizmoUndoNames = map[GizmoMode]string{
+	GizmoTranslate: "Move",
+	GizmoRotate:    "Rotate",
+	GizmoScale:     "Scale",
+}
+
+// clearRedo drops the redo steps after a new edit.
+func (us *UndoStack) clearRedo() {
+	for i := range us.redo {
+		us.redo[i] = nil
+	}
+	us.redo = us.redo[:0]
+}
+
+// changed calls the OnChanged callback if one is set.
+func (us *UndoStack) changed() {
+	if us.OnChanged != nil {
+		us.OnChanged()
+	}
+}
+
+// GroupCommand is a list of commands done and undone as one.
+type GroupCommand struct {
+	Label    string
+	Commands []Command
+}
+
+// Do applies the commands in order.
+func (c *GroupCommand) Do() {
+	for _, cmd := range c.Commands {
+		cmd.Do()
+	}
+}
+
+// Undo reverts the commands in reverse order.
+func (c *GroupCommand) Undo() {
+	for i := len(c.Commands) - 1; i >= 0; i-- {
+		c.Commands[i].Undo()
+	}
+}
+
+// Name returns the label of the group.
+func (c *GroupCommand) Name() string {
+	return c.Label
+}
+
+// Transform is the location, rotation and scale of a Renderable.
+type Transform struct {
+	Location mgl.Vec3
+	Rotation mgl.Quat
+	Scale    mgl.Vec3
+}
+
+// GetTransform returns the current transform of the Renderable.
+func GetTransform(r *fizzle.Renderable) Transform {
+	return Transform{Location: r.Location, Rotation: r.Rotation, Scale: r.Scale}
+}
+
+// Apply sets the transform of the Renderable.
+func (t Transform) Apply(r *fizzle.Renderable) {
+	r.Location = t.Location
+	r.Rotation = t.Rotation
+	r.Scale = t.Scale
+}
+
+// TransformCommand changes the transform of a Renderable.
+type TransformCommand struct {
+	Renderable *fizzle.Renderable
+	Before     Transform
+	After      Transform
+}
+
+// Do sets the transform to After.
+func (c *TransformCommand) Do() {
+	c.After.Apply(c.Renderable)
+}
+
+// Undo sets the transform to Before.
+func (c *TransformCommand) Undo() {
+	c.Before.Apply(c.Renderable)
+}
+
+// Name returns "Transform".
+func (c *TransformCommand) Name() string {
+	return "Transform"
+}
+
+// PropertyCommand sets the value pointed at by Target, such as a field of
+// a RenderableCore edited in a property panel.
+type PropertyCommand struct {
+	Label  string
+	target reflect.Value
+	before reflect.Value
+	after  reflect.Value
+}
+
+// NewPropertyCommand creates a command that sets the variable target points
+// to to the value, which must be assignable to it. The current value is kept
+// for undo. It panics if target isn't a pointer.
+func NewPropertyCommand(name string, target interface{}, value interface{}) *PropertyCommand {
+	c := new(PropertyCommand)
+	c.Label = name
+	c.target = reflect.ValueOf(target).Elem()
+	c.before = reflect.New(c.target.Type()).Elem()
+	c.before.Set(c.target)
+	c.after = reflect.ValueOf(value)
+	return c
+}
+
+// Do sets the new value.
+func (c *PropertyCommand) Do() {
+	c.target.Set(c.after)
+}
+
+// Undo restores the old value.
+func (c *PropertyCommand) Undo() {
+	c.target.Set(c.before)
+}
+
+// Name returns the label of the property change.
+func (c *PropertyCommand) Name() string {
+	return c.Label
+}
+
+// AddCommand adds a Renderable to the hierarchy.
+type AddCommand struct {
+	Hierarchy  *Hierarchy
+	Renderable *fizzle.Renderable
+	Parent     *fizzle.Renderable
+	Index      int
+	Label      string
+}
+
+// NewAddCommand creates a command that adds the Renderable with the name
+// to the end of the roots of the hierarchy.
+func NewAddCommand(h *Hierarchy, r *fizzle.Renderable, name string) *AddCommand {
+	return &AddCommand{Hierarchy: h, Renderable: r, Index: -1, Label: name}
+}
+
+// Do adds the Renderable and selects it.
+func (c *AddCommand) Do() {
+	c.Hierarchy.names[c.Renderable] = c.Label
+	c.Hierarchy.Move(c.Renderable, c.Parent, c.Index)
+	c.Hierarchy.Selection.Set(c.Renderable)
+}
+
+// Undo removes the Renderable again.
+func (c *AddCommand) Undo() {
+	c.Parent, c.Index = c.Hierarchy.Delete(c.Renderable)
+}
+
+// Name returns "Add".
+func (c *AddCommand) Name() string {
+	return "Add"
+}
+
+// DeleteCommand removes Renderables from the hierarchy. The Renderables
+// are not destroyed so that they can be put back.
+type DeleteCommand struct {
+	Hierarchy   *Hierarchy
+	Renderables []*fizzle.Renderable
+
+	parents []*fizzle.Renderable
+	indexes []int
+}
+
+// NewDeleteSelectedCommand creates a command that deletes the current selection.
+func NewDeleteSelectedCommand(h *Hierarchy) *DeleteCommand {
+	return &DeleteCommand{Hierarchy: h, Renderables: h.selectedTops()}
+}
+
+// Do deletes the Renderables, remembering where they were.
+func (c *DeleteCommand) Do() {
+	c.parents = make([]*fizzle.Renderable, len(c.Renderables))
+	c.indexes = make([]int, len(c.Renderables))
+	for i, r := range c.Renderables {
+		c.parents[i], c.indexes[i] = c.Hierarchy.Delete(r)
+	}
+}
+
+// Undo puts the Renderables back in reverse order so the indexes are
+// valid again and selects them.
+func (c *DeleteCommand) Undo() {
+	for i := len(c.Renderables) - 1; i >= 0; i-- {
+		c.Hierarchy.Move(c.Renderables[i], c.parents[i], c.indexes[i])
+	}
+	c.Hierarchy.Selection.Set(c.Renderables...)
+}
+
+// Name returns "Delete".
+func (c *DeleteCommand) Name() string {
+	return "Delete"
+}
@@ -0,0 +1,21 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	"github.com/tbogdala/gombz"
+)
+
+// RenderableDestroyed is published on events.Engine when a Renderable's
+// core is destroyed.
+type RenderableDestroyed struct {
+	Renderable *Renderable
+}
+
+// AnimationFinished is published on events.Engine when AdvanceAnimation
+// reaches the end of an animation and loops it.
+type AnimationFinished struct {
+	Renderable *Renderable
+	Animation  *gombz.Animation
+}
@@ -0,0 +1,33 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package events
+
+const (
+	// AssetTexture is the AssetReloaded kind for textures.
+	AssetTexture = "texture"
+
+	// AssetShader is the AssetReloaded kind for shaders.
+	AssetShader = "shader"
+)
+
+// ResolutionChanged is published by the renderers when their resolution changes.
+type ResolutionChanged struct {
+	// Renderer is the renderer that changed resolution.
+	Renderer interface{}
+
+	Width  int32
+	Height int32
+}
+
+// AssetReloaded is published when an asset is reloaded from disk.
+type AssetReloaded struct {
+	// Kind is the type of asset, such as AssetTexture.
+	Kind string
+
+	// Name is the name the asset is stored under, if any.
+	Name string
+
+	// Path is the file the asset was loaded from.
+	Path string
+}
@@ -0,0 +1,138 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The events module is a publish/subscribe event system. Events are plain
+Go values and handlers subscribe to the type of the event they want, so
+new event types can be added by the engine or game code without registering
+them anywhere.
+
+The engine publishes its events, such as ResolutionChanged, to the Engine
+bus, which game code can subscribe to and publish its own events on.
+
+*/
+
+package events
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Handler is the type of the function called for a published event. The
+// event can be type asserted to the type the handler subscribed to.
+type Handler func(event interface{})
+
+// Subscription identifies a subscribed handler so it can be unsubscribed.
+type Subscription uint64
+
+// subscriber is a handler subscribed to an event type.
+type subscriber struct {
+	id      Subscription
+	handler Handler
+}
+
+// Bus dispatches published events to the handlers subscribed to their type.
+//
+// Publish calls the handlers immediately on the calling goroutine. Post can
+// be called from any goroutine to queue an event that gets dispatched on the
+// next call to DispatchPosted, which is normally done once a frame on the
+// main thread.
+type Bus struct {
+	lock        sync.Mutex
+	subscribers map[reflect.Type][]subscriber
+	nextID      Subscription
+	posted      []interface{}
+	dispatching []interface{}
+}
+
+// Engine is the bus the engine publishes its events to.
+var Engine = NewBus()
+
+// NewBus creates a new event bus with no subscribers.
+func NewBus() *Bus {
+	b := new(Bus)
+	b.subscribers = make(map[reflect.Type][]subscriber)
+	return b
+}
+
+// Subscribe adds a handler for all events of the same type as the event
+// passed in, which only serves as an example of the type, such as
+// Subscribe(ResolutionChanged{}, fn). Handlers are called in the order
+// they were subscribed.
+func (b *Bus) Subscribe(event interface{}, handler Handler) Subscription {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.nextID++
+	t := reflect.TypeOf(event)
+	// copy on write so that Publish can call handlers without holding the lock
+	subs := make([]subscriber, len(b.subscribers[t]), len(b.subscribers[t])+1)
+	copy(subs, b.subscribers[t])
+	b.subscribers[t] = append(subs, subscriber{id: b.nextID, handler: handler})
+	return b.nextID
+}
+
+// Unsubscribe removes the handler for the subscription. It is safe to call
+// from within a handler.
+func (b *Bus) Unsubscribe(sub Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for t, subs := range b.subscribers {
+		for i, s := range subs {
+			if s.id != sub {
+				continue
+			}
+			if len(subs) == 1 {
+				delete(b.subscribers, t)
+				return
+			}
+			remaining := make([]subscriber, 0, len(subs)-1)
+			remaining = append(remaining, subs[:i]...)
+			b.subscribers[t] = append(remaining, subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// HasSubscribers returns true if any handler is subscribed to the type of the
+// event. This can be used to skip building expensive events nobody handles.
+func (b *Bus) HasSubscribers(event interface{}) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.subscribers[reflect.TypeOf(event)]) > 0
+}
+
+// Publish calls the handlers subscribed to the event's type.
+func (b *Bus) Publish(event interface{}) {
+	b.lock.Lock()
+	subs := b.subscribers[reflect.TypeOf(event)]
+	b.lock.Unlock()
+
+	for _, s := range subs {
+		s.handler(event)
+	}
+}
+
+// Post queues the event to be published on the next DispatchPosted.
+// It is safe to call from any goroutine.
+func (b *Bus) Post(event interface{}) {
+	b.lock.Lock()
+	b.posted = append(b.posted, event)
+	b.lock.Unlock()
+}
+
+// DispatchPosted publishes the events queued with Post in the order they
+// were posted. Events posted by the handlers are dispatched on the next call.
+func (b *Bus) DispatchPosted() {
+	b.lock.Lock()
+	b.posted, b.dispatching = b.dispatching[:0], b.posted
+	b.lock.Unlock()
+
+	for i, event := range b.dispatching {
+		b.Publish(event)
+		b.dispatching[i] = nil
+	}
+}
@@ -0,0 +1,317 @@
+#version 330
+precision highp float;
+
+uniform vec4 MATERIAL_DIFFUSE;
+uniform vec4 MATERIAL_SPECULAR;
+uniform float MATERIAL_SHININESS;
+
+uniform vec3 LIGHT_POSITION[4];
+uniform vec4 LIGHT_DIFFUSE[4];
+uniform float LIGHT_DIFFUSE_INTENSITY[4];
+uniform float LIGHT_AMBIENT_INTENSITY[4];
+uniform vec3 LIGHT_DIRECTION[4];
+uniform vec2 LIGHT_SPOT_CUTOFF[4];
+uniform vec4 LIGHT_FALLOFF[4];
+uniform vec3 LIGHT_AREA_RIGHT[4];
+uniform vec4 LIGHT_AREA_UP[4];
+uniform sampler2D LIGHT_AREA_TEXTURE[4];
+uniform sampler2D LIGHT_COOKIE[4];
+uniform mat4 LIGHT_COOKIE_MATRIX[4];
+uniform int LIGHT_COOKIE_ENABLED[4];
+uniform sampler2D LIGHT_PROFILE[4];
+uniform int LIGHT_PROFILE_ENABLED[4];
+uniform int LIGHT_COUNT;
+uniform int AMBIENT_HEMISPHERE;
+uniform vec4 AMBIENT_SKY_COLOR;
+uniform vec4 AMBIENT_GROUND_COLOR;
+uniform vec3 AMBIENT_UP;
+uniform sampler2D AMBIENT_OCCLUSION_TEX;
+uniform int AMBIENT_OCCLUSION_ENABLED;
+uniform int OIT_ENABLED;
+uniform int FOG_MODE;
+uniform vec4 FOG_COLOR;
+uniform vec3 FOG_DISTANCE;
+uniform vec3 FOG_HEIGHT;
+
+in vec3 vs_normal_model;
+in vec3 vs_position_model;
+in vec3 camera_eye;
+in vec4 vs_instance_color;
+
+layout(location = 0) out vec4 frag_color;
+
+// the weight of the fragment for weighted blended order independent
+// transparency, written while OIT_ENABLED is set
+layout(location = 1) out vec4 oit_weight;
+
+// CalcAttenuation returns the falloff of a positional light at the distance
+// using the constant, linear and quadratic terms in LIGHT_FALLOFF with a
+// window that smoothly reaches zero at the light's range, if it has one.
+float CalcAttenuation(int i, float dist)
+{
+  vec4 falloff = LIGHT_FALLOFF[i];
+  float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+  if (falloff.w > 0.0) {
+    float ratio = dist / falloff.w;
+    float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+    attenuation *= window * window;
+  }
+  return attenuation;
+}
+
+// AreaLightTexture samples the emitter texture of area light i; sampler
+// arrays can only be indexed with constants.
+vec4 AreaLightTexture(int i, vec2 uv, float lod)
+{
+  if (i == 0) {
+    return textureLod(LIGHT_AREA_TEXTURE[0], uv, lod);
+  } else if (i == 1) {
+    return textureLod(LIGHT_AREA_TEXTURE[1], uv, lod);
+  } else if (i == 2) {
+    return textureLod(LIGHT_AREA_TEXTURE[2], uv, lod);
+  }
+  return textureLod(LIGHT_AREA_TEXTURE[3], uv, lod);
+}
+
+// IntegrateAreaEdge returns the contribution of one edge of a polygonal
+// light to the vector form factor seen from the shaded point.
+vec3 IntegrateAreaEdge(vec3 v1, vec3 v2)
+{
+  float x = dot(v1, v2);
+  float y = abs(x);
+  float a = 0.8543985 + (0.4965155 + 0.0145206 * y) * y;
+  float b = 3.4175940 + (4.1616724 + y) * y;
+  float v = a / b;
+  float theta_sintheta = (x > 0.0) ? v : 0.5 * inversesqrt(max(1.0 - x * x, 1e-7)) - v;
+  return cross(v1, v2) * theta_sintheta;
+}
+
+// CalcAreaLight returns the light of the rectangular area light i. The
+// diffuse light integrates the clamped cosine over the rectangle, which is
+// the diffuse case of linearly transformed cosines, and the specular light
+// uses the point of the rectangle closest to the reflection ray.
+vec4 CalcAreaLight(int i, vec3 v_model, vec3 n_model)
+{
+  vec3 center = LIGHT_POSITION[i];
+  vec3 right = LIGHT_AREA_RIGHT[i];
+  vec3 up = LIGHT_AREA_UP[i].xyz;
+  vec3 normal = normalize(cross(right, up));
+
+  // the light only emits from its front side
+  vec3 d = v_model - center;
+  if (dot(d, normal) <= 0.0) {
+    return vec4(0, 0, 0, 0);
+  }
+
+  vec3 L0 = normalize(center - right - up - v_model);
+  vec3 L1 = normalize(center + right - up - v_model);
+  vec3 L2 = normalize(center + right + up - v_model);
+  vec3 L3 = normalize(center - right + up - v_model);
+  vec3 F = IntegrateAreaEdge(L0, L1) + IntegrateAreaEdge(L1, L2) + IntegrateAreaEdge(L2, L3) + IntegrateAreaEdge(L3, L0);
+  float form_factor = max(-dot(F, n_model), 0.0) / (2.0 * 3.14159265);
+
+  vec4 emit = LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i];
+  vec4 diffuse_emit = emit;
+  if (LIGHT_AREA_UP[i].w > 0.0) {
+    // textured emitters light the surface with a blurred level of the texture
+    vec2 uv = clamp(vec2(dot(d, right) / dot(right, right), dot(d, up) / dot(up, up)), -1.0, 1.0);
+    diffuse_emit *= AreaLightTexture(i, uv * 0.5 + 0.5, 6.0);
+  }
+  vec4 color = diffuse_emit * form_factor;
+
+  if (MATERIAL_SHININESS > 0.0001) {
+    vec3 v = normalize(camera_eye - v_model);
+    vec3 r = reflect(-v, n_model);
+    float r_dot_n = dot(r, normal);
+    if (r_dot_n < -0.0001) {
+      vec3 hit = v_model + r * (dot(center - v_model, normal) / r_dot_n) - center;
+      vec2 st = clamp(vec2(dot(hit, right) / dot(right, right), dot(hit, up) / dot(up, up)), -1.0, 1.0);
+      vec3 L = normalize(center + right * st.x + up * st.y - v_model);
+      vec4 specular_emit = emit;
+      if (LIGHT_AREA_UP[i].w > 0.0) {
+        specular_emit *= AreaLightTexture(i, st * 0.5 + 0.5, 0.0);
+      }
+      color += MATERIAL_SPECULAR * specular_emit * pow(max(dot(r, L), 0.0), MATERIAL_SHININESS);
+    }
+  }
+
+  return color;
+}
+
+// CalcCookie returns the color of light i's cookie texture projected onto
+// the point; sampler arrays can only be indexed with constants.
+vec4 CalcCookie(int i, vec3 v_model)
+{
+  vec4 p = LIGHT_COOKIE_MATRIX[i] * vec4(v_model, 1.0);
+  if (p.w <= 0.0) {
+    return vec4(0, 0, 0, 0);
+  }
+  vec2 uv = p.xy / p.w;
+  if (i == 0) {
+    return texture(LIGHT_COOKIE[0], uv);
+  } else if (i == 1) {
+    return texture(LIGHT_COOKIE[1], uv);
+  } else if (i == 2) {
+    return texture(LIGHT_COOKIE[2], uv);
+  }
+  return texture(LIGHT_COOKIE[3], uv);
+}
+
+// CalcProfile returns the intensity of light i's IES profile in the
+// direction from the light to the point; sampler arrays can only be
+// indexed with constants.
+float CalcProfile(int i, vec3 light_to_point)
+{
+  vec3 axis = LIGHT_DIRECTION[i];
+  if (dot(axis, axis) < 0.0001) {
+    axis = vec3(0.0, -1.0, 0.0);
+  }
+  axis = normalize(axis);
+  vec3 up = abs(axis.y) > 0.99 ? vec3(1.0, 0.0, 0.0) : vec3(0.0, 1.0, 0.0);
+  vec3 u = normalize(up - axis * dot(up, axis));
+  vec3 v = cross(axis, u);
+
+  vec3 d = normalize(light_to_point);
+  float vertical = acos(clamp(dot(d, axis), -1.0, 1.0)) / 3.14159265;
+  float horizontal = atan(dot(d, v), dot(d, u)) / (2.0 * 3.14159265);
+  vec2 uv = vec2(horizontal, vertical);
+  if (i == 0) {
+    return texture(LIGHT_PROFILE[0], uv).r;
+  } else if (i == 1) {
+    return texture(LIGHT_PROFILE[1], uv).r;
+  } else if (i == 2) {
+    return texture(LIGHT_PROFILE[2], uv).r;
+  }
+  return texture(LIGHT_PROFILE[3], uv).r;
+}
+
+// CalcHemisphereAmbient blends from the ground to the sky ambient color by
+// how much the normal faces up.
+vec4 CalcHemisphereAmbient(vec3 n, vec3 up)
+{
+  float skyward = dot(normalize(n), up) * 0.5 + 0.5;
+  return mix(AMBIENT_GROUND_COLOR, AMBIENT_SKY_COLOR, skyward);
+}
+
+vec4 CalcADSLights(vec3 v_model, vec3 n_model)
+{
+  const float Epsilon = 0.0001;
+  vec4 ambient_color = vec4(0, 0, 0, 0);
+  vec4 diffuse_color  = vec4(0, 0, 0, 0);
+  vec4 specular_color = vec4(0, 0, 0, 0);
+
+  vec3 s;
+  for (int i=0; i<LIGHT_COUNT; i++) {
+    // rectangular area lights are integrated over their whole surface
+    if (dot(LIGHT_AREA_RIGHT[i], LIGHT_AREA_RIGHT[i]) > 0.0) {
+      ambient_color += LIGHT_DIFFUSE[i] * LIGHT_AMBIENT_INTENSITY[i];
+      diffuse_color += CalcAreaLight(i, v_model, n_model) * CalcAttenuation(i, length(LIGHT_POSITION[i] - v_model));
+      continue;
+    }
+
+    // spot lights shine from the position and fade out between the
+    // cosines of the inner and outer cone angles
+    float spot = 1.0;
+    float attenuation = 1.0;
+    if (LIGHT_SPOT_CUTOFF[i].y > 0.0) {
+      s = vec3(LIGHT_POSITION[i] - v_model);
+      float cosAngle = dot(normalize(-s), normalize(LIGHT_DIRECTION[i]));
+      spot = smoothstep(LIGHT_SPOT_CUTOFF[i].y, LIGHT_SPOT_CUTOFF[i].x, cosAngle);
+      attenuation = CalcAttenuation(i, length(s));
+    } else if (abs(LIGHT_DIRECTION[i].x) < Epsilon && abs(LIGHT_DIRECTION[i].y) < Epsilon && abs(LIGHT_DIRECTION[i].z) < Epsilon) {
+      // if light direction is not set, calculate it from the position
+      s = vec3(LIGHT_POSITION[i] - v_model);
+      attenuation = CalcAttenuation(i, length(s));
+    } else {
+      // otherwise we just use the direction here
+      s = -LIGHT_DIRECTION[i];
+    }
+
+    // IES profiles shape the light of point and spot lights
+    if (LIGHT_PROFILE_ENABLED[i] != 0) {
+      attenuation *= CalcProfile(i, -s);
+    }
+
+    // cookies mask and tint the light with a projected texture
+    vec4 cookie = vec4(1.0, 1.0, 1.0, 1.0);
+    if (LIGHT_COOKIE_ENABLED[i] != 0) {
+      cookie = CalcCookie(i, v_model);
+    }
+
+    vec3 sN = normalize(s);
+    float sDotN = dot(n_model, sN);
+    float brightness = clamp(sDotN / length(s), 0.0, 1.0);
+
+    ambient_color += LIGHT_DIFFUSE[i] * LIGHT_AMBIENT_INTENSITY[i];
+    diffuse_color += LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i] * brightness * spot * attenuation * cookie;
+
+    if( sDotN > 0.0 && MATERIAL_SHININESS > Epsilon) {
+      vec3 r = reflect(-sN, n_model);
+      vec3 v = normalize(camera_eye - v_model);
+      specular_color += MATERIAL_SPECULAR * pow(max(0.0, dot(v,r)), MATERIAL_SHININESS) * spot * attenuation * cookie;
+    }
+  }
+
+  // the hemisphere ambient replaces the ambient light of the lights
+  if (AMBIENT_HEMISPHERE != 0) {
+    ambient_color = CalcHemisphereAmbient(n_model, AMBIENT_UP);
+  }
+
+  // screen space ambient occlusion darkens the ambient light in creases
+  if (AMBIENT_OCCLUSION_ENABLED != 0) {
+    ambient_color *= texture(AMBIENT_OCCLUSION_TEX, gl_FragCoord.xy / vec2(textureSize(AMBIENT_OCCLUSION_TEX, 0))).r;
+  }
+
+  return (ambient_color + diffuse_color + specular_color);
+}
+
+
+vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+{
+  if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+    return color;
+  }
+
+  vec3 ray = world_pos - eye_pos;
+  float dist = length(ray);
+
+  // visibility is the fraction of the original color that remains
+  float visibility = 1.0;
+  if (FOG_MODE == 1) {
+    visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+  } else if (FOG_MODE == 2) {
+    visibility = exp(-FOG_DISTANCE.z * dist);
+  } else if (FOG_MODE == 3) {
+    float d = FOG_DISTANCE.z * dist;
+    visibility = exp(-d * d);
+  }
+
+  // height fog integrates a density that falls off exponentially above
+  // the base height along the view ray
+  if (FOG_HEIGHT.z > 0.0) {
+    float falloff = max(FOG_HEIGHT.y, 0.0001);
+    float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+    float rise = falloff * ray.y;
+    if (abs(rise) > 0.0001) {
+      amount *= (1.0 - exp(-rise)) / rise;
+    }
+    visibility *= exp(-amount);
+  }
+
+  return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+}
+
+void main()
+{
+  vec4 lit_color = MATERIAL_DIFFUSE * vs_instance_color * CalcADSLights(vs_position_model, vs_normal_model);
+  frag_color = ApplyFog(lit_color, vs_position_model, camera_eye);
+
+  // weighted blended order independent transparency accumulates the color
+  // premultiplied by alpha and weighted to favor the nearer fragments
+  if (OIT_ENABLED != 0) {
+    float alpha = frag_color.a;
+    float weight = clamp(pow(min(1.0, alpha * 10.0) + 0.01, 3.0) * 1e8 * pow(1.0 - gl_FragCoord.z * 0.9, 3.0), 1e-2, 3e3);
+    oit_weight = vec4(alpha * weight);
+    frag_color = vec4(frag_color.rgb * alpha * weight, alpha);
+  }
+}
@@ -0,0 +1,32 @@
+#version 330
+precision highp float;
+
+uniform mat4 VP_MATRIX;
+uniform mat4 M_MATRIX;
+uniform mat4 V_MATRIX;
+uniform int INSTANCE_COLORED;
+in vec3 VERTEX_POSITION;
+in vec3 VERTEX_NORMAL;
+in mat4 INSTANCE_M_MATRIX;
+in vec4 INSTANCE_COLOR;
+
+out vec3 vs_normal_model;
+out vec3 vs_position_model;
+out vec3 camera_eye;
+out vec4 vs_instance_color;
+
+void main()
+{
+  mat4 model = INSTANCE_M_MATRIX * M_MATRIX;
+  mat3 vs_normal_mat = transpose(inverse(mat3(model)));
+  vs_normal_model = normalize(vs_normal_mat * VERTEX_NORMAL);
+  vs_position_model = vec3(model * vec4(VERTEX_POSITION,1.0));
+  vs_instance_color = INSTANCE_COLORED != 0 ? INSTANCE_COLOR : vec4(1.0);
+
+  mat3 camRot = mat3(V_MATRIX);
+  vec3 d = vec3(V_MATRIX[3]);
+  camera_eye = -d * camRot;
+
+
+  gl_Position = VP_MATRIX * vec4(vs_position_model, 1.0);
+}
@@ -7,6 +7,7 @@ uniform mat4 V_MATRIX;
 uniform vec4 MATERIAL_DIFFUSE;
 uniform vec4 MATERIAL_SPECULAR;
 uniform float MATERIAL_SHININESS;
+uniform float MATERIAL_ALPHA_CUTOFF;
 uniform sampler2D MATERIAL_TEX_0;
 uniform sampler2D MATERIAL_TEX_1;
 
@@ -15,16 +16,76 @@ uniform vec4 LIGHT_DIFFUSE[4];
 uniform float LIGHT_DIFFUSE_INTENSITY[4];
 uniform float LIGHT_AMBIENT_INTENSITY[4];
 uniform vec3 LIGHT_DIRECTION[4];
+uniform vec2 LIGHT_SPOT_CUTOFF[4];
 uniform float LIGHT_ATTENUATION[4];
+uniform vec4 LIGHT_FALLOFF[4];
 uniform int LIGHT_COUNT;
+uniform int AMBIENT_HEMISPHERE;
+uniform vec4 AMBIENT_SKY_COLOR;
+uniform vec4 AMBIENT_GROUND_COLOR;
+uniform vec3 AMBIENT_UP;
+uniform sampler2D AMBIENT_OCCLUSION_TEX;
+uniform int AMBIENT_OCCLUSION_ENABLED;
+uniform int OIT_ENABLED;
+uniform int LIGHT_PROBE_ENABLED;
+uniform vec3 LIGHT_PROBE_SH[9];
+uniform int FOG_MODE;
+uniform vec4 FOG_COLOR;
+uniform vec3 FOG_DISTANCE;
+uniform vec3 FOG_HEIGHT;
 
 in vec3 vs_position;
+in vec3 vs_world_position;
 in vec3 vs_normal;
 in vec3 vs_tangent;
 in vec2 vs_tex0_uv;
 in vec3 camera_eye;
 
-out vec4 frag_color;
+layout(location = 0) out vec4 frag_color;
+
+// the weight of the fragment for weighted blended order independent
+// transparency, written while OIT_ENABLED is set
+layout(location = 1) out vec4 oit_weight;
+
+// CalcAttenuation returns the falloff of a positional light at the distance
+// using the constant, linear and quadratic terms in LIGHT_FALLOFF with a
+// window that smoothly reaches zero at the light's range, if it has one.
+float CalcAttenuation(int i, float dist)
+{
+  vec4 falloff = LIGHT_FALLOFF[i];
+  float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+  if (falloff.w > 0.0) {
+    float ratio = dist / falloff.w;
+    float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+    attenuation *= window * window;
+  }
+  return attenuation;
+}
+
+// CalcHemisphereAmbient blends from the ground to the sky ambient color by
+// how much the normal faces up.
+vec4 CalcHemisphereAmbient(vec3 n, vec3 up)
+{
+  float skyward = dot(normalize(n), up) * 0.5 + 0.5;
+  return mix(AMBIENT_GROUND_COLOR, AMBIENT_SKY_COLOR, skyward);
+}
+
+// CalcLightProbe returns the irradiance of the interpolated light probe
+// for the world space normal.
+vec3 CalcLightProbe(vec3 n)
+{
+  const float c1 = 0.429043;
+  const float c2 = 0.511664;
+  const float c3 = 0.743125;
+  const float c4 = 0.886227;
+  const float c5 = 0.247708;
+  vec3 irradiance = c4 * LIGHT_PROBE_SH[0] - c5 * LIGHT_PROBE_SH[6]
+    + 2.0 * c2 * (LIGHT_PROBE_SH[3] * n.x + LIGHT_PROBE_SH[1] * n.y + LIGHT_PROBE_SH[2] * n.z)
+    + c3 * LIGHT_PROBE_SH[6] * n.z * n.z
+    + c1 * LIGHT_PROBE_SH[8] * (n.x * n.x - n.y * n.y)
+    + 2.0 * c1 * (LIGHT_PROBE_SH[4] * n.x * n.y + LIGHT_PROBE_SH[7] * n.x * n.z + LIGHT_PROBE_SH[5] * n.y * n.z);
+  return max(irradiance, vec3(0.0));
+}
 
 vec4 CalcADSLights(vec3 p, vec3 n)
 {
@@ -46,12 +107,24 @@ vec4 CalcADSLights(vec3 p, vec3 n)
     // eye-space light vector
     vec3 L_view;
     float attenuation;
+    float spot = 1.0;
+
+    // spot lights are positional lights that fade out between the cosines
+    // of the inner and outer cone angles
+    if (LIGHT_SPOT_CUTOFF[i].y > 0.0) {
+      vec3 L_pos_view = (V_MATRIX * vec4(LIGHT_POSITION[i], 1.0)).xyz;
+      vec3 L_distance = L_pos_view - P_view.xyz;
+      attenuation = CalcAttenuation(i, length(L_distance));
+      L_view = normalize(L_distance);
+      vec3 D_view = normalize(mat3(V_MATRIX) * LIGHT_DIRECTION[i]);
+      spot = smoothstep(LIGHT_SPOT_CUTOFF[i].y, LIGHT_SPOT_CUTOFF[i].x, dot(-L_view, D_view));
+    }
 
     // if the direction is not set, then assume we have a positional point light.
-    if (abs(LIGHT_DIRECTION[i].x) < Epsilon && abs(LIGHT_DIRECTION[i].y) < Epsilon && abs(LIGHT_DIRECTION[i].z) < Epsilon) {
+    else if (abs(LIGHT_DIRECTION[i].x) < Epsilon && abs(LIGHT_DIRECTION[i].y) < Epsilon && abs(LIGHT_DIRECTION[i].z) < Epsilon) {
       vec3 L_pos_view = (V_MATRIX * vec4(LIGHT_POSITION[i], 1.0)).xyz;
       vec3 L_distance = L_pos_view - P_view.xyz;
-      attenuation = 1.0 / (1.0 +  LIGHT_ATTENUATION[i] * pow(length(L_distance),2));
+      attenuation = CalcAttenuation(i, length(L_distance));
       L_view = normalize(L_distance);
     }
 
@@ -69,18 +142,68 @@ vec4 CalcADSLights(vec3 p, vec3 n)
 
 		float specular_intensity = 0.0;
 		if (light_intensity > 0.0 && MATERIAL_SHININESS > Epsilon) {
-      specular_intensity = attenuation * pow(max(dot(R, V_view), 0.0), MATERIAL_SHININESS);
+      specular_intensity = attenuation * spot * pow(max(dot(R, V_view), 0.0), MATERIAL_SHININESS);
     }
 
     ambient_color += LIGHT_DIFFUSE[i] * LIGHT_AMBIENT_INTENSITY[i];
-    diffuse_color += LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i] * light_intensity * attenuation;
+    diffuse_color += LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i] * light_intensity * attenuation * spot;
     specular_color += MATERIAL_SPECULAR[i] * LIGHT_DIFFUSE_INTENSITY[i] * specular_intensity;
   }
 
+  // the hemisphere ambient replaces the ambient light of the lights
+  if (AMBIENT_HEMISPHERE != 0) {
+    ambient_color = CalcHemisphereAmbient(N_view, normalize(mat3(V_MATRIX) * AMBIENT_UP));
+  }
+
+  // the light probes blended for the object replace both
+  if (LIGHT_PROBE_ENABLED != 0) {
+    ambient_color = vec4(CalcLightProbe(transpose(mat3(V_MATRIX)) * N_view), 1.0);
+  }
+
+  // screen space ambient occlusion darkens the ambient light in creases
+  if (AMBIENT_OCCLUSION_ENABLED != 0) {
+    ambient_color *= texture(AMBIENT_OCCLUSION_TEX, gl_FragCoord.xy / vec2(textureSize(AMBIENT_OCCLUSION_TEX, 0))).r;
+  }
+
   vec4 result = ambient_color + diffuse_color + specular_color;
   return clamp(result, 0.0, 1.0);
 }
 
+vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+{
+  if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+    return color;
+  }
+
+  vec3 ray = world_pos - eye_pos;
+  float dist = length(ray);
+
+  // visibility is the fraction of the original color that remains
+  float visibility = 1.0;
+  if (FOG_MODE == 1) {
+    visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+  } else if (FOG_MODE == 2) {
+    visibility = exp(-FOG_DISTANCE.z * dist);
+  } else if (FOG_MODE == 3) {
+    float d = FOG_DISTANCE.z * dist;
+    visibility = exp(-d * d);
+  }
+
+  // height fog integrates a density that falls off exponentially above
+  // the base height along the view ray
+  if (FOG_HEIGHT.z > 0.0) {
+    float falloff = max(FOG_HEIGHT.y, 0.0001);
+    float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+    float rise = falloff * ray.y;
+    if (abs(rise) > 0.0001) {
+      amount *= (1.0 - exp(-rise)) / rise;
+    }
+    visibility *= exp(-amount);
+  }
+
+  return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+}
+
 void main()
 {
   vec3 T = normalize(vs_tangent - dot(vs_tangent, vs_normal) * vs_normal);
@@ -91,5 +214,18 @@ void main()
 	vec3 final_bumped_normal = normalize(TBN * bump_normal);
 
   vec4 texture_color = texture(MATERIAL_TEX_0, vs_tex0_uv).rgba;
-  frag_color =  MATERIAL_DIFFUSE * texture_color * CalcADSLights(vs_position, final_bumped_normal);
+  if (texture_color.a * MATERIAL_DIFFUSE.a < MATERIAL_ALPHA_CUTOFF) {
+    discard;
+  }
+  vec4 lit_color = MATERIAL_DIFFUSE * texture_color * CalcADSLights(vs_position, final_bumped_normal);
+  frag_color = ApplyFog(lit_color, vs_world_position, camera_eye);
+
+  // weighted blended order independent transparency accumulates the color
+  // premultiplied by alpha and weighted to favor the nearer fragments
+  if (OIT_ENABLED != 0) {
+    float alpha = frag_color.a;
+    float weight = clamp(pow(min(1.0, alpha * 10.0) + 0.01, 3.0) * 1e8 * pow(1.0 - gl_FragCoord.z * 0.9, 3.0), 1e-2, 3e3);
+    oit_weight = vec4(alpha * weight);
+    frag_color = vec4(frag_color.rgb * alpha * weight, alpha);
+  }
 }
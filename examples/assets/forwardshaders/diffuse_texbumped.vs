@@ -11,6 +11,7 @@ in vec3 VERTEX_TANGENT;
 in vec2 VERTEX_UV_0;
 
 out vec3 vs_position;
+out vec3 vs_world_position;
 out vec3 vs_normal;
 out vec3 vs_tangent;
 out vec2 vs_tex0_uv;
@@ -19,6 +20,7 @@ out vec3 camera_eye;
 void main()
 {
   vs_position = VERTEX_POSITION;
+  vs_world_position = vec3(M_MATRIX * vec4(VERTEX_POSITION, 1.0));
 	vs_tangent = normalize(VERTEX_TANGENT);
 	vs_normal = normalize(VERTEX_NORMAL);
   vs_tex0_uv = VERTEX_UV_0;
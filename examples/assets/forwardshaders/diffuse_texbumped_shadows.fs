@@ -7,47 +7,203 @@ uniform mat4 V_MATRIX;
 uniform vec4 MATERIAL_DIFFUSE;
 uniform vec4 MATERIAL_SPECULAR;
 uniform float MATERIAL_SHININESS;
+uniform float MATERIAL_ALPHA_CUTOFF;
 uniform sampler2D MATERIAL_TEX_0;
 uniform sampler2D MATERIAL_TEX_1;
 uniform sampler2DShadow SHADOW_MAPS[4];
+uniform vec4 SHADOW_RECT[4];
+uniform sampler2D SHADOW_FILTERED_MAPS[4];
+uniform int SHADOW_FILTER[4];
+uniform vec2 SHADOW_FILTER_PARAMS[4];
+uniform samplerCubeShadow SHADOW_CUBE_MAPS[4];
+uniform vec2 SHADOW_CUBE_RANGE[4];
 
 uniform vec3 LIGHT_POSITION[4];
 uniform vec4 LIGHT_DIFFUSE[4];
 uniform float LIGHT_DIFFUSE_INTENSITY[4];
 uniform float LIGHT_AMBIENT_INTENSITY[4];
 uniform vec3 LIGHT_DIRECTION[4];
+uniform vec2 LIGHT_SPOT_CUTOFF[4];
 uniform float LIGHT_ATTENUATION[4];
+uniform vec4 LIGHT_FALLOFF[4];
 uniform int LIGHT_COUNT;
+uniform int AMBIENT_HEMISPHERE;
+uniform vec4 AMBIENT_SKY_COLOR;
+uniform vec4 AMBIENT_GROUND_COLOR;
+uniform vec3 AMBIENT_UP;
+uniform sampler2D AMBIENT_OCCLUSION_TEX;
+uniform int AMBIENT_OCCLUSION_ENABLED;
+uniform int OIT_ENABLED;
+uniform int LIGHT_PROBE_ENABLED;
+uniform vec3 LIGHT_PROBE_SH[9];
 uniform int SHADOW_COUNT;
+uniform vec2 SHADOW_FADE;
+uniform int FOG_MODE;
+uniform vec4 FOG_COLOR;
+uniform vec3 FOG_DISTANCE;
+uniform vec3 FOG_HEIGHT;
 
 in vec3 vs_position;
+in vec3 vs_world_position;
 in vec3 vs_normal;
 in vec3 vs_tangent;
 in vec2 vs_tex0_uv;
 in vec3 camera_eye;
 in vec4 vs_shadow_coord[4];
 
-out vec4 frag_color;
+layout(location = 0) out vec4 frag_color;
+
+// the weight of the fragment for weighted blended order independent
+// transparency, written while OIT_ENABLED is set
+layout(location = 1) out vec4 oit_weight;
+
+float CalcCubeShadow(samplerCubeShadow cube_map, vec3 light_position, vec2 range) {
+	if (range.y <= 0.0) {
+		return 1.0;
+	}
+
+	/* the face rendered the fragment with a 90 degree perspective projection so the
+	   fragment's depth comes from its distance along the dominant axis */
+	vec3 L = vs_world_position - light_position;
+	vec3 L_abs = abs(L);
+	float z = max(L_abs.x, max(L_abs.y, L_abs.z));
+	float near = range.x;
+	float far = range.y;
+	float depth = (far + near) / (far - near) - (2.0 * far * near) / ((far - near) * z);
+	depth = depth * 0.5 + 0.5;
+
+	return texture(cube_map, vec4(L, depth - 0.0005));
+}
+
+/* variance shadow maps hold the mean and mean square of the depth around each
+   texel; Chebyshev's inequality bounds the chance that the fragment is lit and
+   params holds the minimum variance and the light bleed reduction */
+float CalcVarianceShadow(sampler2D moments_map, vec4 shadow_coord, vec2 params) {
+	if (shadow_coord.w <= 0.0) {
+		return 1.0;
+	}
+	vec3 coord = shadow_coord.xyz / shadow_coord.w;
+	vec2 moments = texture(moments_map, coord.xy).rg;
+	if (coord.z <= moments.x) {
+		return 1.0;
+	}
+
+	float variance = max(moments.y - moments.x * moments.x, params.x);
+	float d = coord.z - moments.x;
+	float p_max = variance / (variance + d * d);
+	return clamp((p_max - params.y) / (1.0 - params.y), 0.0, 1.0);
+}
+
+/* rect limits the lookup to the shadow map's tile of a shadow atlas; points
+   outside of it are not in shadow */
+/* exponential shadow maps hold exp(c * depth) of the occluders blurred
+   together, which multiplied by exp(-c * depth) of the fragment falls off
+   quickly behind them; params.x holds c */
+float CalcExponentialShadow(sampler2D exp_map, vec4 shadow_coord, vec2 params) {
+	vec3 coord = shadow_coord.xyz / shadow_coord.w;
+	float occluder = texture(exp_map, coord.xy).r;
+	return clamp(occluder * exp(-params.x * coord.z), 0.0, 1.0);
+}
+
+/* filter_mode is 1 for variance and 2 for exponential shadow maps, which are
+   sampled from filtered_map instead of shadow_map */
+float CalcShadowMap(sampler2DShadow shadow_map, sampler2D filtered_map, int filter_mode, vec4 shadow_coord, vec2 params, vec4 rect) {
+	if (shadow_coord.w <= 0.0) {
+		return 1.0;
+	}
+	vec2 uv = shadow_coord.xy / shadow_coord.w;
+	if (any(lessThan(uv, rect.xy)) || any(greaterThan(uv, rect.zw))) {
+		return 1.0;
+	}
+	if (filter_mode == 1) {
+		return CalcVarianceShadow(filtered_map, shadow_coord, params);
+	}
+	if (filter_mode == 2) {
+		return CalcExponentialShadow(filtered_map, shadow_coord, params);
+	}
+	return textureProj(shadow_map, shadow_coord);
+}
 
 vec4 CalcShadowFactor() {
 	float shadow = 1.0;
 	if (SHADOW_COUNT > 0) {
 		shadow = 0.0;
-		shadow += textureProj(SHADOW_MAPS[0], vs_shadow_coord[0]);
+		shadow += CalcShadowMap(SHADOW_MAPS[0], SHADOW_FILTERED_MAPS[0], SHADOW_FILTER[0], vs_shadow_coord[0], SHADOW_FILTER_PARAMS[0], SHADOW_RECT[0]);
 		if (SHADOW_COUNT > 1) {
-			shadow += textureProj(SHADOW_MAPS[1], vs_shadow_coord[1]);
+			shadow += CalcShadowMap(SHADOW_MAPS[1], SHADOW_FILTERED_MAPS[1], SHADOW_FILTER[1], vs_shadow_coord[1], SHADOW_FILTER_PARAMS[1], SHADOW_RECT[1]);
 		}
 		if (SHADOW_COUNT > 2) {
-			shadow += textureProj(SHADOW_MAPS[2], vs_shadow_coord[2]);
+			shadow += CalcShadowMap(SHADOW_MAPS[2], SHADOW_FILTERED_MAPS[2], SHADOW_FILTER[2], vs_shadow_coord[2], SHADOW_FILTER_PARAMS[2], SHADOW_RECT[2]);
 		}
 		if (SHADOW_COUNT > 3) {
-			shadow += textureProj(SHADOW_MAPS[3], vs_shadow_coord[3]);
+			shadow += CalcShadowMap(SHADOW_MAPS[3], SHADOW_FILTERED_MAPS[3], SHADOW_FILTER[3], vs_shadow_coord[3], SHADOW_FILTER_PARAMS[3], SHADOW_RECT[3]);
 		}
 		shadow = shadow / SHADOW_COUNT;
 	}
+
+	/* point lights with a shadow cubemap have a non-zero range */
+	if (LIGHT_COUNT > 0) {
+		shadow *= CalcCubeShadow(SHADOW_CUBE_MAPS[0], LIGHT_POSITION[0], SHADOW_CUBE_RANGE[0]);
+	}
+	if (LIGHT_COUNT > 1) {
+		shadow *= CalcCubeShadow(SHADOW_CUBE_MAPS[1], LIGHT_POSITION[1], SHADOW_CUBE_RANGE[1]);
+	}
+	if (LIGHT_COUNT > 2) {
+		shadow *= CalcCubeShadow(SHADOW_CUBE_MAPS[2], LIGHT_POSITION[2], SHADOW_CUBE_RANGE[2]);
+	}
+	if (LIGHT_COUNT > 3) {
+		shadow *= CalcCubeShadow(SHADOW_CUBE_MAPS[3], LIGHT_POSITION[3], SHADOW_CUBE_RANGE[3]);
+	}
+
+	/* shadows fade out between the distances in SHADOW_FADE from the camera */
+	if (SHADOW_FADE.y > 0.0) {
+		float dist = length(vs_world_position - camera_eye);
+		float fade = smoothstep(SHADOW_FADE.x, SHADOW_FADE.y + 0.0001, dist);
+		shadow = mix(shadow, 1.0, fade);
+	}
 	return vec4(shadow,shadow,shadow,1.0);
 }
 
+// CalcAttenuation returns the falloff of a positional light at the distance
+// using the constant, linear and quadratic terms in LIGHT_FALLOFF with a
+// window that smoothly reaches zero at the light's range, if it has one.
+float CalcAttenuation(int i, float dist)
+{
+  vec4 falloff = LIGHT_FALLOFF[i];
+  float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+  if (falloff.w > 0.0) {
+    float ratio = dist / falloff.w;
+    float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+    attenuation *= window * window;
+  }
+  return attenuation;
+}
+
+// CalcHemisphereAmbient blends from the ground to the sky ambient color by
+// how much the normal faces up.
+vec4 CalcHemisphereAmbient(vec3 n, vec3 up)
+{
+  float skyward = dot(normalize(n), up) * 0.5 + 0.5;
+  return mix(AMBIENT_GROUND_COLOR, AMBIENT_SKY_COLOR, skyward);
+}
+
+// CalcLightProbe returns the irradiance of the interpolated light probe
+// for the world space normal.
+vec3 CalcLightProbe(vec3 n)
+{
+  const float c1 = 0.429043;
+  const float c2 = 0.511664;
+  const float c3 = 0.743125;
+  const float c4 = 0.886227;
+  const float c5 = 0.247708;
+  vec3 irradiance = c4 * LIGHT_PROBE_SH[0] - c5 * LIGHT_PROBE_SH[6]
+    + 2.0 * c2 * (LIGHT_PROBE_SH[3] * n.x + LIGHT_PROBE_SH[1] * n.y + LIGHT_PROBE_SH[2] * n.z)
+    + c3 * LIGHT_PROBE_SH[6] * n.z * n.z
+    + c1 * LIGHT_PROBE_SH[8] * (n.x * n.x - n.y * n.y)
+    + 2.0 * c1 * (LIGHT_PROBE_SH[4] * n.x * n.y + LIGHT_PROBE_SH[7] * n.x * n.z + LIGHT_PROBE_SH[5] * n.y * n.z);
+  return max(irradiance, vec3(0.0));
+}
+
 vec4 CalcADSLights(vec3 p, vec3 n)
 {
   // eye-space
@@ -68,12 +224,24 @@ vec4 CalcADSLights(vec3 p, vec3 n)
     // eye-space light vector
     vec3 L_view;
     float attenuation;
+    float spot = 1.0;
+
+    // spot lights are positional lights that fade out between the cosines
+    // of the inner and outer cone angles
+    if (LIGHT_SPOT_CUTOFF[i].y > 0.0) {
+      vec3 L_pos_view = (V_MATRIX * vec4(LIGHT_POSITION[i], 1.0)).xyz;
+      vec3 L_distance = L_pos_view - P_view.xyz;
+      attenuation = CalcAttenuation(i, length(L_distance));
+      L_view = normalize(L_distance);
+      vec3 D_view = normalize(mat3(V_MATRIX) * LIGHT_DIRECTION[i]);
+      spot = smoothstep(LIGHT_SPOT_CUTOFF[i].y, LIGHT_SPOT_CUTOFF[i].x, dot(-L_view, D_view));
+    }
 
     // if the direction is not set, then assume we have a positional point light.
-    if (abs(LIGHT_DIRECTION[i].x) < Epsilon && abs(LIGHT_DIRECTION[i].y) < Epsilon && abs(LIGHT_DIRECTION[i].z) < Epsilon) {
+    else if (abs(LIGHT_DIRECTION[i].x) < Epsilon && abs(LIGHT_DIRECTION[i].y) < Epsilon && abs(LIGHT_DIRECTION[i].z) < Epsilon) {
       vec3 L_pos_view = (V_MATRIX * vec4(LIGHT_POSITION[i], 1.0)).xyz;
       vec3 L_distance = L_pos_view - P_view.xyz;
-      attenuation = 1.0 / (1.0 +  LIGHT_ATTENUATION[i] * pow(length(L_distance),2));
+      attenuation = CalcAttenuation(i, length(L_distance));
       L_view = normalize(L_distance);
     }
 
@@ -91,18 +259,68 @@ vec4 CalcADSLights(vec3 p, vec3 n)
 
 		float specular_intensity = 0.0;
 		if (light_intensity > 0.0 && MATERIAL_SHININESS > Epsilon) {
-      specular_intensity = attenuation * pow(max(dot(R, V_view), 0.0), MATERIAL_SHININESS);
+      specular_intensity = attenuation * spot * pow(max(dot(R, V_view), 0.0), MATERIAL_SHININESS);
     }
 
     ambient_color += LIGHT_DIFFUSE[i] * LIGHT_AMBIENT_INTENSITY[i];
-    diffuse_color += LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i] * light_intensity * attenuation;
+    diffuse_color += LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i] * light_intensity * attenuation * spot;
     specular_color += MATERIAL_SPECULAR[i] * LIGHT_DIFFUSE_INTENSITY[i] * specular_intensity;
   }
 
+  // the hemisphere ambient replaces the ambient light of the lights
+  if (AMBIENT_HEMISPHERE != 0) {
+    ambient_color = CalcHemisphereAmbient(N_view, normalize(mat3(V_MATRIX) * AMBIENT_UP));
+  }
+
+  // the light probes blended for the object replace both
+  if (LIGHT_PROBE_ENABLED != 0) {
+    ambient_color = vec4(CalcLightProbe(transpose(mat3(V_MATRIX)) * N_view), 1.0);
+  }
+
+  // screen space ambient occlusion darkens the ambient light in creases
+  if (AMBIENT_OCCLUSION_ENABLED != 0) {
+    ambient_color *= texture(AMBIENT_OCCLUSION_TEX, gl_FragCoord.xy / vec2(textureSize(AMBIENT_OCCLUSION_TEX, 0))).r;
+  }
+
   vec4 result = ambient_color + diffuse_color + specular_color;
   return clamp(result, 0.0, 1.0);
 }
 
+vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+{
+  if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+    return color;
+  }
+
+  vec3 ray = world_pos - eye_pos;
+  float dist = length(ray);
+
+  // visibility is the fraction of the original color that remains
+  float visibility = 1.0;
+  if (FOG_MODE == 1) {
+    visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+  } else if (FOG_MODE == 2) {
+    visibility = exp(-FOG_DISTANCE.z * dist);
+  } else if (FOG_MODE == 3) {
+    float d = FOG_DISTANCE.z * dist;
+    visibility = exp(-d * d);
+  }
+
+  // height fog integrates a density that falls off exponentially above
+  // the base height along the view ray
+  if (FOG_HEIGHT.z > 0.0) {
+    float falloff = max(FOG_HEIGHT.y, 0.0001);
+    float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+    float rise = falloff * ray.y;
+    if (abs(rise) > 0.0001) {
+      amount *= (1.0 - exp(-rise)) / rise;
+    }
+    visibility *= exp(-amount);
+  }
+
+  return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+}
+
 void main()
 {
   vec4 shadowFactor = CalcShadowFactor();
@@ -115,5 +333,18 @@ void main()
 	vec3 final_bumped_normal = normalize(TBN * bump_normal);
 
   vec4 texture_color = texture(MATERIAL_TEX_0, vs_tex0_uv).rgba;
-  frag_color =  MATERIAL_DIFFUSE * texture_color * shadowFactor *CalcADSLights(vs_position, final_bumped_normal);
+  if (texture_color.a * MATERIAL_DIFFUSE.a < MATERIAL_ALPHA_CUTOFF) {
+    discard;
+  }
+  vec4 lit_color = MATERIAL_DIFFUSE * texture_color * shadowFactor *CalcADSLights(vs_position, final_bumped_normal);
+  frag_color = ApplyFog(lit_color, vs_world_position, camera_eye);
+
+  // weighted blended order independent transparency accumulates the color
+  // premultiplied by alpha and weighted to favor the nearer fragments
+  if (OIT_ENABLED != 0) {
+    float alpha = frag_color.a;
+    float weight = clamp(pow(min(1.0, alpha * 10.0) + 0.01, 3.0) * 1e8 * pow(1.0 - gl_FragCoord.z * 0.9, 3.0), 1e-2, 3e3);
+    oit_weight = vec4(alpha * weight);
+    frag_color = vec4(frag_color.rgb * alpha * weight, alpha);
+  }
 }
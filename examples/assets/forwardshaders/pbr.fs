@@ -0,0 +1,334 @@
+#version 330
+precision highp float;
+
+uniform mat4 MV_MATRIX;
+uniform mat4 V_MATRIX;
+
+uniform vec4 MATERIAL_DIFFUSE;
+uniform float MATERIAL_ALPHA_CUTOFF;
+uniform float MATERIAL_METALLIC;
+uniform float MATERIAL_ROUGHNESS;
+uniform vec3 MATERIAL_EMISSIVE;
+uniform int MATERIAL_PBR_MAPS;
+uniform sampler2D MATERIAL_TEX_0;
+uniform sampler2D MATERIAL_TEX_1;
+uniform sampler2D MATERIAL_METAL_ROUGH_TEX;
+uniform sampler2D MATERIAL_OCCLUSION_TEX;
+uniform sampler2D MATERIAL_EMISSIVE_TEX;
+
+uniform vec3 LIGHT_POSITION[4];
+uniform vec4 LIGHT_DIFFUSE[4];
+uniform float LIGHT_DIFFUSE_INTENSITY[4];
+uniform float LIGHT_AMBIENT_INTENSITY[4];
+uniform vec3 LIGHT_DIRECTION[4];
+uniform vec2 LIGHT_SPOT_CUTOFF[4];
+uniform float LIGHT_ATTENUATION[4];
+uniform vec4 LIGHT_FALLOFF[4];
+uniform int LIGHT_COUNT;
+uniform int AMBIENT_HEMISPHERE;
+uniform vec4 AMBIENT_SKY_COLOR;
+uniform vec4 AMBIENT_GROUND_COLOR;
+uniform vec3 AMBIENT_UP;
+uniform sampler2D AMBIENT_OCCLUSION_TEX;
+uniform int AMBIENT_OCCLUSION_ENABLED;
+uniform int OIT_ENABLED;
+uniform int LIGHT_PROBE_ENABLED;
+uniform int IBL_ENABLED;
+uniform samplerCube IBL_IRRADIANCE_TEX;
+uniform samplerCube IBL_PREFILTERED_TEX;
+uniform sampler2D IBL_BRDF_LUT;
+uniform float IBL_MAX_LOD;
+uniform float IBL_INTENSITY;
+uniform vec3 LIGHT_PROBE_SH[9];
+uniform int FOG_MODE;
+uniform vec4 FOG_COLOR;
+uniform vec3 FOG_DISTANCE;
+uniform vec3 FOG_HEIGHT;
+
+in vec3 vs_position;
+in vec3 vs_world_position;
+in vec3 vs_normal;
+in vec3 vs_tangent;
+in vec2 vs_tex0_uv;
+in vec3 camera_eye;
+
+layout(location = 0) out vec4 frag_color;
+
+// the weight of the fragment for weighted blended order independent
+// transparency, written while OIT_ENABLED is set
+layout(location = 1) out vec4 oit_weight;
+
+// the bits of MATERIAL_PBR_MAPS for the maps the material has
+const int PBR_ALBEDO_MAP = 1;
+const int PBR_NORMAL_MAP = 2;
+const int PBR_METAL_ROUGH_MAP = 4;
+const int PBR_OCCLUSION_MAP = 8;
+const int PBR_EMISSIVE_MAP = 16;
+
+const float PI = 3.14159265359;
+
+// CalcAttenuation returns the falloff of a positional light at the distance
+// using the constant, linear and quadratic terms in LIGHT_FALLOFF with a
+// window that smoothly reaches zero at the light's range, if it has one.
+float CalcAttenuation(int i, float dist)
+{
+  vec4 falloff = LIGHT_FALLOFF[i];
+  float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+  if (falloff.w > 0.0) {
+    float ratio = dist / falloff.w;
+    float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+    attenuation *= window * window;
+  }
+  return attenuation;
+}
+
+// CalcHemisphereAmbient blends from the ground to the sky ambient color by
+// how much the normal faces up.
+vec4 CalcHemisphereAmbient(vec3 n, vec3 up)
+{
+  float skyward = dot(normalize(n), up) * 0.5 + 0.5;
+  return mix(AMBIENT_GROUND_COLOR, AMBIENT_SKY_COLOR, skyward);
+}
+
+// CalcLightProbe returns the irradiance of the interpolated light probe
+// for the world space normal.
+vec3 CalcLightProbe(vec3 n)
+{
+  const float c1 = 0.429043;
+  const float c2 = 0.511664;
+  const float c3 = 0.743125;
+  const float c4 = 0.886227;
+  const float c5 = 0.247708;
+  vec3 irradiance = c4 * LIGHT_PROBE_SH[0] - c5 * LIGHT_PROBE_SH[6]
+    + 2.0 * c2 * (LIGHT_PROBE_SH[3] * n.x + LIGHT_PROBE_SH[1] * n.y + LIGHT_PROBE_SH[2] * n.z)
+    + c3 * LIGHT_PROBE_SH[6] * n.z * n.z
+    + c1 * LIGHT_PROBE_SH[8] * (n.x * n.x - n.y * n.y)
+    + 2.0 * c1 * (LIGHT_PROBE_SH[4] * n.x * n.y + LIGHT_PROBE_SH[7] * n.x * n.z + LIGHT_PROBE_SH[5] * n.y * n.z);
+  return max(irradiance, vec3(0.0));
+}
+
+// DistributionGGX is the Trowbridge-Reitz normal distribution: the share
+// of microfacets aligned with the half vector.
+float DistributionGGX(float NdotH, float roughness)
+{
+  float a = roughness * roughness;
+  float a2 = a * a;
+  float d = NdotH * NdotH * (a2 - 1.0) + 1.0;
+  return a2 / max(PI * d * d, 0.0000001);
+}
+
+// GeometrySmith is the Smith shadowing and masking of the microfacets with
+// the Schlick-GGX approximation for direct lights.
+float GeometrySmith(float NdotV, float NdotL, float roughness)
+{
+  float r = roughness + 1.0;
+  float k = (r * r) / 8.0;
+  float gv = NdotV / (NdotV * (1.0 - k) + k);
+  float gl = NdotL / (NdotL * (1.0 - k) + k);
+  return gv * gl;
+}
+
+// FresnelSchlick is the reflectance at the angle between the view and the
+// half vector, starting from F0 when looking straight on.
+vec3 FresnelSchlick(float cosTheta, vec3 F0)
+{
+  return F0 + (1.0 - F0) * pow(clamp(1.0 - cosTheta, 0.0, 1.0), 5.0);
+}
+
+// FresnelSchlickRoughness is FresnelSchlick for the ambient light, which
+// arrives from every direction, so rough surfaces reflect less of it at
+// grazing angles.
+vec3 FresnelSchlickRoughness(float cosTheta, vec3 F0, float roughness)
+{
+  return F0 + (max(vec3(1.0 - roughness), F0) - F0) * pow(clamp(1.0 - cosTheta, 0.0, 1.0), 5.0);
+}
+
+// CalcPBRLights shades the surface with the Cook-Torrance BRDF for every
+// light and adds the ambient light, scaled by the ambient occlusion.
+vec3 CalcPBRLights(vec3 p, vec3 n, vec3 albedo, float metallic, float roughness, float occlusion)
+{
+  // eye-space
+  vec4 P_view = MV_MATRIX * vec4(p, 1.0);
+  vec3 V_view = normalize(-P_view.xyz);
+  vec3 N_view = normalize(mat3(MV_MATRIX) * n);
+  float NdotV = max(dot(N_view, V_view), 0.0001);
+
+  // dielectrics reflect about 4% straight on while metals tint the
+  // reflection with their albedo and have no diffuse light
+  vec3 F0 = mix(vec3(0.04), albedo, metallic);
+
+  vec3 ambient_color = vec3(0.0);
+  vec3 direct_color = vec3(0.0);
+  for (int i=0; i<LIGHT_COUNT; i++) {
+    const float Epsilon = 0.0001;
+
+    // eye-space light vector
+    vec3 L_view;
+    float attenuation;
+    float spot = 1.0;
+
+    // spot lights are positional lights that fade out between the cosines
+    // of the inner and outer cone angles
+    if (LIGHT_SPOT_CUTOFF[i].y > 0.0) {
+      vec3 L_pos_view = (V_MATRIX * vec4(LIGHT_POSITION[i], 1.0)).xyz;
+      vec3 L_distance = L_pos_view - P_view.xyz;
+      attenuation = CalcAttenuation(i, length(L_distance));
+      L_view = normalize(L_distance);
+      vec3 D_view = normalize(mat3(V_MATRIX) * LIGHT_DIRECTION[i]);
+      spot = smoothstep(LIGHT_SPOT_CUTOFF[i].y, LIGHT_SPOT_CUTOFF[i].x, dot(-L_view, D_view));
+    }
+
+    // if the direction is not set, then assume we have a positional point light.
+    else if (abs(LIGHT_DIRECTION[i].x) < Epsilon && abs(LIGHT_DIRECTION[i].y) < Epsilon && abs(LIGHT_DIRECTION[i].z) < Epsilon) {
+      vec3 L_pos_view = (V_MATRIX * vec4(LIGHT_POSITION[i], 1.0)).xyz;
+      vec3 L_distance = L_pos_view - P_view.xyz;
+      attenuation = CalcAttenuation(i, length(L_distance));
+      L_view = normalize(L_distance);
+    }
+
+    // this is the directional light branch where attenuation is a little simpler
+    else {
+      attenuation = 1.0 / (1.0 +  LIGHT_ATTENUATION[i]);
+      L_view = normalize(-(mat3(V_MATRIX) * LIGHT_DIRECTION[i]));
+    }
+
+    ambient_color += LIGHT_DIFFUSE[i].rgb * LIGHT_AMBIENT_INTENSITY[i];
+
+    float NdotL = max(dot(N_view, L_view), 0.0);
+    if (NdotL <= 0.0) {
+      continue;
+    }
+
+    vec3 H_view = normalize(V_view + L_view);
+    float NdotH = max(dot(N_view, H_view), 0.0);
+    vec3 F = FresnelSchlick(max(dot(H_view, V_view), 0.0), F0);
+    float D = DistributionGGX(NdotH, roughness);
+    float G = GeometrySmith(NdotV, NdotL, roughness);
+    vec3 specular = (D * G * F) / (4.0 * NdotV * NdotL + 0.0001);
+
+    // the light that isn't reflected is refracted and diffused, except by metals
+    vec3 kD = (vec3(1.0) - F) * (1.0 - metallic);
+    vec3 radiance = LIGHT_DIFFUSE[i].rgb * LIGHT_DIFFUSE_INTENSITY[i] * attenuation * spot;
+    direct_color += (kD * albedo / PI + specular) * radiance * NdotL;
+  }
+
+  // the hemisphere ambient replaces the ambient light of the lights
+  if (AMBIENT_HEMISPHERE != 0) {
+    ambient_color = CalcHemisphereAmbient(N_view, normalize(mat3(V_MATRIX) * AMBIENT_UP)).rgb;
+  }
+
+  // the light probes blended for the object replace both
+  if (LIGHT_PROBE_ENABLED != 0) {
+    ambient_color = CalcLightProbe(transpose(mat3(V_MATRIX)) * N_view);
+  }
+
+  // screen space ambient occlusion darkens the ambient light in creases
+  if (AMBIENT_OCCLUSION_ENABLED != 0) {
+    occlusion *= texture(AMBIENT_OCCLUSION_TEX, gl_FragCoord.xy / vec2(textureSize(AMBIENT_OCCLUSION_TEX, 0))).r;
+  }
+
+  vec3 kS = FresnelSchlickRoughness(NdotV, F0, roughness);
+  vec3 kD = (vec3(1.0) - kS) * (1.0 - metallic);
+  vec3 ambient;
+
+  // image based lighting replaces the ambient light with the irradiance
+  // and prefiltered reflections of the environment, looked up in world space
+  if (IBL_ENABLED != 0) {
+    mat3 view_to_world = transpose(mat3(V_MATRIX));
+    vec3 N_world = view_to_world * N_view;
+    vec3 R_world = view_to_world * reflect(-V_view, N_view);
+    vec3 irradiance = texture(IBL_IRRADIANCE_TEX, N_world).rgb;
+    vec3 prefiltered = textureLod(IBL_PREFILTERED_TEX, R_world, roughness * IBL_MAX_LOD).rgb;
+    vec2 brdf = texture(IBL_BRDF_LUT, vec2(NdotV, roughness)).rg;
+    ambient = (kD * irradiance * albedo + prefiltered * (kS * brdf.x + brdf.y)) * IBL_INTENSITY;
+  } else {
+    ambient = ambient_color * (kD * albedo + kS * albedo * metallic);
+  }
+  return ambient * occlusion + direct_color;
+}
+
+vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+{
+  if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+    return color;
+  }
+
+  vec3 ray = world_pos - eye_pos;
+  float dist = length(ray);
+
+  // visibility is the fraction of the original color that remains
+  float visibility = 1.0;
+  if (FOG_MODE == 1) {
+    visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+  } else if (FOG_MODE == 2) {
+    visibility = exp(-FOG_DISTANCE.z * dist);
+  } else if (FOG_MODE == 3) {
+    float d = FOG_DISTANCE.z * dist;
+    visibility = exp(-d * d);
+  }
+
+  // height fog integrates a density that falls off exponentially above
+  // the base height along the view ray
+  if (FOG_HEIGHT.z > 0.0) {
+    float falloff = max(FOG_HEIGHT.y, 0.0001);
+    float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+    float rise = falloff * ray.y;
+    if (abs(rise) > 0.0001) {
+      amount *= (1.0 - exp(-rise)) / rise;
+    }
+    visibility *= exp(-amount);
+  }
+
+  return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+}
+
+void main()
+{
+  vec4 albedo = MATERIAL_DIFFUSE;
+  if ((MATERIAL_PBR_MAPS & PBR_ALBEDO_MAP) != 0) {
+    albedo *= texture(MATERIAL_TEX_0, vs_tex0_uv);
+  }
+  if (albedo.a < MATERIAL_ALPHA_CUTOFF) {
+    discard;
+  }
+
+  vec3 normal = normalize(vs_normal);
+  if ((MATERIAL_PBR_MAPS & PBR_NORMAL_MAP) != 0) {
+    vec3 T = normalize(vs_tangent - dot(vs_tangent, normal) * normal);
+    vec3 BT = cross(T, normal);
+    vec3 bump_normal = 2.0 * texture(MATERIAL_TEX_1, vs_tex0_uv).rgb - vec3(1.0, 1.0, 1.0);
+    normal = normalize(mat3(T, BT, normal) * bump_normal);
+  }
+
+  float metallic = MATERIAL_METALLIC;
+  float roughness = MATERIAL_ROUGHNESS;
+  if ((MATERIAL_PBR_MAPS & PBR_METAL_ROUGH_MAP) != 0) {
+    vec4 metal_rough = texture(MATERIAL_METAL_ROUGH_TEX, vs_tex0_uv);
+    roughness *= metal_rough.g;
+    metallic *= metal_rough.b;
+  }
+  roughness = clamp(roughness, 0.04, 1.0);
+  metallic = clamp(metallic, 0.0, 1.0);
+
+  float occlusion = 1.0;
+  if ((MATERIAL_PBR_MAPS & PBR_OCCLUSION_MAP) != 0) {
+    occlusion = texture(MATERIAL_OCCLUSION_TEX, vs_tex0_uv).r;
+  }
+
+  vec3 emissive = MATERIAL_EMISSIVE;
+  if ((MATERIAL_PBR_MAPS & PBR_EMISSIVE_MAP) != 0) {
+    emissive *= texture(MATERIAL_EMISSIVE_TEX, vs_tex0_uv).rgb;
+  }
+
+  vec3 lit_color = CalcPBRLights(vs_position, normal, albedo.rgb, metallic, roughness, occlusion) + emissive;
+  frag_color = ApplyFog(vec4(lit_color, albedo.a), vs_world_position, camera_eye);
+
+  // weighted blended order independent transparency accumulates the color
+  // premultiplied by alpha and weighted to favor the nearer fragments
+  if (OIT_ENABLED != 0) {
+    float alpha = frag_color.a;
+    float weight = clamp(pow(min(1.0, alpha * 10.0) + 0.01, 3.0) * 1e8 * pow(1.0 - gl_FragCoord.z * 0.9, 3.0), 1e-2, 3e3);
+    oit_weight = vec4(alpha * weight);
+    frag_color = vec4(frag_color.rgb * alpha * weight, alpha);
+  }
+}
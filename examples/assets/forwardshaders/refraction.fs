@@ -0,0 +1,80 @@
+#version 330
+precision highp float;
+
+uniform mat4 V_MATRIX;
+
+uniform vec4 MATERIAL_DIFFUSE;
+uniform vec4 MATERIAL_SPECULAR;
+
+/* the scene drawn before this object, copied by the renderer's grab pass */
+uniform sampler2D SCENE_COLOR_TEX;
+
+uniform int FOG_MODE;
+uniform vec4 FOG_COLOR;
+uniform vec3 FOG_DISTANCE;
+uniform vec3 FOG_HEIGHT;
+
+in vec3 vs_normal_model;
+in vec3 vs_position_model;
+in vec3 camera_eye;
+
+out vec4 frag_color;
+
+/* how far, in screen space, the normal bends the view of the scene */
+const float RefractionStrength = 0.05;
+
+vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+{
+  if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+    return color;
+  }
+
+  vec3 ray = world_pos - eye_pos;
+  float dist = length(ray);
+
+  // visibility is the fraction of the original color that remains
+  float visibility = 1.0;
+  if (FOG_MODE == 1) {
+    visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+  } else if (FOG_MODE == 2) {
+    visibility = exp(-FOG_DISTANCE.z * dist);
+  } else if (FOG_MODE == 3) {
+    float d = FOG_DISTANCE.z * dist;
+    visibility = exp(-d * d);
+  }
+
+  // height fog integrates a density that falls off exponentially above
+  // the base height along the view ray
+  if (FOG_HEIGHT.z > 0.0) {
+    float falloff = max(FOG_HEIGHT.y, 0.0001);
+    float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+    float rise = falloff * ray.y;
+    if (abs(rise) > 0.0001) {
+      amount *= (1.0 - exp(-rise)) / rise;
+    }
+    visibility *= exp(-amount);
+  }
+
+  return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+}
+
+void main()
+{
+  vec3 n = normalize(vs_normal_model);
+  vec3 v = normalize(camera_eye - vs_position_model);
+
+  // offset the screen lookup by the view space normal to fake the bending
+  // of light through the surface
+  vec2 scene_size = vec2(textureSize(SCENE_COLOR_TEX, 0));
+  vec2 screen_uv = gl_FragCoord.xy / scene_size;
+  vec3 n_view = mat3(V_MATRIX) * n;
+  vec2 refracted_uv = clamp(screen_uv - n_view.xy * RefractionStrength, vec2(0.0), vec2(1.0));
+  vec3 scene_color = texture(SCENE_COLOR_TEX, refracted_uv).rgb;
+
+  // tint by the material and add a rim reflection that grows at glancing angles
+  float fresnel = pow(1.0 - max(dot(n, v), 0.0), 5.0);
+  vec3 color = mix(scene_color, scene_color * MATERIAL_DIFFUSE.rgb, MATERIAL_DIFFUSE.a);
+  color += MATERIAL_SPECULAR.rgb * fresnel;
+
+  frag_color = ApplyFog(vec4(color, 1.0), vs_position_model, camera_eye);
+}
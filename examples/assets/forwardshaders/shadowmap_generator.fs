@@ -1,8 +1,21 @@
 #version 330
 precision highp float;
 
+uniform float SHADOW_EXPONENTIAL_CONSTANT;
+
 out vec4 frag_color;
 
 void main (void) {
-  frag_color = vec4(gl_FragCoord.z);
+  /* exponential shadow maps store the depth raised by a constant and
+     variance shadow maps store the depth and the squared depth, widened by
+     the depth's slope across the pixel to reduce acne; depth only shadow
+     maps have no color buffer and ignore it */
+  float depth = gl_FragCoord.z;
+  if (SHADOW_EXPONENTIAL_CONSTANT > 0.0) {
+    frag_color = vec4(exp(SHADOW_EXPONENTIAL_CONSTANT * depth), 0.0, 0.0, 1.0);
+    return;
+  }
+  float dx = dFdx(depth);
+  float dy = dFdy(depth);
+  frag_color = vec4(depth, depth * depth + 0.25 * (dx * dx + dy * dy), 0.0, 1.0);
 }
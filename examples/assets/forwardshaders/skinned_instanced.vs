@@ -0,0 +1,55 @@
+#version 330
+precision highp float;
+
+uniform mat4 VP_MATRIX;
+uniform mat4 M_MATRIX;
+uniform mat4 V_MATRIX;
+uniform sampler2D BONE_TEXTURE;
+in vec3 VERTEX_POSITION;
+in vec3 VERTEX_NORMAL;
+in vec4 VERTEX_BONE_IDS;
+in vec4 VERTEX_BONE_WEIGHTS;
+in mat4 INSTANCE_M_MATRIX;
+in float INSTANCE_ANIM_FRAME;
+
+out vec3 vs_normal_model;
+out vec3 vs_position_model;
+out vec3 camera_eye;
+
+// each row of the bone texture is a frame and each bone is four texels wide
+mat4 fetchBone(int bone, int frame)
+{
+  return mat4(
+    texelFetch(BONE_TEXTURE, ivec2(bone*4, frame), 0),
+    texelFetch(BONE_TEXTURE, ivec2(bone*4+1, frame), 0),
+    texelFetch(BONE_TEXTURE, ivec2(bone*4+2, frame), 0),
+    texelFetch(BONE_TEXTURE, ivec2(bone*4+3, frame), 0));
+}
+
+mat4 boneMatrix(int bone)
+{
+  int frame0 = int(floor(INSTANCE_ANIM_FRAME));
+  int frame1 = min(frame0 + 1, textureSize(BONE_TEXTURE, 0).y - 1);
+  float blend = fract(INSTANCE_ANIM_FRAME);
+  return fetchBone(bone, frame0) * (1.0 - blend) + fetchBone(bone, frame1) * blend;
+}
+
+void main()
+{
+  mat4 skin = boneMatrix(int(VERTEX_BONE_IDS.x)) * VERTEX_BONE_WEIGHTS.x;
+  skin += boneMatrix(int(VERTEX_BONE_IDS.y)) * VERTEX_BONE_WEIGHTS.y;
+  skin += boneMatrix(int(VERTEX_BONE_IDS.z)) * VERTEX_BONE_WEIGHTS.z;
+  skin += boneMatrix(int(VERTEX_BONE_IDS.w)) * VERTEX_BONE_WEIGHTS.w;
+
+  mat4 model = INSTANCE_M_MATRIX * M_MATRIX * skin;
+  mat3 vs_normal_mat = transpose(inverse(mat3(model)));
+  vs_normal_model = normalize(vs_normal_mat * VERTEX_NORMAL);
+  vs_position_model = vec3(model * vec4(VERTEX_POSITION,1.0));
+
+  mat3 camRot = mat3(V_MATRIX);
+  vec3 d = vec3(V_MATRIX[3]);
+  camera_eye = -d * camRot;
+
+
+  gl_Position = VP_MATRIX * vec4(vs_position_model, 1.0);
+}
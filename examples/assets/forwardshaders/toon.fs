@@ -11,8 +11,18 @@ uniform float LIGHT_DIFFUSE_INTENSITY[4];
 uniform float LIGHT_SPECULAR_INTENSITY[4];
 uniform float LIGHT_AMBIENT_INTENSITY[4];
 uniform vec3 LIGHT_DIRECTION[4];
+uniform vec2 LIGHT_SPOT_CUTOFF[4];
+uniform vec4 LIGHT_FALLOFF[4];
 uniform int LIGHT_COUNT;
+uniform int AMBIENT_HEMISPHERE;
+uniform vec4 AMBIENT_SKY_COLOR;
+uniform vec4 AMBIENT_GROUND_COLOR;
+uniform vec3 AMBIENT_UP;
 uniform vec3 CAMERA_WORLD_POSITION;
+uniform int FOG_MODE;
+uniform vec4 FOG_COLOR;
+uniform vec3 FOG_DISTANCE;
+uniform vec3 FOG_HEIGHT;
 
 in vec3 vs_vert_color;
 in vec4 w_position;
@@ -64,6 +74,29 @@ float step(float edge, float x) {
     return x < edge ? 0.0 : 1.0;
 }
 
+// CalcAttenuation returns the falloff of a positional light at the distance
+// using the constant, linear and quadratic terms in LIGHT_FALLOFF with a
+// window that smoothly reaches zero at the light's range, if it has one.
+float CalcAttenuation(int i, float dist)
+{
+  vec4 falloff = LIGHT_FALLOFF[i];
+  float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+  if (falloff.w > 0.0) {
+    float ratio = dist / falloff.w;
+    float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+    attenuation *= window * window;
+  }
+  return attenuation;
+}
+
+// CalcHemisphereAmbient blends from the ground to the sky ambient color by
+// how much the normal faces up.
+vec4 CalcHemisphereAmbient(vec3 n, vec3 up)
+{
+  float skyward = dot(normalize(n), up) * 0.5 + 0.5;
+  return mix(AMBIENT_GROUND_COLOR, AMBIENT_SKY_COLOR, skyward);
+}
+
 vec4 Toon(int light_i)
 {
     // apply gamma correction
@@ -76,16 +109,24 @@ vec4 Toon(int light_i)
 
     // calculate the direction towards the light in world space
     vec3 L;
-    // if light direction is not set, calculate it from the position
-    if (abs(LIGHT_DIRECTION[light_i].x) < Epsilon && abs(LIGHT_DIRECTION[light_i].y) < Epsilon && abs(LIGHT_DIRECTION[light_i].z) < Epsilon) {
+    float light_falloff = 1.0;
+    if (LIGHT_SPOT_CUTOFF[light_i].y > 0.0) {
+      // spot lights fade out between the inner and outer cone angles
+      L = normalize(LIGHT_POSITION[light_i] - w_position.xyz);
+      float cosAngle = dot(-L, normalize(LIGHT_DIRECTION[light_i]));
+      light_falloff = smoothstep(LIGHT_SPOT_CUTOFF[light_i].y, LIGHT_SPOT_CUTOFF[light_i].x, cosAngle);
+      light_falloff *= CalcAttenuation(light_i, length(LIGHT_POSITION[light_i] - w_position.xyz));
+    } else if (abs(LIGHT_DIRECTION[light_i].x) < Epsilon && abs(LIGHT_DIRECTION[light_i].y) < Epsilon && abs(LIGHT_DIRECTION[light_i].z) < Epsilon) {
+      // if light direction is not set, calculate it from the position
       L = normalize(LIGHT_POSITION[light_i] - w_position.xyz);
+      light_falloff = CalcAttenuation(light_i, length(LIGHT_POSITION[light_i] - w_position.xyz));
     } else {
       // otherwise we just use the direction here
       L = normalize(-LIGHT_DIRECTION[light_i]);
     }
 
     // get the diffuse intensity
-    float Id = max(0.0, dot(N, L));
+    float Id = max(0.0, dot(N, L)) * light_falloff;
 
     // TOON the diffuse value
     float diffFactor = step4(Id);
@@ -109,17 +150,60 @@ vec4 Toon(int light_i)
     // calculate the color based on the diffuse intensity and copy
     // over the alpha setting of the base color.
     vec3 final_ambient = LIGHT_AMBIENT_INTENSITY[light_i] * l_base_color * l_light_color;
+    if (AMBIENT_HEMISPHERE != 0) {
+      // the hemisphere ambient is added once for all of the lights in main
+      final_ambient = vec3(0.0);
+    }
     vec3 final_diffuse = LIGHT_DIFFUSE_INTENSITY[light_i] * l_base_color * l_light_color * diffFactor;
     vec3 final_specular = LIGHT_SPECULAR_INTENSITY[light_i] * l_specular_color * l_light_color * specFactor;
 
     return toGamma(vec4(final_ambient + final_diffuse + final_specular, MATERIAL_DIFFUSE.a));
 }
 
+vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+{
+  if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+    return color;
+  }
+
+  vec3 ray = world_pos - eye_pos;
+  float dist = length(ray);
+
+  // visibility is the fraction of the original color that remains
+  float visibility = 1.0;
+  if (FOG_MODE == 1) {
+    visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+  } else if (FOG_MODE == 2) {
+    visibility = exp(-FOG_DISTANCE.z * dist);
+  } else if (FOG_MODE == 3) {
+    float d = FOG_DISTANCE.z * dist;
+    visibility = exp(-d * d);
+  }
+
+  // height fog integrates a density that falls off exponentially above
+  // the base height along the view ray
+  if (FOG_HEIGHT.z > 0.0) {
+    float falloff = max(FOG_HEIGHT.y, 0.0001);
+    float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+    float rise = falloff * ray.y;
+    if (abs(rise) > 0.0001) {
+      amount *= (1.0 - exp(-rise)) / rise;
+    }
+    visibility *= exp(-amount);
+  }
+
+  return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+}
+
 void main()
 {
   vec4 final_color;
   for (int i=0; i<LIGHT_COUNT; i++) {
     final_color += Toon(i);
   }
-  frag_color = final_color;
+  if (AMBIENT_HEMISPHERE != 0) {
+    vec3 l_ambient = toLinear(CalcHemisphereAmbient(w_normal, AMBIENT_UP).rgb) * toLinear(MATERIAL_DIFFUSE.rgb);
+    final_color += toGamma(vec4(l_ambient, 0.0));
+  }
+  frag_color = ApplyFog(final_color, w_position.xyz, CAMERA_WORLD_POSITION);
 }
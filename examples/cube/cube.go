@@ -8,7 +8,6 @@ import (
 	"math"
 	"os"
 	"runtime"
-	"time"
 
 	glfw "github.com/go-gl/glfw/v3.1/glfw"
 	mgl "github.com/go-gl/mathgl/mgl32"
@@ -17,7 +16,9 @@ import (
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
 	opengl "github.com/tbogdala/fizzle/graphicsprovider/opengl"
 	input "github.com/tbogdala/fizzle/input/glfwinput"
+	fizzlerenderer "github.com/tbogdala/fizzle/renderer"
 	forward "github.com/tbogdala/fizzle/renderer/forward"
+	"github.com/tbogdala/fizzle/window/glfwwindow"
 )
 
 /*
@@ -76,11 +77,15 @@ func main() {
 	kbModel.BindTrigger(glfw.KeySpace, toggleModel)
 	kbModel.SetupCallbacks()
 
-	// create a new renderer
+	// create a new renderer that presents to the main window and
+	// follows its size changes
 	renderer = forward.NewForwardRenderer(gfx)
-	renderer.ChangeResolution(windowWidth, windowHeight)
+	renderer.SetWindow(glfwwindow.New(mainWindow))
 	defer renderer.Destroy()
 
+	// disable v-sync for max draw rate
+	renderer.SetSwapInterval(fizzlerenderer.SwapIntervalOff)
+
 	// put a light in there
 	light := renderer.NewLight()
 	//light.Position = mgl.Vec3{-10.0, 5.0, 10}
@@ -124,11 +129,11 @@ func main() {
 	gfx.Enable(graphics.DEPTH_TEST)
 
 	// loop until something told the mainWindow that it should close
-	lastFrame := time.Now()
+	clock := fizzle.NewClock()
 	for !mainWindow.ShouldClose() {
-		// calculate the difference in time to control rotation speed
-		thisFrame := time.Now()
-		frameDelta := float32(thisFrame.Sub(lastFrame).Seconds())
+		// advance the clock to get the difference in time to control rotation speed
+		clock.Tick()
+		frameDelta := float32(clock.Delta())
 
 		// handle any keyboard input
 		kbModel.CheckKeyPresses()
@@ -156,13 +161,10 @@ func main() {
 		}
 
 		// draw the screen
-		mainWindow.SwapBuffers()
+		renderer.EndRenderFrame()
 
 		// advise GLFW to poll for input. without this the window appears to hang.
 		glfw.PollEvents()
-
-		// update our last frame time
-		lastFrame = thisFrame
 	}
 }
 
@@ -187,12 +189,8 @@ func initGraphics(title string, w int, h int) (*glfw.Window, graphics.GraphicsPr
 	if err != nil {
 		panic("Failed to create the main window! " + err.Error())
 	}
-	mainWindow.SetSizeCallback(onWindowResize)
 	mainWindow.MakeContextCurrent()
 
-	// disable v-sync for max draw rate
-	glfw.SwapInterval(0)
-
 	// initialize OpenGL
 	gfx, err := opengl.InitOpenGL()
 	if err != nil {
@@ -208,11 +206,6 @@ func setShouldClose() {
 	mainWindow.SetShouldClose(true)
 }
 
-// onWindowResize is called when the window changes size
-func onWindowResize(w *glfw.Window, width int, height int) {
-	renderer.ChangeResolution(int32(width), int32(height))
-}
-
 // toggleModel sets whether or not the cube or the sphere should be rendered.
 func toggleModel() {
 	// spacebar toggles the drawing of the cube or the sphere
@@ -8,7 +8,6 @@ import (
 	"math"
 	"os"
 	"runtime"
-	"time"
 
 	glfw "github.com/go-gl/glfw/v3.1/glfw"
 	mgl "github.com/go-gl/mathgl/mgl32"
@@ -139,7 +138,11 @@ func main() {
 	testCube.Core.Shader = diffuseTexBumpedShader
 
 	// enable shadow mapping in the renderer
-	renderer.SetupShadowMapRendering()
+	err = renderer.SetupShadowMapRendering()
+	if err != nil {
+		fmt.Printf("Failed to setup shadow map rendering.\n%v", err)
+		os.Exit(1)
+	}
 
 	// add light #1
 	light := renderer.NewLight()
@@ -149,7 +152,11 @@ func main() {
 	light.AmbientIntensity = 0.20
 	light.Attenuation = 0.2
 	renderer.ActiveLights[0] = light
-	light.CreateShadowMap(shadowTexSize, 0.5, 50.0, mgl.Vec3{-5.0, -3.0, -5.0})
+	err = light.CreateShadowMap(shadowTexSize, 0.5, 50.0, mgl.Vec3{-5.0, -3.0, -5.0})
+	if err != nil {
+		fmt.Printf("Failed to create the shadow map.\n%v", err)
+		os.Exit(1)
+	}
 
 	// add light #2
 	light2 := renderer.NewLight()
@@ -159,7 +166,11 @@ func main() {
 	light2.AmbientIntensity = 0.00
 	light2.Attenuation = 0.2
 	renderer.ActiveLights[1] = light2
-	light2.CreateShadowMap(shadowTexSize, 0.5, 50.0, mgl.Vec3{2.0, -3.0, -3.0})
+	err = light2.CreateShadowMap(shadowTexSize, 0.5, 50.0, mgl.Vec3{2.0, -3.0, -3.0})
+	if err != nil {
+		fmt.Printf("Failed to create the shadow map.\n%v", err)
+		os.Exit(1)
+	}
 
 	// make a UI image to show the shadowmap texture, scaled down
 	shadowMapUIQuad := fizzle.CreatePlaneXY(0, 0, 256, 256)
@@ -173,11 +184,11 @@ func main() {
 	gfx.Enable(graphics.BLEND)
 
 	// loop until something told the mainWindow that it should close
-	lastFrame := time.Now()
+	clock := fizzle.NewClock()
 	for !mainWindow.ShouldClose() {
-		// calculate the difference in time to control rotation speed
-		thisFrame := time.Now()
-		frameDelta := float32(thisFrame.Sub(lastFrame).Seconds())
+		// advance the clock to get the difference in time to control rotation speed
+		clock.Tick()
+		frameDelta := float32(clock.Delta())
 
 		// rotate the cube around the Y axis at a speed of 0.5*math.Pi / sec
 		rotDelta := mgl.QuatRotate(0.5*math.Pi*frameDelta, mgl.Vec3{0.0, 1.0, 0.0})
@@ -195,7 +206,11 @@ func main() {
 				}
 
 				// enable the light to cast shadows
-				renderer.EnableShadowMappingLight(lightToCast)
+				err = renderer.EnableShadowMappingLight(lightToCast)
+				if err != nil {
+					fmt.Printf("Failed to render the shadow map!\n%v", err)
+					continue
+				}
 				renderer.DrawRenderableWithShader(testCube, shadowmapShader, nil, lightToCast.ShadowMap.Projection, lightToCast.ShadowMap.View, camera)
 				renderer.DrawRenderableWithShader(floorPlane, shadowmapShader, nil, lightToCast.ShadowMap.Projection, lightToCast.ShadowMap.View, camera)
 
@@ -225,9 +240,6 @@ func main() {
 		// draw the screen
 		mainWindow.SwapBuffers()
 		glfw.PollEvents()
-
-		// update our last frame time
-		lastFrame = thisFrame
 	}
 }
 
@@ -10,6 +10,9 @@ Fizzle is a library to make rendering graphics via OpenGL easier.
 package fizzle
 
 import (
+	"fmt"
+	"strings"
+
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
 	"github.com/tbogdala/groggy"
 )
@@ -46,21 +49,49 @@ func DebugCheckForError(msg string) {
 	err := gfx.GetError()
 	for err != graphics.NO_ERROR {
 		if len(msg) > 0 {
-			var errTypeStr string
-			switch err {
-			case graphics.INVALID_ENUM:
-				errTypeStr = "INVALID_ENUM"
-			case graphics.INVALID_VALUE:
-				errTypeStr = "INVALID_VALUE"
-			case graphics.INVALID_OPERATION:
-				errTypeStr = "INVALID_OPERATION"
-			case graphics.OUT_OF_MEMORY:
-				errTypeStr = "OUT_OF_MEMORY"
-			default:
-				errTypeStr = "Undefined Error"
-			}
-			groggy.Logsf("DEBUG", "OpenGL error %d(0x%x) detected (%s): %s", int(err), int(err), msg, errTypeStr)
+			groggy.Logsf("DEBUG", "OpenGL error %d(0x%x) detected (%s): %s", int(err), int(err), msg, GLErrorString(err))
 		}
 		err = gfx.GetError()
 	}
 }
+
+// CheckForError drains the OpenGL error flags and returns an error naming
+// the operation and every error code raised, or nil if there were none.
+func CheckForError(operation string) error {
+	return CheckGraphicsError(gfx, operation)
+}
+
+// CheckGraphicsError works like CheckForError for a specific graphics provider.
+func CheckGraphicsError(g graphics.GraphicsProvider, operation string) error {
+	var codes []string
+	for err := g.GetError(); err != graphics.NO_ERROR; err = g.GetError() {
+		codes = append(codes, GLErrorString(err))
+
+		// a lost context can report errors forever
+		if len(codes) >= 8 {
+			break
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return fmt.Errorf("OpenGL error while %s: %s", operation, strings.Join(codes, ", "))
+}
+
+// GLErrorString returns the name of the OpenGL error code.
+func GLErrorString(err uint32) string {
+	switch err {
+	case graphics.INVALID_ENUM:
+		return "INVALID_ENUM"
+	case graphics.INVALID_VALUE:
+		return "INVALID_VALUE"
+	case graphics.INVALID_OPERATION:
+		return "INVALID_OPERATION"
+	case graphics.INVALID_FRAMEBUFFER_OPERATION:
+		return "INVALID_FRAMEBUFFER_OPERATION"
+	case graphics.OUT_OF_MEMORY:
+		return "OUT_OF_MEMORY"
+	default:
+		return fmt.Sprintf("Undefined Error 0x%x", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// RetainGeometry controls whether or not the Renderable creation functions
+// keep a CPU side copy of the vertex data in RenderableCore.Geometry.
+// This is off by default to save memory, but tools that need to rebuild
+// buffers from existing Renderables (e.g. the StaticBatcher) need it
+// turned on before the source Renderables are created.
+var RetainGeometry = false
+
+// Geometry is a CPU side copy of the triangle data uploaded to the
+// VBOs of a RenderableCore. All of the vertex attributes are stored
+// tightly packed in their own slices.
+type Geometry struct {
+	// Vertices are the vertex positions as x,y,z triplets.
+	Vertices []float32
+
+	// Normals are the vertex normals as x,y,z triplets; may be empty.
+	Normals []float32
+
+	// UVs are the texture coordinates as s,t pairs; may be empty.
+	UVs []float32
+
+	// Tangents are the vertex tangents as x,y,z triplets; may be empty.
+	Tangents []float32
+
+	// LightmapUVs are the second set of texture coordinates, used to sample
+	// baked lightmaps, as s,t pairs; may be empty.
+	LightmapUVs []float32
+
+	// Indexes are the vertex indexes for each triangle face.
+	Indexes []uint32
+}
+
+// VertexCount returns the number of vertices in the geometry.
+func (g *Geometry) VertexCount() int {
+	return len(g.Vertices) / 3
+}
+
+// newGeometryFromInterleaved builds a Geometry object out of an interleaved
+// vertex buffer. The stride and offsets are measured in floats and an offset
+// of -1 means that attribute is not present in the buffer.
+func newGeometryFromInterleaved(buffer []float32, stride, normOffset, uvOffset, tangentOffset int, indexes []uint32) *Geometry {
+	g := new(Geometry)
+	vertCount := len(buffer) / stride
+	g.Vertices = make([]float32, 0, vertCount*3)
+	if normOffset >= 0 {
+		g.Normals = make([]float32, 0, vertCount*3)
+	}
+	if uvOffset >= 0 {
+		g.UVs = make([]float32, 0, vertCount*2)
+	}
+	if tangentOffset >= 0 {
+		g.Tangents = make([]float32, 0, vertCount*3)
+	}
+
+	for i := 0; i < vertCount; i++ {
+		v := buffer[i*stride : i*stride+stride]
+		g.Vertices = append(g.Vertices, v[0], v[1], v[2])
+		if normOffset >= 0 {
+			g.Normals = append(g.Normals, v[normOffset], v[normOffset+1], v[normOffset+2])
+		}
+		if uvOffset >= 0 {
+			g.UVs = append(g.UVs, v[uvOffset], v[uvOffset+1])
+		}
+		if tangentOffset >= 0 {
+			g.Tangents = append(g.Tangents, v[tangentOffset], v[tangentOffset+1], v[tangentOffset+2])
+		}
+	}
+
+	g.Indexes = make([]uint32, len(indexes))
+	copy(g.Indexes, indexes)
+	return g
+}
+
+// retainInterleavedGeometry stores a copy of the interleaved vertex buffer
+// in the core if RetainGeometry is enabled.
+func (rc *RenderableCore) retainInterleavedGeometry(buffer []float32, stride, normOffset, uvOffset, tangentOffset int, indexes []uint32) {
+	if !RetainGeometry {
+		return
+	}
+	rc.Geometry = newGeometryFromInterleaved(buffer, stride, normOffset, uvOffset, tangentOffset, indexes)
+}
+
+// CreateFromGeometry creates a new Renderable by uploading the Geometry into
+// a single interleaved VBO (vertex / normal / uv / tangent) and an element VBO.
+// Attributes missing from the Geometry are filled in with zeros. If the
+// Geometry has LightmapUVs they are interleaved after the tangents.
+func CreateFromGeometry(g *Geometry) *Renderable {
+	const floatSize = 4
+	const uintSize = 4
+
+	vertCount := g.VertexCount()
+	hasNormals := len(g.Normals) >= vertCount*3
+	hasUVs := len(g.UVs) >= vertCount*2
+	hasTangents := len(g.Tangents) >= vertCount*3
+	hasLightmapUVs := len(g.LightmapUVs) >= vertCount*2
+
+	stride := 3 + 3 + 2 + 3
+	if hasLightmapUVs {
+		stride += 2
+	}
+
+	vnutBuffer := make([]float32, 0, vertCount*stride)
+	for i := 0; i < vertCount; i++ {
+		vnutBuffer = append(vnutBuffer, g.Vertices[i*3], g.Vertices[i*3+1], g.Vertices[i*3+2])
+		if hasNormals {
+			vnutBuffer = append(vnutBuffer, g.Normals[i*3], g.Normals[i*3+1], g.Normals[i*3+2])
+		} else {
+			vnutBuffer = append(vnutBuffer, 0.0, 0.0, 0.0)
+		}
+		if hasUVs {
+			vnutBuffer = append(vnutBuffer, g.UVs[i*2], g.UVs[i*2+1])
+		} else {
+			vnutBuffer = append(vnutBuffer, 0.0, 0.0)
+		}
+		if hasTangents {
+			vnutBuffer = append(vnutBuffer, g.Tangents[i*3], g.Tangents[i*3+1], g.Tangents[i*3+2])
+		} else {
+			vnutBuffer = append(vnutBuffer, 0.0, 0.0, 0.0)
+		}
+		if hasLightmapUVs {
+			vnutBuffer = append(vnutBuffer, g.LightmapUVs[i*2], g.LightmapUVs[i*2+1])
+		}
+	}
+
+	r := NewRenderable()
+	r.FaceCount = uint32(len(g.Indexes) / 3)
+	r.BoundingRect = GetBoundingRect(g.Vertices)
+	if RetainGeometry {
+		r.Core.Geometry = g
+	}
+
+	// create a VBO to hold the vertex data
+	r.Core.VertVBO = gfx.GenBuffer()
+	r.Core.UvVBO = r.Core.VertVBO
+	r.Core.NormsVBO = r.Core.VertVBO
+	r.Core.TangentsVBO = r.Core.VertVBO
+	r.Core.VertVBOOffset = 0
+	r.Core.NormsVBOOffset = floatSize * 3
+	r.Core.UvVBOOffset = floatSize * 6
+	r.Core.TangentsVBOOffset = floatSize * 8
+	r.Core.VBOStride = int32(floatSize * stride) // vert / normal / uv / tangent / lightmap uv
+	if hasLightmapUVs {
+		r.Core.Uv1VBO = r.Core.VertVBO
+		r.Core.Uv1VBOOffset = floatSize * 11
+	}
+	if len(vnutBuffer) > 0 {
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.VertVBO)
+		gfx.BufferData(graphics.ARRAY_BUFFER, floatSize*len(vnutBuffer), gfx.Ptr(&vnutBuffer[0]), graphics.STATIC_DRAW)
+	}
+
+	// create a VBO to hold the face indexes
+	r.Core.ElementsVBO = gfx.GenBuffer()
+	if len(g.Indexes) > 0 {
+		gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
+		gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(g.Indexes), gfx.Ptr(&g.Indexes[0]), graphics.STATIC_DRAW)
+	}
+
+	gfx.BindBuffer(graphics.ARRAY_BUFFER, 0)
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, 0)
+
+	return r
+}
+
+// Transform returns a new Geometry with the vertices transformed by the matrix
+// and the normals and tangents rotated accordingly.
+func (g *Geometry) Transform(m mgl.Mat4) *Geometry {
+	result := new(Geometry)
+	result.Vertices = make([]float32, len(g.Vertices))
+	for i := 0; i+2 < len(g.Vertices); i += 3 {
+		v := m.Mul4x1(mgl.Vec4{g.Vertices[i], g.Vertices[i+1], g.Vertices[i+2], 1.0})
+		result.Vertices[i], result.Vertices[i+1], result.Vertices[i+2] = v[0], v[1], v[2]
+	}
+
+	// normals need the inverse transpose so that non-uniform scales don't skew them
+	normalMat := m.Mat3().Inv().Transpose()
+	if len(g.Normals) > 0 {
+		result.Normals = make([]float32, len(g.Normals))
+		for i := 0; i+2 < len(g.Normals); i += 3 {
+			n := normalMat.Mul3x1(mgl.Vec3{g.Normals[i], g.Normals[i+1], g.Normals[i+2]})
+			if n.Len() > 0.0 {
+				n = n.Normalize()
+			}
+			result.Normals[i], result.Normals[i+1], result.Normals[i+2] = n[0], n[1], n[2]
+		}
+	}
+
+	rotMat := m.Mat3()
+	if len(g.Tangents) > 0 {
+		result.Tangents = make([]float32, len(g.Tangents))
+		for i := 0; i+2 < len(g.Tangents); i += 3 {
+			t := rotMat.Mul3x1(mgl.Vec3{g.Tangents[i], g.Tangents[i+1], g.Tangents[i+2]})
+			if t.Len() > 0.0 {
+				t = t.Normalize()
+			}
+			result.Tangents[i], result.Tangents[i+1], result.Tangents[i+2] = t[0], t[1], t[2]
+		}
+	}
+
+	result.UVs = make([]float32, len(g.UVs))
+	copy(result.UVs, g.UVs)
+	if len(g.LightmapUVs) > 0 {
+		result.LightmapUVs = make([]float32, len(g.LightmapUVs))
+		copy(result.LightmapUVs, g.LightmapUVs)
+	}
+	result.Indexes = make([]uint32, len(g.Indexes))
+	copy(result.Indexes, g.Indexes)
+	return result
+}
+
+// Append adds the other Geometry to this one, offsetting the indexes.
+// Attributes that are missing from one side are padded with zeros so
+// that the attribute slices stay in step with the vertices.
+func (g *Geometry) Append(other *Geometry) {
+	baseVert := uint32(g.VertexCount())
+	otherCount := other.VertexCount()
+
+	padAppend := func(dst []float32, src []float32, comps int) []float32 {
+		if len(dst) == 0 && len(src) == 0 {
+			return dst
+		}
+		if len(dst) < int(baseVert)*comps {
+			dst = append(dst, make([]float32, int(baseVert)*comps-len(dst))...)
+		}
+		if len(src) >= otherCount*comps {
+			return append(dst, src[:otherCount*comps]...)
+		}
+		return append(dst, make([]float32, otherCount*comps)...)
+	}
+
+	g.Normals = padAppend(g.Normals, other.Normals, 3)
+	g.UVs = padAppend(g.UVs, other.UVs, 2)
+	g.Tangents = padAppend(g.Tangents, other.Tangents, 3)
+	g.LightmapUVs = padAppend(g.LightmapUVs, other.LightmapUVs, 2)
+	g.Vertices = append(g.Vertices, other.Vertices...)
+
+	for _, idx := range other.Indexes {
+		g.Indexes = append(g.Indexes, idx+baseVert)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package golden
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// maxYIQDelta is the largest possible squared YIQ distance between two colors.
+	maxYIQDelta = 35215.0
+)
+
+// Tolerance controls how different two images can be and still match.
+type Tolerance struct {
+	// Threshold is the perceptual difference, from 0 to 1, a pixel can
+	// have before it counts as mismatched. Around 0.1 hides the dithering
+	// and precision differences between GPUs and drivers.
+	Threshold float64
+
+	// MaxDiffFraction is the fraction of the pixels, from 0 to 1, that can
+	// be mismatched before the images are considered different.
+	MaxDiffFraction float64
+}
+
+// DefaultTolerance returns a tolerance that ignores small driver differences
+// and allows a tenth of a percent of the pixels to be mismatched.
+func DefaultTolerance() Tolerance {
+	return Tolerance{Threshold: 0.1, MaxDiffFraction: 0.001}
+}
+
+// Result describes how two images differ.
+type Result struct {
+	// DiffPixels is the number of mismatched pixels.
+	DiffPixels int
+
+	// TotalPixels is the number of pixels compared.
+	TotalPixels int
+
+	// MaxDelta is the largest perceptual difference of any pixel, from 0 to 1.
+	MaxDelta float64
+
+	// Diff is a faded copy of the reference with the mismatched pixels in red.
+	Diff *image.RGBA
+
+	// Passed is true if the images matched within the tolerance.
+	Passed bool
+}
+
+// DiffFraction returns the fraction of the pixels that were mismatched.
+func (r *Result) DiffFraction() float64 {
+	if r.TotalPixels == 0 {
+		return 0.0
+	}
+	return float64(r.DiffPixels) / float64(r.TotalPixels)
+}
+
+// Compare measures the perceptual difference between the images. The
+// difference of each pixel is the distance between the colors in the YIQ
+// color space, weighted to match how sensitive the eye is to changes in
+// brightness and hue, after blending both over white.
+func Compare(got *image.RGBA, want *image.RGBA, tol Tolerance) (*Result, error) {
+	gb, wb := got.Bounds(), want.Bounds()
+	if gb.Dx() != wb.Dx() || gb.Dy() != wb.Dy() {
+		return nil, fmt.Errorf("the image is %dx%d but the reference is %dx%d", gb.Dx(), gb.Dy(), wb.Dx(), wb.Dy())
+	}
+
+	width, height := gb.Dx(), gb.Dy()
+	result := new(Result)
+	result.TotalPixels = width * height
+	result.Diff = image.NewRGBA(image.Rect(0, 0, width, height))
+	limit := tol.Threshold * tol.Threshold
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			g := got.RGBAAt(gb.Min.X+x, gb.Min.Y+y)
+			w := want.RGBAAt(wb.Min.X+x, wb.Min.Y+y)
+			delta := yiqDelta(g, w) / maxYIQDelta
+			if delta > result.MaxDelta {
+				result.MaxDelta = delta
+			}
+
+			if delta > limit {
+				result.DiffPixels++
+				result.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				// a faded grayscale copy of the reference for context
+				l := uint8(255.0 - (255.0-yiqY(blendWhite(w)))*0.1)
+				result.Diff.SetRGBA(x, y, color.RGBA{l, l, l, 255})
+			}
+		}
+	}
+
+	// report the delta on the same 0 to 1 scale as the threshold
+	result.MaxDelta = math.Sqrt(result.MaxDelta)
+	result.Passed = result.DiffFraction() <= tol.MaxDiffFraction
+	return result, nil
+}
+
+// blendWhite blends the color over a white background.
+func blendWhite(c color.RGBA) [3]float64 {
+	// color.RGBA is alpha premultiplied
+	bg := 255.0 - float64(c.A)
+	return [3]float64{float64(c.R) + bg, float64(c.G) + bg, float64(c.B) + bg}
+}
+
+// yiqY returns the brightness of the color.
+func yiqY(c [3]float64) float64 {
+	return c[0]*0.29889531 + c[1]*0.58662247 + c[2]*0.11448223
+}
+
+// yiqDelta returns the weighted squared distance of the colors in YIQ space.
+func yiqDelta(a color.RGBA, b color.RGBA) float64 {
+	if a == b {
+		return 0.0
+	}
+	ca, cb := blendWhite(a), blendWhite(b)
+	dr, dg, db := ca[0]-cb[0], ca[1]-cb[1], ca[2]-cb[2]
+
+	y := dr*0.29889531 + dg*0.58662247 + db*0.11448223
+	i := dr*0.59597799 - dg*0.27417610 - db*0.32180189
+	q := dr*0.21147017 - dg*0.52261711 + db*0.31114694
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
@@ -0,0 +1,145 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The golden module is a harness for regression testing the renderers and
+shaders with reference images. A test renders a scene into an offscreen
+Target, reads the pixels back and calls Check, which compares them to the
+reference PNG stored under ReferenceDir with a perceptual tolerance.
+
+A GL context still has to be created by the test, but no window needs to
+be shown; a hidden GLFW window or an EGL pbuffer surface both work.
+
+When a reference image doesn't exist yet, or the FIZZLE_UPDATE_GOLDEN
+environment variable is set to 1, the rendered image is saved as the new
+reference instead. When a comparison fails, the rendered image and a diff
+image highlighting the mismatched pixels are written next to the reference
+with .actual.png and .diff.png suffixes.
+
+*/
+
+package golden
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// UpdateEnvVar is the environment variable that, when set to 1, makes
+	// Check save the rendered images as the new references.
+	UpdateEnvVar = "FIZZLE_UPDATE_GOLDEN"
+)
+
+var (
+	// ReferenceDir is the directory the reference images are stored in,
+	// relative to the package being tested.
+	ReferenceDir = filepath.Join("testdata", "golden")
+)
+
+// Render draws a scene into a new offscreen Target of the size and returns
+// the pixels. The draw function is called with the Target bound and cleared.
+func Render(gfx graphics.GraphicsProvider, width, height int32, draw func()) (*image.RGBA, error) {
+	target, err := NewTarget(gfx, width, height)
+	if err != nil {
+		return nil, err
+	}
+	defer target.Destroy()
+
+	target.Begin()
+	draw()
+	target.End()
+	return target.Capture()
+}
+
+// Check compares the image to the named reference image and fails the
+// test if they differ by more than the tolerance.
+func Check(t testing.TB, name string, img *image.RGBA, tol Tolerance) {
+	refPath := filepath.Join(ReferenceDir, name+".png")
+
+	if os.Getenv(UpdateEnvVar) == "1" {
+		if err := SavePNG(refPath, img); err != nil {
+			t.Fatalf("failed to update the reference image %s: %v", refPath, err)
+		}
+		t.Logf("updated the reference image %s", refPath)
+		return
+	}
+
+	ref, err := LoadPNG(refPath)
+	if os.IsNotExist(err) {
+		if err := SavePNG(refPath, img); err != nil {
+			t.Fatalf("failed to create the reference image %s: %v", refPath, err)
+		}
+		t.Logf("created the missing reference image %s", refPath)
+		return
+	} else if err != nil {
+		t.Fatalf("failed to load the reference image %s: %v", refPath, err)
+	}
+
+	result, err := Compare(img, ref, tol)
+	if err != nil {
+		t.Fatalf("failed to compare against %s: %v", refPath, err)
+	}
+	if result.Passed {
+		return
+	}
+
+	base := filepath.Join(ReferenceDir, name)
+	SavePNG(base+".actual.png", img)
+	SavePNG(base+".diff.png", result.Diff)
+	t.Errorf("%s differs from the reference: %d of %d pixels (%.3f%%) mismatched, max delta %.3f; see %s.diff.png",
+		name, result.DiffPixels, result.TotalPixels, result.DiffFraction()*100.0, result.MaxDelta, base)
+}
+
+// LoadPNG reads a PNG file into an RGBA image.
+func LoadPNG(path string) (*image.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return toRGBA(img), nil
+}
+
+// SavePNG writes the image to a PNG file, creating the directory if needed.
+func SavePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	return f.Close()
+}
+
+// toRGBA returns the image as an *image.RGBA, converting if needed.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, okay := img.(*image.RGBA); okay {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			rgba.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return rgba
+}
@@ -0,0 +1,108 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package golden
+
+import (
+	"fmt"
+	"image"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+// Target is an offscreen framebuffer with a color and depth-stencil
+// attachment for rendering test scenes without a visible window.
+type Target struct {
+	// ClearColor is the color the target is cleared to in Begin.
+	ClearColor [4]float32
+
+	gfx      graphics.GraphicsProvider
+	fbo      graphics.Buffer
+	colorTex graphics.Texture
+	depthRB  graphics.Buffer
+	width    int32
+	height   int32
+}
+
+// NewTarget creates the framebuffer at the size.
+func NewTarget(gfx graphics.GraphicsProvider, width, height int32) (*Target, error) {
+	t := new(Target)
+	t.gfx = gfx
+	t.width = width
+	t.height = height
+	t.ClearColor = [4]float32{0.0, 0.0, 0.0, 1.0}
+
+	t.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, t.fbo)
+
+	t.colorTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, t.colorTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA8, width, height, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, t.colorTex, 0)
+
+	t.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, t.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH24_STENCIL8, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_STENCIL_ATTACHMENT, graphics.RENDERBUFFER, t.depthRB)
+
+	status := gfx.CheckFramebufferStatus(graphics.FRAMEBUFFER)
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	if status != graphics.FRAMEBUFFER_COMPLETE {
+		t.Destroy()
+		return nil, fmt.Errorf("failed to create the golden image framebuffer (status 0x%x)", uint32(status))
+	}
+	return t, nil
+}
+
+// Destroy releases the framebuffer and its attachments.
+func (t *Target) Destroy() {
+	if t.fbo != 0 {
+		t.gfx.DeleteFramebuffer(t.fbo)
+		t.fbo = 0
+	}
+	if t.colorTex != 0 {
+		t.gfx.DeleteTexture(t.colorTex)
+		t.colorTex = 0
+	}
+	if t.depthRB != 0 {
+		t.gfx.DeleteRenderbuffer(t.depthRB)
+		t.depthRB = 0
+	}
+}
+
+// GetFramebuffer returns the framebuffer object of the target.
+func (t *Target) GetFramebuffer() graphics.Buffer {
+	return t.fbo
+}
+
+// GetSize returns the size of the target.
+func (t *Target) GetSize() (int32, int32) {
+	return t.width, t.height
+}
+
+// Begin binds and clears the target and sets the viewport to cover it.
+func (t *Target) Begin() {
+	gfx := t.gfx
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, t.fbo)
+	gfx.Viewport(0, 0, t.width, t.height)
+	gfx.ClearColor(t.ClearColor[0], t.ClearColor[1], t.ClearColor[2], t.ClearColor[3])
+	gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT | graphics.STENCIL_BUFFER_BIT)
+}
+
+// End unbinds the target.
+func (t *Target) End() {
+	t.gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+}
+
+// Capture reads the pixels of the target back into an image.
+func (t *Target) Capture() (*image.RGBA, error) {
+	return renderer.CaptureFramebuffer(t.gfx, t.fbo, 0, 0, t.width, t.height)
+}
@@ -37,6 +37,9 @@ type Shader uint32
 // Bitfield is a typ indicating the uint32 use as an OpenGL bitfield
 type Bitfield uint32
 
+// Sync is a type indicating the use as an OpenGL sync object
+type Sync uintptr
+
 // GraphicsProvider represents a common way to interface with graphics
 // 'drivers' like OpenGL or OpenGL ES.
 type GraphicsProvider interface {
@@ -72,6 +75,10 @@ type GraphicsProvider interface {
 	// BlendFunc specifies the pixel arithmetic for the blend fucntion
 	BlendFunc(sFactor, dFactor Enum)
 
+	// BlendFuncSeparate specifies the pixel arithmetic for the RGB and alpha
+	// components separately
+	BlendFuncSeparate(srcRGB, dstRGB, srcAlpha, dstAlpha Enum)
+
 	// BlitFramebuffer copies a block of pixels from one framebuffer object to another
 	BlitFramebuffer(srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1 int32, mask Bitfield, filter Enum)
 
@@ -81,12 +88,22 @@ type GraphicsProvider interface {
 	// CheckFramebufferStatus checks the completeness status of a framebuffer
 	CheckFramebufferStatus(target Enum) Enum
 
+	// ClientWaitSync waits up to timeout nanoseconds for the sync object
+	// to be signaled and returns the status
+	ClientWaitSync(sync Sync, flags Bitfield, timeout uint64) Enum
+
 	// Clear clears the window buffer specified in mask
 	Clear(mask Enum)
 
 	// ClearColor specifies the RGBA value used to clear the color buffers
 	ClearColor(red, green, blue, alpha float32)
 
+	// ClearStencil specifies the index used when the stencil buffer is cleared
+	ClearStencil(s int32)
+
+	// ColorMask enables or disables writing of the color components into the framebuffer
+	ColorMask(red, green, blue, alpha bool)
+
 	// CompileShader compiles the shader object
 	CompileShader(s Shader)
 
@@ -108,30 +125,45 @@ type GraphicsProvider interface {
 	// DeleteProgram deletes the shader program object
 	DeleteProgram(p Program)
 
+	// DeleteQuery deletes the query object
+	DeleteQuery(q uint32)
+
 	// DeleteRenderbuffer deletes the renderbuffer object
 	DeleteRenderbuffer(rb Buffer)
 
 	// DeleteShader deletes the shader object
 	DeleteShader(s Shader)
 
+	// DeleteSync deletes the sync object
+	DeleteSync(sync Sync)
+
 	// DeleteTexture deletes the specified texture
 	DeleteTexture(v Texture)
 
 	// DeleteVertexArray deletes an OpenGL VAO
 	DeleteVertexArray(a uint32)
 
+	// DepthFunc specifies the function used to compare depth values
+	DepthFunc(fn Enum)
+
 	// DepthMask enables or disables writing into the depth buffer
 	DepthMask(flag bool)
 
 	// Disable disables various GL capabilities
 	Disable(e Enum)
 
+	// DisableVertexAttribArray disables a vertex attribute array
+	DisableVertexAttribArray(a uint32)
+
 	// DrawBuffers specifies a list of color buffers to be drawn into
 	DrawBuffers(buffers []uint32)
 
 	// DrawElements renders primitives from array data
 	DrawElements(mode Enum, count int32, xtype Enum, indices unsafe.Pointer)
 
+	// DrawElementsInstanced draws multiple instances of a set of elements
+	DrawElementsInstanced(mode Enum, count int32, xtype Enum, indices unsafe.Pointer, primcount int32)
+
 	// DrawArrays renders primitives from array data
 	DrawArrays(mode Enum, first int32, count int32)
 
@@ -141,6 +173,9 @@ type GraphicsProvider interface {
 	// EnableVertexAttribArray enables a vertex attribute array
 	EnableVertexAttribArray(a uint32)
 
+	// FenceSync creates a sync object that is signaled once the condition is met
+	FenceSync(condition Enum, flags Bitfield) Sync
+
 	// FramebufferRenderbuffer attaches a renderbuffer as a logical buffer
 	// of a framebuffer object
 	FramebufferRenderbuffer(target, attachment, renderbuffertarget Enum, renderbuffer Buffer)
@@ -157,6 +192,9 @@ type GraphicsProvider interface {
 	// GenFramebuffer generates a OpenGL framebuffer object
 	GenFramebuffer() Buffer
 
+	// GenQuery creates an OpenGL query object
+	GenQuery() uint32
+
 	// GenRenderbuffer generates a OpenGL renderbuffer object
 	GenRenderbuffer() Buffer
 
@@ -178,6 +216,12 @@ type GraphicsProvider interface {
 	// GetProgramiv returns a parameter from the program object
 	GetProgramiv(p Program, pname Enum, params *int32)
 
+	// GetQueryObjectui64v returns a 64 bit parameter of a query object
+	GetQueryObjectui64v(q uint32, pname Enum, params *uint64)
+
+	// GetQueryObjectuiv returns a parameter of a query object
+	GetQueryObjectuiv(q uint32, pname Enum, params *uint32)
+
 	// GetShaderInfoLog returns the information log for a shader object
 	GetShaderInfoLog(s Shader) string
 
@@ -190,6 +234,10 @@ type GraphicsProvider interface {
 	// LinkProgram links a program object
 	LinkProgram(p Program)
 
+	// MapBufferRange maps a range of the bound buffer's data store into
+	// client memory and returns a pointer to it
+	MapBufferRange(target Enum, offset int, length int, access Bitfield) unsafe.Pointer
+
 	// PolygonMode sets a polygon rasterization mode.
 	PolygonMode(face, mode Enum)
 
@@ -204,9 +252,17 @@ type GraphicsProvider interface {
 	// parameters indicating an offset rather than an absolute memory address.
 	PtrOffset(offset int) unsafe.Pointer
 
+	// QueryCounter records the GL time into a query object after all
+	// previous commands have been fully executed
+	QueryCounter(q uint32, target Enum)
+
 	// ReadBuffer specifies the color buffer source for pixels
 	ReadBuffer(src Enum)
 
+	// ReadPixels reads a block of pixels from the read framebuffer into
+	// client memory or the bound pixel pack buffer
+	ReadPixels(x, y, width, height int32, format Enum, ty Enum, pixels unsafe.Pointer)
+
 	// RenderbufferStorage establishes the format and dimensions of a renderbuffer
 	RenderbufferStorage(target Enum, internalformat Enum, width int32, height int32)
 
@@ -219,6 +275,21 @@ type GraphicsProvider interface {
 	// ShaderSource replaces the source code for a shader object.
 	ShaderSource(s Shader, source string)
 
+	// StencilFunc sets the function and reference value for stencil testing
+	StencilFunc(fn Enum, ref int32, mask uint32)
+
+	// StencilFuncSeparate sets the front and/or back function and reference value for stencil testing
+	StencilFuncSeparate(face, fn Enum, ref int32, mask uint32)
+
+	// StencilMask controls the writing of individual bits in the stencil buffer
+	StencilMask(mask uint32)
+
+	// StencilOp sets the stencil test actions for both faces
+	StencilOp(sfail, dpfail, dppass Enum)
+
+	// StencilOpSeparate sets the front and/or back stencil test actions
+	StencilOpSeparate(face, sfail, dpfail, dppass Enum)
+
 	// TexImage2D writes a 2D texture image.
 	TexImage2D(target Enum, level, intfmt, width, height, border int32, format Enum, ty Enum, ptr unsafe.Pointer, dataLength int)
 
@@ -241,6 +312,9 @@ type GraphicsProvider interface {
 	// TexSubImage3D specifies a three-dimensonal texture subimage
 	TexSubImage3D(target Enum, level, xoff, yoff, zoff, width, height, depth int32, fmt, ty Enum, ptr unsafe.Pointer)
 
+	// UnmapBuffer releases the mapping of the bound buffer's data store
+	UnmapBuffer(target Enum) bool
+
 	// Uniform1i specifies the value of a uniform variable for the current program object
 	Uniform1i(location int32, v int32)
 
@@ -253,6 +327,9 @@ type GraphicsProvider interface {
 	// Uniform1fv specifies the value of a uniform variable for the current program object
 	Uniform1fv(location int32, values []float32)
 
+	// Uniform2f specifies the value of a uniform variable for the current program object
+	Uniform2f(location int32, v0, v1 float32)
+
 	// Uniform3f specifies the value of a uniform variable for the current program object
 	Uniform3f(location int32, v0, v1, v2 float32)
 
@@ -266,7 +343,9 @@ type GraphicsProvider interface {
 	Uniform4fv(location int32, value []float32)
 
 	// UniformMatrix4fv specifies the value of a uniform variable for the current program object
-	// NOTE: value should be a mgl.Mat4 or []mgl.Mat4, else it will panic.
+	// NOTE: value should be a mgl.Mat4, *mgl.Mat4 or []mgl.Mat4, else it will panic.
+	// A *mgl.Mat4 may point to the first of count contiguous matrices and avoids
+	// the allocation that boxing a matrix value into the interface causes.
 	UniformMatrix4fv(location, count int32, transpose bool, value interface{})
 
 	// UseProgram installs a program object as part of the current rendering state
@@ -283,6 +362,10 @@ type GraphicsProvider interface {
 	// Only integer types are accepted by this function.
 	VertexAttribIPointer(dst uint32, size int32, ty Enum, stride int32, ptr unsafe.Pointer)
 
+	// VertexAttribDivisor modifies the rate at which generic vertex attributes
+	// advance during instanced rendering
+	VertexAttribDivisor(index uint32, divisor uint32)
+
 	// Viewport sets the viewport, an affine transformation that
 	// normalizes device coordinates to window coordinates.
 	Viewport(x, y, width, height int32)
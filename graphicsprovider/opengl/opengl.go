@@ -87,6 +87,12 @@ func (impl *GraphicsImpl) BlendFunc(sFactor, dFactor graphics.Enum) {
 	gl.BlendFunc(uint32(sFactor), uint32(dFactor))
 }
 
+// BlendFuncSeparate specifies the pixel arithmetic for the RGB and alpha
+// components separately
+func (impl *GraphicsImpl) BlendFuncSeparate(srcRGB, dstRGB, srcAlpha, dstAlpha graphics.Enum) {
+	gl.BlendFuncSeparate(uint32(srcRGB), uint32(dstRGB), uint32(srcAlpha), uint32(dstAlpha))
+}
+
 // BlitFramebuffer copies a block of pixels from one framebuffer object to another
 func (impl *GraphicsImpl) BlitFramebuffer(srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1 int32, mask graphics.Bitfield, filter graphics.Enum) {
 	gl.BlitFramebuffer(srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1, uint32(mask), uint32(filter))
@@ -102,6 +108,12 @@ func (impl *GraphicsImpl) CheckFramebufferStatus(target graphics.Enum) graphics.
 	return graphics.Enum(gl.CheckFramebufferStatus(uint32(target)))
 }
 
+// ClientWaitSync waits up to timeout nanoseconds for the sync object
+// to be signaled and returns the status
+func (impl *GraphicsImpl) ClientWaitSync(sync graphics.Sync, flags graphics.Bitfield, timeout uint64) graphics.Enum {
+	return graphics.Enum(gl.ClientWaitSync(uintptr(sync), uint32(flags), timeout))
+}
+
 // Clear clears the window buffer specified in mask
 func (impl *GraphicsImpl) Clear(mask graphics.Enum) {
 	gl.Clear(uint32(mask))
@@ -112,6 +124,16 @@ func (impl *GraphicsImpl) ClearColor(red, green, blue, alpha float32) {
 	gl.ClearColor(red, green, blue, alpha)
 }
 
+// ClearStencil specifies the index used when the stencil buffer is cleared
+func (impl *GraphicsImpl) ClearStencil(s int32) {
+	gl.ClearStencil(s)
+}
+
+// ColorMask enables or disables writing of the color components into the framebuffer
+func (impl *GraphicsImpl) ColorMask(red, green, blue, alpha bool) {
+	gl.ColorMask(red, green, blue, alpha)
+}
+
 // CompileShader compiles the shader object
 func (impl *GraphicsImpl) CompileShader(s graphics.Shader) {
 	gl.CompileShader(uint32(s))
@@ -149,6 +171,11 @@ func (impl *GraphicsImpl) DeleteProgram(p graphics.Program) {
 	gl.DeleteProgram(uint32(p))
 }
 
+// DeleteQuery deletes the query object
+func (impl *GraphicsImpl) DeleteQuery(q uint32) {
+	gl.DeleteQueries(1, &q)
+}
+
 // DeleteRenderbuffer deletes the renderbuffer object
 func (impl *GraphicsImpl) DeleteRenderbuffer(rb graphics.Buffer) {
 	uintV := uint32(rb)
@@ -160,6 +187,11 @@ func (impl *GraphicsImpl) DeleteShader(s graphics.Shader) {
 	gl.DeleteShader(uint32(s))
 }
 
+// DeleteSync deletes the sync object
+func (impl *GraphicsImpl) DeleteSync(sync graphics.Sync) {
+	gl.DeleteSync(uintptr(sync))
+}
+
 // DeleteTexture deletes the specified texture
 func (impl *GraphicsImpl) DeleteTexture(v graphics.Texture) {
 	uintV := uint32(v)
@@ -172,6 +204,11 @@ func (impl *GraphicsImpl) DeleteVertexArray(a uint32) {
 	gl.DeleteVertexArrays(1, &uintV)
 }
 
+// DepthFunc specifies the function used to compare depth values
+func (impl *GraphicsImpl) DepthFunc(fn graphics.Enum) {
+	gl.DepthFunc(uint32(fn))
+}
+
 // DepthMask enables or disables writing into the depth buffer
 func (impl *GraphicsImpl) DepthMask(flag bool) {
 	gl.DepthMask(flag)
@@ -182,6 +219,11 @@ func (impl *GraphicsImpl) Disable(e graphics.Enum) {
 	gl.Disable(uint32(e))
 }
 
+// DisableVertexAttribArray disables a vertex attribute array
+func (impl *GraphicsImpl) DisableVertexAttribArray(a uint32) {
+	gl.DisableVertexAttribArray(a)
+}
+
 // DrawBuffers specifies a list of color buffers to be drawn into
 func (impl *GraphicsImpl) DrawBuffers(buffers []uint32) {
 	c := int32(len(buffers))
@@ -193,6 +235,11 @@ func (impl *GraphicsImpl) DrawElements(mode graphics.Enum, count int32, ty graph
 	gl.DrawElements(uint32(mode), count, uint32(ty), indices)
 }
 
+// DrawElementsInstanced draws multiple instances of a set of elements
+func (impl *GraphicsImpl) DrawElementsInstanced(mode graphics.Enum, count int32, ty graphics.Enum, indices unsafe.Pointer, primcount int32) {
+	gl.DrawElementsInstanced(uint32(mode), count, uint32(ty), indices, primcount)
+}
+
 // DrawArrays renders primitives from array data
 func (impl *GraphicsImpl) DrawArrays(mode graphics.Enum, first int32, count int32) {
 	gl.DrawArrays(uint32(mode), first, count)
@@ -210,6 +257,11 @@ func (impl *GraphicsImpl) EnableVertexAttribArray(a uint32) {
 	gl.EnableVertexAttribArray(a)
 }
 
+// FenceSync creates a sync object that is signaled once the condition is met
+func (impl *GraphicsImpl) FenceSync(condition graphics.Enum, flags graphics.Bitfield) graphics.Sync {
+	return graphics.Sync(gl.FenceSync(uint32(condition), uint32(flags)))
+}
+
 // FramebufferRenderbuffer attaches a renderbuffer as a logical buffer
 // of a framebuffer object
 func (impl *GraphicsImpl) FramebufferRenderbuffer(target, attachment, renderbuffertarget graphics.Enum, renderbuffer graphics.Buffer) {
@@ -240,6 +292,13 @@ func (impl *GraphicsImpl) GenFramebuffer() graphics.Buffer {
 	return graphics.Buffer(b)
 }
 
+// GenQuery creates an OpenGL query object
+func (impl *GraphicsImpl) GenQuery() uint32 {
+	var q uint32
+	gl.GenQueries(1, &q)
+	return q
+}
+
 // GenRenderbuffer generates a OpenGL renderbuffer object
 func (impl *GraphicsImpl) GenRenderbuffer() graphics.Buffer {
 	var b uint32
@@ -289,6 +348,16 @@ func (impl *GraphicsImpl) GetProgramiv(p graphics.Program, pname graphics.Enum,
 	gl.GetProgramiv(uint32(p), uint32(pname), params)
 }
 
+// GetQueryObjectui64v returns a 64 bit parameter of a query object
+func (impl *GraphicsImpl) GetQueryObjectui64v(q uint32, pname graphics.Enum, params *uint64) {
+	gl.GetQueryObjectui64v(q, uint32(pname), params)
+}
+
+// GetQueryObjectuiv returns a parameter of a query object
+func (impl *GraphicsImpl) GetQueryObjectuiv(q uint32, pname graphics.Enum, params *uint32) {
+	gl.GetQueryObjectuiv(q, uint32(pname), params)
+}
+
 // GetShaderInfoLog returns the information log for a shader object
 func (impl *GraphicsImpl) GetShaderInfoLog(s graphics.Shader) string {
 	var logLength int32
@@ -317,6 +386,12 @@ func (impl *GraphicsImpl) LinkProgram(p graphics.Program) {
 	gl.LinkProgram(uint32(p))
 }
 
+// MapBufferRange maps a range of the bound buffer's data store into
+// client memory and returns a pointer to it
+func (impl *GraphicsImpl) MapBufferRange(target graphics.Enum, offset int, length int, access graphics.Bitfield) unsafe.Pointer {
+	return gl.MapBufferRange(uint32(target), offset, length, uint32(access))
+}
+
 // PolygonMode sets a polygon rasterization mode.
 func (impl *GraphicsImpl) PolygonMode(face, mode graphics.Enum) {
 	gl.PolygonMode(uint32(face), uint32(mode))
@@ -339,11 +414,23 @@ func (impl *GraphicsImpl) PtrOffset(offset int) unsafe.Pointer {
 	return gl.PtrOffset(offset)
 }
 
+// QueryCounter records the GL time into a query object after all
+// previous commands have been fully executed
+func (impl *GraphicsImpl) QueryCounter(q uint32, target graphics.Enum) {
+	gl.QueryCounter(q, uint32(target))
+}
+
 // ReadBuffer specifies the color buffer source for pixels
 func (impl *GraphicsImpl) ReadBuffer(src graphics.Enum) {
 	gl.ReadBuffer(uint32(src))
 }
 
+// ReadPixels reads a block of pixels from the read framebuffer into
+// client memory or the bound pixel pack buffer
+func (impl *GraphicsImpl) ReadPixels(x, y, width, height int32, format graphics.Enum, ty graphics.Enum, pixels unsafe.Pointer) {
+	gl.ReadPixels(x, y, width, height, uint32(format), uint32(ty), pixels)
+}
+
 // RenderbufferStorage establishes the format and dimensions of a renderbuffer
 func (impl *GraphicsImpl) RenderbufferStorage(target graphics.Enum, internalformat graphics.Enum, width int32, height int32) {
 	gl.RenderbufferStorage(uint32(target), uint32(internalformat), width, height)
@@ -366,6 +453,31 @@ func (impl *GraphicsImpl) ShaderSource(s graphics.Shader, source string) {
 	free()
 }
 
+// StencilFunc sets the function and reference value for stencil testing
+func (impl *GraphicsImpl) StencilFunc(fn graphics.Enum, ref int32, mask uint32) {
+	gl.StencilFunc(uint32(fn), ref, mask)
+}
+
+// StencilFuncSeparate sets the front and/or back function and reference value for stencil testing
+func (impl *GraphicsImpl) StencilFuncSeparate(face, fn graphics.Enum, ref int32, mask uint32) {
+	gl.StencilFuncSeparate(uint32(face), uint32(fn), ref, mask)
+}
+
+// StencilMask controls the writing of individual bits in the stencil buffer
+func (impl *GraphicsImpl) StencilMask(mask uint32) {
+	gl.StencilMask(mask)
+}
+
+// StencilOp sets the stencil test actions for both faces
+func (impl *GraphicsImpl) StencilOp(sfail, dpfail, dppass graphics.Enum) {
+	gl.StencilOp(uint32(sfail), uint32(dpfail), uint32(dppass))
+}
+
+// StencilOpSeparate sets the front and/or back stencil test actions
+func (impl *GraphicsImpl) StencilOpSeparate(face, sfail, dpfail, dppass graphics.Enum) {
+	gl.StencilOpSeparate(uint32(face), uint32(sfail), uint32(dpfail), uint32(dppass))
+}
+
 // TexImage2D writes a 2D texture image.
 func (impl *GraphicsImpl) TexImage2D(target graphics.Enum, level, intfmt, width, height, border int32, format graphics.Enum, ty graphics.Enum, ptr unsafe.Pointer, dataLength int) {
 	gl.TexImage2D(uint32(target), level, intfmt, width, height, border, uint32(format), uint32(ty), ptr)
@@ -402,6 +514,11 @@ func (impl *GraphicsImpl) TexSubImage3D(target graphics.Enum, level, xoff, yoff,
 	gl.TexSubImage3D(uint32(target), level, xoff, yoff, zoff, width, height, depth, uint32(fmt), uint32(ty), ptr)
 }
 
+// UnmapBuffer releases the mapping of the bound buffer's data store
+func (impl *GraphicsImpl) UnmapBuffer(target graphics.Enum) bool {
+	return gl.UnmapBuffer(uint32(target))
+}
+
 // Uniform1i specifies the value of a uniform variable for the current program object
 func (impl *GraphicsImpl) Uniform1i(location int32, v int32) {
 	gl.Uniform1i(location, v)
@@ -422,6 +539,11 @@ func (impl *GraphicsImpl) Uniform1fv(location int32, values []float32) {
 	gl.Uniform1fv(location, int32(len(values)), &values[0])
 }
 
+// Uniform2f specifies the value of a uniform variable for the current program object
+func (impl *GraphicsImpl) Uniform2f(location int32, v0, v1 float32) {
+	gl.Uniform2f(location, v0, v1)
+}
+
 // Uniform3f specifies the value of a uniform variable for the current program object
 func (impl *GraphicsImpl) Uniform3f(location int32, v0, v1, v2 float32) {
 	gl.Uniform3f(location, v0, v1, v2)
@@ -443,11 +565,13 @@ func (impl *GraphicsImpl) Uniform4fv(location int32, values []float32) {
 }
 
 // UniformMatrix4fv specifies the value of a uniform variable for the current program object
-// NOTE: value should be a mgl.Mat4 or []mgl.Mat4, else it will panic.
+// NOTE: value should be a mgl.Mat4, *mgl.Mat4 or []mgl.Mat4, else it will panic.
 func (impl *GraphicsImpl) UniformMatrix4fv(location, count int32, transpose bool, value interface{}) {
 	switch t := value.(type) {
 	case mgl.Mat4:
 		gl.UniformMatrix4fv(location, count, transpose, &(t[0]))
+	case *mgl.Mat4:
+		gl.UniformMatrix4fv(location, count, transpose, &(t[0]))
 	case []mgl.Mat4:
 		gl.UniformMatrix4fv(location, count, transpose, &(t[0][0]))
 	default:
@@ -475,6 +599,12 @@ func (impl *GraphicsImpl) VertexAttribIPointer(dst uint32, size int32, ty graphi
 	gl.VertexAttribIPointer(dst, size, uint32(ty), stride, ptr)
 }
 
+// VertexAttribDivisor modifies the rate at which generic vertex attributes
+// advance during instanced rendering
+func (impl *GraphicsImpl) VertexAttribDivisor(index uint32, divisor uint32) {
+	gl.VertexAttribDivisor(index, divisor)
+}
+
 // Viewport sets the viewport, an affine transformation that
 // normalizes device coordinates to window coordinates.
 func (impl *GraphicsImpl) Viewport(x, y, width, height int32) {
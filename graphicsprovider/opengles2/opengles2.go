@@ -80,6 +80,12 @@ func (impl *GraphicsImpl) BlendFunc(sFactor, dFactor graphics.Enum) {
 	gles.BlendFunc(gles.Enum(sFactor), gles.Enum(dFactor))
 }
 
+// BlendFuncSeparate specifies the pixel arithmetic for the RGB and alpha
+// components separately
+func (impl *GraphicsImpl) BlendFuncSeparate(srcRGB, dstRGB, srcAlpha, dstAlpha graphics.Enum) {
+	gles.BlendFuncSeparate(gles.Enum(srcRGB), gles.Enum(dstRGB), gles.Enum(srcAlpha), gles.Enum(dstAlpha))
+}
+
 // BlitFramebuffer copies a block of pixels from one framebuffer object to another
 func (impl *GraphicsImpl) BlitFramebuffer(srcX0, srcY0, srcX1, srcY1, dstX0, dstY0, dstX1, dstY1 int32, mask graphics.Bitfield, filter graphics.Enum) {
 	// NO-OP ves3+
@@ -95,6 +101,13 @@ func (impl *GraphicsImpl) CheckFramebufferStatus(target graphics.Enum) graphics.
 	return graphics.Enum(gles.CheckFramebufferStatus(gles.Enum(target)))
 }
 
+// ClientWaitSync waits up to timeout nanoseconds for the sync object
+// to be signaled and returns the status
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) ClientWaitSync(sync graphics.Sync, flags graphics.Bitfield, timeout uint64) graphics.Enum {
+	return graphics.WAIT_FAILED
+}
+
 // Clear clears the window buffer specified in mask
 func (impl *GraphicsImpl) Clear(mask graphics.Enum) {
 	gles.Clear(gles.Bitfield(mask))
@@ -105,6 +118,16 @@ func (impl *GraphicsImpl) ClearColor(red, green, blue, alpha float32) {
 	gles.ClearColor(gles.Clampf(red), gles.Clampf(green), gles.Clampf(blue), gles.Clampf(alpha))
 }
 
+// ClearStencil specifies the index used when the stencil buffer is cleared
+func (impl *GraphicsImpl) ClearStencil(s int32) {
+	gles.ClearStencil(s)
+}
+
+// ColorMask enables or disables writing of the color components into the framebuffer
+func (impl *GraphicsImpl) ColorMask(red, green, blue, alpha bool) {
+	gles.ColorMask(red, green, blue, alpha)
+}
+
 // CompileShader compiles the shader object
 func (impl *GraphicsImpl) CompileShader(s graphics.Shader) {
 	gles.CompileShader(uint32(s))
@@ -142,6 +165,12 @@ func (impl *GraphicsImpl) DeleteProgram(p graphics.Program) {
 	gles.DeleteProgram(uint32(p))
 }
 
+// DeleteQuery deletes the query object
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) DeleteQuery(q uint32) {
+	// NO-OP
+}
+
 // DeleteRenderbuffer deletes the renderbuffer object
 func (impl *GraphicsImpl) DeleteRenderbuffer(rb graphics.Buffer) {
 	ui := uint32(rb)
@@ -153,6 +182,12 @@ func (impl *GraphicsImpl) DeleteShader(s graphics.Shader) {
 	gles.DeleteShader(uint32(s))
 }
 
+// DeleteSync deletes the sync object
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) DeleteSync(sync graphics.Sync) {
+	// NO-OP
+}
+
 // DeleteTexture deletes the specified texture
 func (impl *GraphicsImpl) DeleteTexture(v graphics.Texture) {
 	ui := uint32(v)
@@ -165,6 +200,11 @@ func (impl *GraphicsImpl) DeleteVertexArray(a uint32) {
 	// NO-OP
 }
 
+// DepthFunc specifies the function used to compare depth values
+func (impl *GraphicsImpl) DepthFunc(fn graphics.Enum) {
+	gles.DepthFunc(gles.Enum(fn))
+}
+
 // DepthMask enables or disables writing into the depth buffer
 func (impl *GraphicsImpl) DepthMask(flag bool) {
 	gles.DepthMask(flag)
@@ -175,6 +215,11 @@ func (impl *GraphicsImpl) Disable(e graphics.Enum) {
 	gles.Disable(gles.Enum(e))
 }
 
+// DisableVertexAttribArray disables a vertex attribute array
+func (impl *GraphicsImpl) DisableVertexAttribArray(a uint32) {
+	gles.DisableVertexAttribArray(a)
+}
+
 // DrawBuffers specifies a list of color buffers to be drawn into
 // NOTE: not implemented in OpenGL ES 2
 func (impl *GraphicsImpl) DrawBuffers(buffers []uint32) {
@@ -186,6 +231,12 @@ func (impl *GraphicsImpl) DrawElements(mode graphics.Enum, count int32, ty graph
 	gles.DrawElements(gles.Enum(mode), gles.Sizei(count), gles.Enum(ty), gles.Void(indices))
 }
 
+// DrawElementsInstanced draws multiple instances of a set of elements
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) DrawElementsInstanced(mode graphics.Enum, count int32, ty graphics.Enum, indices unsafe.Pointer, primcount int32) {
+	// NO-OP
+}
+
 // DrawArrays renders primitives from array data
 func (impl *GraphicsImpl) DrawArrays(mode graphics.Enum, first int32, count int32) {
 	gles.DrawArrays(gles.Enum(mode), first, gles.Sizei(count))
@@ -211,6 +262,13 @@ func (impl *GraphicsImpl) Flush() {
 	gles.Flush()
 }
 
+// FenceSync creates a sync object that is signaled once the condition is met
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) FenceSync(condition graphics.Enum, flags graphics.Bitfield) graphics.Sync {
+	// NO-OP
+	return 0
+}
+
 // FramebufferRenderbuffer attaches a renderbuffer as a logical buffer
 // of a framebuffer object
 func (impl *GraphicsImpl) FramebufferRenderbuffer(target, attachment, renderbuffertarget graphics.Enum, renderbuffer graphics.Buffer) {
@@ -241,6 +299,13 @@ func (impl *GraphicsImpl) GenFramebuffer() graphics.Buffer {
 	return graphics.Buffer(b)
 }
 
+// GenQuery creates an OpenGL query object
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) GenQuery() uint32 {
+	// NO-OP
+	return 0
+}
+
 // GenRenderbuffer generates a OpenGL renderbuffer object
 func (impl *GraphicsImpl) GenRenderbuffer() graphics.Buffer {
 	var b uint32
@@ -284,6 +349,18 @@ func (impl *GraphicsImpl) GetProgramiv(p graphics.Program, pname graphics.Enum,
 	gles.GetProgramiv(uint32(p), gles.Enum(pname), params)
 }
 
+// GetQueryObjectui64v returns a 64 bit parameter of a query object
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) GetQueryObjectui64v(q uint32, pname graphics.Enum, params *uint64) {
+	// NO-OP
+}
+
+// GetQueryObjectuiv returns a parameter of a query object
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) GetQueryObjectuiv(q uint32, pname graphics.Enum, params *uint32) {
+	// NO-OP
+}
+
 // GetShaderInfoLog returns the information log for a shader object
 func (impl *GraphicsImpl) GetShaderInfoLog(s graphics.Shader) string {
 	var logLength int32
@@ -306,6 +383,14 @@ func (impl *GraphicsImpl) LinkProgram(p graphics.Program) {
 	gles.LinkProgram(uint32(p))
 }
 
+// MapBufferRange maps a range of the bound buffer's data store into
+// client memory and returns a pointer to it
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) MapBufferRange(target graphics.Enum, offset int, length int, access graphics.Bitfield) unsafe.Pointer {
+	// NO-OP
+	return nil
+}
+
 // PolygonMode sets a polygon rasterization mode.
 func (impl *GraphicsImpl) PolygonMode(face, mode graphics.Enum) {
 	// NO-OP: no support in OpenGL ES
@@ -354,12 +439,25 @@ func (impl *GraphicsImpl) PtrOffset(offset int) unsafe.Pointer {
 	return unsafe.Pointer(uintptr(offset))
 }
 
+// QueryCounter records the GL time into a query object after all
+// previous commands have been fully executed
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) QueryCounter(q uint32, target graphics.Enum) {
+	// NO-OP
+}
+
 // ReadBuffer specifies the color buffer source for pixels
 // NOTE: not implemented in OpenGL ES 2
 func (impl *GraphicsImpl) ReadBuffer(src graphics.Enum) {
 	// NO-OP
 }
 
+// ReadPixels reads a block of pixels from the read framebuffer into
+// client memory or the bound pixel pack buffer
+func (impl *GraphicsImpl) ReadPixels(x, y, width, height int32, format graphics.Enum, ty graphics.Enum, pixels unsafe.Pointer) {
+	gles.ReadPixels(x, y, gles.Sizei(width), gles.Sizei(height), gles.Enum(format), gles.Enum(ty), gles.Void(pixels))
+}
+
 // RenderbufferStorage establishes the format and dimensions of a renderbuffer
 func (impl *GraphicsImpl) RenderbufferStorage(target graphics.Enum, internalformat graphics.Enum, width int32, height int32) {
 	gles.RenderbufferStorage(gles.Enum(target), gles.Enum(internalformat), gles.Sizei(width), gles.Sizei(height))
@@ -380,6 +478,31 @@ func (impl *GraphicsImpl) ShaderSource(s graphics.Shader, source string) {
 	gles.ShaderSource(uint32(s), 1, &source, nil)
 }
 
+// StencilFunc sets the function and reference value for stencil testing
+func (impl *GraphicsImpl) StencilFunc(fn graphics.Enum, ref int32, mask uint32) {
+	gles.StencilFunc(gles.Enum(fn), ref, mask)
+}
+
+// StencilFuncSeparate sets the front and/or back function and reference value for stencil testing
+func (impl *GraphicsImpl) StencilFuncSeparate(face, fn graphics.Enum, ref int32, mask uint32) {
+	gles.StencilFuncSeparate(gles.Enum(face), gles.Enum(fn), ref, mask)
+}
+
+// StencilMask controls the writing of individual bits in the stencil buffer
+func (impl *GraphicsImpl) StencilMask(mask uint32) {
+	gles.StencilMask(mask)
+}
+
+// StencilOp sets the stencil test actions for both faces
+func (impl *GraphicsImpl) StencilOp(sfail, dpfail, dppass graphics.Enum) {
+	gles.StencilOp(gles.Enum(sfail), gles.Enum(dpfail), gles.Enum(dppass))
+}
+
+// StencilOpSeparate sets the front and/or back stencil test actions
+func (impl *GraphicsImpl) StencilOpSeparate(face, sfail, dpfail, dppass graphics.Enum) {
+	gles.StencilOpSeparate(gles.Enum(face), gles.Enum(sfail), gles.Enum(dpfail), gles.Enum(dppass))
+}
+
 // TexImage2D writes a 2D texture image.
 func (impl *GraphicsImpl) TexImage2D(target graphics.Enum, level, intfmt, width, height, border int32, format graphics.Enum, ty graphics.Enum, ptr unsafe.Pointer, dataLength int) {
 	gles.TexImage2D(gles.Enum(target), level, intfmt, gles.Sizei(width), gles.Sizei(height), border, gles.Enum(format), gles.Enum(ty), gles.Void(ptr))
@@ -418,6 +541,13 @@ func (impl *GraphicsImpl) TexSubImage3D(target graphics.Enum, level, xoff, yoff,
 	// NO-OP
 }
 
+// UnmapBuffer releases the mapping of the bound buffer's data store
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) UnmapBuffer(target graphics.Enum) bool {
+	// NO-OP
+	return false
+}
+
 // Uniform1i specifies the value of a uniform variable for the current program object
 func (impl *GraphicsImpl) Uniform1i(location int32, v int32) {
 	gles.Uniform1i(location, v)
@@ -438,6 +568,11 @@ func (impl *GraphicsImpl) Uniform1fv(location int32, values []float32) {
 	gles.Uniform1fv(location, gles.Sizei(len(values)), &values[0])
 }
 
+// Uniform2f specifies the value of a uniform variable for the current program object
+func (impl *GraphicsImpl) Uniform2f(location int32, v0, v1 float32) {
+	gles.Uniform2f(location, v0, v1)
+}
+
 // Uniform3f specifies the value of a uniform variable for the current program object
 func (impl *GraphicsImpl) Uniform3f(location int32, v0, v1, v2 float32) {
 	gles.Uniform3f(location, v0, v1, v2)
@@ -459,11 +594,13 @@ func (impl *GraphicsImpl) Uniform4fv(location int32, values []float32) {
 }
 
 // UniformMatrix4fv specifies the value of a uniform variable for the current program object.
-// NOTE: value should be a mgl.Mat4 or []mgl.Mat4, else it will panic.
+// NOTE: value should be a mgl.Mat4, *mgl.Mat4 or []mgl.Mat4, else it will panic.
 func (impl *GraphicsImpl) UniformMatrix4fv(location, count int32, transpose bool, value interface{}) {
 	switch t := value.(type) {
 	case mgl.Mat4:
 		gles.UniformMatrix4fv(location, gles.Sizei(count), transpose, &t[0])
+	case *mgl.Mat4:
+		gles.UniformMatrix4fv(location, gles.Sizei(count), transpose, &t[0])
 	case []mgl.Mat4:
 		gles.UniformMatrix4fv(location, gles.Sizei(count), transpose, &t[0][0])
 	default:
@@ -492,6 +629,13 @@ func (impl *GraphicsImpl) VertexAttribIPointer(dst uint32, size int32, ty graphi
 	// NO-OP
 }
 
+// VertexAttribDivisor modifies the rate at which generic vertex attributes
+// advance during instanced rendering
+// NOTE: not implemented in OpenGL ES 2
+func (impl *GraphicsImpl) VertexAttribDivisor(index uint32, divisor uint32) {
+	// NO-OP
+}
+
 // Viewport sets the viewport, an affine transformation that
 // normalizes device coordinates to window coordinates.
 func (impl *GraphicsImpl) Viewport(x, y, width, height int32) {
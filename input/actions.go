@@ -0,0 +1,305 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The input module maps physical inputs like keys, mouse buttons and axes and
+gamepad buttons and axes to named actions and axes. Applications query the
+actions once per frame instead of binding raw callbacks, and the bindings
+can be changed at runtime to support rebinding controls.
+
+The physical input state comes from a Source, which is implemented for each
+windowing backend (see the glfwinput module).
+
+*/
+
+package input
+
+// Device is the kind of physical input a Binding refers to.
+type Device int
+
+const (
+	// Key is a keyboard key; the code is the backend's key value.
+	Key Device = iota
+
+	// MouseButton is a mouse button; the code is the backend's button value.
+	MouseButton
+
+	// MouseAxis is a mouse axis; the code is one of the MouseAxis* constants.
+	MouseAxis
+
+	// GamepadButton is a gamepad button; the code is the raw button index,
+	// or one of the standard Gamepad* buttons if the source is a Gamepads.
+	GamepadButton
+
+	// GamepadAxis is a gamepad axis; the code is the raw axis index,
+	// or one of the standard Gamepad* axes if the source is a Gamepads.
+	GamepadAxis
+)
+
+const (
+	// MouseAxisX is the change in the cursor's X position for the frame.
+	MouseAxisX = iota
+
+	// MouseAxisY is the change in the cursor's Y position for the frame.
+	MouseAxisY
+
+	// MouseAxisScrollX is the horizontal scroll amount for the frame.
+	MouseAxisScrollX
+
+	// MouseAxisScrollY is the vertical scroll amount for the frame.
+	MouseAxisScrollY
+)
+
+const (
+	// DefaultAxisPressThreshold is how far an analog input has to go in the
+	// direction of its scale for an action bound to it to be down.
+	DefaultAxisPressThreshold = 0.5
+)
+
+// Source provides the state of the physical inputs for a windowing backend.
+type Source interface {
+	// Poll samples the per-frame inputs like the mouse movement and is
+	// called once at the start of ActionMap.Update.
+	Poll()
+
+	// IsKeyDown returns true if the key is held down.
+	IsKeyDown(key int) bool
+
+	// IsMouseButtonDown returns true if the mouse button is held down.
+	IsMouseButtonDown(button int) bool
+
+	// GetMouseAxis returns the value of a MouseAxis* axis for the frame.
+	GetMouseAxis(axis int) float32
+
+	// IsGamepadButtonDown returns true if the button on the gamepad is held down.
+	IsGamepadButtonDown(gamepad int, button int) bool
+
+	// GetGamepadAxis returns the value of the axis on the gamepad in [-1..1].
+	GetGamepadAxis(gamepad int, axis int) float32
+}
+
+// Binding maps one physical input to an action or axis.
+type Binding struct {
+	Device Device
+	Code   int
+
+	// Gamepad is the gamepad index for the gamepad devices.
+	Gamepad int
+
+	// Scale is multiplied against the input's value when used for an axis.
+	// Buttons and keys have a value of 1 while held down, so a pair of keys
+	// with scales of -1 and 1 makes a digital axis. It defaults to 1 when zero.
+	Scale float32
+}
+
+// KeyBinding returns a Binding for the key.
+func KeyBinding(key int, scale float32) Binding {
+	return Binding{Device: Key, Code: key, Scale: scale}
+}
+
+// MouseButtonBinding returns a Binding for the mouse button.
+func MouseButtonBinding(button int) Binding {
+	return Binding{Device: MouseButton, Code: button, Scale: 1.0}
+}
+
+// MouseAxisBinding returns a Binding for one of the MouseAxis* axes.
+func MouseAxisBinding(axis int, scale float32) Binding {
+	return Binding{Device: MouseAxis, Code: axis, Scale: scale}
+}
+
+// GamepadButtonBinding returns a Binding for a button on the gamepad.
+func GamepadButtonBinding(gamepad int, button int) Binding {
+	return Binding{Device: GamepadButton, Code: button, Gamepad: gamepad, Scale: 1.0}
+}
+
+// GamepadAxisBinding returns a Binding for an axis on the gamepad.
+func GamepadAxisBinding(gamepad int, axis int, scale float32) Binding {
+	return Binding{Device: GamepadAxis, Code: axis, Gamepad: gamepad, Scale: scale}
+}
+
+// value returns the scaled value of the binding's input.
+func (b *Binding) value(src Source) float32 {
+	scale := b.Scale
+	if scale == 0.0 {
+		scale = 1.0
+	}
+
+	switch b.Device {
+	case Key:
+		if src.IsKeyDown(b.Code) {
+			return scale
+		}
+	case MouseButton:
+		if src.IsMouseButtonDown(b.Code) {
+			return scale
+		}
+	case MouseAxis:
+		return src.GetMouseAxis(b.Code) * scale
+	case GamepadButton:
+		if src.IsGamepadButtonDown(b.Gamepad, b.Code) {
+			return scale
+		}
+	case GamepadAxis:
+		return src.GetGamepadAxis(b.Gamepad, b.Code) * scale
+	}
+	return 0.0
+}
+
+// isDown returns true if the binding's input counts as held down.
+func (b *Binding) isDown(src Source, threshold float32) bool {
+	switch b.Device {
+	case Key:
+		return src.IsKeyDown(b.Code)
+	case MouseButton:
+		return src.IsMouseButtonDown(b.Code)
+	case GamepadButton:
+		return src.IsGamepadButtonDown(b.Gamepad, b.Code)
+	}
+
+	// for the analog inputs, the sign of the scale picks the direction
+	return b.value(src) >= threshold
+}
+
+// action is the state of a named action.
+type action struct {
+	bindings []Binding
+	down     bool
+	wasDown  bool
+}
+
+// axis is the state of a named axis.
+type axis struct {
+	bindings []Binding
+	value    float32
+}
+
+// ActionMap maps bindings to named actions and axes and tracks their
+// state from frame to frame.
+type ActionMap struct {
+	// AxisPressThreshold is how far an analog input has to go in the
+	// direction of its scale for an action bound to it to be down.
+	AxisPressThreshold float32
+
+	source  Source
+	actions map[string]*action
+	axes    map[string]*axis
+}
+
+// NewActionMap creates a new action map reading input from the source.
+func NewActionMap(src Source) *ActionMap {
+	am := new(ActionMap)
+	am.AxisPressThreshold = DefaultAxisPressThreshold
+	am.source = src
+	am.actions = make(map[string]*action)
+	am.axes = make(map[string]*axis)
+	return am
+}
+
+// BindAction adds the bindings to the named action, creating the action if needed.
+func (am *ActionMap) BindAction(name string, bindings ...Binding) {
+	a, okay := am.actions[name]
+	if !okay {
+		a = new(action)
+		am.actions[name] = a
+	}
+	a.bindings = append(a.bindings, bindings...)
+}
+
+// RebindAction replaces all of the bindings of the named action.
+func (am *ActionMap) RebindAction(name string, bindings ...Binding) {
+	delete(am.actions, name)
+	am.BindAction(name, bindings...)
+}
+
+// GetActionBindings returns a copy of the bindings for the named action.
+func (am *ActionMap) GetActionBindings(name string) []Binding {
+	a, okay := am.actions[name]
+	if !okay {
+		return nil
+	}
+	return append([]Binding(nil), a.bindings...)
+}
+
+// BindAxis adds the bindings to the named axis, creating the axis if needed.
+func (am *ActionMap) BindAxis(name string, bindings ...Binding) {
+	ax, okay := am.axes[name]
+	if !okay {
+		ax = new(axis)
+		am.axes[name] = ax
+	}
+	ax.bindings = append(ax.bindings, bindings...)
+}
+
+// RebindAxis replaces all of the bindings of the named axis.
+func (am *ActionMap) RebindAxis(name string, bindings ...Binding) {
+	delete(am.axes, name)
+	am.BindAxis(name, bindings...)
+}
+
+// GetAxisBindings returns a copy of the bindings for the named axis.
+func (am *ActionMap) GetAxisBindings(name string) []Binding {
+	ax, okay := am.axes[name]
+	if !okay {
+		return nil
+	}
+	return append([]Binding(nil), ax.bindings...)
+}
+
+// Unbind removes the named action and axis.
+func (am *ActionMap) Unbind(name string) {
+	delete(am.actions, name)
+	delete(am.axes, name)
+}
+
+// Update polls the source and updates the state of all of the actions
+// and axes. It should be called once per frame after polling the window
+// for events.
+func (am *ActionMap) Update() {
+	am.source.Poll()
+
+	for _, a := range am.actions {
+		a.wasDown = a.down
+		a.down = false
+		for i := range a.bindings {
+			if a.bindings[i].isDown(am.source, am.AxisPressThreshold) {
+				a.down = true
+				break
+			}
+		}
+	}
+
+	for _, ax := range am.axes {
+		ax.value = 0.0
+		for i := range ax.bindings {
+			ax.value += ax.bindings[i].value(am.source)
+		}
+	}
+}
+
+// IsDown returns true if any input bound to the action is held down.
+func (am *ActionMap) IsDown(name string) bool {
+	a, okay := am.actions[name]
+	return okay && a.down
+}
+
+// Pressed returns true if the action went down this frame.
+func (am *ActionMap) Pressed(name string) bool {
+	a, okay := am.actions[name]
+	return okay && a.down && !a.wasDown
+}
+
+// Released returns true if the action went up this frame.
+func (am *ActionMap) Released(name string) bool {
+	a, okay := am.actions[name]
+	return okay && !a.down && a.wasDown
+}
+
+// Axis returns the sum of the scaled values of the inputs bound to the axis.
+func (am *ActionMap) Axis(name string) float32 {
+	ax, okay := am.axes[name]
+	if !okay {
+		return 0.0
+	}
+	return ax.value
+}
@@ -0,0 +1,346 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package input
+
+import (
+	"math"
+)
+
+// The standard gamepad buttons, laid out like an XInput controller.
+const (
+	GamepadA = iota
+	GamepadB
+	GamepadX
+	GamepadY
+	GamepadLeftBumper
+	GamepadRightBumper
+	GamepadBack
+	GamepadStart
+	GamepadGuide
+	GamepadLeftThumb
+	GamepadRightThumb
+	GamepadDPadUp
+	GamepadDPadRight
+	GamepadDPadDown
+	GamepadDPadLeft
+
+	// GamepadButtonCount is the number of standard gamepad buttons.
+	GamepadButtonCount
+)
+
+// The standard gamepad axes. The sticks are in [-1..1] with positive Y
+// pointing down and the triggers are in [0..1].
+const (
+	GamepadLeftX = iota
+	GamepadLeftY
+	GamepadRightX
+	GamepadRightY
+	GamepadLeftTrigger
+	GamepadRightTrigger
+
+	// GamepadAxisCount is the number of standard gamepad axes.
+	GamepadAxisCount
+)
+
+const (
+	// AnyGamepad can be used as the gamepad index in a Binding to read
+	// the input from all of the connected gamepads.
+	AnyGamepad = -1
+
+	// DefaultGamepadDeadZone is the default radial dead zone for the sticks.
+	DefaultGamepadDeadZone = 0.2
+
+	// DefaultTriggerDeadZone is the default dead zone for the triggers.
+	DefaultTriggerDeadZone = 0.05
+)
+
+// GamepadSource is implemented by a Source that can enumerate the
+// gamepads for hot-plug detection.
+type GamepadSource interface {
+	// MaxGamepads returns the number of gamepad slots the backend supports.
+	MaxGamepads() int
+
+	// IsGamepadPresent returns true if a gamepad is connected in the slot.
+	IsGamepadPresent(gamepad int) bool
+
+	// GetGamepadName returns the name the device reports for the gamepad.
+	GetGamepadName(gamepad int) string
+}
+
+// GamepadMapping maps the standard buttons and axes to the raw button and
+// axis indexes the backend reports for a type of gamepad. A raw index of
+// -1 means the gamepad doesn't have the input.
+type GamepadMapping struct {
+	Buttons [GamepadButtonCount]int
+	Axes    [GamepadAxisCount]int
+
+	// InvertY flips the Y axes of the sticks for devices reporting up as positive.
+	InvertY bool
+
+	// SignedTriggers indicates that the triggers are reported in [-1..1]
+	// with -1 at rest, instead of [0..1].
+	SignedTriggers bool
+}
+
+// DefaultGamepadMapping is the mapping used for gamepads without a
+// mapping of their own. It matches how an XInput controller is reported.
+var DefaultGamepadMapping = GamepadMapping{
+	Buttons: [GamepadButtonCount]int{
+		GamepadA:           0,
+		GamepadB:           1,
+		GamepadX:           2,
+		GamepadY:           3,
+		GamepadLeftBumper:  4,
+		GamepadRightBumper: 5,
+		GamepadBack:        6,
+		GamepadStart:       7,
+		GamepadGuide:       -1,
+		GamepadLeftThumb:   8,
+		GamepadRightThumb:  9,
+		GamepadDPadUp:      10,
+		GamepadDPadRight:   11,
+		GamepadDPadDown:    12,
+		GamepadDPadLeft:    13,
+	},
+	Axes: [GamepadAxisCount]int{
+		GamepadLeftX:        0,
+		GamepadLeftY:        1,
+		GamepadRightX:       2,
+		GamepadRightY:       3,
+		GamepadLeftTrigger:  4,
+		GamepadRightTrigger: 5,
+	},
+	SignedTriggers: true,
+}
+
+// GamepadConnectedCallback is the type of the function called when a
+// gamepad is connected.
+type GamepadConnectedCallback func(gamepad int, name string)
+
+// GamepadDisconnectedCallback is the type of the function called when a
+// gamepad is disconnected.
+type GamepadDisconnectedCallback func(gamepad int)
+
+// Gamepads wraps a Source so that the gamepad buttons and axes are read
+// through standard mappings with dead zones applied. Keyboard and mouse
+// input is passed through, so it can be handed to NewActionMap in place
+// of the Source and the gamepad bindings can use the standard Gamepad*
+// buttons and axes.
+//
+// If the Source is a GamepadSource, connecting and disconnecting gamepads
+// is detected in Poll.
+type Gamepads struct {
+	// DeadZone is the radial dead zone applied to each stick.
+	DeadZone float32
+
+	// TriggerDeadZone is the dead zone applied to the triggers.
+	TriggerDeadZone float32
+
+	// Mappings are the gamepad mappings by the name the device reports.
+	Mappings map[string]*GamepadMapping
+
+	// OnConnected is called when a gamepad is connected, including the
+	// gamepads already connected on the first Poll.
+	OnConnected GamepadConnectedCallback
+
+	// OnDisconnected is called when a gamepad is disconnected.
+	OnDisconnected GamepadDisconnectedCallback
+
+	source    Source
+	connected []bool
+	mappings  []*GamepadMapping
+}
+
+// NewGamepads wraps the source to provide standard gamepad input.
+func NewGamepads(src Source) *Gamepads {
+	gp := new(Gamepads)
+	gp.DeadZone = DefaultGamepadDeadZone
+	gp.TriggerDeadZone = DefaultTriggerDeadZone
+	gp.Mappings = make(map[string]*GamepadMapping)
+	gp.source = src
+	return gp
+}
+
+// Connected returns the indexes of the connected gamepads as of the last Poll.
+func (gp *Gamepads) Connected() []int {
+	pads := make([]int, 0, len(gp.connected))
+	for i, c := range gp.connected {
+		if c {
+			pads = append(pads, i)
+		}
+	}
+	return pads
+}
+
+// IsConnected returns true if the gamepad was connected as of the last Poll.
+func (gp *Gamepads) IsConnected(gamepad int) bool {
+	return gamepad >= 0 && gamepad < len(gp.connected) && gp.connected[gamepad]
+}
+
+// GetName returns the name the gamepad reports or an empty string if the
+// source can't enumerate gamepads.
+func (gp *Gamepads) GetName(gamepad int) string {
+	enum, okay := gp.source.(GamepadSource)
+	if !okay {
+		return ""
+	}
+	return enum.GetGamepadName(gamepad)
+}
+
+// Poll polls the wrapped source and checks for connected and disconnected gamepads.
+func (gp *Gamepads) Poll() {
+	gp.source.Poll()
+
+	enum, okay := gp.source.(GamepadSource)
+	if !okay {
+		return
+	}
+
+	max := enum.MaxGamepads()
+	if len(gp.connected) != max {
+		gp.connected = make([]bool, max)
+		gp.mappings = make([]*GamepadMapping, max)
+	}
+
+	for i := 0; i < max; i++ {
+		present := enum.IsGamepadPresent(i)
+		if present == gp.connected[i] {
+			continue
+		}
+		gp.connected[i] = present
+
+		if present {
+			name := enum.GetGamepadName(i)
+			gp.mappings[i] = gp.Mappings[name]
+			if gp.OnConnected != nil {
+				gp.OnConnected(i, name)
+			}
+		} else {
+			gp.mappings[i] = nil
+			if gp.OnDisconnected != nil {
+				gp.OnDisconnected(i)
+			}
+		}
+	}
+}
+
+// IsKeyDown passes the query through to the wrapped source.
+func (gp *Gamepads) IsKeyDown(key int) bool {
+	return gp.source.IsKeyDown(key)
+}
+
+// IsMouseButtonDown passes the query through to the wrapped source.
+func (gp *Gamepads) IsMouseButtonDown(button int) bool {
+	return gp.source.IsMouseButtonDown(button)
+}
+
+// GetMouseAxis passes the query through to the wrapped source.
+func (gp *Gamepads) GetMouseAxis(axis int) float32 {
+	return gp.source.GetMouseAxis(axis)
+}
+
+// IsGamepadButtonDown returns true if the standard button is held down on
+// the gamepad, or on any gamepad if the index is AnyGamepad.
+func (gp *Gamepads) IsGamepadButtonDown(gamepad int, button int) bool {
+	if button < 0 || button >= GamepadButtonCount {
+		return false
+	}
+	if gamepad == AnyGamepad {
+		for _, i := range gp.Connected() {
+			if gp.IsGamepadButtonDown(i, button) {
+				return true
+			}
+		}
+		return false
+	}
+
+	raw := gp.getMapping(gamepad).Buttons[button]
+	return raw >= 0 && gp.source.IsGamepadButtonDown(gamepad, raw)
+}
+
+// GetGamepadAxis returns the value of the standard axis on the gamepad with
+// the dead zone applied. For AnyGamepad, the value furthest from rest is used.
+func (gp *Gamepads) GetGamepadAxis(gamepad int, axis int) float32 {
+	if axis < 0 || axis >= GamepadAxisCount {
+		return 0.0
+	}
+	if gamepad == AnyGamepad {
+		var best float32
+		for _, i := range gp.Connected() {
+			v := gp.GetGamepadAxis(i, axis)
+			if math.Abs(float64(v)) > math.Abs(float64(best)) {
+				best = v
+			}
+		}
+		return best
+	}
+
+	m := gp.getMapping(gamepad)
+	switch axis {
+	case GamepadLeftTrigger, GamepadRightTrigger:
+		v := gp.rawAxis(gamepad, m, axis)
+		if m.SignedTriggers {
+			v = (v + 1.0) * 0.5
+		}
+		return applyDeadZone(v, gp.TriggerDeadZone)
+	case GamepadLeftX, GamepadRightX:
+		x, _ := gp.getStick(gamepad, m, axis, axis+1)
+		return x
+	default:
+		_, y := gp.getStick(gamepad, m, axis-1, axis)
+		return y
+	}
+}
+
+// getMapping returns the mapping for the gamepad.
+func (gp *Gamepads) getMapping(gamepad int) *GamepadMapping {
+	if gamepad >= 0 && gamepad < len(gp.mappings) && gp.mappings[gamepad] != nil {
+		return gp.mappings[gamepad]
+	}
+	return &DefaultGamepadMapping
+}
+
+// rawAxis reads the raw value for the standard axis.
+func (gp *Gamepads) rawAxis(gamepad int, m *GamepadMapping, axis int) float32 {
+	raw := m.Axes[axis]
+	if raw < 0 {
+		if (axis == GamepadLeftTrigger || axis == GamepadRightTrigger) && m.SignedTriggers {
+			return -1.0
+		}
+		return 0.0
+	}
+	return gp.source.GetGamepadAxis(gamepad, raw)
+}
+
+// getStick reads both axes of a stick and applies the radial dead zone,
+// rescaling the rest of the range so that the output starts at zero.
+func (gp *Gamepads) getStick(gamepad int, m *GamepadMapping, axisX, axisY int) (float32, float32) {
+	x := gp.rawAxis(gamepad, m, axisX)
+	y := gp.rawAxis(gamepad, m, axisY)
+	if m.InvertY {
+		y = -y
+	}
+
+	mag := float32(math.Sqrt(float64(x*x + y*y)))
+	if mag <= gp.DeadZone || mag == 0.0 {
+		return 0.0, 0.0
+	}
+	scaled := (mag - gp.DeadZone) / (1.0 - gp.DeadZone)
+	if scaled > 1.0 {
+		scaled = 1.0
+	}
+	return x / mag * scaled, y / mag * scaled
+}
+
+// applyDeadZone zeroes values within the dead zone and rescales the rest.
+func applyDeadZone(v float32, deadZone float32) float32 {
+	if v <= deadZone {
+		return 0.0
+	}
+	v = (v - deadZone) / (1.0 - deadZone)
+	if v > 1.0 {
+		v = 1.0
+	}
+	return v
+}
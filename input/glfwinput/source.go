@@ -0,0 +1,132 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package glfwinput
+
+import (
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/tbogdala/fizzle/input"
+)
+
+// Source implements input.Source for a GLFW window. Key codes are
+// glfw.Key values, mouse button codes are glfw.MouseButton values and
+// gamepad indexes are glfw.Joystick values.
+type Source struct {
+	// window is the GLFW window to poll for input
+	window *glfw.Window
+
+	lastCursorX  float64
+	lastCursorY  float64
+	cursorDeltaX float32
+	cursorDeltaY float32
+	hasCursor    bool
+
+	// scroll accumulates the scroll events since the last Poll
+	scrollX      float64
+	scrollY      float64
+	frameScrollX float32
+	frameScrollY float32
+
+	// prevScrollCallback is the previously bound scroll callback from glfw.
+	// This is used to chain input.
+	prevScrollCallback glfw.ScrollCallback
+}
+
+// NewSource creates a new input source for the window. SetupCallbacks
+// must be called for the scroll axes to work.
+func NewSource(w *glfw.Window) *Source {
+	src := new(Source)
+	src.window = w
+	return src
+}
+
+// SetupCallbacks sets the scroll callback handler for the window.
+func (src *Source) SetupCallbacks() {
+	src.prevScrollCallback = src.window.SetScrollCallback(func(w *glfw.Window, xoff float64, yoff float64) {
+		src.scrollX += xoff
+		src.scrollY += yoff
+
+		// chain the event handler to the previous one if it existed.
+		if src.prevScrollCallback != nil {
+			src.prevScrollCallback(w, xoff, yoff)
+		}
+	})
+}
+
+// Poll samples the cursor movement and scrolling since the last Poll.
+func (src *Source) Poll() {
+	x, y := src.window.GetCursorPos()
+	if src.hasCursor {
+		src.cursorDeltaX = float32(x - src.lastCursorX)
+		src.cursorDeltaY = float32(y - src.lastCursorY)
+	}
+	src.lastCursorX, src.lastCursorY = x, y
+	src.hasCursor = true
+
+	src.frameScrollX = float32(src.scrollX)
+	src.frameScrollY = float32(src.scrollY)
+	src.scrollX, src.scrollY = 0.0, 0.0
+}
+
+// IsKeyDown returns true if the glfw.Key is held down.
+func (src *Source) IsKeyDown(key int) bool {
+	return src.window.GetKey(glfw.Key(key)) == glfw.Press
+}
+
+// IsMouseButtonDown returns true if the glfw.MouseButton is held down.
+func (src *Source) IsMouseButtonDown(button int) bool {
+	return src.window.GetMouseButton(glfw.MouseButton(button)) == glfw.Press
+}
+
+// GetMouseAxis returns the value of the input.MouseAxis* axis for the frame.
+func (src *Source) GetMouseAxis(axis int) float32 {
+	switch axis {
+	case input.MouseAxisX:
+		return src.cursorDeltaX
+	case input.MouseAxisY:
+		return src.cursorDeltaY
+	case input.MouseAxisScrollX:
+		return src.frameScrollX
+	case input.MouseAxisScrollY:
+		return src.frameScrollY
+	}
+	return 0.0
+}
+
+// IsGamepadButtonDown returns true if the button on the glfw.Joystick is held down.
+func (src *Source) IsGamepadButtonDown(gamepad int, button int) bool {
+	joy := glfw.Joystick(gamepad)
+	if !glfw.JoystickPresent(joy) {
+		return false
+	}
+	buttons := glfw.GetJoystickButtons(joy)
+	return button >= 0 && button < len(buttons) && buttons[button] > 0
+}
+
+// GetGamepadAxis returns the value of the axis on the glfw.Joystick.
+func (src *Source) GetGamepadAxis(gamepad int, axis int) float32 {
+	joy := glfw.Joystick(gamepad)
+	if !glfw.JoystickPresent(joy) {
+		return 0.0
+	}
+	axes := glfw.GetJoystickAxes(joy)
+	if axis < 0 || axis >= len(axes) {
+		return 0.0
+	}
+	return axes[axis]
+}
+
+// MaxGamepads returns the number of joystick slots in GLFW.
+func (src *Source) MaxGamepads() int {
+	return int(glfw.JoystickLast) + 1
+}
+
+// IsGamepadPresent returns true if the glfw.Joystick is connected.
+func (src *Source) IsGamepadPresent(gamepad int) bool {
+	return glfw.JoystickPresent(glfw.Joystick(gamepad))
+}
+
+// GetGamepadName returns the name of the glfw.Joystick.
+func (src *Source) GetGamepadName(gamepad int) string {
+	return glfw.GetJoystickName(glfw.Joystick(gamepad))
+}
@@ -0,0 +1,77 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package sdlinput
+
+import (
+	"github.com/tbogdala/fizzle/window/sdlwindow"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// KeyCallback is the type of the function that gets called for the key
+// callback events.
+type KeyCallback func()
+
+// KeyboardModel is the way to bind keys to events. It mirrors the
+// glfwinput KeyboardModel for windows created with SDL2.
+type KeyboardModel struct {
+	// KeyTriggerBindings are the functions to call when the given key is pressed
+	KeyTriggerBindings map[sdl.Keycode]KeyCallback
+
+	// KeyBindings are the functions to call when the given key is considered
+	// 'pressed' when the KeyboardModel runs CheckKeyPresses.
+	KeyBindings map[sdl.Keycode]KeyCallback
+
+	// window is the SDL window to get key events from
+	window *sdlwindow.Window
+}
+
+// NewKeyboardModel returns a newly created keyboard model object
+func NewKeyboardModel(w *sdlwindow.Window) *KeyboardModel {
+	kb := new(KeyboardModel)
+	kb.KeyTriggerBindings = make(map[sdl.Keycode]KeyCallback)
+	kb.KeyBindings = make(map[sdl.Keycode]KeyCallback)
+	kb.window = w
+	return kb
+}
+
+// SetupCallbacks adds the keyboard model's event handler to the window.
+func (kb *KeyboardModel) SetupCallbacks() {
+	kb.window.AddEventHandler(kb.handleEvent)
+}
+
+// handleEvent calls the trigger bindings for key presses; key repeats are ignored.
+func (kb *KeyboardModel) handleEvent(event sdl.Event) {
+	e, okay := event.(*sdl.KeyDownEvent)
+	if !okay || e.Repeat != 0 {
+		return
+	}
+
+	cb, okay := kb.KeyTriggerBindings[e.Keysym.Sym]
+	if okay && cb != nil {
+		cb()
+	}
+}
+
+// CheckKeyPresses runs through all of the KeyBindings and checks to see if that
+// key is held down -- if it is, then the callback is invoked.
+func (kb *KeyboardModel) CheckKeyPresses() {
+	state := sdl.GetKeyboardState()
+	for key, cb := range kb.KeyBindings {
+		scancode := int(sdl.GetScancodeFromKey(key))
+		if scancode < len(state) && state[scancode] != 0 && cb != nil {
+			cb()
+		}
+	}
+}
+
+// Bind binds a key press event with a callback that will get called when
+// CheckKeyPresses finds the key to be pressed.
+func (kb *KeyboardModel) Bind(key sdl.Keycode, f KeyCallback) {
+	kb.KeyBindings[key] = f
+}
+
+// BindTrigger binds a key event that gets called when the key is pressed once.
+func (kb *KeyboardModel) BindTrigger(key sdl.Keycode, f KeyCallback) {
+	kb.KeyTriggerBindings[key] = f
+}
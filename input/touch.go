@@ -0,0 +1,334 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package input
+
+import (
+	"math"
+)
+
+// TouchPhase is the stage of a touch's life that a TouchEvent reports.
+type TouchPhase int
+
+const (
+	// TouchBegan is reported when a finger touches the screen.
+	TouchBegan TouchPhase = iota
+
+	// TouchMoved is reported when a finger moves on the screen.
+	TouchMoved
+
+	// TouchEnded is reported when a finger is lifted.
+	TouchEnded
+
+	// TouchCancelled is reported when the system cancels a touch.
+	TouchCancelled
+)
+
+// GestureType is the kind of gesture recognized from the touches.
+type GestureType int
+
+const (
+	// GestureTap is a short touch that didn't move.
+	GestureTap GestureType = iota
+
+	// GestureDrag is a single touch moving across the screen.
+	GestureDrag
+
+	// GesturePinch is two touches moving closer together or further apart.
+	GesturePinch
+)
+
+// GesturePhase is the stage of a continuous gesture. Taps are always GestureEnded.
+type GesturePhase int
+
+const (
+	// GestureBegan is reported when a drag or pinch is first recognized.
+	GestureBegan GesturePhase = iota
+
+	// GestureChanged is reported when a drag or pinch updates.
+	GestureChanged
+
+	// GestureEnded is reported when a gesture finishes.
+	GestureEnded
+)
+
+const (
+	// DefaultTapMaxDuration is the default longest time in seconds a touch
+	// can last and still be a tap.
+	DefaultTapMaxDuration = 0.3
+
+	// DefaultTapMaxDistance is the default furthest distance in pixels a
+	// touch can move and still be a tap; moving further starts a drag.
+	DefaultTapMaxDistance = 10.0
+)
+
+// TouchEvent is a touch reported by the platform backend.
+type TouchEvent struct {
+	// ID identifies the finger for the life of the touch.
+	ID    int
+	Phase TouchPhase
+
+	// X and Y are the position of the touch in window pixels.
+	X float32
+	Y float32
+}
+
+// TouchPoint is the state of an active touch.
+type TouchPoint struct {
+	ID     int
+	X      float32
+	Y      float32
+	StartX float32
+	StartY float32
+
+	// StartTime is the time in seconds the touch began.
+	StartTime float64
+}
+
+// Gesture is a gesture recognized by the TouchTracker.
+type Gesture struct {
+	Type  GestureType
+	Phase GesturePhase
+
+	// X and Y are the position of the gesture; the center of the two
+	// touches for a pinch.
+	X float32
+	Y float32
+
+	// DeltaX and DeltaY are how far the gesture moved since the last update.
+	DeltaX float32
+	DeltaY float32
+
+	// Scale is the distance between the two touches of a pinch relative
+	// to the distance when the pinch began.
+	Scale float32
+}
+
+// GestureCallback is the type of the function called for recognized gestures.
+type GestureCallback func(g Gesture)
+
+// PointerCallback is the type of the function called when the primary
+// touch changes; this allows UI code written for a mouse to be driven by touch.
+type PointerCallback func(x, y float32, down bool)
+
+// TouchTracker tracks the active touches reported by a platform backend and
+// recognizes tap, drag and pinch gestures from them.
+type TouchTracker struct {
+	// TapMaxDuration is the longest time in seconds a touch can last and still be a tap.
+	TapMaxDuration float64
+
+	// TapMaxDistance is the furthest a touch can move and still be a tap.
+	TapMaxDistance float32
+
+	// OnGesture is called for every gesture recognized.
+	OnGesture GestureCallback
+
+	// OnPointer is called when the primary touch, the first finger down,
+	// begins, moves or ends.
+	OnPointer PointerCallback
+
+	touches   []TouchPoint
+	primaryID int
+	hasPrim   bool
+
+	dragging       bool
+	tapCandidate   bool
+	pinching       bool
+	pinchStartDist float32
+	lastX          float32
+	lastY          float32
+}
+
+// NewTouchTracker creates a new touch tracker with the default tap thresholds.
+func NewTouchTracker() *TouchTracker {
+	tt := new(TouchTracker)
+	tt.TapMaxDuration = DefaultTapMaxDuration
+	tt.TapMaxDistance = DefaultTapMaxDistance
+	tt.touches = make([]TouchPoint, 0, 4)
+	return tt
+}
+
+// GetTouches returns the active touches. The slice is only valid until
+// the next call to HandleTouch.
+func (tt *TouchTracker) GetTouches() []TouchPoint {
+	return tt.touches
+}
+
+// GetPointer returns the position of the primary touch and whether or not
+// it's down.
+func (tt *TouchTracker) GetPointer() (float32, float32, bool) {
+	if !tt.hasPrim {
+		return tt.lastX, tt.lastY, false
+	}
+	p := tt.find(tt.primaryID)
+	return p.X, p.Y, true
+}
+
+// HandleTouch updates the touches and gestures with an event from the
+// platform backend. The time is in seconds and is used to recognize taps.
+func (tt *TouchTracker) HandleTouch(ev TouchEvent, time float64) {
+	switch ev.Phase {
+	case TouchBegan:
+		tt.touches = append(tt.touches, TouchPoint{ID: ev.ID, X: ev.X, Y: ev.Y, StartX: ev.X, StartY: ev.Y, StartTime: time})
+		tt.touchBegan(ev)
+	case TouchMoved:
+		p := tt.find(ev.ID)
+		if p == nil {
+			return
+		}
+		prevX, prevY := p.X, p.Y
+		p.X, p.Y = ev.X, ev.Y
+		tt.touchMoved(p, prevX, prevY)
+	case TouchEnded, TouchCancelled:
+		p := tt.find(ev.ID)
+		if p == nil {
+			return
+		}
+		p.X, p.Y = ev.X, ev.Y
+		tt.touchEnded(*p, ev.Phase == TouchCancelled, time)
+		tt.remove(ev.ID)
+	}
+}
+
+// touchBegan starts tracking a primary touch or switches to a pinch when
+// a second finger goes down.
+func (tt *TouchTracker) touchBegan(ev TouchEvent) {
+	switch len(tt.touches) {
+	case 1:
+		tt.primaryID = ev.ID
+		tt.hasPrim = true
+		tt.tapCandidate = true
+		tt.pointer(ev.X, ev.Y, true)
+	case 2:
+		tt.tapCandidate = false
+		if tt.dragging {
+			tt.dragging = false
+			tt.gesture(Gesture{Type: GestureDrag, Phase: GestureEnded, X: tt.touches[0].X, Y: tt.touches[0].Y})
+		}
+		tt.pinching = true
+		tt.pinchStartDist = touchDistance(&tt.touches[0], &tt.touches[1])
+		x, y := touchCenter(&tt.touches[0], &tt.touches[1])
+		tt.gesture(Gesture{Type: GesturePinch, Phase: GestureBegan, X: x, Y: y, Scale: 1.0})
+	default:
+		tt.tapCandidate = false
+	}
+}
+
+// touchMoved updates the drag or pinch for a moved touch.
+func (tt *TouchTracker) touchMoved(p *TouchPoint, prevX, prevY float32) {
+	if tt.hasPrim && p.ID == tt.primaryID {
+		tt.pointer(p.X, p.Y, true)
+	}
+
+	if tt.pinching && len(tt.touches) >= 2 {
+		prevCX, prevCY := touchCenter(&tt.touches[0], &tt.touches[1])
+		if p.ID == tt.touches[0].ID || p.ID == tt.touches[1].ID {
+			// work out the previous center with the moved touch's old position
+			prevCX -= (p.X - prevX) * 0.5
+			prevCY -= (p.Y - prevY) * 0.5
+			x, y := touchCenter(&tt.touches[0], &tt.touches[1])
+			scale := float32(1.0)
+			if tt.pinchStartDist > 0.0 {
+				scale = touchDistance(&tt.touches[0], &tt.touches[1]) / tt.pinchStartDist
+			}
+			tt.gesture(Gesture{Type: GesturePinch, Phase: GestureChanged, X: x, Y: y, DeltaX: x - prevCX, DeltaY: y - prevCY, Scale: scale})
+		}
+		return
+	}
+
+	if len(tt.touches) != 1 {
+		return
+	}
+
+	if !tt.dragging {
+		dx := p.X - p.StartX
+		dy := p.Y - p.StartY
+		if float32(math.Sqrt(float64(dx*dx+dy*dy))) <= tt.TapMaxDistance {
+			return
+		}
+		tt.dragging = true
+		tt.tapCandidate = false
+		tt.gesture(Gesture{Type: GestureDrag, Phase: GestureBegan, X: p.X, Y: p.Y, DeltaX: p.X - p.StartX, DeltaY: p.Y - p.StartY})
+		return
+	}
+	tt.gesture(Gesture{Type: GestureDrag, Phase: GestureChanged, X: p.X, Y: p.Y, DeltaX: p.X - prevX, DeltaY: p.Y - prevY})
+}
+
+// touchEnded finishes any gesture the touch was part of.
+func (tt *TouchTracker) touchEnded(p TouchPoint, cancelled bool, time float64) {
+	if tt.hasPrim && p.ID == tt.primaryID {
+		tt.hasPrim = false
+		tt.lastX, tt.lastY = p.X, p.Y
+		tt.pointer(p.X, p.Y, false)
+	}
+
+	if tt.pinching {
+		if len(tt.touches) <= 2 {
+			tt.pinching = false
+			x, y := touchCenter(&tt.touches[0], &tt.touches[len(tt.touches)-1])
+			scale := float32(1.0)
+			if tt.pinchStartDist > 0.0 && len(tt.touches) == 2 {
+				scale = touchDistance(&tt.touches[0], &tt.touches[1]) / tt.pinchStartDist
+			}
+			tt.gesture(Gesture{Type: GesturePinch, Phase: GestureEnded, X: x, Y: y, Scale: scale})
+		}
+		return
+	}
+
+	if tt.dragging {
+		tt.dragging = false
+		tt.gesture(Gesture{Type: GestureDrag, Phase: GestureEnded, X: p.X, Y: p.Y})
+		return
+	}
+
+	if tt.tapCandidate && !cancelled && len(tt.touches) == 1 && time-p.StartTime <= tt.TapMaxDuration {
+		tt.gesture(Gesture{Type: GestureTap, Phase: GestureEnded, X: p.X, Y: p.Y})
+	}
+	tt.tapCandidate = false
+}
+
+// find returns the active touch with the ID or nil if not found.
+func (tt *TouchTracker) find(id int) *TouchPoint {
+	for i := range tt.touches {
+		if tt.touches[i].ID == id {
+			return &tt.touches[i]
+		}
+	}
+	return nil
+}
+
+// remove stops tracking the touch with the ID.
+func (tt *TouchTracker) remove(id int) {
+	for i := range tt.touches {
+		if tt.touches[i].ID == id {
+			tt.touches = append(tt.touches[:i], tt.touches[i+1:]...)
+			return
+		}
+	}
+}
+
+// gesture calls the gesture callback if one is set.
+func (tt *TouchTracker) gesture(g Gesture) {
+	if tt.OnGesture != nil {
+		tt.OnGesture(g)
+	}
+}
+
+// pointer calls the pointer callback if one is set.
+func (tt *TouchTracker) pointer(x, y float32, down bool) {
+	if tt.OnPointer != nil {
+		tt.OnPointer(x, y, down)
+	}
+}
+
+// touchDistance returns the distance between two touches.
+func touchDistance(a, b *TouchPoint) float32 {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return float32(math.Sqrt(float64(dx*dx + dy*dy)))
+}
+
+// touchCenter returns the point halfway between two touches.
+func touchCenter(a, b *TouchPoint) (float32, float32) {
+	return (a.X + b.X) * 0.5, (a.Y + b.Y) * 0.5
+}
@@ -0,0 +1,253 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The jobs module is a small work-stealing job scheduler for spreading engine
+work such as culling, animation sampling, particle updates and asset
+decoding across all of the available cores.
+
+Jobs are submitted as part of a Group, which is typically created once per
+frame for a batch of related work, and then the Group is waited on. Jobs must
+not make any graphics calls since they do not run on the thread owning the
+GL context.
+
+*/
+
+package jobs
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// job is a unit of work tracked by the group it was submitted in.
+type job struct {
+	fn    func()
+	group *Group
+}
+
+// workerQueue is a double ended job queue owned by a worker. The owner
+// pops from the back while other workers steal from the front.
+type workerQueue struct {
+	lock sync.Mutex
+	jobs []job
+}
+
+// push adds a job to the back of the queue.
+func (q *workerQueue) push(j job) {
+	q.lock.Lock()
+	q.jobs = append(q.jobs, j)
+	q.lock.Unlock()
+}
+
+// pop removes the most recently pushed job.
+func (q *workerQueue) pop() (job, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.jobs) == 0 {
+		return job{}, false
+	}
+	last := len(q.jobs) - 1
+	j := q.jobs[last]
+	q.jobs[last] = job{}
+	q.jobs = q.jobs[:last]
+	return j, true
+}
+
+// steal removes the oldest job in the queue.
+func (q *workerQueue) steal() (job, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.jobs) == 0 {
+		return job{}, false
+	}
+	j := q.jobs[0]
+	q.jobs[0] = job{}
+	q.jobs = q.jobs[1:]
+	return j, true
+}
+
+// Scheduler runs submitted jobs on a fixed set of worker goroutines.
+// Each worker has its own queue and steals from the other workers when
+// its queue runs dry.
+type Scheduler struct {
+	queues []*workerQueue
+
+	// pending is the number of jobs submitted but not yet taken by a worker
+	pending int64
+
+	// nextQueue is used to distribute submissions round-robin
+	nextQueue uint32
+
+	lock    sync.Mutex
+	wake    *sync.Cond
+	stopped bool
+	workers sync.WaitGroup
+}
+
+// NewScheduler creates a new Scheduler and starts the worker goroutines.
+// If workers is <= 0 then one worker per CPU is started.
+func NewScheduler(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	s := new(Scheduler)
+	s.wake = sync.NewCond(&s.lock)
+	s.queues = make([]*workerQueue, workers)
+	for i := range s.queues {
+		s.queues[i] = new(workerQueue)
+	}
+
+	s.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.workerLoop(i)
+	}
+	return s
+}
+
+// WorkerCount returns the number of worker goroutines.
+func (s *Scheduler) WorkerCount() int {
+	return len(s.queues)
+}
+
+// Stop shuts down the worker goroutines once the queued jobs have been run.
+func (s *Scheduler) Stop() {
+	s.lock.Lock()
+	s.stopped = true
+	s.wake.Broadcast()
+	s.lock.Unlock()
+	s.workers.Wait()
+}
+
+// NewGroup creates a new job group for the scheduler.
+func (s *Scheduler) NewGroup() *Group {
+	g := new(Group)
+	g.owner = s
+	return g
+}
+
+// ParallelFor splits the range [0, count) into batches of at most batchSize
+// and calls fn for each batch on the workers, returning once all of the
+// batches have been run. If batchSize is <= 0 the range is divided evenly
+// between the workers.
+func (s *Scheduler) ParallelFor(count int, batchSize int, fn func(start, end int)) {
+	if count <= 0 {
+		return
+	}
+	if batchSize <= 0 {
+		batchSize = (count + len(s.queues) - 1) / len(s.queues)
+	}
+
+	g := s.NewGroup()
+	for start := 0; start < count; start += batchSize {
+		end := start + batchSize
+		if end > count {
+			end = count
+		}
+		first, last := start, end
+		g.Go(func() { fn(first, last) })
+	}
+	g.Wait()
+}
+
+// submit queues up the job on one of the workers.
+func (s *Scheduler) submit(j job) {
+	qi := atomic.AddUint32(&s.nextQueue, 1) % uint32(len(s.queues))
+
+	s.lock.Lock()
+	atomic.AddInt64(&s.pending, 1)
+	s.queues[qi].push(j)
+	s.wake.Signal()
+	s.lock.Unlock()
+}
+
+// tryGet takes a job from the preferred queue or steals one from another.
+func (s *Scheduler) tryGet(preferred int) (job, bool) {
+	if preferred >= 0 {
+		if j, ok := s.queues[preferred].pop(); ok {
+			atomic.AddInt64(&s.pending, -1)
+			return j, true
+		}
+	}
+
+	start := preferred + 1
+	for i := 0; i < len(s.queues); i++ {
+		qi := (start + i) % len(s.queues)
+		if qi == preferred {
+			continue
+		}
+		if j, ok := s.queues[qi].steal(); ok {
+			atomic.AddInt64(&s.pending, -1)
+			return j, true
+		}
+	}
+
+	return job{}, false
+}
+
+// workerLoop runs jobs until the scheduler is stopped.
+func (s *Scheduler) workerLoop(index int) {
+	defer s.workers.Done()
+	for {
+		if j, ok := s.tryGet(index); ok {
+			j.run()
+			continue
+		}
+
+		s.lock.Lock()
+		for atomic.LoadInt64(&s.pending) == 0 && !s.stopped {
+			s.wake.Wait()
+		}
+		stopped := s.stopped && atomic.LoadInt64(&s.pending) == 0
+		s.lock.Unlock()
+		if stopped {
+			return
+		}
+	}
+}
+
+// run executes the job and marks it complete in its group.
+func (j job) run() {
+	defer j.group.finish()
+	j.fn()
+}
+
+// Group tracks a set of related jobs, usually the work for one frame,
+// so that they can be waited on together.
+type Group struct {
+	owner     *Scheduler
+	remaining int64
+
+	// done is waited on once there are no queued jobs left to help with
+	done sync.WaitGroup
+}
+
+// Go submits the function to be run as a job in the group.
+func (g *Group) Go(fn func()) {
+	atomic.AddInt64(&g.remaining, 1)
+	g.done.Add(1)
+	g.owner.submit(job{fn: fn, group: g})
+}
+
+// finish marks one of the group's jobs as complete.
+func (g *Group) finish() {
+	atomic.AddInt64(&g.remaining, -1)
+	g.done.Done()
+}
+
+// Wait blocks until all of the jobs in the group have been run. The calling
+// goroutine helps by running queued jobs while it waits and sleeps once
+// the only jobs left are already running on the workers.
+func (g *Group) Wait() {
+	for atomic.LoadInt64(&g.remaining) > 0 {
+		j, ok := g.owner.tryGet(-1)
+		if !ok {
+			break
+		}
+		j.run()
+	}
+	g.done.Wait()
+}
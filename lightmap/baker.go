@@ -0,0 +1,471 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package lightmap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// Baker bakes the lighting of static Renderables into lightmaps. The
+// settings are used when Renderables are added and baked, so they should be
+// set before calling Add.
+type Baker struct {
+	// Lights are the static lights to bake.
+	Lights []Light
+
+	// TexelsPerUnit is the lightmap resolution for every unit of length of
+	// the surfaces, in world space.
+	TexelsPerUnit float32
+
+	// MaxSize is the largest width and height of a Renderable's lightmap.
+	MaxSize int
+
+	// Padding is the number of texels left around each chart of the
+	// lightmap so that filtering doesn't bleed light between them.
+	Padding int
+
+	// Bounces is the number of times indirect light is bounced around the
+	// scene; zero only bakes direct light.
+	Bounces int
+
+	// Samples is the number of rays traced from each texel per bounce.
+	Samples int
+
+	// Ambient is the light of the sky that rays escaping the scene gather
+	// during bounces; when Bounces is zero it is added evenly instead.
+	Ambient mgl.Vec3
+
+	// Bias is how far rays start off the surfaces to avoid hitting the
+	// surface they leave.
+	Bias float32
+
+	objects []*object
+}
+
+// object is a Renderable being baked.
+type object struct {
+	renderable *fizzle.Renderable
+
+	// world is the Renderable's geometry in world space
+	world *fizzle.Geometry
+
+	// albedo is how much light the surface reflects
+	albedo mgl.Vec3
+
+	// texels are the surface points of the lightmap's texels
+	texels []texel
+
+	// direct is the direct light of each texel
+	direct []mgl.Vec3
+
+	// lightmap is the total light of the last pass
+	lightmap *Lightmap
+}
+
+// texel is the surface point a lightmap texel covers.
+type texel struct {
+	position mgl.Vec3
+	normal   mgl.Vec3
+	valid    bool
+}
+
+// NewBaker creates a new lightmap baker with default settings for a
+// scene measured in meters.
+func NewBaker() *Baker {
+	b := new(Baker)
+	b.TexelsPerUnit = 8.0
+	b.MaxSize = 1024
+	b.Padding = 2
+	b.Bounces = 1
+	b.Samples = 64
+	b.Ambient = mgl.Vec3{0.0, 0.0, 0.0}
+	b.Bias = 0.01
+	return b
+}
+
+// Add adds the Renderable and its children to the bake. Their geometry is
+// needed, so fizzle.RetainGeometry must be set before creating them, and it
+// must not move afterwards. Renderables without LightmapUVs get them from
+// Unwrap, which rebuilds their core; clones sharing the old core will need
+// to share the new one.
+func (b *Baker) Add(r *fizzle.Renderable) error {
+	var err error
+	r.Map(func(leaf *fizzle.Renderable) {
+		if leaf.IsGroup || err != nil {
+			return
+		}
+		err = b.addRenderable(leaf)
+	})
+	return err
+}
+
+// addRenderable adds a single Renderable to the bake.
+func (b *Baker) addRenderable(r *fizzle.Renderable) error {
+	if r.Core == nil || r.Core.Geometry == nil {
+		return fmt.Errorf("the Renderable has no geometry; fizzle.RetainGeometry must be set before creating it")
+	}
+	if r.Core.Skeleton != nil || r.Core.Topology != fizzle.TopologyTriangles {
+		return fmt.Errorf("only static triangle meshes can be lightmapped")
+	}
+
+	g := r.Core.Geometry
+	transform := r.GetTransformMat4()
+	width, height := 0, 0
+	if len(g.LightmapUVs) < g.VertexCount()*2 {
+		unwrapped, w, h, err := unwrapScaled(g, g.Transform(transform), b.TexelsPerUnit, b.Padding, b.MaxSize)
+		if err != nil {
+			return err
+		}
+		replaceGeometry(r, unwrapped)
+		g = unwrapped
+		width, height = w, h
+	} else {
+		// size the lightmap of existing coordinates by the surface area
+		area := float32(0.0)
+		world := g.Transform(transform)
+		for tri := 0; tri < len(world.Indexes)/3; tri++ {
+			p0, p1, p2 := triangleAt(world, tri)
+			area += p1.Sub(p0).Cross(p2.Sub(p0)).Len() * 0.5
+		}
+		size := int(math.Ceil(math.Sqrt(float64(area)) * float64(b.TexelsPerUnit)))
+		width = clampInt(size, 4, b.MaxSize)
+		height = width
+	}
+
+	obj := new(object)
+	obj.renderable = r
+	obj.world = g.Transform(transform)
+	obj.albedo = r.GetMaterial().DiffuseColor.Vec3()
+	obj.lightmap = NewLightmap(width, height)
+	b.objects = append(b.objects, obj)
+	return nil
+}
+
+// replaceGeometry rebuilds the Renderable's core from the geometry, keeping
+// its material.
+func replaceGeometry(r *fizzle.Renderable, g *fizzle.Geometry) {
+	old := r.Core
+	fresh := fizzle.CreateFromGeometry(g)
+	core := fresh.Core
+	core.Material = old.Material
+	core.Lightmap = old.Lightmap
+	core.Geometry = g
+	old.DestroyCore()
+
+	r.Core = core
+	r.FaceCount = fresh.FaceCount
+	r.BoundingRect = fresh.BoundingRect
+}
+
+// Lightmap returns the baked lightmap of the Renderable so that it can be
+// saved, or nil if the Renderable hasn't been baked.
+func (b *Baker) Lightmap(r *fizzle.Renderable) *Lightmap {
+	for _, obj := range b.objects {
+		if obj.renderable == r {
+			return obj.lightmap
+		}
+	}
+	return nil
+}
+
+// Bake traces the lighting of every added Renderable and sets the resulting
+// textures as their Lightmap, deleting any lightmap they had before.
+func (b *Baker) Bake() error {
+	if len(b.objects) == 0 {
+		return fmt.Errorf("there is nothing to bake")
+	}
+
+	// gather the scene's triangles for tracing rays
+	triangles := make([]triangle, 0, 1024)
+	for i, obj := range b.objects {
+		g := obj.world
+		for tri := 0; tri < len(g.Indexes)/3; tri++ {
+			t := triangle{object: i}
+			t.a, t.b, t.c = triangleAt(g, tri)
+			t.normal = t.b.Sub(t.a).Cross(t.c.Sub(t.a))
+			if t.normal.Len() > 0.0 {
+				t.normal = t.normal.Normalize()
+			}
+			t.uvA, t.uvB, t.uvC = lightmapUV(g, g.Indexes[tri*3]), lightmapUV(g, g.Indexes[tri*3+1]), lightmapUV(g, g.Indexes[tri*3+2])
+			triangles = append(triangles, t)
+		}
+	}
+	tree := newBVH(triangles)
+
+	// direct light
+	for _, obj := range b.objects {
+		obj.rasterize()
+		obj.direct = make([]mgl.Vec3, len(obj.texels))
+		parallelRows(obj.lightmap.Height, func(y int, rng *rand.Rand) {
+			for x := 0; x < obj.lightmap.Width; x++ {
+				i := y*obj.lightmap.Width + x
+				t := &obj.texels[i]
+				if !t.valid {
+					continue
+				}
+				obj.direct[i] = b.directLight(tree, t.position, t.normal)
+				light := obj.direct[i]
+				if b.Bounces <= 0 {
+					light = light.Add(b.Ambient)
+				}
+				obj.lightmap.Set(x, y, light)
+			}
+		})
+		obj.dilate(b.Padding + 1)
+	}
+
+	// indirect light gathered from the previous pass
+	for bounce := 0; bounce < b.Bounces; bounce++ {
+		next := make([]*Lightmap, len(b.objects))
+		for oi, obj := range b.objects {
+			lm := NewLightmap(obj.lightmap.Width, obj.lightmap.Height)
+			next[oi] = lm
+			parallelRows(lm.Height, func(y int, rng *rand.Rand) {
+				for x := 0; x < lm.Width; x++ {
+					i := y*lm.Width + x
+					t := &obj.texels[i]
+					if !t.valid {
+						continue
+					}
+					indirect := b.indirectLight(tree, t.position, t.normal, rng)
+					lm.Set(x, y, obj.direct[i].Add(indirect))
+				}
+			})
+		}
+		for oi, obj := range b.objects {
+			obj.lightmap = next[oi]
+			obj.dilate(b.Padding + 1)
+		}
+	}
+
+	for _, obj := range b.objects {
+		tex, err := obj.lightmap.CreateTexture()
+		if err != nil {
+			return err
+		}
+		core := obj.renderable.Core
+		if core.Lightmap != 0 {
+			fizzle.GetGraphics().DeleteTexture(core.Lightmap)
+		}
+		core.Lightmap = tex
+	}
+	return nil
+}
+
+// directLight returns the light reaching the surface point straight from
+// the lights, with shadows.
+func (b *Baker) directLight(tree *bvh, position, normal mgl.Vec3) mgl.Vec3 {
+	var result mgl.Vec3
+	origin := position.Add(normal.Mul(b.Bias))
+	for i := range b.Lights {
+		l := &b.Lights[i]
+		var toLight mgl.Vec3
+		dist := float32(math.MaxFloat32)
+		attenuation := float32(1.0)
+		if l.IsDirectional() {
+			toLight = l.Direction.Normalize().Mul(-1.0)
+		} else {
+			toLight = l.Position.Sub(position)
+			dist = toLight.Len()
+			if dist == 0.0 {
+				continue
+			}
+			toLight = toLight.Mul(1.0 / dist)
+			attenuation = l.attenuation(dist)
+		}
+
+		nDotL := normal.Dot(toLight)
+		if nDotL <= 0.0 || attenuation <= 0.0 {
+			continue
+		}
+		if tree.occluded(origin, toLight, dist-b.Bias) {
+			continue
+		}
+		result = result.Add(l.Color.Mul(l.Intensity * nDotL * attenuation))
+	}
+	return result
+}
+
+// indirectLight returns the light bounced onto the surface point by the
+// rest of the scene, gathered with cosine weighted rays.
+func (b *Baker) indirectLight(tree *bvh, position, normal mgl.Vec3, rng *rand.Rand) mgl.Vec3 {
+	if b.Samples <= 0 {
+		return mgl.Vec3{}
+	}
+
+	var result mgl.Vec3
+	origin := position.Add(normal.Mul(b.Bias))
+	u, v := planeAxes(normal)
+	for s := 0; s < b.Samples; s++ {
+		phi := 2.0 * math.Pi * rng.Float64()
+		r2 := rng.Float64()
+		radius := math.Sqrt(r2)
+		local := mgl.Vec3{float32(radius * math.Cos(phi)), float32(radius * math.Sin(phi)), float32(math.Sqrt(1.0 - r2))}
+		dir := u.Mul(local[0]).Add(v.Mul(local[1])).Add(normal.Mul(local[2])).Normalize()
+
+		hit, okay := tree.intersect(origin, dir, math.MaxFloat32)
+		if !okay {
+			result = result.Add(b.Ambient)
+			continue
+		}
+
+		// the back of a surface doesn't reflect anything
+		t := &tree.triangles[hit.triangle]
+		if t.normal.Dot(dir) > 0.0 {
+			continue
+		}
+		uv := t.uvA.Mul(1.0 - hit.u - hit.v).Add(t.uvB.Mul(hit.u)).Add(t.uvC.Mul(hit.v))
+		hitObj := b.objects[t.object]
+		result = result.Add(mulColor(hitObj.albedo, hitObj.lightmap.Sample(uv)))
+	}
+	return result.Mul(1.0 / float32(b.Samples))
+}
+
+// rasterize finds the surface point covered by each texel of the lightmap.
+func (obj *object) rasterize() {
+	lm := obj.lightmap
+	g := obj.world
+	obj.texels = make([]texel, lm.Width*lm.Height)
+	size := mgl.Vec2{float32(lm.Width), float32(lm.Height)}
+	hasNormals := len(g.Normals) >= g.VertexCount()*3
+
+	for tri := 0; tri < len(g.Indexes)/3; tri++ {
+		var corners [3]mgl.Vec2
+		var positions, normals [3]mgl.Vec3
+		for c := 0; c < 3; c++ {
+			index := g.Indexes[tri*3+c]
+			uv := lightmapUV(g, index)
+			corners[c] = mgl.Vec2{uv[0] * size[0], uv[1] * size[1]}
+			positions[c] = vertexAt(g, index)
+			if hasNormals {
+				normals[c] = mgl.Vec3{g.Normals[index*3], g.Normals[index*3+1], g.Normals[index*3+2]}
+			}
+		}
+		faceNormal := positions[1].Sub(positions[0]).Cross(positions[2].Sub(positions[0]))
+		if faceNormal.Len() == 0.0 {
+			continue
+		}
+		faceNormal = faceNormal.Normalize()
+
+		area := edgeFunction(corners[0], corners[1], corners[2])
+		if area == 0.0 {
+			continue
+		}
+
+		lo := corners[0]
+		hi := corners[0]
+		for _, c := range corners[1:] {
+			for i := range c {
+				lo[i] = float32(math.Min(float64(lo[i]), float64(c[i])))
+				hi[i] = float32(math.Max(float64(hi[i]), float64(c[i])))
+			}
+		}
+		minX := clampInt(int(math.Floor(float64(lo[0]))), 0, lm.Width-1)
+		minY := clampInt(int(math.Floor(float64(lo[1]))), 0, lm.Height-1)
+		maxX := clampInt(int(math.Ceil(float64(hi[0]))), 0, lm.Width-1)
+		maxY := clampInt(int(math.Ceil(float64(hi[1]))), 0, lm.Height-1)
+
+		for y := minY; y <= maxY; y++ {
+			for x := minX; x <= maxX; x++ {
+				p := mgl.Vec2{float32(x) + 0.5, float32(y) + 0.5}
+				w0 := edgeFunction(corners[1], corners[2], p) / area
+				w1 := edgeFunction(corners[2], corners[0], p) / area
+				w2 := 1.0 - w0 - w1
+				if w0 < 0.0 || w1 < 0.0 || w2 < 0.0 {
+					continue
+				}
+
+				t := &obj.texels[y*lm.Width+x]
+				t.valid = true
+				t.position = positions[0].Mul(w0).Add(positions[1].Mul(w1)).Add(positions[2].Mul(w2))
+				t.normal = faceNormal
+				if hasNormals {
+					n := normals[0].Mul(w0).Add(normals[1].Mul(w1)).Add(normals[2].Mul(w2))
+					if n.Len() > 0.0 {
+						t.normal = n.Normalize()
+					}
+				}
+			}
+		}
+	}
+}
+
+// dilate spreads the light of the covered texels into the empty texels
+// around them so that filtering at the edges of the charts doesn't pull in
+// black texels.
+func (obj *object) dilate(passes int) {
+	lm := obj.lightmap
+	filled := make([]bool, len(obj.texels))
+	for i := range obj.texels {
+		filled[i] = obj.texels[i].valid
+	}
+
+	for pass := 0; pass < passes; pass++ {
+		next := make([]bool, len(filled))
+		copy(next, filled)
+		for y := 0; y < lm.Height; y++ {
+			for x := 0; x < lm.Width; x++ {
+				if filled[y*lm.Width+x] {
+					continue
+				}
+				var sum mgl.Vec3
+				count := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						if nx < 0 || ny < 0 || nx >= lm.Width || ny >= lm.Height || !filled[ny*lm.Width+nx] {
+							continue
+						}
+						sum = sum.Add(lm.At(nx, ny))
+						count++
+					}
+				}
+				if count > 0 {
+					lm.Set(x, y, sum.Mul(1.0/float32(count)))
+					next[y*lm.Width+x] = true
+				}
+			}
+		}
+		filled = next
+	}
+}
+
+// parallelRows calls f for every row on all of the CPUs, giving each row
+// its own random source seeded by the row so that bakes are repeatable.
+func parallelRows(rows int, f func(y int, rng *rand.Rand)) {
+	workers := runtime.NumCPU()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for y := w; y < rows; y += workers {
+				f(y, rand.New(rand.NewSource(int64(y)+1)))
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// edgeFunction returns twice the signed area of the triangle a, b, p.
+func edgeFunction(a, b, p mgl.Vec2) float32 {
+	return (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+}
+
+// lightmapUV returns the lightmap coordinates of the vertex.
+func lightmapUV(g *fizzle.Geometry, index uint32) mgl.Vec2 {
+	return mgl.Vec2{g.LightmapUVs[index*2], g.LightmapUVs[index*2+1]}
+}
+
+// mulColor multiplies the colors component by component.
+func mulColor(a, b mgl.Vec3) mgl.Vec3 {
+	return mgl.Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
@@ -0,0 +1,244 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package lightmap
+
+import (
+	"math"
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// maxLeafTriangles is the most triangles kept in a leaf of the hierarchy.
+const maxLeafTriangles = 4
+
+// triangle is a world space triangle of the scene being baked.
+type triangle struct {
+	a, b, c mgl.Vec3
+	normal  mgl.Vec3
+
+	// object is the index of the baked object the triangle belongs to
+	object int
+
+	// uvA, uvB, uvC are the lightmap coordinates of the corners
+	uvA, uvB, uvC mgl.Vec2
+}
+
+// centroid returns the center of the triangle.
+func (t *triangle) centroid() mgl.Vec3 {
+	return t.a.Add(t.b).Add(t.c).Mul(1.0 / 3.0)
+}
+
+// bvhNode is a node of the bounding volume hierarchy. Leaves have a count
+// of triangles starting at first; other nodes have two children, the first
+// of which directly follows the node.
+type bvhNode struct {
+	min, max mgl.Vec3
+	first    int
+	count    int
+	second   int
+}
+
+// bvh is a bounding volume hierarchy over the scene's triangles used to
+// trace the rays of the bake.
+type bvh struct {
+	triangles []triangle
+	nodes     []bvhNode
+}
+
+// rayHit is the closest triangle hit by a ray.
+type rayHit struct {
+	triangle int
+	distance float32
+
+	// u and v are the barycentric weights of the b and c corners
+	u, v float32
+}
+
+// trianglesByAxis sorts triangles by their centroid on one axis.
+type trianglesByAxis struct {
+	triangles []triangle
+	axis      int
+}
+
+func (ta trianglesByAxis) Len() int { return len(ta.triangles) }
+func (ta trianglesByAxis) Less(i, j int) bool {
+	return ta.triangles[i].centroid()[ta.axis] < ta.triangles[j].centroid()[ta.axis]
+}
+func (ta trianglesByAxis) Swap(i, j int) {
+	ta.triangles[i], ta.triangles[j] = ta.triangles[j], ta.triangles[i]
+}
+
+// newBVH builds the hierarchy by splitting the triangles in half on the
+// longest axis of their centroids. The triangle slice is reordered.
+func newBVH(triangles []triangle) *bvh {
+	tree := new(bvh)
+	tree.triangles = triangles
+	tree.nodes = make([]bvhNode, 0, len(triangles)/maxLeafTriangles*2+1)
+	if len(triangles) > 0 {
+		tree.build(0, len(triangles))
+	}
+	return tree
+}
+
+// build adds the node for the triangles in [first, last) and its children,
+// returning the index of the node.
+func (tree *bvh) build(first, last int) int {
+	index := len(tree.nodes)
+	tree.nodes = append(tree.nodes, bvhNode{})
+
+	node := bvhNode{first: first, count: last - first}
+	node.min = mgl.Vec3{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	node.max = mgl.Vec3{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	centerMin, centerMax := node.min, node.max
+	for i := first; i < last; i++ {
+		t := &tree.triangles[i]
+		for _, p := range [3]mgl.Vec3{t.a, t.b, t.c} {
+			for axis := 0; axis < 3; axis++ {
+				node.min[axis] = float32(math.Min(float64(node.min[axis]), float64(p[axis])))
+				node.max[axis] = float32(math.Max(float64(node.max[axis]), float64(p[axis])))
+			}
+		}
+		center := t.centroid()
+		for axis := 0; axis < 3; axis++ {
+			centerMin[axis] = float32(math.Min(float64(centerMin[axis]), float64(center[axis])))
+			centerMax[axis] = float32(math.Max(float64(centerMax[axis]), float64(center[axis])))
+		}
+	}
+
+	if node.count > maxLeafTriangles {
+		extent := centerMax.Sub(centerMin)
+		axis := 0
+		if extent[1] > extent[axis] {
+			axis = 1
+		}
+		if extent[2] > extent[axis] {
+			axis = 2
+		}
+		sort.Sort(trianglesByAxis{tree.triangles[first:last], axis})
+
+		middle := (first + last) / 2
+		tree.build(first, middle)
+		node.second = tree.build(middle, last)
+		node.count = 0
+	}
+
+	tree.nodes[index] = node
+	return index
+}
+
+// intersect returns the closest triangle hit by the ray within maxDistance.
+// Triangles are hit from both sides. The direction must be normalized.
+func (tree *bvh) intersect(origin, direction mgl.Vec3, maxDistance float32) (rayHit, bool) {
+	hit := rayHit{triangle: -1, distance: maxDistance}
+	tree.traverse(origin, direction, func(tri int) bool {
+		t := &tree.triangles[tri]
+		if dist, u, v, okay := rayTriangle(origin, direction, t.a, t.b, t.c); okay && dist < hit.distance {
+			hit = rayHit{triangle: tri, distance: dist, u: u, v: v}
+		}
+		return false
+	}, &hit.distance)
+	return hit, hit.triangle >= 0
+}
+
+// occluded returns true if any triangle blocks the ray within maxDistance.
+func (tree *bvh) occluded(origin, direction mgl.Vec3, maxDistance float32) bool {
+	blocked := false
+	tree.traverse(origin, direction, func(tri int) bool {
+		t := &tree.triangles[tri]
+		if dist, _, _, okay := rayTriangle(origin, direction, t.a, t.b, t.c); okay && dist < maxDistance {
+			blocked = true
+		}
+		return blocked
+	}, &maxDistance)
+	return blocked
+}
+
+// traverse calls visit for every triangle in the leaves whose boxes the ray
+// enters before *maxDistance, stopping early if visit returns true.
+func (tree *bvh) traverse(origin, direction mgl.Vec3, visit func(tri int) bool, maxDistance *float32) {
+	if len(tree.nodes) == 0 {
+		return
+	}
+
+	var inverse mgl.Vec3
+	for axis := 0; axis < 3; axis++ {
+		inverse[axis] = 1.0 / direction[axis]
+	}
+
+	var stack [64]int
+	stack[0] = 0
+	top := 1
+	for top > 0 {
+		top--
+		index := stack[top]
+		node := &tree.nodes[index]
+		if !rayBox(origin, inverse, node.min, node.max, *maxDistance) {
+			continue
+		}
+		if node.count > 0 {
+			for tri := node.first; tri < node.first+node.count; tri++ {
+				if visit(tri) {
+					return
+				}
+			}
+			continue
+		}
+		stack[top] = node.second
+		stack[top+1] = index + 1
+		top += 2
+	}
+}
+
+// rayBox returns true if the ray enters the box before maxDistance.
+func rayBox(origin, inverse, min, max mgl.Vec3, maxDistance float32) bool {
+	near := float32(0.0)
+	far := maxDistance
+	for axis := 0; axis < 3; axis++ {
+		t0 := (min[axis] - origin[axis]) * inverse[axis]
+		t1 := (max[axis] - origin[axis]) * inverse[axis]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		if t0 > near {
+			near = t0
+		}
+		if t1 < far {
+			far = t1
+		}
+		if near > far {
+			return false
+		}
+	}
+	return true
+}
+
+// rayTriangle returns the distance along the ray to the triangle and the
+// barycentric weights of b and c at the hit using the Moller-Trumbore test.
+func rayTriangle(origin, direction, a, b, c mgl.Vec3) (float32, float32, float32, bool) {
+	const epsilon = 0.0000001
+	edge1 := b.Sub(a)
+	edge2 := c.Sub(a)
+	h := direction.Cross(edge2)
+	det := edge1.Dot(h)
+	if det > -epsilon && det < epsilon {
+		return 0.0, 0.0, 0.0, false
+	}
+	f := 1.0 / det
+	s := origin.Sub(a)
+	u := f * s.Dot(h)
+	if u < 0.0 || u > 1.0 {
+		return 0.0, 0.0, 0.0, false
+	}
+	q := s.Cross(edge1)
+	v := f * direction.Dot(q)
+	if v < 0.0 || u+v > 1.0 {
+		return 0.0, 0.0, 0.0, false
+	}
+	t := f * edge2.Dot(q)
+	if t <= epsilon {
+		return 0.0, 0.0, 0.0, false
+	}
+	return t, u, v, true
+}
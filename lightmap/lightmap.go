@@ -0,0 +1,211 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The lightmap module bakes the static lighting of a scene into textures so
+that static geometry can be lit without using any of the dynamic light slots
+of the renderer.
+
+Renderables are added to a Baker, which gives every one of them a second
+set of texture coordinates (Geometry.LightmapUVs, bound as VERTEX_UV_1) if
+they don't already have one. Bake then traces the direct light of the
+static lights, with shadows, and any number of diffuse bounces of indirect
+light through the scene, and uploads the result as the LIGHTMAP texture of
+each Renderable's core. Shaders like diffuse_lightmapped multiply the
+lightmap with the surface color and add the dynamic lights on top.
+
+Lightmaps can be saved with Lightmap.Write and loaded back with Read so that
+the bake can happen offline.
+
+*/
+
+package lightmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// fileMagic identifies the lightmap file format written by Lightmap.Write.
+const fileMagic = "FZLM"
+
+// Light is a static light baked into the lightmaps. Like the forward
+// renderer's lights, a light with a zero Direction is a point light at
+// Position and any other light is a directional light.
+type Light struct {
+	// Position is where a point light shines from.
+	Position mgl.Vec3
+
+	// Direction is the direction a directional light shines in.
+	Direction mgl.Vec3
+
+	// Color is the color of the light.
+	Color mgl.Vec3
+
+	// Intensity scales the color of the light.
+	Intensity float32
+
+	// ConstantAttenuation, LinearAttenuation and Attenuation are the
+	// constant, linear and quadratic terms of a point light's falloff.
+	// When all of them are zero the light doesn't fall off.
+	ConstantAttenuation float32
+	LinearAttenuation   float32
+	Attenuation         float32
+
+	// Range is the distance at which a point light's contribution is
+	// smoothly windowed to zero; zero means the light has no range.
+	Range float32
+}
+
+// IsDirectional returns true if the light is a directional light.
+func (l *Light) IsDirectional() bool {
+	return l.Direction.Len() > 0.0
+}
+
+// attenuation returns how much of a point light reaches the distance,
+// matching CalcAttenuation in the forward shaders.
+func (l *Light) attenuation(dist float32) float32 {
+	constant := l.ConstantAttenuation
+	if constant == 0.0 && l.LinearAttenuation == 0.0 && l.Attenuation == 0.0 {
+		constant = 1.0
+	}
+	denom := constant + l.LinearAttenuation*dist + l.Attenuation*dist*dist
+	if denom < 0.0001 {
+		denom = 0.0001
+	}
+	atten := 1.0 / denom
+	if l.Range > 0.0 {
+		ratio := dist / l.Range
+		window := mgl.Clamp(1.0-ratio*ratio*ratio*ratio, 0.0, 1.0)
+		atten *= window * window
+	}
+	return atten
+}
+
+// Lightmap is the baked light of one Renderable, stored as RGB triplets in
+// rows from the bottom of the texture up, the way OpenGL expects them.
+type Lightmap struct {
+	Width  int
+	Height int
+	Pixels []float32
+}
+
+// NewLightmap creates a black lightmap of the given size.
+func NewLightmap(width, height int) *Lightmap {
+	lm := new(Lightmap)
+	lm.Width = width
+	lm.Height = height
+	lm.Pixels = make([]float32, width*height*3)
+	return lm
+}
+
+// At returns the light of the texel.
+func (lm *Lightmap) At(x, y int) mgl.Vec3 {
+	i := (y*lm.Width + x) * 3
+	return mgl.Vec3{lm.Pixels[i], lm.Pixels[i+1], lm.Pixels[i+2]}
+}
+
+// Set changes the light of the texel.
+func (lm *Lightmap) Set(x, y int, c mgl.Vec3) {
+	i := (y*lm.Width + x) * 3
+	lm.Pixels[i], lm.Pixels[i+1], lm.Pixels[i+2] = c[0], c[1], c[2]
+}
+
+// Sample returns the light of the texel nearest to the texture coordinate.
+func (lm *Lightmap) Sample(uv mgl.Vec2) mgl.Vec3 {
+	x := clampInt(int(uv[0]*float32(lm.Width)), 0, lm.Width-1)
+	y := clampInt(int(uv[1]*float32(lm.Height)), 0, lm.Height-1)
+	return lm.At(x, y)
+}
+
+// CreateTexture uploads the lightmap into a new floating point texture
+// with linear filtering, suitable for RenderableCore.Lightmap.
+func (lm *Lightmap) CreateTexture() (graphics.Texture, error) {
+	if lm.Width <= 0 || lm.Height <= 0 {
+		return 0, fmt.Errorf("invalid lightmap size %dx%d", lm.Width, lm.Height)
+	}
+
+	// the texture has an alpha channel so that it uses the same float
+	// format as the rest of the engine's data textures
+	data := make([]float32, lm.Width*lm.Height*4)
+	for i := 0; i < lm.Width*lm.Height; i++ {
+		data[i*4] = lm.Pixels[i*3]
+		data[i*4+1] = lm.Pixels[i*3+1]
+		data[i*4+2] = lm.Pixels[i*3+2]
+		data[i*4+3] = 1.0
+	}
+
+	gfx := fizzle.GetGraphics()
+	tex := gfx.GenTexture()
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, int32(lm.Width), int32(lm.Height), 0,
+		graphics.RGBA, graphics.FLOAT, gfx.Ptr(data), len(data)*4)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	err := fizzle.CheckGraphicsError(gfx, "creating the lightmap texture")
+	if err != nil {
+		gfx.DeleteTexture(tex)
+		return 0, err
+	}
+	return tex, nil
+}
+
+// Write stores the lightmap in a simple binary format that Read loads.
+func (lm *Lightmap) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, fileMagic); err != nil {
+		return err
+	}
+	header := [2]uint32{uint32(lm.Width), uint32(lm.Height)}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, lm.Pixels)
+}
+
+// Read loads a lightmap stored with Lightmap.Write.
+func Read(r io.Reader) (*Lightmap, error) {
+	magic := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != fileMagic {
+		return nil, fmt.Errorf("the data is not a lightmap")
+	}
+
+	var header [2]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	const maxDimension = 16384
+	if header[0] == 0 || header[1] == 0 || header[0] > maxDimension || header[1] > maxDimension {
+		return nil, fmt.Errorf("invalid lightmap size %dx%d", header[0], header[1])
+	}
+
+	lm := NewLightmap(int(header[0]), int(header[1]))
+	if err := binary.Read(r, binary.LittleEndian, lm.Pixels); err != nil {
+		return nil, err
+	}
+	return lm, nil
+}
+
+// clampInt limits v to the range [min, max].
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,292 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package lightmap
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+const (
+	// chartNormalTolerance is the minimum dot product between the normals
+	// of two neighboring triangles for them to share a chart.
+	chartNormalTolerance = 0.999
+
+	// chartWeldDistance is how close two vertices need to be to count as
+	// the same point when finding the edges shared between triangles.
+	chartWeldDistance = 0.0001
+
+	// maxUnwrapAttempts limits how many times the texel density is lowered
+	// to make the charts fit in the maximum lightmap size.
+	maxUnwrapAttempts = 16
+)
+
+// chart is a group of connected, coplanar triangles that are laid out
+// together in the lightmap.
+type chart struct {
+	triangles []int
+	normal    mgl.Vec3
+	u, v      mgl.Vec3
+
+	// min and max are the bounds of the projected vertices in world units
+	min, max mgl.Vec2
+
+	// x, y, w, h are the texel rectangle of the chart in the lightmap,
+	// including the padding around it
+	x, y, w, h int
+}
+
+// Unwrap returns a copy of the geometry with LightmapUVs laid out so that
+// every surface gets its own area of a lightmap at about texelsPerUnit texels
+// per unit of length, along with the size of that lightmap. Connected
+// coplanar triangles are kept together in charts and the charts are spaced
+// padding texels apart so that filtering doesn't bleed between them.
+// Vertices shared between charts are split, so the result can have more
+// vertices than the source. If the charts don't fit in maxSize texels
+// squared the texel density is lowered until they do.
+func Unwrap(g *fizzle.Geometry, texelsPerUnit float32, padding int, maxSize int) (*fizzle.Geometry, int, int, error) {
+	return unwrapScaled(g, g, texelsPerUnit, padding, maxSize)
+}
+
+// unwrapScaled unwraps the geometry using the triangle sizes of the scaled
+// geometry, which must have the same vertices and indexes, such as the
+// geometry transformed into world space.
+func unwrapScaled(g *fizzle.Geometry, scaled *fizzle.Geometry, texelsPerUnit float32, padding int, maxSize int) (*fizzle.Geometry, int, int, error) {
+	if len(g.Indexes) < 3 {
+		return nil, 0, 0, fmt.Errorf("the geometry has no triangles to unwrap")
+	}
+	if texelsPerUnit <= 0.0 || maxSize <= 0 || padding < 0 {
+		return nil, 0, 0, fmt.Errorf("invalid lightmap unwrap parameters")
+	}
+
+	charts := buildCharts(scaled)
+	width, height := 0, 0
+	for attempt := 0; ; attempt++ {
+		width, height = packCharts(charts, texelsPerUnit, padding)
+		if width <= maxSize && height <= maxSize {
+			break
+		}
+		if attempt >= maxUnwrapAttempts {
+			return nil, 0, 0, fmt.Errorf("the geometry doesn't fit in a %dx%d lightmap", maxSize, maxSize)
+		}
+		largest := width
+		if height > largest {
+			largest = height
+		}
+		texelsPerUnit *= float32(maxSize) / float32(largest) * 0.95
+	}
+
+	// split the vertices so that each chart has its own copies
+	result := new(fizzle.Geometry)
+	vertCount := g.VertexCount()
+	hasNormals := len(g.Normals) >= vertCount*3
+	hasUVs := len(g.UVs) >= vertCount*2
+	hasTangents := len(g.Tangents) >= vertCount*3
+	result.Indexes = make([]uint32, len(g.Indexes))
+	for _, c := range charts {
+		remap := make(map[uint32]uint32)
+		for _, tri := range c.triangles {
+			for corner := 0; corner < 3; corner++ {
+				src := g.Indexes[tri*3+corner]
+				dst, okay := remap[src]
+				if !okay {
+					dst = uint32(result.VertexCount())
+					remap[src] = dst
+					result.Vertices = append(result.Vertices, g.Vertices[src*3:src*3+3]...)
+					if hasNormals {
+						result.Normals = append(result.Normals, g.Normals[src*3:src*3+3]...)
+					}
+					if hasUVs {
+						result.UVs = append(result.UVs, g.UVs[src*2:src*2+2]...)
+					}
+					if hasTangents {
+						result.Tangents = append(result.Tangents, g.Tangents[src*3:src*3+3]...)
+					}
+
+					p := vertexAt(scaled, src)
+					s := (p.Dot(c.u)-c.min[0])*texelsPerUnit + float32(c.x+padding)
+					t := (p.Dot(c.v)-c.min[1])*texelsPerUnit + float32(c.y+padding)
+					result.LightmapUVs = append(result.LightmapUVs, s/float32(width), t/float32(height))
+				}
+				result.Indexes[tri*3+corner] = dst
+			}
+		}
+	}
+
+	return result, width, height, nil
+}
+
+// buildCharts groups the triangles into charts of connected triangles that
+// face the same way and projects each chart onto its plane.
+func buildCharts(g *fizzle.Geometry) []*chart {
+	triCount := len(g.Indexes) / 3
+	normals := make([]mgl.Vec3, triCount)
+	for tri := range normals {
+		a, b, c := triangleAt(g, tri)
+		n := b.Sub(a).Cross(c.Sub(a))
+		if n.Len() > 0.0 {
+			n = n.Normalize()
+		}
+		normals[tri] = n
+	}
+
+	// union the triangles that share an edge and a plane
+	parents := make([]int, triCount)
+	for i := range parents {
+		parents[i] = i
+	}
+	find := func(i int) int {
+		for parents[i] != i {
+			parents[i] = parents[parents[i]]
+			i = parents[i]
+		}
+		return i
+	}
+
+	type weldKey [3]int64
+	type edgeKey [2]weldKey
+	weld := func(p mgl.Vec3) weldKey {
+		return weldKey{
+			int64(math.Floor(float64(p[0] / chartWeldDistance))),
+			int64(math.Floor(float64(p[1] / chartWeldDistance))),
+			int64(math.Floor(float64(p[2] / chartWeldDistance))),
+		}
+	}
+	less := func(a, b weldKey) bool {
+		for i := range a {
+			if a[i] != b[i] {
+				return a[i] < b[i]
+			}
+		}
+		return false
+	}
+
+	edges := make(map[edgeKey]int)
+	for tri := 0; tri < triCount; tri++ {
+		a, b, c := triangleAt(g, tri)
+		corners := [3]weldKey{weld(a), weld(b), weld(c)}
+		for e := 0; e < 3; e++ {
+			k0, k1 := corners[e], corners[(e+1)%3]
+			if less(k1, k0) {
+				k0, k1 = k1, k0
+			}
+			key := edgeKey{k0, k1}
+			other, okay := edges[key]
+			if !okay {
+				edges[key] = tri
+				continue
+			}
+			if normals[other].Dot(normals[tri]) >= chartNormalTolerance {
+				parents[find(tri)] = find(other)
+			}
+		}
+	}
+
+	chartIndex := make(map[int]*chart)
+	charts := make([]*chart, 0, triCount)
+	for tri := 0; tri < triCount; tri++ {
+		root := find(tri)
+		c, okay := chartIndex[root]
+		if !okay {
+			c = new(chart)
+			c.normal = normals[root]
+			c.u, c.v = planeAxes(c.normal)
+			c.min = mgl.Vec2{math.MaxFloat32, math.MaxFloat32}
+			c.max = mgl.Vec2{-math.MaxFloat32, -math.MaxFloat32}
+			chartIndex[root] = c
+			charts = append(charts, c)
+		}
+		c.triangles = append(c.triangles, tri)
+
+		a, b, d := triangleAt(g, tri)
+		for _, p := range [3]mgl.Vec3{a, b, d} {
+			projected := mgl.Vec2{p.Dot(c.u), p.Dot(c.v)}
+			for i := range projected {
+				if projected[i] < c.min[i] {
+					c.min[i] = projected[i]
+				}
+				if projected[i] > c.max[i] {
+					c.max[i] = projected[i]
+				}
+			}
+		}
+	}
+	return charts
+}
+
+// chartsByHeight sorts charts from the tallest to the shortest.
+type chartsByHeight []*chart
+
+func (cs chartsByHeight) Len() int           { return len(cs) }
+func (cs chartsByHeight) Less(i, j int) bool { return cs[i].h > cs[j].h }
+func (cs chartsByHeight) Swap(i, j int)      { cs[i], cs[j] = cs[j], cs[i] }
+
+// packCharts places the charts on shelves in a lightmap at the texel
+// density and returns the size of the lightmap.
+func packCharts(charts []*chart, texelsPerUnit float32, padding int) (int, int) {
+	area := 0
+	widest := 0
+	for _, c := range charts {
+		size := c.max.Sub(c.min).Mul(texelsPerUnit)
+		c.w = int(math.Ceil(float64(size[0]))) + 1 + padding*2
+		c.h = int(math.Ceil(float64(size[1]))) + 1 + padding*2
+		area += c.w * c.h
+		if c.w > widest {
+			widest = c.w
+		}
+	}
+
+	width := int(math.Ceil(math.Sqrt(float64(area) * 1.1)))
+	if width < widest {
+		width = widest
+	}
+
+	// tallest charts first keeps the shelves tight
+	order := make([]*chart, len(charts))
+	copy(order, charts)
+	sort.Stable(chartsByHeight(order))
+
+	x, y, shelfHeight := 0, 0, 0
+	for _, c := range order {
+		if x+c.w > width {
+			x = 0
+			y += shelfHeight
+			shelfHeight = 0
+		}
+		c.x, c.y = x, y
+		x += c.w
+		if c.h > shelfHeight {
+			shelfHeight = c.h
+		}
+	}
+	return width, y + shelfHeight
+}
+
+// planeAxes returns two axes spanning the plane with the normal.
+func planeAxes(normal mgl.Vec3) (mgl.Vec3, mgl.Vec3) {
+	up := mgl.Vec3{0.0, 1.0, 0.0}
+	if normal.Len() == 0.0 {
+		normal = mgl.Vec3{0.0, 0.0, 1.0}
+	}
+	if math.Abs(float64(normal.Dot(up))) > 0.99 {
+		up = mgl.Vec3{1.0, 0.0, 0.0}
+	}
+	u := up.Cross(normal).Normalize()
+	v := normal.Cross(u)
+	return u, v
+}
+
+// vertexAt returns the position of the vertex.
+func vertexAt(g *fizzle.Geometry, index uint32) mgl.Vec3 {
+	return mgl.Vec3{g.Vertices[index*3], g.Vertices[index*3+1], g.Vertices[index*3+2]}
+}
+
+// triangleAt returns the corners of the triangle.
+func triangleAt(g *fizzle.Geometry, tri int) (mgl.Vec3, mgl.Vec3, mgl.Vec3) {
+	return vertexAt(g, g.Indexes[tri*3]), vertexAt(g, g.Indexes[tri*3+1]), vertexAt(g, g.Indexes[tri*3+2])
+}
@@ -0,0 +1,160 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// BlendMode is how a transparent material is blended with what's already
+// been drawn.
+type BlendMode int
+
+const (
+	// BlendAlpha mixes the color in by its alpha.
+	BlendAlpha BlendMode = iota
+
+	// BlendAdditive adds the color, weighted by its alpha, for glows and
+	// particles.
+	BlendAdditive
+
+	// BlendPremultiplied is for colors already multiplied by their alpha.
+	BlendPremultiplied
+)
+
+// CullMode is which faces of a material are culled.
+type CullMode int
+
+const (
+	// CullBack culls the back faces.
+	CullBack CullMode = iota
+
+	// CullFront culls the front faces.
+	CullFront
+
+	// CullNone draws both sides, like for leaves and cloth.
+	CullNone
+)
+
+// Material describes the surface of Renderables: the shader drawing them,
+// its textures and uniforms and the render state it's drawn with. A
+// Material can be shared by many Renderables by setting their Material
+// field, so that changing it changes all of them and render queues can
+// sort the draws by it.
+type Material struct {
+	Shader *RenderShader
+
+	Tex0 graphics.Texture
+	Tex1 graphics.Texture
+
+	DiffuseColor  mgl.Vec4
+	SpecularColor mgl.Vec4
+
+	// Shininess is the exponent used while calculating specular highlights
+	Shininess float32
+
+	// PBR is the metallic-roughness material used by physically based
+	// shaders, which take the albedo from DiffuseColor and Tex0 and the
+	// normal map from Tex1.
+	PBR PBRMaterial
+
+	// AlphaCutoff, when greater than zero, makes the material an alpha
+	// cutout: shaders that declare MATERIAL_ALPHA_CUTOFF discard fragments
+	// whose diffuse alpha is below it, and so do the shadow passes of the
+	// forward renderer, so that foliage and fences cast shadows with holes.
+	AlphaCutoff float32
+
+	// Transparent marks the material as alpha blended. Render queues draw
+	// transparent Renderables after the opaque ones, sorted back to front.
+	Transparent bool
+
+	// Layer orders the draws of render queues: Renderables on lower layers
+	// are drawn before higher ones, ahead of the sorting by shader,
+	// material and depth, so that skyboxes or overlays can be kept in place.
+	Layer uint8
+
+	// Blend is how render queues blend the material when it's Transparent.
+	Blend BlendMode
+
+	// Cull is which faces are culled while drawing the material. Other
+	// modes than CullBack are restored to back face culling after the draw.
+	Cull CullMode
+
+	// NoDepthTest draws the material over everything already drawn.
+	NoDepthTest bool
+
+	// NoDepthWrite keeps the material out of the depth buffer. Transparent
+	// materials drawn by render queues never write depth.
+	NoDepthWrite bool
+
+	// Uniforms are extra values set on the shader by name when the
+	// material is bound. The supported types are float32, int32, mgl.Vec2,
+	// mgl.Vec3, mgl.Vec4, mgl.Mat4 and graphics.Texture, which is bound as
+	// a 2D sampler; values of other types are ignored.
+	Uniforms map[string]interface{}
+}
+
+// PBRMaterial holds the parameters of the metallic-roughness material
+// model, as exported by Blender, Substance and glTF, beyond the albedo and
+// normal map of the Material.
+type PBRMaterial struct {
+	// Metallic is 0 for dielectrics and 1 for metals.
+	Metallic float32
+
+	// Roughness goes from 0 for a mirror finish to 1 for fully rough.
+	Roughness float32
+
+	// Emissive is the color of the light the surface gives off.
+	Emissive mgl.Vec3
+
+	// MetalRoughTex, if set, scales Roughness by its green channel and
+	// Metallic by its blue channel, like glTF's metallicRoughnessTexture.
+	MetalRoughTex graphics.Texture
+
+	// OcclusionTex, if set, is the baked ambient occlusion in its red
+	// channel.
+	OcclusionTex graphics.Texture
+
+	// EmissiveTex, if set, scales Emissive.
+	EmissiveTex graphics.Texture
+}
+
+// NewMaterial creates a new opaque, white material without a shader.
+func NewMaterial() *Material {
+	m := new(Material)
+	m.DiffuseColor = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
+	m.SpecularColor = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
+	m.Shininess = 0.01
+	m.PBR.Roughness = 1.0
+	return m
+}
+
+// Clone returns a copy of the material that can be changed without
+// affecting the original. The shader and textures are shared.
+func (m *Material) Clone() *Material {
+	clone := *m
+	if m.Uniforms != nil {
+		clone.Uniforms = make(map[string]interface{}, len(m.Uniforms))
+		for name, value := range m.Uniforms {
+			clone.Uniforms[name] = value
+		}
+	}
+	return &clone
+}
+
+// SetUniform sets an extra uniform value for the material's shader.
+func (m *Material) SetUniform(name string, value interface{}) {
+	if m.Uniforms == nil {
+		m.Uniforms = make(map[string]interface{})
+	}
+	m.Uniforms[name] = value
+}
+
+// HasRenderState returns true if the material is drawn with render state
+// other than the default of back face culling with depth testing and
+// writing.
+func (m *Material) HasRenderState() bool {
+	return m.Cull != CullBack || m.NoDepthTest || m.NoDepthWrite
+}
@@ -0,0 +1,335 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The navmesh module builds navigation meshes out of scene geometry and finds
+paths across them for AI characters.
+
+The scene triangles are voxelized into a heightfield, the surfaces that are
+flat enough and have room for the agent become walkable cells, the cells
+too close to edges for the agent's radius are eroded away, and the rest are
+merged into rectangular polygons. Paths are found with A* over the polygons
+and then pulled tight through the portals between them.
+
+Thin walls are caught by sampling the triangle edges, but walls thinner than
+the cell size may still be missed if their edges don't cross a column.
+
+*/
+
+package navmesh
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/collision"
+)
+
+// Config holds the agent and voxelization parameters for building a navmesh.
+type Config struct {
+	// CellSize is the width and depth of the heightfield cells.
+	CellSize float32
+
+	// CellHeight is how close together two surfaces in a column can be
+	// before they are treated as one.
+	CellHeight float32
+
+	// AgentHeight is the clearance needed above a walkable surface.
+	AgentHeight float32
+
+	// AgentRadius is how far the agent keeps from edges.
+	AgentRadius float32
+
+	// MaxClimb is the largest step height the agent can walk up.
+	MaxClimb float32
+
+	// MaxSlopeDegrees is the steepest slope the agent can walk on.
+	MaxSlopeDegrees float32
+}
+
+// DefaultConfig returns a configuration for a human sized agent in a scene
+// measured in meters.
+func DefaultConfig() Config {
+	return Config{
+		CellSize:        0.3,
+		CellHeight:      0.2,
+		AgentHeight:     2.0,
+		AgentRadius:     0.6,
+		MaxClimb:        0.9,
+		MaxSlopeDegrees: 45.0,
+	}
+}
+
+// Link connects a polygon to a neighbor through a portal. Left and Right
+// are the ends of the portal as seen when walking into the neighbor.
+type Link struct {
+	To    int
+	Left  mgl.Vec3
+	Right mgl.Vec3
+}
+
+// Polygon is a walkable area of the navmesh.
+type Polygon struct {
+	// Vertices are the corners of the polygon.
+	Vertices []mgl.Vec3
+
+	// Center is the average of the vertices.
+	Center mgl.Vec3
+
+	// Min and Max bound the polygon.
+	Min mgl.Vec3
+	Max mgl.Vec3
+
+	// Links are the neighboring polygons the agent can walk to.
+	Links []Link
+}
+
+// NavMesh is a set of connected walkable polygons.
+type NavMesh struct {
+	Config   Config
+	Polygons []Polygon
+}
+
+// Build creates a navmesh from the world space triangles.
+func Build(cfg Config, vertices []mgl.Vec3, indexes []uint32) (*NavMesh, error) {
+	if cfg.CellSize <= 0.0 || cfg.CellHeight <= 0.0 {
+		return nil, fmt.Errorf("the navmesh cell size and height must be positive")
+	}
+	if len(indexes) < 3 {
+		return nil, fmt.Errorf("no triangles to build the navmesh from")
+	}
+
+	min, max := vertices[0], vertices[0]
+	for _, v := range vertices[1:] {
+		for j := 0; j < 3; j++ {
+			min[j] = float32(math.Min(float64(min[j]), float64(v[j])))
+			max[j] = float32(math.Max(float64(max[j]), float64(v[j])))
+		}
+	}
+
+	hf := newHeightfield(cfg, min, max)
+	minNormalY := float32(math.Cos(float64(mgl.DegToRad(cfg.MaxSlopeDegrees))))
+	for i := 0; i+2 < len(indexes); i += 3 {
+		a, b, c := vertices[indexes[i]], vertices[indexes[i+1]], vertices[indexes[i+2]]
+		normal := b.Sub(a).Cross(c.Sub(a))
+		walkable := false
+		if l := normal.Len(); l > 0.0 {
+			walkable = normal[1]/l >= minNormalY
+		}
+		hf.rasterizeTriangle(a, b, c, walkable)
+	}
+
+	hf.buildCells()
+	hf.erode()
+
+	nm := new(NavMesh)
+	nm.Config = cfg
+	nm.buildPolygons(hf)
+	if len(nm.Polygons) == 0 {
+		return nil, fmt.Errorf("no walkable area was found")
+	}
+	return nm, nil
+}
+
+// BuildFromRenderables creates a navmesh from the triangles of the
+// Renderables, which must have been created with fizzle.RetainGeometry set.
+func BuildFromRenderables(cfg Config, renderables ...*fizzle.Renderable) (*NavMesh, error) {
+	vertices := make([]mgl.Vec3, 0, 1024)
+	indexes := make([]uint32, 0, 1024)
+	for _, r := range renderables {
+		mesh, err := collision.NewTriMeshFromRenderable(r)
+		if err != nil {
+			return nil, err
+		}
+
+		// the collision mesh is in the Renderable's model space
+		transform := r.GetTransformMat4()
+		base := uint32(len(vertices))
+		for _, v := range mesh.Vertices {
+			vertices = append(vertices, transform.Mul4x1(v.Vec4(1.0)).Vec3())
+		}
+		for _, idx := range mesh.Indexes {
+			indexes = append(indexes, base+idx)
+		}
+	}
+	return Build(cfg, vertices, indexes)
+}
+
+// FindPolygon returns the index of the polygon under the point, or -1 if
+// the point isn't within MaxClimb above or below any polygon.
+func (nm *NavMesh) FindPolygon(p mgl.Vec3) int {
+	best := -1
+	bestDist := float32(math.MaxFloat32)
+	for i := range nm.Polygons {
+		poly := &nm.Polygons[i]
+		if p[0] < poly.Min[0] || p[0] > poly.Max[0] || p[2] < poly.Min[2] || p[2] > poly.Max[2] {
+			continue
+		}
+		var dist float32
+		if p[1] < poly.Min[1] {
+			dist = poly.Min[1] - p[1]
+		} else if p[1] > poly.Max[1] {
+			dist = p[1] - poly.Max[1]
+		}
+		if dist <= nm.Config.MaxClimb && dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// buildPolygons greedily merges the live cells into rectangles and links
+// the rectangles that have cells next to each other.
+func (nm *NavMesh) buildPolygons(hf *heightfield) {
+	// visit the cells row by row so the rectangles grow in a stable order
+	order := make([]int, 0, len(hf.cells))
+	for z := 0; z < hf.depth; z++ {
+		for x := 0; x < hf.width; x++ {
+			order = append(order, hf.columnCells[z*hf.width+x]...)
+		}
+	}
+
+	for _, start := range order {
+		if !hf.cells[start].alive || hf.cells[start].poly >= 0 {
+			continue
+		}
+		pi := len(nm.Polygons)
+
+		// grow along +x
+		row := []int{start}
+		for {
+			next := hf.neighbor(row[len(row)-1], 1)
+			if next < 0 || hf.cells[next].poly >= 0 {
+				break
+			}
+			row = append(row, next)
+		}
+
+		// grow along +z while the whole next row is free and connected
+		rows := [][]int{row}
+		for {
+			prev := rows[len(rows)-1]
+			nextRow := make([]int, len(prev))
+			okay := true
+			for i, ci := range prev {
+				n := hf.neighbor(ci, 3)
+				if n < 0 || hf.cells[n].poly >= 0 || (i > 0 && hf.neighbor(nextRow[i-1], 1) != n) {
+					okay = false
+					break
+				}
+				nextRow[i] = n
+			}
+			if !okay {
+				break
+			}
+			rows = append(rows, nextRow)
+		}
+
+		var poly Polygon
+		poly.Min = mgl.Vec3{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+		poly.Max = poly.Min.Mul(-1.0)
+		for _, r := range rows {
+			for _, ci := range r {
+				hf.cells[ci].poly = pi
+				y := hf.cells[ci].y
+				poly.Min[1] = float32(math.Min(float64(poly.Min[1]), float64(y)))
+				poly.Max[1] = float32(math.Max(float64(poly.Max[1]), float64(y)))
+			}
+		}
+
+		first := hf.cells[rows[0][0]]
+		last := rows[len(rows)-1]
+		x0, z0 := first.x, first.z
+		x1, z1 := x0+len(rows[0]), z0+len(rows)
+		poly.Vertices = []mgl.Vec3{
+			hf.cellCorner(x0, z0, first.y),
+			hf.cellCorner(x0, z1, hf.cells[last[0]].y),
+			hf.cellCorner(x1, z1, hf.cells[last[len(last)-1]].y),
+			hf.cellCorner(x1, z0, hf.cells[rows[0][len(rows[0])-1]].y),
+		}
+		for _, v := range poly.Vertices {
+			poly.Center = poly.Center.Add(v)
+		}
+		poly.Center = poly.Center.Mul(0.25)
+		poly.Min[0], poly.Min[2] = poly.Vertices[0][0], poly.Vertices[0][2]
+		poly.Max[0], poly.Max[2] = poly.Vertices[2][0], poly.Vertices[2][2]
+		nm.Polygons = append(nm.Polygons, poly)
+	}
+
+	nm.linkPolygons(hf)
+}
+
+// linkPolygons finds the portals between neighboring polygons from the
+// cell edges they share.
+func (nm *NavMesh) linkPolygons(hf *heightfield) {
+	type portal struct {
+		a, b mgl.Vec3
+		set  bool
+	}
+	portals := make(map[[2]int]*portal)
+	keys := make([][2]int, 0, len(nm.Polygons)*4)
+
+	for ci := range hf.cells {
+		c := &hf.cells[ci]
+		if !c.alive || c.poly < 0 {
+			continue
+		}
+		for d := range neighborOffsets {
+			ni := hf.neighbor(ci, d)
+			if ni < 0 || hf.cells[ni].poly == c.poly {
+				continue
+			}
+			n := &hf.cells[ni]
+
+			// the shared edge of the two cells
+			y := (c.y + n.y) * 0.5
+			var e0, e1 mgl.Vec3
+			switch d {
+			case 0:
+				e0, e1 = hf.cellCorner(c.x, c.z, y), hf.cellCorner(c.x, c.z+1, y)
+			case 1:
+				e0, e1 = hf.cellCorner(c.x+1, c.z, y), hf.cellCorner(c.x+1, c.z+1, y)
+			case 2:
+				e0, e1 = hf.cellCorner(c.x, c.z, y), hf.cellCorner(c.x+1, c.z, y)
+			default:
+				e0, e1 = hf.cellCorner(c.x, c.z+1, y), hf.cellCorner(c.x+1, c.z+1, y)
+			}
+
+			key := [2]int{c.poly, n.poly}
+			p, okay := portals[key]
+			if !okay {
+				p = &portal{a: e0, b: e1}
+				portals[key] = p
+				keys = append(keys, key)
+				continue
+			}
+
+			// extend the portal; the edges all lie on one grid line
+			if e0[0] < p.a[0] || e0[2] < p.a[2] {
+				p.a = e0
+			}
+			if e1[0] > p.b[0] || e1[2] > p.b[2] {
+				p.b = e1
+			}
+		}
+	}
+
+	for _, key := range keys {
+		p := portals[key]
+		from := &nm.Polygons[key[0]]
+		left, right := orientPortal(from.Center, p.a, p.b)
+		from.Links = append(from.Links, Link{To: key[1], Left: left, Right: right})
+	}
+}
+
+// orientPortal returns the ends of the portal ordered as left and right
+// when walking through it from the point.
+func orientPortal(from mgl.Vec3, a mgl.Vec3, b mgl.Vec3) (mgl.Vec3, mgl.Vec3) {
+	if triarea2(from, a, b) > 0.0 {
+		return a, b
+	}
+	return b, a
+}
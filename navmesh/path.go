@@ -0,0 +1,177 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package navmesh
+
+import (
+	"container/heap"
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// pathNode is the A* state of a polygon.
+type pathNode struct {
+	poly   int
+	cost   float32
+	total  float32
+	parent int
+	pos    mgl.Vec3
+	index  int
+	closed bool
+}
+
+// openList is a priority queue of nodes ordered by estimated total cost.
+type openList []*pathNode
+
+func (ol openList) Len() int            { return len(ol) }
+func (ol openList) Less(i, j int) bool  { return ol[i].total < ol[j].total }
+func (ol openList) Swap(i, j int)       { ol[i], ol[j] = ol[j], ol[i]; ol[i].index = i; ol[j].index = j }
+func (ol *openList) Push(x interface{}) { n := x.(*pathNode); n.index = len(*ol); *ol = append(*ol, n) }
+func (ol *openList) Pop() interface{} {
+	old := *ol
+	n := old[len(old)-1]
+	*ol = old[:len(old)-1]
+	return n
+}
+
+// FindPolygonPath returns the indexes of the polygons on the shortest path
+// between the two polygons using A*, or nil if they aren't connected.
+// Polygons are entered at the middle of the portal used to reach them.
+func (nm *NavMesh) FindPolygonPath(startPoly int, endPoly int, start mgl.Vec3, end mgl.Vec3) []int {
+	nodes := make(map[int]*pathNode)
+	open := make(openList, 0, 32)
+
+	first := &pathNode{poly: startPoly, parent: -1, pos: start, total: end.Sub(start).Len()}
+	nodes[startPoly] = first
+	heap.Push(&open, first)
+
+	for open.Len() > 0 {
+		current := heap.Pop(&open).(*pathNode)
+		current.closed = true
+		if current.poly == endPoly {
+			path := make([]int, 0, 16)
+			for n := current; n != nil; n = nodes[n.parent] {
+				path = append([]int{n.poly}, path...)
+				if n.parent < 0 {
+					break
+				}
+			}
+			return path
+		}
+
+		for _, link := range nm.Polygons[current.poly].Links {
+			pos := link.Left.Add(link.Right).Mul(0.5)
+			if link.To == endPoly {
+				pos = end
+			}
+			cost := current.cost + pos.Sub(current.pos).Len()
+
+			n, seen := nodes[link.To]
+			if seen && (n.closed || cost >= n.cost) {
+				continue
+			}
+			if !seen {
+				n = &pathNode{poly: link.To}
+				nodes[link.To] = n
+			}
+			n.cost = cost
+			n.total = cost + end.Sub(pos).Len()
+			n.parent = current.poly
+			n.pos = pos
+			if seen {
+				heap.Fix(&open, n.index)
+			} else {
+				heap.Push(&open, n)
+			}
+		}
+	}
+	return nil
+}
+
+// FindPath returns the corners of the shortest path between the points,
+// including both of them. An error is returned if either point is off the
+// navmesh or there's no path between them.
+func (nm *NavMesh) FindPath(start mgl.Vec3, end mgl.Vec3) ([]mgl.Vec3, error) {
+	startPoly := nm.FindPolygon(start)
+	if startPoly < 0 {
+		return nil, fmt.Errorf("the start point is not on the navmesh")
+	}
+	endPoly := nm.FindPolygon(end)
+	if endPoly < 0 {
+		return nil, fmt.Errorf("the end point is not on the navmesh")
+	}
+
+	polys := nm.FindPolygonPath(startPoly, endPoly, start, end)
+	if polys == nil {
+		return nil, fmt.Errorf("there is no path between the points")
+	}
+
+	// the portals between the polygons, with the end points as zero width portals
+	lefts := make([]mgl.Vec3, 0, len(polys)+1)
+	rights := make([]mgl.Vec3, 0, len(polys)+1)
+	lefts = append(lefts, start)
+	rights = append(rights, start)
+	for i := 0; i+1 < len(polys); i++ {
+		for _, link := range nm.Polygons[polys[i]].Links {
+			if link.To == polys[i+1] {
+				lefts = append(lefts, link.Left)
+				rights = append(rights, link.Right)
+				break
+			}
+		}
+	}
+	lefts = append(lefts, end)
+	rights = append(rights, end)
+
+	return stringPull(lefts, rights), nil
+}
+
+// stringPull runs the simple stupid funnel algorithm over the portals to
+// find the corners of the shortest path through them.
+func stringPull(lefts []mgl.Vec3, rights []mgl.Vec3) []mgl.Vec3 {
+	path := make([]mgl.Vec3, 0, 8)
+	apex, portalLeft, portalRight := lefts[0], lefts[0], rights[0]
+	apexIndex, leftIndex, rightIndex := 0, 0, 0
+	path = append(path, apex)
+
+	for i := 1; i < len(lefts); i++ {
+		left, right := lefts[i], rights[i]
+
+		// narrow the right side of the funnel
+		if triarea2(apex, portalRight, right) <= 0.0 {
+			if apex == portalRight || triarea2(apex, portalLeft, right) > 0.0 {
+				portalRight, rightIndex = right, i
+			} else {
+				// the right side crossed the left, so the left is a corner
+				path = append(path, portalLeft)
+				apex, apexIndex = portalLeft, leftIndex
+				portalLeft, portalRight = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+
+		// narrow the left side of the funnel
+		if triarea2(apex, portalLeft, left) >= 0.0 {
+			if apex == portalLeft || triarea2(apex, portalRight, left) < 0.0 {
+				portalLeft, leftIndex = left, i
+			} else {
+				// the left side crossed the right, so the right is a corner
+				path = append(path, portalRight)
+				apex, apexIndex = portalRight, rightIndex
+				portalLeft, portalRight = apex, apex
+				leftIndex, rightIndex = apexIndex, apexIndex
+				i = apexIndex
+				continue
+			}
+		}
+	}
+
+	end := lefts[len(lefts)-1]
+	if path[len(path)-1] != end {
+		path = append(path, end)
+	}
+	return path
+}
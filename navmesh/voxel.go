@@ -0,0 +1,226 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package navmesh
+
+import (
+	"math"
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// sample is a surface height found in a column of the heightfield.
+type sample struct {
+	y        float32
+	walkable bool
+}
+
+// samplesByHeight sorts the samples of a column from the lowest up.
+type samplesByHeight []sample
+
+func (s samplesByHeight) Len() int           { return len(s) }
+func (s samplesByHeight) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s samplesByHeight) Less(i, j int) bool { return s[i].y < s[j].y }
+
+// cell is a walkable surface in the heightfield that an agent can stand on.
+type cell struct {
+	x, z int
+	y    float32
+
+	// neighbors are the cell indexes in the -x, +x, -z, +z directions or -1
+	neighbors [4]int
+
+	// poly is the polygon the cell was merged into or -1
+	poly  int
+	alive bool
+}
+
+// direction offsets in the order of cell.neighbors
+var neighborOffsets = [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+
+// heightfield is the voxelized scene: every column of the grid has the
+// surface heights that were found in it, lowest first.
+type heightfield struct {
+	cfg     Config
+	origin  mgl.Vec3
+	width   int
+	depth   int
+	columns [][]sample
+
+	cells []cell
+
+	// columnCells holds the indexes of the cells in each column
+	columnCells [][]int
+}
+
+// newHeightfield creates an empty heightfield covering the bounds.
+func newHeightfield(cfg Config, min mgl.Vec3, max mgl.Vec3) *heightfield {
+	hf := new(heightfield)
+	hf.cfg = cfg
+	hf.origin = min
+	hf.width = int(math.Ceil(float64((max[0]-min[0])/cfg.CellSize))) + 1
+	hf.depth = int(math.Ceil(float64((max[2]-min[2])/cfg.CellSize))) + 1
+	hf.columns = make([][]sample, hf.width*hf.depth)
+	return hf
+}
+
+// addSample records a surface height in the column containing the point.
+func (hf *heightfield) addSample(p mgl.Vec3, walkable bool) {
+	x := int((p[0] - hf.origin[0]) / hf.cfg.CellSize)
+	z := int((p[2] - hf.origin[2]) / hf.cfg.CellSize)
+	if x < 0 || z < 0 || x >= hf.width || z >= hf.depth {
+		return
+	}
+	i := z*hf.width + x
+	hf.columns[i] = append(hf.columns[i], sample{y: p[1], walkable: walkable})
+}
+
+// rasterizeTriangle samples the triangle at the center of every column it
+// covers and along its edges, which catches walls that cover no centers.
+func (hf *heightfield) rasterizeTriangle(a, b, c mgl.Vec3, walkable bool) {
+	cs := hf.cfg.CellSize
+	minX := float32(math.Min(float64(a[0]), math.Min(float64(b[0]), float64(c[0]))))
+	maxX := float32(math.Max(float64(a[0]), math.Max(float64(b[0]), float64(c[0]))))
+	minZ := float32(math.Min(float64(a[2]), math.Min(float64(b[2]), float64(c[2]))))
+	maxZ := float32(math.Max(float64(a[2]), math.Max(float64(b[2]), float64(c[2]))))
+
+	x0 := int(math.Floor(float64((minX - hf.origin[0]) / cs)))
+	x1 := int(math.Ceil(float64((maxX - hf.origin[0]) / cs)))
+	z0 := int(math.Floor(float64((minZ - hf.origin[2]) / cs)))
+	z1 := int(math.Ceil(float64((maxZ - hf.origin[2]) / cs)))
+
+	// the triangle's area in the XZ plane for the barycentric coordinates
+	area := triarea2(a, b, c)
+	if area*area > 1e-12 {
+		for z := z0; z <= z1; z++ {
+			for x := x0; x <= x1; x++ {
+				p := mgl.Vec3{hf.origin[0] + (float32(x)+0.5)*cs, 0.0, hf.origin[2] + (float32(z)+0.5)*cs}
+				u := triarea2(b, c, p) / area
+				v := triarea2(c, a, p) / area
+				w := 1.0 - u - v
+				if u < 0.0 || v < 0.0 || w < 0.0 {
+					continue
+				}
+				p[1] = a[1]*u + b[1]*v + c[1]*w
+				hf.addSample(p, walkable)
+			}
+		}
+	}
+
+	for _, edge := range [3][2]mgl.Vec3{{a, b}, {b, c}, {c, a}} {
+		length := edge[1].Sub(edge[0]).Len()
+		steps := int(length/(cs*0.5)) + 1
+		for s := 0; s <= steps; s++ {
+			t := float32(s) / float32(steps)
+			hf.addSample(edge[0].Add(edge[1].Sub(edge[0]).Mul(t)), walkable)
+		}
+	}
+}
+
+// buildCells merges the samples of each column and makes a cell for every
+// walkable surface with enough clearance above it for an agent.
+func (hf *heightfield) buildCells() {
+	cfg := hf.cfg
+	hf.columnCells = make([][]int, len(hf.columns))
+	for i, col := range hf.columns {
+		if len(col) == 0 {
+			continue
+		}
+		sort.Sort(samplesByHeight(col))
+
+		// samples within a cell height of each other are the same surface
+		// and the top one of the cluster decides if it's walkable
+		merged := col[:1]
+		for _, s := range col[1:] {
+			last := &merged[len(merged)-1]
+			if s.y-last.y <= cfg.CellHeight {
+				last.y = s.y
+				last.walkable = s.walkable
+				continue
+			}
+			merged = append(merged, s)
+		}
+		hf.columns[i] = merged
+
+		for j, s := range merged {
+			if !s.walkable {
+				continue
+			}
+			if j+1 < len(merged) && merged[j+1].y-s.y < cfg.AgentHeight {
+				continue
+			}
+			hf.columnCells[i] = append(hf.columnCells[i], len(hf.cells))
+			hf.cells = append(hf.cells, cell{x: i % hf.width, z: i / hf.width, y: s.y, poly: -1, alive: true})
+		}
+	}
+
+	// connect the cells to the neighbors an agent can step to
+	for ci := range hf.cells {
+		c := &hf.cells[ci]
+		for d, off := range neighborOffsets {
+			c.neighbors[d] = -1
+			nx, nz := c.x+off[0], c.z+off[1]
+			if nx < 0 || nz < 0 || nx >= hf.width || nz >= hf.depth {
+				continue
+			}
+			best := float32(math.MaxFloat32)
+			for _, ni := range hf.columnCells[nz*hf.width+nx] {
+				dy := float32(math.Abs(float64(hf.cells[ni].y - c.y)))
+				if dy <= cfg.MaxClimb && dy < best {
+					best = dy
+					c.neighbors[d] = ni
+				}
+			}
+		}
+	}
+}
+
+// erode removes the cells closer than the agent radius to an edge so the
+// agent doesn't clip walls and drops.
+func (hf *heightfield) erode() {
+	steps := int(math.Ceil(float64(hf.cfg.AgentRadius / hf.cfg.CellSize)))
+	for s := 0; s < steps; s++ {
+		border := make([]int, 0, 64)
+		for ci := range hf.cells {
+			c := &hf.cells[ci]
+			if !c.alive {
+				continue
+			}
+			for _, ni := range c.neighbors {
+				if ni < 0 || !hf.cells[ni].alive {
+					border = append(border, ci)
+					break
+				}
+			}
+		}
+		for _, ci := range border {
+			hf.cells[ci].alive = false
+		}
+	}
+}
+
+// neighbor returns the live neighbor of the cell in the direction or -1.
+func (hf *heightfield) neighbor(ci int, dir int) int {
+	ni := hf.cells[ci].neighbors[dir]
+	if ni < 0 || !hf.cells[ni].alive {
+		return -1
+	}
+	return ni
+}
+
+// cellCorner returns the world position of the corner of the grid at (x, z)
+// with the height.
+func (hf *heightfield) cellCorner(x int, z int, y float32) mgl.Vec3 {
+	cs := hf.cfg.CellSize
+	return mgl.Vec3{hf.origin[0] + float32(x)*cs, y, hf.origin[2] + float32(z)*cs}
+}
+
+// triarea2 returns twice the signed area of the triangle in the XZ plane.
+func triarea2(a, b, c mgl.Vec3) float32 {
+	ax := b[0] - a[0]
+	az := b[2] - a[2]
+	bx := c[0] - a[0]
+	bz := c[2] - a[2]
+	return bx*az - ax*bz
+}
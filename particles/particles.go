@@ -11,6 +11,7 @@ import (
 	mgl "github.com/go-gl/mathgl/mgl32"
 	fizzle "github.com/tbogdala/fizzle"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/jobs"
 	renderer "github.com/tbogdala/fizzle/renderer"
 )
 
@@ -52,8 +53,12 @@ type System struct {
 	Origin     mgl.Vec3
 	IsActive   bool
 	IsEmitting bool
-	gfx        graphics.GraphicsProvider
-	runtime    float64
+
+	// Jobs is an optional job scheduler used to update the emitters in parallel.
+	Jobs *jobs.Scheduler
+
+	gfx     graphics.GraphicsProvider
+	runtime float64
 }
 
 // ParticleSpawner is a type of interface for objects that are able to spawn
@@ -178,12 +183,27 @@ func (s *System) NewEmitter(optProps *EmitterProperties) *Emitter {
 func (s *System) Update(frameDelta float64) {
 	if s.IsActive {
 		s.runtime += frameDelta
+		if s.Jobs != nil {
+			// emitters don't share any state so they can be updated in parallel
+			s.Jobs.ParallelFor(len(s.Emitters), 1, func(start, end int) {
+				for _, emitter := range s.Emitters[start:end] {
+					emitter.Update(frameDelta)
+				}
+			})
+			return
+		}
 		for _, emitter := range s.Emitters {
 			emitter.Update(frameDelta)
 		}
 	}
 }
 
+// UpdateWithClock updates the emitters by the clock's scaled frame delta so
+// that the particles respect the engine's time scale and pausing.
+func (s *System) UpdateWithClock(clock *fizzle.Clock) {
+	s.Update(clock.Delta())
+}
+
 // Draw renders all particle emitters.
 func (s *System) Draw(projection mgl.Mat4, view mgl.Mat4) {
 	for _, emitter := range s.Emitters {
@@ -0,0 +1,192 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package physics
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/cubez"
+	m "github.com/tbogdala/cubez/math"
+	"github.com/tbogdala/fizzle"
+)
+
+// Shape is the kind of collision shape fitted to a Renderable's bounds.
+type Shape int
+
+const (
+	// ShapeBox fits a box to the bounding rectangle.
+	ShapeBox Shape = iota
+
+	// ShapeSphere fits a sphere around the bounding rectangle.
+	ShapeSphere
+)
+
+// BodyType controls which way the transform is synchronized.
+type BodyType int
+
+const (
+	// Dynamic bodies are moved by the simulation, which moves the Renderable.
+	Dynamic BodyType = iota
+
+	// Kinematic bodies follow their Renderable every step and push dynamic
+	// bodies out of the way, like moving platforms.
+	Kinematic
+
+	// Static bodies don't move but other bodies collide with them.
+	Static
+)
+
+// RigidBody connects a cubez rigid body to a Renderable.
+type RigidBody struct {
+	Type       BodyType
+	Renderable *fizzle.Renderable
+	Body       *cubez.RigidBody
+	Collider   cubez.Collider
+
+	// UserData is for client code to associate its own objects with the body.
+	UserData interface{}
+
+	// center is the offset from the Renderable's origin to the center of
+	// the collision shape in the Renderable's local space
+	center mgl.Vec3
+
+	prevPosition mgl.Vec3
+	prevRotation mgl.Quat
+
+	// the transform last written to the Renderable, to detect outside changes
+	writtenLocation mgl.Vec3
+	writtenRotation mgl.Quat
+}
+
+// newRigidBody creates the body and fits the collision shape to the Renderable.
+func newRigidBody(r *fizzle.Renderable, shape Shape, mass float32) *RigidBody {
+	rb := new(RigidBody)
+	rb.Renderable = r
+	rb.Body = cubez.NewRigidBody()
+
+	br := r.BoundingRect
+	halfSize := mgl.Vec3{
+		br.DeltaX() * 0.5 * r.Scale[0],
+		br.DeltaY() * 0.5 * r.Scale[1],
+		br.DeltaZ() * 0.5 * r.Scale[2],
+	}
+	rb.center = br.Bottom.Add(br.Top).Mul(0.5)
+	rb.center = mgl.Vec3{rb.center[0] * r.Scale[0], rb.center[1] * r.Scale[1], rb.center[2] * r.Scale[2]}
+
+	var inertia m.Matrix3
+	switch shape {
+	case ShapeSphere:
+		radius := halfSize.Len()
+		rb.Collider = cubez.NewCollisionSphere(rb.Body, m.Real(radius))
+		i := m.Real(0.4 * mass * radius * radius)
+		inertia.SetInertiaTensorCoeffs(i, i, i, 0.0, 0.0, 0.0)
+	default:
+		hs := toVector3(halfSize)
+		rb.Collider = cubez.NewCollisionCube(rb.Body, hs)
+		inertia.SetBlockInertiaTensor(&hs, m.Real(mass))
+	}
+
+	if mass > 0.0 {
+		rb.Type = Dynamic
+		rb.Body.SetMass(m.Real(mass))
+		rb.Body.SetInertiaTensor(&inertia)
+	} else {
+		rb.Type = Static
+		rb.Body.SetInfiniteMass()
+	}
+
+	rb.pullFromRenderable(true)
+	rb.savePrevious()
+	rb.writtenLocation = r.Location
+	rb.writtenRotation = r.LocalRotation
+	return rb
+}
+
+// SetType changes how the body is simulated. Kinematic and static bodies
+// have infinite mass.
+func (rb *RigidBody) SetType(t BodyType, mass float32) {
+	rb.Type = t
+	if t == Dynamic && mass > 0.0 {
+		rb.Body.SetMass(m.Real(mass))
+	} else {
+		rb.Body.SetInfiniteMass()
+	}
+	rb.Body.SetAwake(true)
+}
+
+// GetPosition returns the simulated position of the center of the body.
+func (rb *RigidBody) GetPosition() mgl.Vec3 {
+	return fromVector3(rb.Body.Position)
+}
+
+// GetVelocity returns the linear velocity of the body.
+func (rb *RigidBody) GetVelocity() mgl.Vec3 {
+	return fromVector3(rb.Body.Velocity)
+}
+
+// SetVelocity sets the linear velocity of the body and wakes it up.
+func (rb *RigidBody) SetVelocity(v mgl.Vec3) {
+	rb.Body.Velocity = toVector3(v)
+	rb.Body.SetAwake(true)
+}
+
+// AddForce applies a force through the center of mass for the next step.
+func (rb *RigidBody) AddForce(force mgl.Vec3) {
+	f := toVector3(force)
+	rb.Body.AddForce(&f)
+	rb.Body.SetAwake(true)
+}
+
+// applyGravity sets the constant acceleration of dynamic bodies.
+func (rb *RigidBody) applyGravity(gravity mgl.Vec3) {
+	if rb.Type == Dynamic {
+		rb.Body.Acceleration = toVector3(gravity)
+	}
+}
+
+// savePrevious records the current state for interpolation.
+func (rb *RigidBody) savePrevious() {
+	rb.prevPosition = fromVector3(rb.Body.Position)
+	rb.prevRotation = fromQuat(rb.Body.Orientation)
+}
+
+// pullFromRenderable moves the body to the Renderable's transform. Unless
+// force is set, this only happens if the Renderable was moved since the
+// simulation last wrote to it.
+func (rb *RigidBody) pullFromRenderable(force bool) {
+	r := rb.Renderable
+	if !force && r.Location == rb.writtenLocation && r.LocalRotation == rb.writtenRotation {
+		return
+	}
+
+	rb.Body.Position = toVector3(r.Location.Add(r.LocalRotation.Rotate(rb.center)))
+	rb.Body.Orientation = toQuat(r.LocalRotation)
+	rb.Body.CalculateDerivedData()
+	rb.Body.SetAwake(true)
+
+	// a teleport shouldn't be interpolated from the old state
+	if !force {
+		rb.savePrevious()
+		rb.writtenLocation = r.Location
+		rb.writtenRotation = r.LocalRotation
+	}
+}
+
+// pushToRenderable moves the Renderable to the body state interpolated by
+// alpha between the previous and current steps.
+func (rb *RigidBody) pushToRenderable(alpha float32) {
+	if rb.Type != Dynamic {
+		return
+	}
+
+	curPos := fromVector3(rb.Body.Position)
+	curRot := fromQuat(rb.Body.Orientation)
+	pos := rb.prevPosition.Add(curPos.Sub(rb.prevPosition).Mul(alpha))
+	rot := mgl.QuatSlerp(rb.prevRotation, curRot, alpha)
+
+	r := rb.Renderable
+	r.LocalRotation = rot
+	r.Location = pos.Sub(rot.Rotate(rb.center))
+	rb.writtenLocation = r.Location
+	rb.writtenRotation = r.LocalRotation
+}
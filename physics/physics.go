@@ -0,0 +1,197 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The physics module bridges fizzle Renderables and the cubez rigid body
+physics library. Rigid bodies get their collision shape from the bounds of
+a Renderable and keep the two transforms in sync: the simulation moves
+the Renderable and moving the Renderable from game or editor code moves
+the body. The simulation runs at a fixed step on a renderer.FixedTimestep
+and the Renderables are interpolated between steps.
+
+Body orientations map to Renderable.LocalRotation since that rotation is
+applied around the object's own origin. Only root Renderables, without a
+Parent, are supported.
+
+*/
+
+package physics
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/cubez"
+	m "github.com/tbogdala/cubez/math"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/renderer"
+)
+
+const (
+	// DefaultStep is the default simulation step size in seconds.
+	DefaultStep = 1.0 / 60.0
+
+	// DefaultContactIterations is the default number of contact resolution
+	// iterations done per contact in each step.
+	DefaultContactIterations = 4
+)
+
+// World is a collection of rigid bodies and static colliders simulated together.
+type World struct {
+	// Gravity is the acceleration applied to all dynamic bodies.
+	Gravity mgl.Vec3
+
+	// ContactIterations is how many resolution iterations are done per
+	// contact in each step.
+	ContactIterations int
+
+	// Timestep runs the simulation at a fixed step.
+	Timestep *renderer.FixedTimestep
+
+	// OnContact, if set, is called for every pair of bodies touching after a
+	// step. The static colliders are reported with a nil body.
+	OnContact func(a *RigidBody, b *RigidBody)
+
+	bodies   []*RigidBody
+	statics  []cubez.Collider
+	contacts []*cubez.Contact
+}
+
+// NewWorld creates a new world with earth gravity stepping at DefaultStep.
+func NewWorld() *World {
+	w := new(World)
+	w.Gravity = mgl.Vec3{0.0, -9.8, 0.0}
+	w.ContactIterations = DefaultContactIterations
+	// DefaultStep is positive, so this can't fail
+	w.Timestep, _ = renderer.NewFixedTimestep(DefaultStep)
+	w.bodies = make([]*RigidBody, 0, 64)
+	w.statics = make([]cubez.Collider, 0, 4)
+	w.contacts = make([]*cubez.Contact, 0, 64)
+	return w
+}
+
+// AddRigidBody creates a body for the Renderable with a shape fitted to its
+// bounding rectangle and adds it to the world. A mass of zero or less makes
+// a static body.
+func (w *World) AddRigidBody(r *fizzle.Renderable, shape Shape, mass float32) *RigidBody {
+	rb := newRigidBody(r, shape, mass)
+	rb.applyGravity(w.Gravity)
+	w.bodies = append(w.bodies, rb)
+	return rb
+}
+
+// RemoveRigidBody takes the body out of the world.
+func (w *World) RemoveRigidBody(rb *RigidBody) {
+	for i, b := range w.bodies {
+		if b == rb {
+			copy(w.bodies[i:], w.bodies[i+1:])
+			w.bodies[len(w.bodies)-1] = nil
+			w.bodies = w.bodies[:len(w.bodies)-1]
+			return
+		}
+	}
+}
+
+// GetRigidBodies returns the bodies in the world.
+func (w *World) GetRigidBodies() []*RigidBody {
+	return w.bodies
+}
+
+// AddGroundPlane adds an infinite static plane with the normal at the
+// distance along the normal from the origin.
+func (w *World) AddGroundPlane(normal mgl.Vec3, offset float32) {
+	w.statics = append(w.statics, cubez.NewCollisionPlane(toVector3(normal), m.Real(offset)))
+}
+
+// AddStaticCollider adds any cubez collider that doesn't move, such as the
+// colliders built from level geometry.
+func (w *World) AddStaticCollider(c cubez.Collider) {
+	w.statics = append(w.statics, c)
+}
+
+// UpdateWithClock advances the simulation by the clock's scaled frame delta.
+func (w *World) UpdateWithClock(clock *fizzle.Clock) {
+	w.Update(clock.Delta())
+}
+
+// Update advances the simulation by the frame time in seconds, running as
+// many fixed steps as fit, and then moves the Renderables to the states
+// interpolated between the last two steps.
+func (w *World) Update(frameDelta float64) {
+	// pick up any Renderables moved outside of the simulation
+	for _, rb := range w.bodies {
+		rb.pullFromRenderable(false)
+	}
+
+	alpha := w.Timestep.Advance(frameDelta, w.Step)
+
+	for _, rb := range w.bodies {
+		rb.pushToRenderable(float32(alpha))
+	}
+}
+
+// Step runs a single simulation step of dt seconds. Update calls this as
+// needed, but it can also be called directly for manual stepping.
+func (w *World) Step(dt float64) {
+	duration := m.Real(dt)
+
+	for _, rb := range w.bodies {
+		rb.savePrevious()
+		switch rb.Type {
+		case Kinematic:
+			rb.pullFromRenderable(true)
+		case Dynamic:
+			rb.applyGravity(w.Gravity)
+			rb.Body.Integrate(duration)
+		}
+		rb.Collider.CalculateDerivedData()
+	}
+
+	// brute force collision detection between every pair
+	w.contacts = w.contacts[:0]
+	for i, a := range w.bodies {
+		for _, b := range w.bodies[i+1:] {
+			if a.Type != Dynamic && b.Type != Dynamic {
+				continue
+			}
+			w.collide(a, b, a.Collider, b.Collider)
+		}
+		if a.Type == Dynamic {
+			for _, s := range w.statics {
+				w.collide(a, nil, a.Collider, s)
+			}
+		}
+	}
+
+	if len(w.contacts) > 0 {
+		cubez.ResolveContacts(len(w.contacts)*w.ContactIterations, w.contacts, duration)
+	}
+}
+
+// collide checks two colliders for contacts and reports them.
+func (w *World) collide(a *RigidBody, b *RigidBody, ca cubez.Collider, cb cubez.Collider) {
+	var found bool
+	found, w.contacts = ca.CheckAgainst(cb, w.contacts)
+	if found && w.OnContact != nil {
+		w.OnContact(a, b)
+	}
+}
+
+// toVector3 converts a mathgl vector to a cubez vector.
+func toVector3(v mgl.Vec3) m.Vector3 {
+	return m.Vector3{m.Real(v[0]), m.Real(v[1]), m.Real(v[2])}
+}
+
+// fromVector3 converts a cubez vector to a mathgl vector.
+func fromVector3(v m.Vector3) mgl.Vec3 {
+	return mgl.Vec3{float32(v[0]), float32(v[1]), float32(v[2])}
+}
+
+// toQuat converts a mathgl quaternion to a cubez quaternion, which stores W first.
+func toQuat(q mgl.Quat) m.Quat {
+	return m.Quat{m.Real(q.W), m.Real(q.V[0]), m.Real(q.V[1]), m.Real(q.V[2])}
+}
+
+// fromQuat converts a cubez quaternion to a mathgl quaternion.
+func fromQuat(q m.Quat) mgl.Quat {
+	return mgl.Quat{W: float32(q[0]), V: mgl.Vec3{float32(q[1]), float32(q[2]), float32(q[3])}}
+}
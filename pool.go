@@ -0,0 +1,155 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// DefaultPoolSize is the default maximum number of free objects a pool
+// will hold on to before it starts releasing them.
+const DefaultPoolSize = 64
+
+// BufferPool is a free-list of OpenGL buffer objects meant for dynamic data
+// that gets uploaded every frame, such as debug lines or particles. Reusing
+// buffers avoids creating and deleting GL objects each frame.
+// NOTE: like the rest of the GL calls, this is not safe for concurrent use.
+type BufferPool struct {
+	// MaxFree is the maximum number of buffers kept in the free-list; buffers
+	// returned to a full pool are deleted.
+	MaxFree int
+
+	free []graphics.Buffer
+}
+
+// NewBufferPool creates a new, empty buffer pool.
+func NewBufferPool() *BufferPool {
+	bp := new(BufferPool)
+	bp.MaxFree = DefaultPoolSize
+	bp.free = make([]graphics.Buffer, 0, DefaultPoolSize)
+	return bp
+}
+
+// Get returns a buffer from the free-list or creates a new one if the
+// pool is empty.
+func (bp *BufferPool) Get() graphics.Buffer {
+	if len(bp.free) == 0 {
+		return gfx.GenBuffer()
+	}
+	last := len(bp.free) - 1
+	b := bp.free[last]
+	bp.free = bp.free[:last]
+	return b
+}
+
+// Put returns the buffer to the pool so that it can be reused.
+func (bp *BufferPool) Put(b graphics.Buffer) {
+	if b == 0 {
+		return
+	}
+	if len(bp.free) >= bp.MaxFree {
+		gfx.DeleteBuffer(b)
+		return
+	}
+	bp.free = append(bp.free, b)
+}
+
+// Len returns the number of buffers currently in the free-list.
+func (bp *BufferPool) Len() int {
+	return len(bp.free)
+}
+
+// Destroy deletes all of the buffers in the free-list.
+func (bp *BufferPool) Destroy() {
+	for _, b := range bp.free {
+		gfx.DeleteBuffer(b)
+	}
+	bp.free = bp.free[:0]
+}
+
+// RenderablePool is a free-list of Renderable objects for short-lived
+// renderables. The RenderableCore of a pooled Renderable, including its VAO
+// and VBOs, is kept so that new data can be uploaded into the existing
+// buffers with BufferData instead of generating new GL objects.
+// NOTE: like the rest of the GL calls, this is not safe for concurrent use.
+type RenderablePool struct {
+	// MaxFree is the maximum number of renderables kept in the free-list;
+	// renderables returned to a full pool are destroyed.
+	MaxFree int
+
+	free []*Renderable
+}
+
+// NewRenderablePool creates a new, empty renderable pool.
+func NewRenderablePool() *RenderablePool {
+	rp := new(RenderablePool)
+	rp.MaxFree = DefaultPoolSize
+	rp.free = make([]*Renderable, 0, DefaultPoolSize)
+	return rp
+}
+
+// Get returns a Renderable from the free-list, reset to the same default
+// transform and visibility as NewRenderable, or creates a new one if the pool
+// is empty. The Core of a reused Renderable still holds its previous buffers
+// and material settings.
+func (rp *RenderablePool) Get() *Renderable {
+	if len(rp.free) == 0 {
+		return NewRenderable()
+	}
+	last := len(rp.free) - 1
+	r := rp.free[last]
+	rp.free[last] = nil
+	rp.free = rp.free[:last]
+
+	r.FaceCount = 0
+	r.Location = mgl.Vec3{0.0, 0.0, 0.0}
+	r.Scale = mgl.Vec3{1.0, 1.0, 1.0}
+	r.Rotation = mgl.QuatIdent()
+	r.LocalRotation = mgl.QuatIdent()
+	r.AnimationTime = 0.0
+	r.BoundingRect = Rectangle3D{}
+	r.CullBounds = Rectangle3D{}
+	r.IsVisible = true
+	r.IsGroup = false
+	r.CastShadow = true
+	r.ReceiveShadow = true
+	r.ViewLayer = 0
+	r.Material = nil
+	return r
+}
+
+// Put returns the Renderable to the pool so that it can be reused. Children
+// are detached from the renderable but are not pooled themselves. Renderables
+// whose core was already destroyed are ignored.
+func (rp *RenderablePool) Put(r *Renderable) {
+	if r == nil || r.Core == nil || r.Core.IsDestroyed {
+		return
+	}
+	if len(rp.free) >= rp.MaxFree {
+		r.Destroy()
+		return
+	}
+
+	for _, child := range r.Children {
+		child.Parent = nil
+	}
+	r.Children = r.Children[:0]
+	r.Parent = nil
+	rp.free = append(rp.free, r)
+}
+
+// Len returns the number of renderables currently in the free-list.
+func (rp *RenderablePool) Len() int {
+	return len(rp.free)
+}
+
+// Destroy releases the GL objects of all of the renderables in the free-list.
+func (rp *RenderablePool) Destroy() {
+	for i, r := range rp.free {
+		r.Destroy()
+		rp.free[i] = nil
+	}
+	rp.free = rp.free[:0]
+}
@@ -168,6 +168,9 @@ func createPlane(x0, y0, x1, y1 float32, verts [12]float32, indexes [6]uint32, u
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
 	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gfx.Ptr(&indexes[0]), graphics.STATIC_DRAW)
 
+	// keep a copy of the geometry around if requested
+	r.Core.retainInterleavedGeometry(vnutBuffer, 3+3+2+3, 3, 6, 8, indexes[:])
+
 	return r
 }
 
@@ -274,6 +277,9 @@ func CreateCube(xmin, ymin, zmin, xmax, ymax, zmax float32) *Renderable {
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
 	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gfx.Ptr(&indexes[0]), graphics.STATIC_DRAW)
 
+	// keep a copy of the geometry around if requested
+	r.Core.retainInterleavedGeometry(vnutBuffer, 3+3+2+3, 3, 6, 8, indexes[:])
+
 	return r
 }
 
@@ -332,12 +338,20 @@ func CreateWireframeCube(xmin, ymin, zmin, xmax, ymax, zmax float32) *Renderable
 // CreateLine makes a line between a two points
 // rendered as graphics.LINES.
 func CreateLine(x0, y0, z0, x1, y1, z1 float32) *Renderable {
+	r := NewRenderable()
+	r.Core = NewRenderableCore()
+	SetLine(r, x0, y0, z0, x1, y1, z1)
+	return r
+}
+
+// SetLine uploads the line segment into the Renderable's existing VBOs,
+// creating them if needed, so that renderables taken from a RenderablePool
+// can be reused for debug lines without generating new GL objects.
+func SetLine(r *Renderable, x0, y0, z0, x1, y1, z1 float32) {
 	// calculate the memory size of floats used to calculate total memory size of float arrays
 	const floatSize = 4
 	const uintSize = 4
 
-	r := NewRenderable()
-	r.Core = NewRenderableCore()
 	r.FaceCount = 1 //one line one face
 
 	verts := [...]float32{
@@ -348,11 +362,61 @@ func CreateLine(x0, y0, z0, x1, y1, z1 float32) *Renderable {
 	}
 
 	// create a VBO to hold the vertex data
-	r.Core.VertVBO = gfx.GenBuffer()
+	if r.Core.VertVBO == 0 {
+		r.Core.VertVBO = gfx.GenBuffer()
+	}
+	r.Core.VBOStride = 0
+	r.Core.VertVBOOffset = 0
 	gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.VertVBO)
-	gfx.BufferData(graphics.ARRAY_BUFFER, floatSize*len(verts), gfx.Ptr(&verts[0]), graphics.STATIC_DRAW)
+	gfx.BufferData(graphics.ARRAY_BUFFER, floatSize*len(verts), gfx.Ptr(&verts[0]), graphics.DYNAMIC_DRAW)
 
 	// create a VBO to hold the face indexes
+	if r.Core.ElementsVBO == 0 {
+		r.Core.ElementsVBO = gfx.GenBuffer()
+	}
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
+	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gfx.Ptr(&indexes[0]), graphics.DYNAMIC_DRAW)
+}
+
+// CreatePointCloud makes a Renderable with one vertex per point designed to
+// be rendered as graphics.POINTS. The colors are optional but, if supplied,
+// must have one color per point. FaceCount is set to the number of points.
+func CreatePointCloud(points []mgl.Vec3, colors []mgl.Vec4) *Renderable {
+	// calculate the memory size of floats used to calculate total memory size of float arrays
+	const floatSize = 4
+	const uintSize = 4
+
+	r := NewRenderable()
+	r.Core = NewRenderableCore()
+	r.FaceCount = uint32(len(points))
+	if len(points) == 0 {
+		return r
+	}
+
+	indexes := make([]uint32, len(points))
+	r.BoundingRect.Bottom = points[0]
+	r.BoundingRect.Top = points[0]
+	for i, p := range points {
+		indexes[i] = uint32(i)
+		for axis := 0; axis < 3; axis++ {
+			r.BoundingRect.Bottom[axis] = float32(math.Min(float64(r.BoundingRect.Bottom[axis]), float64(p[axis])))
+			r.BoundingRect.Top[axis] = float32(math.Max(float64(r.BoundingRect.Top[axis]), float64(p[axis])))
+		}
+	}
+
+	// create a VBO to hold the vertex data
+	r.Core.VertVBO = gfx.GenBuffer()
+	gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.VertVBO)
+	gfx.BufferData(graphics.ARRAY_BUFFER, floatSize*3*len(points), gfx.Ptr(&points[0][0]), graphics.STATIC_DRAW)
+
+	// create a VBO to hold the color data
+	if len(colors) == len(points) {
+		r.Core.ColorVBO = gfx.GenBuffer()
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.ColorVBO)
+		gfx.BufferData(graphics.ARRAY_BUFFER, floatSize*4*len(colors), gfx.Ptr(&colors[0][0]), graphics.STATIC_DRAW)
+	}
+
+	// create a VBO to hold the point indexes
 	r.Core.ElementsVBO = gfx.GenBuffer()
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
 	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gfx.Ptr(&indexes[0]), graphics.STATIC_DRAW)
@@ -602,6 +666,9 @@ func CreateSphere(radius float32, rings int, sectors int) *Renderable {
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
 	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gfx.Ptr(&indexes[0]), graphics.STATIC_DRAW)
 
+	// keep a copy of the geometry around if requested
+	r.Core.retainInterleavedGeometry(vnutBuffer, 3+3+2, 3, 6, -1, indexes)
+
 	return r
 }
 
@@ -842,6 +909,9 @@ func CreateCubeMappedSphere(gridSize int, radius float32, cubemapUvs bool) *Rend
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
 	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexes), gfx.Ptr(&indexes[0]), graphics.STATIC_DRAW)
 
+	// keep a copy of the geometry around if requested
+	r.Core.retainInterleavedGeometry(vnutBuffer, 3+3+2, 3, 6, -1, indexes)
+
 	return r
 }
 
@@ -0,0 +1,334 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The profiler module measures named, nested scopes of a frame on the CPU and,
+when a graphics provider with timer query support is supplied, on the GPU.
+
+GPU results arrive a few frames after the commands are issued, so the
+reports for a frame are only finished once all of its queries are available.
+All of the Profiler methods are safe to call on a nil *Profiler, which lets
+the renderers call them unconditionally.
+
+*/
+
+package profiler
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// DefaultHistorySize is the number of finished frame reports kept.
+	DefaultHistorySize = 120
+
+	// maxPendingFrames is the number of frames that can be waiting on GPU
+	// results before the oldest is finished without them.
+	maxPendingFrames = 4
+)
+
+// ScopeReport is the timing of a single named scope within a frame.
+type ScopeReport struct {
+	Name  string
+	Depth int
+
+	// CPUStart is the time the scope began relative to the start of profiling.
+	CPUStart time.Duration
+	CPUTime  time.Duration
+
+	// GPUStart is the GPU time the scope began relative to the first GPU
+	// timestamp recorded; zero if GPU timing isn't available.
+	GPUStart time.Duration
+	GPUTime  time.Duration
+
+	startQuery uint32
+	endQuery   uint32
+}
+
+// FrameReport holds all of the scopes recorded for one frame.
+type FrameReport struct {
+	Frame    uint64
+	CPUStart time.Duration
+	CPUTime  time.Duration
+	Scopes   []ScopeReport
+
+	// HasGPU is true if the GPU times in the scopes are valid.
+	HasGPU bool
+}
+
+// Find returns the first scope with the given name or nil if not found.
+func (fr *FrameReport) Find(name string) *ScopeReport {
+	for i := range fr.Scopes {
+		if fr.Scopes[i].Name == name {
+			return &fr.Scopes[i]
+		}
+	}
+	return nil
+}
+
+// Profiler records named scopes for every frame.
+type Profiler struct {
+	// Enabled toggles the recording of scopes.
+	Enabled bool
+
+	// HistorySize is the number of finished frame reports kept.
+	HistorySize int
+
+	gfx      graphics.GraphicsProvider
+	epoch    time.Time
+	gpuEpoch uint64
+	frame    uint64
+
+	current *FrameReport
+	stack   []int
+	pending []*FrameReport
+	history []*FrameReport
+	queries []uint32
+}
+
+// NewProfiler creates a new enabled profiler. If gfx is non-nil, GPU times
+// are measured with timestamp queries.
+func NewProfiler(gfx graphics.GraphicsProvider) *Profiler {
+	p := new(Profiler)
+	p.Enabled = true
+	p.HistorySize = DefaultHistorySize
+	p.gfx = gfx
+	p.epoch = time.Now()
+	p.stack = make([]int, 0, 16)
+	return p
+}
+
+// Destroy releases the GPU query objects held by the profiler.
+func (p *Profiler) Destroy() {
+	if p == nil || p.gfx == nil {
+		return
+	}
+	for _, fr := range p.pending {
+		p.releaseQueries(fr)
+	}
+	p.pending = p.pending[:0]
+	for _, q := range p.queries {
+		p.gfx.DeleteQuery(q)
+	}
+	p.queries = p.queries[:0]
+}
+
+// BeginFrame starts recording a new frame, finishing any previous frame
+// that was not ended.
+func (p *Profiler) BeginFrame() {
+	if p == nil || !p.Enabled {
+		return
+	}
+	if p.current != nil {
+		p.EndFrame()
+	}
+
+	p.collectPending(false)
+
+	p.current = new(FrameReport)
+	p.current.Frame = p.frame
+	p.current.CPUStart = time.Since(p.epoch)
+	p.frame++
+}
+
+// EndFrame finishes recording the current frame. Any scopes still open are closed.
+func (p *Profiler) EndFrame() {
+	if p == nil || p.current == nil {
+		return
+	}
+	for len(p.stack) > 0 {
+		p.End()
+	}
+
+	fr := p.current
+	fr.CPUTime = time.Since(p.epoch) - fr.CPUStart
+	p.current = nil
+
+	if p.gfx != nil && len(fr.Scopes) > 0 {
+		p.pending = append(p.pending, fr)
+		if len(p.pending) > maxPendingFrames {
+			p.collectPending(true)
+		}
+		return
+	}
+	p.addHistory(fr)
+}
+
+// Begin opens a new named scope in the current frame. Scopes nest and each
+// Begin must be matched with an End.
+func (p *Profiler) Begin(name string) {
+	if p == nil || p.current == nil {
+		return
+	}
+
+	var scope ScopeReport
+	scope.Name = name
+	scope.Depth = len(p.stack)
+	scope.CPUStart = time.Since(p.epoch)
+	if p.gfx != nil {
+		scope.startQuery = p.getQuery()
+		p.gfx.QueryCounter(scope.startQuery, graphics.TIMESTAMP)
+	}
+
+	p.current.Scopes = append(p.current.Scopes, scope)
+	p.stack = append(p.stack, len(p.current.Scopes)-1)
+}
+
+// End closes the most recently opened scope.
+func (p *Profiler) End() {
+	if p == nil || p.current == nil || len(p.stack) == 0 {
+		return
+	}
+
+	last := len(p.stack) - 1
+	scope := &p.current.Scopes[p.stack[last]]
+	p.stack = p.stack[:last]
+
+	scope.CPUTime = time.Since(p.epoch) - scope.CPUStart
+	if p.gfx != nil {
+		scope.endQuery = p.getQuery()
+		p.gfx.QueryCounter(scope.endQuery, graphics.TIMESTAMP)
+	}
+}
+
+// LastReport returns the most recently finished frame report or nil if
+// there isn't one yet.
+func (p *Profiler) LastReport() *FrameReport {
+	if p == nil || len(p.history) == 0 {
+		return nil
+	}
+	return p.history[len(p.history)-1]
+}
+
+// Reports returns the finished frame reports, oldest first.
+func (p *Profiler) Reports() []*FrameReport {
+	if p == nil {
+		return nil
+	}
+	return p.history
+}
+
+// traceEvent is a complete event in the Chrome trace event format.
+type traceEvent struct {
+	Name  string  `json:"name"`
+	Phase string  `json:"ph"`
+	Ts    float64 `json:"ts"`
+	Dur   float64 `json:"dur"`
+	Pid   int     `json:"pid"`
+	Tid   int     `json:"tid"`
+}
+
+// WriteChromeTrace writes the finished frame reports as a JSON trace that
+// can be loaded in chrome://tracing. CPU scopes are on thread 1 and GPU
+// scopes are on thread 2.
+func (p *Profiler) WriteChromeTrace(w io.Writer) error {
+	events := make([]traceEvent, 0)
+	if p != nil {
+		const usec = float64(time.Microsecond)
+		for _, fr := range p.history {
+			for _, s := range fr.Scopes {
+				events = append(events, traceEvent{Name: s.Name, Phase: "X",
+					Ts: float64(s.CPUStart) / usec, Dur: float64(s.CPUTime) / usec, Pid: 1, Tid: 1})
+				if fr.HasGPU {
+					events = append(events, traceEvent{Name: s.Name, Phase: "X",
+						Ts: float64(s.GPUStart) / usec, Dur: float64(s.GPUTime) / usec, Pid: 1, Tid: 2})
+				}
+			}
+		}
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}{events})
+}
+
+// getQuery returns a free query object, creating one if needed.
+func (p *Profiler) getQuery() uint32 {
+	if len(p.queries) == 0 {
+		return p.gfx.GenQuery()
+	}
+	last := len(p.queries) - 1
+	q := p.queries[last]
+	p.queries = p.queries[:last]
+	return q
+}
+
+// releaseQueries returns the queries of a frame to the free list.
+func (p *Profiler) releaseQueries(fr *FrameReport) {
+	for i := range fr.Scopes {
+		s := &fr.Scopes[i]
+		if s.startQuery != 0 {
+			p.queries = append(p.queries, s.startQuery)
+		}
+		if s.endQuery != 0 {
+			p.queries = append(p.queries, s.endQuery)
+		}
+		s.startQuery, s.endQuery = 0, 0
+	}
+}
+
+// collectPending finishes the pending frames whose GPU queries are available.
+// If force is set, the oldest frame is finished even without GPU results.
+func (p *Profiler) collectPending(force bool) {
+	finished := 0
+	for _, fr := range p.pending {
+		if !p.resolveGPU(fr) {
+			if !force || finished > 0 {
+				break
+			}
+		}
+		p.releaseQueries(fr)
+		p.addHistory(fr)
+		finished++
+	}
+
+	copy(p.pending, p.pending[finished:])
+	for i := len(p.pending) - finished; i < len(p.pending); i++ {
+		p.pending[i] = nil
+	}
+	p.pending = p.pending[:len(p.pending)-finished]
+}
+
+// resolveGPU reads back the GPU times for the frame if all of the
+// queries are available.
+func (p *Profiler) resolveGPU(fr *FrameReport) bool {
+	for i := range fr.Scopes {
+		var available uint32
+		p.gfx.GetQueryObjectuiv(fr.Scopes[i].endQuery, graphics.QUERY_RESULT_AVAILABLE, &available)
+		if available == 0 {
+			return false
+		}
+	}
+
+	for i := range fr.Scopes {
+		s := &fr.Scopes[i]
+		var start, end uint64
+		p.gfx.GetQueryObjectui64v(s.startQuery, graphics.QUERY_RESULT, &start)
+		p.gfx.GetQueryObjectui64v(s.endQuery, graphics.QUERY_RESULT, &end)
+		if p.gpuEpoch == 0 {
+			p.gpuEpoch = start
+		}
+		s.GPUStart = time.Duration(start - p.gpuEpoch)
+		s.GPUTime = time.Duration(end - start)
+	}
+	fr.HasGPU = true
+	return true
+}
+
+// addHistory stores the finished frame, dropping the oldest past HistorySize.
+func (p *Profiler) addHistory(fr *FrameReport) {
+	p.history = append(p.history, fr)
+	if len(p.history) > p.HistorySize {
+		over := len(p.history) - p.HistorySize
+		copy(p.history, p.history[over:])
+		for i := len(p.history) - over; i < len(p.history); i++ {
+			p.history[i] = nil
+		}
+		p.history = p.history[:p.HistorySize]
+	}
+}
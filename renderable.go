@@ -7,6 +7,7 @@ import (
 	"math"
 
 	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle/events"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
 	"github.com/tbogdala/gombz"
 )
@@ -14,49 +15,140 @@ import (
 // RenderableCore contains data that is needed to draw an object on the screen.
 // Further, data here can be shared between multiple Renderable instances.
 type RenderableCore struct {
-	Shader   *RenderShader
+	// Material is the surface used when drawing the Renderables sharing the
+	// core, unless they have a Material of their own. Its fields, like
+	// Shader and Tex0, are accessed directly on the core.
+	Material
+
 	Skeleton *Skeleton
 
-	Tex0          graphics.Texture
-	Tex1          graphics.Texture
-	DiffuseColor  mgl.Vec4
-	SpecularColor mgl.Vec4
+	// BakedAnimations, if set, is bound to the BONE_TEXTURE sampler for
+	// shaders that skin instances from baked animation frames.
+	BakedAnimations *BakedAnimations
+
+	// Lightmap, if set, is the baked lighting texture bound to the LIGHTMAP
+	// sampler; it is sampled with the second UV channel (VERTEX_UV_1).
+	Lightmap graphics.Texture
 
-	// Shininess is the exponent used while calculating specular highlights
-	Shininess float32
+	// Topology is how the elements are assembled into primitives when the
+	// Renderable is drawn; FaceCount is the number of those primitives.
+	Topology Topology
+
+	// LineWidth is the width in pixels of the lines of a line topology
+	// drawn with a thick line shader.
+	LineWidth float32
 
 	Vao            uint32
 	VaoInitialized bool
 
 	VertVBO        graphics.Buffer
 	UvVBO          graphics.Buffer
+	Uv1VBO         graphics.Buffer
 	NormsVBO       graphics.Buffer
 	TangentsVBO    graphics.Buffer
 	ElementsVBO    graphics.Buffer
 	BoneFidsVBO    graphics.Buffer
 	BoneWeightsVBO graphics.Buffer
+	ColorVBO       graphics.Buffer
 	ComboVBO1      graphics.Buffer
 	ComboVBO2      graphics.Buffer
 
 	VBOStride            int32
 	VertVBOOffset        int
 	UvVBOOffset          int
+	Uv1VBOOffset         int
 	NormsVBOOffset       int
 	TangentsVBOOffset    int
 	BoneFidsVBOOffset    int
 	BoneWeightsVBOOffset int
+	ColorVBOOffset       int
 	ComboVBO1Offset      int
 	ComboVBO2Offset      int
 
+	// Geometry is an optional CPU side copy of the vertex data uploaded
+	// to the VBOs. It is nil unless RetainGeometry was set when created.
+	Geometry *Geometry
+
 	IsDestroyed bool
 }
 
+// Topology is the primitive topology of a Renderable's elements.
+type Topology int
+
+const (
+	// TopologyTriangles draws every three elements as a triangle; the default.
+	TopologyTriangles Topology = iota
+
+	// TopologyTriangleStrip draws a triangle for every element after the first two.
+	TopologyTriangleStrip
+
+	// TopologyTriangleFan draws a triangle for every element after the
+	// first two, all sharing the first element.
+	TopologyTriangleFan
+
+	// TopologyLines draws every two elements as a line segment.
+	TopologyLines
+
+	// TopologyLineStrip draws a line segment for every element after the first.
+	TopologyLineStrip
+
+	// TopologyPoints draws every element as a point.
+	TopologyPoints
+)
+
+// Mode returns the graphics draw mode, such as graphics.TRIANGLE_STRIP, for the topology.
+func (t Topology) Mode() uint32 {
+	switch t {
+	case TopologyTriangleStrip:
+		return graphics.TRIANGLE_STRIP
+	case TopologyTriangleFan:
+		return graphics.TRIANGLE_FAN
+	case TopologyLines:
+		return graphics.LINES
+	case TopologyLineStrip:
+		return graphics.LINE_STRIP
+	case TopologyPoints:
+		return graphics.POINTS
+	default:
+		return graphics.TRIANGLES
+	}
+}
+
 // Rectangle3D defines a rectangular 3d structure by two points
 type Rectangle3D struct {
 	Bottom mgl.Vec3
 	Top    mgl.Vec3
 }
 
+// IsEmpty returns true if the rectangle has no volume or area because both
+// corners are the same point, which is the case for unset rectangles.
+func (rect *Rectangle3D) IsEmpty() bool {
+	return rect.Bottom == rect.Top
+}
+
+// Transform returns the axis aligned rectangle that encloses this one
+// transformed by the matrix.
+func (rect *Rectangle3D) Transform(m mgl.Mat4) (result Rectangle3D) {
+	// start at the translation and add the extent of every axis of the
+	// matrix so that the corners don't have to be transformed one by one
+	for i := 0; i < 3; i++ {
+		result.Bottom[i] = m[12+i]
+		result.Top[i] = m[12+i]
+		for j := 0; j < 3; j++ {
+			a := m[j*4+i] * rect.Bottom[j]
+			b := m[j*4+i] * rect.Top[j]
+			if a < b {
+				result.Bottom[i] += a
+				result.Top[i] += b
+			} else {
+				result.Bottom[i] += b
+				result.Top[i] += a
+			}
+		}
+	}
+	return result
+}
+
 // DeltaX is the change of the X-axis component of Rectangle3D
 func (rect *Rectangle3D) DeltaX() float32 {
 	return rect.Top[0] - rect.Bottom[0]
@@ -87,9 +179,31 @@ type Renderable struct {
 	// BoundingRect is the unscaled, unrotated bounding rectangle for the renderable.
 	BoundingRect Rectangle3D
 
+	// CullBounds, if not empty, replaces BoundingRect for frustum culling.
+	// It's for renderables whose vertices move beyond their bind pose, like
+	// skinned meshes, or are displaced by their shaders.
+	CullBounds Rectangle3D
+
 	IsVisible bool
 	IsGroup   bool
 
+	// CastShadow is true if the renderable is drawn into shadow maps. Turning
+	// it off for a group leaves out all of its children.
+	CastShadow bool
+
+	// ReceiveShadow is true if shadows are cast onto the renderable when it's
+	// lit by shaders that support shadows.
+	ReceiveShadow bool
+
+	// ViewLayer, from 0 to 31, is matched against the layer mask of the
+	// viewport being drawn so that renderables can be shown in some
+	// viewports only, like a player's own model in split screen.
+	ViewLayer uint8
+
+	// Material, if set, is drawn instead of the core's Material. It can be
+	// shared between Renderables with different geometry.
+	Material *Material
+
 	Core     *RenderableCore
 	Parent   *Renderable
 	Children []*Renderable
@@ -104,6 +218,8 @@ func NewRenderable() *Renderable {
 	r.LocalRotation = mgl.QuatIdent()
 	r.IsVisible = true
 	r.IsGroup = false
+	r.CastShadow = true
+	r.ReceiveShadow = true
 	r.Children = make([]*Renderable, 0, 4)
 
 	r.Core = NewRenderableCore()
@@ -113,16 +229,16 @@ func NewRenderable() *Renderable {
 // NewRenderableCore creates a new RenderableCore object
 func NewRenderableCore() *RenderableCore {
 	rc := new(RenderableCore)
-	rc.DiffuseColor = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
-	rc.SpecularColor = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
-	rc.Shininess = 0.01
+	rc.Material = *NewMaterial()
 	rc.Vao = gfx.GenVertexArray()
 	return rc
 }
 
-// Destroy releases the RenderableCore data
+// Destroy releases the RenderableCore data and publishes a
+// RenderableDestroyed event on events.Engine.
 func (r *Renderable) Destroy() {
 	r.Core.DestroyCore()
+	events.Engine.Publish(RenderableDestroyed{Renderable: r})
 }
 
 // DestroyCore releases the OpenGL VBO and VAO objects but does not release
@@ -130,11 +246,13 @@ func (r *Renderable) Destroy() {
 func (r *RenderableCore) DestroyCore() {
 	gfx.DeleteBuffer(r.VertVBO)
 	gfx.DeleteBuffer(r.UvVBO)
+	gfx.DeleteBuffer(r.Uv1VBO)
 	gfx.DeleteBuffer(r.ElementsVBO)
 	gfx.DeleteBuffer(r.TangentsVBO)
 	gfx.DeleteBuffer(r.NormsVBO)
 	gfx.DeleteBuffer(r.BoneFidsVBO)
 	gfx.DeleteBuffer(r.BoneWeightsVBO)
+	gfx.DeleteBuffer(r.ColorVBO)
 	gfx.DeleteBuffer(r.ComboVBO1)
 	gfx.DeleteBuffer(r.ComboVBO2)
 	gfx.DeleteVertexArray(r.Vao)
@@ -152,10 +270,15 @@ func (r *Renderable) Clone() *Renderable {
 	clone.LocalRotation = r.LocalRotation
 	clone.IsVisible = r.IsVisible
 	clone.IsGroup = r.IsGroup
+	clone.CastShadow = r.CastShadow
+	clone.ReceiveShadow = r.ReceiveShadow
+	clone.ViewLayer = r.ViewLayer
 	clone.BoundingRect = r.BoundingRect
+	clone.CullBounds = r.CullBounds
 
-	// The render core is shared in the clone
+	// The render core and material are shared in the clone
 	clone.Core = r.Core
+	clone.Material = r.Material
 
 	// Deep clone the child renderables
 	for _, rc := range r.Children {
@@ -166,6 +289,20 @@ func (r *Renderable) Clone() *Renderable {
 	return clone
 }
 
+// GetMaterial returns the Material the Renderable is drawn with: its own
+// if set, otherwise the core's.
+func (r *Renderable) GetMaterial() *Material {
+	if r.Material != nil {
+		return r.Material
+	}
+	return &r.Core.Material
+}
+
+// GetShader returns the shader of the Renderable's material.
+func (r *Renderable) GetShader() *RenderShader {
+	return r.GetMaterial().Shader
+}
+
 // HasSkeleton returns true if the renderable has bones associated with it.
 func (r *Renderable) HasSkeleton() bool {
 	if r.Core.Skeleton != nil {
@@ -338,6 +475,20 @@ func CreateFromGombz(srcMesh *gombz.Mesh) *Renderable {
 		gfx.BufferData(graphics.ARRAY_BUFFER, int(floatSize*srcMesh.VertexCount*2), gfx.Ptr(&vertBuffer[0]), graphics.STATIC_DRAW)
 	}
 
+	// setup the second UV channel, used for lightmaps
+	if len(srcMesh.UVChannels[1]) > 0 {
+		uvChan := srcMesh.UVChannels[1]
+		for i := uint32(0); i < srcMesh.VertexCount; i++ {
+			uv := uvChan[i]
+			offset := i * 2
+			vertBuffer[offset] = uv[0]
+			vertBuffer[offset+1] = uv[1]
+		}
+		r.Core.Uv1VBO = gfx.GenBuffer()
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.Uv1VBO)
+		gfx.BufferData(graphics.ARRAY_BUFFER, int(floatSize*srcMesh.VertexCount*2), gfx.Ptr(&vertBuffer[0]), graphics.STATIC_DRAW)
+	}
+
 	// setup vertex weight Ids for bones
 	var weightBuffer []float32
 	if len(srcMesh.VertexWeightIds) > 0 {
@@ -385,8 +536,43 @@ func CreateFromGombz(srcMesh *gombz.Mesh) *Renderable {
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
 	gfx.BufferData(graphics.ELEMENT_ARRAY_BUFFER, uintSize*len(indexBuffer), gfx.Ptr(&indexBuffer[0]), graphics.STATIC_DRAW)
 
+	// keep a copy of the geometry around if requested
+	if RetainGeometry {
+		r.Core.Geometry = newGeometryFromGombz(srcMesh, indexBuffer)
+	}
+
 	gfx.BindBuffer(graphics.ARRAY_BUFFER, 0)
 	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, 0)
 
 	return r
 }
+
+// newGeometryFromGombz copies the vertex data of a gombz mesh into a new Geometry.
+func newGeometryFromGombz(srcMesh *gombz.Mesh, indexes []uint32) *Geometry {
+	g := new(Geometry)
+	g.Vertices = make([]float32, 0, len(srcMesh.Vertices)*3)
+	for _, v := range srcMesh.Vertices {
+		g.Vertices = append(g.Vertices, v[0], v[1], v[2])
+	}
+	g.Normals = make([]float32, 0, len(srcMesh.Normals)*3)
+	for _, n := range srcMesh.Normals {
+		g.Normals = append(g.Normals, n[0], n[1], n[2])
+	}
+	g.Tangents = make([]float32, 0, len(srcMesh.Tangents)*3)
+	for _, t := range srcMesh.Tangents {
+		g.Tangents = append(g.Tangents, t[0], t[1], t[2])
+	}
+	g.UVs = make([]float32, 0, len(srcMesh.UVChannels[0])*2)
+	for _, uv := range srcMesh.UVChannels[0] {
+		g.UVs = append(g.UVs, uv[0], uv[1])
+	}
+	if len(srcMesh.UVChannels[1]) > 0 {
+		g.LightmapUVs = make([]float32, 0, len(srcMesh.UVChannels[1])*2)
+		for _, uv := range srcMesh.UVChannels[1] {
+			g.LightmapUVs = append(g.LightmapUVs, uv[0], uv[1])
+		}
+	}
+	g.Indexes = make([]uint32, len(indexes))
+	copy(g.Indexes, indexes)
+	return g
+}
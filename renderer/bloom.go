@@ -0,0 +1,359 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// MaxBloomLevels is the most downsampled levels a Bloom blurs through.
+	MaxBloomLevels = 8
+)
+
+var (
+	// BloomDownsampleFragShader330 is the GLSL fragment shader that halves
+	// BLOOM_SOURCE_TEX with a box filter of four bilinear taps. The first
+	// level also applies the bright pass, keeping only the part of each
+	// color above BLOOM_THRESHOLD.
+	BloomDownsampleFragShader330 = `#version 330
+  uniform sampler2D BLOOM_SOURCE_TEX;
+  uniform vec2 BLOOM_TEXEL_SIZE;
+  uniform float BLOOM_THRESHOLD;
+  uniform int BLOOM_PREFILTER;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec4 o = BLOOM_TEXEL_SIZE.xyxy * vec4(-1.0, -1.0, 1.0, 1.0);
+    vec3 color = 0.25 * (texture(BLOOM_SOURCE_TEX, vs_uv + o.xy).rgb +
+      texture(BLOOM_SOURCE_TEX, vs_uv + o.zy).rgb +
+      texture(BLOOM_SOURCE_TEX, vs_uv + o.xw).rgb +
+      texture(BLOOM_SOURCE_TEX, vs_uv + o.zw).rgb);
+
+    if (BLOOM_PREFILTER != 0) {
+      float brightness = max(color.r, max(color.g, color.b));
+      color *= max(brightness - BLOOM_THRESHOLD, 0.0) / max(brightness, 0.0001);
+    }
+    frag_color = vec4(color, 1.0);
+  }`
+
+	// BloomUpsampleFragShader330 is the GLSL fragment shader that doubles
+	// BLOOM_SOURCE_TEX with a 3x3 tent filter whose taps are spread by
+	// BLOOM_RADIUS. It's blended additively onto the next larger level.
+	BloomUpsampleFragShader330 = `#version 330
+  uniform sampler2D BLOOM_SOURCE_TEX;
+  uniform vec2 BLOOM_TEXEL_SIZE;
+  uniform float BLOOM_RADIUS;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec4 d = BLOOM_TEXEL_SIZE.xyxy * vec4(1.0, 1.0, -1.0, 0.0) * BLOOM_RADIUS;
+    vec3 color = texture(BLOOM_SOURCE_TEX, vs_uv - d.xy).rgb;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv - d.wy).rgb * 2.0;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv - d.zy).rgb;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv + d.zw).rgb * 2.0;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv).rgb * 4.0;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv + d.xw).rgb * 2.0;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv + d.zy).rgb;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv + d.wy).rgb * 2.0;
+    color += texture(BLOOM_SOURCE_TEX, vs_uv + d.xy).rgb;
+    frag_color = vec4(color * (1.0 / 16.0), 1.0);
+  }`
+
+	// BloomCompositeFragShader330 is the GLSL fragment shader that adds the
+	// blurred bright parts in BLOOM_TEX, scaled by BLOOM_INTENSITY, to
+	// POST_COLOR_TEX.
+	BloomCompositeFragShader330 = `#version 330
+  uniform sampler2D POST_COLOR_TEX;
+  uniform sampler2D BLOOM_TEX;
+  uniform float BLOOM_INTENSITY;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec4 color = texture(POST_COLOR_TEX, vs_uv);
+    frag_color = vec4(color.rgb + texture(BLOOM_TEX, vs_uv).rgb * BLOOM_INTENSITY, color.a);
+  }`
+)
+
+// bloomLevel is one of the downsampled textures of the blur chain.
+type bloomLevel struct {
+	fbo    graphics.Buffer
+	tex    graphics.Texture
+	width  int32
+	height int32
+}
+
+// Bloom makes the parts of the scene brighter than a threshold glow. The
+// bright parts are downsampled through a chain of half sized textures and
+// then upsampled back with a tent filter, blurring them wider at every
+// level, and added back onto the scene. It's a PostEffect and can also be
+// set on an HDR with SetBloom, where it works on the scene before it's
+// tonemapped so that emissive surfaces and specular highlights above 1.0
+// glow.
+type Bloom struct {
+	// Threshold is the brightness that colors have to exceed to bloom.
+	Threshold float32
+
+	// Intensity scales the bloom added to the scene.
+	Intensity float32
+
+	// Radius spreads the taps of the upsample filter, in texels of each
+	// level, for a wider or tighter glow.
+	Radius float32
+
+	// Levels is the number of downsampled levels, up to MaxBloomLevels.
+	// More levels blur further. It takes effect on the next Resize.
+	Levels int
+
+	gfx    graphics.GraphicsProvider
+	width  int32
+	height int32
+	levels []bloomLevel
+
+	downsampleShader *fizzle.RenderShader
+	upsampleShader   *fizzle.RenderShader
+	compositeShader  *fizzle.RenderShader
+	quad             *fizzle.Renderable
+
+	// source, sourceTexel and prefilter are the parameters of the pass
+	// being drawn for the uniform binders
+	source      graphics.Texture
+	sourceTexel mgl.Vec2
+	prefilter   bool
+
+	// bindPassFn and bindCompositeFn are the method values of the binders,
+	// saved so that they aren't allocated for every pass
+	bindPassFn      RenderBinder
+	bindCompositeFn RenderBinder
+}
+
+// NewBloom returns a bloom effect with default parameters. It gets its
+// graphics resources when it's added to a PostProcessStack or an HDR.
+func NewBloom() *Bloom {
+	b := new(Bloom)
+	b.Threshold = 1.0
+	b.Intensity = 0.5
+	b.Radius = 1.0
+	b.Levels = 5
+	return b
+}
+
+// Init compiles the bloom shaders and creates the levels for a framebuffer
+// of the given size.
+func (b *Bloom) Init(gfx graphics.GraphicsProvider, width, height int32) error {
+	b.gfx = gfx
+	b.bindPassFn = b.bindPass
+	b.bindCompositeFn = b.bindComposite
+
+	var err error
+	if b.downsampleShader == nil {
+		b.downsampleShader, err = fizzle.LoadShaderProgram(PostProcessVertShader330, BloomDownsampleFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the bloom downsample shader: %v", err)
+		}
+	}
+	if b.upsampleShader == nil {
+		b.upsampleShader, err = fizzle.LoadShaderProgram(PostProcessVertShader330, BloomUpsampleFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the bloom upsample shader: %v", err)
+		}
+	}
+	if b.compositeShader == nil {
+		b.compositeShader, err = fizzle.LoadShaderProgram(PostProcessVertShader330, BloomCompositeFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the bloom composite shader: %v", err)
+		}
+	}
+	if b.quad == nil {
+		b.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	}
+	return b.Resize(width, height)
+}
+
+// Destroy releases the shaders, quad and levels.
+func (b *Bloom) Destroy() {
+	b.destroyLevels()
+	for _, shader := range []**fizzle.RenderShader{&b.downsampleShader, &b.upsampleShader, &b.compositeShader} {
+		if *shader != nil {
+			(*shader).Destroy()
+			*shader = nil
+		}
+	}
+	if b.quad != nil {
+		b.quad.Destroy()
+		b.quad = nil
+	}
+}
+
+// destroyLevels releases the framebuffers and textures of the levels.
+func (b *Bloom) destroyLevels() {
+	for _, level := range b.levels {
+		b.gfx.DeleteFramebuffer(level.fbo)
+		b.gfx.DeleteTexture(level.tex)
+	}
+	b.levels = b.levels[:0]
+}
+
+// Resize recreates the levels for a new framebuffer size. The first level
+// is half the size and every level after it half of the one before, until
+// Levels are created or they would be smaller than a pixel.
+func (b *Bloom) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid bloom size %dx%d", width, height)
+	}
+	b.destroyLevels()
+	b.width = width
+	b.height = height
+
+	levels := b.Levels
+	if levels > MaxBloomLevels {
+		levels = MaxBloomLevels
+	}
+	if levels < 1 {
+		levels = 1
+	}
+
+	gfx := b.gfx
+	w, h := width/2, height/2
+	var err error
+	for i := 0; i < levels && w >= 1 && h >= 1; i++ {
+		var level bloomLevel
+		level.width = w
+		level.height = h
+		level.tex = gfx.GenTexture()
+		gfx.BindTexture(graphics.TEXTURE_2D, level.tex)
+		gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA16F, w, h, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+
+		level.fbo = gfx.GenFramebuffer()
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, level.fbo)
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, level.tex, 0)
+		b.levels = append(b.levels, level)
+
+		err = CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "bloom")
+		if err != nil {
+			break
+		}
+		w, h = w/2, h/2
+	}
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	return err
+}
+
+// Apply adds the bloom of the frame's Color to it.
+func (b *Bloom) Apply(rend Renderer, frame *PostProcessFrame) {
+	b.blur(rend, frame.Color)
+	b.gfx.BindFramebuffer(graphics.FRAMEBUFFER, frame.Target)
+	b.gfx.Viewport(0, 0, frame.Width, frame.Height)
+	frame.DrawQuad(rend, b.compositeShader, b.bindCompositeFn)
+}
+
+// GetTexture returns the texture holding the blurred bright parts of the
+// last image bloomed, which is half the size of the framebuffer.
+func (b *Bloom) GetTexture() graphics.Texture {
+	if len(b.levels) == 0 {
+		return 0
+	}
+	return b.levels[0].tex
+}
+
+// blur runs the bright pass and the downsample and upsample chain on the
+// source texture, leaving the bloom in the first level. The framebuffer
+// and viewport of the first level are left bound.
+func (b *Bloom) blur(rend Renderer, source graphics.Texture) {
+	gfx := b.gfx
+	if len(b.levels) == 0 {
+		return
+	}
+
+	gfx.Disable(graphics.BLEND)
+	b.source = source
+	b.sourceTexel = mgl.Vec2{1.0 / float32(b.width), 1.0 / float32(b.height)}
+	b.prefilter = true
+	for i := range b.levels {
+		level := &b.levels[i]
+		b.drawPass(rend, level, b.downsampleShader)
+		b.source = level.tex
+		b.sourceTexel = mgl.Vec2{1.0 / float32(level.width), 1.0 / float32(level.height)}
+		b.prefilter = false
+	}
+
+	// each level is upsampled onto the one above it, accumulating the blur
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendEquation(graphics.FUNC_ADD)
+	gfx.BlendFunc(graphics.ONE, graphics.ONE)
+	for i := len(b.levels) - 1; i > 0; i-- {
+		b.source = b.levels[i].tex
+		b.sourceTexel = mgl.Vec2{1.0 / float32(b.levels[i].width), 1.0 / float32(b.levels[i].height)}
+		b.drawPass(rend, &b.levels[i-1], b.upsampleShader)
+	}
+	gfx.Disable(graphics.BLEND)
+}
+
+// drawPass draws the source texture into the level with the shader.
+func (b *Bloom) drawPass(rend Renderer, level *bloomLevel, shader *fizzle.RenderShader) {
+	ident := mgl.Ident4()
+	b.gfx.BindFramebuffer(graphics.FRAMEBUFFER, level.fbo)
+	b.gfx.Viewport(0, 0, level.width, level.height)
+	rend.DrawRenderableWithShader(b.quad, shader, b.bindPassFn, ident, ident, nil)
+}
+
+// bindPass binds the source texture and parameters for the downsample and
+// upsample shaders.
+func (b *Bloom) bindPass(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := b.gfx
+	if loc := shader.GetUniformLocation("BLOOM_SOURCE_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, b.source)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("BLOOM_TEXEL_SIZE"); loc >= 0 {
+		gfx.Uniform2f(loc, b.sourceTexel[0], b.sourceTexel[1])
+	}
+	if loc := shader.GetUniformLocation("BLOOM_THRESHOLD"); loc >= 0 {
+		gfx.Uniform1f(loc, b.Threshold)
+	}
+	if loc := shader.GetUniformLocation("BLOOM_PREFILTER"); loc >= 0 {
+		if b.prefilter {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+	if loc := shader.GetUniformLocation("BLOOM_RADIUS"); loc >= 0 {
+		gfx.Uniform1f(loc, b.Radius)
+	}
+}
+
+// bindComposite binds the bloom texture and intensity for the composite
+// shaders.
+func (b *Bloom) bindComposite(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := b.gfx
+	if loc := shader.GetUniformLocation("BLOOM_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, b.GetTexture())
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("BLOOM_INTENSITY"); loc >= 0 {
+		gfx.Uniform1f(loc, b.Intensity)
+	}
+}
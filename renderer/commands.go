@@ -0,0 +1,226 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"runtime"
+	"sync"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/jobs"
+)
+
+// DrawCommand is a single recorded draw of a Renderable node with all of
+// its transform matrices already calculated.
+type DrawCommand struct {
+	Renderable *fizzle.Renderable
+	Shader     *fizzle.RenderShader
+	Binder     RenderBinder
+	Mode       uint32
+	Matrices   DrawMatrices
+
+	// Material, if set, is drawn instead of the Renderable's material.
+	Material *fizzle.Material
+
+	// Pose, if set, is bound to BONES instead of the pose of the
+	// Renderable's skeleton.
+	Pose []mgl.Mat4
+}
+
+// CommandList is a list of draw commands produced by a CommandRecorder and
+// submitted on the thread owning the GL context.
+type CommandList struct {
+	Commands []DrawCommand
+}
+
+// NewCommandList creates a new, empty command list.
+func NewCommandList() *CommandList {
+	cl := new(CommandList)
+	cl.Commands = make([]DrawCommand, 0, 256)
+	return cl
+}
+
+// Reset clears the list while keeping the allocated storage for reuse.
+func (cl *CommandList) Reset() {
+	for i := range cl.Commands {
+		cl.Commands[i].Renderable = nil
+		cl.Commands[i].Shader = nil
+		cl.Commands[i].Binder = nil
+		cl.Commands[i].Material = nil
+		cl.Commands[i].Pose = nil
+	}
+	cl.Commands = cl.Commands[:0]
+}
+
+// CullFunc is called by the CommandRecorder for every Renderable node that is
+// about to be recorded with the node's world transform. Returning false skips
+// the node (and its children, for groups).
+type CullFunc func(r *fizzle.Renderable, model mgl.Mat4) bool
+
+// FrustumCullFunc returns a CullFunc that skips the nodes whose bounds are
+// outside of the frustum, so that frustum culling runs on the workers. The
+// frustum shouldn't be updated while recording.
+func FrustumCullFunc(frustum *Frustum) CullFunc {
+	return func(r *fizzle.Renderable, model mgl.Mat4) bool {
+		bounds := r.CullBounds
+		if bounds.IsEmpty() {
+			bounds = r.BoundingRect
+		}
+		if bounds.IsEmpty() {
+			return true
+		}
+		return frustum.IntersectsRect(bounds.Transform(model))
+	}
+}
+
+// CommandRecorder traverses Renderable trees on worker goroutines, culling them
+// and preparing the uniform matrices, to produce a CommandList. No GL calls
+// are made during recording so it is safe to run off of the GL context thread.
+// NOTE: the Renderables must not be modified while recording is in progress.
+type CommandRecorder struct {
+	// Workers is the number of goroutines used to record; if <= 0 then
+	// runtime.NumCPU() is used, or the worker count of Jobs if set.
+	Workers int
+
+	// Jobs is an optional job scheduler to record on instead of starting
+	// new goroutines for every call to Record.
+	Jobs *jobs.Scheduler
+
+	// Cull is an optional culling function; if nil every visible node is recorded.
+	Cull CullFunc
+
+	// workerLists holds the per-worker command storage reused between frames.
+	workerLists []CommandList
+}
+
+// NewCommandRecorder creates a new recorder using one worker per CPU.
+func NewCommandRecorder() *CommandRecorder {
+	return new(CommandRecorder)
+}
+
+// Record traverses the renderables and appends a DrawCommand to the list for
+// every visible node that passes culling. The renderables are divided up
+// between the workers and the results are appended in the same order as
+// the renderables were passed in. If shader is nil, each node's own
+// material shader is used.
+func (cr *CommandRecorder) Record(list *CommandList, renderables []*fizzle.Renderable, shader *fizzle.RenderShader,
+	binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4, mode uint32) {
+	workers := cr.Workers
+	if workers <= 0 {
+		if cr.Jobs != nil {
+			workers = cr.Jobs.WorkerCount()
+		} else {
+			workers = runtime.NumCPU()
+		}
+	}
+	if workers > len(renderables) {
+		workers = len(renderables)
+	}
+	if workers == 0 {
+		return
+	}
+
+	if len(cr.workerLists) < workers {
+		cr.workerLists = append(cr.workerLists, make([]CommandList, workers-len(cr.workerLists))...)
+	}
+
+	// split the renderables into contiguous ranges so the merged output
+	// keeps the original order
+	var wg sync.WaitGroup
+	var group *jobs.Group
+	if cr.Jobs != nil {
+		group = cr.Jobs.NewGroup()
+	}
+	perWorker := (len(renderables) + workers - 1) / workers
+	for w := 0; w < workers; w++ {
+		start := w * perWorker
+		end := start + perWorker
+		if end > len(renderables) {
+			end = len(renderables)
+		}
+		wl := &cr.workerLists[w]
+		wl.Reset()
+		if start >= end {
+			continue
+		}
+
+		nodes := renderables[start:end]
+		record := func() {
+			for _, r := range nodes {
+				cr.recordNode(wl, r, shader, binder, &perspective, &view, mode)
+			}
+		}
+		if group != nil {
+			group.Go(record)
+		} else {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				record()
+			}()
+		}
+	}
+	if group != nil {
+		group.Wait()
+	} else {
+		wg.Wait()
+	}
+
+	for w := 0; w < workers; w++ {
+		list.Commands = append(list.Commands, cr.workerLists[w].Commands...)
+	}
+}
+
+// recordNode records the Renderable, or the children of a group, into the list.
+func (cr *CommandRecorder) recordNode(list *CommandList, r *fizzle.Renderable, shader *fizzle.RenderShader,
+	binder RenderBinder, perspective *mgl.Mat4, view *mgl.Mat4, mode uint32) {
+	// only draw visible nodes
+	if !r.IsVisible {
+		return
+	}
+
+	model := r.GetTransformMat4()
+	if cr.Cull != nil && !cr.Cull(r, model) {
+		return
+	}
+
+	// if the renderable is a group, just try to record the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			cr.recordNode(list, child, shader, binder, perspective, view, mode)
+		}
+		return
+	}
+
+	s := shader
+	if s == nil {
+		s = r.GetShader()
+	}
+
+	list.Commands = append(list.Commands, DrawCommand{})
+	cmd := &list.Commands[len(list.Commands)-1]
+	cmd.Renderable = r
+	cmd.Shader = s
+	cmd.Binder = binder
+	cmd.Mode = mode
+	cmd.Matrices.Prepare(perspective, view, model)
+}
+
+// SubmitCommand binds and draws a single recorded command. The binders are
+// called the same way as in BindAndDraw. This must be called on the thread
+// owning the GL context.
+func SubmitCommand(renderer Renderer, cmd *DrawCommand, binders []RenderBinder, camera fizzle.Camera) {
+	mat := cmd.Material
+	if mat == nil {
+		mat = cmd.Renderable.GetMaterial()
+	}
+	gfx := bindRenderable(renderer, cmd.Renderable, mat, cmd.Pose, cmd.Shader, binders, &cmd.Matrices, nil, camera)
+
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, cmd.Renderable.Core.ElementsVBO)
+	gfx.DrawElements(graphics.Enum(cmd.Mode), getElementCount(cmd.Renderable, cmd.Mode), graphics.UNSIGNED_INT, gfx.PtrOffset(0))
+	restoreMaterialState(gfx, mat)
+	gfx.BindVertexArray(0)
+}
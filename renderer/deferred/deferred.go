@@ -5,358 +5,508 @@ package deferred
 
 import (
 	"fmt"
+	"image"
 	"time"
 
-	glfw "github.com/go-gl/glfw/v3.1/glfw"
 	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/events"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/profiler"
+	renderer "github.com/tbogdala/fizzle/renderer"
+	"github.com/tbogdala/fizzle/window"
 	"github.com/tbogdala/groggy"
 )
 
-// ScreenSizeChanged is the type of the function called by the renderer after
-// a screen size change is detected.
-type ScreenSizeChanged func(dr *DeferredRenderer, width int32, height int32)
+// DeferredRenderer is a deferred-rendering style renderer. Which means that
+// it draws the geometry into several framebuffer textures, the G-buffer,
+// and then lights every pixel of them once per light in a separate light
+// accumulation pass. The cost of a light doesn't depend on the number of
+// Renderables, so scenes can have many more lights than with the forward
+// renderer.
+//
+// A frame is drawn by calling BeginGeometryPass, drawing the Renderables
+// with DrawRenderable, calling EndGeometryPass and then DrawLights, which
+// writes the lit scene to the Output framebuffer.
+type DeferredRenderer struct {
+	// Lights are the lights shaded by DrawLights. Any number of lights
+	// can be active; they are shaded LightsPerPass at a time.
+	Lights []*Light
 
-// DeferredBeforeDraw is the type of the function called by the renderer before
-// endtering the geometry draw function.
-type DeferredBeforeDraw func(dr *DeferredRenderer, deltaFrameTime float32)
+	// Frame is the G-buffer framebuffer.
+	Frame graphics.Buffer
 
-// DeferredAfterDraw is the type of the function called by the renderer after
-// endtering the geometry draw function.
-type DeferredAfterDraw func(dr *DeferredRenderer, deltaFrameTime float32)
+	// Albedo holds the diffuse color of the materials drawn.
+	Albedo graphics.Texture
 
-// DeferredGeometryPass is the type of the function called to render geometry to the
-// framebuffers in the deferred renderer.
-type DeferredGeometryPass func(dr *DeferredRenderer, deltaFrameTime float32)
+	// Normals holds the world space normals of the materials drawn.
+	Normals graphics.Texture
 
-// DeferredCompositePass is the type of the function called to render the framebuffers
-// to the screen in the deferred renderer.
-type DeferredCompositePass func(dr *DeferredRenderer, deltaFrameTime float32)
+	// Material holds the specular color of the materials drawn with the
+	// shininess in the alpha channel.
+	Material graphics.Texture
 
-// DeferredRenderer is a deferred-rendering style renderer. Which means that
-// it creates several framebuffers for shaders to write to and has two main
-// rendering steps: 1) geometry and 2) compositing.
-type DeferredRenderer struct {
-	Frame          graphics.Buffer
-	Depth          graphics.Buffer
-	Diffuse        graphics.Texture
-	Positions      graphics.Texture
-	Normals        graphics.Texture
-	CompositePlane *Renderable
-
-	// GeometryPass is the function called to render geometry to the
-	// framebuffers in the deferred renderer.
-	GeometryPass DeferredGeometryPass
-
-	// CompositePass is the function called to render the framebuffers
-	// to the screen in the deferred renderer.
-	CompositePass DeferredCompositePass
-
-	// BeforeDraw is the function called by the renderer before
-	// endtering the geometry draw function.
-	BeforeDraw DeferredBeforeDraw
-
-	// AfterDraw is the function called by the renderer after
-	// endtering the geometry draw function.
-	AfterDraw DeferredAfterDraw
-
-	// OnScreenSizeChanged is the function called by the renderer after
-	// a screen size change is detected.
-	OnScreenSizeChanged ScreenSizeChanged
-
-	// MainWindow the window used to show the rendered composite plane to.
-	MainWindow *glfw.Window
-
-	// UIManager is the user interface manager assigned to the renderer.
-	UIManager *UIManager
-
-	shaders       map[string]*RenderShader
-	width         int32
-	height        int32
+	// Velocity holds the screen space motion of every pixel since the last
+	// frame, in texture coordinates, for temporal effects like TAA.
+	Velocity graphics.Texture
+
+	// Depth is the depth texture of the G-buffer, used to reconstruct the
+	// world position of every pixel.
+	Depth graphics.Texture
+
+	// Output is the framebuffer bound by EndGeometryPass for DrawLights to
+	// write the lit scene into; 0, the default, is the window.
+	Output graphics.Buffer
+
+	// FrameLimiter, if set, caps the frame rate at the end of every frame.
+	FrameLimiter *renderer.FrameLimiter
+
+	// ManualSwap, if set, leaves swapping the buffers of the window set with
+	// SetWindow to the application, which keeps the resize tracking and
+	// swap interval of the window without EndRenderFrame presenting it.
+	ManualSwap bool
+
+	// Textures, if set, is told about the textures of every material drawn
+	// in the geometry pass so that it evicts the ones that aren't being
+	// drawn and reloads the evicted ones that are at the start of the next
+	// frame.
+	Textures *fizzle.TextureManager
+
+	// Profiler, if set, records the time spent in the geometry and light
+	// passes; frames are started in BeginFrame and finished in
+	// EndRenderFrame.
+	Profiler *profiler.Profiler
+
+	// window is the surface the renderer presents frames to; optional
+	window window.Window
+
+	// gfx is the graphics provider the renderer draws with
+	gfx graphics.GraphicsProvider
+
+	width  int32
+	height int32
+
+	// lastFrameTime logs the last time the renderer started a frame
 	lastFrameTime time.Time
-}
 
-// NewDeferredRenderer creates a new DeferredRenderer and sets some of the
-// default callback functions as well as other default values.
-func NewDeferredRenderer(window *glfw.Window) *DeferredRenderer {
-	dr := new(DeferredRenderer)
-	dr.shaders = make(map[string]*RenderShader)
-	dr.MainWindow = window
-	dr.OnScreenSizeChanged = func(r *DeferredRenderer, width int32, height int32) {}
-	dr.BeforeDraw = func(r *DeferredRenderer, deltaFrameTime float32) {}
-	dr.AfterDraw = func(r *DeferredRenderer, deltaFrameTime float32) {}
-	dr.GeometryPass = func(dr *DeferredRenderer, deltaFrameTime float32) {}
-	dr.CompositePass = func(dr *DeferredRenderer, deltaFrameTime float32) {}
+	// geometryShader writes the materials of Renderables into the G-buffer
+	geometryShader *fizzle.RenderShader
 
-	return dr
-}
+	// geometryInstancedShader works like geometryShader for instanced draws
+	geometryInstancedShader *fizzle.RenderShader
 
-// Destroy releases all of the OpenGL buffers the DeferredRenderer is holding on to.
-func (dr *DeferredRenderer) Destroy() {
-	gfx.DeleteRenderbuffer(dr.Depth)
-	gfx.DeleteTexture(dr.Diffuse)
-	gfx.DeleteTexture(dr.Positions)
-	gfx.DeleteTexture(dr.Normals)
-	gfx.DeleteFramebuffer(dr.Frame)
-	dr.CompositePlane.Core.DestroyCore()
+	// lightShader is the light accumulation pass shader
+	lightShader *fizzle.RenderShader
+
+	// quad is the full screen quad drawn by the light accumulation pass
+	quad *fizzle.Renderable
+
+	// instanceVBO holds the instance transforms for DrawRenderableInstanced
+	instanceVBO graphics.Buffer
+
+	// lightBatch is the slice of Lights being shaded by the current quad
+	lightBatch []*Light
+
+	// inverseVP and eye are the camera's inverse view projection matrix
+	// and world position for the light accumulation pass
+	inverseVP mgl.Mat4
+	eye       mgl.Vec3
+
+	// ssao computes the ambient occlusion from the G-buffer depth for the
+	// light accumulation pass; created by EnableSSAO.
+	ssao *renderer.SSAO
+
+	// taa jitters the projection of every draw and resolves the lit scene
+	// in taaStack; created by EnableTAA.
+	taa      *renderer.TAA
+	taaStack *renderer.PostProcessStack
+
+	// motion tracks the camera and Renderable transforms of the last frame
+	// for the velocity written into the G-buffer
+	motion motionTracker
+
+	// drawScratch holds the matrices calculated while binding a Renderable
+	drawScratch renderer.DrawMatrices
+
+	// binders is scratch storage for the binder list passed to BindAndDraw
+	binders [2]renderer.RenderBinder
+
+	// geometryBinderFn and lightBinderFn are the method values of the
+	// renderer's binders, saved so they aren't allocated on every draw
+	geometryBinderFn renderer.RenderBinder
+	lightBinderFn    renderer.RenderBinder
 }
 
-// ChangeResolution internally changes the size of the framebuffers and compositing
-// plane that are used for rendering.
-func (dr *DeferredRenderer) ChangeResolution(width, height int32) {
-	dr.Destroy()
-	dr.Init(width, height)
-	if dr.OnScreenSizeChanged != nil {
-		dr.OnScreenSizeChanged(dr, width, height)
-	}
+// DeferredRenderer is checked to implement the common Renderer interface
+var _ renderer.Renderer = (*DeferredRenderer)(nil)
+var _ renderer.DrawScratcher = (*DeferredRenderer)(nil)
+
+func init() {
+	renderer.RegisterPipeline("deferred", func(gfx graphics.GraphicsProvider) renderer.Renderer {
+		return NewDeferredRenderer(gfx)
+	})
 }
 
-// GetResolution returns the current dimensions of the renderer.
-func (dr *DeferredRenderer) GetResolution() (int32, int32) {
-	return dr.width, dr.height
+// NewDeferredRenderer creates a new deferred renderer. Init must be called
+// to create the G-buffer before drawing.
+func NewDeferredRenderer(g graphics.GraphicsProvider) *DeferredRenderer {
+	dr := new(DeferredRenderer)
+	dr.gfx = g
+	dr.geometryBinderFn = dr.bindGeometry
+	dr.lightBinderFn = dr.bindLightPass
+	return dr
 }
 
-// GetAspectRatio returns the ratio of screen width to height.
-func (dr *DeferredRenderer) GetAspectRatio() float32 {
-	return float32(dr.width) / float32(dr.height)
+// Destroy releases all of the OpenGL objects the DeferredRenderer is holding on to.
+func (dr *DeferredRenderer) Destroy() {
+	dr.destroyGBuffer()
+	dr.DisableSSAO()
+	dr.DisableTAA()
+	if dr.geometryShader != nil {
+		dr.geometryShader.Destroy()
+		dr.geometryShader = nil
+	}
+	if dr.geometryInstancedShader != nil {
+		dr.geometryInstancedShader.Destroy()
+		dr.geometryInstancedShader = nil
+	}
+	if dr.lightShader != nil {
+		dr.lightShader.Destroy()
+		dr.lightShader = nil
+	}
+	if dr.quad != nil {
+		dr.quad.Destroy()
+		dr.quad = nil
+	}
+	if dr.instanceVBO != 0 {
+		dr.gfx.DeleteBuffer(dr.instanceVBO)
+		dr.instanceVBO = 0
+	}
 }
 
-// EndRenderFrame swaps the buffers and calls GLFW to poll for input.
-func (dr *DeferredRenderer) EndRenderFrame() {
-	dr.MainWindow.SwapBuffers()
-	glfw.PollEvents()
+// destroyGBuffer deletes the G-buffer framebuffer and textures.
+func (dr *DeferredRenderer) destroyGBuffer() {
+	gfx := dr.gfx
+	if dr.Frame != 0 {
+		gfx.DeleteFramebuffer(dr.Frame)
+		dr.Frame = 0
+	}
+	for _, tex := range []*graphics.Texture{&dr.Albedo, &dr.Normals, &dr.Material, &dr.Velocity, &dr.Depth} {
+		if *tex != 0 {
+			gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
 }
 
-// Init sets up the DeferredRenderer by creating all of the framebuffers and
-// creating the compositing plane.
+// Init sets up the DeferredRenderer by creating the G-buffer at the
+// resolution and, the first time it's called, compiling the shaders. An
+// error is returned if the renderer has no graphics provider, the
+// resolution is invalid or the G-buffer framebuffer is incomplete.
 func (dr *DeferredRenderer) Init(width, height int32) error {
+	if dr.gfx == nil {
+		return fmt.Errorf("the deferred renderer has no graphics provider")
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid deferred renderer resolution %dx%d", width, height)
+	}
+	if err := dr.initShaders(); err != nil {
+		return err
+	}
+
+	dr.destroyGBuffer()
 	dr.width = width
 	dr.height = height
-	dr.Frame = gfx.GenFramebuffer()
-
-	// setup the depth buffer
-	dr.Depth = gfx.GenRenderbuffer()
-	gfx.BindRenderbuffer(graphics.RENDERBUFFER, dr.Depth)
-	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH_COMPONENT24, width, height)
 
-	// setup the diffuse texture
-	dr.Diffuse = gfx.GenTexture()
-	gfx.ActiveTexture(graphics.TEXTURE0)
-	gfx.BindTexture(graphics.TEXTURE_2D, dr.Diffuse)
-	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, width, height, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
-
-	// setup the positions texture
-	dr.Positions = gfx.GenTexture()
-	gfx.ActiveTexture(graphics.TEXTURE1)
-	gfx.BindTexture(graphics.TEXTURE_2D, dr.Positions)
-	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, width, height, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
-
-	// setup the normals texture
-	dr.Normals = gfx.GenTexture()
-	gfx.ActiveTexture(graphics.TEXTURE2)
-	gfx.BindTexture(graphics.TEXTURE_2D, dr.Normals)
-	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA16F, width, height, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx := dr.gfx
+	dr.Albedo = dr.createTarget(graphics.RGBA8, graphics.RGBA, graphics.UNSIGNED_BYTE)
+	dr.Normals = dr.createTarget(graphics.RGBA16F, graphics.RGBA, graphics.FLOAT)
+	dr.Material = dr.createTarget(graphics.RGBA16F, graphics.RGBA, graphics.FLOAT)
+	dr.Velocity = dr.createTarget(graphics.RG16F, graphics.RG, graphics.FLOAT)
+	dr.Depth = dr.createTarget(graphics.DEPTH_COMPONENT24, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT)
 
 	// now bind all of these things to the framebuffer
+	dr.Frame = gfx.GenFramebuffer()
 	gfx.BindFramebuffer(graphics.FRAMEBUFFER, dr.Frame)
-	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.RENDERBUFFER, dr.Depth)
-	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, dr.Diffuse, 0)
-	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT1, graphics.TEXTURE_2D, dr.Positions, 0)
-	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT2, graphics.TEXTURE_2D, dr.Normals, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, dr.Albedo, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT1, graphics.TEXTURE_2D, dr.Normals, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT2, graphics.TEXTURE_2D, dr.Material, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT3, graphics.TEXTURE_2D, dr.Velocity, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, dr.Depth, 0)
+	gfx.DrawBuffers([]uint32{graphics.COLOR_ATTACHMENT0, graphics.COLOR_ATTACHMENT1, graphics.COLOR_ATTACHMENT2, graphics.COLOR_ATTACHMENT3})
 
 	// how did it all go? lets find out ...
-	status := gfx.CheckFramebufferStatus(graphics.FRAMEBUFFER)
-	switch {
-	case status == graphics.FRAMEBUFFER_UNSUPPORTED:
-		return fmt.Errorf("Failed to create the deferred rendering pipeline because the framebuffer was unsupported.\n")
-	case status != graphics.FRAMEBUFFER_COMPLETE:
-		return fmt.Errorf("Failed to create the deferred rendering pipeline. Code 0x%x\n", status)
-	}
-
+	err := renderer.CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "G-buffer")
 	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	if err != nil {
+		dr.destroyGBuffer()
+		return err
+	}
+	if dr.ssao != nil {
+		if err := dr.ssao.Resize(width, height); err != nil {
+			return err
+		}
+	}
+	if dr.taa != nil {
+		dr.taa.Velocity = dr.Velocity
+	}
+	return fizzle.CheckGraphicsError(gfx, "creating the G-buffer")
+}
 
-	// create a plane for the composite pass
-	groggy.Logsf("DEBUG", "Creatiing composite plane %dx%d.", width, height)
-	cp := CreatePlaneXY("composite", 0, 0, float32(width), float32(height))
-	cp.Core.Tex0 = gfx.GenTexture()
-	gfx.BindTexture(graphics.TEXTURE_2D, cp.Core.Tex0)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.REPEAT)
-	gfx.TexParameterf(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.REPEAT)
+// createTarget creates a G-buffer texture at the renderer's resolution.
+func (dr *DeferredRenderer) createTarget(internalFormat int32, format graphics.Enum, ty graphics.Enum) graphics.Texture {
+	gfx := dr.gfx
+	tex := gfx.GenTexture()
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, internalFormat, dr.width, dr.height, 0, format, ty, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
 	gfx.BindTexture(graphics.TEXTURE_2D, 0)
-	dr.CompositePlane = cp
+	return tex
+}
 
+// initShaders compiles the geometry and light pass shaders and creates the
+// full screen quad unless that was already done.
+func (dr *DeferredRenderer) initShaders() error {
+	var err error
+	if dr.geometryShader == nil {
+		dr.geometryShader, err = fizzle.LoadShaderProgram(GeometryVertShader330, GeometryFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the deferred geometry shader: %v", err)
+		}
+	}
+	if dr.geometryInstancedShader == nil {
+		dr.geometryInstancedShader, err = fizzle.LoadShaderProgram(GeometryInstancedVertShader330, GeometryFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the deferred instanced geometry shader: %v", err)
+		}
+	}
+	if dr.lightShader == nil {
+		dr.lightShader, err = fizzle.LoadShaderProgram(LightPassVertShader330, LightPassFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the deferred light pass shader: %v", err)
+		}
+	}
+	if dr.quad == nil {
+		dr.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	}
 	return nil
 }
 
-// InitShaders sets up the special shaders used in a deferred rendering pipeline.
-func (dr *DeferredRenderer) InitShaders(compositeBaseFilepath string, dirlightShaderFilepath string) error {
-	// Load the composite pass shader and assert variables exist
-	prog, err := LoadShaderProgramFromFiles(compositeBaseFilepath, func(p graphics.Program) {
-		gfx.BindFragDataLocation(p, 0, "frag_color")
-	})
-	if err != nil {
-		return fmt.Errorf("Failed to compile and link the deferred render composite program! %v", err)
+// ChangeResolution should be called when the underlying rendering window
+// changes size. The G-buffer is recreated at the new size and then a
+// ResolutionChanged event is published on events.Engine.
+func (dr *DeferredRenderer) ChangeResolution(width, height int32) {
+	// minimized windows report a zero size; keep the last resolution
+	if width == 0 || height == 0 {
+		return
 	}
-	dr.shaders["composite"] = prog
-
-	// Load the directional light shader and assert variables exist
-	prog, err = LoadShaderProgramFromFiles(dirlightShaderFilepath, func(p graphics.Program) {
-		gfx.BindFragDataLocation(p, 0, "frag_color")
-	})
-	if err != nil {
-		return fmt.Errorf("Failed to compile and link the deferred render composite program! %v", err)
+	if err := dr.Init(width, height); err != nil {
+		groggy.Logsf("ERROR", "DeferredRenderer failed to change the resolution: %v", err)
+		return
 	}
-	dr.shaders["directional_light"] = prog
+	events.Engine.Publish(events.ResolutionChanged{Renderer: dr, Width: width, Height: height})
+}
 
-	return nil
+// GetResolution returns the current dimensions of the renderer.
+func (dr *DeferredRenderer) GetResolution() (int32, int32) {
+	return dr.width, dr.height
 }
 
-// CompositeDraw draws the final composite image onto the composite plane using
-// the composite shader.
-func (dr *DeferredRenderer) CompositeDraw() {
-	// the view matrix would be identity
-	ortho := mgl.Ortho(0, float32(dr.width), 0, float32(dr.height), -200.0, 200.0)
+// GetAspectRatio returns the ratio of screen width to height.
+func (dr *DeferredRenderer) GetAspectRatio() float32 {
+	return float32(dr.width) / float32(dr.height)
+}
+
+// SetGraphics initializes then renderer with the graphics provider.
+func (dr *DeferredRenderer) SetGraphics(gp graphics.GraphicsProvider) {
+	dr.gfx = gp
+}
 
-	r := dr.CompositePlane
-	shader := dr.shaders["composite"]
-	gfx.UseProgram(shader.Prog)
-	gfx.BindVertexArray(r.Core.Vao)
+// GetDrawScratch returns the storage for the matrices calculated while
+// binding Renderables.
+func (dr *DeferredRenderer) GetDrawScratch() *renderer.DrawMatrices {
+	return &dr.drawScratch
+}
 
-	model := r.GetTransformMat4()
+// GetGraphics returns the renderer's the graphics provider.
+func (dr *DeferredRenderer) GetGraphics() graphics.GraphicsProvider {
+	return dr.gfx
+}
 
-	shaderMvp := shader.GetUniformLocation("MVP_MATRIX")
-	if shaderMvp >= 0 {
-		mvp := ortho.Mul4(model)
-		gfx.UniformMatrix4fv(shaderMvp, 1, false, mvp)
+// SetWindow sets the surface the renderer presents frames to. The renderer
+// is initialized to the window's framebuffer size and follows its resize
+// events. EndRenderFrame swaps the window's buffers once one is set.
+func (dr *DeferredRenderer) SetWindow(w window.Window) {
+	dr.window = w
+	if w == nil {
+		return
 	}
 
-	shaderPosition := shader.GetAttribLocation("VERTEX_POSITION")
-	if shaderPosition >= 0 {
-		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.VertVBO)
-		gfx.EnableVertexAttribArray(uint32(shaderPosition))
-		gfx.VertexAttribPointer(uint32(shaderPosition), 3, graphics.FLOAT, false, 0, gfx.PtrOffset(0))
+	w.SetResizeCallback(func(width, height int) {
+		dr.ChangeResolution(int32(width), int32(height))
+	})
+	width, height := w.GetFramebufferSize()
+	if err := dr.Init(int32(width), int32(height)); err != nil {
+		groggy.Logsf("ERROR", "DeferredRenderer failed to initialize for the window: %v", err)
 	}
+}
 
-	shaderVertUv := shader.GetAttribLocation("VERTEX_UV_0")
-	if shaderVertUv >= 0 {
-		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.UvVBO)
-		gfx.EnableVertexAttribArray(uint32(shaderVertUv))
-		gfx.VertexAttribPointer(uint32(shaderVertUv), 2, graphics.FLOAT, false, 0, gfx.PtrOffset(0))
-	}
+// GetWindow returns the surface the renderer presents frames to, if one was set.
+func (dr *DeferredRenderer) GetWindow() window.Window {
+	return dr.window
+}
 
-	shaderTex0 := shader.GetUniformLocation("DIFFUSE_TEX")
-	if shaderTex0 >= 0 {
-		gfx.ActiveTexture(graphics.TEXTURE0)
-		gfx.BindTexture(graphics.TEXTURE_2D, dr.Diffuse)
-		gfx.Uniform1i(shaderTex0, 0)
+// BeginFrame marks the start of a new frame and returns the time in seconds
+// since the start of the previous frame, or 0 for the first frame.
+func (dr *DeferredRenderer) BeginFrame() float64 {
+	if dr.window != nil {
+		dr.window.MakeContextCurrent()
 	}
 
-	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
-	gfx.DrawElements(graphics.TRIANGLES, int32(r.FaceCount*3), graphics.UNSIGNED_INT, gfx.PtrOffset(0))
-	gfx.BindVertexArray(0)
+	now := time.Now()
+	var delta float64
+	if !dr.lastFrameTime.IsZero() {
+		delta = now.Sub(dr.lastFrameTime).Seconds()
+	}
+	dr.lastFrameTime = now
+	dr.Textures.Update()
+	dr.Profiler.BeginFrame()
+	return delta
 }
 
-// DrawDirectionalLight draws the composite plane while lighting everything with
-// a directional light using the parameters specified.
-func (dr *DeferredRenderer) DrawDirectionalLight(eye mgl.Vec3, dir mgl.Vec3, color mgl.Vec3, ambient float32, diffuse float32, specular float32) {
-	// the view matrix would be identity
-	ortho := mgl.Ortho(0, float32(dr.width), 0, float32(dr.height), -200.0, 200.0)
-
-	r := dr.CompositePlane
-	shader := dr.shaders["directional_light"]
-	gfx.UseProgram(shader.Prog)
-	gfx.BindVertexArray(r.Core.Vao)
+// EndRenderFrame is the function called at end of the frame. If a window
+// was set with SetWindow, its buffers are swapped unless ManualSwap is set.
+func (dr *DeferredRenderer) EndRenderFrame() {
+	dr.Profiler.EndFrame()
+	if dr.window != nil && !dr.ManualSwap {
+		dr.window.SwapBuffers()
+	}
+	if dr.FrameLimiter != nil {
+		dr.FrameLimiter.Wait()
+	}
+}
 
-	model := r.GetTransformMat4()
+// CaptureScreenshot reads the back buffer into an image. It should be called
+// after DrawLights and before EndRenderFrame swaps the buffers.
+func (dr *DeferredRenderer) CaptureScreenshot() (*image.RGBA, error) {
+	return renderer.CaptureFramebuffer(dr.gfx, 0, 0, 0, dr.width, dr.height)
+}
 
-	shaderMvp := shader.GetUniformLocation("MVP_MATRIX")
-	if shaderMvp >= 0 {
-		mvp := ortho.Mul4(model)
-		gfx.UniformMatrix4fv(shaderMvp, 1, false, mvp)
+// BeginGeometryPass binds and clears the G-buffer so that the Renderables
+// drawn until EndGeometryPass write their materials into it. It should be
+// called once per frame since it also starts tracking the motion of the
+// frame's Renderables.
+func (dr *DeferredRenderer) BeginGeometryPass() {
+	gfx := dr.gfx
+	dr.Profiler.Begin("geometry pass")
+	dr.motion.beginFrame()
+	if dr.taa != nil {
+		dr.taa.Advance()
 	}
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, dr.Frame)
+	gfx.Viewport(0, 0, dr.width, dr.height)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.Disable(graphics.BLEND)
+	gfx.ClearColor(0.0, 0.0, 0.0, 0.0)
+	gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
+}
 
-	shaderPosition := shader.GetAttribLocation("VERTEX_POSITION")
-	if shaderPosition >= 0 {
-		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.VertVBO)
-		gfx.EnableVertexAttribArray(uint32(shaderPosition))
-		gfx.VertexAttribPointer(uint32(shaderPosition), 3, graphics.FLOAT, false, 0, gfx.PtrOffset(0))
-	}
+// EndGeometryPass finishes drawing into the G-buffer and binds the Output
+// framebuffer.
+func (dr *DeferredRenderer) EndGeometryPass() {
+	dr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, dr.Output)
+	dr.Profiler.End()
+}
 
-	shaderVertUv := shader.GetAttribLocation("VERTEX_UV_0")
-	if shaderVertUv >= 0 {
-		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.UvVBO)
-		gfx.EnableVertexAttribArray(uint32(shaderVertUv))
-		gfx.VertexAttribPointer(uint32(shaderVertUv), 2, graphics.FLOAT, false, 0, gfx.PtrOffset(0))
+// DrawLights runs the light accumulation pass, shading the G-buffer with
+// all of the Lights and blending the result additively into the bound
+// framebuffer, which should have been cleared. Pixels that no Renderable
+// was drawn to are left alone. The perspective and view matrixes should be
+// the ones the geometry was drawn with.
+func (dr *DeferredRenderer) DrawLights(perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	gfx := dr.gfx
+	perspective = dr.jitter(perspective)
+	dr.inverseVP = perspective.Mul4(view).Inv()
+	dr.eye = view.Inv().Col(3).Vec3()
+
+	if dr.ssao != nil {
+		dr.Profiler.Begin("ssao")
+		dr.ssao.Compute(dr, dr.Depth, perspective)
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, dr.Output)
+		gfx.Viewport(0, 0, dr.width, dr.height)
+		dr.Profiler.End()
 	}
 
-	shaderEyePosition := shader.GetAttribLocation("EYE_WORLD_POSITION")
-	if shaderEyePosition >= 0 {
-		gfx.Uniform3f(shaderEyePosition, eye[0], eye[1], eye[2])
-	}
+	dr.Profiler.Begin("light pass")
 
-	shaderTex0 := shader.GetUniformLocation("DIFFUSE_TEX")
-	if shaderTex0 >= 0 {
-		gfx.ActiveTexture(graphics.TEXTURE0)
-		gfx.BindTexture(graphics.TEXTURE_2D, dr.Diffuse)
-		gfx.Uniform1i(shaderTex0, 0)
-	}
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendEquation(graphics.FUNC_ADD)
+	gfx.BlendFunc(graphics.ONE, graphics.ONE)
 
-	shaderTex1 := shader.GetUniformLocation("POSITIONS_TEX")
-	if shaderTex1 >= 0 {
-		gfx.ActiveTexture(graphics.TEXTURE1)
-		gfx.BindTexture(graphics.TEXTURE_2D, dr.Positions)
-		gfx.Uniform1i(shaderTex1, 1)
+	identity := mgl.Ident4()
+	for start := 0; start < len(dr.Lights); start += LightsPerPass {
+		end := start + LightsPerPass
+		if end > len(dr.Lights) {
+			end = len(dr.Lights)
+		}
+		dr.lightBatch = dr.Lights[start:end]
+		dr.binders[0] = dr.lightBinderFn
+		renderer.BindAndDrawRef(dr, dr.quad, dr.lightShader, dr.binders[:1], &identity, &identity, camera, graphics.TRIANGLES)
 	}
+	dr.lightBatch = nil
 
-	shaderTex2 := shader.GetUniformLocation("NORMALS_TEX")
-	if shaderTex2 >= 0 {
-		gfx.ActiveTexture(graphics.TEXTURE2)
-		gfx.BindTexture(graphics.TEXTURE_2D, dr.Normals)
-		gfx.Uniform1i(shaderTex2, 2)
-	}
+	gfx.Disable(graphics.BLEND)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+	dr.Profiler.End()
+}
 
-	shaderLightDir := shader.GetUniformLocation("LIGHT_DIRECTION")
-	if shaderLightDir >= 0 {
-		gfx.Uniform3f(shaderLightDir, dir[0], dir[1], dir[2])
-	}
-	shaderLightColor := shader.GetUniformLocation("LIGHT_COLOR")
-	if shaderLightColor >= 0 {
-		gfx.Uniform3f(shaderLightColor, color[0], color[1], color[2])
-	}
-	shaderLightAmbient := shader.GetUniformLocation("LIGHT_AMBIENT_INTENSITY")
-	if shaderLightAmbient >= 0 {
-		gfx.Uniform1f(shaderLightAmbient, ambient)
-	}
-	shaderLightDiffuse := shader.GetUniformLocation("LIGHT_DIFFUSE_INTENSITY")
-	if shaderLightDiffuse >= 0 {
-		gfx.Uniform1f(shaderLightDiffuse, diffuse)
+// bindGeometry tells the geometry pass shader whether the Renderable has a
+// diffuse texture to sample and binds the matrixes for its velocity. The
+// material's textures are reported to Textures as used.
+func (dr *DeferredRenderer) bindGeometry(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	dr.Textures.TouchMaterial(r.GetMaterial())
+	if loc := shader.GetUniformLocation("GBUFFER_TEXTURED"); loc >= 0 {
+		if r.GetMaterial().Tex0 != 0 {
+			dr.gfx.Uniform1i(loc, 1)
+		} else {
+			dr.gfx.Uniform1i(loc, 0)
+		}
 	}
-	shaderLightSpecPow := shader.GetUniformLocation("LIGHT_SPECULAR_POWER")
-	if shaderLightSpecPow >= 0 {
-		gfx.Uniform1f(shaderLightSpecPow, specular)
+	dr.motion.bind(dr.gfx, r, shader)
+}
+
+// getBinders returns the binder list for BindAndDraw using the renderer's scratch
+// storage: the geometry pass binder followed by the optional user binder.
+func (dr *DeferredRenderer) getBinders(binder renderer.RenderBinder) []renderer.RenderBinder {
+	dr.binders[0] = dr.geometryBinderFn
+	if binder == nil {
+		return dr.binders[:1]
 	}
+	dr.binders[1] = binder
+	return dr.binders[:2]
+}
 
-	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
-	gfx.DrawElements(graphics.TRIANGLES, int32(r.FaceCount*3), graphics.UNSIGNED_INT, gfx.PtrOffset(0))
-	gfx.BindVertexArray(0)
+// DrawRenderable draws a Renderable object with the supplied projection and view matrixes
+// into the G-buffer. The Renderable's own shader is replaced by the geometry pass shader,
+// which writes its material: the diffuse color and texture, the specular color and
+// the shininess.
+func (dr *DeferredRenderer) DrawRenderable(r *fizzle.Renderable, binder renderer.RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	dr.DrawRenderableWithShader(r, dr.geometryShader, binder, perspective, view, camera)
 }
 
-// DrawRenderable draws a Renderable object with the supplied projection and view matrixes.
-func (dr *DeferredRenderer) DrawRenderable(r *Renderable, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4) {
+// DrawRenderableWithShader draws a Renderable object with the supplied projection and view matrixes
+// and a different shader than the geometry pass shader. The shader should write the same
+// outputs as GeometryFragShader330.
+func (dr *DeferredRenderer) DrawRenderableWithShader(r *fizzle.Renderable, shader *fizzle.RenderShader,
+	binder renderer.RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
 	// only draw visible nodes
 	if !r.IsVisible {
 		return
@@ -365,17 +515,18 @@ func (dr *DeferredRenderer) DrawRenderable(r *Renderable, binder RenderBinder, p
 	// if the renderable is a group, just try to draw the children
 	if r.IsGroup {
 		for _, child := range r.Children {
-			dr.DrawRenderable(child, binder, perspective, view)
+			dr.DrawRenderableWithShader(child, shader, binder, perspective, view, camera)
 		}
 		return
 	}
 
-	bindAndDraw(dr, r, r.Core.Shader, binder, perspective, view, graphics.TRIANGLES)
+	perspective = dr.trackCamera(perspective, view)
+	renderer.BindAndDrawRef(dr, r, shader, dr.getBinders(binder), &perspective, &view, camera, r.Core.Topology.Mode())
 }
 
-// DrawRenderableWithShader draws a Renderable object with the supplied projection and view matrixes
-// and a different shader than what is set in the Renderable.
-func (dr *DeferredRenderer) DrawRenderableWithShader(r *Renderable, shader *RenderShader, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4) {
+// DrawLines draws the Renderable using graphics.LINES mode instead of graphics.TRIANGLES.
+func (dr *DeferredRenderer) DrawLines(r *fizzle.Renderable, shader *fizzle.RenderShader, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
 	// only draw visible nodes
 	if !r.IsVisible {
 		return
@@ -384,92 +535,63 @@ func (dr *DeferredRenderer) DrawRenderableWithShader(r *Renderable, shader *Rend
 	// if the renderable is a group, just try to draw the children
 	if r.IsGroup {
 		for _, child := range r.Children {
-			dr.DrawRenderableWithShader(child, shader, binder, perspective, view)
+			dr.DrawLines(child, shader, binder, perspective, view, camera)
 		}
 		return
 	}
 
-	bindAndDraw(dr, r, shader, binder, perspective, view, graphics.TRIANGLES)
+	perspective = dr.trackCamera(perspective, view)
+	renderer.BindAndDrawRef(dr, r, shader, dr.getBinders(binder), &perspective, &view, camera, graphics.LINES)
 }
 
-// DrawLines draws the Renderable using graphics.LINES mode instead of graphics.TRIANGLES.
-func (dr *DeferredRenderer) DrawLines(r *Renderable, shader *RenderShader, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4) {
+// DrawRenderableInstanced draws one instance of the Renderable for each of the
+// transforms supplied into the G-buffer using a single draw call per Renderable node.
+// NOTE: requires instancing support in the graphics provider (not OpenGL ES 2).
+func (dr *DeferredRenderer) DrawRenderableInstanced(r *fizzle.Renderable, transforms []mgl.Mat4, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
 	// only draw visible nodes
-	if !r.IsVisible {
+	if !r.IsVisible || len(transforms) == 0 {
 		return
 	}
 
 	// if the renderable is a group, just try to draw the children
 	if r.IsGroup {
 		for _, child := range r.Children {
-			dr.DrawLines(child, shader, binder, perspective, view)
+			dr.DrawRenderableInstanced(child, transforms, binder, perspective, view, camera)
 		}
 		return
 	}
 
-	bindAndDraw(dr, r, shader, binder, perspective, view, graphics.LINES)
+	if dr.instanceVBO == 0 {
+		dr.instanceVBO = dr.gfx.GenBuffer()
+	}
+	perspective = dr.trackCamera(perspective, view)
+	renderer.BindAndDrawInstanced(dr, r, dr.geometryInstancedShader, dr.getBinders(binder), &perspective, &view, camera,
+		r.Core.Topology.Mode(), dr.instanceVBO, transforms)
 }
 
-// RenderLoop keeps running a render loop function until MainWindow is
-// set to should close
-func (dr *DeferredRenderer) RenderLoop() {
-	dr.lastFrameTime = time.Now()
-	for !dr.MainWindow.ShouldClose() {
-		currentFrameTime := time.Now()
-		deltaFrameTime := float32(currentFrameTime.Sub(dr.lastFrameTime).Seconds())
-
-		// setup the camera matrixes
-		tempW, tempH := dr.MainWindow.GetFramebufferSize()
-		currentWidth, currentHeight := int32(tempW), int32(tempH)
-		if dr.width != currentWidth || dr.height != currentHeight {
-			fmt.Printf("Updating resoluation to %d,%d\n", currentWidth, currentHeight)
-			dr.ChangeResolution(currentWidth, currentHeight)
-			dr.width, dr.height = currentWidth, currentHeight
-		}
-
-		////////////////////////////////////////////////////////////////////////////
-		// BEFORE DRAW
-		dr.BeforeDraw(dr, deltaFrameTime)
-
-		////////////////////////////////////////////////////////////////////////////
-		// GEOMETRY PASS
-		// setup the view matrixes
-		gfx.DepthMask(true)
-		//gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT) // necessary?
-		gfx.Enable(graphics.DEPTH_TEST)
-		gfx.Disable(graphics.BLEND)
-
-		gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, dr.Frame)
-		gfx.Viewport(0, 0, dr.width, dr.height)
-		buffsToClear := []uint32{graphics.COLOR_ATTACHMENT0, graphics.COLOR_ATTACHMENT1, graphics.COLOR_ATTACHMENT2}
-		gfx.DrawBuffers(buffsToClear)
-		gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
-
-		// do the geometry pass on the renderables
-		dr.GeometryPass(dr, deltaFrameTime)
-
-		gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, 0)
-		gfx.DepthMask(false)
-		gfx.Disable(graphics.DEPTH_TEST)
-
-		////////////////////////////////////////////////////////////////////////////
-		// COMPOSITE PASS START
-		gfx.Clear(graphics.COLOR_BUFFER_BIT)
-		gfx.Enable(graphics.BLEND)
-		gfx.BlendEquation(graphics.FUNC_ADD)
-		gfx.BlendFunc(graphics.ONE, graphics.ONE)
-
-		dr.CompositePass(dr, deltaFrameTime)
-
-		gfx.BindVertexArray(0)
-
-		dr.MainWindow.SwapBuffers()
-		glfw.PollEvents()
+// DrawRenderableColoredInstanced works like DrawRenderableInstanced but also
+// tints every instance's albedo with its own color.
+// NOTE: requires instancing support in the graphics provider (not OpenGL ES 2).
+func (dr *DeferredRenderer) DrawRenderableColoredInstanced(r *fizzle.Renderable, instances []renderer.ColoredInstance, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	// only draw visible nodes
+	if !r.IsVisible || len(instances) == 0 {
+		return
+	}
 
-		////////////////////////////////////////////////////////////////////////////
-		// AFTER DRAW
-		dr.AfterDraw(dr, deltaFrameTime)
+	// if the renderable is a group, just try to draw the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			dr.DrawRenderableColoredInstanced(child, instances, binder, perspective, view, camera)
+		}
+		return
+	}
 
-		dr.lastFrameTime = currentFrameTime
+	if dr.instanceVBO == 0 {
+		dr.instanceVBO = dr.gfx.GenBuffer()
 	}
+	perspective = dr.trackCamera(perspective, view)
+	renderer.BindAndDrawColoredInstanced(dr, r, dr.geometryInstancedShader, dr.getBinders(binder), &perspective, &view, camera,
+		r.Core.Topology.Mode(), dr.instanceVBO, instances)
 }
@@ -0,0 +1,228 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package deferred
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+const (
+	// LightsPerPass is the number of lights the light accumulation pass
+	// shades with each full screen quad it draws. Scenes with more lights
+	// are lit with additional quads blended on top.
+	LightsPerPass = 16
+
+	// maxSpotAngle is the widest half angle, in degrees, of a spot light cone
+	maxSpotAngle = 89.0
+)
+
+var (
+	// lightUniformNames caches the per-light uniform names so that binding the
+	// lights doesn't format new strings for every pass.
+	lightUniformNames [LightsPerPass]lightUniforms
+)
+
+// lightUniforms are the names of the shader uniforms for one light slot.
+type lightUniforms struct {
+	position          string
+	direction         string
+	diffuse           string
+	diffuseIntensity  string
+	specularIntensity string
+	ambientIntensity  string
+	falloff           string
+	spotCutoff        string
+}
+
+func init() {
+	for i := range lightUniformNames {
+		lightUniformNames[i] = lightUniforms{
+			position:          fmt.Sprintf("LIGHT_POSITION[%d]", i),
+			direction:         fmt.Sprintf("LIGHT_DIRECTION[%d]", i),
+			diffuse:           fmt.Sprintf("LIGHT_DIFFUSE[%d]", i),
+			diffuseIntensity:  fmt.Sprintf("LIGHT_DIFFUSE_INTENSITY[%d]", i),
+			specularIntensity: fmt.Sprintf("LIGHT_SPECULAR_INTENSITY[%d]", i),
+			ambientIntensity:  fmt.Sprintf("LIGHT_AMBIENT_INTENSITY[%d]", i),
+			falloff:           fmt.Sprintf("LIGHT_FALLOFF[%d]", i),
+			spotCutoff:        fmt.Sprintf("LIGHT_SPOT_CUTOFF[%d]", i),
+		}
+	}
+}
+
+// Light is a light shaded by the light accumulation pass of the deferred
+// renderer. Lights without a Direction are point lights, lights with one
+// are directional lights unless SpotOuterAngle makes them spot lights.
+type Light struct {
+	// Position is the location of the light in world space
+	Position mgl.Vec3
+
+	// Direction is the direction the light points in
+	Direction mgl.Vec3
+
+	// DiffuseColor is the color the light emmits
+	DiffuseColor mgl.Vec4
+
+	// DiffuseIntensity is how strong the diffuse light should be
+	DiffuseIntensity float32
+
+	// SpecularIntensity is how strong the specular highlight should be
+	SpecularIntensity float32
+
+	// AmbientIntensity is how strong the ambient light should be
+	AmbientIntensity float32
+
+	// Attenuation is the quadratic coefficient for the attenuation factor
+	// of point and spot lights: 1 / (constant + linear*d + quadratic*d*d).
+	Attenuation float32
+
+	// ConstantAttenuation is the constant term of the attenuation factor.
+	// Defaults to 1.0 with NewLight.
+	ConstantAttenuation float32
+
+	// LinearAttenuation is the linear coefficient of the attenuation factor.
+	LinearAttenuation float32
+
+	// Range is the distance at which a point or spot light's contribution
+	// smoothly reaches zero. Zero means the light has no range limit.
+	Range float32
+
+	// SpotInnerAngle is the half angle, in degrees, of the cone around
+	// Direction that a spot light fully lights.
+	SpotInnerAngle float32
+
+	// SpotOuterAngle is the half angle, in degrees, of the cone around
+	// Direction past which a spot light has no effect. The light is a spot
+	// light when this is greater than zero.
+	SpotOuterAngle float32
+}
+
+// NewLight creates a new light object and returns it
+func NewLight() *Light {
+	l := new(Light)
+	l.ConstantAttenuation = 1.0
+	return l
+}
+
+// SetSpot makes the light a spot light with the inner and outer half angles
+// of its cone in degrees.
+func (l *Light) SetSpot(innerAngle, outerAngle float32) {
+	if outerAngle > maxSpotAngle {
+		outerAngle = maxSpotAngle
+	}
+	if innerAngle > outerAngle {
+		innerAngle = outerAngle
+	}
+	if innerAngle < 0.0 {
+		innerAngle = 0.0
+	}
+	l.SpotInnerAngle = innerAngle
+	l.SpotOuterAngle = outerAngle
+}
+
+// IsSpot returns true if the light is a spot light.
+func (l *Light) IsSpot() bool {
+	return l.SpotOuterAngle > 0.0
+}
+
+// falloff returns the constant, linear and quadratic attenuation terms and
+// the range for the LIGHT_FALLOFF uniform. A light without any terms gets a
+// constant term of 1 so that it isn't infinitely bright.
+func (l *Light) falloff() (float32, float32, float32, float32) {
+	constant := l.ConstantAttenuation
+	if constant == 0.0 && l.LinearAttenuation == 0.0 && l.Attenuation == 0.0 {
+		constant = 1.0
+	}
+	return constant, l.LinearAttenuation, l.Attenuation, l.Range
+}
+
+// spotCutoff returns the cosines of the inner and outer cone angles of a spot
+// light for the LIGHT_SPOT_CUTOFF uniform or zeros for other lights.
+func (l *Light) spotCutoff() (float32, float32) {
+	if !l.IsSpot() {
+		return 0.0, 0.0
+	}
+	outer := l.SpotOuterAngle
+	if outer > maxSpotAngle {
+		outer = maxSpotAngle
+	}
+	inner := l.SpotInnerAngle
+	if inner > outer {
+		inner = outer
+	}
+	innerCos := float32(math.Cos(float64(mgl.DegToRad(inner))))
+	outerCos := float32(math.Cos(float64(mgl.DegToRad(outer))))
+	return innerCos, outerCos
+}
+
+// bindLightPass binds the G-buffer and the batch of lights being shaded
+// for the light accumulation pass shader.
+func (dr *DeferredRenderer) bindLightPass(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := dr.gfx
+	gbuffer := [...]struct {
+		name string
+		tex  graphics.Texture
+	}{
+		{"GBUFFER_ALBEDO", dr.Albedo},
+		{"GBUFFER_NORMAL", dr.Normals},
+		{"GBUFFER_MATERIAL", dr.Material},
+		{"GBUFFER_DEPTH", dr.Depth},
+	}
+	for _, target := range gbuffer {
+		if loc := shader.GetUniformLocation(target.name); loc >= 0 {
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			gfx.BindTexture(graphics.TEXTURE_2D, target.tex)
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+
+	if loc := shader.GetUniformLocation("INV_VP_MATRIX"); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &dr.inverseVP)
+	}
+	if loc := shader.GetUniformLocation("CAMERA_WORLD_POSITION"); loc >= 0 {
+		gfx.Uniform3f(loc, dr.eye[0], dr.eye[1], dr.eye[2])
+	}
+
+	for i, light := range dr.lightBatch {
+		names := &lightUniformNames[i]
+		if loc := shader.GetUniformLocation(names.position); loc >= 0 {
+			gfx.Uniform3f(loc, light.Position[0], light.Position[1], light.Position[2])
+		}
+		if loc := shader.GetUniformLocation(names.direction); loc >= 0 {
+			gfx.Uniform3f(loc, light.Direction[0], light.Direction[1], light.Direction[2])
+		}
+		if loc := shader.GetUniformLocation(names.diffuse); loc >= 0 {
+			gfx.Uniform4f(loc, light.DiffuseColor[0], light.DiffuseColor[1], light.DiffuseColor[2], light.DiffuseColor[3])
+		}
+		if loc := shader.GetUniformLocation(names.diffuseIntensity); loc >= 0 {
+			gfx.Uniform1f(loc, light.DiffuseIntensity)
+		}
+		if loc := shader.GetUniformLocation(names.specularIntensity); loc >= 0 {
+			gfx.Uniform1f(loc, light.SpecularIntensity)
+		}
+		if loc := shader.GetUniformLocation(names.ambientIntensity); loc >= 0 {
+			gfx.Uniform1f(loc, light.AmbientIntensity)
+		}
+		if loc := shader.GetUniformLocation(names.falloff); loc >= 0 {
+			constant, linear, quadratic, lightRange := light.falloff()
+			gfx.Uniform4f(loc, constant, linear, quadratic, lightRange)
+		}
+		if loc := shader.GetUniformLocation(names.spotCutoff); loc >= 0 {
+			innerCos, outerCos := light.spotCutoff()
+			gfx.Uniform2f(loc, innerCos, outerCos)
+		}
+	}
+
+	if loc := shader.GetUniformLocation("LIGHT_COUNT"); loc >= 0 {
+		gfx.Uniform1i(loc, int32(len(dr.lightBatch)))
+	}
+
+	dr.ssao.Bind(gfx, shader, texturesBound)
+}
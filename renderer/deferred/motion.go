@@ -0,0 +1,89 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package deferred
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// motionModel is the transform of a Renderable in the current and the
+// previous frame it was drawn in.
+type motionModel struct {
+	prev  mgl.Mat4
+	curr  mgl.Mat4
+	frame uint64
+}
+
+// motionTracker remembers the view projection and the Renderable transforms
+// of the last frame so that the geometry pass can write the velocity of
+// every pixel. The first camera drawn with in a frame is the one tracked.
+type motionTracker struct {
+	frame uint64
+
+	viewProjection     mgl.Mat4
+	prevViewProjection mgl.Mat4
+	cameraFrame        uint64
+
+	models map[*fizzle.Renderable]*motionModel
+}
+
+// beginFrame starts a new frame and forgets the Renderables that weren't
+// drawn in the last one.
+func (mt *motionTracker) beginFrame() {
+	mt.frame++
+	for r, m := range mt.models {
+		if m.frame+1 < mt.frame {
+			delete(mt.models, r)
+		}
+	}
+}
+
+// trackCamera records the unjittered view projection of the frame.
+func (mt *motionTracker) trackCamera(perspective mgl.Mat4, view mgl.Mat4) {
+	if mt.cameraFrame == mt.frame {
+		return
+	}
+	vp := perspective.Mul4(view)
+	if mt.cameraFrame+1 == mt.frame {
+		mt.prevViewProjection = mt.viewProjection
+	} else {
+		mt.prevViewProjection = vp
+	}
+	mt.viewProjection = vp
+	mt.cameraFrame = mt.frame
+}
+
+// bind binds the current and previous view projection and the previous
+// transform of the Renderable to the MOTION_* uniforms.
+func (mt *motionTracker) bind(gfx graphics.GraphicsProvider, r *fizzle.Renderable, shader *fizzle.RenderShader) {
+	loc := shader.GetUniformLocation("MOTION_PREV_M_MATRIX")
+	if loc >= 0 {
+		if mt.models == nil {
+			mt.models = make(map[*fizzle.Renderable]*motionModel)
+		}
+		model := r.GetTransformMat4()
+		m, ok := mt.models[r]
+		if !ok {
+			m = &motionModel{prev: model, curr: model, frame: mt.frame}
+			mt.models[r] = m
+		} else if m.frame != mt.frame {
+			if m.frame+1 == mt.frame {
+				m.prev = m.curr
+			} else {
+				m.prev = model
+			}
+			m.curr = model
+			m.frame = mt.frame
+		}
+		gfx.UniformMatrix4fv(loc, 1, false, &m.prev)
+	}
+	if loc := shader.GetUniformLocation("MOTION_VP_MATRIX"); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &mt.viewProjection)
+	}
+	if loc := shader.GetUniformLocation("MOTION_PREV_VP_MATRIX"); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &mt.prevViewProjection)
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package deferred
+
+var (
+	// GeometryVertShader330 is the GLSL vertex shader for the geometry pass.
+	// It passes the world space normal, the texture coordinates, a white
+	// instance color and the unjittered clip space position in this and
+	// the last frame on to GeometryFragShader330.
+	GeometryVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  uniform mat4 M_MATRIX;
+  uniform mat4 MOTION_VP_MATRIX;
+  uniform mat4 MOTION_PREV_VP_MATRIX;
+  uniform mat4 MOTION_PREV_M_MATRIX;
+  in vec3 VERTEX_POSITION;
+  in vec3 VERTEX_NORMAL;
+  in vec2 VERTEX_UV_0;
+  out vec3 vs_normal_world;
+  out vec2 vs_tex0_uv;
+  out vec4 vs_instance_color;
+  out vec4 vs_position_clip;
+  out vec4 vs_prev_position_clip;
+
+  void main()
+  {
+    vec4 position = vec4(VERTEX_POSITION, 1.0);
+    vs_normal_world = normalize(transpose(inverse(mat3(M_MATRIX))) * VERTEX_NORMAL);
+    vs_tex0_uv = VERTEX_UV_0;
+    vs_instance_color = vec4(1.0);
+    vs_position_clip = MOTION_VP_MATRIX * M_MATRIX * position;
+    vs_prev_position_clip = MOTION_PREV_VP_MATRIX * MOTION_PREV_M_MATRIX * position;
+    gl_Position = MVP_MATRIX * position;
+  }`
+
+	// GeometryInstancedVertShader330 is the GLSL vertex shader for the
+	// geometry pass of instanced draws. Each instance is placed with the
+	// INSTANCE_M_MATRIX vertex attribute and tinted with INSTANCE_COLOR
+	// when INSTANCE_COLORED is set. The instances are assumed to stand
+	// still, so their velocity only comes from the camera.
+	GeometryInstancedVertShader330 = `#version 330
+  uniform mat4 VP_MATRIX;
+  uniform mat4 MOTION_VP_MATRIX;
+  uniform mat4 MOTION_PREV_VP_MATRIX;
+  uniform int INSTANCE_COLORED;
+  in vec3 VERTEX_POSITION;
+  in vec3 VERTEX_NORMAL;
+  in vec2 VERTEX_UV_0;
+  in mat4 INSTANCE_M_MATRIX;
+  in vec4 INSTANCE_COLOR;
+  out vec3 vs_normal_world;
+  out vec2 vs_tex0_uv;
+  out vec4 vs_instance_color;
+  out vec4 vs_position_clip;
+  out vec4 vs_prev_position_clip;
+
+  void main()
+  {
+    vec4 world = INSTANCE_M_MATRIX * vec4(VERTEX_POSITION, 1.0);
+    vs_normal_world = normalize(transpose(inverse(mat3(INSTANCE_M_MATRIX))) * VERTEX_NORMAL);
+    vs_tex0_uv = VERTEX_UV_0;
+    vs_instance_color = INSTANCE_COLORED != 0 ? INSTANCE_COLOR : vec4(1.0);
+    vs_position_clip = MOTION_VP_MATRIX * world;
+    vs_prev_position_clip = MOTION_PREV_VP_MATRIX * world;
+    gl_Position = VP_MATRIX * world;
+  }`
+
+	// GeometryFragShader330 is the GLSL fragment shader for the geometry
+	// pass. It writes the material of the Renderable into the G-buffer:
+	// the albedo tinted by the instance color, the world space normal and the specular color with the
+	// shininess in the alpha channel, and the screen space velocity of the
+	// fragment since the last frame. The diffuse texture is only sampled
+	// when GBUFFER_TEXTURED is set and alpha cutout materials discard the
+	// fragments below MATERIAL_ALPHA_CUTOFF.
+	GeometryFragShader330 = `#version 330
+  uniform vec4 MATERIAL_DIFFUSE;
+  uniform vec4 MATERIAL_SPECULAR;
+  uniform float MATERIAL_SHININESS;
+  uniform float MATERIAL_ALPHA_CUTOFF;
+  uniform sampler2D MATERIAL_TEX_0;
+  uniform int GBUFFER_TEXTURED;
+  in vec3 vs_normal_world;
+  in vec2 vs_tex0_uv;
+  in vec4 vs_instance_color;
+  in vec4 vs_position_clip;
+  in vec4 vs_prev_position_clip;
+  layout(location = 0) out vec4 gbuffer_albedo;
+  layout(location = 1) out vec4 gbuffer_normal;
+  layout(location = 2) out vec4 gbuffer_material;
+  layout(location = 3) out vec2 gbuffer_velocity;
+
+  void main()
+  {
+    vec4 albedo = MATERIAL_DIFFUSE * vs_instance_color;
+    if (GBUFFER_TEXTURED != 0) {
+      albedo *= texture(MATERIAL_TEX_0, vs_tex0_uv);
+    }
+    if (albedo.a < MATERIAL_ALPHA_CUTOFF) {
+      discard;
+    }
+
+    gbuffer_albedo = vec4(albedo.rgb, 1.0);
+    gbuffer_normal = vec4(normalize(vs_normal_world), 0.0);
+    gbuffer_material = vec4(MATERIAL_SPECULAR.rgb, MATERIAL_SHININESS);
+    gbuffer_velocity = (vs_position_clip.xy / vs_position_clip.w - vs_prev_position_clip.xy / vs_prev_position_clip.w) * 0.5;
+  }`
+
+	// LightPassVertShader330 is the GLSL vertex shader for the light
+	// accumulation pass. It draws a full screen quad.
+	LightPassVertShader330 = `#version 330
+  in vec3 VERTEX_POSITION;
+  out vec2 vs_uv;
+
+  void main()
+  {
+    vs_uv = VERTEX_POSITION.xy * 0.5 + 0.5;
+    gl_Position = vec4(VERTEX_POSITION.xy, 0.0, 1.0);
+  }`
+
+	// LightPassFragShader330 is the GLSL fragment shader for the light
+	// accumulation pass. It reconstructs the world position of every pixel
+	// from the G-buffer depth and lights it with up to 16 lights at a time
+	// using the same model as the forward diffuse shaders. Pixels that no
+	// geometry was drawn to are discarded.
+	LightPassFragShader330 = `#version 330
+  uniform sampler2D GBUFFER_ALBEDO;
+  uniform sampler2D GBUFFER_NORMAL;
+  uniform sampler2D GBUFFER_MATERIAL;
+  uniform sampler2D GBUFFER_DEPTH;
+  uniform mat4 INV_VP_MATRIX;
+  uniform vec3 CAMERA_WORLD_POSITION;
+
+  uniform vec3 LIGHT_POSITION[16];
+  uniform vec3 LIGHT_DIRECTION[16];
+  uniform vec4 LIGHT_DIFFUSE[16];
+  uniform float LIGHT_DIFFUSE_INTENSITY[16];
+  uniform float LIGHT_SPECULAR_INTENSITY[16];
+  uniform float LIGHT_AMBIENT_INTENSITY[16];
+  uniform vec4 LIGHT_FALLOFF[16];
+  uniform vec2 LIGHT_SPOT_CUTOFF[16];
+  uniform int LIGHT_COUNT;
+  uniform sampler2D AMBIENT_OCCLUSION_TEX;
+  uniform int AMBIENT_OCCLUSION_ENABLED;
+
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  // CalcAttenuation returns the falloff of a positional light at the distance
+  // using the constant, linear and quadratic terms in LIGHT_FALLOFF with a
+  // window that smoothly reaches zero at the light's range, if it has one.
+  float CalcAttenuation(int i, float dist)
+  {
+    vec4 falloff = LIGHT_FALLOFF[i];
+    float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+    if (falloff.w > 0.0) {
+      float ratio = dist / falloff.w;
+      float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+      attenuation *= window * window;
+    }
+    return attenuation;
+  }
+
+  void main()
+  {
+    const float Epsilon = 0.0001;
+    float depth = texture(GBUFFER_DEPTH, vs_uv).r;
+    if (depth >= 1.0) {
+      discard;
+    }
+
+    vec4 world = INV_VP_MATRIX * vec4(vs_uv * 2.0 - 1.0, depth * 2.0 - 1.0, 1.0);
+    vec3 v_world = world.xyz / world.w;
+    vec3 n_world = normalize(texture(GBUFFER_NORMAL, vs_uv).xyz);
+    vec4 albedo = texture(GBUFFER_ALBEDO, vs_uv);
+    vec4 material = texture(GBUFFER_MATERIAL, vs_uv);
+    vec3 v = normalize(CAMERA_WORLD_POSITION - v_world);
+
+    vec4 ambient_color = vec4(0, 0, 0, 0);
+    vec4 diffuse_color = vec4(0, 0, 0, 0);
+    vec4 specular_color = vec4(0, 0, 0, 0);
+    vec3 s;
+    for (int i=0; i<LIGHT_COUNT; i++) {
+      // spot lights shine from the position and fade out between the
+      // cosines of the inner and outer cone angles
+      float spot = 1.0;
+      float attenuation = 1.0;
+      if (LIGHT_SPOT_CUTOFF[i].y > 0.0) {
+        s = LIGHT_POSITION[i] - v_world;
+        float cosAngle = dot(normalize(-s), normalize(LIGHT_DIRECTION[i]));
+        spot = smoothstep(LIGHT_SPOT_CUTOFF[i].y, LIGHT_SPOT_CUTOFF[i].x, cosAngle);
+        attenuation = CalcAttenuation(i, length(s));
+      } else if (dot(LIGHT_DIRECTION[i], LIGHT_DIRECTION[i]) < Epsilon) {
+        // point lights have no direction
+        s = LIGHT_POSITION[i] - v_world;
+        attenuation = CalcAttenuation(i, length(s));
+      } else {
+        s = -LIGHT_DIRECTION[i];
+      }
+
+      vec3 sN = normalize(s);
+      float sDotN = dot(n_world, sN);
+      float brightness = clamp(sDotN, 0.0, 1.0);
+
+      ambient_color += LIGHT_DIFFUSE[i] * LIGHT_AMBIENT_INTENSITY[i];
+      diffuse_color += LIGHT_DIFFUSE[i] * LIGHT_DIFFUSE_INTENSITY[i] * brightness * spot * attenuation;
+
+      if (sDotN > 0.0 && material.a > Epsilon) {
+        vec3 r = reflect(-sN, n_world);
+        float highlight = pow(max(0.0, dot(v, r)), material.a);
+        specular_color += vec4(material.rgb, 1.0) * LIGHT_SPECULAR_INTENSITY[i] * highlight * spot * attenuation;
+      }
+    }
+
+    // screen space ambient occlusion darkens the ambient light in creases
+    if (AMBIENT_OCCLUSION_ENABLED != 0) {
+      ambient_color *= texture(AMBIENT_OCCLUSION_TEX, vs_uv).r;
+    }
+
+    frag_color = vec4((albedo * (ambient_color + diffuse_color) + specular_color).rgb, 1.0);
+  }`
+)
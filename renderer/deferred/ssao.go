@@ -0,0 +1,40 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package deferred
+
+import (
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableSSAO creates the screen space ambient occlusion pass at the quality
+// preset. DrawLights computes it from the G-buffer depth and multiplies it
+// into the ambient light. Init must have been called first.
+func (dr *DeferredRenderer) EnableSSAO(quality renderer.SSAOQuality) error {
+	if dr.ssao != nil {
+		dr.ssao.SetQuality(quality)
+		return nil
+	}
+	ssao, err := renderer.NewSSAO(dr.gfx, dr.width, dr.height)
+	if err != nil {
+		return err
+	}
+	ssao.SetQuality(quality)
+	dr.ssao = ssao
+	return nil
+}
+
+// DisableSSAO releases the ambient occlusion pass.
+func (dr *DeferredRenderer) DisableSSAO() {
+	if dr.ssao == nil {
+		return
+	}
+	dr.ssao.Destroy()
+	dr.ssao = nil
+}
+
+// GetSSAO returns the ambient occlusion pass so that its samples, radius
+// and bias can be tuned, or nil if it isn't enabled.
+func (dr *DeferredRenderer) GetSSAO() *renderer.SSAO {
+	return dr.ssao
+}
@@ -0,0 +1,62 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package deferred
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableTAA turns on temporal anti-aliasing: the projection of every draw
+// is jittered and a TAA effect that reads the G-buffer's Velocity is added
+// to the post process stack. The lights should be drawn into the stack,
+// by setting Output to its Framebuffer, for the TAA to resolve them. The
+// TAA should be the first effect, so it should be enabled before other
+// effects are added to the stack.
+func (dr *DeferredRenderer) EnableTAA(ps *renderer.PostProcessStack) error {
+	if dr.taa != nil {
+		return nil
+	}
+	taa := renderer.NewTAA()
+	taa.Velocity = dr.Velocity
+	if err := ps.Add(taa); err != nil {
+		return err
+	}
+	dr.taa = taa
+	dr.taaStack = ps
+	return nil
+}
+
+// DisableTAA removes the TAA effect from the post process stack and stops
+// jittering the projection.
+func (dr *DeferredRenderer) DisableTAA() {
+	if dr.taa == nil {
+		return
+	}
+	dr.taaStack.Remove(dr.taa)
+	dr.taa = nil
+	dr.taaStack = nil
+}
+
+// GetTAA returns the TAA effect so that its feedback can be tuned and its
+// history reset on camera cuts, or nil if it isn't enabled.
+func (dr *DeferredRenderer) GetTAA() *renderer.TAA {
+	return dr.taa
+}
+
+// jitter returns the projection offset by the TAA jitter of the frame, or
+// unchanged if TAA isn't enabled.
+func (dr *DeferredRenderer) jitter(perspective mgl.Mat4) mgl.Mat4 {
+	if dr.taa == nil {
+		return perspective
+	}
+	return dr.taa.JitterProjection(perspective)
+}
+
+// trackCamera records the camera of the frame for the velocity and returns
+// the projection to draw with.
+func (dr *DeferredRenderer) trackCamera(perspective mgl.Mat4, view mgl.Mat4) mgl.Mat4 {
+	dr.motion.trackCamera(perspective, view)
+	return dr.jitter(perspective)
+}
@@ -0,0 +1,292 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// dynResHistorySize is the number of frame times averaged when
+	// deciding whether or not to change the scale.
+	dynResHistorySize = 16
+
+	// dynResQueryFrames is the number of frames of GPU timer queries that
+	// can be waiting for their results.
+	dynResQueryFrames = 4
+)
+
+// dynResQuery is the pair of timestamp queries around one frame's scene.
+type dynResQuery struct {
+	start   uint32
+	end     uint32
+	pending bool
+}
+
+// DynamicResolution renders the scene into an internal framebuffer whose
+// resolution is scaled down when the GPU takes longer than the target
+// frame time to draw it and scaled back up when there's headroom. The
+// scaled image is then upsampled to the window.
+//
+// The GPU time of the scene drawn between Begin and End is measured with
+// timestamp queries, so frames limited by the CPU or by vsync don't lower
+// the resolution. The results arrive a few frames late. Where timer queries
+// aren't supported, like OpenGL ES 2, the frame times have to be passed to
+// AddFrameTime instead.
+//
+// The framebuffer is allocated at the full window size and only a scaled
+// portion of it is rendered to, so changing the scale never reallocates.
+type DynamicResolution struct {
+	// TargetFrameTime is the frame time, in seconds, to try to stay under.
+	TargetFrameTime float64
+
+	// MinScale and MaxScale clamp the resolution scale.
+	MinScale float32
+	MaxScale float32
+
+	// ScaleStep is how much the scale changes in one adjustment.
+	ScaleStep float32
+
+	// Scale is the current resolution scale applied to both dimensions.
+	Scale float32
+
+	gfx      graphics.GraphicsProvider
+	fbo      graphics.Buffer
+	colorTex graphics.Texture
+	depthRB  graphics.Buffer
+	width    int32
+	height   int32
+
+	history      [dynResHistorySize]float64
+	historyCount int
+	historyNext  int
+
+	// queries time the scene on the GPU if gpuTiming is set; timing is
+	// set while the current frame's queries are in use
+	queries   [dynResQueryFrames]dynResQuery
+	queryNext int
+	gpuTiming bool
+	timing    bool
+}
+
+// NewDynamicResolution creates the internal framebuffer for a window of the
+// given size targeting 60 frames per second.
+func NewDynamicResolution(gfx graphics.GraphicsProvider, width, height int32) (*DynamicResolution, error) {
+	dr := new(DynamicResolution)
+	dr.gfx = gfx
+	dr.TargetFrameTime = 1.0 / 60.0
+	dr.MinScale = 0.5
+	dr.MaxScale = 1.0
+	dr.ScaleStep = 0.05
+	dr.Scale = 1.0
+
+	dr.gpuTiming = true
+	for i := range dr.queries {
+		q := &dr.queries[i]
+		q.start = gfx.GenQuery()
+		q.end = gfx.GenQuery()
+		if q.start == 0 || q.end == 0 {
+			dr.gpuTiming = false
+		}
+	}
+
+	err := dr.Resize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	return dr, nil
+}
+
+// Destroy releases the framebuffer, its attachments and the timer queries.
+func (dr *DynamicResolution) Destroy() {
+	dr.destroyFramebuffer()
+	for i := range dr.queries {
+		q := &dr.queries[i]
+		if q.start != 0 {
+			dr.gfx.DeleteQuery(q.start)
+		}
+		if q.end != 0 {
+			dr.gfx.DeleteQuery(q.end)
+		}
+		*q = dynResQuery{}
+	}
+	dr.gpuTiming = false
+}
+
+// destroyFramebuffer releases the framebuffer and its attachments.
+func (dr *DynamicResolution) destroyFramebuffer() {
+	if dr.fbo != 0 {
+		dr.gfx.DeleteFramebuffer(dr.fbo)
+		dr.fbo = 0
+	}
+	if dr.colorTex != 0 {
+		dr.gfx.DeleteTexture(dr.colorTex)
+		dr.colorTex = 0
+	}
+	if dr.depthRB != 0 {
+		dr.gfx.DeleteRenderbuffer(dr.depthRB)
+		dr.depthRB = 0
+	}
+}
+
+// Resize recreates the internal framebuffer for a new window size.
+func (dr *DynamicResolution) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid dynamic resolution size %dx%d", width, height)
+	}
+	dr.destroyFramebuffer()
+	dr.width = width
+	dr.height = height
+
+	gfx := dr.gfx
+	dr.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, dr.fbo)
+
+	dr.colorTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, dr.colorTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA8, width, height, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, dr.colorTex, 0)
+
+	dr.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, dr.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH24_STENCIL8, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_STENCIL_ATTACHMENT, graphics.RENDERBUFFER, dr.depthRB)
+
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "dynamic resolution")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	return err
+}
+
+// AddFrameTime records the time, in seconds, the GPU took to draw a frame
+// and adjusts the scale once enough frames have been averaged. It's called
+// with the measured GPU times by Begin; call it only if timer queries
+// aren't supported.
+func (dr *DynamicResolution) AddFrameTime(frameTime float64) {
+	dr.history[dr.historyNext] = frameTime
+	dr.historyNext = (dr.historyNext + 1) % dynResHistorySize
+	if dr.historyCount < dynResHistorySize {
+		dr.historyCount++
+		return
+	}
+
+	var avg float64
+	for _, t := range dr.history {
+		avg += t
+	}
+	avg /= dynResHistorySize
+
+	// a little hysteresis keeps the scale from bouncing every frame
+	changed := false
+	if avg > dr.TargetFrameTime*1.05 && dr.Scale > dr.MinScale {
+		dr.Scale -= dr.ScaleStep
+		changed = true
+	} else if avg < dr.TargetFrameTime*0.85 && dr.Scale < dr.MaxScale {
+		dr.Scale += dr.ScaleStep
+		changed = true
+	}
+	if dr.Scale < dr.MinScale {
+		dr.Scale = dr.MinScale
+	}
+	if dr.Scale > dr.MaxScale {
+		dr.Scale = dr.MaxScale
+	}
+
+	// start a fresh average at the new scale
+	if changed {
+		dr.historyCount = 0
+	}
+}
+
+// GetScaledResolution returns the size of the area of the framebuffer
+// that is rendered to at the current scale.
+func (dr *DynamicResolution) GetScaledResolution() (int32, int32) {
+	w := int32(float32(dr.width) * dr.Scale)
+	h := int32(float32(dr.height) * dr.Scale)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// Framebuffer returns the internal framebuffer the scene is drawn into.
+func (dr *DynamicResolution) Framebuffer() graphics.Buffer {
+	return dr.fbo
+}
+
+// Begin binds the internal framebuffer and sets the viewport to the scaled
+// resolution. The scene should be drawn between Begin and End. The GPU
+// times of earlier frames that became available are added first.
+func (dr *DynamicResolution) Begin() {
+	dr.timing = false
+	if dr.gpuTiming {
+		dr.collectGPUTimes()
+		q := &dr.queries[dr.queryNext]
+		if !q.pending {
+			dr.gfx.QueryCounter(q.start, graphics.TIMESTAMP)
+			dr.timing = true
+		}
+	}
+
+	w, h := dr.GetScaledResolution()
+	dr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, dr.fbo)
+	dr.gfx.Viewport(0, 0, w, h)
+}
+
+// End upsamples the scaled image to the default framebuffer and restores
+// the viewport to the full window size.
+func (dr *DynamicResolution) End() {
+	gfx := dr.gfx
+	if dr.timing {
+		q := &dr.queries[dr.queryNext]
+		gfx.QueryCounter(q.end, graphics.TIMESTAMP)
+		q.pending = true
+		dr.queryNext = (dr.queryNext + 1) % dynResQueryFrames
+		dr.timing = false
+	}
+
+	w, h := dr.GetScaledResolution()
+	gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, dr.fbo)
+	gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, 0)
+	gfx.BlitFramebuffer(0, 0, w, h, 0, 0, dr.width, dr.height, graphics.COLOR_BUFFER_BIT, graphics.LINEAR)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	gfx.Viewport(0, 0, dr.width, dr.height)
+}
+
+// collectGPUTimes adds the GPU times of the finished frames, oldest first,
+// stopping at the first frame whose results aren't available yet.
+func (dr *DynamicResolution) collectGPUTimes() {
+	for i := 0; i < dynResQueryFrames; i++ {
+		q := &dr.queries[(dr.queryNext+i)%dynResQueryFrames]
+		if !q.pending {
+			continue
+		}
+		var available uint32
+		dr.gfx.GetQueryObjectuiv(q.end, graphics.QUERY_RESULT_AVAILABLE, &available)
+		if available == 0 {
+			return
+		}
+
+		var start, end uint64
+		dr.gfx.GetQueryObjectui64v(q.start, graphics.QUERY_RESULT, &start)
+		dr.gfx.GetQueryObjectui64v(q.end, graphics.QUERY_RESULT, &end)
+		q.pending = false
+		if end > start {
+			dr.AddFrameTime(float64(end-start) / 1e9)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// CheckFramebuffer returns an error naming the framebuffer if the one bound
+// to target isn't complete.
+func CheckFramebuffer(gfx graphics.GraphicsProvider, target graphics.Enum, name string) error {
+	status := gfx.CheckFramebufferStatus(target)
+	if status == graphics.FRAMEBUFFER_COMPLETE {
+		return nil
+	}
+	return fmt.Errorf("the %s framebuffer is incomplete: %s", name, FramebufferStatusString(status))
+}
+
+// FramebufferStatusString returns the name of a framebuffer completeness status.
+func FramebufferStatusString(status graphics.Enum) string {
+	switch status {
+	case graphics.FRAMEBUFFER_COMPLETE:
+		return "FRAMEBUFFER_COMPLETE"
+	case graphics.FRAMEBUFFER_UNDEFINED:
+		return "FRAMEBUFFER_UNDEFINED"
+	case graphics.FRAMEBUFFER_INCOMPLETE_ATTACHMENT:
+		return "FRAMEBUFFER_INCOMPLETE_ATTACHMENT"
+	case graphics.FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT:
+		return "FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT"
+	case graphics.FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER:
+		return "FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER"
+	case graphics.FRAMEBUFFER_INCOMPLETE_READ_BUFFER:
+		return "FRAMEBUFFER_INCOMPLETE_READ_BUFFER"
+	case graphics.FRAMEBUFFER_UNSUPPORTED:
+		return "FRAMEBUFFER_UNSUPPORTED"
+	case graphics.FRAMEBUFFER_INCOMPLETE_MULTISAMPLE:
+		return "FRAMEBUFFER_INCOMPLETE_MULTISAMPLE"
+	case graphics.FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS:
+		return "FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS"
+	default:
+		return fmt.Sprintf("unknown status 0x%x", uint32(status))
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// HemisphereAmbient is an ambient light that blends from a ground color for
+// surfaces facing down to a sky color for surfaces facing up. When enabled
+// it replaces the AmbientIntensity of the lights in the built-in forward
+// shaders, which gives unlit sides of objects some shape for almost no cost.
+type HemisphereAmbient struct {
+	// SkyColor is the ambient light on surfaces facing Up.
+	SkyColor mgl.Vec4
+
+	// GroundColor is the ambient light on surfaces facing away from Up.
+	GroundColor mgl.Vec4
+
+	// Up is the world space direction of the sky; a zero vector means +Y.
+	Up mgl.Vec3
+
+	// Intensity scales both colors; 0 disables the hemisphere ambient and
+	// the lights' AmbientIntensity is used instead.
+	Intensity float32
+}
+
+// IsEnabled returns true if the hemisphere ambient replaces the ambient
+// light of the lights.
+func (a *HemisphereAmbient) IsEnabled() bool {
+	return a.Intensity > 0.0
+}
+
+// bind sets the AMBIENT_* uniforms of the shader. The additive passes of a
+// draw split over many lights get no ambient light since the first pass
+// already added it. Shaders without AMBIENT_HEMISPHERE are skipped.
+func (a *HemisphereAmbient) bind(gfx graphics.GraphicsProvider, shader *fizzle.RenderShader, additive bool) {
+	shaderHemisphere := shader.GetUniformLocation("AMBIENT_HEMISPHERE")
+	if shaderHemisphere < 0 {
+		return
+	}
+	if !a.IsEnabled() {
+		gfx.Uniform1i(shaderHemisphere, 0)
+		return
+	}
+	gfx.Uniform1i(shaderHemisphere, 1)
+
+	intensity := a.Intensity
+	if additive {
+		intensity = 0.0
+	}
+	if loc := shader.GetUniformLocation("AMBIENT_SKY_COLOR"); loc >= 0 {
+		c := a.SkyColor.Mul(intensity)
+		gfx.Uniform4f(loc, c[0], c[1], c[2], c[3])
+	}
+	if loc := shader.GetUniformLocation("AMBIENT_GROUND_COLOR"); loc >= 0 {
+		c := a.GroundColor.Mul(intensity)
+		gfx.Uniform4f(loc, c[0], c[1], c[2], c[3])
+	}
+	if loc := shader.GetUniformLocation("AMBIENT_UP"); loc >= 0 {
+		up := mgl.Vec3{0.0, 1.0, 0.0}
+		if a.Up.Len() > 0.0 {
+			up = a.Up.Normalize()
+		}
+		gfx.Uniform3f(loc, up[0], up[1], up[2])
+	}
+}
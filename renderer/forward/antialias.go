@@ -0,0 +1,95 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableMSAA creates a multisampled framebuffer with the number of samples
+// per pixel, usually 2, 4 or 8, that the scene drawn between BeginScene and
+// EndScene is rendered into and then resolved into the PostProcess scene
+// framebuffer, if PostProcess is set, or the window. PostProcess should be
+// set before MSAA is enabled since the color format has to match it. If
+// MSAA is already enabled, only the sample count is changed.
+//
+// An error is returned if the hardware doesn't support the sample count.
+func (fr *ForwardRenderer) EnableMSAA(samples int32) error {
+	if fr.msaa != nil {
+		return fr.msaa.SetSamples(samples)
+	}
+
+	var colorFormat graphics.Enum = graphics.RGBA8
+	if fr.PostProcess != nil {
+		colorFormat = graphics.RGBA16F
+	}
+	msaa, err := renderer.NewMSAA(fr.gfx, fr.width, fr.height, samples, colorFormat)
+	if err != nil {
+		return err
+	}
+	msaa.ResolveDepth = fr.PostProcess != nil
+	fr.msaa = msaa
+	fr.resizables.Register(msaa)
+	return nil
+}
+
+// DisableMSAA releases the multisampled framebuffer; the scene is drawn
+// directly into the PostProcess scene framebuffer or the window afterwards.
+func (fr *ForwardRenderer) DisableMSAA() {
+	if fr.msaa == nil {
+		return
+	}
+	fr.resizables.Unregister(fr.msaa)
+	fr.msaa.Destroy()
+	fr.msaa = nil
+}
+
+// GetMSAA returns the multisampled framebuffer or nil if MSAA isn't enabled.
+func (fr *ForwardRenderer) GetMSAA() *renderer.MSAA {
+	return fr.msaa
+}
+
+// EnableFXAA adds the FXAA effect to the end of PostProcess as a cheaper
+// fallback for hardware where MSAA is too slow or unsupported. A
+// PostProcessStack is created for PostProcess if it's not set; like one
+// set by the application, it isn't destroyed with the renderer.
+func (fr *ForwardRenderer) EnableFXAA() error {
+	if fr.fxaa != nil {
+		return nil
+	}
+	if fr.PostProcess == nil {
+		ps, err := renderer.NewPostProcessStack(fr.gfx, fr.width, fr.height)
+		if err != nil {
+			return err
+		}
+		fr.PostProcess = ps
+	}
+
+	fxaa := renderer.NewFXAA()
+	if err := fr.PostProcess.Add(fxaa); err != nil {
+		return err
+	}
+	fr.fxaa = fxaa
+	return nil
+}
+
+// DisableFXAA removes the FXAA effect from PostProcess and destroys it.
+func (fr *ForwardRenderer) DisableFXAA() {
+	if fr.fxaa == nil {
+		return
+	}
+	if fr.PostProcess != nil {
+		fr.PostProcess.Remove(fr.fxaa)
+	} else {
+		fr.fxaa.Destroy()
+	}
+	fr.fxaa = nil
+}
+
+// GetFXAA returns the FXAA effect so that its thresholds can be tuned, or
+// nil if it isn't enabled.
+func (fr *ForwardRenderer) GetFXAA() *renderer.FXAA {
+	return fr.fxaa
+}
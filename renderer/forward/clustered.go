@@ -0,0 +1,351 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// clusterTexWidth is the width of the textures the clustered lighting
+	// data is uploaded in; the data wraps onto as many rows as needed.
+	clusterTexWidth = 1024
+)
+
+// ClusteredLight is a point light with a limited radius for clustered lighting.
+type ClusteredLight struct {
+	// Position is the location of the light in world space
+	Position mgl.Vec3
+
+	// Radius is the distance past which the light has no effect
+	Radius float32
+
+	// Color is the color the light emits
+	Color mgl.Vec3
+
+	// Intensity scales the color of the light
+	Intensity float32
+}
+
+// LightClusters bins a large number of ClusteredLights into a grid of
+// clusters that divides the view frustum into screen tiles and exponential
+// depth slices, so that shaders only light each fragment with the lights
+// whose radius touches its cluster.
+//
+// Set it as the ForwardRenderer's Clusters and call Update every frame once
+// the camera has moved. Shaders read the data with the CLUSTER_* uniforms,
+// like the diffuse_clustered shader does:
+//
+//	CLUSTER_LIGHTS   two texels per light: position and radius, color and intensity
+//	CLUSTER_GRID     one texel per cluster: the offset and count of its indexes
+//	CLUSTER_INDICES  the light indexes of the clusters, four per texel
+//	CLUSTER_SIZE     the tile and slice counts as a vec3
+//	CLUSTER_DEPTH    the near and far distances the slices cover
+//	CLUSTER_SCREEN   the size of the viewport in pixels
+//
+// Texels are addressed in rows of 1024.
+type LightClusters struct {
+	// Lights are the lights to bin with the next Update
+	Lights []ClusteredLight
+
+	// TilesX is the number of tiles the screen is divided into horizontally
+	TilesX int
+
+	// TilesY is the number of tiles the screen is divided into vertically
+	TilesY int
+
+	// Slices is the number of depth slices between Near and Far
+	Slices int
+
+	// Near is the distance of the first depth slice; set by Update
+	Near float32
+
+	// Far is the distance of the last depth slice; set by Update
+	Far float32
+
+	lightTex   graphics.Texture
+	gridTex    graphics.Texture
+	indicesTex graphics.Texture
+
+	// scratch storage reused between updates
+	counts    []int32
+	assigned  []clusterAssignment
+	lightData []float32
+	gridData  []float32
+	indexData []float32
+
+	gfx graphics.GraphicsProvider
+}
+
+// clusterAssignment records that a light touches a cluster.
+type clusterAssignment struct {
+	cluster int32
+	light   int32
+}
+
+// NewLightClusters creates the textures for clustered lighting with the
+// screen divided into tilesX by tilesY tiles and the depth into slices.
+func NewLightClusters(gfx graphics.GraphicsProvider, tilesX, tilesY, slices int) (*LightClusters, error) {
+	if tilesX <= 0 || tilesY <= 0 || slices <= 0 {
+		return nil, fmt.Errorf("invalid cluster grid %dx%dx%d", tilesX, tilesY, slices)
+	}
+
+	lc := new(LightClusters)
+	lc.gfx = gfx
+	lc.TilesX = tilesX
+	lc.TilesY = tilesY
+	lc.Slices = slices
+	lc.lightTex = lc.newDataTexture()
+	lc.gridTex = lc.newDataTexture()
+	lc.indicesTex = lc.newDataTexture()
+
+	err := fizzle.CheckGraphicsError(gfx, "creating the light cluster textures")
+	if err != nil || lc.lightTex == 0 || lc.gridTex == 0 || lc.indicesTex == 0 {
+		lc.Destroy()
+		if err == nil {
+			err = fmt.Errorf("failed to generate the light cluster textures")
+		}
+		return nil, err
+	}
+	return lc, nil
+}
+
+// Destroy releases the textures of the clusters.
+func (lc *LightClusters) Destroy() {
+	for _, tex := range []*graphics.Texture{&lc.lightTex, &lc.gridTex, &lc.indicesTex} {
+		if *tex != 0 {
+			lc.gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
+}
+
+// newDataTexture creates a float texture sampled with texelFetch.
+func (lc *LightClusters) newDataTexture() graphics.Texture {
+	gfx := lc.gfx
+	tex := gfx.GenTexture()
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	return tex
+}
+
+// clusterCount returns the number of clusters in the grid.
+func (lc *LightClusters) clusterCount() int {
+	return lc.TilesX * lc.TilesY * lc.Slices
+}
+
+// slice returns the depth slice of a positive view space depth.
+func (lc *LightClusters) slice(depth float32) int {
+	s := int(math.Log(float64(depth/lc.Near)) / math.Log(float64(lc.Far/lc.Near)) * float64(lc.Slices))
+	return clampInt(s, 0, lc.Slices-1)
+}
+
+// Update bins the Lights into the clusters of the frustum described by the
+// camera's matrixes, with the depth slices spread between near and far, and
+// uploads the results for the next draws.
+func (lc *LightClusters) Update(perspective mgl.Mat4, view mgl.Mat4, near float32, far float32) {
+	lc.Near = near
+	lc.Far = far
+
+	clusterCount := lc.clusterCount()
+	if cap(lc.counts) < clusterCount {
+		lc.counts = make([]int32, clusterCount)
+	}
+	lc.counts = lc.counts[:clusterCount]
+	for i := range lc.counts {
+		lc.counts[i] = 0
+	}
+	lc.assigned = lc.assigned[:0]
+
+	for li := range lc.Lights {
+		lc.assignLight(int32(li), perspective, view)
+	}
+
+	// lay out the light indexes of each cluster one after the other
+	lc.gridData = resizeFloats(lc.gridData, clusterCount*4)
+	offset := int32(0)
+	for c, count := range lc.counts {
+		lc.gridData[c*4] = float32(offset)
+		lc.gridData[c*4+1] = 0.0
+		lc.gridData[c*4+2] = 0.0
+		lc.gridData[c*4+3] = 0.0
+		offset += count
+	}
+	lc.indexData = resizeFloats(lc.indexData, roundUpToTexels(len(lc.assigned), 4))
+	for _, a := range lc.assigned {
+		slot := int32(lc.gridData[a.cluster*4]) + int32(lc.gridData[a.cluster*4+1])
+		lc.indexData[slot] = float32(a.light)
+		lc.gridData[a.cluster*4+1]++
+	}
+
+	lc.lightData = resizeFloats(lc.lightData, len(lc.Lights)*8)
+	for i, l := range lc.Lights {
+		data := lc.lightData[i*8 : i*8+8]
+		data[0], data[1], data[2], data[3] = l.Position[0], l.Position[1], l.Position[2], l.Radius
+		data[4], data[5], data[6], data[7] = l.Color[0], l.Color[1], l.Color[2], l.Intensity
+	}
+
+	lc.upload(lc.lightTex, &lc.lightData)
+	lc.upload(lc.gridTex, &lc.gridData)
+	lc.upload(lc.indicesTex, &lc.indexData)
+}
+
+// assignLight adds the light to every cluster its bounding box overlaps.
+func (lc *LightClusters) assignLight(li int32, perspective mgl.Mat4, view mgl.Mat4) {
+	l := &lc.Lights[li]
+	center := view.Mul4x1(l.Position.Vec4(1.0)).Vec3()
+	depth := -center[2]
+	if l.Radius <= 0.0 || depth+l.Radius < lc.Near || depth-l.Radius > lc.Far {
+		return
+	}
+
+	s0 := lc.slice(float32(math.Max(float64(depth-l.Radius), float64(lc.Near))))
+	s1 := lc.slice(float32(math.Min(float64(depth+l.Radius), float64(lc.Far))))
+
+	// project the corners of the light's bounding box to find its tiles;
+	// lights reaching past the near plane may cover the whole screen
+	x0, y0, x1, y1 := 0, 0, lc.TilesX-1, lc.TilesY-1
+	if depth-l.Radius > lc.Near {
+		minX, minY := float32(math.MaxFloat32), float32(math.MaxFloat32)
+		maxX, maxY := float32(-math.MaxFloat32), float32(-math.MaxFloat32)
+		for corner := 0; corner < 8; corner++ {
+			p := center
+			p[0] += cornerSign(corner, 1) * l.Radius
+			p[1] += cornerSign(corner, 2) * l.Radius
+			p[2] += cornerSign(corner, 4) * l.Radius
+			clip := perspective.Mul4x1(p.Vec4(1.0))
+			ndcX, ndcY := clip[0]/clip[3], clip[1]/clip[3]
+			minX, maxX = float32(math.Min(float64(minX), float64(ndcX))), float32(math.Max(float64(maxX), float64(ndcX)))
+			minY, maxY = float32(math.Min(float64(minY), float64(ndcY))), float32(math.Max(float64(maxY), float64(ndcY)))
+		}
+		if maxX < -1.0 || minX > 1.0 || maxY < -1.0 || minY > 1.0 {
+			return
+		}
+		x0 = clampInt(int((minX*0.5+0.5)*float32(lc.TilesX)), 0, lc.TilesX-1)
+		x1 = clampInt(int((maxX*0.5+0.5)*float32(lc.TilesX)), 0, lc.TilesX-1)
+		y0 = clampInt(int((minY*0.5+0.5)*float32(lc.TilesY)), 0, lc.TilesY-1)
+		y1 = clampInt(int((maxY*0.5+0.5)*float32(lc.TilesY)), 0, lc.TilesY-1)
+	}
+
+	for z := s0; z <= s1; z++ {
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				c := int32(x + lc.TilesX*(y+lc.TilesY*z))
+				lc.counts[c]++
+				lc.assigned = append(lc.assigned, clusterAssignment{cluster: c, light: li})
+			}
+		}
+	}
+}
+
+// upload replaces the contents of the data texture with the RGBA values,
+// wrapped into rows of clusterTexWidth texels. The data is padded to fill
+// the last row.
+func (lc *LightClusters) upload(tex graphics.Texture, dataPtr *[]float32) {
+	texels := len(*dataPtr) / 4
+	width, height := clusterTexWidth, (texels+clusterTexWidth-1)/clusterTexWidth
+	if height <= 1 {
+		width, height = texels, 1
+	}
+	if width == 0 {
+		// keep a texel around so the sampler is complete
+		width = 1
+	}
+	*dataPtr = resizeFloats(*dataPtr, width*height*4)
+	data := *dataPtr
+
+	gfx := lc.gfx
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, int32(width), int32(height), 0,
+		graphics.RGBA, graphics.FLOAT, gfx.Ptr(data), len(data)*4)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+}
+
+// bindClusters binds the light cluster textures and parameters if the
+// renderer has Clusters and the shader uses them.
+func (fr *ForwardRenderer) bindClusters(shader *fizzle.RenderShader, texturesBound *int32) {
+	lc := fr.Clusters
+	if lc == nil {
+		return
+	}
+	gfx := fr.gfx
+
+	textures := [...]struct {
+		name string
+		tex  graphics.Texture
+	}{
+		{"CLUSTER_LIGHTS", lc.lightTex},
+		{"CLUSTER_GRID", lc.gridTex},
+		{"CLUSTER_INDICES", lc.indicesTex},
+	}
+	for _, t := range textures {
+		if loc := shader.GetUniformLocation(t.name); loc >= 0 {
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			gfx.BindTexture(graphics.TEXTURE_2D, t.tex)
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+
+	if loc := shader.GetUniformLocation("CLUSTER_SIZE"); loc >= 0 {
+		// the clustered lights are only added in the first of several light passes
+		if fr.lightPasses.pass > 0 {
+			gfx.Uniform3f(loc, 0.0, 0.0, 0.0)
+		} else {
+			gfx.Uniform3f(loc, float32(lc.TilesX), float32(lc.TilesY), float32(lc.Slices))
+		}
+	}
+	if loc := shader.GetUniformLocation("CLUSTER_DEPTH"); loc >= 0 {
+		gfx.Uniform2f(loc, lc.Near, lc.Far)
+	}
+	if loc := shader.GetUniformLocation("CLUSTER_SCREEN"); loc >= 0 {
+		width, height := fr.GetResolution()
+		gfx.Uniform2f(loc, float32(width), float32(height))
+	}
+}
+
+// cornerSign returns -1 or 1 for a box corner depending on the bit.
+func cornerSign(corner int, bit int) float32 {
+	if corner&bit != 0 {
+		return 1.0
+	}
+	return -1.0
+}
+
+// roundUpToTexels returns the number of floats needed to store count values
+// packed perTexel to an RGBA texel.
+func roundUpToTexels(count int, perTexel int) int {
+	return (count + perTexel - 1) / perTexel * 4
+}
+
+// resizeFloats returns a slice of length n reusing the storage of s if possible.
+func resizeFloats(s []float32, n int) []float32 {
+	if cap(s) < n {
+		grown := make([]float32, n)
+		copy(grown, s)
+		return grown
+	}
+	return s[:n]
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
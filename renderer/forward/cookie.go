@@ -0,0 +1,87 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// cookieFar is the far distance of the projection for the cookie of a
+	// spot light without a Range or shadow map.
+	cookieFar = 1000.0
+)
+
+// CookieMatrix returns the matrix that projects world positions into the
+// texture coordinates of the light's Cookie. Lights with a shadow map use
+// the shadow map's projection, so UpdateShadowMapData should have been
+// called since the light last moved. Otherwise spot lights project through
+// their outer cone and directional lights project orthographically along
+// Direction with the cookie repeating every CookieSize units.
+func (l *Light) CookieMatrix() mgl.Mat4 {
+	if l.ShadowMap != nil {
+		return l.ShadowMap.BiasedMatrix
+	}
+
+	dir := l.Direction
+	if dir.Len() == 0.0 {
+		dir = mgl.Vec3{0.0, -1.0, 0.0}
+	}
+	dir = dir.Normalize()
+	up := mgl.Vec3{0.0, 1.0, 0.0}
+	if d := dir.Dot(up); d > 0.999 || d < -0.999 {
+		up = mgl.Vec3{0.0, 0.0, 1.0}
+	}
+
+	var viewProj mgl.Mat4
+	if l.IsSpot() {
+		far := l.Range
+		if far <= 0.0 {
+			far = cookieFar
+		}
+		_, outerCos := l.spotCutoff()
+		fovy := 2.0 * float32(math.Acos(float64(outerCos)))
+		view := mgl.LookAtV(l.Position, l.Position.Add(dir), up)
+		viewProj = mgl.Perspective(fovy, 1.0, 0.01, far).Mul4(view)
+	} else {
+		size := l.CookieSize
+		if size <= 0.0 {
+			size = 1.0
+		}
+		half := size * 0.5
+		view := mgl.LookAtV(mgl.Vec3{}, dir, up)
+		viewProj = mgl.Ortho(-half, half, -half, half, -cookieFar, cookieFar).Mul4(view)
+	}
+	return shadowBiasMat.Mul4(viewProj)
+}
+
+// bindCookie binds the light's cookie texture and projection, or no texture
+// for lights without a cookie.
+func (fr *ForwardRenderer) bindCookie(light *Light, names *lightUniforms, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := fr.gfx
+	if loc := shader.GetUniformLocation(names.cookieEnabled); loc >= 0 {
+		if light.Cookie != 0 {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+	if loc := shader.GetUniformLocation(names.cookie); loc >= 0 {
+		// bind a 0 for lights without a cookie so that every sampler is bound
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, light.Cookie)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if light.Cookie != 0 {
+		if loc := shader.GetUniformLocation(names.cookieMatrix); loc >= 0 {
+			light.cookieMatrix = light.CookieMatrix()
+			gfx.UniformMatrix4fv(loc, 1, false, &light.cookieMatrix)
+		}
+	}
+}
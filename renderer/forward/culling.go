@@ -0,0 +1,54 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// isCulled returns true if FrustumCulling is set and the Renderable node is
+// outside of the frustum of the matrices it's drawn with, or of the light
+// while drawing a shadow map.
+func (fr *ForwardRenderer) isCulled(r *fizzle.Renderable, perspective mgl.Mat4, view mgl.Mat4) bool {
+	if !fr.FrustumCulling || r.IsGroup {
+		return false
+	}
+	var vp mgl.Mat4
+	if fr.currentShadowPassVP != nil {
+		vp = *fr.currentShadowPassVP
+	} else {
+		vp = perspective.Mul4(view)
+	}
+	frustum := fr.getCullFrustum(vp)
+	if frustum.IsRenderableVisible(r) {
+		return false
+	}
+	fr.culledCount++
+	return true
+}
+
+// getCullFrustum returns the frustum of the view projection matrix, which
+// is only extracted again when the matrix changes.
+func (fr *ForwardRenderer) getCullFrustum(vp mgl.Mat4) *renderer.Frustum {
+	if !fr.cullFrustumSet || vp != fr.cullVP {
+		fr.cullVP = vp
+		fr.cullFrustum.Update(vp)
+		fr.cullFrustumSet = true
+	}
+	return &fr.cullFrustum
+}
+
+// GetCulledCount returns the number of Renderable nodes skipped by the
+// frustum culling since the last ResetCulledCount.
+func (fr *ForwardRenderer) GetCulledCount() int {
+	return fr.culledCount
+}
+
+// ResetCulledCount sets the count of culled nodes back to zero; call it
+// at the start of a frame to count the nodes culled in that frame.
+func (fr *ForwardRenderer) ResetCulledCount() {
+	fr.culledCount = 0
+}
@@ -0,0 +1,186 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/groggy"
+)
+
+var (
+	// DepthPrepassVertShader330 is the GLSL vertex shader of the depth
+	// prepass. It has to transform the vertexes exactly like the lit
+	// shaders for their fragments to pass the EQUAL depth test.
+	DepthPrepassVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  in vec3 VERTEX_POSITION;
+  in vec2 VERTEX_UV_0;
+  out vec2 vs_tex0_uv;
+
+  void main()
+  {
+    vs_tex0_uv = VERTEX_UV_0;
+    gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+  }`
+
+	// DepthPrepassInstancedVertShader330 is the GLSL vertex shader of the
+	// depth prepass for instanced draws.
+	DepthPrepassInstancedVertShader330 = `#version 330
+  uniform mat4 VP_MATRIX;
+  in vec3 VERTEX_POSITION;
+  in vec2 VERTEX_UV_0;
+  in mat4 INSTANCE_M_MATRIX;
+  out vec2 vs_tex0_uv;
+
+  void main()
+  {
+    vec3 position = (INSTANCE_M_MATRIX * vec4(VERTEX_POSITION, 1.0)).xyz;
+    vs_tex0_uv = VERTEX_UV_0;
+    gl_Position = VP_MATRIX * vec4(position, 1.0);
+  }`
+
+	// DepthPrepassFragShader330 is the GLSL fragment shader of the depth
+	// prepass. It only writes depth, discarding the fragments of alpha
+	// cutout materials below MATERIAL_ALPHA_CUTOFF.
+	DepthPrepassFragShader330 = `#version 330
+  uniform vec4 MATERIAL_DIFFUSE;
+  uniform sampler2D MATERIAL_TEX_0;
+  uniform float MATERIAL_ALPHA_CUTOFF;
+  in vec2 vs_tex0_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    if (MATERIAL_ALPHA_CUTOFF > 0.0) {
+      float alpha = texture(MATERIAL_TEX_0, vs_tex0_uv).a * MATERIAL_DIFFUSE.a;
+      if (alpha < MATERIAL_ALPHA_CUTOFF) {
+        discard;
+      }
+    }
+    frag_color = vec4(0.0);
+  }`
+)
+
+// depthPrepassState is the pass of DrawOpaqueScene being drawn.
+type depthPrepassState int
+
+const (
+	// depthPrepassNone is outside of DrawOpaqueScene or without DepthPrepass
+	depthPrepassNone depthPrepassState = iota
+
+	// depthPrepassDepth draws the depth only
+	depthPrepassDepth
+
+	// depthPrepassLit draws the lit fragments matching the depth
+	depthPrepassLit
+)
+
+// DrawOpaqueScene calls drawScene to draw the opaque Renderables. If
+// DepthPrepass is set, it's called twice: first the depth of the scene is
+// drawn with a minimal shader and the color writes masked off, and then the
+// scene is drawn normally with the depth test set to EQUAL, so that the
+// expensive lit shaders only run once for every visible pixel no matter
+// how much the geometry overlaps. Transparent Renderables should be drawn
+// after it returns.
+//
+// Skinned Renderables and DrawRenderableSkinnedInstanced draw their depth
+// with their own shaders, as do DrawLines and DrawPoints.
+func (fr *ForwardRenderer) DrawOpaqueScene(drawScene func()) {
+	if !fr.DepthPrepass {
+		drawScene()
+		return
+	}
+
+	gfx := fr.gfx
+	fr.Profiler.Begin("depth prepass")
+	fr.depthPass = depthPrepassDepth
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.DepthFunc(graphics.LESS)
+	gfx.DepthMask(true)
+	gfx.ColorMask(false, false, false, false)
+	drawScene()
+	gfx.ColorMask(true, true, true, true)
+	fr.Profiler.End()
+
+	fr.depthPass = depthPrepassLit
+	fr.restoreDepthState()
+	drawScene()
+
+	fr.depthPass = depthPrepassNone
+	fr.restoreDepthState()
+}
+
+// restoreDepthState sets the depth test and writes for the pass being
+// drawn: the lit pass of a depth prepass only draws the fragments matching
+// the depth without writing it and transparent Renderables don't write it.
+func (fr *ForwardRenderer) restoreDepthState() {
+	gfx := fr.gfx
+	if fr.depthPass == depthPrepassLit {
+		gfx.DepthFunc(graphics.EQUAL)
+		gfx.DepthMask(false)
+		return
+	}
+	gfx.DepthMask(!fr.transparentPass)
+	gfx.DepthFunc(graphics.LESS)
+}
+
+// prepassShader returns the shader to draw the Renderable with. During the
+// depth prepass, Renderables without a skeleton are drawn with the depth
+// prepass shader.
+func (fr *ForwardRenderer) prepassShader(r *fizzle.Renderable, shader *fizzle.RenderShader) *fizzle.RenderShader {
+	if fr.depthPass != depthPrepassDepth || r.HasSkeleton() || !fr.initDepthPrepass() {
+		return shader
+	}
+	return fr.depthPrepassShader
+}
+
+// prepassInstancedShader returns the shader to draw instances with. During
+// the depth prepass, the instanced depth prepass shader is used.
+func (fr *ForwardRenderer) prepassInstancedShader(shader *fizzle.RenderShader) *fizzle.RenderShader {
+	if fr.depthPass != depthPrepassDepth || !fr.initDepthPrepass() {
+		return shader
+	}
+	return fr.depthPrepassInstancedShader
+}
+
+// initDepthPrepass compiles the depth prepass shaders the first time
+// they're needed and returns true if they're available.
+func (fr *ForwardRenderer) initDepthPrepass() bool {
+	if fr.depthPrepassShader != nil {
+		return true
+	}
+	if fr.depthPrepassFailed {
+		return false
+	}
+
+	shader, err := fizzle.LoadShaderProgram(DepthPrepassVertShader330, DepthPrepassFragShader330, nil)
+	if err != nil {
+		groggy.Logsf("ERROR", "Failed to compile the depth prepass shader: %v", err)
+		fr.depthPrepassFailed = true
+		return false
+	}
+	instanced, err := fizzle.LoadShaderProgram(DepthPrepassInstancedVertShader330, DepthPrepassFragShader330, nil)
+	if err != nil {
+		groggy.Logsf("ERROR", "Failed to compile the instanced depth prepass shader: %v", err)
+		shader.Destroy()
+		fr.depthPrepassFailed = true
+		return false
+	}
+	fr.depthPrepassShader = shader
+	fr.depthPrepassInstancedShader = instanced
+	return true
+}
+
+// destroyDepthPrepass releases the depth prepass shaders.
+func (fr *ForwardRenderer) destroyDepthPrepass() {
+	if fr.depthPrepassShader != nil {
+		fr.depthPrepassShader.Destroy()
+		fr.depthPrepassShader = nil
+	}
+	if fr.depthPrepassInstancedShader != nil {
+		fr.depthPrepassInstancedShader.Destroy()
+		fr.depthPrepassInstancedShader = nil
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+var (
+	// DistortionVertShader330 is the GLSL vertex shader for drawing
+	// distortion emitters into the offset buffer.
+	DistortionVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  uniform mat4 MV_MATRIX;
+  in vec3 VERTEX_POSITION;
+  in vec3 VERTEX_NORMAL;
+  in vec2 VERTEX_UV_0;
+
+  out vec3 vs_normal_view;
+  out vec2 vs_tex0_uv;
+
+  void main()
+  {
+    vs_normal_view = mat3(MV_MATRIX) * VERTEX_NORMAL;
+    vs_tex0_uv = VERTEX_UV_0;
+    gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+  }`
+
+	// DistortionFragShader330 is the GLSL fragment shader for drawing
+	// distortion emitters. The red and green channels of the scrolling
+	// MATERIAL_TEX_0 noise texture are turned into screen space offsets
+	// that fade out towards the silhouette of the emitter.
+	DistortionFragShader330 = `#version 330
+  uniform sampler2D MATERIAL_TEX_0;
+  uniform float DISTORTION_STRENGTH;
+  uniform float DISTORTION_TIME;
+  uniform vec2 DISTORTION_SCROLL;
+
+  in vec3 vs_normal_view;
+  in vec2 vs_tex0_uv;
+
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec2 uv = vs_tex0_uv + DISTORTION_SCROLL * DISTORTION_TIME;
+    vec2 offset = texture(MATERIAL_TEX_0, uv).rg * 2.0 - 1.0;
+    float fade = abs(normalize(vs_normal_view).z);
+    frag_color = vec4(offset * DISTORTION_STRENGTH * fade, 0.0, 1.0);
+  }`
+
+	// DistortionCompositeFragShader330 is the GLSL fragment shader that
+	// samples the scene through the offset buffer. It uses the fog pass
+	// vertex shader to draw a full screen quad.
+	DistortionCompositeFragShader330 = `#version 330
+  uniform sampler2D DISTORTION_SCENE_TEX;
+  uniform sampler2D DISTORTION_OFFSET_TEX;
+
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec2 offset = texture(DISTORTION_OFFSET_TEX, vs_uv).rg;
+    frag_color = texture(DISTORTION_SCENE_TEX, clamp(vs_uv + offset, vec2(0.0), vec2(1.0)));
+  }`
+)
+
+// DistortionPass perturbs the final image for effects like heat haze,
+// shockwaves and underwater views. Emitters are drawn between Begin and End
+// into an offset buffer, accumulating screen space offsets, and Apply then
+// draws the scene texture sampled through those offsets.
+//
+// The offset buffer has no depth so emitters are not hidden by the scene.
+type DistortionPass struct {
+	// Time advances the scrolling of the emitters' noise textures; usually
+	// set to the clock time every frame.
+	Time float32
+
+	// Scroll is the speed, in texture coordinates per second, that the
+	// noise texture scrolls across the emitters.
+	Scroll mgl.Vec2
+
+	gfx       graphics.GraphicsProvider
+	fbo       graphics.Buffer
+	offsetTex graphics.Texture
+
+	emitterShader   *fizzle.RenderShader
+	compositeShader *fizzle.RenderShader
+	quad            *fizzle.Renderable
+
+	// strength and sceneTex are the values being drawn for the uniform binder
+	strength float32
+	sceneTex graphics.Texture
+}
+
+// NewDistortionPass compiles the distortion shaders and creates the offset
+// buffer for a framebuffer of the given size.
+func NewDistortionPass(gfx graphics.GraphicsProvider, width, height int32) (*DistortionPass, error) {
+	emitterShader, err := fizzle.LoadShaderProgram(DistortionVertShader330, DistortionFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+	compositeShader, err := fizzle.LoadShaderProgram(FogPassVertShader330, DistortionCompositeFragShader330, nil)
+	if err != nil {
+		emitterShader.Destroy()
+		return nil, err
+	}
+
+	dp := new(DistortionPass)
+	dp.gfx = gfx
+	dp.Scroll = mgl.Vec2{0.0, 0.5}
+	dp.emitterShader = emitterShader
+	dp.compositeShader = compositeShader
+	dp.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+
+	err = dp.Resize(width, height)
+	if err != nil {
+		dp.Destroy()
+		return nil, err
+	}
+	return dp, nil
+}
+
+// Destroy releases the shaders, quad and offset buffer.
+func (dp *DistortionPass) Destroy() {
+	dp.destroyBuffer()
+	dp.emitterShader.Destroy()
+	dp.compositeShader.Destroy()
+	dp.quad.Destroy()
+}
+
+// destroyBuffer releases the offset buffer and its texture.
+func (dp *DistortionPass) destroyBuffer() {
+	if dp.fbo != 0 {
+		dp.gfx.DeleteFramebuffer(dp.fbo)
+		dp.fbo = 0
+	}
+	if dp.offsetTex != 0 {
+		dp.gfx.DeleteTexture(dp.offsetTex)
+		dp.offsetTex = 0
+	}
+}
+
+// Resize recreates the offset buffer for a new framebuffer size.
+func (dp *DistortionPass) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid distortion pass size %dx%d", width, height)
+	}
+	dp.destroyBuffer()
+
+	gfx := dp.gfx
+	dp.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, dp.fbo)
+
+	dp.offsetTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, dp.offsetTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RG16F, width, height, 0, graphics.RG, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, dp.offsetTex, 0)
+
+	err := renderer.CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "distortion pass")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	return err
+}
+
+// GetOffsetTexture returns the texture holding the accumulated offsets.
+func (dp *DistortionPass) GetOffsetTexture() graphics.Texture {
+	return dp.offsetTex
+}
+
+// Begin binds and clears the offset buffer and sets up additive blending so
+// that overlapping emitters add up. The clear color is left at zero.
+func (dp *DistortionPass) Begin() {
+	gfx := dp.gfx
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, dp.fbo)
+	gfx.ClearColor(0.0, 0.0, 0.0, 0.0)
+	gfx.Clear(graphics.COLOR_BUFFER_BIT)
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendFunc(graphics.ONE, graphics.ONE)
+}
+
+// End restores the state changed by Begin and binds the default framebuffer.
+func (dp *DistortionPass) End() {
+	gfx := dp.gfx
+	gfx.Disable(graphics.BLEND)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+}
+
+// DrawEmitter draws the Renderable into the offset buffer. Its first
+// material texture is used as the noise for the offsets, which are scaled
+// by strength in texture coordinates.
+func (dp *DistortionPass) DrawEmitter(fr *ForwardRenderer, r *fizzle.Renderable, strength float32,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	dp.strength = strength
+	fr.DrawRenderableWithShader(r, dp.emitterShader, dp.bindEmitterUniforms, perspective, view, camera)
+}
+
+// DrawScreen distorts the whole screen with the noise texture, such as for
+// an underwater view.
+func (dp *DistortionPass) DrawScreen(fr *ForwardRenderer, noise graphics.Texture, strength float32) {
+	dp.quad.Core.Tex0 = noise
+	dp.strength = strength
+	ident := mgl.Ident4()
+	fr.DrawRenderableWithShader(dp.quad, dp.emitterShader, dp.bindEmitterUniforms, ident, ident, nil)
+}
+
+// Apply draws sceneTex, which must not be attached to the bound framebuffer,
+// over the whole viewport sampled through the accumulated offsets.
+func (dp *DistortionPass) Apply(fr *ForwardRenderer, sceneTex graphics.Texture) {
+	gfx := dp.gfx
+	dp.sceneTex = sceneTex
+	ident := mgl.Ident4()
+
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	fr.DrawRenderableWithShader(dp.quad, dp.compositeShader, dp.bindCompositeUniforms, ident, ident, nil)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// bindEmitterUniforms sets the distortion uniforms of the emitter shader.
+func (dp *DistortionPass) bindEmitterUniforms(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := rend.GetGraphics()
+	if loc := shader.GetUniformLocation("DISTORTION_STRENGTH"); loc >= 0 {
+		gfx.Uniform1f(loc, dp.strength)
+	}
+	if loc := shader.GetUniformLocation("DISTORTION_TIME"); loc >= 0 {
+		gfx.Uniform1f(loc, dp.Time)
+	}
+	if loc := shader.GetUniformLocation("DISTORTION_SCROLL"); loc >= 0 {
+		gfx.Uniform2f(loc, dp.Scroll[0], dp.Scroll[1])
+	}
+}
+
+// bindCompositeUniforms binds the scene and offset textures for the composite shader.
+func (dp *DistortionPass) bindCompositeUniforms(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := rend.GetGraphics()
+	if loc := shader.GetUniformLocation("DISTORTION_SCENE_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, dp.sceneTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("DISTORTION_OFFSET_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, dp.offsetTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// FogMode selects how the fog amount grows with the distance from the camera.
+type FogMode int
+
+const (
+	// FogNone disables the distance fog; height fog can still be enabled.
+	FogNone FogMode = iota
+
+	// FogLinear fades linearly from no fog at Start to full fog at End.
+	FogLinear
+
+	// FogExp fades exponentially with distance scaled by Density.
+	FogExp
+
+	// FogExp2 fades with the square of the distance scaled by Density.
+	FogExp2
+)
+
+var (
+	// FogShaderFunctions330 is the GLSL for the ApplyFog function used by the
+	// built-in forward shaders and the fog pass. Custom shaders can include it
+	// after their declarations to get the same fog the renderer binds through
+	// the FOG_* uniforms.
+	FogShaderFunctions330 = `
+  uniform int FOG_MODE;
+  uniform vec4 FOG_COLOR;
+  uniform vec3 FOG_DISTANCE;
+  uniform vec3 FOG_HEIGHT;
+
+  vec4 ApplyFog(vec4 color, vec3 world_pos, vec3 eye_pos)
+  {
+    if (FOG_MODE == 0 && FOG_HEIGHT.z <= 0.0) {
+      return color;
+    }
+
+    vec3 ray = world_pos - eye_pos;
+    float dist = length(ray);
+
+    // visibility is the fraction of the original color that remains
+    float visibility = 1.0;
+    if (FOG_MODE == 1) {
+      visibility = clamp((FOG_DISTANCE.y - dist) / max(FOG_DISTANCE.y - FOG_DISTANCE.x, 0.0001), 0.0, 1.0);
+    } else if (FOG_MODE == 2) {
+      visibility = exp(-FOG_DISTANCE.z * dist);
+    } else if (FOG_MODE == 3) {
+      float d = FOG_DISTANCE.z * dist;
+      visibility = exp(-d * d);
+    }
+
+    // height fog integrates a density that falls off exponentially above
+    // the base height along the view ray
+    if (FOG_HEIGHT.z > 0.0) {
+      float falloff = max(FOG_HEIGHT.y, 0.0001);
+      float amount = FOG_HEIGHT.z * exp(-falloff * (eye_pos.y - FOG_HEIGHT.x)) * dist;
+      float rise = falloff * ray.y;
+      if (abs(rise) > 0.0001) {
+        amount *= (1.0 - exp(-rise)) / rise;
+      }
+      visibility *= exp(-amount);
+    }
+
+    return vec4(mix(FOG_COLOR.rgb, color.rgb, visibility), color.a);
+  }
+`
+
+	// FogPassVertShader330 is the GLSL vertex shader for the fog pass. It
+	// draws a full screen quad.
+	FogPassVertShader330 = `#version 330
+  in vec3 VERTEX_POSITION;
+  out vec2 vs_uv;
+
+  void main()
+  {
+    vs_uv = VERTEX_POSITION.xy * 0.5 + 0.5;
+    gl_Position = vec4(VERTEX_POSITION.xy, 0.0, 1.0);
+  }`
+
+	// FogPassFragShader330 is the GLSL fragment shader for the fog pass. It
+	// reconstructs the world position of every pixel from the depth texture
+	// and applies the fog to the color texture.
+	FogPassFragShader330 = `#version 330
+  uniform mat4 VP_MATRIX;
+  uniform vec3 CAMERA_WORLD_POSITION;
+  uniform sampler2D FOG_COLOR_TEX;
+  uniform sampler2D FOG_DEPTH_TEX;
+` + FogShaderFunctions330 + `
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    float depth = texture(FOG_DEPTH_TEX, vs_uv).r;
+    vec4 world = inverse(VP_MATRIX) * vec4(vs_uv * 2.0 - 1.0, depth * 2.0 - 1.0, 1.0);
+    frag_color = ApplyFog(texture(FOG_COLOR_TEX, vs_uv), world.xyz / world.w, CAMERA_WORLD_POSITION);
+  }`
+)
+
+// Fog holds the fog parameters the renderer binds to every shader that
+// declares the FOG_* uniforms.
+type Fog struct {
+	// Mode selects the distance fog falloff.
+	Mode FogMode
+
+	// Color is the color of the fog; the alpha is ignored.
+	Color mgl.Vec4
+
+	// Start and End are the distances for FogLinear.
+	Start float32
+	End   float32
+
+	// Density scales the distance for FogExp and FogExp2.
+	Density float32
+
+	// HeightDensity is the density of the height fog at HeightBase;
+	// 0 disables the height fog.
+	HeightDensity float32
+
+	// HeightBase is the world Y coordinate where the height fog has
+	// HeightDensity and HeightFalloff is how quickly it thins out above it.
+	HeightBase    float32
+	HeightFalloff float32
+}
+
+// IsEnabled returns true if either the distance or the height fog is enabled.
+func (f *Fog) IsEnabled() bool {
+	return f.Mode != FogNone || f.HeightDensity > 0.0
+}
+
+// bind sets the FOG_* uniforms of the shader. Shaders without FOG_MODE are skipped.
+func (f *Fog) bind(gfx graphics.GraphicsProvider, shader *fizzle.RenderShader) {
+	shaderFogMode := shader.GetUniformLocation("FOG_MODE")
+	if shaderFogMode < 0 {
+		return
+	}
+	gfx.Uniform1i(shaderFogMode, int32(f.Mode))
+
+	if loc := shader.GetUniformLocation("FOG_COLOR"); loc >= 0 {
+		gfx.Uniform4f(loc, f.Color[0], f.Color[1], f.Color[2], f.Color[3])
+	}
+	if loc := shader.GetUniformLocation("FOG_DISTANCE"); loc >= 0 {
+		gfx.Uniform3f(loc, f.Start, f.End, f.Density)
+	}
+	if loc := shader.GetUniformLocation("FOG_HEIGHT"); loc >= 0 {
+		gfx.Uniform3f(loc, f.HeightBase, f.HeightFalloff, f.HeightDensity)
+	}
+}
+
+// FogPass applies the renderer's fog as a post process using the scene's
+// depth texture. This is for scenes drawn with custom shaders that don't
+// apply the fog themselves.
+type FogPass struct {
+	shader *fizzle.RenderShader
+	quad   *fizzle.Renderable
+
+	colorTex graphics.Texture
+	depthTex graphics.Texture
+}
+
+// NewFogPass compiles the fog pass shader.
+func NewFogPass() (*FogPass, error) {
+	shader, err := fizzle.LoadShaderProgram(FogPassVertShader330, FogPassFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := new(FogPass)
+	fp.shader = shader
+	fp.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	return fp, nil
+}
+
+// Destroy releases the shader and quad used by the fog pass.
+func (fp *FogPass) Destroy() {
+	fp.shader.Destroy()
+	fp.quad.Destroy()
+}
+
+// Draw reads the scene from colorTex and depthTex, which must not be attached
+// to the bound framebuffer, and writes the fogged scene over the whole
+// viewport. The perspective, view and camera should be the ones the scene
+// was drawn with. Pixels at the far plane, like a cleared background, get the
+// fog for the far plane distance.
+func (fp *FogPass) Draw(fr *ForwardRenderer, colorTex, depthTex graphics.Texture, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	gfx := fr.GetGraphics()
+	fp.colorTex = colorTex
+	fp.depthTex = depthTex
+
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	fr.DrawRenderableWithShader(fp.quad, fp.shader, fp.bindUniforms, perspective, view, camera)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// bindUniforms binds the scene textures for the fog pass shader.
+func (fp *FogPass) bindUniforms(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := rend.GetGraphics()
+	if loc := shader.GetUniformLocation("FOG_COLOR_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, fp.colorTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("FOG_DEPTH_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, fp.depthTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+}
@@ -5,17 +5,28 @@ package forward
 
 import (
 	"fmt"
+	"image"
+	"math"
 	"time"
 
 	mgl "github.com/go-gl/mathgl/mgl32"
 	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/events"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/profiler"
 	renderer "github.com/tbogdala/fizzle/renderer"
+	"github.com/tbogdala/fizzle/window"
+	"github.com/tbogdala/groggy"
 )
 
 const (
-	// MaxForwardLights is the maximum amount of lights supported by this renderer.
+	// MaxForwardLights is the maximum amount of lights the shaders light a
+	// Renderable with in one draw. Use ForwardRenderer.Lights for more lights.
 	MaxForwardLights = 4
+
+	// maxSpotAngle is the largest outer cone half angle, in degrees, of a
+	// spot light; the shaders tell spot lights apart by a positive cosine.
+	maxSpotAngle = 89.0
 )
 
 var (
@@ -25,11 +36,78 @@ var (
 		0.0, 0.0, 0.5, 0.0,
 		0.5, 0.5, 0.5, 1.0,
 	}
+
+	// lightUniformNames caches the per-light uniform names so that binding the
+	// lights doesn't format new strings for every Renderable drawn.
+	lightUniformNames [MaxForwardLights]lightUniforms
 )
 
+// lightUniforms are the names of the shader uniforms for one light slot.
+type lightUniforms struct {
+	position          string
+	direction         string
+	diffuse           string
+	diffuseIntensity  string
+	specularIntensity string
+	ambientIntensity  string
+	attenuation       string
+	falloff           string
+	spotCutoff        string
+	areaRight         string
+	areaUp            string
+	areaTexture       string
+	cookie            string
+	cookieMatrix      string
+	cookieEnabled     string
+	profile           string
+	profileEnabled    string
+	shadowMap         string
+	shadowMatrix      string
+	shadowRect        string
+	filteredMap       string
+	filterMode        string
+	filterParams      string
+	shadowCubeMap     string
+	shadowCubeRange   string
+}
+
+func init() {
+	for i := range lightUniformNames {
+		lightUniformNames[i] = lightUniforms{
+			position:          fmt.Sprintf("LIGHT_POSITION[%d]", i),
+			direction:         fmt.Sprintf("LIGHT_DIRECTION[%d]", i),
+			diffuse:           fmt.Sprintf("LIGHT_DIFFUSE[%d]", i),
+			diffuseIntensity:  fmt.Sprintf("LIGHT_DIFFUSE_INTENSITY[%d]", i),
+			specularIntensity: fmt.Sprintf("LIGHT_SPECULAR_INTENSITY[%d]", i),
+			ambientIntensity:  fmt.Sprintf("LIGHT_AMBIENT_INTENSITY[%d]", i),
+			attenuation:       fmt.Sprintf("LIGHT_ATTENUATION[%d]", i),
+			falloff:           fmt.Sprintf("LIGHT_FALLOFF[%d]", i),
+			spotCutoff:        fmt.Sprintf("LIGHT_SPOT_CUTOFF[%d]", i),
+			areaRight:         fmt.Sprintf("LIGHT_AREA_RIGHT[%d]", i),
+			areaUp:            fmt.Sprintf("LIGHT_AREA_UP[%d]", i),
+			areaTexture:       fmt.Sprintf("LIGHT_AREA_TEXTURE[%d]", i),
+			cookie:            fmt.Sprintf("LIGHT_COOKIE[%d]", i),
+			cookieMatrix:      fmt.Sprintf("LIGHT_COOKIE_MATRIX[%d]", i),
+			cookieEnabled:     fmt.Sprintf("LIGHT_COOKIE_ENABLED[%d]", i),
+			profile:           fmt.Sprintf("LIGHT_PROFILE[%d]", i),
+			profileEnabled:    fmt.Sprintf("LIGHT_PROFILE_ENABLED[%d]", i),
+			shadowMap:         fmt.Sprintf("SHADOW_MAPS[%d]", i),
+			shadowMatrix:      fmt.Sprintf("SHADOW_MATRIX[%d]", i),
+			shadowRect:        fmt.Sprintf("SHADOW_RECT[%d]", i),
+			filteredMap:       fmt.Sprintf("SHADOW_FILTERED_MAPS[%d]", i),
+			filterMode:        fmt.Sprintf("SHADOW_FILTER[%d]", i),
+			filterParams:      fmt.Sprintf("SHADOW_FILTER_PARAMS[%d]", i),
+			shadowCubeMap:     fmt.Sprintf("SHADOW_CUBE_MAPS[%d]", i),
+			shadowCubeRange:   fmt.Sprintf("SHADOW_CUBE_RANGE[%d]", i),
+		}
+	}
+}
+
 // ShadowMap contains the id of the shadow map texture as well as the associated
 // vectors and matrixes needed to render the shadow map for the owning light.
-// NOTE: only point lights via a given direction are supported at present.
+// Spot lights project the shadow map through a perspective frustum matching
+// their outer cone, directional lights use an orthographic projection and
+// other lights use a fixed frustum along Direction.
 type ShadowMap struct {
 	// Texture is the texture for the shadowmap
 	Texture graphics.Texture
@@ -52,6 +130,23 @@ type ShadowMap struct {
 	// Projection is the projection transformation matrix for the shadowmap
 	Projection mgl.Mat4
 
+	// ProjectionParams are the parameters Projection was built from. Use
+	// SetProjection to change them.
+	ProjectionParams ShadowProjection
+
+	// Orthographic is true for the shadow maps of directional lights, whose
+	// view and orthographic projection are fitted around the box between
+	// BoundsMin and BoundsMax along Direction by UpdateShadowMapData().
+	Orthographic bool
+
+	// BoundsMin is the minimum corner of the world space box that an
+	// orthographic shadow map covers. See FitToFrustum.
+	BoundsMin mgl.Vec3
+
+	// BoundsMax is the maximum corner of the world space box that an
+	// orthographic shadow map covers.
+	BoundsMax mgl.Vec3
+
 	// View is the view transformation matrix for the shadowmap
 	// Updated with UpdateShadowMapData().
 	View mgl.Mat4
@@ -64,6 +159,58 @@ type ShadowMap struct {
 	// Updated with UpdateShadowMapData().
 	BiasedMatrix mgl.Mat4
 
+	// Variance is true if Texture holds the depth and squared depth for
+	// variance shadow mapping instead of a depth texture. Use SetVariance
+	// to change it.
+	Variance bool
+
+	// Exponential is true if Texture holds the exponential of the depth for
+	// exponential shadow mapping instead of a depth texture. Use
+	// SetExponential to change it.
+	Exponential bool
+
+	// ExponentialConstant scales the depth before it's raised in an
+	// exponential shadow map. Larger values darken the shadows close to
+	// their occluders but show more artifacts; it's limited to 85.
+	ExponentialConstant float32
+
+	// FilterBlur is the radius, in texels, of the blur applied to a
+	// variance or exponential shadow map after it's rendered. Larger radii
+	// give softer shadows; 0 disables the blur.
+	FilterBlur float32
+
+	// MinVariance is the smallest variance used in the Chebyshev test of a
+	// variance shadow map, which hides acne on surfaces facing the light.
+	MinVariance float32
+
+	// LightBleedReduction, from 0 to 1, darkens the faint light that leaks
+	// into variance shadows where several occluders overlap at the cost of
+	// sharper shadow edges.
+	LightBleedReduction float32
+
+	// depthBuffer is the depth buffer used while rendering a variance or
+	// exponential shadow map
+	depthBuffer graphics.Buffer
+
+	// blurTexture holds the horizontally blurred texels of a variance or
+	// exponential shadow map
+	blurTexture graphics.Texture
+
+	// atlas is the shadow atlas holding the shadow map, if any, and
+	// atlasTile is the index of its tile
+	atlas     *ShadowAtlas
+	atlasTile int
+
+	// samplerMatrix is BiasedMatrix mapped into the shadow map's atlas tile
+	// and samplerRect the texture space rectangle of the tile; for shadow
+	// maps with their own texture they're BiasedMatrix and the whole texture
+	samplerMatrix mgl.Mat4
+	samplerRect   mgl.Vec4
+
+	// framebufferChecked is true once the shadow framebuffer was found to
+	// be complete with the shadow map's textures attached
+	framebufferChecked bool
+
 	// owner is the owning renderer
 	owner *ForwardRenderer
 }
@@ -71,8 +218,21 @@ type ShadowMap struct {
 // Destroy deallocates any data being held onto by the ShadowMap that is not
 // controlled by the Go GC.
 func (shady *ShadowMap) Destroy() {
+	// the texture of a shadow map in an atlas belongs to the atlas
+	if shady.atlas != nil {
+		shady.releaseAtlasTile()
+		return
+	}
+
 	// delete the texture associated with the shadow map
-	shady.owner.GetGraphics().DeleteTexture(shady.Texture)
+	gfx := shady.owner.GetGraphics()
+	gfx.DeleteTexture(shady.Texture)
+	if shady.blurTexture != 0 {
+		gfx.DeleteTexture(shady.blurTexture)
+	}
+	if shady.depthBuffer != 0 {
+		gfx.DeleteRenderbuffer(shady.depthBuffer)
+	}
 }
 
 // Light is a basic light structure used in the forward renderer.
@@ -92,24 +252,263 @@ type Light struct {
 	// SpecularIntensity is how strong the specular highlight should be
 	SpecularIntensity float32
 
-	// AmbientIntensity is how strong the ambient light should be
+	// AmbientIntensity is how strong the ambient light should be; it's
+	// ignored by shaders when the renderer's hemisphere Ambient is enabled
 	AmbientIntensity float32
 
-	// Attenuation is the coefficient for the attenuation factor
+	// Attenuation is the quadratic coefficient for the attenuation factor
+	// of point and spot lights: 1 / (constant + linear*d + quadratic*d*d).
 	Attenuation float32
 
+	// ConstantAttenuation is the constant term of the attenuation factor.
+	// Defaults to 1.0 with NewLight.
+	ConstantAttenuation float32
+
+	// LinearAttenuation is the linear coefficient of the attenuation factor.
+	LinearAttenuation float32
+
+	// Range is the distance at which a point or spot light's contribution
+	// smoothly reaches zero so that it can be culled for anything farther
+	// away. Zero means the light has no range limit.
+	Range float32
+
+	// SpotInnerAngle is the half angle, in degrees, of the cone around
+	// Direction that a spot light fully lights.
+	SpotInnerAngle float32
+
+	// SpotOuterAngle is the half angle, in degrees, of the cone around
+	// Direction past which a spot light has no effect. The light fades
+	// smoothly between the inner and outer cones. The light is a spot
+	// light when this is greater than zero; see SetSpot.
+	SpotOuterAngle float32
+
+	// AreaSize is the width and height of a rectangular area light centered
+	// on Position that emits light from the side facing Direction. The light
+	// is an area light when both are greater than zero; see SetArea.
+	AreaSize mgl.Vec2
+
+	// AreaRight is the direction of the width of an area light. It doesn't
+	// need to be perpendicular to Direction.
+	AreaRight mgl.Vec3
+
+	// AreaTexture, if set, colors the surface of an area light, like a
+	// window or a screen. It should have mipmaps since the diffuse light
+	// samples a blurred level of it.
+	AreaTexture graphics.Texture
+
+	// Cookie, if set, is a texture projected from a spot or directional
+	// light that masks and tints its light, like a gobo. See CookieMatrix.
+	Cookie graphics.Texture
+
+	// CookieSize is the width in world units that the Cookie of a
+	// directional light without a shadow map covers before it repeats.
+	CookieSize float32
+
+	// Profile, if set, is the texture of an IESProfile that shapes the light
+	// of a point or spot light by the measured angular distribution of a
+	// real fixture. The profile's nadir points along Direction, or straight
+	// down for point lights without one, and its horizontal angles start
+	// from the axis perpendicular to it that's closest to the world's X axis
+	// when the nadir is vertical, or to the world's Y axis otherwise.
+	Profile graphics.Texture
+
+	// ScatteringDensity is how thick the air the light shines through is
+	// for the VolumetricPass, which draws light shafts for lights with a
+	// shadow map and a density greater than zero.
+	ScatteringDensity float32
+
+	// ScatteringAnisotropy controls which way the air scatters the light,
+	// from -1 to 1. Positive values scatter it forward so that the shafts
+	// are brightest when looking towards the light; zero scatters evenly.
+	ScatteringAnisotropy float32
+
 	// ShadowMap is the texture, and other data, used to render
 	// shadows casted by the light. This member is nil when
 	// the light does not cast shadows.
 	ShadowMap *ShadowMap
 
+	// ShadowCubeMap is the depth cubemap used to cast shadows in every
+	// direction around a point light. This member is nil when the light
+	// does not cast omnidirectional shadows.
+	ShadowCubeMap *ShadowCubeMap
+
 	// owner is the owning renderer
 	owner *ForwardRenderer
+
+	// cookieMatrix is the storage for the cookie projection being bound
+	cookieMatrix mgl.Mat4
+}
+
+// SetSpot makes the light a spot light at Position shining along Direction
+// that is fully lit within the inner cone and fades out to the outer cone.
+// The angles are the half angles of the cones in degrees; the outer angle is
+// clamped to be less than 90 degrees and the inner angle to the outer angle.
+func (l *Light) SetSpot(innerAngle, outerAngle float32) {
+	if outerAngle > maxSpotAngle {
+		outerAngle = maxSpotAngle
+	}
+	if innerAngle > outerAngle {
+		innerAngle = outerAngle
+	}
+	if innerAngle < 0.0 {
+		innerAngle = 0.0
+	}
+	l.SpotInnerAngle = innerAngle
+	l.SpotOuterAngle = outerAngle
+}
+
+// InRange returns true if any part of the sphere is within the light's Range.
+// Directional lights and lights without a Range are always in range.
+func (l *Light) InRange(center mgl.Vec3, radius float32) bool {
+	if l.Range <= 0.0 || (l.Direction.Len() > 0.0 && !l.IsSpot() && !l.IsArea()) {
+		return true
+	}
+	return center.Sub(l.Position).Len()-radius < l.Range
+}
+
+// falloff returns the constant, linear and quadratic attenuation terms and
+// the range for the LIGHT_FALLOFF uniform. A light without any terms gets a
+// constant term of 1 so that it isn't infinitely bright.
+func (l *Light) falloff() (float32, float32, float32, float32) {
+	constant := l.ConstantAttenuation
+	if constant == 0.0 && l.LinearAttenuation == 0.0 && l.Attenuation == 0.0 {
+		constant = 1.0
+	}
+	return constant, l.LinearAttenuation, l.Attenuation, l.Range
+}
+
+// SetArea makes the light a rectangular area light of the given size
+// centered at Position and facing along Direction, with its width running
+// along right. Area lights are supported by the diffuse family of shaders.
+func (l *Light) SetArea(width, height float32, right mgl.Vec3) {
+	l.AreaSize = mgl.Vec2{width, height}
+	l.AreaRight = right
+}
+
+// IsArea returns true if the light is a rectangular area light.
+func (l *Light) IsArea() bool {
+	return l.AreaSize[0] > 0.0 && l.AreaSize[1] > 0.0
+}
+
+// areaAxes returns the vectors from the center of an area light to the
+// middle of its right and top edges for the LIGHT_AREA_* uniforms, or zeros
+// for other lights. Their cross product points along Direction.
+func (l *Light) areaAxes() (mgl.Vec3, mgl.Vec3) {
+	if !l.IsArea() || l.Direction.Len() == 0.0 {
+		return mgl.Vec3{}, mgl.Vec3{}
+	}
+	normal := l.Direction.Normalize()
+	right := l.AreaRight.Sub(normal.Mul(l.AreaRight.Dot(normal)))
+	if right.Len() < 0.0001 {
+		right = normal.Cross(mgl.Vec3{0.0, 1.0, 0.0})
+		if right.Len() < 0.0001 {
+			right = mgl.Vec3{1.0, 0.0, 0.0}
+		}
+	}
+	right = right.Normalize()
+	up := normal.Cross(right)
+	return right.Mul(l.AreaSize[0] * 0.5), up.Mul(l.AreaSize[1] * 0.5)
+}
+
+// IsSpot returns true if the light is a spot light.
+func (l *Light) IsSpot() bool {
+	return l.SpotOuterAngle > 0.0
+}
+
+// spotCutoff returns the cosines of the inner and outer cone angles of a spot
+// light for the LIGHT_SPOT_CUTOFF uniform or zeros for other lights.
+func (l *Light) spotCutoff() (float32, float32) {
+	if !l.IsSpot() {
+		return 0.0, 0.0
+	}
+	outer := l.SpotOuterAngle
+	if outer > maxSpotAngle {
+		outer = maxSpotAngle
+	}
+	inner := l.SpotInnerAngle
+	if inner > outer {
+		inner = outer
+	}
+	innerCos := float32(math.Cos(float64(mgl.DegToRad(inner))))
+	outerCos := float32(math.Cos(float64(mgl.DegToRad(outer))))
+
+	// smoothstep is undefined for equal edges so keep a sliver of falloff
+	if innerCos-outerCos < 0.0001 {
+		innerCos = outerCos + 0.0001
+	}
+	return innerCos, outerCos
+}
+
+// bindAreaLight binds the axes and emitter texture of an area light, or
+// zeros and no texture for other lights.
+func (fr *ForwardRenderer) bindAreaLight(light *Light, names *lightUniforms, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := fr.gfx
+	right, up := light.areaAxes()
+	if loc := shader.GetUniformLocation(names.areaRight); loc >= 0 {
+		gfx.Uniform3f(loc, right[0], right[1], right[2])
+	}
+	if loc := shader.GetUniformLocation(names.areaUp); loc >= 0 {
+		textured := float32(0.0)
+		if light.AreaTexture != 0 {
+			textured = 1.0
+		}
+		gfx.Uniform4f(loc, up[0], up[1], up[2], textured)
+	}
+	if loc := shader.GetUniformLocation(names.areaTexture); loc >= 0 {
+		// bind a 0 for lights without a texture so that every sampler is bound
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, light.AreaTexture)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
 }
 
 // CreateShadowMap allocates a texture and sets up the projections to draw
-// the shadows.
-func (l *Light) CreateShadowMap(textureSize int32, near float32, far float32, dir mgl.Vec3) {
+// the shadows through DefaultShadowProjection. An error is returned if the
+// parameters are invalid or the texture couldn't be created, in which case
+// the light has no shadow map. Spot lights ignore dir and cast their shadows
+// along Direction through their outer cone.
+func (l *Light) CreateShadowMap(textureSize int32, near float32, far float32, dir mgl.Vec3) error {
+	return l.CreateShadowMapWithProjection(textureSize, DefaultShadowProjection(), near, far, dir)
+}
+
+// CreateShadowMapWithProjection is like CreateShadowMap but renders the
+// shadows through the projection, which can be changed later with
+// ShadowMap.SetProjection.
+func (l *Light) CreateShadowMapWithProjection(textureSize int32, proj ShadowProjection, near float32, far float32, dir mgl.Vec3) error {
+	if l.owner == nil {
+		return fmt.Errorf("the light was not created with ForwardRenderer.NewLight")
+	}
+	if textureSize <= 0 {
+		return fmt.Errorf("invalid shadow map texture size %d", textureSize)
+	}
+	if err := proj.validate(); err != nil {
+		return err
+	}
+	if (!proj.Orthographic && near <= 0.0) || far <= near {
+		return fmt.Errorf("invalid shadow map depth range %f to %f", near, far)
+	}
+	if l.IsSpot() {
+		dir = l.Direction
+	}
+	if dir.Len() == 0.0 {
+		return fmt.Errorf("the shadow map direction must not be zero")
+	}
+
+	if err := l.allocateShadowMap(textureSize); err != nil {
+		return err
+	}
+
+	// setup the projection from the light's position; use
+	// CreateDirectionalShadowMap for sun-style lights
+	l.ShadowMap.Direction = dir
+	return l.ShadowMap.SetProjection(proj, near, far)
+}
+
+// allocateShadowMap replaces the light's shadow map with a new one that has
+// a depth texture of the given size. An error is returned if the texture
+// couldn't be created, in which case the light has no shadow map.
+func (l *Light) allocateShadowMap(textureSize int32) error {
 	// if there was already a shadow map, destroy it
 	if l.ShadowMap != nil {
 		l.ShadowMap.Destroy()
@@ -117,24 +516,30 @@ func (l *Light) CreateShadowMap(textureSize int32, near float32, far float32, di
 
 	// allocate a new structure
 	l.ShadowMap = l.owner.NewShadowMap()
-
-	// setup the projection
-	l.ShadowMap.Near = near
-	l.ShadowMap.Far = far
-
-	// Frustum is okay for directional lights
-	// FIXME: this will likely need to be customizable
-	factor := float32(0.5)
-	l.ShadowMap.Projection = mgl.Frustum(-factor, factor, -factor, factor, near, far)
-
 	l.ShadowMap.TextureSize = textureSize
-	l.ShadowMap.Direction = dir
 
 	// create the shadow map texture
-	gfx := l.owner.GetGraphics()
-	l.ShadowMap.Texture = gfx.GenTexture()
+	if err := l.ShadowMap.createDepthTexture(); err != nil {
+		l.ShadowMap.Destroy()
+		l.ShadowMap = nil
+		return err
+	}
+	return nil
+}
+
+// createDepthTexture creates the depth texture of a regular shadow map.
+func (shady *ShadowMap) createDepthTexture() error {
+	var err error
+	shady.Texture, err = createShadowDepthTexture(shady.owner.GetGraphics(), shady.TextureSize)
+	return err
+}
+
+// createShadowDepthTexture creates a depth texture of the size for shadow
+// maps to render into and be sampled from with a depth comparison.
+func createShadowDepthTexture(gfx graphics.GraphicsProvider, textureSize int32) (graphics.Texture, error) {
+	tex := gfx.GenTexture()
 	gfx.ActiveTexture(graphics.TEXTURE0)
-	gfx.BindTexture(graphics.TEXTURE_2D, l.ShadowMap.Texture)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
 	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.DEPTH_COMPONENT32, textureSize, textureSize, 0, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT, nil, 0)
 	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
 	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
@@ -149,54 +554,173 @@ func (l *Light) CreateShadowMap(textureSize int32, near float32, far float32, di
 
 	// a safety unbind
 	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	err := fizzle.CheckGraphicsError(gfx, "creating the shadow map texture")
+	if err == nil && tex == 0 {
+		err = fmt.Errorf("failed to generate the shadow map texture")
+	}
+	return tex, err
 }
 
 // UpdateShadowMapData updates a shadow maps internal structures based on data
 // from the light.
 func (l *Light) UpdateShadowMapData() {
+	if l.ShadowCubeMap != nil {
+		l.ShadowCubeMap.update(l.Position)
+	}
+
 	// don't do nothin' on no shadowmap havin' lights
 	if l.ShadowMap == nil {
 		return
 	}
 
+	// spot lights follow their cone so that a moving flashlight keeps
+	// casting shadows where it points
+	up := l.ShadowMap.Up
+	if l.IsSpot() && l.Direction.Len() > 0.0 {
+		l.ShadowMap.Direction = l.Direction
+		_, outerCos := l.spotCutoff()
+		fovy := 2.0 * float32(math.Acos(float64(outerCos)))
+		aspect := l.ShadowMap.ProjectionParams.aspect()
+		l.ShadowMap.Projection = mgl.Perspective(fovy, aspect, l.ShadowMap.Near, l.ShadowMap.Far)
+
+		// pick another up vector when pointing straight along it
+		dir := l.Direction.Normalize()
+		if abs := dir.Dot(up.Normalize()); abs > 0.999 || abs < -0.999 {
+			up = mgl.Vec3{0.0, 0.0, 1.0}
+		}
+	}
+
 	// construct a dummy target along the direction vector
 	target := l.Position.Add(l.ShadowMap.Direction)
 
 	// update the view matrix
-	l.ShadowMap.View = mgl.LookAtV(l.Position, target, l.ShadowMap.Up)
+	l.ShadowMap.View = mgl.LookAtV(l.Position, target, up)
+	if l.ShadowMap.Orthographic {
+		if l.Direction.Len() > 0.0 {
+			l.ShadowMap.Direction = l.Direction
+		}
+		l.ShadowMap.fitOrthographic()
+	}
 
 	// update the view projection matrix
 	l.ShadowMap.ViewProjMatrix = l.ShadowMap.Projection.Mul4(l.ShadowMap.View)
 
 	// update the shadow biased matrix
 	l.ShadowMap.BiasedMatrix = shadowBiasMat.Mul4(l.ShadowMap.ViewProjMatrix)
+	l.ShadowMap.updateSamplerMatrix()
 }
 
 // ForwardRenderer is a forward-rendering style renderer, meaning that when
 // it draws the geometry it lights it at the same time and the output goes
 // to the output framebuffer, which is the only framebuffer.
 type ForwardRenderer struct {
-
-	// GeometryPassFn is the function called to render geometry to the
-	// framebuffers in the deferred renderer.
-	//GeometryPassFn DeferredGeometryPass
-
-	// BeforeDrawFn is the function called by the renderer before
-	// endtering the geometry draw function.
-	//BeforeDrawFn DeferredBeforeDraw
-
-	// AfterDrawFn is the function called by the renderer after
-	// endtering the geometry draw function.
-	//AfterDrawFn DeferredAfterDraw
-
-	// OnScreenSizeChanged is the function called by the renderer after
-	// a screen size change is detected.
-	OnScreenSizeChanged func(fr *ForwardRenderer, width int32, height int32)
-
 	// ActiveLights are the current lights that should be used while
 	// drawing Renderables.
 	ActiveLights [MaxForwardLights]*Light
 
+	// Lights, when not empty, is used instead of ActiveLights so that any
+	// number of lights can be active. Lit Renderables are drawn once for
+	// every MaxForwardLights lights with each pass after the first blended
+	// additively. Shadow casting lights should come first in every group
+	// of MaxForwardLights, as with ActiveLights.
+	Lights []*Light
+
+	// LightManager, if set, picks the lights for each Renderable drawn with
+	// DrawRenderable or DrawRenderableWithShader out of its own lights,
+	// taking the place of ActiveLights and Lights for those draws.
+	LightManager *LightManager
+
+	// Clusters, if set, holds many small point lights binned into screen
+	// space clusters for shaders that support clustered lighting.
+	Clusters *LightClusters
+
+	// ActiveProjectors are the current projectors that get projected onto
+	// Renderables drawn with shaders that receive them.
+	ActiveProjectors [MaxForwardProjectors]*Projector
+
+	// Fog is bound to every shader drawn that declares the FOG_* uniforms,
+	// which the built-in forward shaders do. Use a FogPass to apply it to
+	// scenes drawn with shaders that don't.
+	Fog Fog
+
+	// Ambient, when enabled, is the hemisphere ambient light bound to every
+	// shader drawn that declares the AMBIENT_* uniforms in place of the
+	// lights' AmbientIntensity.
+	Ambient HemisphereAmbient
+
+	// LightProbes, if set, supplies the ambient light of each Renderable
+	// drawn with DrawRenderable or DrawRenderableWithShader from the probes
+	// closest to it, for shaders that declare the LIGHT_PROBE_* uniforms.
+	LightProbes *LightProbes
+
+	// ShadowDistance is the distance from the camera past which nothing is
+	// shadowed, for shaders that declare SHADOW_FADE. Shadows fade out over
+	// the ShadowFadeRange before it so that distant geometry doesn't pop in
+	// and out of shadow. A ShadowDistance of 0 shadows everything.
+	ShadowDistance float32
+
+	// ShadowFadeRange is the width of the band before ShadowDistance over
+	// which shadows fade out; 0 cuts them off at ShadowDistance.
+	ShadowFadeRange float32
+
+	// Points is the size and shape of the points drawn with DrawPoints.
+	Points PointStyle
+
+	// FrameLimiter, if set, caps the frame rate at the end of every frame.
+	FrameLimiter *renderer.FrameLimiter
+
+	// ManualSwap, if set, leaves swapping the buffers of the window set with
+	// SetWindow to the application, which keeps the resize tracking and
+	// swap interval of the window without EndRenderFrame presenting it.
+	ManualSwap bool
+
+	// Profiler, if set, records the time spent in the renderer's passes;
+	// frames are started in BeginFrame and finished in EndRenderFrame.
+	Profiler *profiler.Profiler
+
+	// DynamicResolution, if set, is resized along with the renderer. It
+	// measures the GPU time of the scene drawn between its Begin and End.
+	DynamicResolution *renderer.DynamicResolution
+
+	// PostProcess, if set, is resized along with the renderer; the scene
+	// drawn between BeginScene and EndScene goes through its effects.
+	PostProcess *renderer.PostProcessStack
+
+	// DepthPrepass, if true, makes DrawOpaqueScene draw the depth of the
+	// scene first so that the lit pass only shades the visible fragments.
+	DepthPrepass bool
+
+	// FrustumCulling, if true, skips the Renderables drawn with
+	// DrawRenderable or queued for FlushQueue whose bounds are outside of
+	// the view frustum of the matrices they're drawn with. The bounds are
+	// the nodes' CullBounds or BoundingRect; nodes without bounds are
+	// always drawn. DrawRenderableWithShader isn't culled since the
+	// renderer's own full screen passes are drawn with it.
+	FrustumCulling bool
+
+	// window is the surface the renderer presents frames to; optional
+	window window.Window
+
+	// Textures, if set, is told about the textures of every material drawn
+	// so that it evicts the ones that aren't being drawn and reloads the
+	// evicted ones that are at the start of the next frame.
+	Textures *fizzle.TextureManager
+
+	// VertexArrays, if set, provides the VAOs used when the renderer draws
+	// into a window whose context shares resources with the context the
+	// Renderables were created in.
+	VertexArrays *renderer.VertexArrayCache
+
+	// destroyedSub releases the VAOs of destroyed Renderables from VertexArrays
+	destroyedSub events.Subscription
+
+	// swapIntervalFn applies the swap interval to the window's context
+	swapIntervalFn renderer.SwapIntervalFunc
+
+	// swapInterval is the last swap interval set on the renderer
+	swapInterval renderer.SwapInterval
+
 	width  int32
 	height int32
 
@@ -209,20 +733,187 @@ type ForwardRenderer struct {
 	// currentShadowPassLight is the light currently enabled for shadow mapping
 	currentShadowPassLight *Light
 
+	// currentShadowPassVP is the view projection matrix of the shadow map,
+	// or shadow cubemap face, currently being rendered
+	currentShadowPassVP *mgl.Mat4
+
+	// shadowFilteredTarget is true while the shadow framebuffer has the
+	// color and depth buffers of a variance or exponential shadow map attached
+	shadowFilteredTarget bool
+
+	// shadowDepthAttachment is the texture attached as the depth buffer of
+	// the shadow framebuffer by the last EnableShadowMappingLight
+	shadowDepthAttachment graphics.Texture
+
+	// shadowBlur blurs variance and exponential shadow maps; created when
+	// first needed
+	shadowBlur *shadowMapBlur
+
+	// cutoutShadowShader draws alpha cutout materials into shadow maps;
+	// created when first needed unless that failed before
+	cutoutShadowShader *fizzle.RenderShader
+	cutoutShadowFailed bool
+
+	// depthPass is the pass of DrawOpaqueScene being drawn
+	depthPass depthPrepassState
+
+	// queue collects the draws submitted with Submit; created on first use
+	queue *renderer.RenderQueue
+
+	// queueDraws is true while DrawRenderable submits to the queue; set
+	// by BeginQueue and cleared by FlushQueue
+	queueDraws bool
+
+	// cullFrustum is the frustum of cullVP used by the frustum culling
+	cullFrustum    renderer.Frustum
+	cullVP         mgl.Mat4
+	cullFrustumSet bool
+
+	// culledCount is the number of nodes culled since ResetCulledCount
+	culledCount int
+
+	// transparentPass is true while the queue's transparent Renderables
+	// are drawn with alpha blending
+	transparentPass bool
+
+	// depthPrepassShader and depthPrepassInstancedShader draw the depth
+	// prepass; created when first needed unless that failed before
+	depthPrepassShader          *fizzle.RenderShader
+	depthPrepassInstancedShader *fizzle.RenderShader
+	depthPrepassFailed          bool
+
+	// lightPasses tracks the draw being split into passes over Lights
+	lightPasses lightPasses
+
+	// chainedBinderFn is the cached method value of chainedBinder so that
+	// a new closure isn't allocated for every draw call.
+	chainedBinderFn renderer.RenderBinder
+
+	// drawScratch holds the matrices calculated while binding a Renderable
+	drawScratch renderer.DrawMatrices
+
+	// binders is the scratch storage for the binder list handed to BindAndDraw.
+	binders [2]renderer.RenderBinder
+
+	// resizables are resized along with the renderer's resolution
+	resizables renderer.ResizeRegistry
+
+	// screenshots reads back the asynchronous screenshots; created on first use.
+	screenshots *renderer.AsyncCapture
+
+	// grabPass holds the copy of the scene color for shaders that sample
+	// SCENE_COLOR_TEX; created by EnableGrabPass.
+	grabPass *renderer.GrabPass
+
+	// sceneGrabbed is true once the scene color was grabbed in the current frame
+	sceneGrabbed bool
+
+	// ssao computes the ambient occlusion sampled with AMBIENT_OCCLUSION_TEX;
+	// created by EnableSSAO.
+	ssao *renderer.SSAO
+
+	// ibl is the image based lighting bound to the IBL_* uniforms; created
+	// by EnableIBL.
+	ibl *renderer.IBL
+
+	// msaa is the multisampled framebuffer the scene is drawn into between
+	// BeginScene and EndScene; created by EnableMSAA.
+	msaa *renderer.MSAA
+
+	// fxaa is the effect added to the end of PostProcess by EnableFXAA
+	fxaa *renderer.FXAA
+
+	// srgbFramebuffer is true while FRAMEBUFFER_SRGB is enabled
+	srgbFramebuffer bool
+
+	// viewport is the viewport being drawn by DrawViewports
+	viewport *renderer.Viewport
+
+	// renderTarget is the target being drawn into; set by SetRenderTarget
+	renderTarget *renderer.RenderTarget
+
+	// oit accumulates the transparent Renderables drawn by FlushQueue;
+	// created by EnableOIT.
+	oit *renderer.OIT
+
+	// oitPass is true while the transparent Renderables are accumulated
+	// into oit
+	oitPass bool
+
+	// instanceVBO is the buffer that per-instance data gets streamed into
+	// for DrawRenderableInstanced; created on first use.
+	instanceVBO graphics.Buffer
+
 	// gfx is the underlying graphics implementation for the renderer
 	gfx graphics.GraphicsProvider
 }
 
+// ForwardRenderer is checked to implement the common Renderer interface
+var _ renderer.Renderer = (*ForwardRenderer)(nil)
+var _ renderer.DrawScratcher = (*ForwardRenderer)(nil)
+
+func init() {
+	renderer.RegisterPipeline("forward", func(gfx graphics.GraphicsProvider) renderer.Renderer {
+		return NewForwardRenderer(gfx)
+	})
+}
+
 // NewForwardRenderer creates a new forward rendering style render engine object.
 func NewForwardRenderer(g graphics.GraphicsProvider) *ForwardRenderer {
 	fr := new(ForwardRenderer)
 	fr.gfx = g
-	fr.OnScreenSizeChanged = func(r *ForwardRenderer, width int32, height int32) {}
+	fr.chainedBinderFn = fr.chainedBinder
+	fr.Points.Size = 1.0
+	fr.destroyedSub = events.Engine.Subscribe(fizzle.RenderableDestroyed{}, fr.onRenderableDestroyed)
+	return fr
+}
+
+// NewSharedForwardRenderer creates a new forward renderer for a secondary
+// window whose context was created sharing resources with the context the
+// Renderables, textures and shaders were created in. The renderer gets its
+// own VAOs and framebuffers since those aren't shared between contexts.
+// The window's context is made current.
+func NewSharedForwardRenderer(g graphics.GraphicsProvider, w window.Window) *ForwardRenderer {
+	w.MakeContextCurrent()
+	fr := NewForwardRenderer(g)
+	fr.VertexArrays = renderer.NewVertexArrayCache(g)
+	fr.SetWindow(w)
 	return fr
 }
 
 // Destroy releases any data the renderer was holding that it 'owns'.
 func (fr *ForwardRenderer) Destroy() {
+	events.Engine.Unsubscribe(fr.destroyedSub)
+	if fr.VertexArrays != nil {
+		fr.VertexArrays.Destroy()
+	}
+	if fr.instanceVBO != 0 {
+		fr.gfx.DeleteBuffer(fr.instanceVBO)
+		fr.instanceVBO = 0
+	}
+	if fr.screenshots != nil {
+		fr.screenshots.Destroy()
+		fr.screenshots = nil
+	}
+	if fr.shadowFBO != 0 {
+		fr.gfx.DeleteFramebuffer(fr.shadowFBO)
+		fr.shadowFBO = 0
+	}
+	if fr.shadowBlur != nil {
+		fr.shadowBlur.Destroy()
+		fr.shadowBlur = nil
+	}
+	if fr.cutoutShadowShader != nil {
+		fr.cutoutShadowShader.Destroy()
+		fr.cutoutShadowShader = nil
+	}
+	fr.destroyDepthPrepass()
+	fr.DisableGrabPass()
+	fr.DisableSSAO()
+	fr.DisableIBL()
+	fr.DisableMSAA()
+	fr.DisableFXAA()
+	fr.DisableOIT()
 }
 
 // NewShadowMap creates a new shadow map object
@@ -231,7 +922,13 @@ func (fr *ForwardRenderer) NewShadowMap() *ShadowMap {
 	shady.owner = fr
 	shady.Up = mgl.Vec3{0.0, 1.0, 0.0}
 	shady.Projection = mgl.Ident4()
+	shady.ProjectionParams = DefaultShadowProjection()
 	shady.View = mgl.Ident4()
+	shady.FilterBlur = 2.0
+	shady.ExponentialConstant = 80.0
+	shady.MinVariance = 0.00002
+	shady.LightBleedReduction = 0.2
+	shady.samplerRect = mgl.Vec4{0.0, 0.0, 1.0, 1.0}
 	return shady
 }
 
@@ -239,16 +936,62 @@ func (fr *ForwardRenderer) NewShadowMap() *ShadowMap {
 func (fr *ForwardRenderer) NewLight() *Light {
 	l := new(Light)
 	l.owner = fr
+	l.ConstantAttenuation = 1.0
 	return l
 }
 
 // ChangeResolution should be called when the underlying rendering
-// window changes size.
+// window changes size. The dynamic resolution scaler and every resource
+// registered with RegisterResizable are resized, and then a
+// ResolutionChanged event is published on events.Engine.
 func (fr *ForwardRenderer) ChangeResolution(width, height int32) {
-	fr.Init(width, height)
-	if fr.OnScreenSizeChanged != nil {
-		fr.OnScreenSizeChanged(fr, width, height)
+	// minimized windows report a zero size; keep the last resolution
+	if width == 0 || height == 0 {
+		return
+	}
+	if err := fr.Init(width, height); err != nil {
+		groggy.Logsf("ERROR", "ForwardRenderer failed to change the resolution: %v", err)
+		return
 	}
+	if fr.DynamicResolution != nil {
+		err := fr.DynamicResolution.Resize(width, height)
+		if err != nil {
+			groggy.Logsf("ERROR", "ForwardRenderer failed to resize the dynamic resolution target: %v", err)
+		}
+	}
+	if fr.PostProcess != nil {
+		if err := fr.PostProcess.Resize(width, height); err != nil {
+			groggy.Logsf("ERROR", "ForwardRenderer failed to resize the post process stack: %v", err)
+		}
+	}
+	if err := fr.resizables.ResizeAll(width, height); err != nil {
+		groggy.Logsf("ERROR", "ForwardRenderer %v", err)
+	}
+	events.Engine.Publish(events.ResolutionChanged{Renderer: fr, Width: width, Height: height})
+}
+
+// RegisterResizable adds a resource, such as a post-process target or a
+// renderer.ScreenProjection, to be resized to the full resolution whenever
+// it changes. The resource is resized right away if the renderer has a
+// resolution.
+func (fr *ForwardRenderer) RegisterResizable(r renderer.Resizable) error {
+	return fr.RegisterScaledResizable(r, 1.0)
+}
+
+// RegisterScaledResizable works like RegisterResizable but sizes the
+// resource to the resolution times the scale.
+func (fr *ForwardRenderer) RegisterScaledResizable(r renderer.Resizable, scale float32) error {
+	fr.resizables.RegisterScaled(r, scale)
+	if fr.width <= 0 || fr.height <= 0 {
+		return nil
+	}
+
+	return r.Resize(renderer.ScaleResolution(fr.width, fr.height, scale))
+}
+
+// UnregisterResizable stops resizing the resource with the renderer.
+func (fr *ForwardRenderer) UnregisterResizable(r renderer.Resizable) {
+	fr.resizables.Unregister(r)
 }
 
 // GetResolution returns the current dimensions of the renderer.
@@ -261,16 +1004,47 @@ func (fr *ForwardRenderer) SetGraphics(gp graphics.GraphicsProvider) {
 	fr.gfx = gp
 }
 
+// GetDrawScratch returns the storage for the matrices calculated while
+// binding Renderables.
+func (fr *ForwardRenderer) GetDrawScratch() *renderer.DrawMatrices {
+	return &fr.drawScratch
+}
+
+// onRenderableDestroyed releases the renderer's VAO for the destroyed
+// Renderable so the cache doesn't map a recycled VAO id to a stale VAO.
+func (fr *ForwardRenderer) onRenderableDestroyed(event interface{}) {
+	if fr.VertexArrays == nil {
+		return
+	}
+	destroyed := event.(fizzle.RenderableDestroyed)
+	if destroyed.Renderable != nil && destroyed.Renderable.Core != nil {
+		fr.VertexArrays.Release(destroyed.Renderable.Core.Vao)
+	}
+}
+
+// GetVertexArrays returns the VAO cache for the renderer's context, which is
+// nil unless the renderer draws into a secondary shared context.
+func (fr *ForwardRenderer) GetVertexArrays() *renderer.VertexArrayCache {
+	return fr.VertexArrays
+}
+
 // GetGraphics returns the renderer's the graphics provider.
 func (fr *ForwardRenderer) GetGraphics() graphics.GraphicsProvider {
 	return fr.gfx
 }
 
-// Init initializes the renderer.
+// Init initializes the renderer. An error is returned if the renderer has
+// no graphics provider or the resolution is invalid.
 func (fr *ForwardRenderer) Init(width, height int32) error {
+	if fr.gfx == nil {
+		return fmt.Errorf("the forward renderer has no graphics provider")
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid forward renderer resolution %dx%d", width, height)
+	}
+
 	fr.width = width
 	fr.height = height
-
 	return nil
 }
 
@@ -279,9 +1053,108 @@ func (fr *ForwardRenderer) GetAspectRatio() float32 {
 	return float32(fr.width) / float32(fr.height)
 }
 
-// EndRenderFrame is the function called at end of the frame.
+// BeginFrame marks the start of a new frame and returns the time in seconds
+// since the start of the previous frame, or 0 for the first frame. If a
+// window was set with SetWindow, its context is made current so that
+// renderers for multiple windows can take turns drawing.
+func (fr *ForwardRenderer) BeginFrame() float64 {
+	if fr.window != nil {
+		fr.window.MakeContextCurrent()
+	}
+	if fr.VertexArrays != nil {
+		fr.VertexArrays.DeleteReleased()
+	}
+
+	now := time.Now()
+	var delta float64
+	if !fr.lastFrameTime.IsZero() {
+		delta = now.Sub(fr.lastFrameTime).Seconds()
+	}
+	fr.lastFrameTime = now
+	fr.sceneGrabbed = false
+	fr.Textures.Update()
+	fr.Profiler.BeginFrame()
+	return delta
+}
+
+// SetWindow sets the surface the renderer presents frames to. The renderer
+// is initialized to the window's framebuffer size, follows its resize events
+// and uses it to set the swap interval. EndRenderFrame swaps the window's
+// buffers once one is set.
+func (fr *ForwardRenderer) SetWindow(w window.Window) {
+	fr.window = w
+	if w == nil {
+		return
+	}
+
+	fr.swapIntervalFn = w.SetSwapInterval
+	w.SetResizeCallback(func(width, height int) {
+		fr.ChangeResolution(int32(width), int32(height))
+	})
+	width, height := w.GetFramebufferSize()
+	if err := fr.Init(int32(width), int32(height)); err != nil {
+		groggy.Logsf("ERROR", "ForwardRenderer failed to initialize for the window: %v", err)
+	}
+}
+
+// GetWindow returns the surface the renderer presents frames to, if one was set.
+func (fr *ForwardRenderer) GetWindow() window.Window {
+	return fr.window
+}
+
+// EndRenderFrame is the function called at end of the frame. If a window
+// was set with SetWindow, its buffers are swapped unless ManualSwap is set.
 func (fr *ForwardRenderer) EndRenderFrame() {
-	// nothing to do
+	fr.Profiler.EndFrame()
+	if fr.screenshots != nil {
+		fr.screenshots.Poll()
+	}
+	if fr.window != nil && !fr.ManualSwap {
+		fr.window.SwapBuffers()
+	}
+	if fr.FrameLimiter != nil {
+		fr.FrameLimiter.Wait()
+	}
+}
+
+// CaptureScreenshot reads the back buffer into an image. It should be called
+// after drawing and before EndRenderFrame swaps the buffers. This waits for
+// the GPU to finish the frame; CaptureScreenshotAsync avoids the stall.
+func (fr *ForwardRenderer) CaptureScreenshot() (*image.RGBA, error) {
+	return renderer.CaptureFramebuffer(fr.gfx, 0, 0, 0, fr.width, fr.height)
+}
+
+// CaptureScreenshotAsync starts copying the back buffer into a pixel buffer
+// and calls done with the image from a later EndRenderFrame once the copy
+// has finished, or with nil if it failed. Like CaptureScreenshot, it should be called after drawing
+// and before EndRenderFrame.
+func (fr *ForwardRenderer) CaptureScreenshotAsync(done func(img *image.RGBA)) error {
+	if fr.screenshots == nil {
+		fr.screenshots = renderer.NewAsyncCapture(fr.gfx, renderer.DefaultCaptureSlots)
+	}
+	return fr.screenshots.Capture(0, 0, 0, fr.width, fr.height, done)
+}
+
+// SetSwapIntervalFunc sets the function used to change the swap interval of
+// the window's context, such as glfw.SwapInterval.
+func (fr *ForwardRenderer) SetSwapIntervalFunc(fn renderer.SwapIntervalFunc) {
+	fr.swapIntervalFn = fn
+}
+
+// SetSwapInterval changes the vsync mode of the window. This can be called at
+// runtime but must be on the thread owning the GL context.
+func (fr *ForwardRenderer) SetSwapInterval(si renderer.SwapInterval) error {
+	if fr.swapIntervalFn == nil {
+		return fmt.Errorf("no swap interval function was set on the renderer")
+	}
+	fr.swapIntervalFn(int(si))
+	fr.swapInterval = si
+	return nil
+}
+
+// GetSwapInterval returns the last swap interval set on the renderer.
+func (fr *ForwardRenderer) GetSwapInterval() renderer.SwapInterval {
+	return fr.swapInterval
 }
 
 // GetActiveLightCount counts the number of *Light set in
@@ -311,124 +1184,192 @@ func (fr *ForwardRenderer) GetActiveShadowLightCount() int {
 }
 
 // SetupShadowMapRendering is called to create the framebuffer to render the shadows
-// and must be called before rendering shadow maps.
-func (fr *ForwardRenderer) SetupShadowMapRendering() {
+// and must be called before rendering shadow maps. An error is returned if the
+// driver doesn't support rendering to a depth texture only framebuffer.
+func (fr *ForwardRenderer) SetupShadowMapRendering() error {
+	gfx := fr.gfx
+	if fr.shadowFBO != 0 {
+		gfx.DeleteFramebuffer(fr.shadowFBO)
+	}
+
 	// create the FBO for the shadows
-	fr.shadowFBO = fr.gfx.GenFramebuffer()
-	fr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, fr.shadowFBO)
+	fr.shadowFBO = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, fr.shadowFBO)
 
 	drawBuffers := []uint32{graphics.NONE}
-	fr.gfx.DrawBuffers(drawBuffers)
-	fr.gfx.ReadBuffer(graphics.NONE)
+	gfx.DrawBuffers(drawBuffers)
+	gfx.ReadBuffer(graphics.NONE)
 
-	/*
-		// we attach a shadowmap here just to check the framebuffer completion status
-		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, light.ShadowMap.Texture, 0);
-		if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
-		}
-	*/
+	// attach a small depth texture just to check the framebuffer completion status
+	testTex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, testTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.DEPTH_COMPONENT32, 1, 1, 0, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT, nil, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, testTex, 0)
+	err := renderer.CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "shadow map")
 
-	fr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, 0, 0)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.DeleteTexture(testTex)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	if err == nil {
+		err = fizzle.CheckGraphicsError(gfx, "creating the shadow map framebuffer")
+	}
+	if err != nil {
+		gfx.DeleteFramebuffer(fr.shadowFBO)
+		fr.shadowFBO = 0
+		return err
+	}
+	return nil
 }
 
 // StartShadowMapping binds the shadow map framebuffer for use by the lights
 // to render shadows.
 func (fr *ForwardRenderer) StartShadowMapping() {
+	fr.Profiler.Begin("shadow pass")
 	fr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, fr.shadowFBO)
 	fr.gfx.Enable(graphics.POLYGON_OFFSET_FILL)
 	fr.gfx.PolygonOffset(4.0, 4.0)
 	fr.gfx.Enable(graphics.CULL_FACE)
 	fr.gfx.CullFace(graphics.FRONT)
 	fr.currentShadowPassLight = nil
+	fr.currentShadowPassVP = nil
+	fr.shadowDepthAttachment = 0
 }
 
 // EndShadowMapping unbinds the shadow map framebuffer and lets the renderer
 // proceed as normal.
 func (fr *ForwardRenderer) EndShadowMapping() {
+	fr.finishShadowMap()
+	fr.useDepthShadowTarget()
 	fr.gfx.CullFace(graphics.BACK)
 	fr.gfx.Disable(graphics.CULL_FACE)
 	fr.gfx.Disable(graphics.POLYGON_OFFSET_FILL)
 	fr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
 	fr.currentShadowPassLight = nil
+	fr.currentShadowPassVP = nil
+	fr.Profiler.End()
 }
 
 // EnableShadowMappingLight enables the light to start casting shadows with draw functions
-// and the appropriate shaders.
+// and the appropriate shaders. An error is returned, and the light's shadows shouldn't
+// be drawn, if the light has no shadow map, SetupShadowMapRendering wasn't called or
+// the shadow framebuffer isn't complete with the shadow map attached. The framebuffer
+// is only checked the first time each shadow map is rendered.
 // NOTE: A good client would call StartShadowMapping() and EndShadowMapping() before
 // and after doing shadow draws.
-func (fr *ForwardRenderer) EnableShadowMappingLight(l *Light) {
+func (fr *ForwardRenderer) EnableShadowMappingLight(l *Light) error {
+	if l.ShadowMap == nil {
+		return fmt.Errorf("can't render shadows for a light without a shadow map")
+	}
+	if fr.shadowFBO == 0 {
+		return fmt.Errorf("can't render shadows before SetupShadowMapRendering")
+	}
+	fr.finishShadowMap()
 	fr.currentShadowPassLight = l
 	l.UpdateShadowMapData()
-	fr.gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, l.ShadowMap.Texture, 0)
-	fr.gfx.Clear(graphics.DEPTH_BUFFER_BIT)
-	fr.gfx.Viewport(0, 0, l.ShadowMap.TextureSize, l.ShadowMap.TextureSize)
+	fr.currentShadowPassVP = &l.ShadowMap.ViewProjMatrix
+	if l.ShadowMap.filtered() {
+		fr.useFilteredShadowTarget(l.ShadowMap)
+	} else {
+		fr.useDepthShadowTarget()
+		fr.useShadowDepthTexture(l.ShadowMap.Texture)
+		fr.clearShadowMap(l.ShadowMap)
+	}
+	fr.gfx.Viewport(l.ShadowMap.Viewport())
+
+	if !l.ShadowMap.framebufferChecked {
+		if err := fr.checkShadowFramebuffer("shadow map"); err != nil {
+			return err
+		}
+		l.ShadowMap.framebufferChecked = true
+	}
+	return nil
+}
+
+// checkShadowFramebuffer returns an error if the shadow framebuffer isn't
+// complete or a GL error was raised while attaching the shadow map. The
+// current shadow pass is abandoned on errors so nothing gets blurred.
+func (fr *ForwardRenderer) checkShadowFramebuffer(name string) error {
+	err := renderer.CheckFramebuffer(fr.gfx, graphics.FRAMEBUFFER, name)
+	if err == nil {
+		err = fizzle.CheckGraphicsError(fr.gfx, "attaching the "+name)
+	}
+	if err != nil {
+		fr.currentShadowPassLight = nil
+		fr.currentShadowPassVP = nil
+	}
+	return err
 }
 
 // do some special binding for the different Renderer types if necessary
 func (fr *ForwardRenderer) chainedBinder(renderer renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
 	gfx := fr.gfx
+	fr.Textures.TouchMaterial(r.GetMaterial())
 	var lightCount = int32(fr.GetActiveLightCount())
 	var shadowLightCount = int32(fr.GetActiveShadowLightCount())
 	if lightCount >= 1 {
+		// the first shadow map in an atlas binds it for the rest
+		var atlas *ShadowAtlas
+		var atlasUnit int32
 		for lightI := 0; lightI < int(lightCount); lightI++ {
 			light := fr.ActiveLights[lightI]
+			names := &lightUniformNames[lightI]
 
-			shaderLightPosition := shader.GetUniformLocation(fmt.Sprintf("LIGHT_POSITION[%d]", lightI))
+			shaderLightPosition := shader.GetUniformLocation(names.position)
 			if shaderLightPosition >= 0 {
 				gfx.Uniform3f(shaderLightPosition, light.Position[0], light.Position[1], light.Position[2])
 			}
 
-			shaderLightDirection := shader.GetUniformLocation(fmt.Sprintf("LIGHT_DIRECTION[%d]", lightI))
+			shaderLightDirection := shader.GetUniformLocation(names.direction)
 			if shaderLightDirection >= 0 {
 				gfx.Uniform3f(shaderLightDirection, light.Direction[0], light.Direction[1], light.Direction[2])
 			}
 
-			shaderLightDiffuse := shader.GetUniformLocation(fmt.Sprintf("LIGHT_DIFFUSE[%d]", lightI))
+			shaderLightDiffuse := shader.GetUniformLocation(names.diffuse)
 			if shaderLightDiffuse >= 0 {
 				gfx.Uniform4f(shaderLightDiffuse, light.DiffuseColor[0], light.DiffuseColor[1], light.DiffuseColor[2], light.DiffuseColor[3])
 			}
 
-			shaderLightIntensity := shader.GetUniformLocation(fmt.Sprintf("LIGHT_DIFFUSE_INTENSITY[%d]", lightI))
+			shaderLightIntensity := shader.GetUniformLocation(names.diffuseIntensity)
 			if shaderLightIntensity >= 0 {
 				gfx.Uniform1f(shaderLightIntensity, light.DiffuseIntensity)
 			}
 
-			shaderLightSpecularIntensity := shader.GetUniformLocation(fmt.Sprintf("LIGHT_SPECULAR_INTENSITY[%d]", lightI))
+			shaderLightSpecularIntensity := shader.GetUniformLocation(names.specularIntensity)
 			if shaderLightSpecularIntensity >= 0 {
 				gfx.Uniform1f(shaderLightSpecularIntensity, light.SpecularIntensity)
 			}
 
-			shaderLightAmbientIntensity := shader.GetUniformLocation(fmt.Sprintf("LIGHT_AMBIENT_INTENSITY[%d]", lightI))
+			shaderLightAmbientIntensity := shader.GetUniformLocation(names.ambientIntensity)
 			if shaderLightAmbientIntensity >= 0 {
 				gfx.Uniform1f(shaderLightAmbientIntensity, light.AmbientIntensity)
 			}
 
-			shaderLightAttenuation := shader.GetUniformLocation(fmt.Sprintf("LIGHT_ATTENUATION[%d]", lightI))
+			shaderLightAttenuation := shader.GetUniformLocation(names.attenuation)
 			if shaderLightAttenuation >= 0 {
 				gfx.Uniform1f(shaderLightAttenuation, light.Attenuation)
 			}
 
-			shaderShadowMaps := shader.GetUniformLocation(fmt.Sprintf("SHADOW_MAPS[%d]", lightI))
-			if shaderShadowMaps >= 0 {
-				///* There have been problems in the past on Intel drivers on Mac OS if all of the
-				///  samplers are not bound to something. So this code will bind a 0 if the shadow map
-				///	 does not exist for that light. */
-				gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
-				if light.ShadowMap != nil {
-					gfx.BindTexture(graphics.TEXTURE_2D, light.ShadowMap.Texture)
-				} else {
-					gfx.BindTexture(graphics.TEXTURE_2D, 0)
-				}
-				gfx.Uniform1i(shaderShadowMaps, *texturesBound)
-				*texturesBound++
+			shaderLightFalloff := shader.GetUniformLocation(names.falloff)
+			if shaderLightFalloff >= 0 {
+				constant, linear, quadratic, lightRange := light.falloff()
+				gfx.Uniform4f(shaderLightFalloff, constant, linear, quadratic, lightRange)
 			}
 
-			if light.ShadowMap != nil {
-				shaderShadowMatrix := shader.GetUniformLocation(fmt.Sprintf("SHADOW_MATRIX[%d]", lightI))
-				if shaderShadowMatrix >= 0 {
-					gfx.UniformMatrix4fv(shaderShadowMatrix, 1, false, light.ShadowMap.BiasedMatrix)
-				}
+			shaderLightSpotCutoff := shader.GetUniformLocation(names.spotCutoff)
+			if shaderLightSpotCutoff >= 0 {
+				innerCos, outerCos := light.spotCutoff()
+				gfx.Uniform2f(shaderLightSpotCutoff, innerCos, outerCos)
 			}
+
+			fr.bindAreaLight(light, names, shader, texturesBound)
+			fr.bindCookie(light, names, shader, texturesBound)
+			fr.bindProfile(light, names, shader, texturesBound)
+
+			fr.bindShadowMap(light, names, shader, texturesBound, &atlas, &atlasUnit)
+			fr.bindFilteredShadowMap(light, names, shader, texturesBound)
+			fr.bindShadowCubeMap(r, light, names, shader, texturesBound)
 		} // lightI
 
 		shaderLightCount := shader.GetUniformLocation("LIGHT_COUNT")
@@ -436,25 +1377,92 @@ func (fr *ForwardRenderer) chainedBinder(renderer renderer.Renderer, r *fizzle.R
 			gfx.Uniform1i(shaderLightCount, lightCount)
 		}
 
+		// renderables that don't receive shadows are drawn as if no light
+		// had a shadow map
 		shaderShadowLightCount := shader.GetUniformLocation("SHADOW_COUNT")
 		if shaderShadowLightCount >= 0 {
-			gfx.Uniform1i(shaderShadowLightCount, shadowLightCount)
+			if r != nil && !r.ReceiveShadow {
+				gfx.Uniform1i(shaderShadowLightCount, 0)
+			} else {
+				gfx.Uniform1i(shaderShadowLightCount, shadowLightCount)
+			}
+		}
+
+		if loc := shader.GetUniformLocation("SHADOW_FADE"); loc >= 0 {
+			fr.bindShadowFade(loc)
 		}
 
-		if fr.currentShadowPassLight != nil {
+		if fr.currentShadowPassVP != nil {
 			shaderShadowVP := shader.GetUniformLocation("SHADOW_VP_MATRIX")
 			if shaderShadowVP >= 0 {
-				gfx.UniformMatrix4fv(shaderShadowVP, 1, false, fr.currentShadowPassLight.ShadowMap.ViewProjMatrix)
+				gfx.UniformMatrix4fv(shaderShadowVP, 1, false, fr.currentShadowPassVP)
 			}
+			fr.bindShadowPassFilter(shader)
 		}
 
 	} // lightcount
+
+	fr.bindProjectors(shader, texturesBound)
+	fr.bindClusters(shader, texturesBound)
+	fr.bindSceneColor(shader, texturesBound)
+	fr.Fog.bind(gfx, shader)
+	fr.Ambient.bind(gfx, shader, fr.lightPasses.pass > 0)
+	fr.ssao.Bind(gfx, shader, texturesBound)
+	fr.ibl.Bind(gfx, shader, texturesBound)
+	fr.oit.Bind(gfx, shader, fr.oitPass)
+	fr.bindLightProbes(r, shader)
+	fr.Points.bind(gfx, r, shader)
+	fr.bindLineWidth(r, shader)
+}
+
+// bindShadowFade binds the distances from the camera where shadows start
+// and finish fading out, or zeros if they don't.
+func (fr *ForwardRenderer) bindShadowFade(loc int32) {
+	if fr.ShadowDistance <= 0.0 {
+		fr.gfx.Uniform2f(loc, 0.0, 0.0)
+		return
+	}
+	start := fr.ShadowDistance - fr.ShadowFadeRange
+	if start < 0.0 {
+		start = 0.0
+	}
+	fr.gfx.Uniform2f(loc, start, fr.ShadowDistance)
+}
+
+// isDrawn returns true if the Renderable should be drawn: it has to be
+// visible, in the layer mask of the viewport being drawn and, while
+// rendering shadows, cast them.
+func (fr *ForwardRenderer) isDrawn(r *fizzle.Renderable) bool {
+	if !r.IsVisible {
+		return false
+	}
+	if fr.viewport != nil && !fr.viewport.Shows(r) {
+		return false
+	}
+	return fr.currentShadowPassVP == nil || r.CastShadow
+}
+
+// getBinders returns the binder list for BindAndDraw using the renderer's scratch
+// storage: the renderer's own chained binder followed by the optional user binder.
+func (fr *ForwardRenderer) getBinders(binder renderer.RenderBinder) []renderer.RenderBinder {
+	fr.binders[0] = fr.chainedBinderFn
+	if binder == nil {
+		return fr.binders[:1]
+	}
+	fr.binders[1] = binder
+	return fr.binders[:2]
 }
 
 // DrawRenderable draws a Renderable object with the supplied projection and view matrixes.
 func (fr *ForwardRenderer) DrawRenderable(r *fizzle.Renderable, binder renderer.RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
 	// only draw visible nodes
-	if !r.IsVisible {
+	if !fr.isDrawn(r) || fr.isCulled(r, perspective, view) {
+		return
+	}
+
+	// in queued mode the draw is recorded for FlushQueue
+	if fr.queueDraws {
+		fr.SubmitWithShader(r, nil, binder)
 		return
 	}
 
@@ -466,11 +1474,14 @@ func (fr *ForwardRenderer) DrawRenderable(r *fizzle.Renderable, binder renderer.
 		return
 	}
 
-	binders := []renderer.RenderBinder{fr.chainedBinder}
-	if binder != nil {
-		binders = append(binders, binder)
+	shader := fr.shadowShader(r, r.GetShader())
+	shader = fr.prepassShader(r, shader)
+	passes := fr.beginLightPasses(r, shader)
+	for pass := 0; pass < passes; pass++ {
+		fr.setLightPass(pass)
+		renderer.BindAndDrawRef(fr, r, shader, fr.getBinders(binder), &perspective, &view, camera, r.Core.Topology.Mode())
 	}
-	renderer.BindAndDraw(fr, r, r.Core.Shader, binders, perspective, view, camera, graphics.TRIANGLES)
+	fr.endLightPasses()
 }
 
 // DrawRenderableWithShader draws a Renderable object with the supplied projection and view matrixes
@@ -478,7 +1489,7 @@ func (fr *ForwardRenderer) DrawRenderable(r *fizzle.Renderable, binder renderer.
 func (fr *ForwardRenderer) DrawRenderableWithShader(r *fizzle.Renderable, shader *fizzle.RenderShader,
 	binder renderer.RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
 	// only draw visible nodes
-	if !r.IsVisible {
+	if !fr.isDrawn(r) {
 		return
 	}
 
@@ -490,18 +1501,21 @@ func (fr *ForwardRenderer) DrawRenderableWithShader(r *fizzle.Renderable, shader
 		return
 	}
 
-	binders := []renderer.RenderBinder{fr.chainedBinder}
-	if binder != nil {
-		binders = append(binders, binder)
+	shader = fr.shadowShader(r, shader)
+	shader = fr.prepassShader(r, shader)
+	passes := fr.beginLightPasses(r, shader)
+	for pass := 0; pass < passes; pass++ {
+		fr.setLightPass(pass)
+		renderer.BindAndDrawRef(fr, r, shader, fr.getBinders(binder), &perspective, &view, camera, r.Core.Topology.Mode())
 	}
-	renderer.BindAndDraw(fr, r, shader, binders, perspective, view, camera, graphics.TRIANGLES)
+	fr.endLightPasses()
 }
 
 // DrawLines draws the Renderable using graphics.LINES mode instead of graphics.TRIANGLES.
 func (fr *ForwardRenderer) DrawLines(r *fizzle.Renderable, shader *fizzle.RenderShader, binder renderer.RenderBinder,
 	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
 	// only draw visible nodes
-	if !r.IsVisible {
+	if !fr.isDrawn(r) {
 		return
 	}
 
@@ -513,9 +1527,125 @@ func (fr *ForwardRenderer) DrawLines(r *fizzle.Renderable, shader *fizzle.Render
 		return
 	}
 
-	binders := []renderer.RenderBinder{fr.chainedBinder}
-	if binder != nil {
-		binders = append(binders, binder)
+	renderer.BindAndDrawRef(fr, r, shader, fr.getBinders(binder), &perspective, &view, camera, graphics.LINES)
+}
+
+// DrawRenderableInstanced draws one instance of the Renderable for each of the
+// transforms supplied using a single draw call per Renderable node. The shader
+// needs to use the INSTANCE_M_MATRIX vertex attribute to place each instance.
+// NOTE: requires instancing support in the graphics provider (not OpenGL ES 2).
+func (fr *ForwardRenderer) DrawRenderableInstanced(r *fizzle.Renderable, transforms []mgl.Mat4, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	// only draw visible nodes
+	if !fr.isDrawn(r) || len(transforms) == 0 {
+		return
+	}
+
+	// if the renderable is a group, just try to draw the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			fr.DrawRenderableInstanced(child, transforms, binder, perspective, view, camera)
+		}
+		return
+	}
+
+	if fr.instanceVBO == 0 {
+		fr.instanceVBO = fr.gfx.GenBuffer()
+	}
+
+	shader := fr.prepassInstancedShader(r.GetShader())
+	passes := fr.beginLightPasses(nil, shader)
+	for pass := 0; pass < passes; pass++ {
+		fr.setLightPass(pass)
+		renderer.BindAndDrawInstanced(fr, r, shader, fr.getBinders(binder), &perspective, &view, camera,
+			r.Core.Topology.Mode(), fr.instanceVBO, transforms)
+	}
+	fr.endLightPasses()
+}
+
+// DrawRenderableColoredInstanced works like DrawRenderableInstanced but
+// also gives every instance its own color, bound to the INSTANCE_COLOR
+// vertex attribute, so that thousands of rocks or crates can be tinted
+// differently and still be drawn with one draw call per Renderable node.
+// NOTE: requires instancing support in the graphics provider (not OpenGL ES 2).
+func (fr *ForwardRenderer) DrawRenderableColoredInstanced(r *fizzle.Renderable, instances []renderer.ColoredInstance, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	// only draw visible nodes
+	if !fr.isDrawn(r) || len(instances) == 0 {
+		return
+	}
+
+	// if the renderable is a group, just try to draw the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			fr.DrawRenderableColoredInstanced(child, instances, binder, perspective, view, camera)
+		}
+		return
+	}
+
+	if fr.instanceVBO == 0 {
+		fr.instanceVBO = fr.gfx.GenBuffer()
+	}
+
+	shader := fr.prepassInstancedShader(r.GetShader())
+	passes := fr.beginLightPasses(nil, shader)
+	for pass := 0; pass < passes; pass++ {
+		fr.setLightPass(pass)
+		renderer.BindAndDrawColoredInstanced(fr, r, shader, fr.getBinders(binder), &perspective, &view, camera,
+			r.Core.Topology.Mode(), fr.instanceVBO, instances)
+	}
+	fr.endLightPasses()
+}
+
+// DrawRenderableSkinnedInstanced draws one posed copy of the Renderable for
+// each instance. The Renderable's Core must have BakedAnimations set and its
+// shader should skin the vertices from BONE_TEXTURE, like the
+// skinned_instanced shader.
+func (fr *ForwardRenderer) DrawRenderableSkinnedInstanced(r *fizzle.Renderable, instances []renderer.SkinnedInstance,
+	binder renderer.RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	// only draw visible nodes
+	if !fr.isDrawn(r) || len(instances) == 0 {
+		return
+	}
+
+	// if the renderable is a group, just try to draw the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			fr.DrawRenderableSkinnedInstanced(child, instances, binder, perspective, view, camera)
+		}
+		return
+	}
+
+	if fr.instanceVBO == 0 {
+		fr.instanceVBO = fr.gfx.GenBuffer()
+	}
+
+	passes := fr.beginLightPasses(nil, r.GetShader())
+	for pass := 0; pass < passes; pass++ {
+		fr.setLightPass(pass)
+		renderer.BindAndDrawSkinnedInstanced(fr, r, r.GetShader(), fr.getBinders(binder), &perspective, &view, camera,
+			r.Core.Topology.Mode(), fr.instanceVBO, instances)
+	}
+	fr.endLightPasses()
+}
+
+// SubmitCommandList draws all of the commands recorded in the list, which may
+// have been recorded on other goroutines with a renderer.CommandRecorder.
+// Commands for Renderables outside of the current viewport's layer mask are
+// skipped. This must be called on the thread owning the GL context.
+func (fr *ForwardRenderer) SubmitCommandList(list *renderer.CommandList, camera fizzle.Camera) {
+	fr.Profiler.Begin("submit commands")
+	defer fr.Profiler.End()
+	for i := range list.Commands {
+		cmd := &list.Commands[i]
+		if !fr.isDrawn(cmd.Renderable) {
+			continue
+		}
+		if shader := fr.prepassShader(cmd.Renderable, cmd.Shader); shader != cmd.Shader {
+			prepass := *cmd
+			prepass.Shader = shader
+			cmd = &prepass
+		}
+		renderer.SubmitCommand(fr, cmd, fr.getBinders(cmd.Binder), camera)
 	}
-	renderer.BindAndDraw(fr, r, shader, binders, perspective, view, camera, graphics.LINES)
 }
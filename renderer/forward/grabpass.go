@@ -0,0 +1,88 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableGrabPass creates the texture that the scene color is copied into for
+// shaders that declare the SCENE_COLOR_TEX sampler, like the refraction
+// shader. The first Renderable drawn in a frame with such a shader grabs the
+// scene drawn so far automatically, so the opaque geometry should be drawn
+// before the transparent geometry using it. GrabSceneColor can be called to
+// grab at a specific point instead.
+func (fr *ForwardRenderer) EnableGrabPass() error {
+	if fr.grabPass != nil {
+		return nil
+	}
+	gp, err := renderer.NewGrabPass(fr.gfx, fr.width, fr.height)
+	if err != nil {
+		return err
+	}
+	fr.grabPass = gp
+	fr.resizables.Register(gp)
+	return nil
+}
+
+// DisableGrabPass releases the grab pass texture. Shaders sampling
+// SCENE_COLOR_TEX get texture 0 bound afterwards.
+func (fr *ForwardRenderer) DisableGrabPass() {
+	if fr.grabPass == nil {
+		return
+	}
+	fr.resizables.Unregister(fr.grabPass)
+	fr.grabPass.Destroy()
+	fr.grabPass = nil
+}
+
+// GetSceneColorTexture returns the texture holding the grabbed scene color
+// or 0 if the grab pass isn't enabled.
+func (fr *ForwardRenderer) GetSceneColorTexture() graphics.Texture {
+	if fr.grabPass == nil {
+		return 0
+	}
+	return fr.grabPass.Texture()
+}
+
+// GrabSceneColor copies the scene drawn so far into the grab pass texture.
+// The scene is read from the DynamicResolution framebuffer if set and
+// otherwise from the default framebuffer. It does nothing if the grab
+// pass isn't enabled.
+func (fr *ForwardRenderer) GrabSceneColor() {
+	if fr.grabPass == nil {
+		return
+	}
+
+	fr.Profiler.Begin("grab pass")
+	if fr.DynamicResolution != nil {
+		w, h := fr.DynamicResolution.GetScaledResolution()
+		fr.grabPass.Grab(fr.DynamicResolution.Framebuffer(), w, h)
+	} else {
+		fr.grabPass.Grab(0, fr.width, fr.height)
+	}
+	fr.Profiler.End()
+	fr.sceneGrabbed = true
+}
+
+// bindSceneColor binds the grabbed scene color to SCENE_COLOR_TEX, grabbing
+// it first if that hasn't happened yet this frame.
+func (fr *ForwardRenderer) bindSceneColor(shader *fizzle.RenderShader, texturesBound *int32) {
+	shaderSceneColor := shader.GetUniformLocation("SCENE_COLOR_TEX")
+	if shaderSceneColor < 0 {
+		return
+	}
+
+	if fr.grabPass != nil && !fr.sceneGrabbed {
+		fr.GrabSceneColor()
+	}
+
+	gfx := fr.gfx
+	gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+	gfx.BindTexture(graphics.TEXTURE_2D, fr.GetSceneColorTexture())
+	gfx.Uniform1i(shaderSceneColor, *texturesBound)
+	*texturesBound++
+}
@@ -0,0 +1,53 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableIBL generates the image based lighting of the environment cubemap
+// with faces of the size. Shaders that declare the IBL_* uniforms, like
+// the pbr shader, take their ambient light from it afterwards. Enabling it
+// again replaces the previous environment.
+func (fr *ForwardRenderer) EnableIBL(environment graphics.Texture, size int32, settings renderer.IBLSettings) error {
+	ibl, err := renderer.NewIBL(fr, environment, size, settings)
+	if err != nil {
+		return err
+	}
+	fr.DisableIBL()
+	fr.ibl = ibl
+	return nil
+}
+
+// EnableIBLFromEquirectangular works like EnableIBL for an equirectangular
+// panorama texture, which is converted into a cubemap with faces of the
+// size first.
+func (fr *ForwardRenderer) EnableIBLFromEquirectangular(equirect graphics.Texture, size int32, settings renderer.IBLSettings) error {
+	ibl, err := renderer.NewIBLFromEquirectangular(fr, equirect, size, settings)
+	if err != nil {
+		return err
+	}
+	fr.DisableIBL()
+	fr.ibl = ibl
+	return nil
+}
+
+// DisableIBL releases the image based lighting. Shaders get IBL_ENABLED set
+// to 0 afterwards.
+func (fr *ForwardRenderer) DisableIBL() {
+	if fr.ibl == nil {
+		return
+	}
+	fr.ibl.Destroy()
+	fr.ibl = nil
+}
+
+// GetIBL returns the image based lighting so that its intensity can be
+// changed or its environment drawn as the skybox, or nil if it isn't
+// enabled.
+func (fr *ForwardRenderer) GetIBL() *renderer.IBL {
+	return fr.ibl
+}
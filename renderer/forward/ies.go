@@ -0,0 +1,281 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// profileTextureWidth is the number of horizontal angles, covering a
+	// full circle, in the textures of IES profiles.
+	profileTextureWidth = 64
+
+	// profileTextureHeight is the number of vertical angles, from straight
+	// along the light's axis to straight away from it, in the textures of
+	// IES profiles.
+	profileTextureHeight = 128
+)
+
+// IESProfile is the angular distribution of the light of a fixture measured
+// in an IES LM-63 photometric file. Vertical angles are measured from the
+// nadir, which is the fixture's axis, and horizontal angles around it; both
+// are in degrees.
+type IESProfile struct {
+	// VerticalAngles are the increasing vertical angles of the measurements.
+	VerticalAngles []float32
+
+	// HorizontalAngles are the increasing horizontal angles of the
+	// measurements. A single angle means the light is the same all around
+	// the axis and a last angle of 90 or 180 means the measurements are
+	// mirrored to cover the rest of the circle.
+	HorizontalAngles []float32
+
+	// Candela are the measured intensities for every horizontal angle, each
+	// holding a value for every vertical angle.
+	Candela [][]float32
+
+	// MaxCandela is the largest of the measured intensities.
+	MaxCandela float32
+}
+
+// LoadIESProfile loads an IES profile from a file.
+func LoadIESProfile(filePath string) (*IESProfile, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseIESProfile(f)
+}
+
+// ParseIESProfile reads an IES LM-63 profile. The keywords before the TILT
+// line are skipped, as is any lamp tilt data.
+func ParseIESProfile(r io.Reader) (*IESProfile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	tiltLine := -1
+	tiltInclude := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToUpper(line), "TILT=") {
+			tiltLine = i
+			tiltInclude = strings.ToUpper(strings.TrimSpace(line[5:])) == "INCLUDE"
+			break
+		}
+	}
+	if tiltLine < 0 {
+		return nil, fmt.Errorf("the IES profile has no TILT line")
+	}
+
+	// the rest of the file is numbers separated by spaces, commas or lines
+	words := strings.FieldsFunc(strings.Join(lines[tiltLine+1:], " "), func(c rune) bool {
+		return unicode.IsSpace(c) || c == ','
+	})
+	next := func() (float32, error) {
+		if len(words) == 0 {
+			return 0.0, fmt.Errorf("the IES profile ended early")
+		}
+		word := words[0]
+		words = words[1:]
+		value, err := strconv.ParseFloat(word, 32)
+		if err != nil {
+			return 0.0, fmt.Errorf("invalid number %q in the IES profile", word)
+		}
+		return float32(value), nil
+	}
+	readValues := func(count int) ([]float32, error) {
+		values := make([]float32, count)
+		for i := range values {
+			v, err := next()
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return values, nil
+	}
+
+	if tiltInclude {
+		// lamp to luminaire geometry, then the pairs of angles and multipliers
+		if _, err := next(); err != nil {
+			return nil, err
+		}
+		pairs, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if pairs < 0.0 {
+			return nil, fmt.Errorf("invalid IES profile tilt pair count %f", pairs)
+		}
+		if _, err = readValues(int(pairs) * 2); err != nil {
+			return nil, err
+		}
+	}
+
+	header, err := readValues(13)
+	if err != nil {
+		return nil, err
+	}
+	multiplier := header[2]
+	verticalCount := int(header[3])
+	horizontalCount := int(header[4])
+	if verticalCount <= 0 || horizontalCount <= 0 {
+		return nil, fmt.Errorf("invalid IES profile angle counts %d and %d", verticalCount, horizontalCount)
+	}
+
+	profile := new(IESProfile)
+	if profile.VerticalAngles, err = readValues(verticalCount); err != nil {
+		return nil, err
+	}
+	if profile.HorizontalAngles, err = readValues(horizontalCount); err != nil {
+		return nil, err
+	}
+	profile.Candela = make([][]float32, horizontalCount)
+	for h := range profile.Candela {
+		if profile.Candela[h], err = readValues(verticalCount); err != nil {
+			return nil, err
+		}
+		for v := range profile.Candela[h] {
+			profile.Candela[h][v] *= multiplier
+			if profile.Candela[h][v] > profile.MaxCandela {
+				profile.MaxCandela = profile.Candela[h][v]
+			}
+		}
+	}
+	return profile, nil
+}
+
+// Sample returns the intensity of the profile in the direction, relative to
+// MaxCandela. Directions outside of the measured vertical angles are dark.
+func (p *IESProfile) Sample(vertical, horizontal float32) float32 {
+	if p.MaxCandela <= 0.0 || len(p.VerticalAngles) == 0 {
+		return 0.0
+	}
+	if vertical < p.VerticalAngles[0] || vertical > p.VerticalAngles[len(p.VerticalAngles)-1] {
+		return 0.0
+	}
+
+	// fold the horizontal angle into the measured range using the symmetry
+	horizontal = float32(math.Mod(float64(horizontal), 360.0))
+	if horizontal < 0.0 {
+		horizontal += 360.0
+	}
+	last := p.HorizontalAngles[len(p.HorizontalAngles)-1]
+	switch {
+	case len(p.HorizontalAngles) == 1:
+		horizontal = p.HorizontalAngles[0]
+	case last <= 90.0:
+		horizontal = float32(math.Mod(float64(horizontal), 180.0))
+		if horizontal > 90.0 {
+			horizontal = 180.0 - horizontal
+		}
+	case last <= 180.0:
+		if horizontal > 180.0 {
+			horizontal = 360.0 - horizontal
+		}
+	}
+
+	h0, h1, ht := bracketAngle(p.HorizontalAngles, horizontal)
+	v0, v1, vt := bracketAngle(p.VerticalAngles, vertical)
+	low := p.Candela[h0][v0]*(1.0-vt) + p.Candela[h0][v1]*vt
+	high := p.Candela[h1][v0]*(1.0-vt) + p.Candela[h1][v1]*vt
+	return (low*(1.0-ht) + high*ht) / p.MaxCandela
+}
+
+// bracketAngle returns the indexes of the angles on either side of the
+// angle and how far it is between them.
+func bracketAngle(angles []float32, angle float32) (int, int, float32) {
+	if angle <= angles[0] {
+		return 0, 0, 0.0
+	}
+	for i := 1; i < len(angles); i++ {
+		if angle <= angles[i] {
+			span := angles[i] - angles[i-1]
+			if span <= 0.0 {
+				return i, i, 0.0
+			}
+			return i - 1, i, (angle - angles[i-1]) / span
+		}
+	}
+	last := len(angles) - 1
+	return last, last, 0.0
+}
+
+// CreateTexture resamples the profile into a texture for Light.Profile.
+// The texture's s coordinate is the horizontal angle over a full circle and
+// its t coordinate is the vertical angle from 0 to 180 degrees.
+func (p *IESProfile) CreateTexture(gfx graphics.GraphicsProvider) (graphics.Texture, error) {
+	data := make([]float32, profileTextureWidth*profileTextureHeight*4)
+	for y := 0; y < profileTextureHeight; y++ {
+		vertical := (float32(y) + 0.5) / profileTextureHeight * 180.0
+		for x := 0; x < profileTextureWidth; x++ {
+			horizontal := (float32(x) + 0.5) / profileTextureWidth * 360.0
+			value := p.Sample(vertical, horizontal)
+			i := (y*profileTextureWidth + x) * 4
+			data[i], data[i+1], data[i+2], data[i+3] = value, value, value, 1.0
+		}
+	}
+
+	tex := gfx.GenTexture()
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.REPEAT)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, profileTextureWidth, profileTextureHeight, 0,
+		graphics.RGBA, graphics.FLOAT, gfx.Ptr(data), len(data)*4)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	err := fizzle.CheckGraphicsError(gfx, "creating the IES profile texture")
+	if err != nil {
+		gfx.DeleteTexture(tex)
+		return 0, err
+	}
+	return tex, nil
+}
+
+// hasProfile returns true if the light's Profile shapes its light, which is
+// the case for point and spot lights.
+func (l *Light) hasProfile() bool {
+	if l.Profile == 0 || l.IsArea() {
+		return false
+	}
+	return l.IsSpot() || l.Direction.Len() == 0.0
+}
+
+// bindProfile binds the light's IES profile texture, or no texture if it
+// doesn't have one.
+func (fr *ForwardRenderer) bindProfile(light *Light, names *lightUniforms, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := fr.gfx
+	if loc := shader.GetUniformLocation(names.profileEnabled); loc >= 0 {
+		if light.hasProfile() {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+	if loc := shader.GetUniformLocation(names.profile); loc >= 0 {
+		// bind a 0 for lights without a profile so that every sampler is bound
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, light.Profile)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+}
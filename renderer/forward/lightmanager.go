@@ -0,0 +1,157 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// LightManager picks the lights that matter most to each Renderable out of
+// all the lights in a scene. When set as the renderer's LightManager, every
+// Renderable drawn with DrawRenderable or DrawRenderableWithShader is lit by
+// its own MaxForwardLights most relevant lights, so ActiveLights doesn't
+// need to be repacked as things move around. Instanced draws are lit by the
+// renderer's ActiveLights or Lights as usual.
+//
+// Directional lights always come first. The other lights are ranked by how
+// bright they are at the closest point of the Renderable's bounding sphere,
+// and lights whose Range doesn't reach it are skipped. Lights with shadow
+// maps are put in the first slots of the selection, as ActiveLights expects.
+type LightManager struct {
+	// Lights are all of the lights to choose from.
+	Lights []*Light
+
+	// candidates is the scratch space for ranking the lights
+	candidates []rankedLight
+}
+
+// rankedLight is a light and its relevance to the Renderable being drawn.
+type rankedLight struct {
+	light       *Light
+	relevance   float32
+	directional bool
+}
+
+// rankedLights sorts lights with the directional ones first, followed by
+// the others from the most to the least relevant.
+type rankedLights []rankedLight
+
+func (rl rankedLights) Len() int      { return len(rl) }
+func (rl rankedLights) Swap(i, j int) { rl[i], rl[j] = rl[j], rl[i] }
+func (rl rankedLights) Less(i, j int) bool {
+	if rl[i].directional != rl[j].directional {
+		return rl[i].directional
+	}
+	return rl[i].relevance > rl[j].relevance
+}
+
+// NewLightManager creates a new light manager without any lights.
+func NewLightManager() *LightManager {
+	lm := new(LightManager)
+	lm.Lights = make([]*Light, 0, MaxForwardLights*4)
+	return lm
+}
+
+// Add adds the light to the ones the manager chooses from.
+func (lm *LightManager) Add(l *Light) {
+	lm.Lights = append(lm.Lights, l)
+}
+
+// Remove removes the light from the ones the manager chooses from.
+func (lm *LightManager) Remove(l *Light) {
+	for i, other := range lm.Lights {
+		if other == l {
+			lm.Lights = append(lm.Lights[:i], lm.Lights[i+1:]...)
+			return
+		}
+	}
+}
+
+// Select fills dest with the most relevant lights for the Renderable,
+// leaving the unused slots nil.
+func (lm *LightManager) Select(r *fizzle.Renderable, dest *[MaxForwardLights]*Light) {
+	center, radius := worldBoundingSphere(r)
+
+	lm.candidates = lm.candidates[:0]
+	for _, l := range lm.Lights {
+		if l == nil || !l.InRange(center, radius) {
+			continue
+		}
+		candidate := rankedLight{light: l}
+		if l.Direction.Len() > 0.0 && !l.IsSpot() && !l.IsArea() {
+			candidate.directional = true
+		} else {
+			dist := l.Position.Sub(center).Len() - radius
+			if dist < 0.0 {
+				dist = 0.0
+			}
+			brightness := l.DiffuseColor.Vec3().Dot(mgl.Vec3{0.2126, 0.7152, 0.0722}) * l.DiffuseIntensity
+			candidate.relevance = brightness * l.attenuationAt(dist)
+		}
+		lm.candidates = append(lm.candidates, candidate)
+	}
+	sort.Stable(rankedLights(lm.candidates))
+
+	for i := range dest {
+		dest[i] = nil
+	}
+
+	// the shadow casters of the selection go in the first slots
+	count := 0
+	selected := lm.candidates
+	if len(selected) > MaxForwardLights {
+		selected = selected[:MaxForwardLights]
+	}
+	for _, c := range selected {
+		if c.light.ShadowMap != nil {
+			dest[count] = c.light
+			count++
+		}
+	}
+	for _, c := range selected {
+		if c.light.ShadowMap == nil {
+			dest[count] = c.light
+			count++
+		}
+	}
+}
+
+// attenuationAt returns how much of the light reaches the distance, the
+// same as CalcAttenuation does in the shaders.
+func (l *Light) attenuationAt(dist float32) float32 {
+	constant, linear, quadratic, lightRange := l.falloff()
+	denom := constant + linear*dist + quadratic*dist*dist
+	if denom < 0.0001 {
+		denom = 0.0001
+	}
+	attenuation := 1.0 / denom
+	if lightRange > 0.0 {
+		ratio := dist / lightRange
+		window := mgl.Clamp(1.0-ratio*ratio*ratio*ratio, 0.0, 1.0)
+		attenuation *= window * window
+	}
+	return attenuation
+}
+
+// worldBoundingSphere returns a sphere around the Renderable's bounding
+// rectangle in world space.
+func worldBoundingSphere(r *fizzle.Renderable) (mgl.Vec3, float32) {
+	transform := r.GetTransformMat4()
+	rect := r.BoundingRect
+	localCenter := rect.Bottom.Add(rect.Top).Mul(0.5)
+	center := transform.Mul4x1(localCenter.Vec4(1.0)).Vec3()
+
+	// the largest axis scale bounds how much the transform stretches the radius
+	scale := float32(0.0)
+	for col := 0; col < 3; col++ {
+		if s := transform.Col(col).Vec3().Len(); s > scale {
+			scale = s
+		}
+	}
+	radius := rect.Top.Sub(rect.Bottom).Len() * 0.5 * scale
+	return center, radius
+}
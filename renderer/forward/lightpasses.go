@@ -0,0 +1,126 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// lightPasses is the state of a draw split into several lighting passes
+// because there are more Lights than the shaders have slots for.
+type lightPasses struct {
+	// count is the number of passes of the current draw; zero when the
+	// draw isn't split and ActiveLights is used as is
+	count int
+
+	// pass is the index of the pass being drawn
+	pass int
+
+	// selected is true when the LightManager picked the lights of the
+	// draw, which then has a single pass
+	selected bool
+
+	// savedLights is the ActiveLights array to restore after the draw
+	savedLights [MaxForwardLights]*Light
+
+	// savedFogColor is the fog color to restore after the additive passes
+	savedFogColor mgl.Vec4
+}
+
+// beginLightPasses returns how many times a renderable should be drawn with
+// the shader to light it with all of the renderer's Lights. Draws are only
+// split when Lights is set, the shader is lit and no shadow map is being
+// rendered; otherwise a single pass with ActiveLights is returned. If the
+// renderer has a LightManager and the renderable is known, the manager
+// selects the lights for a single pass instead.
+func (fr *ForwardRenderer) beginLightPasses(r *fizzle.Renderable, shader *fizzle.RenderShader) int {
+	fr.lightPasses.count = 0
+	fr.lightPasses.pass = 0
+	fr.lightPasses.selected = false
+	if fr.currentShadowPassVP != nil || fr.depthPass == depthPrepassDepth || shader == nil {
+		return 1
+	}
+	if fr.LightManager == nil && len(fr.Lights) == 0 {
+		return 1
+	}
+	if shader.GetUniformLocation("LIGHT_COUNT") < 0 {
+		return 1
+	}
+
+	if fr.LightManager != nil && r != nil {
+		fr.lightPasses.count = 1
+		fr.lightPasses.selected = true
+		fr.lightPasses.savedLights = fr.ActiveLights
+		fr.LightManager.Select(r, &fr.ActiveLights)
+		return 1
+	}
+	if len(fr.Lights) == 0 {
+		return 1
+	}
+
+	fr.lightPasses.count = (len(fr.Lights) + MaxForwardLights - 1) / MaxForwardLights
+	fr.lightPasses.savedLights = fr.ActiveLights
+	return fr.lightPasses.count
+}
+
+// setLightPass puts the lights of the pass into ActiveLights. Every pass
+// after the first is blended additively on top of the first with the fog
+// color removed so that it only attenuates the added light.
+func (fr *ForwardRenderer) setLightPass(pass int) {
+	if fr.lightPasses.count == 0 || fr.lightPasses.selected {
+		return
+	}
+
+	fr.lightPasses.pass = pass
+	first := pass * MaxForwardLights
+	for i := range fr.ActiveLights {
+		fr.ActiveLights[i] = nil
+		if first+i < len(fr.Lights) {
+			fr.ActiveLights[i] = fr.Lights[first+i]
+		}
+	}
+
+	if pass == 1 {
+		gfx := fr.gfx
+		fr.lightPasses.savedFogColor = fr.Fog.Color
+		fr.Fog.Color = mgl.Vec4{0.0, 0.0, 0.0, 0.0}
+		gfx.DepthFunc(graphics.LEQUAL)
+		gfx.DepthMask(false)
+		// the OIT accumulation already sums the weighted colors
+		if !fr.oitPass {
+			gfx.Enable(graphics.BLEND)
+			if fr.transparentPass {
+				// the added light is still faded by the material's alpha
+				gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE)
+			} else {
+				gfx.BlendFunc(graphics.ONE, graphics.ONE)
+			}
+		}
+	}
+}
+
+// endLightPasses restores ActiveLights and the state changed by the
+// additive passes.
+func (fr *ForwardRenderer) endLightPasses() {
+	if fr.lightPasses.count == 0 {
+		return
+	}
+
+	fr.ActiveLights = fr.lightPasses.savedLights
+	if fr.lightPasses.count > 1 {
+		gfx := fr.gfx
+		fr.Fog.Color = fr.lightPasses.savedFogColor
+		if fr.transparentPass && !fr.oitPass {
+			gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE_MINUS_SRC_ALPHA)
+		} else if !fr.transparentPass {
+			gfx.Disable(graphics.BLEND)
+		}
+		fr.restoreDepthState()
+	}
+	fr.lightPasses.count = 0
+	fr.lightPasses.pass = 0
+	fr.lightPasses.selected = false
+}
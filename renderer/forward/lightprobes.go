@@ -0,0 +1,460 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// SHCoefficientCount is the number of coefficients of the second order
+	// spherical harmonics stored in light probes.
+	SHCoefficientCount = 9
+
+	// maxLightProbeNeighbors is the most probes blended for a Renderable.
+	maxLightProbeNeighbors = 8
+)
+
+var (
+	// lightProbeUniformNames caches the names of the LIGHT_PROBE_SH elements
+	// so that binding the probes doesn't format new strings for every
+	// Renderable drawn.
+	lightProbeUniformNames [SHCoefficientCount]string
+
+	// LightProbeShaderFunctions330 is the GLSL for the CalcLightProbe function
+	// used by the built-in diffuse shaders. Custom shaders can include it after
+	// their declarations to receive the light probes the renderer binds
+	// through the LIGHT_PROBE_* uniforms.
+	LightProbeShaderFunctions330 = `
+  uniform int LIGHT_PROBE_ENABLED;
+  uniform vec3 LIGHT_PROBE_SH[9];
+
+  // CalcLightProbe returns the irradiance of the interpolated light probe
+  // for the world space normal.
+  vec3 CalcLightProbe(vec3 n)
+  {
+    const float c1 = 0.429043;
+    const float c2 = 0.511664;
+    const float c3 = 0.743125;
+    const float c4 = 0.886227;
+    const float c5 = 0.247708;
+    vec3 irradiance = c4 * LIGHT_PROBE_SH[0] - c5 * LIGHT_PROBE_SH[6]
+      + 2.0 * c2 * (LIGHT_PROBE_SH[3] * n.x + LIGHT_PROBE_SH[1] * n.y + LIGHT_PROBE_SH[2] * n.z)
+      + c3 * LIGHT_PROBE_SH[6] * n.z * n.z
+      + c1 * LIGHT_PROBE_SH[8] * (n.x * n.x - n.y * n.y)
+      + 2.0 * c1 * (LIGHT_PROBE_SH[4] * n.x * n.y + LIGHT_PROBE_SH[7] * n.x * n.z + LIGHT_PROBE_SH[5] * n.y * n.z);
+    return max(irradiance, vec3(0.0));
+  }
+`
+)
+
+func init() {
+	for i := range lightProbeUniformNames {
+		lightProbeUniformNames[i] = fmt.Sprintf("LIGHT_PROBE_SH[%d]", i)
+	}
+}
+
+// SphericalHarmonics holds the light arriving from every direction around
+// a point as second order spherical harmonics, one RGB color for each of
+// the coefficients in the order L00, L1-1, L10, L11, L2-2, L2-1, L20, L21
+// and L22. The light is scaled so that Irradiance matches the diffuse
+// lighting of the shaders, which don't divide by pi.
+type SphericalHarmonics [SHCoefficientCount]mgl.Vec3
+
+// shBasis returns the spherical harmonics basis functions for the
+// normalized direction.
+func shBasis(dir mgl.Vec3) [SHCoefficientCount]float32 {
+	x, y, z := dir[0], dir[1], dir[2]
+	return [SHCoefficientCount]float32{
+		0.282095,
+		0.488603 * y,
+		0.488603 * z,
+		0.488603 * x,
+		1.092548 * x * y,
+		1.092548 * y * z,
+		0.315392 * (3.0*z*z - 1.0),
+		1.092548 * x * z,
+		0.546274 * (x*x - y*y),
+	}
+}
+
+// Clear removes all of the light.
+func (sh *SphericalHarmonics) Clear() {
+	for i := range sh {
+		sh[i] = mgl.Vec3{}
+	}
+}
+
+// AddScaled adds the other harmonics multiplied by the weight.
+func (sh *SphericalHarmonics) AddScaled(other *SphericalHarmonics, weight float32) {
+	for i := range sh {
+		sh[i] = sh[i].Add(other[i].Mul(weight))
+	}
+}
+
+// AddLight adds the light of a directional light shining from the
+// direction, which points towards the light, such that a surface facing it
+// receives about the color.
+func (sh *SphericalHarmonics) AddLight(direction mgl.Vec3, color mgl.Vec3) {
+	if direction.Len() == 0.0 {
+		return
+	}
+	basis := shBasis(direction.Normalize())
+	for i := range sh {
+		sh[i] = sh[i].Add(color.Mul(basis[i]))
+	}
+}
+
+// AddRadiance adds the color seen in the direction over the solid angle,
+// as is done for every texel of a captured cubemap.
+func (sh *SphericalHarmonics) AddRadiance(direction mgl.Vec3, color mgl.Vec3, solidAngle float32) {
+	if direction.Len() == 0.0 {
+		return
+	}
+	basis := shBasis(direction.Normalize())
+	scale := solidAngle / math.Pi
+	for i := range sh {
+		sh[i] = sh[i].Add(color.Mul(basis[i] * scale))
+	}
+}
+
+// AddAmbient adds light that is the same from every direction.
+func (sh *SphericalHarmonics) AddAmbient(color mgl.Vec3) {
+	sh[0] = sh[0].Add(color.Mul(1.0 / 0.886227))
+}
+
+// AddHemisphere adds the light of a HemisphereAmbient with the given
+// colors, already scaled by its intensity. The blend between them is
+// linear in the normal, so it's represented exactly.
+func (sh *SphericalHarmonics) AddHemisphere(sky, ground mgl.Vec3, up mgl.Vec3) {
+	sh.AddAmbient(sky.Add(ground).Mul(0.5))
+	if up.Len() == 0.0 {
+		up = mgl.Vec3{0.0, 1.0, 0.0}
+	}
+	up = up.Normalize()
+	slope := sky.Sub(ground).Mul(0.5 / 1.023327)
+	sh[1] = sh[1].Add(slope.Mul(up[1]))
+	sh[2] = sh[2].Add(slope.Mul(up[2]))
+	sh[3] = sh[3].Add(slope.Mul(up[0]))
+}
+
+// Irradiance returns the light received by a surface with the normal, the
+// same as CalcLightProbe does in the shaders.
+func (sh *SphericalHarmonics) Irradiance(normal mgl.Vec3) mgl.Vec3 {
+	const (
+		c1 = 0.429043
+		c2 = 0.511664
+		c3 = 0.743125
+		c4 = 0.886227
+		c5 = 0.247708
+	)
+	n := normal.Normalize()
+	x, y, z := n[0], n[1], n[2]
+	irradiance := sh[0].Mul(c4).Sub(sh[6].Mul(c5)).
+		Add(sh[3].Mul(2.0 * c2 * x)).Add(sh[1].Mul(2.0 * c2 * y)).Add(sh[2].Mul(2.0 * c2 * z)).
+		Add(sh[6].Mul(c3 * z * z)).
+		Add(sh[8].Mul(c1 * (x*x - y*y))).
+		Add(sh[4].Mul(2.0 * c1 * x * y)).Add(sh[7].Mul(2.0 * c1 * x * z)).Add(sh[5].Mul(2.0 * c1 * y * z))
+	for i := range irradiance {
+		if irradiance[i] < 0.0 {
+			irradiance[i] = 0.0
+		}
+	}
+	return irradiance
+}
+
+// LightProbe is a point in the scene that records the light around it so
+// that moving objects near it can be lit by the baked or captured lighting.
+type LightProbe struct {
+	// Position is the location of the probe in world space.
+	Position mgl.Vec3
+
+	// SH is the light around the probe.
+	SH SphericalHarmonics
+}
+
+// LightProbes is a set of light probes placed around a scene. When set as
+// the renderer's LightProbes, every Renderable drawn with DrawRenderable or
+// DrawRenderableWithShader gets the probes nearest to the center of its
+// bounding box blended together as its ambient light in the shaders that
+// declare the LIGHT_PROBE_* uniforms. This replaces the lights' ambient and
+// the renderer's hemisphere Ambient, which should be baked into the probes.
+type LightProbes struct {
+	// Probes are all of the probes in the scene.
+	Probes []*LightProbe
+
+	// Neighbors is how many of the closest probes are blended together,
+	// weighted by the inverse of their squared distances. At most 8 are used.
+	Neighbors int
+}
+
+// lightProbeNeighbor is a probe being considered for a blend.
+type lightProbeNeighbor struct {
+	probe  *LightProbe
+	distSq float32
+}
+
+// NewLightProbes creates an empty set of light probes that blends the four
+// probes closest to each Renderable.
+func NewLightProbes() *LightProbes {
+	lp := new(LightProbes)
+	lp.Probes = make([]*LightProbe, 0, 16)
+	lp.Neighbors = 4
+	return lp
+}
+
+// Add places a new probe without any light at the position and returns it.
+func (lp *LightProbes) Add(position mgl.Vec3) *LightProbe {
+	probe := new(LightProbe)
+	probe.Position = position
+	lp.Probes = append(lp.Probes, probe)
+	return probe
+}
+
+// Remove removes the probe from the set.
+func (lp *LightProbes) Remove(probe *LightProbe) {
+	for i, other := range lp.Probes {
+		if other == probe {
+			lp.Probes = append(lp.Probes[:i], lp.Probes[i+1:]...)
+			return
+		}
+	}
+}
+
+// Sample blends the probes closest to the position into dest. It returns
+// false, leaving dest cleared, if there are no probes.
+func (lp *LightProbes) Sample(position mgl.Vec3, dest *SphericalHarmonics) bool {
+	dest.Clear()
+	count := lp.Neighbors
+	if count < 1 {
+		count = 1
+	} else if count > maxLightProbeNeighbors {
+		count = maxLightProbeNeighbors
+	}
+
+	// keep the closest probes sorted by distance
+	var nearest [maxLightProbeNeighbors]lightProbeNeighbor
+	found := 0
+	for _, probe := range lp.Probes {
+		if probe == nil {
+			continue
+		}
+		distSq := probe.Position.Sub(position).Dot(probe.Position.Sub(position))
+		if found == count && distSq >= nearest[found-1].distSq {
+			continue
+		}
+		i := found
+		if found < count {
+			found++
+		} else {
+			i = found - 1
+		}
+		for ; i > 0 && nearest[i-1].distSq > distSq; i-- {
+			nearest[i] = nearest[i-1]
+		}
+		nearest[i] = lightProbeNeighbor{probe, distSq}
+	}
+	if found == 0 {
+		return false
+	}
+
+	// a probe right at the position is used as is
+	const epsilon = 0.000001
+	if nearest[0].distSq < epsilon {
+		*dest = nearest[0].probe.SH
+		return true
+	}
+
+	totalWeight := float32(0.0)
+	for i := 0; i < found; i++ {
+		totalWeight += 1.0 / nearest[i].distSq
+	}
+	for i := 0; i < found; i++ {
+		dest.AddScaled(&nearest[i].probe.SH, 1.0/nearest[i].distSq/totalWeight)
+	}
+	return true
+}
+
+// BakeLights sets every probe's light to the direct light of the lights
+// and the ambient light, ignoring shadows, cookies and IES profiles. If the
+// hemisphere ambient isn't enabled, the lights' AmbientIntensity is baked
+// instead. Use CaptureLightProbe to include the light bounced off the scene.
+func (lp *LightProbes) BakeLights(lights []*Light, ambient *HemisphereAmbient) {
+	for _, probe := range lp.Probes {
+		if probe == nil {
+			continue
+		}
+		probe.SH.Clear()
+		if ambient != nil && ambient.IsEnabled() {
+			probe.SH.AddHemisphere(ambient.SkyColor.Vec3().Mul(ambient.Intensity),
+				ambient.GroundColor.Vec3().Mul(ambient.Intensity), ambient.Up)
+		}
+		for _, l := range lights {
+			if l != nil {
+				l.bakeIntoProbe(probe, ambient == nil || !ambient.IsEnabled())
+			}
+		}
+	}
+}
+
+// bakeIntoProbe adds the direct light reaching the probe and, optionally,
+// the light's ambient light.
+func (l *Light) bakeIntoProbe(probe *LightProbe, withAmbient bool) {
+	color := l.DiffuseColor.Vec3()
+	if withAmbient {
+		probe.SH.AddAmbient(color.Mul(l.AmbientIntensity))
+	}
+
+	if l.Direction.Len() > 0.0 && !l.IsSpot() && !l.IsArea() {
+		probe.SH.AddLight(l.Direction.Mul(-1.0), color.Mul(l.DiffuseIntensity))
+		return
+	}
+
+	toLight := l.Position.Sub(probe.Position)
+	dist := toLight.Len()
+	if dist == 0.0 {
+		return
+	}
+	strength := l.DiffuseIntensity * l.attenuationAt(dist)
+	if l.IsSpot() && l.Direction.Len() > 0.0 {
+		innerCos, outerCos := l.spotCutoff()
+		cosAngle := toLight.Mul(-1.0 / dist).Dot(l.Direction.Normalize())
+		t := float32(1.0)
+		if innerCos > outerCos {
+			t = mgl.Clamp((cosAngle-outerCos)/(innerCos-outerCos), 0.0, 1.0)
+		} else if cosAngle < outerCos {
+			t = 0.0
+		}
+		strength *= t * t * (3.0 - 2.0*t)
+	}
+	probe.SH.AddLight(toLight, color.Mul(strength))
+}
+
+// LightProbeDrawFunc draws the scene for one face of a light probe capture
+// with the given projection, view and camera.
+type LightProbeDrawFunc func(perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
+
+// probeCamera is the camera of a light probe capture face.
+type probeCamera struct {
+	view     mgl.Mat4
+	position mgl.Vec3
+}
+
+func (c *probeCamera) GetViewMatrix() mgl.Mat4 { return c.view }
+func (c *probeCamera) GetPosition() mgl.Vec3   { return c.position }
+
+// CaptureLightProbe sets the probe's light to what's seen from its position
+// by drawing the scene into the six faces of a cube, each size pixels wide,
+// with drawFn and projecting the colors onto the probe's harmonics. The
+// faces are cleared to black, so drawFn should draw a sky if there is one.
+// The renderer's viewport is restored afterwards.
+func (fr *ForwardRenderer) CaptureLightProbe(probe *LightProbe, size int32, near, far float32, drawFn LightProbeDrawFunc) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid light probe capture size %d", size)
+	}
+	if near <= 0.0 || far <= near {
+		return fmt.Errorf("invalid light probe capture depth range %f to %f", near, far)
+	}
+
+	gfx := fr.gfx
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA32F, size, size, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	defer gfx.DeleteTexture(tex)
+
+	depthRB := gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH_COMPONENT24, size, size)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	defer gfx.DeleteRenderbuffer(depthRB)
+
+	fbo := gfx.GenFramebuffer()
+	defer gfx.DeleteFramebuffer(fbo)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, fbo)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, tex, 0)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.RENDERBUFFER, depthRB)
+	status := gfx.CheckFramebufferStatus(graphics.FRAMEBUFFER)
+	if status != graphics.FRAMEBUFFER_COMPLETE {
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+		return fmt.Errorf("failed to create the light probe capture framebuffer (status 0x%x)", uint32(status))
+	}
+
+	var sh SphericalHarmonics
+	pixels := make([]float32, size*size*4)
+	perspective := mgl.Perspective(mgl.DegToRad(90.0), 1.0, near, far)
+	camera := &probeCamera{position: probe.Position}
+	gfx.Viewport(0, 0, size, size)
+	gfx.ClearColor(0.0, 0.0, 0.0, 1.0)
+	for face := 0; face < ShadowCubeFaces; face++ {
+		forward := shadowCubeDirections[face]
+		right := forward.Cross(shadowCubeUps[face]).Normalize()
+		up := right.Cross(forward)
+		camera.view = mgl.LookAtV(probe.Position, probe.Position.Add(forward), shadowCubeUps[face])
+
+		gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
+		drawFn(perspective, camera.view, camera)
+		gfx.ReadPixels(0, 0, size, size, graphics.RGBA, graphics.FLOAT, gfx.Ptr(pixels))
+
+		// rows start at the bottom of the face; each texel covers a solid
+		// angle that shrinks towards the corners of the face
+		for y := int32(0); y < size; y++ {
+			v := (float32(y)+0.5)/float32(size)*2.0 - 1.0
+			for x := int32(0); x < size; x++ {
+				u := (float32(x)+0.5)/float32(size)*2.0 - 1.0
+				dir := forward.Add(right.Mul(u)).Add(up.Mul(v))
+				d2 := u*u + v*v + 1.0
+				solidAngle := 4.0 / float32(size*size) / (d2 * float32(math.Sqrt(float64(d2))))
+				i := (y*size + x) * 4
+				sh.AddRadiance(dir, mgl.Vec3{pixels[i], pixels[i+1], pixels[i+2]}, solidAngle)
+			}
+		}
+	}
+	probe.SH = sh
+
+	// restore the default framebuffer and the renderer's viewport
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	width, height := fr.GetResolution()
+	gfx.Viewport(0, 0, width, height)
+	return fizzle.CheckGraphicsError(gfx, "capturing the light probe")
+}
+
+// bindLightProbes binds the blend of the light probes nearest to the
+// Renderable. The additive passes of a draw split over many lights get no
+// probe light since the first pass already added it.
+func (fr *ForwardRenderer) bindLightProbes(r *fizzle.Renderable, shader *fizzle.RenderShader) {
+	shaderProbeEnabled := shader.GetUniformLocation("LIGHT_PROBE_ENABLED")
+	if shaderProbeEnabled < 0 {
+		return
+	}
+
+	gfx := fr.gfx
+	if fr.LightProbes == nil || r == nil {
+		gfx.Uniform1i(shaderProbeEnabled, 0)
+		return
+	}
+	var sh SphericalHarmonics
+	center, _ := worldBoundingSphere(r)
+	if !fr.LightProbes.Sample(center, &sh) {
+		gfx.Uniform1i(shaderProbeEnabled, 0)
+		return
+	}
+	if fr.lightPasses.pass > 0 {
+		sh.Clear()
+	}
+
+	gfx.Uniform1i(shaderProbeEnabled, 1)
+	for i, name := range lightProbeUniformNames {
+		if loc := shader.GetUniformLocation(name); loc >= 0 {
+			gfx.Uniform3f(loc, sh[i][0], sh[i][1], sh[i][2])
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableOIT makes FlushQueue draw the transparent Renderables with weighted
+// blended order independent transparency instead of sorting them, for
+// scenes with intersecting glass or dense particles where sorting by
+// center fails. Their shaders need the OIT_ENABLED uniform and its second
+// output, like the diffuse shaders in the examples. PostProcess and MSAA
+// should be set up before OIT is enabled since the depth format has to
+// match the scene framebuffer's.
+func (fr *ForwardRenderer) EnableOIT() error {
+	if fr.oit != nil {
+		return nil
+	}
+
+	var depthFormat graphics.Enum = graphics.DEPTH24_STENCIL8
+	if fr.PostProcess != nil || fr.msaa != nil {
+		depthFormat = graphics.DEPTH_COMPONENT24
+	}
+	oit, err := renderer.NewOIT(fr.gfx, fr.width, fr.height, depthFormat)
+	if err != nil {
+		return err
+	}
+	fr.oit = oit
+	fr.resizables.Register(oit)
+	return nil
+}
+
+// DisableOIT releases the accumulation targets; transparent Renderables are
+// sorted back to front again afterwards.
+func (fr *ForwardRenderer) DisableOIT() {
+	if fr.oit == nil {
+		return
+	}
+	fr.resizables.Unregister(fr.oit)
+	fr.oit.Destroy()
+	fr.oit = nil
+}
+
+// GetOIT returns the order independent transparency or nil if it isn't
+// enabled.
+func (fr *ForwardRenderer) GetOIT() *renderer.OIT {
+	return fr.oit
+}
+
+// sceneFramebuffer returns the framebuffer the scene is drawn into between
+// BeginScene and EndScene.
+func (fr *ForwardRenderer) sceneFramebuffer() graphics.Buffer {
+	if fr.msaa != nil {
+		return fr.msaa.Framebuffer()
+	}
+	if fr.PostProcess != nil {
+		return fr.PostProcess.Framebuffer()
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// MinColorTemperature is the lowest temperature, in Kelvin, that
+	// KelvinToRGB converts; lower temperatures are clamped to it.
+	MinColorTemperature = 1667.0
+
+	// MaxColorTemperature is the highest temperature, in Kelvin, that
+	// KelvinToRGB converts; higher temperatures are clamped to it.
+	MaxColorTemperature = 25000.0
+)
+
+// ReferenceIlluminance is the illuminance, in lux, that the photometric
+// helpers turn into a DiffuseIntensity of 1.0, which lights a white surface
+// facing the light fully white. The default of 1000 lux suits brightly lit
+// interiors; raise it for daylight scenes, where direct sunlight is around
+// 100000 lux, so that the lights stay within the range the shaders expect.
+var ReferenceIlluminance float32 = 1000.0
+
+// KelvinToRGB returns the linear RGB color of a black body at the
+// temperature in Kelvin, scaled so that its largest component is 1.0.
+// Candle flames are around 1900K, incandescent bulbs 2700K, noon daylight
+// 5500K and overcast skies 6500K and above.
+func KelvinToRGB(kelvin float32) mgl.Vec3 {
+	t := float64(mgl.Clamp(kelvin, MinColorTemperature, MaxColorTemperature))
+
+	// chromaticity of the Planckian locus using the cubic spline of
+	// Kim et al. from their US patent 7024034
+	var x, y float64
+	if t <= 4000.0 {
+		x = -0.2661239e9/(t*t*t) - 0.2343589e6/(t*t) + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/(t*t*t) + 2.1070379e6/(t*t) + 0.2226347e3/t + 0.240390
+	}
+	switch {
+	case t <= 2222.0:
+		y = -1.1063814*x*x*x - 1.34811020*x*x + 2.18555832*x - 0.20219683
+	case t <= 4000.0:
+		y = -0.9549476*x*x*x - 1.37418593*x*x + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x*x*x - 5.87338670*x*x + 3.75112997*x - 0.37001483
+	}
+
+	// convert the xyY color with a luminance of 1 to linear sRGB
+	bigX := x / y
+	bigZ := (1.0 - x - y) / y
+	rgb := mgl.Vec3{
+		float32(3.2404542*bigX - 1.5371385 - 0.4985314*bigZ),
+		float32(-0.9692660*bigX + 1.8760108 + 0.0415560*bigZ),
+		float32(0.0556434*bigX - 0.2040259 + 1.0572252*bigZ),
+	}
+
+	largest := float32(0.0)
+	for i := range rgb {
+		if rgb[i] < 0.0 {
+			rgb[i] = 0.0
+		}
+		if rgb[i] > largest {
+			largest = rgb[i]
+		}
+	}
+	return rgb.Mul(1.0 / largest)
+}
+
+// LuxToIntensity returns the DiffuseIntensity of a directional light with
+// the illuminance in lux.
+func LuxToIntensity(lux float32) float32 {
+	return lux / ReferenceIlluminance
+}
+
+// CandelaToIntensity returns the DiffuseIntensity of a point or spot light
+// with the luminous intensity in candela when its attenuation falls off
+// with the inverse square of the distance, as SetLuminousIntensity sets up.
+// A candela lights a surface one unit away with one lux.
+func CandelaToIntensity(candela float32) float32 {
+	return candela / ReferenceIlluminance
+}
+
+// SetColorTemperature sets the light's DiffuseColor to the color of a black
+// body at the temperature in Kelvin; see KelvinToRGB.
+func (l *Light) SetColorTemperature(kelvin float32) {
+	rgb := KelvinToRGB(kelvin)
+	l.DiffuseColor = mgl.Vec4{rgb[0], rgb[1], rgb[2], 1.0}
+}
+
+// SetIlluminance sets the DiffuseIntensity of a directional light from the
+// illuminance in lux that it casts on a surface facing it.
+func (l *Light) SetIlluminance(lux float32) {
+	l.DiffuseIntensity = LuxToIntensity(lux)
+}
+
+// SetLuminousIntensity sets the DiffuseIntensity of a point or spot light
+// from the luminous intensity in candela and makes its attenuation fall off
+// with the inverse square of the distance, in world units taken as meters.
+// The Range of the light is kept.
+func (l *Light) SetLuminousIntensity(candela float32) {
+	l.ConstantAttenuation = 0.0
+	l.LinearAttenuation = 0.0
+	l.Attenuation = 1.0
+	l.DiffuseIntensity = CandelaToIntensity(candela)
+}
+
+// SetLuminousFlux sets the DiffuseIntensity of a point, spot or area light
+// from the total light it emits in lumens, like the rating of a bulb, with
+// inverse square attenuation as in SetLuminousIntensity. A point light
+// spreads the flux over the whole sphere and a spot light over its outer
+// cone, so narrowing the cone makes it brighter. An area light is treated
+// as a diffuse emitter facing Direction. Call SetSpot or SetArea first.
+func (l *Light) SetLuminousFlux(lumens float32) {
+	var candela float32
+	switch {
+	case l.IsArea():
+		candela = lumens / math.Pi
+	case l.IsSpot():
+		_, outerCos := l.spotCutoff()
+		candela = lumens / (2.0 * math.Pi * (1.0 - outerCos))
+	default:
+		candela = lumens / (4.0 * math.Pi)
+	}
+	l.SetLuminousIntensity(candela)
+}
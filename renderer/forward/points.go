@@ -0,0 +1,160 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// PointSprite selects the shape of the points drawn by DrawPoints.
+type PointSprite int
+
+const (
+	// PointSquare draws every point as a solid square.
+	PointSquare PointSprite = iota
+
+	// PointRound discards the corners of the square to draw a disc.
+	PointRound
+
+	// PointSoft draws a disc whose alpha falls off towards the edge. The
+	// points are alpha blended and don't write to the depth buffer.
+	PointSoft
+)
+
+var (
+	// PointVertShader330 is the GLSL vertex shader for DrawPoints. Each point
+	// is colored by its VERTEX_COLOR, if the Renderable has vertex colors,
+	// multiplied by the material's diffuse color.
+	PointVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  uniform mat4 MV_MATRIX;
+  uniform vec4 MATERIAL_DIFFUSE;
+  uniform float POINT_SIZE;
+  uniform float POINT_SCALE;
+  uniform int POINT_VERTEX_COLORS;
+  in vec3 VERTEX_POSITION;
+  in vec4 VERTEX_COLOR;
+
+  out vec4 vs_color;
+
+  void main()
+  {
+    vs_color = MATERIAL_DIFFUSE;
+    if (POINT_VERTEX_COLORS != 0) {
+      vs_color *= VERTEX_COLOR;
+    }
+
+    // a POINT_SCALE of zero keeps the size constant in pixels
+    float size = POINT_SIZE;
+    if (POINT_SCALE > 0.0) {
+      vec4 view_pos = MV_MATRIX * vec4(VERTEX_POSITION, 1.0);
+      size *= POINT_SCALE / max(-view_pos.z, 0.0001);
+    }
+    gl_PointSize = max(size, 1.0);
+    gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+  }`
+
+	// PointFragShader330 is the GLSL fragment shader for DrawPoints that
+	// shapes the point sprite according to POINT_SPRITE.
+	PointFragShader330 = `#version 330
+  uniform int POINT_SPRITE;
+
+  in vec4 vs_color;
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec4 color = vs_color;
+    if (POINT_SPRITE != 0) {
+      float dist = length(gl_PointCoord - vec2(0.5)) * 2.0;
+      if (dist > 1.0) {
+        discard;
+      }
+      if (POINT_SPRITE == 2) {
+        color.a *= 1.0 - smoothstep(0.0, 1.0, dist);
+      }
+    }
+    frag_color = color;
+  }`
+)
+
+// PointStyle controls the size and shape of the points drawn with DrawPoints.
+type PointStyle struct {
+	// Size is the diameter of the points in pixels; a size below one
+	// draws one pixel points.
+	Size float32
+
+	// Scale, if greater than zero, makes Size shrink with distance so that
+	// a point of the given Size is that many pixels wide at a view depth
+	// of Scale units.
+	Scale float32
+
+	// Sprite is the shape of the points.
+	Sprite PointSprite
+}
+
+// bind sets the POINT_* uniforms of the shader.
+func (ps *PointStyle) bind(gfx graphics.GraphicsProvider, r *fizzle.Renderable, shader *fizzle.RenderShader) {
+	if loc := shader.GetUniformLocation("POINT_SIZE"); loc >= 0 {
+		gfx.Uniform1f(loc, ps.Size)
+	}
+	if loc := shader.GetUniformLocation("POINT_SCALE"); loc >= 0 {
+		gfx.Uniform1f(loc, ps.Scale)
+	}
+	if loc := shader.GetUniformLocation("POINT_SPRITE"); loc >= 0 {
+		gfx.Uniform1i(loc, int32(ps.Sprite))
+	}
+	if loc := shader.GetUniformLocation("POINT_VERTEX_COLORS"); loc >= 0 {
+		if r.Core.ColorVBO != 0 {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+}
+
+// DrawPoints draws the vertices of the Renderable as points using
+// graphics.POINTS mode, such as a point cloud made with fizzle.CreatePointCloud.
+// The shader, usually compiled from PointVertShader330 and PointFragShader330,
+// sets gl_PointSize and receives the renderer's Points style.
+func (fr *ForwardRenderer) DrawPoints(r *fizzle.Renderable, shader *fizzle.RenderShader, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	gfx := fr.gfx
+	gfx.Enable(graphics.PROGRAM_POINT_SIZE)
+	if fr.Points.Sprite == PointSoft {
+		gfx.Enable(graphics.BLEND)
+		gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE_MINUS_SRC_ALPHA)
+		gfx.DepthMask(false)
+	}
+
+	fr.drawPoints(r, shader, binder, perspective, view, camera)
+
+	if fr.Points.Sprite == PointSoft {
+		gfx.DepthMask(true)
+		gfx.Disable(graphics.BLEND)
+	}
+	gfx.Disable(graphics.PROGRAM_POINT_SIZE)
+}
+
+// drawPoints draws the Renderable and its children for DrawPoints.
+func (fr *ForwardRenderer) drawPoints(r *fizzle.Renderable, shader *fizzle.RenderShader, binder renderer.RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	// only draw visible nodes
+	if !fr.isDrawn(r) {
+		return
+	}
+
+	// if the renderable is a group, just try to draw the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			fr.drawPoints(child, shader, binder, perspective, view, camera)
+		}
+		return
+	}
+
+	renderer.BindAndDrawRef(fr, r, shader, fr.getBinders(binder), &perspective, &view, camera, graphics.POINTS)
+}
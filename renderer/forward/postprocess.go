@@ -0,0 +1,47 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// BeginScene starts the scene pass. If MSAA is enabled, its multisampled
+// framebuffer is bound, and otherwise, if PostProcess is set, its scene
+// framebuffer is bound so that everything drawn until EndScene goes
+// through the effects.
+func (fr *ForwardRenderer) BeginScene() {
+	if fr.msaa != nil {
+		fr.msaa.Output = 0
+		if fr.PostProcess != nil {
+			fr.msaa.Output = fr.PostProcess.Framebuffer()
+		}
+		fr.msaa.Begin()
+		return
+	}
+	if fr.PostProcess == nil {
+		return
+	}
+	fr.PostProcess.Begin()
+}
+
+// EndScene finishes the scene pass, resolving the samples if MSAA is
+// enabled, and, if PostProcess is set, applies its effects to the scene,
+// writing the result to its Output framebuffer. The perspective, view and
+// camera should be the ones the scene was drawn with.
+func (fr *ForwardRenderer) EndScene(perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	if fr.msaa != nil {
+		fr.Profiler.Begin("msaa resolve")
+		fr.msaa.End()
+		fr.Profiler.End()
+	}
+	if fr.PostProcess == nil {
+		return
+	}
+
+	fr.Profiler.Begin("post process")
+	fr.PostProcess.End(fr, perspective, view, camera)
+	fr.Profiler.End()
+}
@@ -0,0 +1,236 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// MaxForwardProjectors is the maximum amount of projectors supported by this renderer.
+	MaxForwardProjectors = 2
+)
+
+// ProjectorBlend selects how the projected texture is combined with the
+// color of the receiving surface.
+type ProjectorBlend int
+
+const (
+	// ProjectorAdd adds the projected color, like a flashlight pattern.
+	ProjectorAdd ProjectorBlend = iota
+
+	// ProjectorMultiply multiplies the surface color by the projected color,
+	// like a blob shadow.
+	ProjectorMultiply
+
+	// ProjectorMix blends the projected color over the surface using its
+	// alpha, like a video projected onto a wall.
+	ProjectorMix
+)
+
+var (
+	// projectorUniformNames caches the per-projector uniform names so that
+	// binding the projectors doesn't format new strings for every Renderable drawn.
+	projectorUniformNames [MaxForwardProjectors]projectorUniforms
+
+	// ProjectorShaderFunctions330 is the GLSL for the ApplyProjectors function
+	// used by the built-in diffuse_projected shader. Custom shaders can include
+	// it after their declarations to receive the projectors the renderer binds
+	// through the PROJECTOR_* uniforms.
+	ProjectorShaderFunctions330 = `
+  uniform mat4 PROJECTOR_MATRIX[2];
+  uniform vec3 PROJECTOR_DIRECTION[2];
+  uniform vec4 PROJECTOR_COLOR[2];
+  uniform int PROJECTOR_BLEND[2];
+  uniform sampler2D PROJECTOR_TEX[2];
+  uniform int PROJECTOR_COUNT;
+
+  vec4 ApplyProjector(vec4 color, vec4 proj_coord, vec3 dir, vec4 tint, int blend, sampler2D tex, vec3 n_world)
+  {
+    // skip surfaces behind the projector or facing away from it
+    if (proj_coord.w <= 0.0 || dot(n_world, -dir) <= 0.0) {
+      return color;
+    }
+    vec3 uvw = proj_coord.xyz / proj_coord.w;
+    if (any(lessThan(uvw, vec3(0.0))) || any(greaterThan(uvw, vec3(1.0)))) {
+      return color;
+    }
+
+    vec4 projected = texture(tex, uvw.xy) * tint;
+    if (blend == 0) {
+      return vec4(color.rgb + projected.rgb * projected.a, color.a);
+    } else if (blend == 1) {
+      return vec4(color.rgb * mix(vec3(1.0), projected.rgb, projected.a), color.a);
+    }
+    return vec4(mix(color.rgb, projected.rgb, projected.a), color.a);
+  }
+
+  vec4 ApplyProjectors(vec4 color, vec3 world_pos, vec3 n_world)
+  {
+    /* unrolled since indexing sampler arrays in a loop can be problematic */
+    if (PROJECTOR_COUNT > 0) {
+      color = ApplyProjector(color, PROJECTOR_MATRIX[0] * vec4(world_pos, 1.0), PROJECTOR_DIRECTION[0],
+        PROJECTOR_COLOR[0], PROJECTOR_BLEND[0], PROJECTOR_TEX[0], n_world);
+    }
+    if (PROJECTOR_COUNT > 1) {
+      color = ApplyProjector(color, PROJECTOR_MATRIX[1] * vec4(world_pos, 1.0), PROJECTOR_DIRECTION[1],
+        PROJECTOR_COLOR[1], PROJECTOR_BLEND[1], PROJECTOR_TEX[1], n_world);
+    }
+    return color;
+  }
+`
+)
+
+// projectorUniforms are the names of the shader uniforms for one projector slot.
+type projectorUniforms struct {
+	matrix    string
+	direction string
+	color     string
+	blend     string
+	texture   string
+}
+
+func init() {
+	for i := range projectorUniformNames {
+		projectorUniformNames[i] = projectorUniforms{
+			matrix:    fmt.Sprintf("PROJECTOR_MATRIX[%d]", i),
+			direction: fmt.Sprintf("PROJECTOR_DIRECTION[%d]", i),
+			color:     fmt.Sprintf("PROJECTOR_COLOR[%d]", i),
+			blend:     fmt.Sprintf("PROJECTOR_BLEND[%d]", i),
+			texture:   fmt.Sprintf("PROJECTOR_TEX[%d]", i),
+		}
+	}
+}
+
+// Projector projects a texture along a frustum onto the surfaces drawn with
+// shaders that receive projectors, such as the diffuse_projected shader.
+type Projector struct {
+	// Position is the location of the projector in world space
+	Position mgl.Vec3
+
+	// Direction is the direction the projector points in
+	Direction mgl.Vec3
+
+	// Up defines the Up vector for the projection. Defaults to {0,1,0}
+	Up mgl.Vec3
+
+	// Texture is the texture that gets projected. It should use
+	// CLAMP_TO_EDGE wrapping so that the borders don't bleed.
+	Texture graphics.Texture
+
+	// Color tints the projected texture; the alpha scales its strength.
+	Color mgl.Vec4
+
+	// Blend selects how the projection is combined with the surface color.
+	Blend ProjectorBlend
+
+	// Projection is the projection transformation matrix for the projector
+	Projection mgl.Mat4
+
+	// View is the view transformation matrix for the projector.
+	// Updated with Update().
+	View mgl.Mat4
+
+	// BiasedMatrix maps world space to the texture space of the projector.
+	// Updated with Update().
+	BiasedMatrix mgl.Mat4
+}
+
+// NewProjector creates a projector with a perspective frustum, which suits
+// flashlight patterns and video projection. The field of view is in degrees.
+func NewProjector(tex graphics.Texture, fovy, aspect, near, far float32) *Projector {
+	p := new(Projector)
+	p.Texture = tex
+	p.Direction = mgl.Vec3{0.0, 0.0, -1.0}
+	p.Up = mgl.Vec3{0.0, 1.0, 0.0}
+	p.Color = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
+	p.Projection = mgl.Perspective(mgl.DegToRad(fovy), aspect, near, far)
+	p.Update()
+	return p
+}
+
+// NewOrthoProjector creates a projector with a box shaped frustum, which
+// suits blob shadows cast straight down.
+func NewOrthoProjector(tex graphics.Texture, width, height, near, far float32) *Projector {
+	p := new(Projector)
+	p.Texture = tex
+	p.Direction = mgl.Vec3{0.0, -1.0, 0.0}
+	p.Up = mgl.Vec3{0.0, 0.0, -1.0}
+	p.Color = mgl.Vec4{1.0, 1.0, 1.0, 1.0}
+	p.Blend = ProjectorMultiply
+	p.Projection = mgl.Ortho(-width*0.5, width*0.5, -height*0.5, height*0.5, near, far)
+	p.Update()
+	return p
+}
+
+// Update recalculates the view and biased matrixes after the position,
+// direction or projection changes.
+func (p *Projector) Update() {
+	target := p.Position.Add(p.Direction)
+	p.View = mgl.LookAtV(p.Position, target, p.Up)
+	p.BiasedMatrix = shadowBiasMat.Mul4(p.Projection.Mul4(p.View))
+}
+
+// GetActiveProjectorCount counts the number of *Projector set in
+// the ForwardRenderer's ActiveProjectors array until a nil is hit.
+func (fr *ForwardRenderer) GetActiveProjectorCount() int {
+	for i := 0; i < MaxForwardProjectors; i++ {
+		if fr.ActiveProjectors[i] == nil {
+			return i
+		}
+	}
+	return MaxForwardProjectors
+}
+
+// bindProjectors sets the PROJECTOR_* uniforms of the shader. Every sampler
+// slot the shader declares gets a texture bound, even if that's 0, so that
+// drivers don't complain about unbound samplers.
+func (fr *ForwardRenderer) bindProjectors(shader *fizzle.RenderShader, texturesBound *int32) {
+	shaderProjectorCount := shader.GetUniformLocation("PROJECTOR_COUNT")
+	if shaderProjectorCount < 0 {
+		return
+	}
+
+	gfx := fr.gfx
+	projectorCount := fr.GetActiveProjectorCount()
+	gfx.Uniform1i(shaderProjectorCount, int32(projectorCount))
+
+	for i := 0; i < MaxForwardProjectors; i++ {
+		p := fr.ActiveProjectors[i]
+		names := &projectorUniformNames[i]
+
+		shaderProjectorTex := shader.GetUniformLocation(names.texture)
+		if shaderProjectorTex >= 0 {
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			if i < projectorCount {
+				gfx.BindTexture(graphics.TEXTURE_2D, p.Texture)
+			} else {
+				gfx.BindTexture(graphics.TEXTURE_2D, 0)
+			}
+			gfx.Uniform1i(shaderProjectorTex, *texturesBound)
+			*texturesBound++
+		}
+
+		if i >= projectorCount {
+			continue
+		}
+
+		if loc := shader.GetUniformLocation(names.matrix); loc >= 0 {
+			gfx.UniformMatrix4fv(loc, 1, false, &p.BiasedMatrix)
+		}
+		if loc := shader.GetUniformLocation(names.direction); loc >= 0 {
+			gfx.Uniform3f(loc, p.Direction[0], p.Direction[1], p.Direction[2])
+		}
+		if loc := shader.GetUniformLocation(names.color); loc >= 0 {
+			gfx.Uniform4f(loc, p.Color[0], p.Color[1], p.Color[2], p.Color[3])
+		}
+		if loc := shader.GetUniformLocation(names.blend); loc >= 0 {
+			gfx.Uniform1i(loc, int32(p.Blend))
+		}
+	}
+}
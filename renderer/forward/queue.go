@@ -0,0 +1,80 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// BeginQueue starts the queued mode: until the next FlushQueue,
+// DrawRenderable records the Renderables in the render queue instead of
+// drawing them right away, so that the draws of the frame are sorted by
+// their sort keys and the shader and texture changes are minimized
+// without changing the drawing code. DrawRenderableWithShader still draws
+// right away since the renderer's own full screen passes go through it;
+// SubmitWithShader queues those draws.
+//
+// The queued draws are drawn with the matrices and camera that are passed
+// to FlushQueue, so the shadow maps should be drawn before BeginQueue.
+func (fr *ForwardRenderer) BeginQueue() {
+	fr.queueDraws = true
+}
+
+// Submit queues the Renderable to be drawn with its own shader by the next
+// FlushQueue instead of drawing it right away.
+func (fr *ForwardRenderer) Submit(r *fizzle.Renderable, binder renderer.RenderBinder) {
+	fr.SubmitWithShader(r, nil, binder)
+}
+
+// SubmitWithShader queues the Renderable to be drawn with a different
+// shader than what is set in the Renderable by the next FlushQueue.
+func (fr *ForwardRenderer) SubmitWithShader(r *fizzle.Renderable, shader *fizzle.RenderShader, binder renderer.RenderBinder) {
+	if fr.queue == nil {
+		fr.queue = renderer.NewRenderQueue()
+	}
+	fr.queue.Submit(r, shader, binder)
+}
+
+// FlushQueue draws everything submitted since the last flush, ending the
+// queued mode started by BeginQueue: the opaque Renderables sorted by
+// shader, material and then front to back, with the depth prepass if
+// DepthPrepass is set, and then the Renderables with a Transparent core
+// back to front with alpha blending, or in any order with OIT if it's
+// enabled. The queue is empty afterwards.
+func (fr *ForwardRenderer) FlushQueue(perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	fr.queueDraws = false
+	if fr.queue == nil || fr.queue.Len() == 0 {
+		return
+	}
+
+	fr.Profiler.Begin("render queue")
+	if fr.FrustumCulling {
+		before := fr.queue.Len()
+		fr.queue.Cull(fr.getCullFrustum(perspective.Mul4(view)))
+		fr.culledCount += before - fr.queue.Len()
+	}
+	fr.queue.Sort(view)
+	fr.Profiler.Begin("opaque")
+	fr.DrawOpaqueScene(func() {
+		fr.queue.DrawOpaque(fr, perspective, view, camera)
+	})
+	fr.Profiler.End()
+	fr.Profiler.Begin("transparent")
+	fr.transparentPass = true
+	// the accumulation targets match the scene, not the render targets
+	if fr.oit != nil && fr.renderTarget == nil {
+		fr.oit.Output = fr.sceneFramebuffer()
+		fr.oitPass = true
+		fr.queue.DrawTransparentWithOIT(fr, fr.oit, perspective, view, camera)
+		fr.oitPass = false
+	} else {
+		fr.queue.DrawTransparent(fr, perspective, view, camera)
+	}
+	fr.transparentPass = false
+	fr.Profiler.End()
+	fr.queue.Reset()
+	fr.Profiler.End()
+}
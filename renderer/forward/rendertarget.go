@@ -0,0 +1,41 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// SetRenderTarget makes the render target the active one: it's bound with
+// its viewport and cleared, and everything drawn until ClearRenderTarget,
+// or until another target is set, goes into its textures. Offscreen views
+// should be drawn before BeginScene. Passing nil is the same as calling
+// ClearRenderTarget.
+func (fr *ForwardRenderer) SetRenderTarget(rt *renderer.RenderTarget) {
+	if rt == nil {
+		fr.ClearRenderTarget()
+		return
+	}
+	fr.renderTarget = rt
+	rt.Begin()
+	rt.Clear()
+}
+
+// ClearRenderTarget stops drawing into the active render target and binds
+// the window again with the renderer's resolution as the viewport.
+func (fr *ForwardRenderer) ClearRenderTarget() {
+	if fr.renderTarget == nil {
+		return
+	}
+	fr.renderTarget = nil
+	fr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	fr.gfx.Viewport(0, 0, fr.width, fr.height)
+}
+
+// GetRenderTarget returns the active render target or nil if the renderer
+// is drawing to the window.
+func (fr *ForwardRenderer) GetRenderTarget() *renderer.RenderTarget {
+	return fr.renderTarget
+}
@@ -0,0 +1,258 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// ShadowAtlas is one large depth texture split into square tiles that the
+// shadow maps of several lights render into. The shadow framebuffer keeps
+// the atlas attached while moving between its tiles and the shaders sample
+// every tile through a single texture unit. Shadow cubemaps and variance or
+// exponential shadow maps can't use an atlas.
+type ShadowAtlas struct {
+	// Texture is the depth texture holding all of the tiles.
+	Texture graphics.Texture
+
+	// Size is the width and height of Texture.
+	Size int32
+
+	// TileSize is the width and height of each tile.
+	TileSize int32
+
+	// tiles holds the shadow map using each tile, or nil for free tiles,
+	// row by row from the bottom left corner
+	tiles []*ShadowMap
+
+	// owner is the owning renderer
+	owner *ForwardRenderer
+}
+
+// NewShadowAtlas creates a shadow atlas with a depth texture of the size
+// split into tiles of tileSize, which has to divide it evenly. An error is
+// returned if the sizes are invalid or the texture couldn't be created.
+func (fr *ForwardRenderer) NewShadowAtlas(size int32, tileSize int32) (*ShadowAtlas, error) {
+	if tileSize <= 0 || size < tileSize || size%tileSize != 0 {
+		return nil, fmt.Errorf("invalid shadow atlas size %d for tiles of %d", size, tileSize)
+	}
+
+	atlas := new(ShadowAtlas)
+	atlas.owner = fr
+	atlas.Size = size
+	atlas.TileSize = tileSize
+	tilesPerRow := size / tileSize
+	atlas.tiles = make([]*ShadowMap, tilesPerRow*tilesPerRow)
+
+	tex, err := createShadowDepthTexture(fr.gfx, size)
+	if err != nil {
+		fr.gfx.DeleteTexture(tex)
+		return nil, err
+	}
+	atlas.Texture = tex
+	return atlas, nil
+}
+
+// Destroy deletes the atlas texture. Shadow maps still using the atlas are
+// left without a texture and should be destroyed or moved out of it first.
+func (atlas *ShadowAtlas) Destroy() {
+	atlas.owner.GetGraphics().DeleteTexture(atlas.Texture)
+	for i, shady := range atlas.tiles {
+		if shady != nil {
+			shady.atlas = nil
+			shady.Texture = 0
+			atlas.tiles[i] = nil
+		}
+	}
+}
+
+// TileCount returns the number of tiles in the atlas.
+func (atlas *ShadowAtlas) TileCount() int {
+	return len(atlas.tiles)
+}
+
+// FreeTileCount returns the number of tiles not used by a shadow map.
+func (atlas *ShadowAtlas) FreeTileCount() int {
+	free := 0
+	for _, shady := range atlas.tiles {
+		if shady == nil {
+			free++
+		}
+	}
+	return free
+}
+
+// tileRect returns the corner and size, in texels, of the tile.
+func (atlas *ShadowAtlas) tileRect(tile int) (int32, int32, int32) {
+	tilesPerRow := int(atlas.Size / atlas.TileSize)
+	x := int32(tile%tilesPerRow) * atlas.TileSize
+	y := int32(tile/tilesPerRow) * atlas.TileSize
+	return x, y, atlas.TileSize
+}
+
+// UseAtlas moves the shadow map into a free tile of the atlas, deleting its
+// own texture, so that its TextureSize becomes the atlas' TileSize. Passing
+// nil moves it out of its atlas into a new texture of the tile's size. An
+// error is returned if the atlas has no free tiles, the shadow map is a
+// variance or exponential shadow map or its new texture couldn't be created.
+func (shady *ShadowMap) UseAtlas(atlas *ShadowAtlas) error {
+	if shady.atlas == atlas {
+		return nil
+	}
+	if atlas != nil {
+		if shady.filtered() {
+			return fmt.Errorf("filtered shadow maps can't be placed in a shadow atlas")
+		}
+		tile := -1
+		for i, user := range atlas.tiles {
+			if user == nil {
+				tile = i
+				break
+			}
+		}
+		if tile < 0 {
+			return fmt.Errorf("the shadow atlas has no free tiles")
+		}
+
+		shady.Destroy()
+		atlas.tiles[tile] = shady
+		shady.atlas = atlas
+		shady.atlasTile = tile
+		shady.Texture = atlas.Texture
+		shady.TextureSize = atlas.TileSize
+		shady.framebufferChecked = false
+		shady.updateSamplerMatrix()
+		return nil
+	}
+
+	shady.Destroy()
+	shady.Texture = 0
+	shady.framebufferChecked = false
+	err := shady.createDepthTexture()
+	shady.updateSamplerMatrix()
+	return err
+}
+
+// Atlas returns the shadow atlas the shadow map renders into or nil if it
+// has its own texture.
+func (shady *ShadowMap) Atlas() *ShadowAtlas {
+	return shady.atlas
+}
+
+// releaseAtlasTile frees the shadow map's tile in its atlas.
+func (shady *ShadowMap) releaseAtlasTile() {
+	if shady.atlas == nil {
+		return
+	}
+	shady.atlas.tiles[shady.atlasTile] = nil
+	shady.atlas = nil
+}
+
+// Viewport returns the rectangle of the shadow map's texture that it
+// renders into, which is its tile for shadow maps in an atlas.
+func (shady *ShadowMap) Viewport() (int32, int32, int32, int32) {
+	if shady.atlas == nil {
+		return 0, 0, shady.TextureSize, shady.TextureSize
+	}
+	x, y, size := shady.atlas.tileRect(shady.atlasTile)
+	return x, y, size, size
+}
+
+// updateSamplerMatrix maps BiasedMatrix into the shadow map's tile and
+// updates the texture space rectangle that lookups are limited to. The
+// rectangle is shrunk by half a texel so that filtering doesn't read the
+// neighboring tiles.
+func (shady *ShadowMap) updateSamplerMatrix() {
+	if shady.atlas == nil {
+		shady.samplerMatrix = shady.BiasedMatrix
+		shady.samplerRect = mgl.Vec4{0.0, 0.0, 1.0, 1.0}
+		return
+	}
+
+	x, y, size := shady.atlas.tileRect(shady.atlasTile)
+	atlasSize := float32(shady.atlas.Size)
+	scale := float32(size) / atlasSize
+	offsetX := float32(x) / atlasSize
+	offsetY := float32(y) / atlasSize
+	tile := mgl.Translate3D(offsetX, offsetY, 0.0).Mul4(mgl.Scale3D(scale, scale, 1.0))
+	shady.samplerMatrix = tile.Mul4(shady.BiasedMatrix)
+
+	halfTexel := 0.5 / atlasSize
+	shady.samplerRect = mgl.Vec4{
+		offsetX + halfTexel,
+		offsetY + halfTexel,
+		offsetX + scale - halfTexel,
+		offsetY + scale - halfTexel,
+	}
+}
+
+// useShadowDepthTexture attaches the depth texture to the shadow framebuffer
+// unless it's already attached, which saves the attachment swaps between
+// the shadow maps of an atlas.
+func (fr *ForwardRenderer) useShadowDepthTexture(tex graphics.Texture) {
+	if fr.shadowDepthAttachment == tex {
+		return
+	}
+	fr.gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, tex, 0)
+	fr.shadowDepthAttachment = tex
+}
+
+// clearShadowMap clears the depth of the shadow map's viewport, which for
+// shadow maps in an atlas leaves the other tiles alone.
+func (fr *ForwardRenderer) clearShadowMap(shady *ShadowMap) {
+	gfx := fr.gfx
+	if shady.atlas == nil {
+		gfx.Clear(graphics.DEPTH_BUFFER_BIT)
+		return
+	}
+	x, y, w, h := shady.Viewport()
+	gfx.Enable(graphics.SCISSOR_TEST)
+	gfx.Scissor(x, y, w, h)
+	gfx.Clear(graphics.DEPTH_BUFFER_BIT)
+	gfx.Disable(graphics.SCISSOR_TEST)
+}
+
+// bindShadowMap binds the light's shadow map, or no texture if it doesn't
+// have a depth shadow map. Shadow maps in the same atlas share the texture
+// unit that the first of them bound, which atlasUnit tracks for the draw.
+func (fr *ForwardRenderer) bindShadowMap(light *Light, names *lightUniforms, shader *fizzle.RenderShader, texturesBound *int32, atlas **ShadowAtlas, atlasUnit *int32) {
+	gfx := fr.gfx
+	shady := light.ShadowMap
+	depth := shady != nil && !shady.filtered()
+	if loc := shader.GetUniformLocation(names.shadowMap); loc >= 0 {
+		if depth && shady.atlas != nil && shady.atlas == *atlas {
+			gfx.Uniform1i(loc, *atlasUnit)
+		} else {
+			///* There have been problems in the past on Intel drivers on Mac OS if all of the
+			///  samplers are not bound to something. So this code will bind a 0 if the shadow map
+			///	 does not exist for that light. */
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			if depth {
+				gfx.BindTexture(graphics.TEXTURE_2D, shady.Texture)
+				if shady.atlas != nil {
+					*atlas = shady.atlas
+					*atlasUnit = *texturesBound
+				}
+			} else {
+				gfx.BindTexture(graphics.TEXTURE_2D, 0)
+			}
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+
+	if shady == nil {
+		return
+	}
+	if loc := shader.GetUniformLocation(names.shadowMatrix); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &shady.samplerMatrix)
+	}
+	if loc := shader.GetUniformLocation(names.shadowRect); loc >= 0 {
+		gfx.Uniform4f(loc, shady.samplerRect[0], shady.samplerRect[1], shady.samplerRect[2], shady.samplerRect[3])
+	}
+}
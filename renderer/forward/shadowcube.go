@@ -0,0 +1,215 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// ShadowCubeFaces is the number of faces of a shadow cubemap, each of
+// which is rendered in its own shadow pass.
+const ShadowCubeFaces = 6
+
+var (
+	// shadowCubeDirections are the directions the cubemap faces look in,
+	// in the order of TEXTURE_CUBE_MAP_POSITIVE_X onwards.
+	shadowCubeDirections = [ShadowCubeFaces]mgl.Vec3{
+		{1.0, 0.0, 0.0},
+		{-1.0, 0.0, 0.0},
+		{0.0, 1.0, 0.0},
+		{0.0, -1.0, 0.0},
+		{0.0, 0.0, 1.0},
+		{0.0, 0.0, -1.0},
+	}
+
+	// shadowCubeUps are the up vectors of the cubemap faces.
+	shadowCubeUps = [ShadowCubeFaces]mgl.Vec3{
+		{0.0, -1.0, 0.0},
+		{0.0, -1.0, 0.0},
+		{0.0, 0.0, 1.0},
+		{0.0, 0.0, -1.0},
+		{0.0, -1.0, 0.0},
+		{0.0, -1.0, 0.0},
+	}
+)
+
+// ShadowCubeMap contains the depth cubemap and the matrixes needed to render
+// omnidirectional shadows for a point light. Each face is rendered with a 90
+// degree perspective projection from the light's position.
+//
+// Shaders sample it with a samplerCubeShadow named SHADOW_CUBE_MAPS[i] using
+// the vector from the light to the fragment along with the depth of the
+// fragment on its dominant axis, computed from SHADOW_CUBE_RANGE[i] which
+// holds the near and far distances (or zeros when light i has no cubemap).
+type ShadowCubeMap struct {
+	// Texture is the depth cubemap texture
+	Texture graphics.Texture
+
+	// TextureSize is the size of each face of the cubemap.
+	TextureSize int32
+
+	// Near is the near distance for the projection of the faces
+	Near float32
+
+	// Far is the far distance for the projection of the faces
+	Far float32
+
+	// Projection is the projection transformation matrix shared by the faces
+	Projection mgl.Mat4
+
+	// ViewProjMatrices are the view-projection matrixes of the faces.
+	// Updated with UpdateShadowMapData() and EnableShadowCubeMappingFace().
+	ViewProjMatrices [ShadowCubeFaces]mgl.Mat4
+
+	// framebufferChecked is true once the shadow framebuffer was found to
+	// be complete with a face of the cubemap attached
+	framebufferChecked bool
+
+	// owner is the owning renderer
+	owner *ForwardRenderer
+}
+
+// Destroy deallocates any data being held onto by the ShadowCubeMap that is
+// not controlled by the Go GC.
+func (shady *ShadowCubeMap) Destroy() {
+	shady.owner.GetGraphics().DeleteTexture(shady.Texture)
+}
+
+// update recalculates the view-projection matrixes of the faces for a light
+// at the position.
+func (shady *ShadowCubeMap) update(position mgl.Vec3) {
+	for face := range shady.ViewProjMatrices {
+		target := position.Add(shadowCubeDirections[face])
+		view := mgl.LookAtV(position, target, shadowCubeUps[face])
+		shady.ViewProjMatrices[face] = shady.Projection.Mul4(view)
+	}
+}
+
+// CreateShadowCubeMap allocates a depth cubemap so that the light casts
+// shadows in every direction around its position. An error is returned if
+// the parameters are invalid or the texture couldn't be created, in which
+// case the light has no shadow cubemap.
+func (l *Light) CreateShadowCubeMap(textureSize int32, near float32, far float32) error {
+	if l.owner == nil {
+		return fmt.Errorf("the light was not created with ForwardRenderer.NewLight")
+	}
+	if textureSize <= 0 {
+		return fmt.Errorf("invalid shadow cubemap texture size %d", textureSize)
+	}
+	if near <= 0.0 || far <= near {
+		return fmt.Errorf("invalid shadow cubemap depth range %f to %f", near, far)
+	}
+
+	// if there was already a shadow cubemap, destroy it
+	if l.ShadowCubeMap != nil {
+		l.ShadowCubeMap.Destroy()
+	}
+
+	shady := new(ShadowCubeMap)
+	shady.owner = l.owner
+	shady.TextureSize = textureSize
+	shady.Near = near
+	shady.Far = far
+	shady.Projection = mgl.Perspective(mgl.DegToRad(90.0), 1.0, near, far)
+	l.ShadowCubeMap = shady
+
+	// create the depth cubemap texture
+	gfx := l.owner.GetGraphics()
+	shady.Texture = gfx.GenTexture()
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, shady.Texture)
+	for face := graphics.Enum(0); face < ShadowCubeFaces; face++ {
+		gfx.TexImage2D(graphics.TEXTURE_CUBE_MAP_POSITIVE_X+face, 0, graphics.DEPTH_COMPONENT32, textureSize, textureSize, 0, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT, nil, 0)
+	}
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_WRAP_R, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_COMPARE_MODE, graphics.COMPARE_REF_TO_TEXTURE)
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, 0)
+
+	err := fizzle.CheckGraphicsError(gfx, "creating the shadow cubemap texture")
+	if err != nil || shady.Texture == 0 {
+		shady.Destroy()
+		l.ShadowCubeMap = nil
+		if err == nil {
+			err = fmt.Errorf("failed to generate the shadow cubemap texture")
+		}
+		return err
+	}
+	return nil
+}
+
+// EnableShadowCubeMappingFace enables one face of the light's shadow cubemap
+// for the following shadow draws, which use the same shaders as regular
+// shadow maps. All ShadowCubeFaces faces, 0 being the positive X face, need
+// to be rendered to cast shadows in every direction. An error is returned,
+// and the face shouldn't be drawn, if the light has no shadow cubemap, the
+// face is invalid or the shadow framebuffer isn't complete with it attached.
+// NOTE: A good client would call StartShadowMapping() and EndShadowMapping() before
+// and after doing shadow draws.
+func (fr *ForwardRenderer) EnableShadowCubeMappingFace(l *Light, face int) error {
+	if l.ShadowCubeMap == nil {
+		return fmt.Errorf("can't render shadows for a light without a shadow cubemap")
+	}
+	if fr.shadowFBO == 0 {
+		return fmt.Errorf("can't render shadows before SetupShadowMapRendering")
+	}
+	if face < 0 || face >= ShadowCubeFaces {
+		return fmt.Errorf("can't render the shadow cubemap face %d", face)
+	}
+
+	fr.finishShadowMap()
+	fr.useDepthShadowTarget()
+	fr.currentShadowPassLight = l
+	l.ShadowCubeMap.update(l.Position)
+	fr.currentShadowPassVP = &l.ShadowCubeMap.ViewProjMatrices[face]
+
+	target := graphics.Enum(graphics.TEXTURE_CUBE_MAP_POSITIVE_X + face)
+	fr.gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, target, l.ShadowCubeMap.Texture, 0)
+	fr.shadowDepthAttachment = 0
+	fr.gfx.Clear(graphics.DEPTH_BUFFER_BIT)
+	fr.gfx.Viewport(0, 0, l.ShadowCubeMap.TextureSize, l.ShadowCubeMap.TextureSize)
+
+	if !l.ShadowCubeMap.framebufferChecked {
+		if err := fr.checkShadowFramebuffer("shadow cubemap"); err != nil {
+			return err
+		}
+		l.ShadowCubeMap.framebufferChecked = true
+	}
+	return nil
+}
+
+// bindShadowCubeMap binds the light's shadow cubemap, or no texture if the
+// light doesn't have one, along with its depth range. A zero range turns the
+// shadows off for renderables that don't receive them.
+func (fr *ForwardRenderer) bindShadowCubeMap(r *fizzle.Renderable, light *Light, names *lightUniforms, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := fr.gfx
+	if loc := shader.GetUniformLocation(names.shadowCubeMap); loc >= 0 {
+		// bind a 0 for lights without a cubemap so that every sampler is bound
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		if light.ShadowCubeMap != nil {
+			gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, light.ShadowCubeMap.Texture)
+		} else {
+			gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, 0)
+		}
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+
+	if loc := shader.GetUniformLocation(names.shadowCubeRange); loc >= 0 {
+		if light.ShadowCubeMap != nil && (r == nil || r.ReceiveShadow) {
+			gfx.Uniform2f(loc, light.ShadowCubeMap.Near, light.ShadowCubeMap.Far)
+		} else {
+			gfx.Uniform2f(loc, 0.0, 0.0)
+		}
+	}
+}
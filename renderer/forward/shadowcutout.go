@@ -0,0 +1,78 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/groggy"
+)
+
+var (
+	// CutoutShadowVertShader330 is the GLSL vertex shader that renders the
+	// shadows of alpha cutout materials into shadow maps.
+	CutoutShadowVertShader330 = `#version 330
+  uniform mat4 M_MATRIX;
+  uniform mat4 SHADOW_VP_MATRIX;
+  in vec4 VERTEX_POSITION;
+  in vec2 VERTEX_UV_0;
+  out vec2 vs_tex0_uv;
+
+  void main()
+  {
+    vs_tex0_uv = VERTEX_UV_0;
+    gl_Position = SHADOW_VP_MATRIX * M_MATRIX * VERTEX_POSITION;
+  }`
+
+	// CutoutShadowFragShader330 is the GLSL fragment shader that renders the
+	// shadows of alpha cutout materials. Fragments whose diffuse alpha is
+	// below MATERIAL_ALPHA_CUTOFF are discarded and the rest write the same
+	// values as the shadow map generator shader for filtered shadow maps.
+	CutoutShadowFragShader330 = `#version 330
+  uniform vec4 MATERIAL_DIFFUSE;
+  uniform sampler2D MATERIAL_TEX_0;
+  uniform float MATERIAL_ALPHA_CUTOFF;
+  uniform float SHADOW_EXPONENTIAL_CONSTANT;
+  in vec2 vs_tex0_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    float alpha = texture(MATERIAL_TEX_0, vs_tex0_uv).a * MATERIAL_DIFFUSE.a;
+    if (alpha < MATERIAL_ALPHA_CUTOFF) {
+      discard;
+    }
+
+    float depth = gl_FragCoord.z;
+    if (SHADOW_EXPONENTIAL_CONSTANT > 0.0) {
+      frag_color = vec4(exp(SHADOW_EXPONENTIAL_CONSTANT * depth), 0.0, 0.0, 1.0);
+      return;
+    }
+    float dx = dFdx(depth);
+    float dy = dFdy(depth);
+    frag_color = vec4(depth, depth * depth + 0.25 * (dx * dx + dy * dy), 0.0, 1.0);
+  }`
+)
+
+// shadowShader returns the shader to draw the Renderable with. While
+// rendering shadows, alpha cutout materials are drawn with the cutout
+// shadow shader so that the holes in them don't cast shadows. It's compiled
+// the first time it's needed and the shader passed in is used if that fails.
+func (fr *ForwardRenderer) shadowShader(r *fizzle.Renderable, shader *fizzle.RenderShader) *fizzle.RenderShader {
+	if fr.currentShadowPassVP == nil || r.GetMaterial().AlphaCutoff <= 0.0 {
+		return shader
+	}
+	if fr.cutoutShadowShader == nil && !fr.cutoutShadowFailed {
+		cutout, err := fizzle.LoadShaderProgram(CutoutShadowVertShader330, CutoutShadowFragShader330, nil)
+		if err != nil {
+			groggy.Logsf("ERROR", "Failed to compile the cutout shadow shader: %v", err)
+			fr.cutoutShadowFailed = true
+			return shader
+		}
+		fr.cutoutShadowShader = cutout
+	}
+	if fr.cutoutShadowShader == nil {
+		return shader
+	}
+	return fr.cutoutShadowShader
+}
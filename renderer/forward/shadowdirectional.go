@@ -0,0 +1,88 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// CreateDirectionalShadowMap allocates a shadow map for a sun-style light
+// that shines along its Direction from infinitely far away. The shadow map
+// uses an orthographic projection covering the world space box between
+// boundsMin and boundsMax, which can be changed later by setting the
+// ShadowMap's bounds or with FitToFrustum. An error is returned if the
+// parameters are invalid or the texture couldn't be created, in which case
+// the light has no shadow map.
+func (l *Light) CreateDirectionalShadowMap(textureSize int32, boundsMin mgl.Vec3, boundsMax mgl.Vec3) error {
+	if l.owner == nil {
+		return fmt.Errorf("the light was not created with ForwardRenderer.NewLight")
+	}
+	if textureSize <= 0 {
+		return fmt.Errorf("invalid shadow map texture size %d", textureSize)
+	}
+	if l.Direction.Len() == 0.0 {
+		return fmt.Errorf("a directional light needs a direction")
+	}
+
+	if err := l.allocateShadowMap(textureSize); err != nil {
+		return err
+	}
+	l.ShadowMap.Orthographic = true
+	l.ShadowMap.Direction = l.Direction
+	l.ShadowMap.BoundsMin = boundsMin
+	l.ShadowMap.BoundsMax = boundsMax
+	return nil
+}
+
+// FitToFrustum sets the bounds of an orthographic shadow map to enclose the
+// view frustum of a camera. Passing a perspective matrix with a closer far
+// plane than the camera's trades shadow distance for shadow resolution.
+func (shady *ShadowMap) FitToFrustum(perspective mgl.Mat4, view mgl.Mat4) {
+	inv := perspective.Mul4(view).Inv()
+	shady.BoundsMin = mgl.Vec3{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+	shady.BoundsMax = mgl.Vec3{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+	for corner := 0; corner < 8; corner++ {
+		ndc := mgl.Vec4{cornerSign(corner, 1), cornerSign(corner, 2), cornerSign(corner, 4), 1.0}
+		p := inv.Mul4x1(ndc)
+		world := p.Vec3().Mul(1.0 / p[3])
+		for i := 0; i < 3; i++ {
+			shady.BoundsMin[i] = float32(math.Min(float64(shady.BoundsMin[i]), float64(world[i])))
+			shady.BoundsMax[i] = float32(math.Max(float64(shady.BoundsMax[i]), float64(world[i])))
+		}
+	}
+}
+
+// fitOrthographic updates the view and projection of an orthographic shadow
+// map to cover the sphere around its bounds. The sphere keeps the size of the
+// projection the same as the light or camera turns and the projection is
+// snapped to whole texels so the shadow edges don't shimmer as it moves.
+func (shady *ShadowMap) fitOrthographic() {
+	dir := shady.Direction.Normalize()
+	up := shady.Up
+	if d := dir.Dot(up.Normalize()); d > 0.999 || d < -0.999 {
+		up = mgl.Vec3{0.0, 0.0, 1.0}
+	}
+
+	// the view is anchored at the origin so that only the projection moves
+	shady.View = mgl.LookAtV(mgl.Vec3{}, dir, up)
+
+	center := shady.BoundsMin.Add(shady.BoundsMax).Mul(0.5)
+	radius := shady.BoundsMax.Sub(shady.BoundsMin).Len() * 0.5
+	if radius <= 0.0 {
+		radius = 1.0
+	}
+	lightCenter := shady.View.Mul4x1(center.Vec4(1.0)).Vec3()
+
+	texelSize := 2.0 * radius / float32(shady.TextureSize)
+	x := float32(math.Floor(float64(lightCenter[0]/texelSize))) * texelSize
+	y := float32(math.Floor(float64(lightCenter[1]/texelSize))) * texelSize
+
+	// the view looks down -Z so the depth of the center is -Z
+	shady.Near = -lightCenter[2] - radius
+	shady.Far = -lightCenter[2] + radius
+	shady.Projection = mgl.Ortho(x-radius, x+radius, y-radius, y+radius, shady.Near, shady.Far)
+}
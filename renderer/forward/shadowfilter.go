@@ -0,0 +1,354 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+	"math"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+	"github.com/tbogdala/groggy"
+)
+
+const (
+	// shadowFilterNone, shadowFilterVariance and shadowFilterExponential
+	// are the values of the SHADOW_FILTER uniforms for each kind of map
+	shadowFilterNone        = 0
+	shadowFilterVariance    = 1
+	shadowFilterExponential = 2
+
+	// maxExponentialConstant is the largest ExponentialConstant used, which
+	// keeps exp(c * depth) within the range of 32 bit floats
+	maxExponentialConstant = 85.0
+)
+
+var (
+	// ShadowMapBlurFragShader330 is the GLSL fragment shader that blurs
+	// variance and exponential shadow maps along one axis with a 9 tap
+	// gaussian kernel. It uses the fog pass vertex shader to draw a full
+	// screen quad.
+	ShadowMapBlurFragShader330 = `#version 330
+  uniform sampler2D SHADOW_BLUR_TEX;
+  uniform vec2 SHADOW_BLUR_STEP;
+
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    float weights[5] = float[](0.227027, 0.1945946, 0.1216216, 0.054054, 0.016216);
+    vec2 moments = texture(SHADOW_BLUR_TEX, vs_uv).rg * weights[0];
+    for (int i = 1; i < 5; i++) {
+      moments += texture(SHADOW_BLUR_TEX, vs_uv + SHADOW_BLUR_STEP * float(i)).rg * weights[i];
+      moments += texture(SHADOW_BLUR_TEX, vs_uv - SHADOW_BLUR_STEP * float(i)).rg * weights[i];
+    }
+    frag_color = vec4(moments, 0.0, 1.0);
+  }`
+
+	// filteredDrawBuffers and depthDrawBuffers are the draw buffers of the
+	// shadow framebuffer with and without a filtered shadow map attached
+	filteredDrawBuffers = []uint32{graphics.COLOR_ATTACHMENT0}
+	depthDrawBuffers    = []uint32{graphics.NONE}
+)
+
+// shadowMapBlur is the shader and quad used to blur filtered shadow maps.
+type shadowMapBlur struct {
+	shader *fizzle.RenderShader
+	quad   *fizzle.Renderable
+
+	// source and step are what's being blurred for the uniform binder
+	source graphics.Texture
+	step   mgl.Vec2
+}
+
+// Destroy releases the shader and quad of the blur.
+func (sb *shadowMapBlur) Destroy() {
+	sb.shader.Destroy()
+	sb.quad.Destroy()
+}
+
+// bindUniforms binds the texture being blurred and the blur step.
+func (sb *shadowMapBlur) bindUniforms(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := rend.GetGraphics()
+	if loc := shader.GetUniformLocation("SHADOW_BLUR_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, sb.source)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("SHADOW_BLUR_STEP"); loc >= 0 {
+		gfx.Uniform2f(loc, sb.step[0], sb.step[1])
+	}
+}
+
+// SetVariance switches the shadow map between a regular depth texture,
+// which shaders sample with a depth comparison, and a variance shadow map,
+// which stores the depth and squared depth in an RG32F texture that's
+// blurred after rendering and tested with Chebyshev's inequality. Variance
+// shadows are much softer, which suits large lights, but can leak light
+// where occluders overlap; see LightBleedReduction. The shadow map's
+// textures are recreated and an error is returned if that fails, in which
+// case it's left as a regular shadow map.
+func (shady *ShadowMap) SetVariance(enabled bool) error {
+	return shady.setFilter(enabled, false)
+}
+
+// SetExponential switches the shadow map between a regular depth texture
+// and an exponential shadow map, which stores exp(ExponentialConstant *
+// depth) in an R32F texture that's blurred after rendering and tested with
+// a single lookup. Exponential shadows are soft and cheaper than variance
+// shadows or filtering a depth texture, which suits low-end GPUs, but they
+// lighten where receivers are close to their occluders. The textures are
+// recreated and an error is returned if that fails, in which case it's
+// left as a regular shadow map.
+func (shady *ShadowMap) SetExponential(enabled bool) error {
+	return shady.setFilter(false, enabled)
+}
+
+// setFilter recreates the textures of the shadow map for the filtering.
+func (shady *ShadowMap) setFilter(variance bool, exponential bool) error {
+	if shady.Variance == variance && shady.Exponential == exponential {
+		return nil
+	}
+	if (variance || exponential) && shady.atlas != nil {
+		return fmt.Errorf("shadow maps in a shadow atlas can't be filtered shadow maps")
+	}
+
+	shady.destroyTextures()
+	shady.Variance = variance
+	shady.Exponential = exponential
+	if !shady.filtered() {
+		return shady.createDepthTexture()
+	}
+
+	err := shady.createFilteredTextures()
+	if err != nil {
+		shady.destroyTextures()
+		shady.Variance = false
+		shady.Exponential = false
+		if fallbackErr := shady.createDepthTexture(); fallbackErr != nil {
+			groggy.Logsf("ERROR", "Failed to recreate the shadow map depth texture: %v", fallbackErr)
+		}
+	}
+	return err
+}
+
+// destroyTextures deletes the textures and buffers of the shadow map.
+func (shady *ShadowMap) destroyTextures() {
+	shady.Destroy()
+	shady.Texture = 0
+	shady.blurTexture = 0
+	shady.depthBuffer = 0
+	shady.framebufferChecked = false
+}
+
+// filtered returns true for variance and exponential shadow maps, which
+// are rendered to a color texture that gets blurred.
+func (shady *ShadowMap) filtered() bool {
+	return shady.Variance || shady.Exponential
+}
+
+// filterMode returns the value of the SHADOW_FILTER uniform for the map.
+func (shady *ShadowMap) filterMode() int32 {
+	switch {
+	case shady.Variance:
+		return shadowFilterVariance
+	case shady.Exponential:
+		return shadowFilterExponential
+	default:
+		return shadowFilterNone
+	}
+}
+
+// exponentialConstant returns the ExponentialConstant limited to the range
+// that doesn't overflow.
+func (shady *ShadowMap) exponentialConstant() float32 {
+	return mgl.Clamp(shady.ExponentialConstant, 1.0, maxExponentialConstant)
+}
+
+// createFilteredTextures creates the color textures and the depth buffer
+// of a variance or exponential shadow map.
+func (shady *ShadowMap) createFilteredTextures() error {
+	gfx := shady.owner.GetGraphics()
+	internalFormat, format := int32(graphics.RG32F), graphics.Enum(graphics.RG)
+	if shady.Exponential {
+		internalFormat, format = graphics.R32F, graphics.RED
+	}
+	shady.Texture = createFilteredTexture(gfx, shady.TextureSize, internalFormat, format)
+	shady.blurTexture = createFilteredTexture(gfx, shady.TextureSize, internalFormat, format)
+
+	shady.depthBuffer = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, shady.depthBuffer)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH_COMPONENT24, shady.TextureSize, shady.TextureSize)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+
+	return fizzle.CheckGraphicsError(gfx, "creating the filtered shadow map textures")
+}
+
+// createFilteredTexture creates a float texture for a variance or
+// exponential shadow map. Like depth shadow maps, it has a white border;
+// shaders skip lookups outside of it for exponential maps.
+func createFilteredTexture(gfx graphics.GraphicsProvider, size int32, internalFormat int32, format graphics.Enum) graphics.Texture {
+	tex := gfx.GenTexture()
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, internalFormat, size, size, 0, format, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	border := mgl.Vec4{1.0, 1.0, 1.0, 1.0}
+	gfx.TexParameterfv(graphics.TEXTURE_2D, graphics.TEXTURE_BORDER_COLOR, &border[0])
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_BORDER)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_BORDER)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	return tex
+}
+
+// useFilteredShadowTarget attaches the color texture and depth buffer of
+// the filtered shadow map to the shadow framebuffer and clears them to the
+// farthest depth.
+func (fr *ForwardRenderer) useFilteredShadowTarget(shady *ShadowMap) {
+	gfx := fr.gfx
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, shady.Texture, 0)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.RENDERBUFFER, shady.depthBuffer)
+	fr.shadowDepthAttachment = 0
+	if !fr.shadowFilteredTarget {
+		gfx.DrawBuffers(filteredDrawBuffers)
+		fr.shadowFilteredTarget = true
+	}
+	if shady.Exponential {
+		far := float32(math.Exp(float64(shady.exponentialConstant())))
+		gfx.ClearColor(far, 0.0, 0.0, 0.0)
+	} else {
+		gfx.ClearColor(1.0, 1.0, 0.0, 0.0)
+	}
+	gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
+}
+
+// useDepthShadowTarget detaches the buffers of a filtered shadow map from
+// the shadow framebuffer so that it only renders depth again.
+func (fr *ForwardRenderer) useDepthShadowTarget() {
+	if !fr.shadowFilteredTarget {
+		return
+	}
+	gfx := fr.gfx
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, 0, 0)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.RENDERBUFFER, 0)
+	gfx.DrawBuffers(depthDrawBuffers)
+	fr.shadowFilteredTarget = false
+}
+
+// finishShadowMap blurs the shadow map that was just rendered if it's a
+// filtered shadow map.
+func (fr *ForwardRenderer) finishShadowMap() {
+	l := fr.currentShadowPassLight
+	if l == nil || l.ShadowMap == nil || fr.currentShadowPassVP != &l.ShadowMap.ViewProjMatrix {
+		return
+	}
+	if !l.ShadowMap.filtered() || l.ShadowMap.FilterBlur <= 0.0 {
+		return
+	}
+	fr.blurShadowMap(l.ShadowMap)
+}
+
+// blurShadowMap blurs the filtered shadow map horizontally into its blur
+// texture and then vertically back.
+func (fr *ForwardRenderer) blurShadowMap(shady *ShadowMap) {
+	if fr.shadowBlur == nil {
+		shader, err := fizzle.LoadShaderProgram(FogPassVertShader330, ShadowMapBlurFragShader330, nil)
+		if err != nil {
+			groggy.Logsf("ERROR", "Failed to compile the shadow map blur shader: %v", err)
+			return
+		}
+		fr.shadowBlur = new(shadowMapBlur)
+		fr.shadowBlur.shader = shader
+		fr.shadowBlur.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	}
+	sb := fr.shadowBlur
+	gfx := fr.gfx
+
+	// the shadow pass state culls front faces and offsets depth, neither of
+	// which the full screen quad wants
+	savedLight, savedVP := fr.currentShadowPassLight, fr.currentShadowPassVP
+	fr.currentShadowPassLight, fr.currentShadowPassVP = nil, nil
+	gfx.Disable(graphics.CULL_FACE)
+	gfx.Disable(graphics.POLYGON_OFFSET_FILL)
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+
+	// the kernel has four taps on either side of the center
+	step := shady.FilterBlur / 4.0 / float32(shady.TextureSize)
+	identity := mgl.Ident4()
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, shady.blurTexture, 0)
+	sb.source = shady.Texture
+	sb.step = mgl.Vec2{step, 0.0}
+	fr.DrawRenderableWithShader(sb.quad, sb.shader, sb.bindUniforms, identity, identity, nil)
+
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, shady.Texture, 0)
+	sb.source = shady.blurTexture
+	sb.step = mgl.Vec2{0.0, step}
+	fr.DrawRenderableWithShader(sb.quad, sb.shader, sb.bindUniforms, identity, identity, nil)
+	sb.source = 0
+
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.Enable(graphics.POLYGON_OFFSET_FILL)
+	gfx.Enable(graphics.CULL_FACE)
+	fr.currentShadowPassLight, fr.currentShadowPassVP = savedLight, savedVP
+}
+
+// bindShadowPassFilter tells the shadow map generator shader whether the
+// shadow map being rendered is exponential through the constant it raises
+// the depth with, or 0 for other shadow maps.
+func (fr *ForwardRenderer) bindShadowPassFilter(shader *fizzle.RenderShader) {
+	loc := shader.GetUniformLocation("SHADOW_EXPONENTIAL_CONSTANT")
+	if loc < 0 {
+		return
+	}
+	l := fr.currentShadowPassLight
+	if l != nil && l.ShadowMap != nil && fr.currentShadowPassVP == &l.ShadowMap.ViewProjMatrix && l.ShadowMap.Exponential {
+		fr.gfx.Uniform1f(loc, l.ShadowMap.exponentialConstant())
+	} else {
+		fr.gfx.Uniform1f(loc, 0.0)
+	}
+}
+
+// bindFilteredShadowMap binds the light's variance or exponential shadow
+// map, or no texture if it doesn't have one, along with the kind of filter
+// and the parameters of its test.
+func (fr *ForwardRenderer) bindFilteredShadowMap(light *Light, names *lightUniforms, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := fr.gfx
+	filtered := light.ShadowMap != nil && light.ShadowMap.filtered()
+	if loc := shader.GetUniformLocation(names.filterMode); loc >= 0 {
+		if filtered {
+			gfx.Uniform1i(loc, light.ShadowMap.filterMode())
+		} else {
+			gfx.Uniform1i(loc, shadowFilterNone)
+		}
+	}
+	if loc := shader.GetUniformLocation(names.filteredMap); loc >= 0 {
+		// bind a 0 for lights without a filtered shadow map so that every sampler is bound
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		if filtered {
+			gfx.BindTexture(graphics.TEXTURE_2D, light.ShadowMap.Texture)
+		} else {
+			gfx.BindTexture(graphics.TEXTURE_2D, 0)
+		}
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if !filtered {
+		return
+	}
+	if loc := shader.GetUniformLocation(names.filterParams); loc >= 0 {
+		if light.ShadowMap.Exponential {
+			gfx.Uniform2f(loc, light.ShadowMap.exponentialConstant(), 0.0)
+		} else {
+			bleed := mgl.Clamp(light.ShadowMap.LightBleedReduction, 0.0, 0.99)
+			gfx.Uniform2f(loc, light.ShadowMap.MinVariance, bleed)
+		}
+	}
+}
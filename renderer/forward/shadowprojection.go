@@ -0,0 +1,127 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// ShadowProjection describes the projection that a shadow map renders the
+// scene through from its light. A perspective projection is set up either
+// with a field of view and aspect ratio or with the frustum extents at the
+// near plane; an orthographic projection uses the extents as the sides of
+// its box, which suits small directional lights that don't need their
+// bounds fitted like CreateDirectionalShadowMap does.
+type ShadowProjection struct {
+	// Orthographic is true for an orthographic projection along the shadow
+	// map's Direction instead of a perspective one.
+	Orthographic bool
+
+	// FieldOfView is the vertical field of view, in radians, of a
+	// perspective projection. When zero the extents are used instead.
+	FieldOfView float32
+
+	// Aspect is the width over the height of a perspective projection
+	// using FieldOfView; zero means 1.0.
+	Aspect float32
+
+	// Left, Right, Bottom and Top are the extents of the projection at the
+	// near plane for perspective projections and of the box for
+	// orthographic ones.
+	Left   float32
+	Right  float32
+	Bottom float32
+	Top    float32
+}
+
+// DefaultShadowProjection returns the projection CreateShadowMap uses: a
+// small perspective frustum one unit wide at the near plane.
+func DefaultShadowProjection() ShadowProjection {
+	return FrustumShadowProjection(-0.5, 0.5, -0.5, 0.5)
+}
+
+// PerspectiveShadowProjection returns a perspective projection with the
+// vertical field of view, in radians, and the width over height aspect.
+func PerspectiveShadowProjection(fovy float32, aspect float32) ShadowProjection {
+	return ShadowProjection{FieldOfView: fovy, Aspect: aspect}
+}
+
+// FrustumShadowProjection returns a perspective projection with the extents
+// at the near plane.
+func FrustumShadowProjection(left, right, bottom, top float32) ShadowProjection {
+	return ShadowProjection{Left: left, Right: right, Bottom: bottom, Top: top}
+}
+
+// OrthographicShadowProjection returns an orthographic projection of the
+// box with the extents.
+func OrthographicShadowProjection(left, right, bottom, top float32) ShadowProjection {
+	return ShadowProjection{Orthographic: true, Left: left, Right: right, Bottom: bottom, Top: top}
+}
+
+// validate returns an error if the projection can't be turned into a matrix.
+func (p *ShadowProjection) validate() error {
+	if !p.Orthographic && p.FieldOfView != 0.0 {
+		if p.FieldOfView < 0.0 || p.FieldOfView >= mgl.DegToRad(180.0) {
+			return fmt.Errorf("invalid shadow projection field of view %f", p.FieldOfView)
+		}
+		if p.Aspect < 0.0 {
+			return fmt.Errorf("invalid shadow projection aspect ratio %f", p.Aspect)
+		}
+		return nil
+	}
+	if p.Right <= p.Left || p.Top <= p.Bottom {
+		return fmt.Errorf("invalid shadow projection extents %f, %f, %f, %f", p.Left, p.Right, p.Bottom, p.Top)
+	}
+	return nil
+}
+
+// aspect returns the aspect ratio of the projection.
+func (p *ShadowProjection) aspect() float32 {
+	if !p.Orthographic && p.FieldOfView != 0.0 {
+		if p.Aspect <= 0.0 {
+			return 1.0
+		}
+		return p.Aspect
+	}
+	return (p.Right - p.Left) / (p.Top - p.Bottom)
+}
+
+// Matrix returns the projection matrix with the depth range.
+func (p *ShadowProjection) Matrix(near float32, far float32) mgl.Mat4 {
+	switch {
+	case p.Orthographic:
+		return mgl.Ortho(p.Left, p.Right, p.Bottom, p.Top, near, far)
+	case p.FieldOfView != 0.0:
+		return mgl.Perspective(p.FieldOfView, p.aspect(), near, far)
+	default:
+		return mgl.Frustum(p.Left, p.Right, p.Bottom, p.Top, near, far)
+	}
+}
+
+// SetProjection changes the projection and depth range of the shadow map
+// without reallocating its texture. An error is returned if the parameters
+// are invalid, in which case the shadow map is unchanged. Shadow maps of
+// spot lights keep following the outer cone and only use the aspect ratio,
+// and the fitted projections of CreateDirectionalShadowMap ignore it.
+func (shady *ShadowMap) SetProjection(proj ShadowProjection, near float32, far float32) error {
+	if err := proj.validate(); err != nil {
+		return err
+	}
+	if !proj.Orthographic && near <= 0.0 {
+		return fmt.Errorf("invalid shadow map depth range %f to %f", near, far)
+	}
+	if far <= near {
+		return fmt.Errorf("invalid shadow map depth range %f to %f", near, far)
+	}
+
+	shady.ProjectionParams = proj
+	shady.Near = near
+	shady.Far = far
+	if !shady.Orthographic {
+		shady.Projection = proj.Matrix(near, far)
+	}
+	return nil
+}
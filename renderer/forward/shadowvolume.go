@@ -0,0 +1,392 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/collision"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// floatSize is the size in bytes of a float32
+	floatSize = 4
+)
+
+var (
+	// ShadowVolumeVertShader330 is the GLSL vertex shader for the shadow
+	// volumes. Vertices with a W of 0 are extruded to infinity.
+	ShadowVolumeVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  in vec4 VERTEX_POSITION;
+
+  void main()
+  {
+    gl_Position = MVP_MATRIX * VERTEX_POSITION;
+  }`
+
+	// ShadowVolumeFragShader330 is the GLSL fragment shader for the shadow
+	// volumes. Only the stencil buffer is written.
+	ShadowVolumeFragShader330 = `#version 330
+  out vec4 frag_color;
+
+  void main()
+  {
+    frag_color = vec4(0.0);
+  }`
+)
+
+// volumeEdge is an edge between two welded vertexes, smallest index first.
+type volumeEdge [2]uint32
+
+// ShadowVolume is the shadow volume of one caster. The silhouette of the
+// mesh, as seen from the light, is extracted on the CPU whenever Update is
+// called and extruded to infinity. The mesh should be closed for the
+// shadows to be correct.
+type ShadowVolume struct {
+	// Caster is the Renderable casting the shadow; its transform is used
+	// when updating and drawing the volume.
+	Caster *fizzle.Renderable
+
+	gfx graphics.GraphicsProvider
+	vao uint32
+	vbo graphics.Buffer
+
+	// vertices are the welded positions and triangles index them
+	vertices  []mgl.Vec3
+	triangles [][3]uint32
+	normals   []mgl.Vec3
+
+	// neighbors has the adjacent triangle across each edge of each
+	// triangle or -1 if the edge is open
+	neighbors [][3]int32
+
+	facing      []bool
+	volume      []float32
+	vertexCount int32
+}
+
+// NewShadowVolume creates the shadow volume for the caster, which must have
+// been created with fizzle.RetainGeometry set.
+func NewShadowVolume(gfx graphics.GraphicsProvider, caster *fizzle.Renderable) (*ShadowVolume, error) {
+	mesh, err := collision.NewTriMeshFromRenderable(caster)
+	if err != nil {
+		return nil, err
+	}
+	if len(mesh.Indexes) < 3 {
+		return nil, fmt.Errorf("the shadow caster has no triangles")
+	}
+
+	sv := new(ShadowVolume)
+	sv.Caster = caster
+	sv.gfx = gfx
+	sv.buildAdjacency(mesh)
+	sv.facing = make([]bool, len(sv.triangles))
+	sv.vao = gfx.GenVertexArray()
+	sv.vbo = gfx.GenBuffer()
+	return sv, nil
+}
+
+// Destroy releases the buffers of the volume.
+func (sv *ShadowVolume) Destroy() {
+	sv.gfx.DeleteBuffer(sv.vbo)
+	sv.gfx.DeleteVertexArray(sv.vao)
+}
+
+// buildAdjacency welds the vertexes of the mesh by position, since meshes
+// split vertexes along UV and normal seams, and finds the neighbors of
+// every triangle.
+func (sv *ShadowVolume) buildAdjacency(mesh *collision.TriMesh) {
+	welded := make(map[mgl.Vec3]uint32, len(mesh.Vertices))
+	remap := make([]uint32, len(mesh.Vertices))
+	for i, v := range mesh.Vertices {
+		idx, okay := welded[v]
+		if !okay {
+			idx = uint32(len(sv.vertices))
+			welded[v] = idx
+			sv.vertices = append(sv.vertices, v)
+		}
+		remap[i] = idx
+	}
+
+	triCount := len(mesh.Indexes) / 3
+	sv.triangles = make([][3]uint32, 0, triCount)
+	sv.normals = make([]mgl.Vec3, 0, triCount)
+	for t := 0; t < triCount; t++ {
+		tri := [3]uint32{remap[mesh.Indexes[t*3]], remap[mesh.Indexes[t*3+1]], remap[mesh.Indexes[t*3+2]]}
+		if tri[0] == tri[1] || tri[1] == tri[2] || tri[2] == tri[0] {
+			continue // degenerate
+		}
+		v0, v1, v2 := sv.vertices[tri[0]], sv.vertices[tri[1]], sv.vertices[tri[2]]
+		sv.triangles = append(sv.triangles, tri)
+		sv.normals = append(sv.normals, v1.Sub(v0).Cross(v2.Sub(v0)))
+	}
+
+	edges := make(map[volumeEdge][]int32, len(sv.triangles)*3/2)
+	for t, tri := range sv.triangles {
+		for e := 0; e < 3; e++ {
+			key := makeVolumeEdge(tri[e], tri[(e+1)%3])
+			edges[key] = append(edges[key], int32(t))
+		}
+	}
+
+	sv.neighbors = make([][3]int32, len(sv.triangles))
+	for t, tri := range sv.triangles {
+		for e := 0; e < 3; e++ {
+			sv.neighbors[t][e] = -1
+			for _, other := range edges[makeVolumeEdge(tri[e], tri[(e+1)%3])] {
+				if other != int32(t) {
+					sv.neighbors[t][e] = other
+					break
+				}
+			}
+		}
+	}
+}
+
+// makeVolumeEdge returns the edge key for the two vertexes.
+func makeVolumeEdge(a, b uint32) volumeEdge {
+	if a > b {
+		return volumeEdge{b, a}
+	}
+	return volumeEdge{a, b}
+}
+
+// Update rebuilds the volume for a light in world space. For point lights
+// light is the position with a W of 1 and for directional lights it is the
+// direction the light shines in with a W of 0.
+func (sv *ShadowVolume) Update(light mgl.Vec4) {
+	// bring the light into the caster's model space such that the
+	// direction from a vertex v towards the light is toLight.xyz - v * toLight.w
+	invModel := sv.Caster.GetTransformMat4().Inv()
+	var toLight mgl.Vec4
+	if light[3] == 0.0 {
+		toLight = invModel.Mul4x1(mgl.Vec4{-light[0], -light[1], -light[2], 0.0})
+	} else {
+		toLight = invModel.Mul4x1(light)
+		toLight = toLight.Mul(1.0 / toLight[3])
+	}
+	lightPos := toLight.Vec3()
+
+	for t, tri := range sv.triangles {
+		v0 := sv.vertices[tri[0]]
+		sv.facing[t] = sv.normals[t].Dot(lightPos.Sub(v0.Mul(toLight[3]))) > 0.0
+	}
+
+	sv.volume = sv.volume[:0]
+	for t, tri := range sv.triangles {
+		if !sv.facing[t] {
+			continue
+		}
+		a, b, c := sv.vertices[tri[0]], sv.vertices[tri[1]], sv.vertices[tri[2]]
+
+		// the front cap is the lit triangle and the back cap is the same
+		// triangle extruded to infinity facing the other way
+		sv.addVertex(a.Vec4(1.0))
+		sv.addVertex(b.Vec4(1.0))
+		sv.addVertex(c.Vec4(1.0))
+		sv.addVertex(extrude(c, toLight))
+		sv.addVertex(extrude(b, toLight))
+		sv.addVertex(extrude(a, toLight))
+
+		// the sides are extruded from the silhouette edges, the ones
+		// shared with an unlit triangle or not shared at all
+		for e := 0; e < 3; e++ {
+			n := sv.neighbors[t][e]
+			if n >= 0 && sv.facing[n] {
+				continue
+			}
+			p0 := sv.vertices[tri[e]]
+			p1 := sv.vertices[tri[(e+1)%3]]
+			p0Inf := extrude(p0, toLight)
+			p1Inf := extrude(p1, toLight)
+			sv.addVertex(p1.Vec4(1.0))
+			sv.addVertex(p0.Vec4(1.0))
+			sv.addVertex(p0Inf)
+			sv.addVertex(p1.Vec4(1.0))
+			sv.addVertex(p0Inf)
+			sv.addVertex(p1Inf)
+		}
+	}
+
+	sv.vertexCount = int32(len(sv.volume) / 4)
+	if sv.vertexCount == 0 {
+		return
+	}
+	gfx := sv.gfx
+	gfx.BindBuffer(graphics.ARRAY_BUFFER, sv.vbo)
+	gfx.BufferData(graphics.ARRAY_BUFFER, floatSize*len(sv.volume), gfx.Ptr(&sv.volume[0]), graphics.STREAM_DRAW)
+}
+
+// addVertex appends the vertex to the volume.
+func (sv *ShadowVolume) addVertex(v mgl.Vec4) {
+	sv.volume = append(sv.volume, v[0], v[1], v[2], v[3])
+}
+
+// extrude returns the vertex pushed away from the light to infinity.
+func extrude(v mgl.Vec3, toLight mgl.Vec4) mgl.Vec4 {
+	d := v.Mul(toLight[3]).Sub(toLight.Vec3())
+	return d.Vec4(0.0)
+}
+
+// StencilShadows renders hard edged shadows with depth-fail stencil shadow
+// volumes as an alternative to shadow maps for GPUs where sampling depth
+// textures is slow or unsupported. The framebuffer needs a stencil buffer.
+//
+// The volumes are extruded to infinity, so DEPTH_CLAMP is enabled while
+// drawing them; where that isn't supported, such as OpenGL ES, the
+// perspective matrix should have an infinite far plane.
+type StencilShadows struct {
+	gfx    graphics.GraphicsProvider
+	shader *fizzle.RenderShader
+
+	// lights are the scratch copies of the lights for each pass
+	lights [MaxForwardLights]Light
+}
+
+// NewStencilShadows compiles the shadow volume shader.
+func NewStencilShadows(gfx graphics.GraphicsProvider) (*StencilShadows, error) {
+	shader, err := fizzle.LoadShaderProgram(ShadowVolumeVertShader330, ShadowVolumeFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := new(StencilShadows)
+	ss.gfx = gfx
+	ss.shader = shader
+	return ss, nil
+}
+
+// Destroy releases the shadow volume shader.
+func (ss *StencilShadows) Destroy() {
+	ss.shader.Destroy()
+}
+
+// Render draws the scene lit by each light in turn, with the pixels inside
+// of the shadow volumes of the casters left unlit by that light.
+//
+// drawScene is called once for an ambient and depth pass and then once for
+// every light, with the renderer's ActiveLights set to just that light,
+// and should draw the scene with the renderer using the normal lit shaders.
+// The lights' shadow maps are ignored and ActiveLights is restored afterwards.
+// Face culling is left enabled.
+func (ss *StencilShadows) Render(fr *ForwardRenderer, lights []*Light, casters []*ShadowVolume,
+	perspective mgl.Mat4, view mgl.Mat4, drawScene func()) {
+	if len(lights) > MaxForwardLights {
+		lights = lights[:MaxForwardLights]
+	}
+	gfx := ss.gfx
+	savedLights := fr.ActiveLights
+	savedFog := fr.Fog
+
+	// the passes set ActiveLights themselves so Lights must not override it
+	savedLightList := fr.Lights
+	fr.Lights = nil
+
+	// the ambient pass lays down the depth for the volumes to test against
+	fr.Profiler.Begin("shadow volumes")
+	for i := range fr.ActiveLights {
+		fr.ActiveLights[i] = nil
+	}
+	for i, l := range lights {
+		ss.lights[i] = *l
+		ss.lights[i].ShadowMap = nil
+		ss.lights[i].DiffuseIntensity = 0.0
+		ss.lights[i].SpecularIntensity = 0.0
+		fr.ActiveLights[i] = &ss.lights[i]
+	}
+	drawScene()
+
+	// additive passes must not add the fog color again
+	fr.Fog.Color = mgl.Vec4{0.0, 0.0, 0.0, 0.0}
+
+	for i, l := range lights {
+		for _, sv := range casters {
+			sv.Update(lightVector(l))
+		}
+		ss.drawVolumes(casters, perspective, view)
+
+		// light only the pixels outside of the volumes
+		ss.lights[i] = *l
+		ss.lights[i].ShadowMap = nil
+		ss.lights[i].AmbientIntensity = 0.0
+		for j := range fr.ActiveLights {
+			fr.ActiveLights[j] = nil
+		}
+		fr.ActiveLights[0] = &ss.lights[i]
+
+		gfx.StencilFunc(graphics.EQUAL, 0, 0xff)
+		gfx.StencilOpSeparate(graphics.FRONT_AND_BACK, graphics.KEEP, graphics.KEEP, graphics.KEEP)
+		gfx.DepthFunc(graphics.LEQUAL)
+		gfx.DepthMask(false)
+		gfx.Enable(graphics.BLEND)
+		gfx.BlendFunc(graphics.ONE, graphics.ONE)
+		drawScene()
+		gfx.Disable(graphics.BLEND)
+		gfx.DepthMask(true)
+		gfx.DepthFunc(graphics.LESS)
+		gfx.Disable(graphics.STENCIL_TEST)
+	}
+
+	fr.ActiveLights = savedLights
+	fr.Lights = savedLightList
+	fr.Fog = savedFog
+	fr.Profiler.End()
+}
+
+// drawVolumes fills the stencil buffer with the shadow volumes using the
+// depth-fail method: back faces behind the scene increment and front faces
+// behind the scene decrement, leaving non-zero values in the shadows.
+func (ss *StencilShadows) drawVolumes(casters []*ShadowVolume, perspective mgl.Mat4, view mgl.Mat4) {
+	gfx := ss.gfx
+	gfx.ClearStencil(0)
+	gfx.Clear(graphics.STENCIL_BUFFER_BIT)
+	gfx.Enable(graphics.STENCIL_TEST)
+	gfx.Enable(graphics.DEPTH_CLAMP)
+	gfx.Disable(graphics.CULL_FACE)
+	gfx.ColorMask(false, false, false, false)
+	gfx.DepthMask(false)
+	gfx.StencilFunc(graphics.ALWAYS, 0, 0xff)
+	gfx.StencilOpSeparate(graphics.BACK, graphics.KEEP, graphics.INCR_WRAP, graphics.KEEP)
+	gfx.StencilOpSeparate(graphics.FRONT, graphics.KEEP, graphics.DECR_WRAP, graphics.KEEP)
+
+	gfx.UseProgram(ss.shader.Prog)
+	shaderMVP := ss.shader.GetUniformLocation("MVP_MATRIX")
+	shaderPosition := ss.shader.GetAttribLocation("VERTEX_POSITION")
+	vp := perspective.Mul4(view)
+	for _, sv := range casters {
+		if sv.vertexCount == 0 || !sv.Caster.IsVisible {
+			continue
+		}
+		mvp := vp.Mul4(sv.Caster.GetTransformMat4())
+		if shaderMVP >= 0 {
+			gfx.UniformMatrix4fv(shaderMVP, 1, false, mvp)
+		}
+
+		gfx.BindVertexArray(sv.vao)
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, sv.vbo)
+		gfx.EnableVertexAttribArray(uint32(shaderPosition))
+		gfx.VertexAttribPointer(uint32(shaderPosition), 4, graphics.FLOAT, false, 0, gfx.PtrOffset(0))
+		gfx.DrawArrays(graphics.TRIANGLES, 0, sv.vertexCount)
+	}
+	gfx.BindVertexArray(0)
+
+	gfx.ColorMask(true, true, true, true)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.CULL_FACE)
+	gfx.Disable(graphics.DEPTH_CLAMP)
+}
+
+// lightVector returns the light as a position with a W of 1, or as a
+// direction with a W of 0 if the light has a direction set, matching how
+// the built-in shaders treat lights.
+func lightVector(l *Light) mgl.Vec4 {
+	if l.Direction.Len() > 0.0 {
+		return l.Direction.Vec4(0.0)
+	}
+	return l.Position.Vec4(1.0)
+}
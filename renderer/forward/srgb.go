@@ -0,0 +1,32 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// EnableSRGBFramebuffer makes the GPU convert the linear colors written to
+// sRGB capable framebuffers to sRGB, which is the output half of a linear
+// lighting pipeline whose color textures are loaded as sRGB. The window
+// has to be created sRGB capable, like with the glfw SRGBCapable hint, for
+// the conversion to apply to it. The HDR resolve's Gamma should be set to
+// 1.0 so the colors aren't corrected twice.
+func (fr *ForwardRenderer) EnableSRGBFramebuffer() {
+	fr.gfx.Enable(graphics.FRAMEBUFFER_SRGB)
+	fr.srgbFramebuffer = true
+}
+
+// DisableSRGBFramebuffer stops converting the colors written to the
+// framebuffers.
+func (fr *ForwardRenderer) DisableSRGBFramebuffer() {
+	fr.gfx.Disable(graphics.FRAMEBUFFER_SRGB)
+	fr.srgbFramebuffer = false
+}
+
+// IsSRGBFramebufferEnabled returns true if the colors written to sRGB
+// capable framebuffers are converted to sRGB.
+func (fr *ForwardRenderer) IsSRGBFramebufferEnabled() bool {
+	return fr.srgbFramebuffer
+}
@@ -0,0 +1,68 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// EnableSSAO creates the screen space ambient occlusion pass at the quality
+// preset. Shaders that declare the AMBIENT_OCCLUSION_TEX sampler multiply it
+// into their ambient light once a depth prepass was drawn between
+// BeginSSAODepthPass and EndSSAODepthPass in the frame.
+func (fr *ForwardRenderer) EnableSSAO(quality renderer.SSAOQuality) error {
+	if fr.ssao != nil {
+		fr.ssao.SetQuality(quality)
+		return nil
+	}
+	ssao, err := renderer.NewSSAO(fr.gfx, fr.width, fr.height)
+	if err != nil {
+		return err
+	}
+	ssao.SetQuality(quality)
+	fr.ssao = ssao
+	fr.resizables.Register(ssao)
+	return nil
+}
+
+// DisableSSAO releases the ambient occlusion pass. Shaders sampling
+// AMBIENT_OCCLUSION_TEX get AMBIENT_OCCLUSION_ENABLED set to 0 afterwards.
+func (fr *ForwardRenderer) DisableSSAO() {
+	if fr.ssao == nil {
+		return
+	}
+	fr.resizables.Unregister(fr.ssao)
+	fr.ssao.Destroy()
+	fr.ssao = nil
+}
+
+// GetSSAO returns the ambient occlusion pass so that its samples, radius
+// and bias can be tuned, or nil if it isn't enabled.
+func (fr *ForwardRenderer) GetSSAO() *renderer.SSAO {
+	return fr.ssao
+}
+
+// BeginSSAODepthPass binds the depth prepass framebuffer of the ambient
+// occlusion. The opaque Renderables should be drawn until EndSSAODepthPass;
+// color writes are masked so the cheapest shader will do. It does nothing
+// if SSAO isn't enabled.
+func (fr *ForwardRenderer) BeginSSAODepthPass() {
+	if fr.ssao == nil {
+		return
+	}
+	fr.ssao.BeginDepth()
+}
+
+// EndSSAODepthPass computes the ambient occlusion from the depth prepass
+// drawn with the perspective and binds the default framebuffer again. It
+// does nothing if SSAO isn't enabled.
+func (fr *ForwardRenderer) EndSSAODepthPass(perspective mgl.Mat4) {
+	if fr.ssao == nil {
+		return
+	}
+	fr.Profiler.Begin("ssao")
+	fr.ssao.EndDepth(fr, perspective)
+	fr.Profiler.End()
+}
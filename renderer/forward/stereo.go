@@ -0,0 +1,28 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// StereoDrawFunc draws the scene for one eye with its projection, view
+// matrix and camera.
+type StereoDrawFunc func(eye renderer.Eye, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
+
+// DrawStereo draws the scene once per eye of the rig into the eye's render
+// target, with the eye matrices derived from the view matrix of the head,
+// and then hands the eye textures to the rig's Submitter. The window isn't
+// drawn to; use the rig's BlitToWindow for a mirror view.
+func (fr *ForwardRenderer) DrawStereo(rig *renderer.StereoRig, headView mgl.Mat4, drawScene StereoDrawFunc) error {
+	for _, eye := range []renderer.Eye{renderer.LeftEye, renderer.RightEye} {
+		view := rig.GetEyeView(eye, headView)
+		fr.SetRenderTarget(rig.GetTarget(eye))
+		drawScene(eye, rig.GetEyeProjection(eye), view, renderer.NewEyeCamera(view))
+	}
+	fr.ClearRenderTarget()
+	return rig.Submit()
+}
@@ -0,0 +1,93 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	"github.com/tbogdala/fizzle"
+)
+
+var (
+	// ThickLineVertShader330 is the GLSL vertex shader for drawing lines
+	// wider than one pixel.
+	ThickLineVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  uniform vec4 MATERIAL_DIFFUSE;
+  in vec3 VERTEX_POSITION;
+
+  out vec4 vs_color;
+
+  void main()
+  {
+    vs_color = MATERIAL_DIFFUSE;
+    gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+  }`
+
+	// ThickLineGeomShader330 is the GLSL geometry shader that expands each
+	// line segment into a screen aligned quad LINE_WIDTH pixels wide, since
+	// core profiles don't support glLineWidth above one. It works for the
+	// LINES and LINE_STRIP topologies.
+	ThickLineGeomShader330 = `#version 330
+  layout(lines) in;
+  layout(triangle_strip, max_vertices = 4) out;
+
+  uniform float LINE_WIDTH;
+  uniform vec2 VIEWPORT_SIZE;
+
+  in vec4 vs_color[];
+  out vec4 gs_color;
+
+  void main()
+  {
+    vec4 p0 = gl_in[0].gl_Position;
+    vec4 p1 = gl_in[1].gl_Position;
+
+    // offset perpendicular to the segment in pixels, then back to clip space
+    vec2 dir = (p1.xy / p1.w - p0.xy / p0.w) * VIEWPORT_SIZE;
+    if (dot(dir, dir) < 0.0001) {
+      dir = vec2(1.0, 0.0);
+    }
+    vec2 normal = normalize(vec2(-dir.y, dir.x)) * max(LINE_WIDTH, 1.0) / VIEWPORT_SIZE;
+
+    gs_color = vs_color[0];
+    gl_Position = vec4(p0.xy + normal * p0.w, p0.zw);
+    EmitVertex();
+    gl_Position = vec4(p0.xy - normal * p0.w, p0.zw);
+    EmitVertex();
+    gs_color = vs_color[1];
+    gl_Position = vec4(p1.xy + normal * p1.w, p1.zw);
+    EmitVertex();
+    gl_Position = vec4(p1.xy - normal * p1.w, p1.zw);
+    EmitVertex();
+    EndPrimitive();
+  }`
+
+	// ThickLineFragShader330 is the GLSL fragment shader for thick lines.
+	ThickLineFragShader330 = `#version 330
+  in vec4 gs_color;
+  out vec4 frag_color;
+
+  void main()
+  {
+    frag_color = gs_color;
+  }`
+)
+
+// NewThickLineShader compiles the thick line shaders. Renderables with a
+// line Topology drawn with it are LineWidth pixels wide.
+// NOTE: requires geometry shader support (not OpenGL ES 2).
+func NewThickLineShader() (*fizzle.RenderShader, error) {
+	return fizzle.LoadShaderProgramWithGeometry(ThickLineVertShader330, ThickLineGeomShader330, ThickLineFragShader330, nil)
+}
+
+// bindLineWidth sets the LINE_WIDTH and VIEWPORT_SIZE uniforms of thick
+// line shaders from the Renderable and the renderer's resolution.
+func (fr *ForwardRenderer) bindLineWidth(r *fizzle.Renderable, shader *fizzle.RenderShader) {
+	gfx := fr.gfx
+	if loc := shader.GetUniformLocation("LINE_WIDTH"); loc >= 0 {
+		gfx.Uniform1f(loc, r.Core.LineWidth)
+	}
+	if loc := shader.GetUniformLocation("VIEWPORT_SIZE"); loc >= 0 {
+		gfx.Uniform2f(loc, float32(fr.width), float32(fr.height))
+	}
+}
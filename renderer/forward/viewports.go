@@ -0,0 +1,52 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+// ViewportDrawFunc draws the scene for a viewport with the projection and
+// view matrix of its camera.
+type ViewportDrawFunc func(vp *renderer.Viewport, perspective mgl.Mat4, view mgl.Mat4)
+
+// DrawViewports calls drawScene once per viewport with the GL viewport and
+// scissor set to the viewport's part of the window, or of the active
+// render target, so that split screen and multi pane views don't need to
+// change the renderer's resolution. Renderables whose ViewLayer isn't in a
+// viewport's LayerMask are skipped while it's drawn.
+//
+// It can be called between BeginScene and EndScene so that the post
+// process effects are applied to all of the viewports at once.
+func (fr *ForwardRenderer) DrawViewports(viewports []*renderer.Viewport, drawScene ViewportDrawFunc) {
+	gfx := fr.gfx
+	width, height := fr.width, fr.height
+	if fr.renderTarget != nil {
+		width, height = fr.renderTarget.GetSize()
+	}
+
+	gfx.Enable(graphics.SCISSOR_TEST)
+	for _, vp := range viewports {
+		x, y, w, h := vp.Pixels(width, height)
+		gfx.Viewport(x, y, w, h)
+		gfx.Scissor(x, y, w, h)
+		if vp.Clear {
+			gfx.ClearColor(vp.ClearColor[0], vp.ClearColor[1], vp.ClearColor[2], vp.ClearColor[3])
+			gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT | graphics.STENCIL_BUFFER_BIT)
+		}
+
+		fr.viewport = vp
+		drawScene(vp, vp.Perspective(w, h), vp.Camera.GetViewMatrix())
+	}
+	fr.viewport = nil
+	gfx.Disable(graphics.SCISSOR_TEST)
+	gfx.Viewport(0, 0, width, height)
+}
+
+// GetViewport returns the viewport being drawn by DrawViewports or nil.
+func (fr *ForwardRenderer) GetViewport() *renderer.Viewport {
+	return fr.viewport
+}
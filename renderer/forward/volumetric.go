@@ -0,0 +1,242 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package forward
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	renderer "github.com/tbogdala/fizzle/renderer"
+)
+
+var (
+	// VolumetricFragShader330 is the GLSL fragment shader for the volumetric
+	// pass. It marches from the camera to the surface of every pixel,
+	// reconstructed from the depth texture, sampling the light's shadow map
+	// along the way and accumulating the light scattered towards the camera.
+	// The start of the march is dithered per pixel to trade banding for noise.
+	// It uses the fog pass vertex shader to draw a full screen quad.
+	VolumetricFragShader330 = `#version 330
+  uniform mat4 VP_MATRIX;
+  uniform vec3 CAMERA_WORLD_POSITION;
+  uniform sampler2D VOLUMETRIC_DEPTH_TEX;
+  uniform sampler2DShadow VOLUMETRIC_SHADOW_MAP;
+  uniform mat4 VOLUMETRIC_SHADOW_MATRIX;
+  uniform vec4 VOLUMETRIC_SHADOW_RECT;
+  uniform vec3 VOLUMETRIC_LIGHT_POSITION;
+  uniform vec3 VOLUMETRIC_LIGHT_DIRECTION;
+  uniform vec3 VOLUMETRIC_LIGHT_COLOR;
+  uniform int VOLUMETRIC_DIRECTIONAL;
+  uniform vec2 VOLUMETRIC_SPOT_CUTOFF;
+  uniform vec4 VOLUMETRIC_FALLOFF;
+  uniform vec2 VOLUMETRIC_SCATTERING;
+  uniform int VOLUMETRIC_STEPS;
+  uniform float VOLUMETRIC_MAX_DISTANCE;
+
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  const float PI = 3.14159265;
+
+  // PhaseHG is the Henyey-Greenstein phase function.
+  float PhaseHG(float cosTheta, float g)
+  {
+    float g2 = g * g;
+    return (1.0 - g2) / (4.0 * PI * pow(max(1.0 + g2 - 2.0 * g * cosTheta, 0.0001), 1.5));
+  }
+
+  float CalcAttenuation(float dist)
+  {
+    vec4 falloff = VOLUMETRIC_FALLOFF;
+    float attenuation = 1.0 / max(falloff.x + falloff.y * dist + falloff.z * dist * dist, 0.0001);
+    if (falloff.w > 0.0) {
+      float ratio = dist / falloff.w;
+      float window = clamp(1.0 - ratio * ratio * ratio * ratio, 0.0, 1.0);
+      attenuation *= window * window;
+    }
+    return attenuation;
+  }
+
+  void main()
+  {
+    float depth = texture(VOLUMETRIC_DEPTH_TEX, vs_uv).r;
+    vec4 world = inverse(VP_MATRIX) * vec4(vs_uv * 2.0 - 1.0, depth * 2.0 - 1.0, 1.0);
+    vec3 ray = world.xyz / world.w - CAMERA_WORLD_POSITION;
+    float dist = min(length(ray), VOLUMETRIC_MAX_DISTANCE);
+    vec3 dir = normalize(ray);
+
+    int steps = max(VOLUMETRIC_STEPS, 1);
+    float stepLength = dist / float(steps);
+    float jitter = fract(52.9829189 * fract(dot(gl_FragCoord.xy, vec2(0.06711056, 0.00583715))));
+    float density = VOLUMETRIC_SCATTERING.x;
+
+    vec3 scattered = vec3(0.0);
+    float transmittance = 1.0;
+    for (int i = 0; i < steps; i++) {
+      vec3 p = CAMERA_WORLD_POSITION + dir * ((float(i) + jitter) * stepLength);
+
+      vec4 shadow_coord = VOLUMETRIC_SHADOW_MATRIX * vec4(p, 1.0);
+      float lit = 0.0;
+      if (shadow_coord.w > 0.0) {
+        vec2 uv = shadow_coord.xy / shadow_coord.w;
+        if (any(lessThan(uv, VOLUMETRIC_SHADOW_RECT.xy)) || any(greaterThan(uv, VOLUMETRIC_SHADOW_RECT.zw))) {
+          lit = 1.0;
+        } else {
+          lit = textureProj(VOLUMETRIC_SHADOW_MAP, shadow_coord);
+        }
+      }
+
+      vec3 to_light;
+      float attenuation = 1.0;
+      if (VOLUMETRIC_DIRECTIONAL != 0) {
+        to_light = -normalize(VOLUMETRIC_LIGHT_DIRECTION);
+      } else {
+        vec3 s = VOLUMETRIC_LIGHT_POSITION - p;
+        to_light = normalize(s);
+        attenuation = CalcAttenuation(length(s));
+        if (VOLUMETRIC_SPOT_CUTOFF.y > 0.0) {
+          float cosAngle = dot(-to_light, normalize(VOLUMETRIC_LIGHT_DIRECTION));
+          attenuation *= smoothstep(VOLUMETRIC_SPOT_CUTOFF.y, VOLUMETRIC_SPOT_CUTOFF.x, cosAngle);
+        }
+      }
+
+      float phase = PhaseHG(dot(dir, to_light), VOLUMETRIC_SCATTERING.y);
+      scattered += transmittance * lit * attenuation * phase * density * stepLength * VOLUMETRIC_LIGHT_COLOR;
+      transmittance *= exp(-density * stepLength);
+    }
+
+    frag_color = vec4(scattered, 0.0);
+  }`
+)
+
+// VolumetricPass draws light shafts, or god rays, for the lights that have
+// a ScatteringDensity and a ShadowMap. For every such light it ray-marches
+// the view rays through the light's shadow map and adds the scattered light
+// over the bound framebuffer, so it should be drawn after the scene.
+type VolumetricPass struct {
+	// Steps is the number of shadow map samples taken along each view ray.
+	Steps int
+
+	// MaxDistance limits how far from the camera the view rays are marched.
+	MaxDistance float32
+
+	shader *fizzle.RenderShader
+	quad   *fizzle.Renderable
+
+	// depthTex and light are what's being drawn for the uniform binder
+	depthTex graphics.Texture
+	light    *Light
+}
+
+// NewVolumetricPass compiles the volumetric pass shader.
+func NewVolumetricPass() (*VolumetricPass, error) {
+	shader, err := fizzle.LoadShaderProgram(FogPassVertShader330, VolumetricFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	vp := new(VolumetricPass)
+	vp.Steps = 32
+	vp.MaxDistance = 100.0
+	vp.shader = shader
+	vp.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	return vp, nil
+}
+
+// Destroy releases the shader and quad used by the volumetric pass.
+func (vp *VolumetricPass) Destroy() {
+	vp.shader.Destroy()
+	vp.quad.Destroy()
+}
+
+// Draw adds the light shafts of the renderer's Lights, or of its
+// ActiveLights if Lights isn't set, over the whole viewport. The scene's
+// depth is read from depthTex, which must not be attached to the bound
+// framebuffer. The perspective, view and camera should be the ones the
+// scene was drawn with, and the shadow maps should be up to date.
+func (vp *VolumetricPass) Draw(fr *ForwardRenderer, depthTex graphics.Texture, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	lights := fr.Lights
+	if len(lights) == 0 {
+		lights = fr.ActiveLights[:]
+	}
+
+	gfx := fr.GetGraphics()
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendFunc(graphics.ONE, graphics.ONE)
+	for _, l := range lights {
+		if l == nil || l.ShadowMap == nil || l.ScatteringDensity <= 0.0 {
+			continue
+		}
+		vp.depthTex = depthTex
+		vp.light = l
+		fr.DrawRenderableWithShader(vp.quad, vp.shader, vp.bindUniforms, perspective, view, camera)
+	}
+	vp.light = nil
+	gfx.Disable(graphics.BLEND)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// bindUniforms binds the scene depth and the light being drawn for the
+// volumetric pass shader.
+func (vp *VolumetricPass) bindUniforms(rend renderer.Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := rend.GetGraphics()
+	l := vp.light
+	if loc := shader.GetUniformLocation("VOLUMETRIC_DEPTH_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, vp.depthTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_SHADOW_MAP"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, l.ShadowMap.Texture)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_SHADOW_MATRIX"); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &l.ShadowMap.samplerMatrix)
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_SHADOW_RECT"); loc >= 0 {
+		r := l.ShadowMap.samplerRect
+		gfx.Uniform4f(loc, r[0], r[1], r[2], r[3])
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_LIGHT_POSITION"); loc >= 0 {
+		gfx.Uniform3f(loc, l.Position[0], l.Position[1], l.Position[2])
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_LIGHT_DIRECTION"); loc >= 0 {
+		gfx.Uniform3f(loc, l.Direction[0], l.Direction[1], l.Direction[2])
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_LIGHT_COLOR"); loc >= 0 {
+		c := l.DiffuseColor.Vec3().Mul(l.DiffuseIntensity)
+		gfx.Uniform3f(loc, c[0], c[1], c[2])
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_DIRECTIONAL"); loc >= 0 {
+		directional := int32(0)
+		if !l.IsSpot() && l.Direction.Len() > 0.0 {
+			directional = 1
+		}
+		gfx.Uniform1i(loc, directional)
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_SPOT_CUTOFF"); loc >= 0 {
+		innerCos, outerCos := l.spotCutoff()
+		gfx.Uniform2f(loc, innerCos, outerCos)
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_FALLOFF"); loc >= 0 {
+		constant, linear, quadratic, lightRange := l.falloff()
+		gfx.Uniform4f(loc, constant, linear, quadratic, lightRange)
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_SCATTERING"); loc >= 0 {
+		g := mgl.Clamp(l.ScatteringAnisotropy, -0.99, 0.99)
+		gfx.Uniform2f(loc, l.ScatteringDensity, g)
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_STEPS"); loc >= 0 {
+		gfx.Uniform1i(loc, int32(vp.Steps))
+	}
+	if loc := shader.GetUniformLocation("VOLUMETRIC_MAX_DISTANCE"); loc >= 0 {
+		gfx.Uniform1f(loc, vp.MaxDistance)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// Frustum is the volume seen through a projection and view matrix, stored
+// as its six planes, which is used to skip drawing objects outside of it.
+type Frustum struct {
+	// Planes are the left, right, bottom, top, near and far planes with
+	// their normals pointing into the frustum; a point p is inside a plane
+	// when dot(plane.xyz, p) + plane.w is positive.
+	Planes [6]mgl.Vec4
+}
+
+// NewFrustum extracts the frustum planes from the combined projection and
+// view matrix, perspective * view.
+func NewFrustum(viewProjection mgl.Mat4) Frustum {
+	var f Frustum
+	f.Update(viewProjection)
+	return f
+}
+
+// Update extracts the frustum planes from the combined projection and view
+// matrix, perspective * view.
+func (f *Frustum) Update(viewProjection mgl.Mat4) {
+	// every plane is the sum or difference of the last row of the matrix
+	// and one of the others, from the clip space -w <= x,y,z <= w tests
+	r0 := viewProjection.Row(0)
+	r1 := viewProjection.Row(1)
+	r2 := viewProjection.Row(2)
+	r3 := viewProjection.Row(3)
+	f.Planes[0] = r3.Add(r0)
+	f.Planes[1] = r3.Sub(r0)
+	f.Planes[2] = r3.Add(r1)
+	f.Planes[3] = r3.Sub(r1)
+	f.Planes[4] = r3.Add(r2)
+	f.Planes[5] = r3.Sub(r2)
+
+	for i := range f.Planes {
+		l := f.Planes[i].Vec3().Len()
+		if l > 0.0 {
+			f.Planes[i] = f.Planes[i].Mul(1.0 / l)
+		}
+	}
+}
+
+// ContainsPoint returns true if the point is inside the frustum.
+func (f *Frustum) ContainsPoint(p mgl.Vec3) bool {
+	for _, plane := range f.Planes {
+		if plane.Vec3().Dot(p)+plane[3] < 0.0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsSphere returns true if any part of the sphere is inside the
+// frustum.
+func (f *Frustum) IntersectsSphere(center mgl.Vec3, radius float32) bool {
+	for _, plane := range f.Planes {
+		if plane.Vec3().Dot(center)+plane[3] < -radius {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsRect returns true if any part of the axis aligned rectangle is
+// inside the frustum. Rectangles near the corners of the frustum may be
+// reported as intersecting when they're just outside of it, which is fine
+// for culling.
+func (f *Frustum) IntersectsRect(rect fizzle.Rectangle3D) bool {
+	for _, plane := range f.Planes {
+		// test the corner furthest along the plane's normal
+		var p mgl.Vec3
+		for i := 0; i < 3; i++ {
+			if plane[i] >= 0.0 {
+				p[i] = rect.Top[i]
+			} else {
+				p[i] = rect.Bottom[i]
+			}
+		}
+		if plane.Vec3().Dot(p)+plane[3] < 0.0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRenderableVisible returns true if the bounds of the Renderable node,
+// its CullBounds or else its BoundingRect, intersect the frustum once
+// transformed to world space. Nodes without bounds are always visible;
+// groups should be tested by their children.
+func (f *Frustum) IsRenderableVisible(r *fizzle.Renderable) bool {
+	bounds := r.CullBounds
+	if bounds.IsEmpty() {
+		bounds = r.BoundingRect
+	}
+	if bounds.IsEmpty() {
+		return true
+	}
+	return f.IntersectsRect(bounds.Transform(r.GetTransformMat4()))
+}
@@ -0,0 +1,222 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+var (
+	// FXAAFragShader330 is the GLSL fragment shader that smooths the jagged
+	// edges of POST_COLOR_TEX. Edges are found from the contrast of the
+	// luma with the neighboring pixels, searched along in both directions
+	// to find their ends, and the pixel is blended across the edge by how
+	// close it is to the end. Small details get an extra subpixel blend.
+	FXAAFragShader330 = `#version 330
+  uniform sampler2D POST_COLOR_TEX;
+  uniform vec2 POST_TEXEL_SIZE;
+  uniform float FXAA_EDGE_THRESHOLD;
+  uniform float FXAA_EDGE_THRESHOLD_MIN;
+  uniform float FXAA_SUBPIXEL;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  const int SearchSteps = 10;
+
+  float Luma(vec3 color)
+  {
+    // perceived brightness of the gamma corrected color
+    return sqrt(dot(clamp(color, 0.0, 1.0), vec3(0.299, 0.587, 0.114)));
+  }
+
+  float LumaAt(vec2 uv)
+  {
+    return Luma(texture(POST_COLOR_TEX, uv).rgb);
+  }
+
+  void main()
+  {
+    vec4 center = texture(POST_COLOR_TEX, vs_uv);
+    float lumaM = Luma(center.rgb);
+    float lumaN = LumaAt(vs_uv + vec2(0.0, POST_TEXEL_SIZE.y));
+    float lumaS = LumaAt(vs_uv - vec2(0.0, POST_TEXEL_SIZE.y));
+    float lumaE = LumaAt(vs_uv + vec2(POST_TEXEL_SIZE.x, 0.0));
+    float lumaW = LumaAt(vs_uv - vec2(POST_TEXEL_SIZE.x, 0.0));
+
+    float lumaMin = min(lumaM, min(min(lumaN, lumaS), min(lumaE, lumaW)));
+    float lumaMax = max(lumaM, max(max(lumaN, lumaS), max(lumaE, lumaW)));
+    float range = lumaMax - lumaMin;
+    if (range < max(FXAA_EDGE_THRESHOLD_MIN, lumaMax * FXAA_EDGE_THRESHOLD)) {
+      frag_color = center;
+      return;
+    }
+
+    float lumaNE = LumaAt(vs_uv + POST_TEXEL_SIZE);
+    float lumaSW = LumaAt(vs_uv - POST_TEXEL_SIZE);
+    float lumaNW = LumaAt(vs_uv + vec2(-POST_TEXEL_SIZE.x, POST_TEXEL_SIZE.y));
+    float lumaSE = LumaAt(vs_uv + vec2(POST_TEXEL_SIZE.x, -POST_TEXEL_SIZE.y));
+
+    // the subpixel blend is for pixels that differ from all of their
+    // neighbors, like thin lines and sparkling highlights
+    float average = (2.0 * (lumaN + lumaS + lumaE + lumaW) + lumaNE + lumaSW + lumaNW + lumaSE) / 12.0;
+    float subpixel = clamp(abs(average - lumaM) / range, 0.0, 1.0);
+    subpixel = smoothstep(0.0, 1.0, subpixel);
+    subpixel = subpixel * subpixel * FXAA_SUBPIXEL;
+
+    // decide whether the edge runs horizontally or vertically
+    float edgeHorizontal = abs(lumaNW + lumaNE - 2.0 * lumaN) + 2.0 * abs(lumaW + lumaE - 2.0 * lumaM) + abs(lumaSW + lumaSE - 2.0 * lumaS);
+    float edgeVertical = abs(lumaNW + lumaSW - 2.0 * lumaW) + 2.0 * abs(lumaN + lumaS - 2.0 * lumaM) + abs(lumaNE + lumaSE - 2.0 * lumaE);
+    bool horizontal = edgeHorizontal >= edgeVertical;
+
+    float luma1 = horizontal ? lumaS : lumaW;
+    float luma2 = horizontal ? lumaN : lumaE;
+    float gradient1 = abs(luma1 - lumaM);
+    float gradient2 = abs(luma2 - lumaM);
+    float stepLength = horizontal ? POST_TEXEL_SIZE.y : POST_TEXEL_SIZE.x;
+    float lumaLocal;
+    if (gradient1 >= gradient2) {
+      stepLength = -stepLength;
+      lumaLocal = 0.5 * (luma1 + lumaM);
+    } else {
+      lumaLocal = 0.5 * (luma2 + lumaM);
+    }
+    float gradientScaled = 0.25 * max(gradient1, gradient2);
+
+    // walk along the edge, half a pixel over, until the luma changes
+    vec2 edgeUV = vs_uv;
+    vec2 offset;
+    if (horizontal) {
+      edgeUV.y += stepLength * 0.5;
+      offset = vec2(POST_TEXEL_SIZE.x, 0.0);
+    } else {
+      edgeUV.x += stepLength * 0.5;
+      offset = vec2(0.0, POST_TEXEL_SIZE.y);
+    }
+
+    vec2 uv1 = edgeUV - offset;
+    vec2 uv2 = edgeUV + offset;
+    float lumaEnd1 = LumaAt(uv1) - lumaLocal;
+    float lumaEnd2 = LumaAt(uv2) - lumaLocal;
+    bool reached1 = abs(lumaEnd1) >= gradientScaled;
+    bool reached2 = abs(lumaEnd2) >= gradientScaled;
+    for (int i = 1; i < SearchSteps && !(reached1 && reached2); i++) {
+      float stride = i < 4 ? 1.0 : 2.0;
+      if (!reached1) {
+        uv1 -= offset * stride;
+        lumaEnd1 = LumaAt(uv1) - lumaLocal;
+        reached1 = abs(lumaEnd1) >= gradientScaled;
+      }
+      if (!reached2) {
+        uv2 += offset * stride;
+        lumaEnd2 = LumaAt(uv2) - lumaLocal;
+        reached2 = abs(lumaEnd2) >= gradientScaled;
+      }
+    }
+
+    float distance1 = horizontal ? (vs_uv.x - uv1.x) : (vs_uv.y - uv1.y);
+    float distance2 = horizontal ? (uv2.x - vs_uv.x) : (uv2.y - vs_uv.y);
+    bool nearest1 = distance1 < distance2;
+    float distanceNearest = min(distance1, distance2);
+    float edgeLength = distance1 + distance2;
+
+    // only blend when the end nearest to the pixel turns the right way
+    bool centerSmaller = lumaM < lumaLocal;
+    bool correctVariation = ((nearest1 ? lumaEnd1 : lumaEnd2) < 0.0) != centerSmaller;
+    float edgeBlend = correctVariation ? (0.5 - distanceNearest / edgeLength) : 0.0;
+
+    float blend = max(edgeBlend, subpixel);
+    vec2 uv = vs_uv;
+    if (horizontal) {
+      uv.y += blend * stepLength;
+    } else {
+      uv.x += blend * stepLength;
+    }
+    frag_color = vec4(texture(POST_COLOR_TEX, uv).rgb, center.a);
+  }`
+)
+
+// FXAA is a PostEffect that smooths the jagged edges of the scene after
+// it's drawn. It's cheaper than MSAA and also catches the aliasing of
+// shader output, like specular highlights and alpha cutouts, but it blurs
+// the image slightly. It should be the last effect in the chain, after the
+// image is tonemapped, since it judges edges by their perceived brightness.
+type FXAA struct {
+	// EdgeThreshold is the contrast, relative to the brightest neighbor,
+	// needed for a pixel to be smoothed. Lower values smooth more edges.
+	EdgeThreshold float32
+
+	// EdgeThresholdMin is the contrast below which dark pixels are never
+	// smoothed.
+	EdgeThresholdMin float32
+
+	// Subpixel is how strongly details smaller than a pixel are blurred,
+	// from 0.0 for none to 1.0 for the softest image.
+	Subpixel float32
+
+	gfx    graphics.GraphicsProvider
+	shader *fizzle.RenderShader
+
+	// bindFn is the method value of the binder, saved so that it isn't
+	// allocated every frame
+	bindFn RenderBinder
+}
+
+// NewFXAA returns an FXAA effect with default parameters. It gets its
+// graphics resources when it's added to a PostProcessStack.
+func NewFXAA() *FXAA {
+	fxaa := new(FXAA)
+	fxaa.EdgeThreshold = 0.125
+	fxaa.EdgeThresholdMin = 0.0312
+	fxaa.Subpixel = 0.75
+	return fxaa
+}
+
+// Init compiles the FXAA shader.
+func (fxaa *FXAA) Init(gfx graphics.GraphicsProvider, width, height int32) error {
+	fxaa.gfx = gfx
+	fxaa.bindFn = fxaa.bind
+	if fxaa.shader == nil {
+		shader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, FXAAFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the FXAA shader: %v", err)
+		}
+		fxaa.shader = shader
+	}
+	return nil
+}
+
+// Destroy releases the shader.
+func (fxaa *FXAA) Destroy() {
+	if fxaa.shader != nil {
+		fxaa.shader.Destroy()
+		fxaa.shader = nil
+	}
+}
+
+// Resize does nothing since FXAA has no textures of its own.
+func (fxaa *FXAA) Resize(width, height int32) error {
+	return nil
+}
+
+// Apply draws the frame's Color with its edges smoothed.
+func (fxaa *FXAA) Apply(rend Renderer, frame *PostProcessFrame) {
+	frame.DrawQuad(rend, fxaa.shader, fxaa.bindFn)
+}
+
+// bind binds the edge thresholds and subpixel blend for the FXAA shader.
+func (fxaa *FXAA) bind(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := fxaa.gfx
+	if loc := shader.GetUniformLocation("FXAA_EDGE_THRESHOLD"); loc >= 0 {
+		gfx.Uniform1f(loc, fxaa.EdgeThreshold)
+	}
+	if loc := shader.GetUniformLocation("FXAA_EDGE_THRESHOLD_MIN"); loc >= 0 {
+		gfx.Uniform1f(loc, fxaa.EdgeThresholdMin)
+	}
+	if loc := shader.GetUniformLocation("FXAA_SUBPIXEL"); loc >= 0 {
+		gfx.Uniform1f(loc, fxaa.Subpixel)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// GrabPass copies the color of the scene drawn so far into a texture so
+// that shaders drawn afterwards, such as glass or heat distortion, can
+// sample what is behind them. The copy should happen after the opaque
+// geometry is drawn and before the transparent geometry.
+//
+// The texture is the full size of the framebuffer and a grab of a smaller
+// region lands in the same pixels, so shaders can use
+// gl_FragCoord.xy / textureSize(tex, 0) as the screen coordinate.
+type GrabPass struct {
+	gfx      graphics.GraphicsProvider
+	fbo      graphics.Buffer
+	colorTex graphics.Texture
+	width    int32
+	height   int32
+}
+
+// NewGrabPass creates the grab texture for a framebuffer of the given size.
+func NewGrabPass(gfx graphics.GraphicsProvider, width, height int32) (*GrabPass, error) {
+	gp := new(GrabPass)
+	gp.gfx = gfx
+	err := gp.Resize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	return gp, nil
+}
+
+// Destroy releases the framebuffer and texture.
+func (gp *GrabPass) Destroy() {
+	if gp.fbo != 0 {
+		gp.gfx.DeleteFramebuffer(gp.fbo)
+		gp.fbo = 0
+	}
+	if gp.colorTex != 0 {
+		gp.gfx.DeleteTexture(gp.colorTex)
+		gp.colorTex = 0
+	}
+}
+
+// Resize recreates the grab texture for a new framebuffer size.
+func (gp *GrabPass) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid grab pass size %dx%d", width, height)
+	}
+	gp.Destroy()
+	gp.width = width
+	gp.height = height
+
+	gfx := gp.gfx
+	gp.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, gp.fbo)
+
+	gp.colorTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, gp.colorTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA8, width, height, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, gp.colorTex, 0)
+
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "grab pass")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	return err
+}
+
+// Texture returns the texture holding the grabbed color.
+func (gp *GrabPass) Texture() graphics.Texture {
+	return gp.colorTex
+}
+
+// Grab copies the lower left width x height pixels of the source
+// framebuffer, 0 being the default framebuffer, into the texture and then
+// binds the source framebuffer again.
+func (gp *GrabPass) Grab(src graphics.Buffer, width, height int32) {
+	if width > gp.width {
+		width = gp.width
+	}
+	if height > gp.height {
+		height = gp.height
+	}
+
+	gfx := gp.gfx
+	gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, src)
+	gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, gp.fbo)
+	gfx.BlitFramebuffer(0, 0, width, height, 0, 0, width, height, graphics.COLOR_BUFFER_BIT, graphics.NEAREST)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, src)
+}
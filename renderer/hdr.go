@@ -0,0 +1,350 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// TonemapOperator selects the curve the HDR resolve uses to map the high
+// dynamic range scene into the displayable range.
+type TonemapOperator int32
+
+const (
+	// TonemapNone only applies the exposure and clips at 1.0.
+	TonemapNone TonemapOperator = iota
+
+	// TonemapReinhard uses the simple Reinhard curve, x / (1 + x).
+	TonemapReinhard
+
+	// TonemapACES uses Narkowicz's fit of the ACES filmic curve.
+	TonemapACES
+
+	// TonemapUncharted2 uses Hable's filmic curve from Uncharted 2 with
+	// WhitePoint as the linear white.
+	TonemapUncharted2
+)
+
+var (
+	// HDRVertShader330 is the GLSL vertex shader for the HDR resolve. It
+	// draws a full screen quad.
+	HDRVertShader330 = `#version 330
+  in vec3 VERTEX_POSITION;
+  out vec2 vs_uv;
+
+  void main()
+  {
+    vs_uv = VERTEX_POSITION.xy * 0.5 + 0.5;
+    gl_Position = vec4(VERTEX_POSITION.xy, 0.0, 1.0);
+  }`
+
+	// HDRFragShader330 is the GLSL fragment shader for the HDR resolve. It
+	// adds the bloom, if there is one, scales the scene by the exposure, maps it with the tonemapping operator
+	// in HDR_TONEMAP and then gamma corrects it.
+	HDRFragShader330 = `#version 330
+  uniform sampler2D HDR_COLOR_TEX;
+  uniform sampler2D HDR_BLOOM_TEX;
+  uniform float HDR_BLOOM_INTENSITY;
+  uniform int HDR_TONEMAP;
+  uniform float HDR_EXPOSURE;
+  uniform float HDR_WHITE_POINT;
+  uniform float HDR_GAMMA;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  vec3 Uncharted2Curve(vec3 x)
+  {
+    const float A = 0.15;
+    const float B = 0.50;
+    const float C = 0.10;
+    const float D = 0.20;
+    const float E = 0.02;
+    const float F = 0.30;
+    return ((x * (A * x + C * B) + D * E) / (x * (A * x + B) + D * F)) - E / F;
+  }
+
+  vec3 Tonemap(vec3 color)
+  {
+    if (HDR_TONEMAP == 1) {
+      return color / (vec3(1.0) + color);
+    } else if (HDR_TONEMAP == 2) {
+      const float a = 2.51;
+      const float b = 0.03;
+      const float c = 2.43;
+      const float d = 0.59;
+      const float e = 0.14;
+      return clamp((color * (a * color + b)) / (color * (c * color + d) + e), 0.0, 1.0);
+    } else if (HDR_TONEMAP == 3) {
+      const float ExposureBias = 2.0;
+      vec3 white = Uncharted2Curve(vec3(HDR_WHITE_POINT));
+      return Uncharted2Curve(color * ExposureBias) / white;
+    }
+    return clamp(color, 0.0, 1.0);
+  }
+
+  void main()
+  {
+    vec3 hdr = texture(HDR_COLOR_TEX, vs_uv).rgb;
+    if (HDR_BLOOM_INTENSITY > 0.0) {
+      hdr += texture(HDR_BLOOM_TEX, vs_uv).rgb * HDR_BLOOM_INTENSITY;
+    }
+    vec3 mapped = Tonemap(hdr * HDR_EXPOSURE);
+    frag_color = vec4(pow(mapped, vec3(1.0 / HDR_GAMMA)), 1.0);
+  }`
+)
+
+// HDR renders the scene into a floating point framebuffer so that lighting
+// can exceed 1.0 without clipping, and then resolves it to the bound
+// framebuffer with an exposure and a tonemapping operator. The scene is
+// drawn between Begin and End and Resolve draws the final image.
+//
+// Shaders drawing into it should write linear color; the resolve applies
+// the Gamma correction. HDR is Resizable so it can be registered to follow
+// the renderer's resolution.
+type HDR struct {
+	// Exposure scales the scene before it's tonemapped.
+	Exposure float32
+
+	// Operator is the tonemapping curve.
+	Operator TonemapOperator
+
+	// WhitePoint is the linear scene value that maps to white with
+	// TonemapUncharted2.
+	WhitePoint float32
+
+	// Gamma is the display gamma the resolved image is corrected for;
+	// 1.0 writes the tonemapped values unchanged.
+	Gamma float32
+
+	// Output is the framebuffer bound by End that Resolve writes to; 0,
+	// the default, is the window.
+	Output graphics.Buffer
+
+	// bloom, if set, is added to the scene before it's tonemapped
+	bloom *Bloom
+
+	gfx      graphics.GraphicsProvider
+	fbo      graphics.Buffer
+	colorTex graphics.Texture
+	depthRB  graphics.Buffer
+	width    int32
+	height   int32
+
+	shader *fizzle.RenderShader
+	quad   *fizzle.Renderable
+}
+
+// NewHDR compiles the resolve shader and creates the floating point
+// framebuffer of the given size. It defaults to an exposure of 1.0 with
+// the ACES operator and a gamma of 2.2.
+func NewHDR(gfx graphics.GraphicsProvider, width, height int32) (*HDR, error) {
+	shader, err := fizzle.LoadShaderProgram(HDRVertShader330, HDRFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := new(HDR)
+	hdr.gfx = gfx
+	hdr.Exposure = 1.0
+	hdr.Operator = TonemapACES
+	hdr.WhitePoint = 11.2
+	hdr.Gamma = 2.2
+	hdr.shader = shader
+	hdr.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+
+	err = hdr.Resize(width, height)
+	if err != nil {
+		hdr.Destroy()
+		return nil, err
+	}
+	return hdr, nil
+}
+
+// Destroy releases the shader, quad and framebuffer along with the bloom,
+// if one was set.
+func (hdr *HDR) Destroy() {
+	if hdr.bloom != nil {
+		hdr.bloom.Destroy()
+		hdr.bloom = nil
+	}
+	hdr.destroyFramebuffer()
+	hdr.shader.Destroy()
+	hdr.quad.Destroy()
+}
+
+// destroyFramebuffer releases the framebuffer and its attachments.
+func (hdr *HDR) destroyFramebuffer() {
+	if hdr.fbo != 0 {
+		hdr.gfx.DeleteFramebuffer(hdr.fbo)
+		hdr.fbo = 0
+	}
+	if hdr.colorTex != 0 {
+		hdr.gfx.DeleteTexture(hdr.colorTex)
+		hdr.colorTex = 0
+	}
+	if hdr.depthRB != 0 {
+		hdr.gfx.DeleteRenderbuffer(hdr.depthRB)
+		hdr.depthRB = 0
+	}
+}
+
+// Resize recreates the framebuffer, and the bloom if one was set, for a
+// new size.
+func (hdr *HDR) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid HDR size %dx%d", width, height)
+	}
+	hdr.destroyFramebuffer()
+	hdr.width = width
+	hdr.height = height
+
+	gfx := hdr.gfx
+	hdr.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, hdr.fbo)
+
+	hdr.colorTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, hdr.colorTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA16F, width, height, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, hdr.colorTex, 0)
+
+	hdr.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, hdr.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH24_STENCIL8, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_STENCIL_ATTACHMENT, graphics.RENDERBUFFER, hdr.depthRB)
+
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "HDR")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	if err == nil && hdr.bloom != nil {
+		err = hdr.bloom.Resize(width, height)
+	}
+	return err
+}
+
+// SetBloom initializes the bloom for the HDR framebuffer's size and adds
+// it to the scene in Resolve before the scene is tonemapped, so its
+// Threshold is in the same linear units as the lighting. The HDR takes
+// ownership of it and destroys any bloom set before. Passing nil removes
+// the bloom.
+func (hdr *HDR) SetBloom(b *Bloom) error {
+	if hdr.bloom != nil && hdr.bloom != b {
+		hdr.bloom.Destroy()
+	}
+	hdr.bloom = nil
+	if b == nil {
+		return nil
+	}
+	if err := b.Init(hdr.gfx, hdr.width, hdr.height); err != nil {
+		return err
+	}
+	hdr.bloom = b
+	return nil
+}
+
+// GetBloom returns the bloom set with SetBloom or nil.
+func (hdr *HDR) GetBloom() *Bloom {
+	return hdr.bloom
+}
+
+// Framebuffer returns the floating point framebuffer the scene is drawn into.
+func (hdr *HDR) Framebuffer() graphics.Buffer {
+	return hdr.fbo
+}
+
+// GetColorTexture returns the RGBA16F texture holding the scene.
+func (hdr *HDR) GetColorTexture() graphics.Texture {
+	return hdr.colorTex
+}
+
+// Begin binds the floating point framebuffer and sets the viewport to its
+// size. The scene should be drawn between Begin and End.
+func (hdr *HDR) Begin() {
+	hdr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, hdr.fbo)
+	hdr.gfx.Viewport(0, 0, hdr.width, hdr.height)
+}
+
+// End binds the Output framebuffer.
+func (hdr *HDR) End() {
+	hdr.gfx.BindFramebuffer(graphics.FRAMEBUFFER, hdr.Output)
+}
+
+// Resolve tonemaps the scene into the Output framebuffer over the whole
+// viewport, blooming it first if a bloom was set.
+func (hdr *HDR) Resolve(rend Renderer) {
+	gfx := hdr.gfx
+	ident := mgl.Ident4()
+
+	if hdr.bloom != nil {
+		hdr.bloom.blur(rend, hdr.colorTex)
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, hdr.Output)
+		gfx.Viewport(0, 0, hdr.width, hdr.height)
+	}
+
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Disable(graphics.BLEND)
+	rend.DrawRenderableWithShader(hdr.quad, hdr.shader, hdr.bindUniforms, ident, ident, nil)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// bindUniforms binds the scene and the tonemapping parameters for the resolve shader.
+func (hdr *HDR) bindUniforms(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := hdr.gfx
+	if loc := shader.GetUniformLocation("HDR_COLOR_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, hdr.colorTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("HDR_BLOOM_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		if hdr.bloom != nil {
+			gfx.BindTexture(graphics.TEXTURE_2D, hdr.bloom.GetTexture())
+		} else {
+			gfx.BindTexture(graphics.TEXTURE_2D, 0)
+		}
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("HDR_BLOOM_INTENSITY"); loc >= 0 {
+		if hdr.bloom != nil {
+			gfx.Uniform1f(loc, hdr.bloom.Intensity)
+		} else {
+			gfx.Uniform1f(loc, 0.0)
+		}
+	}
+	if loc := shader.GetUniformLocation("HDR_TONEMAP"); loc >= 0 {
+		gfx.Uniform1i(loc, int32(hdr.Operator))
+	}
+	if loc := shader.GetUniformLocation("HDR_EXPOSURE"); loc >= 0 {
+		gfx.Uniform1f(loc, hdr.Exposure)
+	}
+	if loc := shader.GetUniformLocation("HDR_WHITE_POINT"); loc >= 0 {
+		whitePoint := hdr.WhitePoint
+		if whitePoint <= 0.0 {
+			whitePoint = 11.2
+		}
+		gfx.Uniform1f(loc, whitePoint)
+	}
+	if loc := shader.GetUniformLocation("HDR_GAMMA"); loc >= 0 {
+		gamma := hdr.Gamma
+		if gamma <= 0.0 {
+			gamma = 1.0
+		}
+		gfx.Uniform1f(loc, gamma)
+	}
+}
@@ -0,0 +1,636 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+var (
+	// IBLCubeVertShader330 is the GLSL vertex shader that draws a unit cube
+	// around the camera for rendering into the faces of a cubemap, passing
+	// the direction of every fragment from the center.
+	IBLCubeVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  in vec3 VERTEX_POSITION;
+  out vec3 vs_direction;
+
+  void main()
+  {
+    vs_direction = VERTEX_POSITION;
+    gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+  }`
+
+	// IBLEquirectFragShader330 is the GLSL fragment shader that samples an
+	// equirectangular panorama in IBL_EQUIRECT_TEX for the cubemap faces.
+	IBLEquirectFragShader330 = `#version 330
+  uniform sampler2D IBL_EQUIRECT_TEX;
+  in vec3 vs_direction;
+  out vec4 frag_color;
+
+  const float PI = 3.14159265359;
+
+  void main()
+  {
+    vec3 d = normalize(vs_direction);
+    vec2 uv = vec2(atan(d.z, d.x) / (2.0 * PI) + 0.5, asin(clamp(d.y, -1.0, 1.0)) / PI + 0.5);
+    frag_color = vec4(texture(IBL_EQUIRECT_TEX, uv).rgb, 1.0);
+  }`
+
+	// IBLIrradianceFragShader330 is the GLSL fragment shader that convolves
+	// the environment cubemap in IBL_ENVIRONMENT_TEX over the hemisphere
+	// around every direction for the diffuse irradiance.
+	IBLIrradianceFragShader330 = `#version 330
+  uniform samplerCube IBL_ENVIRONMENT_TEX;
+  in vec3 vs_direction;
+  out vec4 frag_color;
+
+  const float PI = 3.14159265359;
+
+  void main()
+  {
+    vec3 n = normalize(vs_direction);
+    vec3 up = abs(n.y) < 0.999 ? vec3(0.0, 1.0, 0.0) : vec3(0.0, 0.0, 1.0);
+    vec3 right = normalize(cross(up, n));
+    up = cross(n, right);
+
+    const float delta = 0.025;
+    vec3 irradiance = vec3(0.0);
+    float samples = 0.0;
+    for (float phi = 0.0; phi < 2.0 * PI; phi += delta) {
+      for (float theta = 0.0; theta < 0.5 * PI; theta += delta) {
+        vec3 tangent = vec3(sin(theta) * cos(phi), sin(theta) * sin(phi), cos(theta));
+        vec3 dir = tangent.x * right + tangent.y * up + tangent.z * n;
+        irradiance += texture(IBL_ENVIRONMENT_TEX, dir).rgb * cos(theta) * sin(theta);
+        samples += 1.0;
+      }
+    }
+    frag_color = vec4(PI * irradiance / samples, 1.0);
+  }`
+
+	// IBLSampleFunctions330 is the GLSL for the GGX importance sampling
+	// shared by the prefilter and BRDF lookup shaders.
+	IBLSampleFunctions330 = `
+  const float PI = 3.14159265359;
+
+  // Hammersley returns the ith of count points of a low discrepancy sequence.
+  vec2 Hammersley(uint i, uint count)
+  {
+    uint bits = i;
+    bits = (bits << 16u) | (bits >> 16u);
+    bits = ((bits & 0x55555555u) << 1u) | ((bits & 0xAAAAAAAAu) >> 1u);
+    bits = ((bits & 0x33333333u) << 2u) | ((bits & 0xCCCCCCCCu) >> 2u);
+    bits = ((bits & 0x0F0F0F0Fu) << 4u) | ((bits & 0xF0F0F0F0u) >> 4u);
+    bits = ((bits & 0x00FF00FFu) << 8u) | ((bits & 0xFF00FF00u) >> 8u);
+    return vec2(float(i) / float(count), float(bits) * 2.3283064365386963e-10);
+  }
+
+  // ImportanceSampleGGX returns a half vector around n distributed like the
+  // GGX microfacets of the roughness.
+  vec3 ImportanceSampleGGX(vec2 xi, vec3 n, float roughness)
+  {
+    float a = roughness * roughness;
+    float phi = 2.0 * PI * xi.x;
+    float cosTheta = sqrt((1.0 - xi.y) / (1.0 + (a * a - 1.0) * xi.y));
+    float sinTheta = sqrt(1.0 - cosTheta * cosTheta);
+    vec3 h = vec3(cos(phi) * sinTheta, sin(phi) * sinTheta, cosTheta);
+
+    vec3 up = abs(n.z) < 0.999 ? vec3(0.0, 0.0, 1.0) : vec3(1.0, 0.0, 0.0);
+    vec3 tangent = normalize(cross(up, n));
+    vec3 bitangent = cross(n, tangent);
+    return normalize(tangent * h.x + bitangent * h.y + n * h.z);
+  }
+`
+
+	// IBLPrefilterFragShader330 is the GLSL fragment shader that prefilters
+	// the environment cubemap in IBL_ENVIRONMENT_TEX for the specular
+	// reflections of the IBL_ROUGHNESS of the mip level being drawn.
+	IBLPrefilterFragShader330 = `#version 330
+  uniform samplerCube IBL_ENVIRONMENT_TEX;
+  uniform float IBL_ENVIRONMENT_SIZE;
+  uniform float IBL_ROUGHNESS;
+  uniform int IBL_SAMPLE_COUNT;
+  in vec3 vs_direction;
+  out vec4 frag_color;
+` + IBLSampleFunctions330 + `
+  void main()
+  {
+    // the view direction is assumed to be the normal and the reflection
+    vec3 n = normalize(vs_direction);
+    float a = IBL_ROUGHNESS * IBL_ROUGHNESS;
+    uint count = uint(IBL_SAMPLE_COUNT);
+
+    vec3 color = vec3(0.0);
+    float weight = 0.0;
+    for (uint i = 0u; i < count; i++) {
+      vec3 h = ImportanceSampleGGX(Hammersley(i, count), n, IBL_ROUGHNESS);
+      vec3 l = normalize(2.0 * dot(n, h) * h - n);
+      float NdotL = dot(n, l);
+      if (NdotL > 0.0) {
+        // sample a mip of the environment matching the solid angle of the
+        // sample to avoid bright dots from undersampling
+        float NdotH = max(dot(n, h), 0.0);
+        float d = NdotH * NdotH * (a * a - 1.0) + 1.0;
+        float pdf = (a * a) / (PI * d * d) * 0.25 + 0.0001;
+        float saTexel = 4.0 * PI / (6.0 * IBL_ENVIRONMENT_SIZE * IBL_ENVIRONMENT_SIZE);
+        float saSample = 1.0 / (float(count) * pdf + 0.0001);
+        float lod = IBL_ROUGHNESS == 0.0 ? 0.0 : 0.5 * log2(saSample / saTexel);
+
+        color += textureLod(IBL_ENVIRONMENT_TEX, l, lod).rgb * NdotL;
+        weight += NdotL;
+      }
+    }
+    frag_color = vec4(color / max(weight, 0.0001), 1.0);
+  }`
+
+	// IBLBRDFFragShader330 is the GLSL fragment shader that integrates the
+	// scale and bias of the Fresnel term of the split sum approximation for
+	// NdotV along u and the roughness along v.
+	IBLBRDFFragShader330 = `#version 330
+  uniform int IBL_SAMPLE_COUNT;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+` + IBLSampleFunctions330 + `
+  float GeometrySchlickGGX(float NdotV, float roughness)
+  {
+    float k = (roughness * roughness) / 2.0;
+    return NdotV / (NdotV * (1.0 - k) + k);
+  }
+
+  void main()
+  {
+    float NdotV = max(vs_uv.x, 0.0001);
+    float roughness = vs_uv.y;
+    vec3 v = vec3(sqrt(1.0 - NdotV * NdotV), 0.0, NdotV);
+    vec3 n = vec3(0.0, 0.0, 1.0);
+    uint count = uint(IBL_SAMPLE_COUNT);
+
+    float scale = 0.0;
+    float bias = 0.0;
+    for (uint i = 0u; i < count; i++) {
+      vec3 h = ImportanceSampleGGX(Hammersley(i, count), n, roughness);
+      vec3 l = normalize(2.0 * dot(v, h) * h - v);
+      float NdotL = max(l.z, 0.0);
+      float NdotH = max(h.z, 0.0);
+      float VdotH = max(dot(v, h), 0.0);
+      if (NdotL > 0.0) {
+        float g = GeometrySchlickGGX(NdotV, roughness) * GeometrySchlickGGX(NdotL, roughness);
+        float gVis = (g * VdotH) / (NdotH * NdotV);
+        float fc = pow(1.0 - VdotH, 5.0);
+        scale += (1.0 - fc) * gVis;
+        bias += fc * gVis;
+      }
+    }
+    frag_color = vec4(scale / float(count), bias / float(count), 0.0, 1.0);
+  }`
+)
+
+// IBLSettings are the sizes and quality of the maps generated for an IBL.
+type IBLSettings struct {
+	// IrradianceSize is the size of the faces of the irradiance cubemap,
+	// which is smooth enough to be tiny.
+	IrradianceSize int32
+
+	// PrefilteredSize is the size of the faces of the top mip level of the
+	// prefiltered specular cubemap.
+	PrefilteredSize int32
+
+	// PrefilteredLevels is the number of mip levels of the prefiltered
+	// cubemap, going from a roughness of 0 to 1.
+	PrefilteredLevels int32
+
+	// BRDFLUTSize is the size of the BRDF lookup texture.
+	BRDFLUTSize int32
+
+	// SampleCount is the number of GGX samples per texel for the
+	// prefiltered cubemap and the BRDF lookup texture.
+	SampleCount int32
+}
+
+// DefaultIBLSettings returns the settings used for most environments.
+func DefaultIBLSettings() IBLSettings {
+	return IBLSettings{
+		IrradianceSize:    32,
+		PrefilteredSize:   128,
+		PrefilteredLevels: 5,
+		BRDFLUTSize:       512,
+		SampleCount:       1024,
+	}
+}
+
+// IBL is the image based lighting of an environment cubemap: the diffuse
+// irradiance cubemap, the specular cubemap prefiltered for increasing
+// roughness in its mip levels and the BRDF lookup texture of the split sum
+// approximation. Shaders that declare the IBL_* uniforms, like the forward
+// pbr shader, use them for their ambient light in place of the lights'
+// ambient intensity.
+type IBL struct {
+	// Intensity scales the light from the environment.
+	Intensity float32
+
+	gfx             graphics.GraphicsProvider
+	environment     graphics.Texture
+	ownsEnvironment bool
+	irradiance      graphics.Texture
+	prefiltered     graphics.Texture
+	brdfLUT         graphics.Texture
+	levels          int32
+}
+
+// NewIBL generates the maps for the environment cubemap with faces of the
+// size, which should be in linear color space and is left owned by the
+// caller. Mipmaps are
+// generated for the environment cubemap to reduce the noise of the
+// prefiltering. The drawing is done through the renderer, and the default
+// framebuffer is bound afterwards.
+func NewIBL(rend Renderer, environment graphics.Texture, size int32, settings IBLSettings) (*IBL, error) {
+	if environment == 0 || size <= 0 {
+		return nil, fmt.Errorf("no environment cubemap to create the IBL from")
+	}
+	if settings.IrradianceSize <= 0 || settings.PrefilteredSize <= 0 || settings.PrefilteredLevels <= 0 ||
+		settings.BRDFLUTSize <= 0 || settings.SampleCount <= 0 {
+		return nil, fmt.Errorf("invalid IBL settings %+v", settings)
+	}
+
+	gen, err := newIBLGenerator(rend)
+	if err != nil {
+		return nil, err
+	}
+	defer gen.destroy()
+
+	ibl := new(IBL)
+	ibl.Intensity = 1.0
+	ibl.gfx = rend.GetGraphics()
+	ibl.environment = environment
+	ibl.levels = settings.PrefilteredLevels
+	err = gen.generate(ibl, size, settings)
+	if err != nil {
+		ibl.Destroy()
+		return nil, err
+	}
+	return ibl, nil
+}
+
+// NewIBLFromEquirectangular converts the equirectangular panorama texture,
+// such as a linear HDR sky, into an environment cubemap with faces of the
+// size and then generates the maps for it like NewIBL. The cubemap is
+// owned by the IBL.
+func NewIBLFromEquirectangular(rend Renderer, equirect graphics.Texture, size int32, settings IBLSettings) (*IBL, error) {
+	if equirect == 0 || size <= 0 {
+		return nil, fmt.Errorf("invalid equirectangular environment for the IBL")
+	}
+	gen, err := newIBLGenerator(rend)
+	if err != nil {
+		return nil, err
+	}
+	environment, err := gen.convertEquirect(equirect, size)
+	gen.destroy()
+	if err != nil {
+		return nil, err
+	}
+
+	ibl, err := NewIBL(rend, environment, size, settings)
+	if err != nil {
+		rend.GetGraphics().DeleteTexture(environment)
+		return nil, err
+	}
+	ibl.ownsEnvironment = true
+	return ibl, nil
+}
+
+// Destroy releases the generated maps and the environment cubemap if the
+// IBL created it.
+func (ibl *IBL) Destroy() {
+	gfx := ibl.gfx
+	for _, tex := range []*graphics.Texture{&ibl.irradiance, &ibl.prefiltered, &ibl.brdfLUT} {
+		if *tex != 0 {
+			gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
+	if ibl.ownsEnvironment && ibl.environment != 0 {
+		gfx.DeleteTexture(ibl.environment)
+	}
+	ibl.environment = 0
+}
+
+// GetEnvironment returns the environment cubemap, which can also be drawn
+// as the skybox.
+func (ibl *IBL) GetEnvironment() graphics.Texture {
+	return ibl.environment
+}
+
+// GetIrradiance returns the diffuse irradiance cubemap.
+func (ibl *IBL) GetIrradiance() graphics.Texture {
+	return ibl.irradiance
+}
+
+// GetPrefiltered returns the prefiltered specular cubemap.
+func (ibl *IBL) GetPrefiltered() graphics.Texture {
+	return ibl.prefiltered
+}
+
+// GetBRDFLUT returns the BRDF lookup texture.
+func (ibl *IBL) GetBRDFLUT() graphics.Texture {
+	return ibl.brdfLUT
+}
+
+// Bind binds the maps for shaders that declare IBL_IRRADIANCE_TEX,
+// IBL_PREFILTERED_TEX and IBL_BRDF_LUT along with IBL_MAX_LOD and
+// IBL_INTENSITY, and sets IBL_ENABLED. It can be called on a nil IBL, which
+// binds no textures to the samplers and clears IBL_ENABLED.
+func (ibl *IBL) Bind(gfx graphics.GraphicsProvider, shader *fizzle.RenderShader, texturesBound *int32) {
+	if loc := shader.GetUniformLocation("IBL_ENABLED"); loc >= 0 {
+		if ibl != nil {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+
+	var irradiance, prefiltered, brdfLUT graphics.Texture
+	if ibl != nil {
+		irradiance, prefiltered, brdfLUT = ibl.irradiance, ibl.prefiltered, ibl.brdfLUT
+		if loc := shader.GetUniformLocation("IBL_MAX_LOD"); loc >= 0 {
+			gfx.Uniform1f(loc, float32(ibl.levels-1))
+		}
+		if loc := shader.GetUniformLocation("IBL_INTENSITY"); loc >= 0 {
+			gfx.Uniform1f(loc, ibl.Intensity)
+		}
+	}
+
+	// every sampler is bound, even without an IBL, so that the cube
+	// samplers don't share a unit with 2D samplers
+	samplers := [...]struct {
+		name   string
+		target graphics.Enum
+		tex    graphics.Texture
+	}{
+		{"IBL_IRRADIANCE_TEX", graphics.TEXTURE_CUBE_MAP, irradiance},
+		{"IBL_PREFILTERED_TEX", graphics.TEXTURE_CUBE_MAP, prefiltered},
+		{"IBL_BRDF_LUT", graphics.TEXTURE_2D, brdfLUT},
+	}
+	for _, sampler := range samplers {
+		if loc := shader.GetUniformLocation(sampler.name); loc >= 0 {
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			gfx.BindTexture(sampler.target, sampler.tex)
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+}
+
+// iblGenerator holds the shaders and framebuffer used while generating
+// the maps of an IBL.
+type iblGenerator struct {
+	rend Renderer
+	gfx  graphics.GraphicsProvider
+	fbo  graphics.Buffer
+	cube *fizzle.Renderable
+	quad *fizzle.Renderable
+
+	equirectShader   *fizzle.RenderShader
+	irradianceShader *fizzle.RenderShader
+	prefilterShader  *fizzle.RenderShader
+	brdfShader       *fizzle.RenderShader
+
+	// the state read by bind for the map being drawn
+	source      graphics.Texture
+	sourceSize  int32
+	roughness   float32
+	sampleCount int32
+	bindFn      RenderBinder
+}
+
+// newIBLGenerator compiles the shaders for generating IBL maps.
+func newIBLGenerator(rend Renderer) (*iblGenerator, error) {
+	gen := new(iblGenerator)
+	gen.rend = rend
+	gen.gfx = rend.GetGraphics()
+	gen.bindFn = gen.bind
+
+	shaders := []struct {
+		dest       **fizzle.RenderShader
+		vert, frag string
+		name       string
+	}{
+		{&gen.equirectShader, IBLCubeVertShader330, IBLEquirectFragShader330, "equirectangular"},
+		{&gen.irradianceShader, IBLCubeVertShader330, IBLIrradianceFragShader330, "irradiance"},
+		{&gen.prefilterShader, IBLCubeVertShader330, IBLPrefilterFragShader330, "prefilter"},
+		{&gen.brdfShader, PostProcessVertShader330, IBLBRDFFragShader330, "BRDF"},
+	}
+	for _, s := range shaders {
+		shader, err := fizzle.LoadShaderProgram(s.vert, s.frag, nil)
+		if err != nil {
+			gen.destroy()
+			return nil, fmt.Errorf("failed to compile the IBL %s shader: %v", s.name, err)
+		}
+		*s.dest = shader
+	}
+
+	gen.cube = fizzle.CreateCube(-1.0, -1.0, -1.0, 1.0, 1.0, 1.0)
+	gen.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	gen.fbo = gen.gfx.GenFramebuffer()
+	return gen, nil
+}
+
+// destroy releases the generator's shaders, meshes and framebuffer.
+func (gen *iblGenerator) destroy() {
+	for _, shader := range []*fizzle.RenderShader{gen.equirectShader, gen.irradianceShader, gen.prefilterShader, gen.brdfShader} {
+		if shader != nil {
+			shader.Destroy()
+		}
+	}
+	if gen.cube != nil {
+		gen.cube.Destroy()
+	}
+	if gen.quad != nil {
+		gen.quad.Destroy()
+	}
+	if gen.fbo != 0 {
+		gen.gfx.DeleteFramebuffer(gen.fbo)
+	}
+}
+
+// generate draws the irradiance, prefiltered and BRDF maps of the IBL.
+func (gen *iblGenerator) generate(ibl *IBL, envSize int32, settings IBLSettings) error {
+	gfx := gen.gfx
+	gfx.Enable(graphics.TEXTURE_CUBE_MAP_SEAMLESS)
+
+	// the prefiltering samples lower mips of the environment
+	gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, ibl.environment)
+	gfx.GenerateMipmap(graphics.TEXTURE_CUBE_MAP)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR_MIPMAP_LINEAR)
+	gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, 0)
+
+	gen.source = ibl.environment
+	gen.sourceSize = envSize
+	gen.sampleCount = settings.SampleCount
+
+	ibl.irradiance = createCubemap(gfx, settings.IrradianceSize, 1)
+	err := gen.drawCubemap(gen.irradianceShader, ibl.irradiance, settings.IrradianceSize, 0)
+	if err != nil {
+		return err
+	}
+
+	ibl.prefiltered = createCubemap(gfx, settings.PrefilteredSize, settings.PrefilteredLevels)
+	for level := int32(0); level < settings.PrefilteredLevels; level++ {
+		size := settings.PrefilteredSize >> uint(level)
+		if size < 1 {
+			size = 1
+		}
+		if settings.PrefilteredLevels > 1 {
+			gen.roughness = float32(level) / float32(settings.PrefilteredLevels-1)
+		}
+		err = gen.drawCubemap(gen.prefilterShader, ibl.prefiltered, size, level)
+		if err != nil {
+			return err
+		}
+	}
+
+	ibl.brdfLUT = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, ibl.brdfLUT)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RG16F, settings.BRDFLUTSize, settings.BRDFLUTSize, 0, graphics.RG, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, gen.fbo)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, ibl.brdfLUT, 0)
+	err = CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "IBL BRDF lookup")
+	if err == nil {
+		ident := mgl.Ident4()
+		gen.beginDraw(settings.BRDFLUTSize)
+		gen.rend.DrawRenderableWithShader(gen.quad, gen.brdfShader, gen.bindFn, ident, ident, nil)
+	}
+	gen.endDraw()
+	return err
+}
+
+// convertEquirect draws the equirectangular texture into the faces of a
+// new cubemap.
+func (gen *iblGenerator) convertEquirect(equirect graphics.Texture, size int32) (graphics.Texture, error) {
+	gfx := gen.gfx
+	cubemap := createCubemap(gfx, size, 1)
+	gen.source = equirect
+	err := gen.drawCubemap(gen.equirectShader, cubemap, size, 0)
+	if err != nil {
+		gfx.DeleteTexture(cubemap)
+		return 0, err
+	}
+	return cubemap, nil
+}
+
+// drawCubemap draws the shader into every face of the cubemap's mip level.
+func (gen *iblGenerator) drawCubemap(shader *fizzle.RenderShader, cubemap graphics.Texture, size int32, level int32) error {
+	gfx := gen.gfx
+	projection := mgl.Perspective(mgl.DegToRad(90.0), 1.0, 0.1, 10.0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, gen.fbo)
+	for face := 0; face < 6; face++ {
+		target := graphics.Enum(graphics.TEXTURE_CUBE_MAP_POSITIVE_X + face)
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, target, cubemap, level)
+		err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "IBL cubemap")
+		if err != nil {
+			gen.endDraw()
+			return err
+		}
+		gen.beginDraw(size)
+		gen.rend.DrawRenderableWithShader(gen.cube, shader, gen.bindFn, projection, cubemapFaceViews[face], nil)
+	}
+	gen.endDraw()
+	return nil
+}
+
+// beginDraw sets the state for drawing a map of the size into the bound
+// framebuffer.
+func (gen *iblGenerator) beginDraw(size int32) {
+	gfx := gen.gfx
+	gfx.Viewport(0, 0, size, size)
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.Disable(graphics.CULL_FACE)
+	gfx.Disable(graphics.BLEND)
+	gfx.ClearColor(0.0, 0.0, 0.0, 1.0)
+	gfx.Clear(graphics.COLOR_BUFFER_BIT)
+}
+
+// endDraw restores the default framebuffer and state.
+func (gen *iblGenerator) endDraw() {
+	gfx := gen.gfx
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	width, height := gen.rend.GetResolution()
+	gfx.Viewport(0, 0, width, height)
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.Enable(graphics.CULL_FACE)
+}
+
+// bind binds the source map and settings for the generator's shaders.
+func (gen *iblGenerator) bind(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := gen.gfx
+	if loc := shader.GetUniformLocation("IBL_EQUIRECT_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, gen.source)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("IBL_ENVIRONMENT_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, gen.source)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("IBL_ENVIRONMENT_SIZE"); loc >= 0 {
+		gfx.Uniform1f(loc, float32(gen.sourceSize))
+	}
+	if loc := shader.GetUniformLocation("IBL_ROUGHNESS"); loc >= 0 {
+		gfx.Uniform1f(loc, gen.roughness)
+	}
+	if loc := shader.GetUniformLocation("IBL_SAMPLE_COUNT"); loc >= 0 {
+		gfx.Uniform1i(loc, gen.sampleCount)
+	}
+}
+
+// cubemapFaceViews are the view matrixes looking from the origin through
+// the faces of a cubemap in the order of TEXTURE_CUBE_MAP_POSITIVE_X
+// onwards.
+var cubemapFaceViews = [6]mgl.Mat4{
+	mgl.LookAtV(mgl.Vec3{}, mgl.Vec3{1.0, 0.0, 0.0}, mgl.Vec3{0.0, -1.0, 0.0}),
+	mgl.LookAtV(mgl.Vec3{}, mgl.Vec3{-1.0, 0.0, 0.0}, mgl.Vec3{0.0, -1.0, 0.0}),
+	mgl.LookAtV(mgl.Vec3{}, mgl.Vec3{0.0, 1.0, 0.0}, mgl.Vec3{0.0, 0.0, 1.0}),
+	mgl.LookAtV(mgl.Vec3{}, mgl.Vec3{0.0, -1.0, 0.0}, mgl.Vec3{0.0, 0.0, -1.0}),
+	mgl.LookAtV(mgl.Vec3{}, mgl.Vec3{0.0, 0.0, 1.0}, mgl.Vec3{0.0, -1.0, 0.0}),
+	mgl.LookAtV(mgl.Vec3{}, mgl.Vec3{0.0, 0.0, -1.0}, mgl.Vec3{0.0, -1.0, 0.0}),
+}
+
+// createCubemap creates a floating point cubemap with the mip levels.
+func createCubemap(gfx graphics.GraphicsProvider, size int32, levels int32) graphics.Texture {
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, tex)
+	for level := int32(0); level < levels; level++ {
+		levelSize := size >> uint(level)
+		if levelSize < 1 {
+			levelSize = 1
+		}
+		for face := graphics.Enum(0); face < 6; face++ {
+			gfx.TexImage2D(graphics.TEXTURE_CUBE_MAP_POSITIVE_X+face, level, graphics.RGB16F, levelSize, levelSize, 0, graphics.RGB, graphics.FLOAT, nil, 0)
+		}
+	}
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	if levels > 1 {
+		gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR_MIPMAP_LINEAR)
+	} else {
+		gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	}
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_MAX_LEVEL, levels-1)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_CUBE_MAP, graphics.TEXTURE_WRAP_R, graphics.CLAMP_TO_EDGE)
+	gfx.BindTexture(graphics.TEXTURE_CUBE_MAP, 0)
+	return tex
+}
@@ -0,0 +1,97 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// SetBlendMode sets the blend function for the material blend mode. BLEND
+// has to be enabled separately.
+func SetBlendMode(gfx graphics.GraphicsProvider, mode fizzle.BlendMode) {
+	switch mode {
+	case fizzle.BlendAdditive:
+		gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE)
+	case fizzle.BlendPremultiplied:
+		gfx.BlendFunc(graphics.ONE, graphics.ONE_MINUS_SRC_ALPHA)
+	default:
+		gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE_MINUS_SRC_ALPHA)
+	}
+}
+
+// bindMaterialUniforms sets the extra uniforms of the material that the
+// shader declares.
+func bindMaterialUniforms(gfx graphics.GraphicsProvider, mat *fizzle.Material, shader *fizzle.RenderShader, texturesBound *int32) {
+	for name, value := range mat.Uniforms {
+		loc := shader.GetUniformLocation(name)
+		if loc < 0 {
+			continue
+		}
+
+		switch v := value.(type) {
+		case float32:
+			gfx.Uniform1f(loc, v)
+		case int32:
+			gfx.Uniform1i(loc, v)
+		case mgl.Vec2:
+			gfx.Uniform2f(loc, v[0], v[1])
+		case mgl.Vec3:
+			gfx.Uniform3f(loc, v[0], v[1], v[2])
+		case mgl.Vec4:
+			gfx.Uniform4f(loc, v[0], v[1], v[2], v[3])
+		case mgl.Mat4:
+			gfx.UniformMatrix4fv(loc, 1, false, &v)
+		case graphics.Texture:
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			gfx.BindTexture(graphics.TEXTURE_2D, v)
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+}
+
+// applyMaterialState sets the culling and depth state of the material if
+// it differs from the default of back face culling with depth testing and
+// writing. Depth writes are left alone for transparent materials, which
+// are drawn without them.
+func applyMaterialState(gfx graphics.GraphicsProvider, mat *fizzle.Material) {
+	if !mat.HasRenderState() {
+		return
+	}
+
+	switch mat.Cull {
+	case fizzle.CullFront:
+		gfx.CullFace(graphics.FRONT)
+	case fizzle.CullNone:
+		gfx.Disable(graphics.CULL_FACE)
+	}
+	if mat.NoDepthTest {
+		gfx.Disable(graphics.DEPTH_TEST)
+	}
+	if mat.NoDepthWrite && !mat.Transparent {
+		gfx.DepthMask(false)
+	}
+}
+
+// restoreMaterialState undoes applyMaterialState after the draw.
+func restoreMaterialState(gfx graphics.GraphicsProvider, mat *fizzle.Material) {
+	if !mat.HasRenderState() {
+		return
+	}
+
+	switch mat.Cull {
+	case fizzle.CullFront:
+		gfx.CullFace(graphics.BACK)
+	case fizzle.CullNone:
+		gfx.Enable(graphics.CULL_FACE)
+	}
+	if mat.NoDepthTest {
+		gfx.Enable(graphics.DEPTH_TEST)
+	}
+	if mat.NoDepthWrite && !mat.Transparent {
+		gfx.DepthMask(true)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// MSAA renders the scene into a multisampled framebuffer and resolves it
+// into the Output framebuffer, smoothing the edges of the geometry. The
+// scene is drawn between Begin and End. MSAA is Resizable so it can be
+// registered to follow the renderer's resolution.
+//
+// Resolving requires the Output's color format to match the multisampled
+// one, so it's chosen when the MSAA is created: RGBA8 for the window and
+// RGBA16F for a PostProcessStack or HDR framebuffer.
+type MSAA struct {
+	// Output is the framebuffer the samples are resolved into; 0, the
+	// default, is the window.
+	Output graphics.Buffer
+
+	// ResolveDepth also resolves the depth buffer, which is needed when
+	// the Output's depth is sampled by later passes. The Output's depth
+	// must be DEPTH_COMPONENT24, like the PostProcessStack's.
+	ResolveDepth bool
+
+	gfx         graphics.GraphicsProvider
+	fbo         graphics.Buffer
+	colorRB     graphics.Buffer
+	depthRB     graphics.Buffer
+	colorFormat graphics.Enum
+	samples     int32
+	width       int32
+	height      int32
+}
+
+// NewMSAA creates the multisampled framebuffer for a window of the given
+// size with the number of samples per pixel and the color format.
+func NewMSAA(gfx graphics.GraphicsProvider, width, height, samples int32, colorFormat graphics.Enum) (*MSAA, error) {
+	if samples < 2 {
+		return nil, fmt.Errorf("MSAA needs at least 2 samples, got %d", samples)
+	}
+	msaa := new(MSAA)
+	msaa.gfx = gfx
+	msaa.samples = samples
+	msaa.colorFormat = colorFormat
+
+	err := msaa.Resize(width, height)
+	if err != nil {
+		msaa.Destroy()
+		return nil, err
+	}
+	return msaa, nil
+}
+
+// Destroy releases the framebuffer and its renderbuffers.
+func (msaa *MSAA) Destroy() {
+	if msaa.fbo != 0 {
+		msaa.gfx.DeleteFramebuffer(msaa.fbo)
+		msaa.fbo = 0
+	}
+	if msaa.colorRB != 0 {
+		msaa.gfx.DeleteRenderbuffer(msaa.colorRB)
+		msaa.colorRB = 0
+	}
+	if msaa.depthRB != 0 {
+		msaa.gfx.DeleteRenderbuffer(msaa.depthRB)
+		msaa.depthRB = 0
+	}
+}
+
+// Resize recreates the multisampled framebuffer for a new window size.
+func (msaa *MSAA) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid MSAA size %dx%d", width, height)
+	}
+	msaa.Destroy()
+	msaa.width = width
+	msaa.height = height
+
+	gfx := msaa.gfx
+	msaa.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, msaa.fbo)
+
+	msaa.colorRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, msaa.colorRB)
+	gfx.RenderbufferStorageMultisample(graphics.RENDERBUFFER, msaa.samples, msaa.colorFormat, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.RENDERBUFFER, msaa.colorRB)
+
+	msaa.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, msaa.depthRB)
+	gfx.RenderbufferStorageMultisample(graphics.RENDERBUFFER, msaa.samples, graphics.DEPTH_COMPONENT24, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.RENDERBUFFER, msaa.depthRB)
+
+	// an incomplete framebuffer here usually means more samples were asked
+	// for than the hardware supports
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "MSAA")
+
+	// a safety unbind
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	return err
+}
+
+// SetSamples recreates the framebuffer with a new number of samples per
+// pixel. The old framebuffer is kept if the new one can't be created.
+func (msaa *MSAA) SetSamples(samples int32) error {
+	if samples < 2 {
+		return fmt.Errorf("MSAA needs at least 2 samples, got %d", samples)
+	}
+	if samples == msaa.samples {
+		return nil
+	}
+	old := msaa.samples
+	msaa.samples = samples
+	if err := msaa.Resize(msaa.width, msaa.height); err != nil {
+		msaa.samples = old
+		msaa.Resize(msaa.width, msaa.height)
+		return err
+	}
+	return nil
+}
+
+// GetSamples returns the number of samples per pixel.
+func (msaa *MSAA) GetSamples() int32 {
+	return msaa.samples
+}
+
+// Framebuffer returns the multisampled framebuffer the scene is drawn into.
+func (msaa *MSAA) Framebuffer() graphics.Buffer {
+	return msaa.fbo
+}
+
+// Begin binds the multisampled framebuffer and sets the viewport to its
+// size. The scene should be drawn between Begin and End.
+func (msaa *MSAA) Begin() {
+	msaa.gfx.BindFramebuffer(graphics.FRAMEBUFFER, msaa.fbo)
+	msaa.gfx.Viewport(0, 0, msaa.width, msaa.height)
+}
+
+// End resolves the samples into the Output framebuffer and leaves it bound.
+func (msaa *MSAA) End() {
+	gfx := msaa.gfx
+	gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, msaa.fbo)
+	gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, msaa.Output)
+	gfx.BlitFramebuffer(0, 0, msaa.width, msaa.height, 0, 0, msaa.width, msaa.height, graphics.COLOR_BUFFER_BIT, graphics.NEAREST)
+	if msaa.ResolveDepth {
+		gfx.BlitFramebuffer(0, 0, msaa.width, msaa.height, 0, 0, msaa.width, msaa.height, graphics.DEPTH_BUFFER_BIT, graphics.NEAREST)
+	}
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, msaa.Output)
+}
@@ -0,0 +1,252 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+var (
+	// OITCompositeFragShader330 is the GLSL fragment shader that resolves
+	// the weighted blended transparency. The accumulated color divided by
+	// the accumulated weight in OIT_WEIGHT_TEX is the average color of the
+	// transparent fragments, which covers the opaque scene by one minus
+	// the revealage in the alpha of OIT_ACCUM_TEX.
+	OITCompositeFragShader330 = `#version 330
+  uniform sampler2D OIT_ACCUM_TEX;
+  uniform sampler2D OIT_WEIGHT_TEX;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    vec4 accum = texture(OIT_ACCUM_TEX, vs_uv);
+    float revealage = accum.a;
+    if (revealage >= 1.0) {
+      discard;
+    }
+
+    float weight = texture(OIT_WEIGHT_TEX, vs_uv).r;
+    vec3 average = accum.rgb / clamp(weight, 1e-4, 5e4);
+    frag_color = vec4(average, 1.0 - revealage);
+  }`
+)
+
+// OIT draws transparent geometry with weighted blended order independent
+// transparency, which doesn't need the geometry sorted and so works for
+// intersecting surfaces and dense particles where sorting fails. Between
+// Begin and End the transparent Renderables are drawn with shaders that
+// write the weighted outputs while OIT_ENABLED is set, like the forward
+// diffuse shaders, and End composites them over the opaque scene.
+//
+// The accumulation is depth tested against the opaque scene by copying
+// the depth of the Output framebuffer, whose depth format has to match
+// the one the OIT is created with: DEPTH24_STENCIL8 for the window and
+// DEPTH_COMPONENT24 for a PostProcessStack or MSAA framebuffer.
+type OIT struct {
+	// Output is the framebuffer holding the opaque scene that the
+	// transparency is composited onto; 0, the default, is the window.
+	Output graphics.Buffer
+
+	gfx         graphics.GraphicsProvider
+	fbo         graphics.Buffer
+	accumTex    graphics.Texture
+	weightTex   graphics.Texture
+	depthRB     graphics.Buffer
+	depthFormat graphics.Enum
+	width       int32
+	height      int32
+
+	shader *fizzle.RenderShader
+	quad   *fizzle.Renderable
+
+	// bindFn is the method value of the binder, saved so that it isn't
+	// allocated every frame
+	bindFn RenderBinder
+}
+
+// NewOIT compiles the composite shader and creates the accumulation
+// targets for a window of the given size with the depth format of the
+// Output framebuffer.
+func NewOIT(gfx graphics.GraphicsProvider, width, height int32, depthFormat graphics.Enum) (*OIT, error) {
+	shader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, OITCompositeFragShader330, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile the OIT composite shader: %v", err)
+	}
+
+	oit := new(OIT)
+	oit.gfx = gfx
+	oit.depthFormat = depthFormat
+	oit.shader = shader
+	oit.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	oit.bindFn = oit.bind
+
+	err = oit.Resize(width, height)
+	if err != nil {
+		oit.Destroy()
+		return nil, err
+	}
+	return oit, nil
+}
+
+// Destroy releases the shader, quad and accumulation targets.
+func (oit *OIT) Destroy() {
+	oit.destroyTargets()
+	oit.shader.Destroy()
+	oit.quad.Destroy()
+}
+
+// destroyTargets releases the framebuffer and its attachments.
+func (oit *OIT) destroyTargets() {
+	gfx := oit.gfx
+	if oit.fbo != 0 {
+		gfx.DeleteFramebuffer(oit.fbo)
+		oit.fbo = 0
+	}
+	for _, tex := range []*graphics.Texture{&oit.accumTex, &oit.weightTex} {
+		if *tex != 0 {
+			gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
+	if oit.depthRB != 0 {
+		gfx.DeleteRenderbuffer(oit.depthRB)
+		oit.depthRB = 0
+	}
+}
+
+// Resize recreates the accumulation targets for a new window size.
+func (oit *OIT) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid OIT size %dx%d", width, height)
+	}
+	oit.destroyTargets()
+	oit.width = width
+	oit.height = height
+
+	gfx := oit.gfx
+	oit.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, oit.fbo)
+
+	oit.accumTex = oit.createTexture(graphics.RGBA16F, graphics.RGBA)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, oit.accumTex, 0)
+	oit.weightTex = oit.createTexture(graphics.R16F, graphics.RED)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT1, graphics.TEXTURE_2D, oit.weightTex, 0)
+
+	oit.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, oit.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, oit.depthFormat, width, height)
+	attachment := graphics.Enum(graphics.DEPTH_ATTACHMENT)
+	if oit.depthFormat == graphics.DEPTH24_STENCIL8 {
+		attachment = graphics.DEPTH_STENCIL_ATTACHMENT
+	}
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, attachment, graphics.RENDERBUFFER, oit.depthRB)
+	gfx.DrawBuffers([]uint32{graphics.COLOR_ATTACHMENT0, graphics.COLOR_ATTACHMENT1})
+
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "OIT")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	return err
+}
+
+// createTexture creates a floating point texture of the window size.
+func (oit *OIT) createTexture(internalFormat int32, format graphics.Enum) graphics.Texture {
+	gfx := oit.gfx
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, internalFormat, oit.width, oit.height, 0, format, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	return tex
+}
+
+// Begin copies the depth of the opaque scene from the Output framebuffer,
+// clears the accumulation targets and sets up the blending for the
+// transparent geometry drawn until End.
+func (oit *OIT) Begin() {
+	gfx := oit.gfx
+	gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, oit.Output)
+	gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, oit.fbo)
+	gfx.BlitFramebuffer(0, 0, oit.width, oit.height, 0, 0, oit.width, oit.height, graphics.DEPTH_BUFFER_BIT, graphics.NEAREST)
+
+	// the accumulated color starts at zero with full revealage in the
+	// alpha and the accumulated weight starts at zero
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, oit.fbo)
+	gfx.Viewport(0, 0, oit.width, oit.height)
+	gfx.DrawBuffers([]uint32{graphics.COLOR_ATTACHMENT0})
+	gfx.ClearColor(0.0, 0.0, 0.0, 1.0)
+	gfx.Clear(graphics.COLOR_BUFFER_BIT)
+	gfx.DrawBuffers([]uint32{graphics.COLOR_ATTACHMENT1})
+	gfx.ClearColor(0.0, 0.0, 0.0, 0.0)
+	gfx.Clear(graphics.COLOR_BUFFER_BIT)
+	gfx.DrawBuffers([]uint32{graphics.COLOR_ATTACHMENT0, graphics.COLOR_ATTACHMENT1})
+
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	oit.SetBlending()
+}
+
+// SetBlending sets the blend function of the accumulation: the colors and
+// weights are summed while the revealage in the accumulated alpha is
+// multiplied by one minus the alpha of every fragment.
+func (oit *OIT) SetBlending() {
+	gfx := oit.gfx
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendEquation(graphics.FUNC_ADD)
+	gfx.BlendFuncSeparate(graphics.ONE, graphics.ONE, graphics.ZERO, graphics.ONE_MINUS_SRC_ALPHA)
+}
+
+// End composites the accumulated transparency over the opaque scene in the
+// Output framebuffer, which is left bound.
+func (oit *OIT) End(rend Renderer) {
+	gfx := oit.gfx
+	ident := mgl.Ident4()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, oit.Output)
+	gfx.Viewport(0, 0, oit.width, oit.height)
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE_MINUS_SRC_ALPHA)
+	rend.DrawRenderableWithShader(oit.quad, oit.shader, oit.bindFn, ident, ident, nil)
+
+	gfx.Disable(graphics.BLEND)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// Bind sets the OIT_ENABLED uniform of the shader. Renderers call it from
+// their binders with enabled set while drawing between Begin and End.
+func (oit *OIT) Bind(gfx graphics.GraphicsProvider, shader *fizzle.RenderShader, enabled bool) {
+	if loc := shader.GetUniformLocation("OIT_ENABLED"); loc >= 0 {
+		if enabled {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+}
+
+// bind binds the accumulation targets for the composite shader.
+func (oit *OIT) bind(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := oit.gfx
+	if loc := shader.GetUniformLocation("OIT_ACCUM_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, oit.accumTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("OIT_WEIGHT_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, oit.weightTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+}
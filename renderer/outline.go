@@ -0,0 +1,302 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// MaxOutlineWidth is the widest outline, in pixels, that the composite
+	// shader searches for.
+	MaxOutlineWidth = 8
+)
+
+var (
+	// OutlineMaskVertShader330 is the GLSL vertex shader that draws the
+	// highlighted Renderables into the outline mask.
+	OutlineMaskVertShader330 = `#version 330
+  uniform mat4 MVP_MATRIX;
+  in vec3 VERTEX_POSITION;
+
+  void main()
+  {
+    gl_Position = MVP_MATRIX * vec4(VERTEX_POSITION, 1.0);
+  }`
+
+	// OutlineMaskFragShader330 is the GLSL fragment shader that fills the
+	// outline mask with the outline color of the Renderable.
+	OutlineMaskFragShader330 = `#version 330
+  uniform vec4 OUTLINE_COLOR;
+  out vec4 frag_color;
+
+  void main()
+  {
+    frag_color = OUTLINE_COLOR;
+  }`
+
+	// OutlineVertShader330 is the GLSL vertex shader for the outline
+	// composite. It draws a full screen quad.
+	OutlineVertShader330 = `#version 330
+  in vec3 VERTEX_POSITION;
+  out vec2 vs_uv;
+
+  void main()
+  {
+    vs_uv = VERTEX_POSITION.xy * 0.5 + 0.5;
+    gl_Position = vec4(VERTEX_POSITION.xy, 0.0, 1.0);
+  }`
+
+	// OutlineFragShader330 is the GLSL fragment shader for the outline
+	// composite. Pixels outside of the mask take the color of the closest
+	// masked pixel within the outline width, anti-aliased at the outer edge.
+	OutlineFragShader330 = `#version 330
+  uniform sampler2D OUTLINE_MASK_TEX;
+  uniform float OUTLINE_WIDTH;
+
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  const int MaxRadius = 8;
+
+  void main()
+  {
+    if (texture(OUTLINE_MASK_TEX, vs_uv).a > 0.0) {
+      discard;
+    }
+
+    vec2 texel = 1.0 / vec2(textureSize(OUTLINE_MASK_TEX, 0));
+    int radius = int(min(ceil(OUTLINE_WIDTH), float(MaxRadius)));
+    float closest = 1000.0;
+    vec4 color = vec4(0.0);
+    for (int y = -MaxRadius; y <= MaxRadius; y++) {
+      for (int x = -MaxRadius; x <= MaxRadius; x++) {
+        if (abs(x) > radius || abs(y) > radius) {
+          continue;
+        }
+        vec4 m = texture(OUTLINE_MASK_TEX, vs_uv + vec2(x, y) * texel);
+        float d = length(vec2(x, y));
+        if (m.a > 0.0 && d < closest) {
+          closest = d;
+          color = m;
+        }
+      }
+    }
+
+    float coverage = clamp(OUTLINE_WIDTH + 0.5 - closest, 0.0, 1.0);
+    if (coverage <= 0.0) {
+      discard;
+    }
+    frag_color = vec4(color.rgb, color.a * coverage);
+  }`
+)
+
+// Outline draws colored outlines around a set of Renderables for selection
+// highlighting and interaction feedback. The Renderables are drawn into a
+// mask between Begin and End and then Draw finds the edges of the mask and
+// blends the outlines over the bound framebuffer.
+//
+// The mask has its own depth buffer so the outlines show through other
+// objects, the way editors usually highlight a selection. Animated meshes
+// are outlined in their bind pose.
+type Outline struct {
+	// Width is the width of the outlines in pixels, up to MaxOutlineWidth.
+	Width float32
+
+	// Color is the outline color used by Add.
+	Color mgl.Vec4
+
+	gfx     graphics.GraphicsProvider
+	fbo     graphics.Buffer
+	maskTex graphics.Texture
+	depthRB graphics.Buffer
+	width   int32
+	height  int32
+
+	maskShader      *fizzle.RenderShader
+	compositeShader *fizzle.RenderShader
+	quad            *fizzle.Renderable
+
+	// drawColor is the color of the Renderable being drawn for the uniform binder
+	drawColor mgl.Vec4
+}
+
+// NewOutline compiles the outline shaders and creates the mask for a
+// framebuffer of the given size.
+func NewOutline(gfx graphics.GraphicsProvider, width, height int32) (*Outline, error) {
+	maskShader, err := fizzle.LoadShaderProgram(OutlineMaskVertShader330, OutlineMaskFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+	compositeShader, err := fizzle.LoadShaderProgram(OutlineVertShader330, OutlineFragShader330, nil)
+	if err != nil {
+		maskShader.Destroy()
+		return nil, err
+	}
+
+	o := new(Outline)
+	o.gfx = gfx
+	o.Width = 2.0
+	o.Color = mgl.Vec4{1.0, 0.6, 0.1, 1.0}
+	o.maskShader = maskShader
+	o.compositeShader = compositeShader
+	o.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+
+	err = o.Resize(width, height)
+	if err != nil {
+		o.Destroy()
+		return nil, err
+	}
+	return o, nil
+}
+
+// Destroy releases the shaders, quad and mask.
+func (o *Outline) Destroy() {
+	o.destroyMask()
+	o.maskShader.Destroy()
+	o.compositeShader.Destroy()
+	o.quad.Destroy()
+}
+
+// destroyMask releases the mask framebuffer and its attachments.
+func (o *Outline) destroyMask() {
+	if o.fbo != 0 {
+		o.gfx.DeleteFramebuffer(o.fbo)
+		o.fbo = 0
+	}
+	if o.maskTex != 0 {
+		o.gfx.DeleteTexture(o.maskTex)
+		o.maskTex = 0
+	}
+	if o.depthRB != 0 {
+		o.gfx.DeleteRenderbuffer(o.depthRB)
+		o.depthRB = 0
+	}
+}
+
+// Resize recreates the mask for a new framebuffer size.
+func (o *Outline) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid outline size %dx%d", width, height)
+	}
+	o.destroyMask()
+	o.width = width
+	o.height = height
+
+	gfx := o.gfx
+	o.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, o.fbo)
+
+	o.maskTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, o.maskTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA8, width, height, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, o.maskTex, 0)
+
+	o.depthRB = gfx.GenRenderbuffer()
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, o.depthRB)
+	gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH_COMPONENT24, width, height)
+	gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.RENDERBUFFER, o.depthRB)
+
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "outline")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	return err
+}
+
+// GetMaskTexture returns the texture holding the outline mask.
+func (o *Outline) GetMaskTexture() graphics.Texture {
+	return o.maskTex
+}
+
+// Begin binds and clears the mask. The clear color is left at zero.
+func (o *Outline) Begin() {
+	gfx := o.gfx
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, o.fbo)
+	gfx.Viewport(0, 0, o.width, o.height)
+	gfx.ClearColor(0.0, 0.0, 0.0, 0.0)
+	gfx.Clear(graphics.COLOR_BUFFER_BIT | graphics.DEPTH_BUFFER_BIT)
+}
+
+// Add draws the Renderable into the mask with the default outline Color.
+func (o *Outline) Add(rend Renderer, r *fizzle.Renderable, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	o.AddWithColor(rend, r, o.Color, perspective, view, camera)
+}
+
+// AddWithColor draws the Renderable into the mask with its own outline color.
+func (o *Outline) AddWithColor(rend Renderer, r *fizzle.Renderable, color mgl.Vec4, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	o.drawColor = color
+	rend.DrawRenderableWithShader(r, o.maskShader, o.bindMaskUniforms, perspective, view, camera)
+}
+
+// End binds the default framebuffer again and sets the viewport to the mask size.
+func (o *Outline) End() {
+	o.gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	o.gfx.Viewport(0, 0, o.width, o.height)
+}
+
+// Draw blends the outlines of the mask over the whole viewport of the bound framebuffer.
+func (o *Outline) Draw(rend Renderer) {
+	gfx := o.gfx
+	ident := mgl.Ident4()
+
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendFunc(graphics.SRC_ALPHA, graphics.ONE_MINUS_SRC_ALPHA)
+	rend.DrawRenderableWithShader(o.quad, o.compositeShader, o.bindCompositeUniforms, ident, ident, nil)
+	gfx.Disable(graphics.BLEND)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// DrawOutlines is a shortcut that builds the mask from the Renderables with
+// the default outline Color and draws the outlines over the default framebuffer.
+func (o *Outline) DrawOutlines(rend Renderer, renderables []*fizzle.Renderable, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	if len(renderables) == 0 {
+		return
+	}
+	o.Begin()
+	for _, r := range renderables {
+		o.Add(rend, r, perspective, view, camera)
+	}
+	o.End()
+	o.Draw(rend)
+}
+
+// bindMaskUniforms sets the outline color of the mask shader.
+func (o *Outline) bindMaskUniforms(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	if loc := shader.GetUniformLocation("OUTLINE_COLOR"); loc >= 0 {
+		o.gfx.Uniform4f(loc, o.drawColor[0], o.drawColor[1], o.drawColor[2], o.drawColor[3])
+	}
+}
+
+// bindCompositeUniforms binds the mask and width for the composite shader.
+func (o *Outline) bindCompositeUniforms(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := o.gfx
+	if loc := shader.GetUniformLocation("OUTLINE_MASK_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, o.maskTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("OUTLINE_WIDTH"); loc >= 0 {
+		width := o.Width
+		if width > MaxOutlineWidth {
+			width = MaxOutlineWidth
+		}
+		gfx.Uniform1f(loc, width)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// PBRAlbedoMap is set in MATERIAL_PBR_MAPS when Tex0 holds the albedo.
+	PBRAlbedoMap = 1 << iota
+
+	// PBRNormalMap is set in MATERIAL_PBR_MAPS when Tex1 holds the normal map.
+	PBRNormalMap
+
+	// PBRMetalRoughMap is set in MATERIAL_PBR_MAPS when the material has a
+	// MetalRoughTex.
+	PBRMetalRoughMap
+
+	// PBROcclusionMap is set in MATERIAL_PBR_MAPS when the material has an
+	// OcclusionTex.
+	PBROcclusionMap
+
+	// PBREmissiveMap is set in MATERIAL_PBR_MAPS when the material has an
+	// EmissiveTex.
+	PBREmissiveMap
+)
+
+// GetPBRMaps returns the MATERIAL_PBR_MAPS bits of the textures the
+// material has, which shaders test before sampling a map.
+func GetPBRMaps(mat *fizzle.Material) int32 {
+	var maps int32
+	if mat.Tex0 != 0 {
+		maps |= PBRAlbedoMap
+	}
+	if mat.Tex1 != 0 {
+		maps |= PBRNormalMap
+	}
+	if mat.PBR.MetalRoughTex != 0 {
+		maps |= PBRMetalRoughMap
+	}
+	if mat.PBR.OcclusionTex != 0 {
+		maps |= PBROcclusionMap
+	}
+	if mat.PBR.EmissiveTex != 0 {
+		maps |= PBREmissiveMap
+	}
+	return maps
+}
+
+// bindPBRMaterial binds the metallic-roughness parameters and maps of the
+// material for shaders that declare them.
+func bindPBRMaterial(gfx graphics.GraphicsProvider, mat *fizzle.Material, shader *fizzle.RenderShader, texturesBound *int32) {
+	pbr := &mat.PBR
+	if loc := shader.GetUniformLocation("MATERIAL_METALLIC"); loc >= 0 {
+		gfx.Uniform1f(loc, pbr.Metallic)
+	}
+	if loc := shader.GetUniformLocation("MATERIAL_ROUGHNESS"); loc >= 0 {
+		gfx.Uniform1f(loc, pbr.Roughness)
+	}
+	if loc := shader.GetUniformLocation("MATERIAL_EMISSIVE"); loc >= 0 {
+		gfx.Uniform3f(loc, pbr.Emissive[0], pbr.Emissive[1], pbr.Emissive[2])
+	}
+	if loc := shader.GetUniformLocation("MATERIAL_PBR_MAPS"); loc >= 0 {
+		gfx.Uniform1i(loc, GetPBRMaps(mat))
+	}
+
+	samplers := [...]struct {
+		name string
+		tex  graphics.Texture
+	}{
+		{"MATERIAL_METAL_ROUGH_TEX", pbr.MetalRoughTex},
+		{"MATERIAL_OCCLUSION_TEX", pbr.OcclusionTex},
+		{"MATERIAL_EMISSIVE_TEX", pbr.EmissiveTex},
+	}
+	for _, sampler := range samplers {
+		if loc := shader.GetUniformLocation(sampler.name); loc >= 0 {
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			gfx.BindTexture(graphics.TEXTURE_2D, sampler.tex)
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"sort"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// PipelineFactory creates an uninitialized Renderer for the graphics
+// provider.
+type PipelineFactory func(gfx graphics.GraphicsProvider) Renderer
+
+// pipelines are the registered factories by name
+var pipelines = make(map[string]PipelineFactory)
+
+// RegisterPipeline makes a renderer available to NewPipeline under the
+// name. The forward and deferred packages register themselves as
+// "forward" and "deferred" when they're imported.
+func RegisterPipeline(name string, factory PipelineFactory) {
+	pipelines[name] = factory
+}
+
+// NewPipeline creates the renderer registered under the name, such as one
+// read from a settings file, so that the rendering pipeline can be swapped
+// at startup. The package of the renderer has to be imported, if only
+// with a blank import, for it to be registered.
+func NewPipeline(name string, gfx graphics.GraphicsProvider) (Renderer, error) {
+	factory, okay := pipelines[name]
+	if !okay {
+		return nil, fmt.Errorf("no rendering pipeline named %q is registered", name)
+	}
+	return factory(gfx), nil
+}
+
+// GetPipelineNames returns the sorted names of the registered pipelines.
+func GetPipelineNames() []string {
+	names := make([]string, 0, len(pipelines))
+	for name := range pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
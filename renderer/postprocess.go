@@ -0,0 +1,399 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+var (
+	// PostProcessVertShader330 is the GLSL vertex shader for full screen
+	// post process effects. It draws a full screen quad and passes the
+	// texture coordinates of the screen on as vs_uv.
+	PostProcessVertShader330 = `#version 330
+  in vec3 VERTEX_POSITION;
+  out vec2 vs_uv;
+
+  void main()
+  {
+    vs_uv = VERTEX_POSITION.xy * 0.5 + 0.5;
+    gl_Position = vec4(VERTEX_POSITION.xy, 0.0, 1.0);
+  }`
+
+	// PostProcessCopyFragShader330 is the GLSL fragment shader that copies
+	// POST_COLOR_TEX, used when no effects are enabled.
+	PostProcessCopyFragShader330 = `#version 330
+  uniform sampler2D POST_COLOR_TEX;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    frag_color = texture(POST_COLOR_TEX, vs_uv);
+  }`
+)
+
+// PostEffect is a full screen effect in a PostProcessStack.
+type PostEffect interface {
+	// Init creates the effect's resources, like its shaders, for a
+	// framebuffer of the given size. It's called when the effect is added
+	// to a stack.
+	Init(gfx graphics.GraphicsProvider, width, height int32) error
+
+	// Resize recreates any resources that depend on the framebuffer size.
+	Resize(width, height int32) error
+
+	// Apply draws the effect of the frame's Color texture over the whole
+	// viewport of the bound framebuffer, usually with frame.DrawQuad.
+	Apply(rend Renderer, frame *PostProcessFrame)
+}
+
+// PostEffectDestroyer is implemented by effects that have resources to
+// release when their stack is destroyed or they are removed from it.
+type PostEffectDestroyer interface {
+	Destroy()
+}
+
+// PostProcessFrame is what an effect reads when it's applied: the output
+// of the previous effect, or the scene for the first one, along with the
+// scene depth and the camera the scene was drawn with.
+type PostProcessFrame struct {
+	// Color is the texture holding the image to process.
+	Color graphics.Texture
+
+	// Depth is the depth texture of the scene.
+	Depth graphics.Texture
+
+	// Target is the framebuffer the effect writes to, which is bound
+	// before Apply is called. Effects that draw into their own
+	// framebuffers first have to bind it again.
+	Target graphics.Buffer
+
+	// Width and Height are the size of the textures.
+	Width  int32
+	Height int32
+
+	// Perspective, View and Camera are the ones the scene was drawn with.
+	Perspective mgl.Mat4
+	View        mgl.Mat4
+	Camera      fizzle.Camera
+
+	// stack is the owning stack whose quad is drawn
+	stack *PostProcessStack
+}
+
+// DrawQuad draws a full screen quad with the shader, which should use
+// PostProcessVertShader330 as its vertex shader. The frame's Color and
+// Depth textures are bound to the POST_COLOR_TEX and POST_DEPTH_TEX
+// samplers, if the shader has them, before the binder is called.
+func (f *PostProcessFrame) DrawQuad(rend Renderer, shader *fizzle.RenderShader, binder RenderBinder) {
+	ps := f.stack
+	ident := mgl.Ident4()
+	ps.effectBinder = binder
+	rend.DrawRenderableWithShader(ps.quad, shader, ps.bindFrameFn, ident, ident, f.Camera)
+	ps.effectBinder = nil
+}
+
+// PostProcessStack draws the scene into a floating point framebuffer and
+// then chains full screen effects over it, ping-ponging between two
+// textures, with the last enabled effect writing to the output framebuffer.
+// The scene is drawn between Begin and End. PostProcessStack is Resizable
+// so it can be registered to follow the renderer's resolution.
+type PostProcessStack struct {
+	// Output is the framebuffer the last effect writes to; 0, the default,
+	// is the window.
+	Output graphics.Buffer
+
+	gfx    graphics.GraphicsProvider
+	width  int32
+	height int32
+
+	effects  []PostEffect
+	disabled map[PostEffect]bool
+
+	sceneFBO   graphics.Buffer
+	sceneColor graphics.Texture
+	sceneDepth graphics.Texture
+
+	pingPongFBOs  [2]graphics.Buffer
+	pingPongColor [2]graphics.Texture
+
+	copyShader *fizzle.RenderShader
+	quad       *fizzle.Renderable
+	frame      PostProcessFrame
+
+	// effectBinder is the binder of the effect drawing with DrawQuad
+	effectBinder RenderBinder
+
+	// bindFrameFn is the method value of bindFrame, saved so that it isn't
+	// allocated for every effect applied
+	bindFrameFn RenderBinder
+}
+
+// NewPostProcessStack creates an empty stack with framebuffers of the
+// given size.
+func NewPostProcessStack(gfx graphics.GraphicsProvider, width, height int32) (*PostProcessStack, error) {
+	shader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, PostProcessCopyFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := new(PostProcessStack)
+	ps.gfx = gfx
+	ps.disabled = make(map[PostEffect]bool)
+	ps.copyShader = shader
+	ps.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	ps.frame.stack = ps
+	ps.bindFrameFn = ps.bindFrame
+
+	err = ps.Resize(width, height)
+	if err != nil {
+		ps.Destroy()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// Destroy releases the framebuffers, the shader and quad of the stack and
+// any effects in it that implement PostEffectDestroyer.
+func (ps *PostProcessStack) Destroy() {
+	for _, effect := range ps.effects {
+		if d, ok := effect.(PostEffectDestroyer); ok {
+			d.Destroy()
+		}
+	}
+	ps.effects = nil
+	ps.destroyFramebuffers()
+	ps.copyShader.Destroy()
+	ps.quad.Destroy()
+}
+
+// destroyFramebuffers releases the scene and ping-pong framebuffers.
+func (ps *PostProcessStack) destroyFramebuffers() {
+	gfx := ps.gfx
+	for _, fbo := range []*graphics.Buffer{&ps.sceneFBO, &ps.pingPongFBOs[0], &ps.pingPongFBOs[1]} {
+		if *fbo != 0 {
+			gfx.DeleteFramebuffer(*fbo)
+			*fbo = 0
+		}
+	}
+	for _, tex := range []*graphics.Texture{&ps.sceneColor, &ps.sceneDepth, &ps.pingPongColor[0], &ps.pingPongColor[1]} {
+		if *tex != 0 {
+			gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
+}
+
+// Resize recreates the framebuffers for a new size and resizes every effect.
+func (ps *PostProcessStack) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid post process size %dx%d", width, height)
+	}
+	ps.destroyFramebuffers()
+	ps.width = width
+	ps.height = height
+
+	gfx := ps.gfx
+	ps.sceneColor = ps.createColorTexture()
+	ps.sceneDepth = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, ps.sceneDepth)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.DEPTH_COMPONENT24, width, height, 0, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+
+	ps.sceneFBO = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, ps.sceneFBO)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, ps.sceneColor, 0)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, ps.sceneDepth, 0)
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "post process scene")
+
+	for i := range ps.pingPongFBOs {
+		if err != nil {
+			break
+		}
+		ps.pingPongColor[i] = ps.createColorTexture()
+		ps.pingPongFBOs[i] = gfx.GenFramebuffer()
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, ps.pingPongFBOs[i])
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, ps.pingPongColor[i], 0)
+		err = CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "post process")
+	}
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	if err != nil {
+		return err
+	}
+
+	for _, effect := range ps.effects {
+		if err := effect.Resize(width, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createColorTexture creates an RGBA16F texture of the stack's size.
+func (ps *PostProcessStack) createColorTexture() graphics.Texture {
+	gfx := ps.gfx
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA16F, ps.width, ps.height, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	return tex
+}
+
+// Add initializes the effect and appends it to the end of the chain. An
+// error is returned, and the effect isn't added, if Init fails.
+func (ps *PostProcessStack) Add(effect PostEffect) error {
+	if err := effect.Init(ps.gfx, ps.width, ps.height); err != nil {
+		return err
+	}
+	ps.effects = append(ps.effects, effect)
+	return nil
+}
+
+// Remove takes the effect out of the chain and destroys it if it
+// implements PostEffectDestroyer.
+func (ps *PostProcessStack) Remove(effect PostEffect) {
+	for i, e := range ps.effects {
+		if e == effect {
+			copy(ps.effects[i:], ps.effects[i+1:])
+			ps.effects[len(ps.effects)-1] = nil
+			ps.effects = ps.effects[:len(ps.effects)-1]
+			delete(ps.disabled, effect)
+			if d, ok := effect.(PostEffectDestroyer); ok {
+				d.Destroy()
+			}
+			return
+		}
+	}
+}
+
+// Effects returns the effects in the order they're applied.
+func (ps *PostProcessStack) Effects() []PostEffect {
+	return ps.effects
+}
+
+// SetEnabled turns an effect in the chain on or off without removing it.
+func (ps *PostProcessStack) SetEnabled(effect PostEffect, enabled bool) {
+	if enabled {
+		delete(ps.disabled, effect)
+	} else {
+		ps.disabled[effect] = true
+	}
+}
+
+// IsEnabled returns true if the effect isn't turned off.
+func (ps *PostProcessStack) IsEnabled(effect PostEffect) bool {
+	return !ps.disabled[effect]
+}
+
+// Framebuffer returns the framebuffer the scene is drawn into.
+func (ps *PostProcessStack) Framebuffer() graphics.Buffer {
+	return ps.sceneFBO
+}
+
+// GetSceneColorTexture returns the texture holding the scene color.
+func (ps *PostProcessStack) GetSceneColorTexture() graphics.Texture {
+	return ps.sceneColor
+}
+
+// GetSceneDepthTexture returns the depth texture of the scene.
+func (ps *PostProcessStack) GetSceneDepthTexture() graphics.Texture {
+	return ps.sceneDepth
+}
+
+// Begin binds the scene framebuffer and sets the viewport to its size.
+// The scene should be drawn between Begin and End.
+func (ps *PostProcessStack) Begin() {
+	ps.gfx.BindFramebuffer(graphics.FRAMEBUFFER, ps.sceneFBO)
+	ps.gfx.Viewport(0, 0, ps.width, ps.height)
+}
+
+// End applies the enabled effects in order and leaves the Output
+// framebuffer bound. The perspective, view and camera should be the ones
+// the scene was drawn with.
+func (ps *PostProcessStack) End(rend Renderer, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	gfx := ps.gfx
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Disable(graphics.BLEND)
+
+	ps.frame.Color = ps.sceneColor
+	ps.frame.Depth = ps.sceneDepth
+	ps.frame.Width = ps.width
+	ps.frame.Height = ps.height
+	ps.frame.Perspective = perspective
+	ps.frame.View = view
+	ps.frame.Camera = camera
+
+	last := -1
+	for i, effect := range ps.effects {
+		if !ps.disabled[effect] {
+			last = i
+		}
+	}
+
+	if last < 0 {
+		ps.frame.Target = ps.Output
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, ps.Output)
+		ps.frame.DrawQuad(rend, ps.copyShader, nil)
+	} else {
+		target := 0
+		for i, effect := range ps.effects[:last+1] {
+			if ps.disabled[effect] {
+				continue
+			}
+			if i == last {
+				ps.frame.Target = ps.Output
+			} else {
+				ps.frame.Target = ps.pingPongFBOs[target]
+			}
+			gfx.BindFramebuffer(graphics.FRAMEBUFFER, ps.frame.Target)
+			gfx.Viewport(0, 0, ps.width, ps.height)
+			effect.Apply(rend, &ps.frame)
+
+			// the next effect reads what this one wrote
+			ps.frame.Color = ps.pingPongColor[target]
+			target = 1 - target
+		}
+	}
+
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// bindFrame binds the frame's textures and then calls the effect's binder.
+func (ps *PostProcessStack) bindFrame(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := ps.gfx
+	if loc := shader.GetUniformLocation("POST_COLOR_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, ps.frame.Color)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("POST_DEPTH_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, ps.frame.Depth)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("POST_TEXEL_SIZE"); loc >= 0 {
+		gfx.Uniform2f(loc, 1.0/float32(ps.frame.Width), 1.0/float32(ps.frame.Height))
+	}
+	if ps.effectBinder != nil {
+		ps.effectBinder(rend, r, shader, texturesBound)
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"math"
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// The sort key of a queued draw packs, from the most significant bits,
+// the layer of its core and then the shader, the material and the depth
+// for opaque draws, so that draws sharing a shader and textures end up
+// next to each other and are drawn front to back among themselves. The
+// depth of transparent draws comes before the shader and is inverted, so
+// that they're drawn back to front and only share state when they happen
+// to be neighbors.
+const (
+	sortKeyLayerShift = 56
+
+	sortKeyShaderBits   = 16
+	sortKeyMaterialBits = 20
+	sortKeyDepthBits    = 20
+
+	sortKeyShaderMask   = 1<<sortKeyShaderBits - 1
+	sortKeyMaterialMask = 1<<sortKeyMaterialBits - 1
+	sortKeyDepthMask    = 1<<sortKeyDepthBits - 1
+)
+
+// queuedDraw is a Renderable node submitted to a RenderQueue.
+type queuedDraw struct {
+	renderable *fizzle.Renderable
+	shader     *fizzle.RenderShader
+	binder     RenderBinder
+
+	// center is the world space center of the node's bounding rectangle
+	center mgl.Vec3
+
+	// shaderID and materialID number the distinct shaders and materials
+	// of the frame in the order they were first submitted
+	shaderID   uint64
+	materialID uint64
+
+	// key is the sort key, set when the queue is sorted
+	key uint64
+}
+
+// queuedMaterial is the texture state that a material rebinds. The
+// Materials shared between Renderables are told apart by their address.
+type queuedMaterial struct {
+	shared     *fizzle.Material
+	tex0       graphics.Texture
+	tex1       graphics.Texture
+	lightmap   graphics.Texture
+	metalRough graphics.Texture
+	occlusion  graphics.Texture
+	emissive   graphics.Texture
+}
+
+// drawsByKey sorts queued draws by increasing sort key.
+type drawsByKey []queuedDraw
+
+func (d drawsByKey) Len() int           { return len(d) }
+func (d drawsByKey) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d drawsByKey) Less(i, j int) bool { return d[i].key < d[j].key }
+
+// RenderQueue collects the draws of a frame so that they can be drawn in
+// a better order than they were submitted in. Each draw gets a 64-bit sort
+// key built from the Layer of its material, its shader, its material and
+// its view space depth. Opaque Renderables are sorted to minimize the
+// shader and texture changes and then front to back, so that the depth
+// test rejects the hidden fragments early, and the Renderables whose
+// material is Transparent are sorted back to front, so that they blend
+// over each other correctly.
+//
+// Groups are split into their nodes when submitted and each node is sorted
+// by the center of its bounding rectangle.
+type RenderQueue struct {
+	opaque      []queuedDraw
+	transparent []queuedDraw
+
+	shaderIDs   map[*fizzle.RenderShader]uint64
+	materialIDs map[queuedMaterial]uint64
+}
+
+// NewRenderQueue creates a new, empty render queue.
+func NewRenderQueue() *RenderQueue {
+	rq := new(RenderQueue)
+	rq.opaque = make([]queuedDraw, 0, 256)
+	rq.transparent = make([]queuedDraw, 0, 64)
+	rq.shaderIDs = make(map[*fizzle.RenderShader]uint64)
+	rq.materialIDs = make(map[queuedMaterial]uint64)
+	return rq
+}
+
+// Submit adds the visible nodes of the Renderable to the queue. If shader
+// is nil, the nodes are drawn with the renderer's DrawRenderable.
+func (rq *RenderQueue) Submit(r *fizzle.Renderable, shader *fizzle.RenderShader, binder RenderBinder) {
+	// only draw visible nodes
+	if !r.IsVisible {
+		return
+	}
+
+	// if the renderable is a group, just try to submit the children
+	if r.IsGroup {
+		for _, child := range r.Children {
+			rq.Submit(child, shader, binder)
+		}
+		return
+	}
+
+	b := r.BoundingRect
+	center := b.Bottom.Add(b.Top).Mul(0.5)
+	world := r.GetTransformMat4().Mul4x1(center.Vec4(1.0))
+
+	draw := queuedDraw{renderable: r, shader: shader, binder: binder, center: world.Vec3()}
+	drawShader := shader
+	if drawShader == nil {
+		drawShader = r.GetShader()
+	}
+	draw.shaderID = rq.getShaderID(drawShader)
+	draw.materialID = rq.getMaterialID(r)
+
+	if r.GetMaterial().Transparent {
+		rq.transparent = append(rq.transparent, draw)
+	} else {
+		rq.opaque = append(rq.opaque, draw)
+	}
+}
+
+// getShaderID returns the number of the shader in the frame.
+func (rq *RenderQueue) getShaderID(shader *fizzle.RenderShader) uint64 {
+	id, ok := rq.shaderIDs[shader]
+	if !ok {
+		id = uint64(len(rq.shaderIDs)) & sortKeyShaderMask
+		rq.shaderIDs[shader] = id
+	}
+	return id
+}
+
+// getMaterialID returns the number of the Renderable's material in the
+// frame.
+func (rq *RenderQueue) getMaterialID(r *fizzle.Renderable) uint64 {
+	var mat queuedMaterial
+	if r.Material != nil {
+		mat.shared = r.Material
+	} else {
+		core := r.Core
+		mat.tex0 = core.Tex0
+		mat.tex1 = core.Tex1
+		mat.metalRough = core.PBR.MetalRoughTex
+		mat.occlusion = core.PBR.OcclusionTex
+		mat.emissive = core.PBR.EmissiveTex
+	}
+	mat.lightmap = r.Core.Lightmap
+	id, ok := rq.materialIDs[mat]
+	if !ok {
+		id = uint64(len(rq.materialIDs)) & sortKeyMaterialMask
+		rq.materialIDs[mat] = id
+	}
+	return id
+}
+
+// Len returns the number of nodes in the queue.
+func (rq *RenderQueue) Len() int {
+	return len(rq.opaque) + len(rq.transparent)
+}
+
+// Reset empties the queue while keeping the allocated storage for reuse.
+func (rq *RenderQueue) Reset() {
+	for i := range rq.opaque {
+		rq.opaque[i] = queuedDraw{}
+	}
+	for i := range rq.transparent {
+		rq.transparent[i] = queuedDraw{}
+	}
+	rq.opaque = rq.opaque[:0]
+	rq.transparent = rq.transparent[:0]
+	for shader := range rq.shaderIDs {
+		delete(rq.shaderIDs, shader)
+	}
+	for mat := range rq.materialIDs {
+		delete(rq.materialIDs, mat)
+	}
+}
+
+// Cull removes the nodes whose bounds are outside of the frustum.
+func (rq *RenderQueue) Cull(frustum *Frustum) {
+	rq.opaque = cullDraws(rq.opaque, frustum)
+	rq.transparent = cullDraws(rq.transparent, frustum)
+}
+
+// cullDraws removes the draws outside of the frustum in place.
+func cullDraws(draws []queuedDraw, frustum *Frustum) []queuedDraw {
+	kept := draws[:0]
+	for i := range draws {
+		if frustum.IsRenderableVisible(draws[i].renderable) {
+			kept = append(kept, draws[i])
+		}
+	}
+	for i := len(kept); i < len(draws); i++ {
+		draws[i] = queuedDraw{}
+	}
+	return kept
+}
+
+// Sort builds the sort keys of the nodes with their depth as seen with
+// the view matrix and orders them by it.
+func (rq *RenderQueue) Sort(view mgl.Mat4) {
+	for i := range rq.opaque {
+		d := &rq.opaque[i]
+		d.key = uint64(d.renderable.GetMaterial().Layer)<<sortKeyLayerShift |
+			d.shaderID<<(sortKeyMaterialBits+sortKeyDepthBits) |
+			d.materialID<<sortKeyDepthBits |
+			depthSortBits(view, d.center)
+	}
+	for i := range rq.transparent {
+		d := &rq.transparent[i]
+		d.key = uint64(d.renderable.GetMaterial().Layer)<<sortKeyLayerShift |
+			(sortKeyDepthMask-depthSortBits(view, d.center))<<(sortKeyShaderBits+sortKeyMaterialBits) |
+			d.shaderID<<sortKeyMaterialBits |
+			d.materialID
+	}
+	sort.Stable(drawsByKey(rq.opaque))
+	sort.Stable(drawsByKey(rq.transparent))
+}
+
+// depthSortBits quantizes the view space depth of the point to the bits
+// of the sort key. The bits of a positive float keep its order, so the
+// top bits of the depth are used, which keeps the precision relative to
+// the distance; points behind the camera get zero.
+func depthSortBits(view mgl.Mat4, p mgl.Vec3) uint64 {
+	// the camera looks down -Z in view space
+	depth := -view.Mul4x1(p.Vec4(1.0))[2]
+	if depth <= 0.0 {
+		return 0
+	}
+	return uint64(math.Float32bits(depth)>>(31-sortKeyDepthBits)) & sortKeyDepthMask
+}
+
+// DrawOpaque draws the opaque nodes in the order they were sorted.
+func (rq *RenderQueue) DrawOpaque(rend Renderer, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	rq.draw(rend, rq.opaque, perspective, view, camera)
+}
+
+// DrawTransparent draws the transparent nodes in the order they were
+// sorted with blending enabled and depth writes disabled, so that they're
+// hidden by the opaque geometry in front of them but don't hide each
+// other. Each node is blended with the Blend mode of its material.
+func (rq *RenderQueue) DrawTransparent(rend Renderer, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	if len(rq.transparent) == 0 {
+		return
+	}
+
+	gfx := rend.GetGraphics()
+	gfx.Enable(graphics.BLEND)
+	gfx.BlendEquation(graphics.FUNC_ADD)
+	gfx.DepthMask(false)
+	blend := fizzle.BlendAlpha
+	SetBlendMode(gfx, blend)
+	for i := range rq.transparent {
+		d := &rq.transparent[i]
+		if mode := d.renderable.GetMaterial().Blend; mode != blend {
+			blend = mode
+			SetBlendMode(gfx, blend)
+		}
+		rq.draw(rend, rq.transparent[i:i+1], perspective, view, camera)
+	}
+	if blend != fizzle.BlendAlpha {
+		SetBlendMode(gfx, fizzle.BlendAlpha)
+	}
+	gfx.DepthMask(true)
+	gfx.Disable(graphics.BLEND)
+}
+
+// DrawTransparentWithOIT draws the transparent nodes into the weighted
+// blended accumulation of the OIT and composites them over its Output.
+// The draw order doesn't matter, so the nodes don't have to be sorted, but
+// their shaders have to write the weighted outputs; renderers that support
+// OIT set OIT_ENABLED in their binders while drawing them.
+func (rq *RenderQueue) DrawTransparentWithOIT(rend Renderer, oit *OIT, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	if len(rq.transparent) == 0 {
+		return
+	}
+
+	oit.Begin()
+	rq.draw(rend, rq.transparent, perspective, view, camera)
+	oit.End(rend)
+}
+
+// Flush sorts the queue, draws the opaque and then the transparent nodes
+// and empties the queue.
+func (rq *RenderQueue) Flush(rend Renderer, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	rq.Sort(view)
+	rq.DrawOpaque(rend, perspective, view, camera)
+	rq.DrawTransparent(rend, perspective, view, camera)
+	rq.Reset()
+}
+
+// draw draws the nodes with their shader, or the renderer's default.
+func (rq *RenderQueue) draw(rend Renderer, draws []queuedDraw, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera) {
+	for i := range draws {
+		d := &draws[i]
+		if d.shader == nil {
+			rend.DrawRenderable(d.renderable, d.binder, perspective, view, camera)
+		} else {
+			rend.DrawRenderableWithShader(d.renderable, d.shader, d.binder, perspective, view, camera)
+		}
+	}
+}
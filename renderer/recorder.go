@@ -0,0 +1,320 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// recorderQueueSize is the number of frames that can wait to be written
+	// before capturing blocks.
+	recorderQueueSize = 8
+)
+
+// FrameSink receives the frames captured by a Recorder in order.
+type FrameSink interface {
+	// WriteFrame writes the frame with the index, starting at 0.
+	WriteFrame(index int, img *image.RGBA) error
+
+	// Close finishes writing after the last frame.
+	Close() error
+}
+
+// PNGSequence is a FrameSink that writes every frame to a numbered PNG file
+// such as frame_000042.png.
+type PNGSequence struct {
+	// Dir is the directory the files are written to.
+	Dir string
+
+	// Prefix is put in front of the frame number in the file names.
+	Prefix string
+}
+
+// NewPNGSequence creates the directory, if needed, and returns a sink
+// writing PNG files into it.
+func NewPNGSequence(dir string, prefix string) (*PNGSequence, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	seq := new(PNGSequence)
+	seq.Dir = dir
+	seq.Prefix = prefix
+	return seq, nil
+}
+
+// WriteFrame implements FrameSink.
+func (seq *PNGSequence) WriteFrame(index int, img *image.RGBA) error {
+	path := filepath.Join(seq.Dir, fmt.Sprintf("%s%06d.png", seq.Prefix, index))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close implements FrameSink.
+func (seq *PNGSequence) Close() error {
+	return nil
+}
+
+// FFmpegPipe is a FrameSink that pipes raw frames into an ffmpeg process
+// for encoding into a video.
+type FFmpegPipe struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	width  int
+	height int
+}
+
+// NewFFmpegPipe starts ffmpeg encoding frames of the size at the frame rate
+// into the output file. ffmpegPath is the executable to run, which can be
+// just "ffmpeg" if it's on the PATH. The extra arguments are passed to
+// ffmpeg before the output file, such as "-c:v", "libx264", "-crf", "18".
+func NewFFmpegPipe(ffmpegPath string, output string, width, height int32, fps float64, extraArgs ...string) (*FFmpegPipe, error) {
+	args := []string{
+		"-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", width, height),
+		"-r", strconv.FormatFloat(fps, 'f', -1, 64),
+		"-i", "-",
+		"-pix_fmt", "yuv420p",
+	}
+	args = append(args, extraArgs...)
+	args = append(args, output)
+
+	fp := new(FFmpegPipe)
+	fp.width = int(width)
+	fp.height = int(height)
+	fp.cmd = exec.Command(ffmpegPath, args...)
+	fp.cmd.Stderr = os.Stderr
+
+	var err error
+	fp.stdin, err = fp.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = fp.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %v", err)
+	}
+	return fp, nil
+}
+
+// WriteFrame implements FrameSink.
+func (fp *FFmpegPipe) WriteFrame(index int, img *image.RGBA) error {
+	b := img.Bounds()
+	if b.Dx() != fp.width || b.Dy() != fp.height {
+		return fmt.Errorf("frame %d is %dx%d but the video is %dx%d", index, b.Dx(), b.Dy(), fp.width, fp.height)
+	}
+	for y := 0; y < fp.height; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+fp.width*4]
+		if _, err := fp.stdin.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements FrameSink by closing the pipe and waiting for ffmpeg
+// to finish encoding.
+func (fp *FFmpegPipe) Close() error {
+	if err := fp.stdin.Close(); err != nil {
+		return err
+	}
+	return fp.cmd.Wait()
+}
+
+// recordedFrame is a captured frame waiting to be written.
+type recordedFrame struct {
+	index int
+	img   *image.RGBA
+}
+
+// Recorder captures a frame every time CaptureFrame is called and writes
+// them to a FrameSink on a background goroutine. The frames are read back
+// with an AsyncCapture so recording doesn't stall rendering.
+//
+// The recorder doesn't look at the wall clock. To get a video that plays at
+// the right speed, the game should advance by FrameDelta for every captured
+// frame, such as by passing it to Clock.Advance, no matter how long the
+// frame really took to render.
+type Recorder struct {
+	// FPS is the frame rate of the recording.
+	FPS float64
+
+	gfx     graphics.GraphicsProvider
+	sink    FrameSink
+	capture *AsyncCapture
+	width   int32
+	height  int32
+
+	nextIndex int
+	nextWrite int
+	ready     map[int]*image.RGBA
+	dropped   int
+
+	frames  chan recordedFrame
+	wg      sync.WaitGroup
+	errLock sync.Mutex
+	err     error
+	stopped bool
+}
+
+// NewRecorder creates a recorder capturing frames of the size at the frame
+// rate into the sink.
+func NewRecorder(gfx graphics.GraphicsProvider, sink FrameSink, width, height int32, fps float64) *Recorder {
+	r := new(Recorder)
+	r.FPS = fps
+	r.gfx = gfx
+	r.sink = sink
+	r.width = width
+	r.height = height
+	r.capture = NewAsyncCapture(gfx, DefaultCaptureSlots)
+	r.ready = make(map[int]*image.RGBA)
+	r.frames = make(chan recordedFrame, recorderQueueSize)
+
+	r.wg.Add(1)
+	go r.writeFrames()
+	return r
+}
+
+// FrameDelta returns the time in seconds between recorded frames.
+func (r *Recorder) FrameDelta() float64 {
+	return 1.0 / r.FPS
+}
+
+// FrameCount returns the number of frames captured so far.
+func (r *Recorder) FrameCount() int {
+	return r.nextIndex
+}
+
+// DroppedCount returns the number of frames that couldn't be read back.
+func (r *Recorder) DroppedCount() int {
+	return r.dropped
+}
+
+// Err returns the first error the sink returned, if any.
+func (r *Recorder) Err() error {
+	r.errLock.Lock()
+	defer r.errLock.Unlock()
+	return r.err
+}
+
+// CaptureFrame starts reading back the framebuffer, or the back buffer if
+// fbo is 0. For the back buffer it should be called after drawing and
+// before the buffers are swapped. This must be called on the thread
+// owning the GL context.
+func (r *Recorder) CaptureFrame(fbo graphics.Buffer) error {
+	if r.stopped {
+		return fmt.Errorf("the recorder has been stopped")
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	r.capture.Poll()
+	index := r.nextIndex
+	r.nextIndex++
+	err := r.capture.Capture(fbo, 0, 0, r.width, r.height, func(img *image.RGBA) {
+		r.frameReady(index, img)
+	})
+	if err == nil {
+		return nil
+	}
+
+	// every slot is busy, so fall back to a stalling read
+	img, err := CaptureFramebuffer(r.gfx, fbo, 0, 0, r.width, r.height)
+	if err != nil {
+		r.frameReady(index, nil)
+		return err
+	}
+	r.frameReady(index, img)
+	return nil
+}
+
+// Stop waits for the pending frames to be read back and written and then
+// closes the sink. It returns the first error encountered while writing.
+func (r *Recorder) Stop() error {
+	if r.stopped {
+		return r.Err()
+	}
+	r.stopped = true
+
+	for r.capture.Pending() > 0 {
+		r.capture.Poll()
+		if r.capture.Pending() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	r.capture.Destroy()
+
+	close(r.frames)
+	r.wg.Wait()
+
+	if err := r.sink.Close(); err != nil {
+		r.setErr(err)
+	}
+	return r.Err()
+}
+
+// frameReady queues the frames for writing in capture order; the async
+// captures can finish out of order. A nil image is a dropped frame.
+func (r *Recorder) frameReady(index int, img *image.RGBA) {
+	r.ready[index] = img
+	for {
+		img, okay := r.ready[r.nextWrite]
+		if !okay {
+			return
+		}
+		delete(r.ready, r.nextWrite)
+		if img == nil {
+			r.dropped++
+		} else {
+			r.frames <- recordedFrame{index: r.nextWrite - r.dropped, img: img}
+		}
+		r.nextWrite++
+	}
+}
+
+// writeFrames hands the queued frames to the sink until the queue closes.
+func (r *Recorder) writeFrames() {
+	defer r.wg.Done()
+	for f := range r.frames {
+		if r.Err() != nil {
+			continue
+		}
+
+		// the alpha in the back buffer isn't meaningful for a video
+		for i := 3; i < len(f.img.Pix); i += 4 {
+			f.img.Pix[i] = 255
+		}
+		if err := r.sink.WriteFrame(f.index, f.img); err != nil {
+			r.setErr(err)
+		}
+	}
+}
+
+// setErr keeps the first error.
+func (r *Recorder) setErr(err error) {
+	r.errLock.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.errLock.Unlock()
+}
@@ -4,83 +4,397 @@
 package renderer
 
 import (
+	"image"
+	"unsafe"
+
 	mgl "github.com/go-gl/mathgl/mgl32"
 	"github.com/tbogdala/fizzle"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/fizzle/window"
 )
 
 // Renderer is the common interface between the built-in deferred or forward
-// style renderers.
+// style renderers, so that engine code and binders can be written once and
+// the pipeline chosen at startup; see NewPipeline.
 type Renderer interface {
 	Init(width, height int32) error
 	Destroy()
 	ChangeResolution(width, height int32)
 	GetResolution() (int32, int32)
+	GetAspectRatio() float32
 	GetGraphics() graphics.GraphicsProvider
 	SetGraphics(gp graphics.GraphicsProvider)
+	SetWindow(w window.Window)
+	GetWindow() window.Window
 
+	BeginFrame() float64
 	DrawRenderable(r *fizzle.Renderable, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
 	DrawRenderableWithShader(r *fizzle.Renderable, shader *fizzle.RenderShader, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
 	DrawLines(r *fizzle.Renderable, shader *fizzle.RenderShader, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
+	DrawRenderableInstanced(r *fizzle.Renderable, transforms []mgl.Mat4, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
+	DrawRenderableColoredInstanced(r *fizzle.Renderable, instances []ColoredInstance, binder RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera)
 	EndRenderFrame()
+	CaptureScreenshot() (*image.RGBA, error)
 }
 
 // RenderBinder is the type of the function called when binding shader variables
 // which allows for custom binding of VBO objects.
 type RenderBinder func(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32)
 
+// DrawMatrices holds the transform matrices bound to the shader uniforms
+// when drawing a Renderable.
+type DrawMatrices struct {
+	Model mgl.Mat4
+	View  mgl.Mat4
+	MVP   mgl.Mat4
+	MV    mgl.Mat4
+	VP    mgl.Mat4
+}
+
+// Prepare calculates all of the matrices for the model transform.
+func (m *DrawMatrices) Prepare(perspective *mgl.Mat4, view *mgl.Mat4, model mgl.Mat4) {
+	m.Model = model
+	m.View = *view
+	m.VP = perspective.Mul4(m.View)
+	m.MVP = m.VP.Mul4(m.Model)
+	m.MV = m.View.Mul4(m.Model)
+}
+
+// DrawScratcher is implemented by renderers that keep the storage for the
+// matrices calculated while binding a Renderable, so that pointers to them
+// can be handed to the graphics provider without allocating on every draw
+// call. A renderer only draws on one thread at a time, so the storage
+// isn't shared between threads.
+type DrawScratcher interface {
+	// GetDrawScratch returns the renderer's matrix storage.
+	GetDrawScratch() *DrawMatrices
+}
+
+// fallbackDrawScratch is the matrix storage used for renderers that don't
+// implement DrawScratcher. Like the rest of the draw path it must only be
+// used from one thread; renderers drawing on other threads should keep
+// their own storage.
+var fallbackDrawScratch DrawMatrices
+
+// getDrawScratch returns the matrix storage of the renderer, or the shared
+// fallback storage if it doesn't keep any.
+func getDrawScratch(renderer Renderer) *DrawMatrices {
+	if scratcher, okay := renderer.(DrawScratcher); okay {
+		return scratcher.GetDrawScratch()
+	}
+	return &fallbackDrawScratch
+}
+
 // BindAndDraw is a common shader variable binder meant to be called from the
 // renderer implementations.
 func BindAndDraw(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader,
 	binders []RenderBinder, perspective mgl.Mat4, view mgl.Mat4, camera fizzle.Camera, mode uint32) {
+	BindAndDrawRef(renderer, r, shader, binders, &perspective, &view, camera, mode)
+}
+
+// BindAndDrawRef works like BindAndDraw but the perspective and view
+// matrixes are passed by pointer to avoid copying them for every Renderable
+// drawn; they are not modified.
+func BindAndDrawRef(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader,
+	binders []RenderBinder, perspective *mgl.Mat4, view *mgl.Mat4, camera fizzle.Camera, mode uint32) {
+	m := getDrawScratch(renderer)
+	m.Model = r.GetTransformMat4()
+	m.View = *view
+	gfx := bindRenderable(renderer, r, r.GetMaterial(), nil, shader, binders, m, perspective, camera)
+
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
+	gfx.DrawElements(graphics.Enum(mode), getElementCount(r, mode), graphics.UNSIGNED_INT, gfx.PtrOffset(0))
+	restoreMaterialState(gfx, r.GetMaterial())
+	gfx.BindVertexArray(0)
+}
+
+// BindAndDrawInstanced works like BindAndDraw but draws one instance of the
+// Renderable for each of the transforms. The transforms are uploaded into
+// instanceVBO and bound to the INSTANCE_M_MATRIX vertex attribute which
+// advances once per instance. The Renderable's own transform is still bound
+// to M_MATRIX so shaders can combine them.
+func BindAndDrawInstanced(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader,
+	binders []RenderBinder, perspective *mgl.Mat4, view *mgl.Mat4, camera fizzle.Camera, mode uint32,
+	instanceVBO graphics.Buffer, transforms []mgl.Mat4) {
+	const floatSize = 4
+	const matSize = floatSize * 16
+	if len(transforms) == 0 {
+		return
+	}
+
+	m := getDrawScratch(renderer)
+	m.Model = r.GetTransformMat4()
+	m.View = *view
+	gfx := bindRenderable(renderer, r, r.GetMaterial(), nil, shader, binders, m, perspective, camera)
+	if loc := shader.GetUniformLocation("INSTANCE_COLORED"); loc >= 0 {
+		gfx.Uniform1i(loc, 0)
+	}
+
+	// a mat4 attribute takes up four consecutive attribute locations, one per column
+	shaderInstanceM := shader.GetAttribLocation("INSTANCE_M_MATRIX")
+	if shaderInstanceM >= 0 {
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, instanceVBO)
+		gfx.BufferData(graphics.ARRAY_BUFFER, matSize*len(transforms), gfx.Ptr(&transforms[0][0]), graphics.STREAM_DRAW)
+		for col := uint32(0); col < 4; col++ {
+			loc := uint32(shaderInstanceM) + col
+			gfx.EnableVertexAttribArray(loc)
+			gfx.VertexAttribPointer(loc, 4, graphics.FLOAT, false, matSize, gfx.PtrOffset(int(col)*4*floatSize))
+			gfx.VertexAttribDivisor(loc, 1)
+		}
+	}
+
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
+	gfx.DrawElementsInstanced(graphics.Enum(mode), getElementCount(r, mode), graphics.UNSIGNED_INT, gfx.PtrOffset(0), int32(len(transforms)))
+
+	// reset the divisors since they are stored in the Renderable's VAO and
+	// would otherwise affect normal draws of the same Renderable
+	if shaderInstanceM >= 0 {
+		for col := uint32(0); col < 4; col++ {
+			loc := uint32(shaderInstanceM) + col
+			gfx.VertexAttribDivisor(loc, 0)
+			gfx.DisableVertexAttribArray(loc)
+		}
+	}
+	restoreMaterialState(gfx, r.GetMaterial())
+	gfx.BindVertexArray(0)
+}
+
+// ColoredInstance is the per-instance data uploaded by BindAndDrawColoredInstanced.
+type ColoredInstance struct {
+	// Transform is the model matrix of the instance.
+	Transform mgl.Mat4
+
+	// Color tints the instance; shaders usually multiply it with the
+	// material's diffuse color.
+	Color mgl.Vec4
+}
+
+// BindAndDrawColoredInstanced works like BindAndDrawInstanced but also
+// binds each instance's color to the INSTANCE_COLOR vertex attribute. The
+// INSTANCE_COLORED uniform is set to 1 so that shaders shared with
+// BindAndDrawInstanced, which sets it to 0, know that the color is bound.
+func BindAndDrawColoredInstanced(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader,
+	binders []RenderBinder, perspective *mgl.Mat4, view *mgl.Mat4, camera fizzle.Camera, mode uint32,
+	instanceVBO graphics.Buffer, instances []ColoredInstance) {
+	const floatSize = 4
+	const colorOffset = floatSize * 16
+	const stride = int32(unsafe.Sizeof(ColoredInstance{}))
+	if len(instances) == 0 {
+		return
+	}
+
+	m := getDrawScratch(renderer)
+	m.Model = r.GetTransformMat4()
+	m.View = *view
+	gfx := bindRenderable(renderer, r, r.GetMaterial(), nil, shader, binders, m, perspective, camera)
+	if loc := shader.GetUniformLocation("INSTANCE_COLORED"); loc >= 0 {
+		gfx.Uniform1i(loc, 1)
+	}
+
+	gfx.BindBuffer(graphics.ARRAY_BUFFER, instanceVBO)
+	gfx.BufferData(graphics.ARRAY_BUFFER, int(stride)*len(instances), gfx.Ptr(&instances[0].Transform[0]), graphics.STREAM_DRAW)
+
+	// a mat4 attribute takes up four consecutive attribute locations, one per column
+	shaderInstanceM := shader.GetAttribLocation("INSTANCE_M_MATRIX")
+	if shaderInstanceM >= 0 {
+		for col := uint32(0); col < 4; col++ {
+			loc := uint32(shaderInstanceM) + col
+			gfx.EnableVertexAttribArray(loc)
+			gfx.VertexAttribPointer(loc, 4, graphics.FLOAT, false, stride, gfx.PtrOffset(int(col)*4*floatSize))
+			gfx.VertexAttribDivisor(loc, 1)
+		}
+	}
+
+	shaderInstanceColor := shader.GetAttribLocation("INSTANCE_COLOR")
+	if shaderInstanceColor >= 0 {
+		loc := uint32(shaderInstanceColor)
+		gfx.EnableVertexAttribArray(loc)
+		gfx.VertexAttribPointer(loc, 4, graphics.FLOAT, false, stride, gfx.PtrOffset(colorOffset))
+		gfx.VertexAttribDivisor(loc, 1)
+	}
+
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
+	gfx.DrawElementsInstanced(graphics.Enum(mode), getElementCount(r, mode), graphics.UNSIGNED_INT, gfx.PtrOffset(0), int32(len(instances)))
+
+	// reset the divisors since they are stored in the Renderable's VAO
+	if shaderInstanceM >= 0 {
+		for col := uint32(0); col < 4; col++ {
+			loc := uint32(shaderInstanceM) + col
+			gfx.VertexAttribDivisor(loc, 0)
+			gfx.DisableVertexAttribArray(loc)
+		}
+	}
+	if shaderInstanceColor >= 0 {
+		gfx.VertexAttribDivisor(uint32(shaderInstanceColor), 0)
+		gfx.DisableVertexAttribArray(uint32(shaderInstanceColor))
+	}
+	restoreMaterialState(gfx, r.GetMaterial())
+	gfx.BindVertexArray(0)
+}
+
+// SkinnedInstance is the per-instance data uploaded by BindAndDrawSkinnedInstanced.
+type SkinnedInstance struct {
+	// Transform is the model matrix of the instance.
+	Transform mgl.Mat4
+
+	// Frame is the row of the Renderable's BakedAnimations to pose the
+	// instance with, as returned by BakedAnimations.Frame.
+	Frame float32
+}
+
+// BindAndDrawSkinnedInstanced works like BindAndDrawInstanced but also
+// binds each instance's animation frame to the INSTANCE_ANIM_FRAME vertex
+// attribute so that every instance can be posed from the Renderable's
+// BakedAnimations texture.
+func BindAndDrawSkinnedInstanced(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader,
+	binders []RenderBinder, perspective *mgl.Mat4, view *mgl.Mat4, camera fizzle.Camera, mode uint32,
+	instanceVBO graphics.Buffer, instances []SkinnedInstance) {
+	const floatSize = 4
+	const frameOffset = floatSize * 16
+	const stride = int32(unsafe.Sizeof(SkinnedInstance{}))
+	if len(instances) == 0 {
+		return
+	}
+
+	m := getDrawScratch(renderer)
+	m.Model = r.GetTransformMat4()
+	m.View = *view
+	gfx := bindRenderable(renderer, r, r.GetMaterial(), nil, shader, binders, m, perspective, camera)
+
+	gfx.BindBuffer(graphics.ARRAY_BUFFER, instanceVBO)
+	gfx.BufferData(graphics.ARRAY_BUFFER, int(stride)*len(instances), gfx.Ptr(&instances[0].Transform[0]), graphics.STREAM_DRAW)
+
+	// a mat4 attribute takes up four consecutive attribute locations, one per column
+	shaderInstanceM := shader.GetAttribLocation("INSTANCE_M_MATRIX")
+	if shaderInstanceM >= 0 {
+		for col := uint32(0); col < 4; col++ {
+			loc := uint32(shaderInstanceM) + col
+			gfx.EnableVertexAttribArray(loc)
+			gfx.VertexAttribPointer(loc, 4, graphics.FLOAT, false, stride, gfx.PtrOffset(int(col)*4*floatSize))
+			gfx.VertexAttribDivisor(loc, 1)
+		}
+	}
+
+	shaderInstanceFrame := shader.GetAttribLocation("INSTANCE_ANIM_FRAME")
+	if shaderInstanceFrame >= 0 {
+		loc := uint32(shaderInstanceFrame)
+		gfx.EnableVertexAttribArray(loc)
+		gfx.VertexAttribPointer(loc, 1, graphics.FLOAT, false, stride, gfx.PtrOffset(frameOffset))
+		gfx.VertexAttribDivisor(loc, 1)
+	}
+
+	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
+	gfx.DrawElementsInstanced(graphics.Enum(mode), getElementCount(r, mode), graphics.UNSIGNED_INT, gfx.PtrOffset(0), int32(len(instances)))
+
+	// reset the divisors since they are stored in the Renderable's VAO
+	if shaderInstanceM >= 0 {
+		for col := uint32(0); col < 4; col++ {
+			loc := uint32(shaderInstanceM) + col
+			gfx.VertexAttribDivisor(loc, 0)
+			gfx.DisableVertexAttribArray(loc)
+		}
+	}
+	if shaderInstanceFrame >= 0 {
+		gfx.VertexAttribDivisor(uint32(shaderInstanceFrame), 0)
+		gfx.DisableVertexAttribArray(uint32(shaderInstanceFrame))
+	}
+	restoreMaterialState(gfx, r.GetMaterial())
+	gfx.BindVertexArray(0)
+}
+
+// getElementCount returns the number of element indexes to draw for the
+// Renderable, where FaceCount is the number of primitives of the mode.
+func getElementCount(r *fizzle.Renderable, mode uint32) int32 {
+	switch mode {
+	case graphics.LINES:
+		return int32(r.FaceCount * 2)
+	case graphics.LINE_STRIP:
+		return int32(r.FaceCount + 1)
+	case graphics.TRIANGLE_STRIP, graphics.TRIANGLE_FAN:
+		return int32(r.FaceCount + 2)
+	case graphics.POINTS:
+		return int32(r.FaceCount)
+	default:
+		return int32(r.FaceCount * 3)
+	}
+}
+
+// bindRenderable binds the shader, the VAO and all of the shader variables
+// needed to draw the Renderable with the material and then calls the
+// binders. If pose is nil, the bones are bound from the Renderable's
+// skeleton. The VAO is
+// left bound for the caller to issue the draw call. If the renderer is a
+// VertexArrayMapper, the VAO comes from its cache.
+//
+// The Model and View matrices in m must be set. If perspective is non-nil the
+// rest of the matrices are calculated only as needed by the shader, otherwise
+// m is expected to have been fully prepared already (see DrawMatrices.Prepare).
+func bindRenderable(renderer Renderer, r *fizzle.Renderable, mat *fizzle.Material, pose []mgl.Mat4, shader *fizzle.RenderShader,
+	binders []RenderBinder, m *DrawMatrices, perspective *mgl.Mat4, camera fizzle.Camera) graphics.GraphicsProvider {
 	gfx := renderer.GetGraphics()
 	gfx.UseProgram(shader.Prog)
-	gfx.BindVertexArray(r.Core.Vao)
+	gfx.BindVertexArray(getVertexArray(renderer, r))
 
 	texturesBound := int32(0)
-	model := r.GetTransformMat4()
 
 	shaderMvp := shader.GetUniformLocation("MVP_MATRIX")
 	if shaderMvp >= 0 {
-		mvp := perspective.Mul4(view).Mul4(model)
-		gfx.UniformMatrix4fv(shaderMvp, 1, false, mvp)
+		if perspective != nil {
+			m.MVP = perspective.Mul4(m.View).Mul4(m.Model)
+		}
+		gfx.UniformMatrix4fv(shaderMvp, 1, false, &m.MVP)
 	}
 
 	shaderMv := shader.GetUniformLocation("MV_MATRIX")
 	if shaderMv >= 0 {
-		mv := view.Mul4(model)
-		gfx.UniformMatrix4fv(shaderMv, 1, false, mv)
+		if perspective != nil {
+			m.MV = m.View.Mul4(m.Model)
+		}
+		gfx.UniformMatrix4fv(shaderMv, 1, false, &m.MV)
+	}
+
+	shaderVp := shader.GetUniformLocation("VP_MATRIX")
+	if shaderVp >= 0 {
+		if perspective != nil {
+			m.VP = perspective.Mul4(m.View)
+		}
+		gfx.UniformMatrix4fv(shaderVp, 1, false, &m.VP)
 	}
 
 	shaderV := shader.GetUniformLocation("V_MATRIX")
 	if shaderV >= 0 {
-		gfx.UniformMatrix4fv(shaderV, 1, false, view)
+		gfx.UniformMatrix4fv(shaderV, 1, false, &m.View)
 	}
 
 	shaderM := shader.GetUniformLocation("M_MATRIX")
 	if shaderM >= 0 {
-		gfx.UniformMatrix4fv(shaderM, 1, false, model)
+		gfx.UniformMatrix4fv(shaderM, 1, false, &m.Model)
 	}
 
 	shaderDiffuse := shader.GetUniformLocation("MATERIAL_DIFFUSE")
 	if shaderDiffuse >= 0 {
-		gfx.Uniform4f(shaderDiffuse, r.Core.DiffuseColor[0], r.Core.DiffuseColor[1], r.Core.DiffuseColor[2], r.Core.DiffuseColor[3])
+		gfx.Uniform4f(shaderDiffuse, mat.DiffuseColor[0], mat.DiffuseColor[1], mat.DiffuseColor[2], mat.DiffuseColor[3])
 	}
 
 	shaderSpecular := shader.GetUniformLocation("MATERIAL_SPECULAR")
 	if shaderSpecular >= 0 {
-		gfx.Uniform4f(shaderSpecular, r.Core.SpecularColor[0], r.Core.SpecularColor[1], r.Core.SpecularColor[2], r.Core.SpecularColor[3])
+		gfx.Uniform4f(shaderSpecular, mat.SpecularColor[0], mat.SpecularColor[1], mat.SpecularColor[2], mat.SpecularColor[3])
 	}
 
 	shaderShiny := shader.GetUniformLocation("MATERIAL_SHININESS")
 	if shaderShiny >= 0 {
-		gfx.Uniform1f(shaderShiny, r.Core.Shininess)
+		gfx.Uniform1f(shaderShiny, mat.Shininess)
+	}
+
+	shaderAlphaCutoff := shader.GetUniformLocation("MATERIAL_ALPHA_CUTOFF")
+	if shaderAlphaCutoff >= 0 {
+		gfx.Uniform1f(shaderAlphaCutoff, mat.AlphaCutoff)
 	}
 
 	shaderTex1 := shader.GetUniformLocation("MATERIAL_TEX_0")
 	if shaderTex1 >= 0 {
 		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(texturesBound)))
-		gfx.BindTexture(graphics.TEXTURE_2D, r.Core.Tex0)
+		gfx.BindTexture(graphics.TEXTURE_2D, mat.Tex0)
 		gfx.Uniform1i(shaderTex1, texturesBound)
 		texturesBound++
 	}
@@ -88,14 +402,38 @@ func BindAndDraw(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderS
 	shaderTex2 := shader.GetUniformLocation("MATERIAL_TEX_1")
 	if shaderTex2 >= 0 {
 		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(texturesBound)))
-		gfx.BindTexture(graphics.TEXTURE_2D, r.Core.Tex1)
+		gfx.BindTexture(graphics.TEXTURE_2D, mat.Tex1)
 		gfx.Uniform1i(shaderTex2, texturesBound)
 		texturesBound++
 	}
 
+	bindPBRMaterial(gfx, mat, shader, &texturesBound)
+	bindMaterialUniforms(gfx, mat, shader, &texturesBound)
+
+	shaderLightmap := shader.GetUniformLocation("LIGHTMAP")
+	if shaderLightmap >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, r.Core.Lightmap)
+		gfx.Uniform1i(shaderLightmap, texturesBound)
+		texturesBound++
+	}
+
 	shaderBones := shader.GetUniformLocation("BONES")
-	if shaderBones >= 0 && r.Core.Skeleton != nil && len(r.Core.Skeleton.Bones) > 0 {
-		gfx.UniformMatrix4fv(shaderBones, int32(len(r.Core.Skeleton.Bones)), false, r.Core.Skeleton.PoseTransforms)
+	if shaderBones >= 0 {
+		if pose == nil && r.Core.Skeleton != nil {
+			pose = r.Core.Skeleton.PoseTransforms[:len(r.Core.Skeleton.Bones)]
+		}
+		if len(pose) > 0 {
+			gfx.UniformMatrix4fv(shaderBones, int32(len(pose)), false, &pose[0])
+		}
+	}
+
+	shaderBoneTex := shader.GetUniformLocation("BONE_TEXTURE")
+	if shaderBoneTex >= 0 && r.Core.BakedAnimations != nil {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, r.Core.BakedAnimations.Texture)
+		gfx.Uniform1i(shaderBoneTex, texturesBound)
+		texturesBound++
 	}
 
 	if camera != nil {
@@ -120,6 +458,13 @@ func BindAndDraw(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderS
 		gfx.VertexAttribPointer(uint32(shaderVertUv), 2, graphics.FLOAT, false, r.Core.VBOStride, gfx.PtrOffset(r.Core.UvVBOOffset))
 	}
 
+	shaderVertUv1 := shader.GetAttribLocation("VERTEX_UV_1")
+	if shaderVertUv1 >= 0 && r.Core.Uv1VBO != 0 {
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.Uv1VBO)
+		gfx.EnableVertexAttribArray(uint32(shaderVertUv1))
+		gfx.VertexAttribPointer(uint32(shaderVertUv1), 2, graphics.FLOAT, false, r.Core.VBOStride, gfx.PtrOffset(r.Core.Uv1VBOOffset))
+	}
+
 	shaderNormal := shader.GetAttribLocation("VERTEX_NORMAL")
 	if shaderNormal >= 0 {
 		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.NormsVBO)
@@ -141,6 +486,13 @@ func BindAndDraw(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderS
 		gfx.VertexAttribPointer(uint32(shaderBoneFids), 4, graphics.FLOAT, false, r.Core.VBOStride, gfx.PtrOffset(r.Core.BoneFidsVBOOffset))
 	}
 
+	shaderColor := shader.GetAttribLocation("VERTEX_COLOR")
+	if shaderColor >= 0 && r.Core.ColorVBO != 0 {
+		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.ColorVBO)
+		gfx.EnableVertexAttribArray(uint32(shaderColor))
+		gfx.VertexAttribPointer(uint32(shaderColor), 4, graphics.FLOAT, false, r.Core.VBOStride, gfx.PtrOffset(r.Core.ColorVBOOffset))
+	}
+
 	shaderBoneWeights := shader.GetAttribLocation("VERTEX_BONE_WEIGHTS")
 	if shaderBoneWeights >= 0 {
 		gfx.BindBuffer(graphics.ARRAY_BUFFER, r.Core.BoneWeightsVBO)
@@ -149,19 +501,13 @@ func BindAndDraw(renderer Renderer, r *fizzle.Renderable, shader *fizzle.RenderS
 	}
 
 	// if a custom binder function was passed in then call it
-	if len(binders) > 0 {
-		for _, binder := range binders {
-			if binder != nil {
-				binder(renderer, r, shader, &texturesBound)
-			}
+	for _, binder := range binders {
+		if binder != nil {
+			binder(renderer, r, shader, &texturesBound)
 		}
 	}
 
-	gfx.BindBuffer(graphics.ELEMENT_ARRAY_BUFFER, r.Core.ElementsVBO)
-	if mode != graphics.LINES {
-		gfx.DrawElements(graphics.Enum(mode), int32(r.FaceCount*3), graphics.UNSIGNED_INT, gfx.PtrOffset(0))
-	} else {
-		gfx.DrawElements(graphics.Enum(mode), int32(r.FaceCount*2), graphics.UNSIGNED_INT, gfx.PtrOffset(0))
-	}
-	gfx.BindVertexArray(0)
+	applyMaterialState(gfx, mat)
+
+	return gfx
 }
@@ -0,0 +1,192 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// RenderTargetDepth is the kind of depth attachment of a RenderTarget.
+type RenderTargetDepth int
+
+const (
+	// RenderTargetNoDepth creates the target without a depth buffer, for
+	// things like UI that are drawn without the depth test.
+	RenderTargetNoDepth RenderTargetDepth = iota
+
+	// RenderTargetDepthBuffer attaches a depth and stencil renderbuffer,
+	// which is the cheapest choice when the depth isn't sampled later.
+	RenderTargetDepthBuffer
+
+	// RenderTargetDepthTexture attaches a depth texture that can be
+	// sampled afterwards with GetDepthTexture.
+	RenderTargetDepthTexture
+)
+
+// RenderTarget is a framebuffer with a color texture and an optional depth
+// attachment for drawing views into textures, like security cameras,
+// mirrors or minimaps, that are then used as the textures of Renderables.
+// Scenes are drawn into it between Begin and End. RenderTarget is
+// Resizable so it can be registered to follow the renderer's resolution.
+type RenderTarget struct {
+	// ClearColor is the color the target is cleared to by Clear.
+	ClearColor mgl.Vec4
+
+	gfx         graphics.GraphicsProvider
+	fbo         graphics.Buffer
+	colorTex    graphics.Texture
+	depthTex    graphics.Texture
+	depthRB     graphics.Buffer
+	colorFormat int32
+	depth       RenderTargetDepth
+	width       int32
+	height      int32
+}
+
+// NewRenderTarget creates a render target of the size with the internal
+// format of the color texture, such as RGBA8 or RGBA16F for HDR, and the
+// kind of depth attachment.
+func NewRenderTarget(gfx graphics.GraphicsProvider, width, height int32, colorFormat int32, depth RenderTargetDepth) (*RenderTarget, error) {
+	rt := new(RenderTarget)
+	rt.gfx = gfx
+	rt.colorFormat = colorFormat
+	rt.depth = depth
+	rt.ClearColor = mgl.Vec4{0.0, 0.0, 0.0, 1.0}
+
+	err := rt.Resize(width, height)
+	if err != nil {
+		rt.Destroy()
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Destroy releases the framebuffer and its attachments.
+func (rt *RenderTarget) Destroy() {
+	gfx := rt.gfx
+	if rt.fbo != 0 {
+		gfx.DeleteFramebuffer(rt.fbo)
+		rt.fbo = 0
+	}
+	for _, tex := range []*graphics.Texture{&rt.colorTex, &rt.depthTex} {
+		if *tex != 0 {
+			gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
+	if rt.depthRB != 0 {
+		gfx.DeleteRenderbuffer(rt.depthRB)
+		rt.depthRB = 0
+	}
+}
+
+// Resize recreates the attachments for a new size. The textures are new
+// objects afterwards, so Renderables using them need to get them again.
+func (rt *RenderTarget) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid render target size %dx%d", width, height)
+	}
+	rt.Destroy()
+	rt.width = width
+	rt.height = height
+
+	gfx := rt.gfx
+	rt.fbo = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, rt.fbo)
+
+	var dataType graphics.Enum = graphics.UNSIGNED_BYTE
+	if rt.colorFormat == graphics.RGBA16F || rt.colorFormat == graphics.RGBA32F {
+		dataType = graphics.FLOAT
+	}
+	rt.colorTex = rt.createTexture(rt.colorFormat, graphics.RGBA, dataType, graphics.LINEAR)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, rt.colorTex, 0)
+
+	switch rt.depth {
+	case RenderTargetDepthBuffer:
+		rt.depthRB = gfx.GenRenderbuffer()
+		gfx.BindRenderbuffer(graphics.RENDERBUFFER, rt.depthRB)
+		gfx.RenderbufferStorage(graphics.RENDERBUFFER, graphics.DEPTH24_STENCIL8, width, height)
+		gfx.FramebufferRenderbuffer(graphics.FRAMEBUFFER, graphics.DEPTH_STENCIL_ATTACHMENT, graphics.RENDERBUFFER, rt.depthRB)
+	case RenderTargetDepthTexture:
+		rt.depthTex = rt.createTexture(graphics.DEPTH_COMPONENT24, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT, graphics.NEAREST)
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, rt.depthTex, 0)
+	}
+
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "render target")
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindRenderbuffer(graphics.RENDERBUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	return err
+}
+
+// createTexture creates a texture of the target's size.
+func (rt *RenderTarget) createTexture(internalFormat int32, format, dataType graphics.Enum, filter int32) graphics.Texture {
+	gfx := rt.gfx
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, internalFormat, rt.width, rt.height, 0, format, dataType, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, filter)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, filter)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	return tex
+}
+
+// GetTexture returns the color texture that the target is drawn into.
+func (rt *RenderTarget) GetTexture() graphics.Texture {
+	return rt.colorTex
+}
+
+// GetDepthTexture returns the depth texture, which is only created for
+// targets with RenderTargetDepthTexture.
+func (rt *RenderTarget) GetDepthTexture() graphics.Texture {
+	return rt.depthTex
+}
+
+// Framebuffer returns the framebuffer object of the target.
+func (rt *RenderTarget) Framebuffer() graphics.Buffer {
+	return rt.fbo
+}
+
+// GetSize returns the width and height of the target.
+func (rt *RenderTarget) GetSize() (int32, int32) {
+	return rt.width, rt.height
+}
+
+// GetAspectRatio returns the width of the target divided by its height,
+// for building the perspective matrix of the view drawn into it.
+func (rt *RenderTarget) GetAspectRatio() float32 {
+	return float32(rt.width) / float32(rt.height)
+}
+
+// Begin binds the framebuffer and sets the viewport to the target's size.
+func (rt *RenderTarget) Begin() {
+	rt.gfx.BindFramebuffer(graphics.FRAMEBUFFER, rt.fbo)
+	rt.gfx.Viewport(0, 0, rt.width, rt.height)
+}
+
+// Clear clears the color of the bound target to ClearColor and its depth
+// and stencil if it has them.
+func (rt *RenderTarget) Clear() {
+	gfx := rt.gfx
+	gfx.ClearColor(rt.ClearColor[0], rt.ClearColor[1], rt.ClearColor[2], rt.ClearColor[3])
+	var bits graphics.Enum = graphics.COLOR_BUFFER_BIT
+	switch rt.depth {
+	case RenderTargetDepthBuffer:
+		bits |= graphics.DEPTH_BUFFER_BIT | graphics.STENCIL_BUFFER_BIT
+	case RenderTargetDepthTexture:
+		bits |= graphics.DEPTH_BUFFER_BIT
+	}
+	gfx.Clear(bits)
+}
+
+// End binds the default framebuffer again.
+func (rt *RenderTarget) End() {
+	rt.gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+}
@@ -0,0 +1,127 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"strings"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Resizable is implemented by the resources whose size follows the
+// renderer's resolution, such as post-process targets.
+type Resizable interface {
+	Resize(width, height int32) error
+}
+
+// ResizeFunc adapts a function to the Resizable interface.
+type ResizeFunc func(width, height int32) error
+
+// Resize calls the function.
+func (f ResizeFunc) Resize(width, height int32) error {
+	return f(width, height)
+}
+
+// resizeEntry is a registered resource and the scale of its size.
+type resizeEntry struct {
+	target Resizable
+	scale  float32
+}
+
+// ResizeRegistry keeps a list of resources to resize whenever the
+// renderer's resolution changes so that applications don't need to rebuild
+// their offscreen buffers by hand.
+type ResizeRegistry struct {
+	entries []resizeEntry
+}
+
+// Register adds the resource to be resized to the full resolution.
+func (rr *ResizeRegistry) Register(r Resizable) {
+	rr.RegisterScaled(r, 1.0)
+}
+
+// RegisterScaled adds the resource to be resized to the resolution times
+// the scale, such as 0.5 for a half resolution bloom target. Registering
+// a resource again updates its scale.
+func (rr *ResizeRegistry) RegisterScaled(r Resizable, scale float32) {
+	for i := range rr.entries {
+		if rr.entries[i].target == r {
+			rr.entries[i].scale = scale
+			return
+		}
+	}
+	rr.entries = append(rr.entries, resizeEntry{target: r, scale: scale})
+}
+
+// Unregister stops resizing the resource.
+func (rr *ResizeRegistry) Unregister(r Resizable) {
+	for i := range rr.entries {
+		if rr.entries[i].target == r {
+			copy(rr.entries[i:], rr.entries[i+1:])
+			rr.entries[len(rr.entries)-1] = resizeEntry{}
+			rr.entries = rr.entries[:len(rr.entries)-1]
+			return
+		}
+	}
+}
+
+// Len returns the number of registered resources.
+func (rr *ResizeRegistry) Len() int {
+	return len(rr.entries)
+}
+
+// ResizeAll resizes every registered resource in the order they were
+// registered. All of them are resized even if some fail, and the errors
+// are combined into the one returned.
+func (rr *ResizeRegistry) ResizeAll(width, height int32) error {
+	var failures []string
+	for _, e := range rr.entries {
+		w, h := ScaleResolution(width, height, e.scale)
+		if err := e.target.Resize(w, h); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to resize %d resources: %s", len(failures), strings.Join(failures, "; "))
+}
+
+// ScaleResolution returns the resolution times the scale, at least 1x1.
+func ScaleResolution(width, height int32, scale float32) (int32, int32) {
+	w := int32(float32(width) * scale)
+	h := int32(float32(height) * scale)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// ScreenProjection is an orthographic projection matching the resolution,
+// with the origin in the lower left corner, for drawing UI in pixels.
+// Register it with the renderer to keep it up to date.
+type ScreenProjection struct {
+	Matrix mgl.Mat4
+	Width  int32
+	Height int32
+}
+
+// NewScreenProjection creates a projection for the resolution.
+func NewScreenProjection(width, height int32) *ScreenProjection {
+	sp := new(ScreenProjection)
+	sp.Resize(width, height)
+	return sp
+}
+
+// Resize implements Resizable by rebuilding the projection.
+func (sp *ScreenProjection) Resize(width, height int32) error {
+	sp.Width = width
+	sp.Height = height
+	sp.Matrix = mgl.Ortho(0.0, float32(width), 0.0, float32(height), -1.0, 1.0)
+	return nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"image"
+
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// DefaultCaptureSlots is the number of asynchronous captures that can
+	// be in flight at once.
+	DefaultCaptureSlots = 3
+)
+
+// CaptureFramebuffer reads the rectangle of the framebuffer's first color
+// attachment, or of the back buffer if fbo is 0, into a new image. The rows
+// are flipped so that the image is top to bottom. For the default
+// framebuffer this should be called before the buffers are swapped.
+// This stalls until the GPU has finished drawing; see AsyncCapture to avoid that.
+func CaptureFramebuffer(gfx graphics.GraphicsProvider, fbo graphics.Buffer, x, y, width, height int32) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid capture size %dx%d", width, height)
+	}
+
+	pixels := make([]uint8, width*height*4)
+	bindReadFramebuffer(gfx, fbo)
+	gfx.ReadPixels(x, y, width, height, graphics.RGBA, graphics.UNSIGNED_BYTE, gfx.Ptr(pixels))
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	if err := gfx.GetError(); err != graphics.NO_ERROR {
+		return nil, fmt.Errorf("failed to read the framebuffer pixels (error 0x%x)", uint32(err))
+	}
+	return flipPixels(pixels, width, height), nil
+}
+
+// AsyncCapture reads framebuffers into pixel buffer objects so that the
+// copy happens on the GPU without stalling rendering. The image is handed
+// to a callback from Poll once a fence shows the copy has finished,
+// usually a frame or two later.
+type AsyncCapture struct {
+	gfx   graphics.GraphicsProvider
+	slots []captureSlot
+}
+
+// captureSlot is a pixel buffer and the capture pending in it.
+type captureSlot struct {
+	pbo     graphics.Buffer
+	size    int
+	fence   graphics.Sync
+	width   int32
+	height  int32
+	pending bool
+	done    func(img *image.RGBA)
+
+	// flushed is set once the fence was waited on with
+	// SYNC_FLUSH_COMMANDS_BIT, which makes sure it gets signaled even if
+	// nothing else flushes the commands, like a buffer swap
+	flushed bool
+}
+
+// NewAsyncCapture creates an AsyncCapture that can have up to slotCount
+// captures in flight.
+func NewAsyncCapture(gfx graphics.GraphicsProvider, slotCount int) *AsyncCapture {
+	ac := new(AsyncCapture)
+	ac.gfx = gfx
+	ac.slots = make([]captureSlot, slotCount)
+	return ac
+}
+
+// Destroy releases the pixel buffers and fences. Pending captures are dropped.
+func (ac *AsyncCapture) Destroy() {
+	for i := range ac.slots {
+		slot := &ac.slots[i]
+		if slot.pending {
+			ac.gfx.DeleteSync(slot.fence)
+		}
+		if slot.pbo != 0 {
+			ac.gfx.DeleteBuffer(slot.pbo)
+		}
+		ac.slots[i] = captureSlot{}
+	}
+}
+
+// Capture starts copying the rectangle of the framebuffer, or the back buffer
+// if fbo is 0, into a free pixel buffer. The done function is called from
+// Poll with the image once the copy finishes, or with nil if the image
+// couldn't be read back. An error is returned if all of the slots are busy.
+func (ac *AsyncCapture) Capture(fbo graphics.Buffer, x, y, width, height int32, done func(img *image.RGBA)) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid capture size %dx%d", width, height)
+	}
+
+	var slot *captureSlot
+	for i := range ac.slots {
+		if !ac.slots[i].pending {
+			slot = &ac.slots[i]
+			break
+		}
+	}
+	if slot == nil {
+		return fmt.Errorf("all %d capture slots are busy", len(ac.slots))
+	}
+
+	gfx := ac.gfx
+	size := int(width * height * 4)
+	if slot.pbo == 0 {
+		slot.pbo = gfx.GenBuffer()
+	}
+	gfx.BindBuffer(graphics.PIXEL_PACK_BUFFER, slot.pbo)
+	if slot.size != size {
+		gfx.BufferData(graphics.PIXEL_PACK_BUFFER, size, nil, graphics.STREAM_READ)
+		slot.size = size
+	}
+
+	bindReadFramebuffer(gfx, fbo)
+	gfx.ReadPixels(x, y, width, height, graphics.RGBA, graphics.UNSIGNED_BYTE, gfx.PtrOffset(0))
+	gfx.BindBuffer(graphics.PIXEL_PACK_BUFFER, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+
+	slot.fence = gfx.FenceSync(graphics.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	slot.width = width
+	slot.height = height
+	slot.done = done
+	slot.pending = true
+	slot.flushed = false
+	return nil
+}
+
+// Pending returns the number of captures that haven't finished.
+func (ac *AsyncCapture) Pending() int {
+	count := 0
+	for i := range ac.slots {
+		if ac.slots[i].pending {
+			count++
+		}
+	}
+	return count
+}
+
+// Poll hands the finished captures to their callbacks without waiting on
+// the ones still in progress. It should be called once a frame.
+func (ac *AsyncCapture) Poll() {
+	gfx := ac.gfx
+	for i := range ac.slots {
+		slot := &ac.slots[i]
+		if !slot.pending {
+			continue
+		}
+
+		var flags graphics.Bitfield
+		if !slot.flushed {
+			flags = graphics.SYNC_FLUSH_COMMANDS_BIT
+			slot.flushed = true
+		}
+		status := gfx.ClientWaitSync(slot.fence, flags, 0)
+		if status == graphics.TIMEOUT_EXPIRED {
+			continue
+		}
+		gfx.DeleteSync(slot.fence)
+		slot.fence = 0
+		slot.pending = false
+
+		var img *image.RGBA
+		if status != graphics.WAIT_FAILED {
+			gfx.BindBuffer(graphics.PIXEL_PACK_BUFFER, slot.pbo)
+			ptr := gfx.MapBufferRange(graphics.PIXEL_PACK_BUFFER, 0, slot.size, graphics.MAP_READ_BIT)
+			if ptr != nil {
+				mapped := (*[1 << 30]uint8)(ptr)[:slot.size:slot.size]
+				img = flipPixels(mapped, slot.width, slot.height)
+				gfx.UnmapBuffer(graphics.PIXEL_PACK_BUFFER)
+			}
+			gfx.BindBuffer(graphics.PIXEL_PACK_BUFFER, 0)
+		}
+
+		done := slot.done
+		slot.done = nil
+		if done != nil {
+			done(img)
+		}
+	}
+}
+
+// bindReadFramebuffer makes the framebuffer, or the back buffer for 0,
+// the source for reading pixels.
+func bindReadFramebuffer(gfx graphics.GraphicsProvider, fbo graphics.Buffer) {
+	gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, fbo)
+	if fbo == 0 {
+		gfx.ReadBuffer(graphics.BACK)
+	} else {
+		gfx.ReadBuffer(graphics.COLOR_ATTACHMENT0)
+	}
+}
+
+// flipPixels copies the bottom to top rows read from OpenGL into a new
+// top to bottom image.
+func flipPixels(pixels []uint8, width, height int32) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	stride := int(width) * 4
+	for y := 0; y < int(height); y++ {
+		src := pixels[(int(height)-1-y)*stride : (int(height)-y)*stride]
+		copy(img.Pix[y*img.Stride:], src)
+	}
+	return img
+}
@@ -0,0 +1,251 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// SnapshotEntry is the captured state of a single Renderable node. Only
+// the geometry of the Renderable is drawn from the live node; the rest is
+// drawn from the copies, which the simulation can't change.
+type SnapshotEntry struct {
+	Renderable *fizzle.Renderable
+	Transform  mgl.Mat4
+
+	// Material is a copy of the node's material.
+	Material fizzle.Material
+
+	// Pose is a copy of the bone matrices of the node's skeleton, if it
+	// has one.
+	Pose []mgl.Mat4
+}
+
+// capture copies the state of the Renderable into the entry, reusing the
+// storage of the pose.
+func (e *SnapshotEntry) capture(r *fizzle.Renderable) {
+	e.Renderable = r
+	e.Transform = r.GetTransformMat4()
+
+	mat := r.GetMaterial()
+	e.Material = *mat
+	if len(mat.Uniforms) > 0 {
+		e.Material.Uniforms = make(map[string]interface{}, len(mat.Uniforms))
+		for name, value := range mat.Uniforms {
+			e.Material.Uniforms[name] = value
+		}
+	}
+
+	e.Pose = e.Pose[:0]
+	if skel := r.Core.Skeleton; skel != nil {
+		e.Pose = append(e.Pose, skel.PoseTransforms[:len(skel.Bones)]...)
+	}
+}
+
+// Snapshot is a copy of the transforms, materials and poses of the visible
+// Renderable nodes at the end of a simulation tick. Once published it is
+// not modified, so the render thread can draw from it while the simulation
+// moves on. The simulation must not change the geometry of the captured
+// Renderables or destroy them while a snapshot holding them may be drawn.
+type Snapshot struct {
+	Entries []SnapshotEntry
+
+	// Tick is the simulation tick the snapshot was captured on.
+	Tick uint64
+}
+
+// Reset clears the snapshot while keeping the allocated storage for reuse.
+func (s *Snapshot) Reset() {
+	for i := range s.Entries {
+		s.Entries[i].Renderable = nil
+		s.Entries[i].Material = fizzle.Material{}
+	}
+	s.Entries = s.Entries[:0]
+}
+
+// Capture appends the world transform, material and pose of every visible,
+// non-group node in the renderables to the snapshot.
+func (s *Snapshot) Capture(renderables ...*fizzle.Renderable) {
+	for _, r := range renderables {
+		s.captureNode(r)
+	}
+}
+
+// captureNode adds the renderable, or the children of a group, to the snapshot.
+func (s *Snapshot) captureNode(r *fizzle.Renderable) {
+	if !r.IsVisible {
+		return
+	}
+
+	if r.IsGroup {
+		for _, child := range r.Children {
+			s.captureNode(child)
+		}
+		return
+	}
+
+	// reuse the entries left from before the last Reset to keep their poses' storage
+	if len(s.Entries) < cap(s.Entries) {
+		s.Entries = s.Entries[:len(s.Entries)+1]
+	} else {
+		s.Entries = append(s.Entries, SnapshotEntry{})
+	}
+	s.Entries[len(s.Entries)-1].capture(r)
+}
+
+// Record appends a DrawCommand for every entry in the snapshot to the list
+// using the captured transforms, materials and poses. If shader is nil,
+// each node's captured material shader is used.
+func (s *Snapshot) Record(list *CommandList, shader *fizzle.RenderShader, binder RenderBinder,
+	perspective mgl.Mat4, view mgl.Mat4, mode uint32) {
+	for i := range s.Entries {
+		e := &s.Entries[i]
+		sh := shader
+		if sh == nil {
+			sh = e.Material.Shader
+		}
+
+		list.Commands = append(list.Commands, DrawCommand{})
+		cmd := &list.Commands[len(list.Commands)-1]
+		cmd.Renderable = e.Renderable
+		cmd.Shader = sh
+		cmd.Binder = binder
+		cmd.Mode = mode
+		cmd.Material = &e.Material
+		cmd.Pose = e.Pose
+		cmd.Matrices.Prepare(&perspective, &view, e.Transform)
+	}
+}
+
+// SnapshotBuffer exchanges snapshots between the simulation goroutine and
+// the render thread without either one waiting on the other. It keeps three
+// snapshots: one being written, the latest published and one being read.
+type SnapshotBuffer struct {
+	lock      sync.Mutex
+	write     *Snapshot
+	latest    *Snapshot
+	read      *Snapshot
+	hasLatest bool
+}
+
+// NewSnapshotBuffer creates a new SnapshotBuffer with empty snapshots.
+func NewSnapshotBuffer() *SnapshotBuffer {
+	sb := new(SnapshotBuffer)
+	sb.write = new(Snapshot)
+	sb.latest = new(Snapshot)
+	sb.read = new(Snapshot)
+	return sb
+}
+
+// BeginWrite returns the snapshot owned by the simulation goroutine, reset
+// and ready to capture into.
+func (sb *SnapshotBuffer) BeginWrite() *Snapshot {
+	sb.write.Reset()
+	return sb.write
+}
+
+// Publish makes the snapshot returned by BeginWrite available to the render thread.
+func (sb *SnapshotBuffer) Publish() {
+	sb.lock.Lock()
+	sb.write, sb.latest = sb.latest, sb.write
+	sb.hasLatest = true
+	sb.lock.Unlock()
+}
+
+// Latest returns the most recently published snapshot for the render thread.
+// The snapshot stays valid until the next call to Latest. If nothing new
+// was published since the last call, the same snapshot is returned again.
+func (sb *SnapshotBuffer) Latest() *Snapshot {
+	sb.lock.Lock()
+	if sb.hasLatest {
+		sb.read, sb.latest = sb.latest, sb.read
+		sb.hasLatest = false
+	}
+	sb.lock.Unlock()
+	return sb.read
+}
+
+// SimulationUpdate is the function called for every simulation tick. It should
+// update the game state and then capture the Renderables to draw into the snapshot.
+type SimulationUpdate func(tick uint64, deltaTime float64, snapshot *Snapshot)
+
+// SimulationThread runs the simulation on its own goroutine at a fixed tick
+// rate, publishing a snapshot to the SnapshotBuffer after every tick. The
+// render thread then draws the latest snapshot at its own rate.
+// NOTE: the SimulationUpdate function must not make any graphics calls.
+type SimulationThread struct {
+	// TickRate is the number of simulation ticks per second.
+	TickRate float64
+
+	// Buffer is where the snapshots are published.
+	Buffer *SnapshotBuffer
+
+	update SimulationUpdate
+	stop   chan struct{}
+	done   sync.WaitGroup
+}
+
+// NewSimulationThread creates a new simulation thread running the update
+// function at tickRate ticks per second. An error is returned if the tick
+// rate isn't positive.
+func NewSimulationThread(tickRate float64, update SimulationUpdate) (*SimulationThread, error) {
+	if tickRate <= 0.0 {
+		return nil, fmt.Errorf("the simulation tick rate must be positive, got %v", tickRate)
+	}
+	st := new(SimulationThread)
+	st.TickRate = tickRate
+	st.Buffer = NewSnapshotBuffer()
+	st.update = update
+	return st, nil
+}
+
+// Start launches the simulation goroutine. An error is returned if
+// TickRate isn't positive.
+func (st *SimulationThread) Start() error {
+	if st.TickRate <= 0.0 {
+		return fmt.Errorf("the simulation tick rate must be positive, got %v", st.TickRate)
+	}
+	st.stop = make(chan struct{})
+	st.done.Add(1)
+	go st.run()
+	return nil
+}
+
+// Stop signals the simulation goroutine to exit and waits for it.
+func (st *SimulationThread) Stop() {
+	if st.stop == nil {
+		return
+	}
+	close(st.stop)
+	st.done.Wait()
+	st.stop = nil
+}
+
+// run ticks the simulation until stopped.
+func (st *SimulationThread) run() {
+	defer st.done.Done()
+
+	step := time.Duration(float64(time.Second) / st.TickRate)
+	ticker := time.NewTicker(step)
+	defer ticker.Stop()
+
+	var tick uint64
+	for {
+		select {
+		case <-st.stop:
+			return
+		case <-ticker.C:
+			snap := st.Buffer.BeginWrite()
+			snap.Tick = tick
+			st.update(tick, step.Seconds(), snap)
+			st.Buffer.Publish()
+			tick++
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+var (
+	// SRGBEncodeFragShader330 is the GLSL fragment shader that converts the
+	// linear colors of POST_COLOR_TEX to sRGB with the exact sRGB curve.
+	SRGBEncodeFragShader330 = `#version 330
+  uniform sampler2D POST_COLOR_TEX;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  vec3 LinearToSRGB(vec3 c)
+  {
+    c = clamp(c, 0.0, 1.0);
+    vec3 low = c * 12.92;
+    vec3 high = 1.055 * pow(c, vec3(1.0 / 2.4)) - 0.055;
+    return mix(high, low, vec3(lessThanEqual(c, vec3(0.0031308))));
+  }
+
+  void main()
+  {
+    vec4 color = texture(POST_COLOR_TEX, vs_uv);
+    frag_color = vec4(LinearToSRGB(color.rgb), color.a);
+  }`
+)
+
+// SRGBEncode is a PostEffect that converts the linear colors of the scene
+// to sRGB for the display. Scenes lit in linear space, with their color
+// textures loaded as sRGB, need it, or an sRGB framebuffer, to not look
+// too dark. It should be the last color changing effect in the chain and
+// isn't needed after an HDR resolve, which already gamma corrects.
+type SRGBEncode struct {
+	shader *fizzle.RenderShader
+}
+
+// NewSRGBEncode returns the sRGB conversion effect. It gets its graphics
+// resources when it's added to a PostProcessStack.
+func NewSRGBEncode() *SRGBEncode {
+	return new(SRGBEncode)
+}
+
+// Init compiles the conversion shader.
+func (se *SRGBEncode) Init(gfx graphics.GraphicsProvider, width, height int32) error {
+	if se.shader == nil {
+		shader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, SRGBEncodeFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the sRGB encode shader: %v", err)
+		}
+		se.shader = shader
+	}
+	return nil
+}
+
+// Destroy releases the shader.
+func (se *SRGBEncode) Destroy() {
+	if se.shader != nil {
+		se.shader.Destroy()
+		se.shader = nil
+	}
+}
+
+// Resize does nothing since the conversion has no textures of its own.
+func (se *SRGBEncode) Resize(width, height int32) error {
+	return nil
+}
+
+// Apply draws the frame's Color converted to sRGB.
+func (se *SRGBEncode) Apply(rend Renderer, frame *PostProcessFrame) {
+	frame.DrawQuad(rend, se.shader, nil)
+}
@@ -0,0 +1,468 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+	"math/rand"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// MaxSSAOSamples is the largest kernel the SSAO shader supports.
+	MaxSSAOSamples = 64
+
+	// ssaoNoiseSize is the width and height of the tiled rotation texture;
+	// the blur averages the same number of pixels in each direction
+	ssaoNoiseSize = 4
+)
+
+// SSAOQuality is a preset of the SSAO sample count, radius and bias.
+type SSAOQuality int
+
+const (
+	// SSAOLow takes 8 samples per pixel.
+	SSAOLow SSAOQuality = iota
+
+	// SSAOMedium takes 16 samples per pixel.
+	SSAOMedium
+
+	// SSAOHigh takes 32 samples per pixel.
+	SSAOHigh
+
+	// SSAOUltra takes MaxSSAOSamples samples per pixel.
+	SSAOUltra
+)
+
+var (
+	// SSAOFragShader330 is the GLSL fragment shader that computes the
+	// ambient occlusion of every pixel from the depth texture. The view
+	// space position and normal are reconstructed from the depth and the
+	// samples of a hemisphere kernel around the normal, rotated per pixel
+	// by the tiled noise texture, are tested against the depth.
+	SSAOFragShader330 = `#version 330
+  uniform sampler2D SSAO_DEPTH_TEX;
+  uniform sampler2D SSAO_NOISE_TEX;
+  uniform mat4 SSAO_PROJECTION;
+  uniform mat4 SSAO_INV_PROJECTION;
+  uniform vec3 SSAO_KERNEL[64];
+  uniform int SSAO_SAMPLES;
+  uniform float SSAO_RADIUS;
+  uniform float SSAO_BIAS;
+  uniform float SSAO_POWER;
+  uniform vec2 SSAO_NOISE_SCALE;
+  uniform vec2 SSAO_TEXEL_SIZE;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  vec3 ViewPosition(vec2 uv)
+  {
+    float depth = texture(SSAO_DEPTH_TEX, uv).r;
+    vec4 p = SSAO_INV_PROJECTION * vec4(uv * 2.0 - 1.0, depth * 2.0 - 1.0, 1.0);
+    return p.xyz / p.w;
+  }
+
+  // ShortestDelta returns the smaller of the differences to the neighbors
+  // on either side so that normals don't bend across depth edges.
+  vec3 ShortestDelta(vec3 p, vec2 uv, vec2 offset)
+  {
+    vec3 forward = ViewPosition(uv + offset) - p;
+    vec3 backward = p - ViewPosition(uv - offset);
+    return abs(forward.z) < abs(backward.z) ? forward : backward;
+  }
+
+  void main()
+  {
+    if (texture(SSAO_DEPTH_TEX, vs_uv).r >= 1.0) {
+      frag_color = vec4(1.0);
+      return;
+    }
+
+    vec3 p = ViewPosition(vs_uv);
+    vec3 dx = ShortestDelta(p, vs_uv, vec2(SSAO_TEXEL_SIZE.x, 0.0));
+    vec3 dy = ShortestDelta(p, vs_uv, vec2(0.0, SSAO_TEXEL_SIZE.y));
+    vec3 normal = normalize(cross(dx, dy));
+
+    vec3 random = vec3(texture(SSAO_NOISE_TEX, vs_uv * SSAO_NOISE_SCALE).xy, 0.0);
+    vec3 tangent = normalize(random - normal * dot(random, normal));
+    vec3 bitangent = cross(normal, tangent);
+    mat3 tbn = mat3(tangent, bitangent, normal);
+
+    float occlusion = 0.0;
+    for (int i = 0; i < SSAO_SAMPLES; i++) {
+      vec3 s = p + tbn * SSAO_KERNEL[i] * SSAO_RADIUS;
+      vec4 offset = SSAO_PROJECTION * vec4(s, 1.0);
+      vec2 uv = offset.xy / offset.w * 0.5 + 0.5;
+      float sampleDepth = ViewPosition(uv).z;
+      float rangeCheck = smoothstep(0.0, 1.0, SSAO_RADIUS / max(abs(p.z - sampleDepth), 0.0001));
+      occlusion += (sampleDepth >= s.z + SSAO_BIAS ? 1.0 : 0.0) * rangeCheck;
+    }
+
+    float ao = 1.0 - occlusion / float(max(SSAO_SAMPLES, 1));
+    frag_color = vec4(pow(ao, SSAO_POWER), 0.0, 0.0, 1.0);
+  }`
+
+	// SSAOBlurFragShader330 is the GLSL fragment shader that averages the
+	// ambient occlusion over the size of the noise texture to remove the
+	// pattern of the rotations.
+	SSAOBlurFragShader330 = `#version 330
+  uniform sampler2D SSAO_BLUR_TEX;
+  uniform vec2 SSAO_TEXEL_SIZE;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  void main()
+  {
+    float result = 0.0;
+    for (int x = -2; x < 2; x++) {
+      for (int y = -2; y < 2; y++) {
+        result += texture(SSAO_BLUR_TEX, vs_uv + vec2(float(x), float(y)) * SSAO_TEXEL_SIZE).r;
+      }
+    }
+    frag_color = vec4(result / 16.0, 0.0, 0.0, 1.0);
+  }`
+)
+
+// SSAO computes screen space ambient occlusion from a depth texture for
+// shaders to multiply into their ambient light. The occlusion is computed
+// into a single channel texture at the size of the screen and then blurred;
+// shaders sample it at gl_FragCoord.
+//
+// The forward renderer needs a depth prepass drawn between BeginDepth and
+// EndDepth, while the deferred renderer uses its G-buffer depth.
+type SSAO struct {
+	// Samples is the number of kernel samples per pixel, up to MaxSSAOSamples.
+	Samples int
+
+	// Radius is the world space radius of the hemisphere sampled.
+	Radius float32
+
+	// Bias is the depth difference needed before a sample occludes, which
+	// keeps flat surfaces from shadowing themselves.
+	Bias float32
+
+	// Power sharpens the occlusion; 1.0 leaves it linear.
+	Power float32
+
+	gfx    graphics.GraphicsProvider
+	width  int32
+	height int32
+
+	depthFBO graphics.Buffer
+	depthTex graphics.Texture
+	aoFBO    graphics.Buffer
+	aoTex    graphics.Texture
+	blurFBO  graphics.Buffer
+	blurTex  graphics.Texture
+	noiseTex graphics.Texture
+
+	// kernel holds the xyz of the MaxSSAOSamples kernel points
+	kernel []float32
+
+	shader     *fizzle.RenderShader
+	blurShader *fizzle.RenderShader
+	quad       *fizzle.Renderable
+
+	// source, projection and inverseProjection are the parameters of the
+	// pass being drawn for the uniform binders
+	source            graphics.Texture
+	projection        mgl.Mat4
+	inverseProjection mgl.Mat4
+}
+
+// NewSSAO compiles the SSAO shaders and creates its textures for a screen
+// of the given size at SSAOMedium quality.
+func NewSSAO(gfx graphics.GraphicsProvider, width, height int32) (*SSAO, error) {
+	shader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, SSAOFragShader330, nil)
+	if err != nil {
+		return nil, err
+	}
+	blurShader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, SSAOBlurFragShader330, nil)
+	if err != nil {
+		shader.Destroy()
+		return nil, err
+	}
+
+	ssao := new(SSAO)
+	ssao.gfx = gfx
+	ssao.shader = shader
+	ssao.blurShader = blurShader
+	ssao.quad = fizzle.CreatePlaneXY(-1.0, -1.0, 1.0, 1.0)
+	ssao.SetQuality(SSAOMedium)
+	ssao.Power = 1.0
+	ssao.createKernel()
+	ssao.createNoise()
+
+	err = ssao.Resize(width, height)
+	if err != nil {
+		ssao.Destroy()
+		return nil, err
+	}
+	return ssao, nil
+}
+
+// SetQuality changes the sample count, radius and bias to the preset.
+func (ssao *SSAO) SetQuality(q SSAOQuality) {
+	switch q {
+	case SSAOLow:
+		ssao.Samples, ssao.Radius, ssao.Bias = 8, 0.5, 0.05
+	case SSAOHigh:
+		ssao.Samples, ssao.Radius, ssao.Bias = 32, 0.5, 0.025
+	case SSAOUltra:
+		ssao.Samples, ssao.Radius, ssao.Bias = MaxSSAOSamples, 0.5, 0.02
+	default:
+		ssao.Samples, ssao.Radius, ssao.Bias = 16, 0.5, 0.025
+	}
+}
+
+// createKernel fills the kernel with points in the hemisphere around +Z
+// that cluster towards the center.
+func (ssao *SSAO) createKernel() {
+	rng := rand.New(rand.NewSource(1))
+	ssao.kernel = make([]float32, 0, MaxSSAOSamples*3)
+	for i := 0; i < MaxSSAOSamples; i++ {
+		v := mgl.Vec3{rng.Float32()*2.0 - 1.0, rng.Float32()*2.0 - 1.0, rng.Float32()}
+		v = v.Normalize().Mul(rng.Float32())
+		scale := float32(i) / MaxSSAOSamples
+		scale = 0.1 + 0.9*scale*scale
+		v = v.Mul(scale)
+		ssao.kernel = append(ssao.kernel, v[0], v[1], v[2])
+	}
+}
+
+// createNoise creates the tiled texture of random rotations around the
+// normal.
+func (ssao *SSAO) createNoise() {
+	rng := rand.New(rand.NewSource(2))
+	noise := make([]float32, ssaoNoiseSize*ssaoNoiseSize*3)
+	for i := 0; i < len(noise); i += 3 {
+		noise[i] = rng.Float32()*2.0 - 1.0
+		noise[i+1] = rng.Float32()*2.0 - 1.0
+	}
+
+	gfx := ssao.gfx
+	ssao.noiseTex = gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, ssao.noiseTex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGB16F, ssaoNoiseSize, ssaoNoiseSize, 0, graphics.RGB, graphics.FLOAT, gfx.Ptr(noise), len(noise)*4)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.REPEAT)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.REPEAT)
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+}
+
+// Destroy releases the shaders, quad and textures.
+func (ssao *SSAO) Destroy() {
+	ssao.destroyTargets()
+	if ssao.noiseTex != 0 {
+		ssao.gfx.DeleteTexture(ssao.noiseTex)
+		ssao.noiseTex = 0
+	}
+	ssao.shader.Destroy()
+	ssao.blurShader.Destroy()
+	ssao.quad.Destroy()
+}
+
+// destroyTargets releases the framebuffers and textures sized to the screen.
+func (ssao *SSAO) destroyTargets() {
+	gfx := ssao.gfx
+	for _, fbo := range []*graphics.Buffer{&ssao.depthFBO, &ssao.aoFBO, &ssao.blurFBO} {
+		if *fbo != 0 {
+			gfx.DeleteFramebuffer(*fbo)
+			*fbo = 0
+		}
+	}
+	for _, tex := range []*graphics.Texture{&ssao.depthTex, &ssao.aoTex, &ssao.blurTex} {
+		if *tex != 0 {
+			gfx.DeleteTexture(*tex)
+			*tex = 0
+		}
+	}
+}
+
+// Resize recreates the textures for a new screen size.
+func (ssao *SSAO) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid SSAO size %dx%d", width, height)
+	}
+	ssao.destroyTargets()
+	ssao.width = width
+	ssao.height = height
+
+	gfx := ssao.gfx
+	ssao.depthTex = ssao.createTexture(graphics.DEPTH_COMPONENT24, graphics.DEPTH_COMPONENT, graphics.UNSIGNED_INT)
+	ssao.depthFBO = gfx.GenFramebuffer()
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, ssao.depthFBO)
+	gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.DEPTH_ATTACHMENT, graphics.TEXTURE_2D, ssao.depthTex, 0)
+	gfx.DrawBuffers([]uint32{graphics.NONE})
+	err := CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "SSAO depth")
+
+	if err == nil {
+		ssao.aoTex = ssao.createTexture(graphics.R8, graphics.RED, graphics.UNSIGNED_BYTE)
+		ssao.aoFBO = gfx.GenFramebuffer()
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, ssao.aoFBO)
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, ssao.aoTex, 0)
+		err = CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "SSAO")
+	}
+	if err == nil {
+		ssao.blurTex = ssao.createTexture(graphics.R8, graphics.RED, graphics.UNSIGNED_BYTE)
+		ssao.blurFBO = gfx.GenFramebuffer()
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, ssao.blurFBO)
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, ssao.blurTex, 0)
+		err = CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "SSAO blur")
+	}
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	return err
+}
+
+// createTexture creates a texture of the screen size.
+func (ssao *SSAO) createTexture(internalFormat int32, format graphics.Enum, ty graphics.Enum) graphics.Texture {
+	gfx := ssao.gfx
+	tex := gfx.GenTexture()
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, internalFormat, ssao.width, ssao.height, 0, format, ty, nil, 0)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.NEAREST)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+	return tex
+}
+
+// GetTexture returns the blurred ambient occlusion texture.
+func (ssao *SSAO) GetTexture() graphics.Texture {
+	return ssao.blurTex
+}
+
+// GetDepthTexture returns the depth texture of the prepass drawn between
+// BeginDepth and EndDepth.
+func (ssao *SSAO) GetDepthTexture() graphics.Texture {
+	return ssao.depthTex
+}
+
+// BeginDepth binds and clears the depth prepass framebuffer. Opaque
+// Renderables drawn until EndDepth only write their depth.
+func (ssao *SSAO) BeginDepth() {
+	gfx := ssao.gfx
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, ssao.depthFBO)
+	gfx.Viewport(0, 0, ssao.width, ssao.height)
+	gfx.Enable(graphics.DEPTH_TEST)
+	gfx.DepthMask(true)
+	gfx.Clear(graphics.DEPTH_BUFFER_BIT)
+	gfx.ColorMask(false, false, false, false)
+}
+
+// EndDepth finishes the depth prepass and computes the ambient occlusion
+// from it with the perspective it was drawn with. The default framebuffer
+// is bound afterwards.
+func (ssao *SSAO) EndDepth(rend Renderer, perspective mgl.Mat4) {
+	ssao.gfx.ColorMask(true, true, true, true)
+	ssao.Compute(rend, ssao.depthTex, perspective)
+}
+
+// Compute draws the ambient occlusion of the depth texture, which was drawn
+// with the perspective, and blurs it. The default framebuffer is bound and
+// the viewport is set to the screen size afterwards.
+func (ssao *SSAO) Compute(rend Renderer, depthTex graphics.Texture, perspective mgl.Mat4) {
+	gfx := ssao.gfx
+	ident := mgl.Ident4()
+	ssao.projection = perspective
+	ssao.inverseProjection = perspective.Inv()
+
+	gfx.Disable(graphics.DEPTH_TEST)
+	gfx.DepthMask(false)
+	gfx.Disable(graphics.BLEND)
+	gfx.Viewport(0, 0, ssao.width, ssao.height)
+
+	ssao.source = depthTex
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, ssao.aoFBO)
+	rend.DrawRenderableWithShader(ssao.quad, ssao.shader, ssao.bindUniforms, ident, ident, nil)
+
+	ssao.source = ssao.aoTex
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, ssao.blurFBO)
+	rend.DrawRenderableWithShader(ssao.quad, ssao.blurShader, ssao.bindUniforms, ident, ident, nil)
+
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	gfx.DepthMask(true)
+	gfx.Enable(graphics.DEPTH_TEST)
+}
+
+// Bind binds the ambient occlusion texture to the AMBIENT_OCCLUSION_TEX
+// sampler and enables AMBIENT_OCCLUSION_ENABLED for the shader. Renderers
+// call it from their binders; a nil SSAO disables the occlusion.
+func (ssao *SSAO) Bind(gfx graphics.GraphicsProvider, shader *fizzle.RenderShader, texturesBound *int32) {
+	if loc := shader.GetUniformLocation("AMBIENT_OCCLUSION_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		if ssao != nil {
+			gfx.BindTexture(graphics.TEXTURE_2D, ssao.blurTex)
+		} else {
+			gfx.BindTexture(graphics.TEXTURE_2D, 0)
+		}
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("AMBIENT_OCCLUSION_ENABLED"); loc >= 0 {
+		if ssao != nil {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+}
+
+// bindUniforms binds the source texture and parameters for the SSAO and
+// blur shaders.
+func (ssao *SSAO) bindUniforms(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := ssao.gfx
+	for _, name := range []string{"SSAO_DEPTH_TEX", "SSAO_BLUR_TEX"} {
+		if loc := shader.GetUniformLocation(name); loc >= 0 {
+			gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+			gfx.BindTexture(graphics.TEXTURE_2D, ssao.source)
+			gfx.Uniform1i(loc, *texturesBound)
+			*texturesBound++
+		}
+	}
+	if loc := shader.GetUniformLocation("SSAO_NOISE_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, ssao.noiseTex)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("SSAO_PROJECTION"); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &ssao.projection)
+	}
+	if loc := shader.GetUniformLocation("SSAO_INV_PROJECTION"); loc >= 0 {
+		gfx.UniformMatrix4fv(loc, 1, false, &ssao.inverseProjection)
+	}
+	if loc := shader.GetUniformLocation("SSAO_KERNEL"); loc >= 0 {
+		gfx.Uniform3fv(loc, ssao.kernel)
+	}
+	if loc := shader.GetUniformLocation("SSAO_SAMPLES"); loc >= 0 {
+		samples := ssao.Samples
+		if samples > MaxSSAOSamples {
+			samples = MaxSSAOSamples
+		}
+		gfx.Uniform1i(loc, int32(samples))
+	}
+	if loc := shader.GetUniformLocation("SSAO_RADIUS"); loc >= 0 {
+		gfx.Uniform1f(loc, ssao.Radius)
+	}
+	if loc := shader.GetUniformLocation("SSAO_BIAS"); loc >= 0 {
+		gfx.Uniform1f(loc, ssao.Bias)
+	}
+	if loc := shader.GetUniformLocation("SSAO_POWER"); loc >= 0 {
+		gfx.Uniform1f(loc, ssao.Power)
+	}
+	if loc := shader.GetUniformLocation("SSAO_NOISE_SCALE"); loc >= 0 {
+		gfx.Uniform2f(loc, float32(ssao.width)/ssaoNoiseSize, float32(ssao.height)/ssaoNoiseSize)
+	}
+	if loc := shader.GetUniformLocation("SSAO_TEXEL_SIZE"); loc >= 0 {
+		gfx.Uniform2f(loc, 1.0/float32(ssao.width), 1.0/float32(ssao.height))
+	}
+}
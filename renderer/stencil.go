@@ -0,0 +1,123 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// StencilState is the stencil test configuration for a set of draws. The
+// framebuffer drawn to needs a stencil buffer, like the window's default
+// framebuffer or a RenderTarget created with RenderTargetDepthBuffer.
+type StencilState struct {
+	// Func is the comparison of Ref against the stored value, such as
+	// ALWAYS, EQUAL or NOTEQUAL.
+	Func graphics.Enum
+
+	// Ref is the reference value compared and written with REPLACE.
+	Ref int32
+
+	// ReadMask is ANDed with Ref and the stored value before comparing.
+	ReadMask uint32
+
+	// WriteMask controls which bits of the stencil buffer are written.
+	WriteMask uint32
+
+	// StencilFail, DepthFail and DepthPass are the actions taken when the
+	// stencil test fails, the depth test fails and both pass, such as
+	// KEEP, REPLACE or INCR.
+	StencilFail graphics.Enum
+	DepthFail   graphics.Enum
+	DepthPass   graphics.Enum
+}
+
+// StencilWrite returns the state that writes ref to every pixel drawn,
+// for drawing the shape of a mask.
+func StencilWrite(ref int32) StencilState {
+	return StencilState{
+		Func:        graphics.ALWAYS,
+		Ref:         ref,
+		ReadMask:    0xff,
+		WriteMask:   0xff,
+		StencilFail: graphics.KEEP,
+		DepthFail:   graphics.KEEP,
+		DepthPass:   graphics.REPLACE,
+	}
+}
+
+// StencilEqual returns the state that only draws where the stencil buffer
+// holds ref, leaving the stencil buffer unchanged.
+func StencilEqual(ref int32) StencilState {
+	return StencilState{
+		Func:        graphics.EQUAL,
+		Ref:         ref,
+		ReadMask:    0xff,
+		WriteMask:   0x00,
+		StencilFail: graphics.KEEP,
+		DepthFail:   graphics.KEEP,
+		DepthPass:   graphics.KEEP,
+	}
+}
+
+// StencilNotEqual returns the state that only draws where the stencil
+// buffer doesn't hold ref, leaving the stencil buffer unchanged.
+func StencilNotEqual(ref int32) StencilState {
+	state := StencilEqual(ref)
+	state.Func = graphics.NOTEQUAL
+	return state
+}
+
+// Apply enables the stencil test with the state.
+func (s StencilState) Apply(gfx graphics.GraphicsProvider) {
+	gfx.Enable(graphics.STENCIL_TEST)
+	gfx.StencilFunc(s.Func, s.Ref, s.ReadMask)
+	gfx.StencilMask(s.WriteMask)
+	gfx.StencilOp(s.StencilFail, s.DepthFail, s.DepthPass)
+}
+
+// ClearStencil clears the stencil buffer of the bound framebuffer to the
+// value.
+func ClearStencil(gfx graphics.GraphicsProvider, value int32) {
+	gfx.StencilMask(0xff)
+	gfx.ClearStencil(value)
+	gfx.Clear(graphics.STENCIL_BUFFER_BIT)
+}
+
+// DisableStencil turns the stencil test off and restores the write mask.
+func DisableStencil(gfx graphics.GraphicsProvider) {
+	gfx.StencilMask(0xff)
+	gfx.Disable(graphics.STENCIL_TEST)
+}
+
+// DrawStencilMasked limits drawing to the shape of a mask. The stencil
+// buffer is cleared, drawMask is called to draw the mask's shape, such as a
+// portal's doorway or a mirror's surface, into the stencil buffer only and
+// then drawMasked is called with drawing limited to inside of the mask, or
+// to outside of it if inside is false. The stencil test is off afterwards.
+//
+// For portals and mirrors drawMasked draws the view through them; the mask
+// is drawn with the depth test so that it's hidden by what's in front of
+// it. For x-ray views the occluders are drawn as the mask and drawMasked
+// draws the hidden objects with the depth test set to GREATER, so they
+// show up only where they're behind something.
+func DrawStencilMasked(rend Renderer, ref int32, inside bool, drawMask func(), drawMasked func()) {
+	gfx := rend.GetGraphics()
+	ClearStencil(gfx, 0)
+
+	// only the stencil is written for the mask's shape
+	gfx.ColorMask(false, false, false, false)
+	gfx.DepthMask(false)
+	StencilWrite(ref).Apply(gfx)
+	drawMask()
+	gfx.ColorMask(true, true, true, true)
+	gfx.DepthMask(true)
+
+	if inside {
+		StencilEqual(ref).Apply(gfx)
+	} else {
+		StencilNotEqual(ref).Apply(gfx)
+	}
+	drawMasked()
+	DisableStencil(gfx)
+}
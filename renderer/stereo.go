@@ -0,0 +1,182 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// Eye identifies one of the two views of stereo rendering.
+type Eye int
+
+const (
+	// LeftEye is the view of the left eye.
+	LeftEye Eye = iota
+
+	// RightEye is the view of the right eye.
+	RightEye
+)
+
+// EyeCount is the number of eyes of stereo rendering.
+const EyeCount = 2
+
+// StereoSubmitter hands the rendered eye textures to a VR runtime, such as
+// OpenVR's compositor or an OpenXR swapchain. Fizzle doesn't link against
+// either; applications implement this with the bindings of their choice.
+type StereoSubmitter interface {
+	// Submit is called with the color texture of each eye once both eyes
+	// are drawn. The texture stays owned by the StereoRig.
+	Submit(eye Eye, texture graphics.Texture, width, height int32) error
+}
+
+// StereoRig holds the per-eye render targets and matrices for drawing a
+// scene twice for a head mounted display. The eye projections and offsets
+// default to a symmetric frustum separated by the interpupillary distance;
+// VR runtimes report the real ones for their HMD, which should be set with
+// SetEyeProjection and SetEyeOffset.
+type StereoRig struct {
+	// Submitter, if set, is given the eye textures by Submit.
+	Submitter StereoSubmitter
+
+	gfx         graphics.GraphicsProvider
+	targets     [EyeCount]*RenderTarget
+	projections [EyeCount]mgl.Mat4
+	offsets     [EyeCount]mgl.Mat4
+}
+
+// NewStereoRig creates the eye render targets of the size per eye, usually
+// the recommended size reported by the VR runtime, and default eye
+// matrices for the interpupillary distance in world units and the field of
+// view in degrees.
+func NewStereoRig(gfx graphics.GraphicsProvider, width, height int32, ipd, fieldOfView, near, far float32) (*StereoRig, error) {
+	rig := new(StereoRig)
+	rig.gfx = gfx
+	for eye := range rig.targets {
+		rt, err := NewRenderTarget(gfx, width, height, graphics.RGBA8, RenderTargetDepthBuffer)
+		if err != nil {
+			rig.Destroy()
+			return nil, fmt.Errorf("failed to create the eye render target: %v", err)
+		}
+		rig.targets[eye] = rt
+	}
+
+	perspective := mgl.Perspective(mgl.DegToRad(fieldOfView), float32(width)/float32(height), near, far)
+	rig.SetEyeProjection(LeftEye, perspective)
+	rig.SetEyeProjection(RightEye, perspective)
+	rig.SetInterpupillaryDistance(ipd)
+	return rig, nil
+}
+
+// Destroy releases the eye render targets.
+func (rig *StereoRig) Destroy() {
+	for eye, rt := range rig.targets {
+		if rt != nil {
+			rt.Destroy()
+			rig.targets[eye] = nil
+		}
+	}
+}
+
+// Resize recreates the eye render targets for a new size per eye. The eye
+// projections aren't changed.
+func (rig *StereoRig) Resize(width, height int32) error {
+	for _, rt := range rig.targets {
+		if err := rt.Resize(width, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetInterpupillaryDistance places the eyes half of the distance to the
+// left and right of the head.
+func (rig *StereoRig) SetInterpupillaryDistance(ipd float32) {
+	rig.offsets[LeftEye] = mgl.Translate3D(-ipd*0.5, 0.0, 0.0)
+	rig.offsets[RightEye] = mgl.Translate3D(ipd*0.5, 0.0, 0.0)
+}
+
+// SetEyeProjection sets the projection of the eye, which for HMDs is
+// usually an asymmetric frustum reported by the VR runtime.
+func (rig *StereoRig) SetEyeProjection(eye Eye, projection mgl.Mat4) {
+	rig.projections[eye] = projection
+}
+
+// SetEyeOffset sets the transform from the eye to the head, as reported by
+// the VR runtime, in place of the interpupillary distance.
+func (rig *StereoRig) SetEyeOffset(eye Eye, eyeToHead mgl.Mat4) {
+	rig.offsets[eye] = eyeToHead
+}
+
+// GetEyeProjection returns the projection of the eye.
+func (rig *StereoRig) GetEyeProjection(eye Eye) mgl.Mat4 {
+	return rig.projections[eye]
+}
+
+// GetEyeView returns the view matrix of the eye for the view matrix of the
+// head, which is usually the tracked HMD pose combined with the player's
+// position.
+func (rig *StereoRig) GetEyeView(eye Eye, headView mgl.Mat4) mgl.Mat4 {
+	return rig.offsets[eye].Inv().Mul4(headView)
+}
+
+// GetTarget returns the render target of the eye.
+func (rig *StereoRig) GetTarget(eye Eye) *RenderTarget {
+	return rig.targets[eye]
+}
+
+// Submit hands the eye textures to the Submitter, if one is set.
+func (rig *StereoRig) Submit() error {
+	if rig.Submitter == nil {
+		return nil
+	}
+	for eye, rt := range rig.targets {
+		width, height := rt.GetSize()
+		if err := rig.Submitter.Submit(Eye(eye), rt.GetTexture(), width, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlitToWindow copies the eyes side by side into the window of the size,
+// as a mirror of what the HMD shows for spectators.
+func (rig *StereoRig) BlitToWindow(width, height int32) {
+	gfx := rig.gfx
+	half := width / 2
+	gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, 0)
+	for eye, rt := range rig.targets {
+		w, h := rt.GetSize()
+		x := int32(eye) * half
+		gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, rt.Framebuffer())
+		gfx.BlitFramebuffer(0, 0, w, h, x, 0, x+half, height, graphics.COLOR_BUFFER_BIT, graphics.LINEAR)
+	}
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+}
+
+// EyeCamera is the fizzle.Camera of an eye handed to the renderer while
+// drawing the eye.
+type EyeCamera struct {
+	view     mgl.Mat4
+	position mgl.Vec3
+}
+
+// GetViewMatrix returns the eye's view matrix.
+func (c *EyeCamera) GetViewMatrix() mgl.Mat4 {
+	return c.view
+}
+
+// GetPosition returns the eye's world position.
+func (c *EyeCamera) GetPosition() mgl.Vec3 {
+	return c.position
+}
+
+// NewEyeCamera returns a camera for the view matrix of an eye, such as one
+// returned by GetEyeView, for drawing the eye's view with shaders that use
+// the camera position.
+func NewEyeCamera(view mgl.Mat4) *EyeCamera {
+	return &EyeCamera{view: view, position: view.Inv().Col(3).Vec3()}
+}
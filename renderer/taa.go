@@ -0,0 +1,322 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+const (
+	// TAASampleCount is the number of jitter offsets the camera cycles
+	// through before repeating.
+	TAASampleCount = 8
+)
+
+var (
+	// TAAFragShader330 is the GLSL fragment shader that blends the jittered
+	// POST_COLOR_TEX with the accumulated history. The history is
+	// reprojected with the velocity in TAA_VELOCITY_TEX, the screen space
+	// motion of every pixel since the last frame, and clamped to the color
+	// range of the pixel's neighborhood so that disoccluded areas don't
+	// ghost. The blend is weighted by luminance to keep bright pixels from
+	// flickering.
+	TAAFragShader330 = `#version 330
+  uniform sampler2D POST_COLOR_TEX;
+  uniform vec2 POST_TEXEL_SIZE;
+  uniform sampler2D TAA_HISTORY_TEX;
+  uniform sampler2D TAA_VELOCITY_TEX;
+  uniform float TAA_FEEDBACK;
+  uniform int TAA_HISTORY_VALID;
+  in vec2 vs_uv;
+  out vec4 frag_color;
+
+  vec3 RGBToYCoCg(vec3 c)
+  {
+    return vec3(
+      0.25 * c.r + 0.5 * c.g + 0.25 * c.b,
+      0.5 * c.r - 0.5 * c.b,
+      -0.25 * c.r + 0.5 * c.g - 0.25 * c.b);
+  }
+
+  vec3 YCoCgToRGB(vec3 c)
+  {
+    return vec3(c.x + c.y - c.z, c.x + c.z, c.x - c.y - c.z);
+  }
+
+  // LongestVelocity returns the longest velocity of the pixel and its
+  // neighbors so that the edges of moving objects reproject with them.
+  vec2 LongestVelocity(vec2 uv)
+  {
+    vec2 best = texture(TAA_VELOCITY_TEX, uv).xy;
+    vec2 offsets[4] = vec2[](vec2(-1.0, 0.0), vec2(1.0, 0.0), vec2(0.0, -1.0), vec2(0.0, 1.0));
+    for (int i = 0; i < 4; i++) {
+      vec2 v = texture(TAA_VELOCITY_TEX, uv + offsets[i] * POST_TEXEL_SIZE).xy;
+      if (dot(v, v) > dot(best, best)) {
+        best = v;
+      }
+    }
+    return best;
+  }
+
+  void main()
+  {
+    vec4 current = texture(POST_COLOR_TEX, vs_uv);
+    vec2 historyUV = vs_uv - LongestVelocity(vs_uv);
+    if (TAA_HISTORY_VALID == 0 || any(lessThan(historyUV, vec2(0.0))) || any(greaterThan(historyUV, vec2(1.0)))) {
+      frag_color = current;
+      return;
+    }
+
+    // the color range of the neighborhood in YCoCg, which bounds the
+    // chroma tighter than RGB does
+    vec3 minColor = vec3(1e20);
+    vec3 maxColor = vec3(-1e20);
+    for (int x = -1; x <= 1; x++) {
+      for (int y = -1; y <= 1; y++) {
+        vec3 c = RGBToYCoCg(texture(POST_COLOR_TEX, vs_uv + vec2(float(x), float(y)) * POST_TEXEL_SIZE).rgb);
+        minColor = min(minColor, c);
+        maxColor = max(maxColor, c);
+      }
+    }
+
+    vec3 history = RGBToYCoCg(texture(TAA_HISTORY_TEX, historyUV).rgb);
+    history = YCoCgToRGB(clamp(history, minColor, maxColor));
+
+    float currentWeight = (1.0 - TAA_FEEDBACK) / (1.0 + RGBToYCoCg(current.rgb).x);
+    float historyWeight = TAA_FEEDBACK / (1.0 + RGBToYCoCg(history).x);
+    vec3 result = (current.rgb * currentWeight + history * historyWeight) / max(currentWeight + historyWeight, 0.0001);
+    frag_color = vec4(result, current.a);
+  }`
+)
+
+// TAA is a PostEffect for temporal anti-aliasing. The camera's projection
+// is offset by a different fraction of a pixel every frame with
+// JitterProjection, so that over several frames every pixel is sampled at
+// different points, and each frame is blended into a history of the
+// previous frames reprojected with the per-pixel velocity in Velocity.
+// It removes the shimmering of fine geometric detail that MSAA and FXAA
+// can't, at the cost of some softness in motion.
+//
+// The renderer drawing the scene has to jitter its projection, call
+// Advance once per frame and write the velocity texture; see the deferred
+// renderer's EnableTAA. It should be the first effect in the chain.
+type TAA struct {
+	// Feedback is how much of the history is kept every frame, from 0.0
+	// to 1.0. Higher values smooth more but take longer to converge.
+	Feedback float32
+
+	// Velocity is the texture holding the screen space motion, in texture
+	// coordinates, of every pixel since the last frame.
+	Velocity graphics.Texture
+
+	gfx    graphics.GraphicsProvider
+	width  int32
+	height int32
+
+	historyFBOs [2]graphics.Buffer
+	history     [2]graphics.Texture
+
+	// current is the history texture written this frame and historyValid
+	// is false until a frame has been written to the other one
+	current      int
+	historyValid bool
+
+	// sample is the index of the jitter offset of the current frame
+	sample int
+	jitter mgl.Vec2
+
+	shader *fizzle.RenderShader
+
+	// bindFn is the method value of the binder, saved so that it isn't
+	// allocated every frame
+	bindFn RenderBinder
+}
+
+// NewTAA returns a TAA effect with default parameters. It gets its
+// graphics resources when it's added to a PostProcessStack.
+func NewTAA() *TAA {
+	taa := new(TAA)
+	taa.Feedback = 0.9
+	taa.jitter = taaJitter(0)
+	return taa
+}
+
+// halton returns the index'th number of the Halton sequence of the base,
+// which spreads evenly over [0,1) without clumping.
+func halton(index, base int) float32 {
+	var result float32
+	f := float32(1.0)
+	for i := index; i > 0; i /= base {
+		f /= float32(base)
+		result += f * float32(i%base)
+	}
+	return result
+}
+
+// taaJitter returns the jitter offset of the sample in pixels, between
+// -0.5 and 0.5.
+func taaJitter(sample int) mgl.Vec2 {
+	// the sequence starts at 1 since halton(0) is 0 for every base
+	i := sample%TAASampleCount + 1
+	return mgl.Vec2{halton(i, 2) - 0.5, halton(i, 3) - 0.5}
+}
+
+// Init compiles the TAA shader and creates the history textures.
+func (taa *TAA) Init(gfx graphics.GraphicsProvider, width, height int32) error {
+	taa.gfx = gfx
+	taa.bindFn = taa.bind
+	if taa.shader == nil {
+		shader, err := fizzle.LoadShaderProgram(PostProcessVertShader330, TAAFragShader330, nil)
+		if err != nil {
+			return fmt.Errorf("failed to compile the TAA shader: %v", err)
+		}
+		taa.shader = shader
+	}
+	return taa.Resize(width, height)
+}
+
+// Destroy releases the shader and the history textures.
+func (taa *TAA) Destroy() {
+	taa.destroyHistory()
+	if taa.shader != nil {
+		taa.shader.Destroy()
+		taa.shader = nil
+	}
+}
+
+// destroyHistory releases the history framebuffers and textures.
+func (taa *TAA) destroyHistory() {
+	for i := range taa.history {
+		if taa.historyFBOs[i] != 0 {
+			taa.gfx.DeleteFramebuffer(taa.historyFBOs[i])
+			taa.historyFBOs[i] = 0
+		}
+		if taa.history[i] != 0 {
+			taa.gfx.DeleteTexture(taa.history[i])
+			taa.history[i] = 0
+		}
+	}
+}
+
+// Resize recreates the history textures for a new framebuffer size, which
+// also discards the history.
+func (taa *TAA) Resize(width, height int32) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid TAA size %dx%d", width, height)
+	}
+	taa.destroyHistory()
+	taa.width = width
+	taa.height = height
+	taa.historyValid = false
+
+	gfx := taa.gfx
+	var err error
+	for i := range taa.history {
+		taa.history[i] = gfx.GenTexture()
+		gfx.BindTexture(graphics.TEXTURE_2D, taa.history[i])
+		gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA16F, width, height, 0, graphics.RGBA, graphics.FLOAT, nil, 0)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.CLAMP_TO_EDGE)
+		gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.CLAMP_TO_EDGE)
+
+		taa.historyFBOs[i] = gfx.GenFramebuffer()
+		gfx.BindFramebuffer(graphics.FRAMEBUFFER, taa.historyFBOs[i])
+		gfx.FramebufferTexture2D(graphics.FRAMEBUFFER, graphics.COLOR_ATTACHMENT0, graphics.TEXTURE_2D, taa.history[i], 0)
+		err = CheckFramebuffer(gfx, graphics.FRAMEBUFFER, "TAA history")
+		if err != nil {
+			break
+		}
+	}
+
+	// a safety unbind
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, 0)
+	return err
+}
+
+// Reset discards the history so that the next frame starts fresh. It
+// should be called when the camera cuts to a different view.
+func (taa *TAA) Reset() {
+	taa.historyValid = false
+}
+
+// Advance moves on to the jitter offset of the next frame.
+func (taa *TAA) Advance() {
+	taa.sample = (taa.sample + 1) % TAASampleCount
+	taa.jitter = taaJitter(taa.sample)
+}
+
+// GetJitter returns the jitter offset of the current frame in pixels.
+func (taa *TAA) GetJitter() mgl.Vec2 {
+	return taa.jitter
+}
+
+// JitterProjection returns the projection matrix offset by the current
+// frame's jitter. The projection is returned unchanged until the TAA has
+// been added to a PostProcessStack and knows the framebuffer size.
+func (taa *TAA) JitterProjection(projection mgl.Mat4) mgl.Mat4 {
+	if taa.width <= 0 || taa.height <= 0 {
+		return projection
+	}
+	// offsetting after the projection shifts every point by the same
+	// fraction of a pixel for perspective and orthographic projections
+	x := 2.0 * taa.jitter[0] / float32(taa.width)
+	y := 2.0 * taa.jitter[1] / float32(taa.height)
+	return mgl.Translate3D(x, y, 0.0).Mul4(projection)
+}
+
+// GetHistoryTexture returns the texture holding the last resolved frame.
+func (taa *TAA) GetHistoryTexture() graphics.Texture {
+	return taa.history[taa.current]
+}
+
+// Apply blends the frame's Color into the history and writes the result
+// to the frame's Target.
+func (taa *TAA) Apply(rend Renderer, frame *PostProcessFrame) {
+	gfx := taa.gfx
+	taa.current = 1 - taa.current
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, taa.historyFBOs[taa.current])
+	gfx.Viewport(0, 0, taa.width, taa.height)
+	frame.DrawQuad(rend, taa.shader, taa.bindFn)
+	taa.historyValid = true
+
+	// the resolved frame is both the next frame's history and this
+	// frame's output
+	gfx.BindFramebuffer(graphics.READ_FRAMEBUFFER, taa.historyFBOs[taa.current])
+	gfx.BindFramebuffer(graphics.DRAW_FRAMEBUFFER, frame.Target)
+	gfx.BlitFramebuffer(0, 0, taa.width, taa.height, 0, 0, frame.Width, frame.Height, graphics.COLOR_BUFFER_BIT, graphics.NEAREST)
+	gfx.BindFramebuffer(graphics.FRAMEBUFFER, frame.Target)
+}
+
+// bind binds the history, velocity and feedback for the TAA shader.
+func (taa *TAA) bind(rend Renderer, r *fizzle.Renderable, shader *fizzle.RenderShader, texturesBound *int32) {
+	gfx := taa.gfx
+	if loc := shader.GetUniformLocation("TAA_HISTORY_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, taa.history[1-taa.current])
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("TAA_VELOCITY_TEX"); loc >= 0 {
+		gfx.ActiveTexture(graphics.Texture(graphics.TEXTURE0 + uint32(*texturesBound)))
+		gfx.BindTexture(graphics.TEXTURE_2D, taa.Velocity)
+		gfx.Uniform1i(loc, *texturesBound)
+		*texturesBound++
+	}
+	if loc := shader.GetUniformLocation("TAA_FEEDBACK"); loc >= 0 {
+		gfx.Uniform1f(loc, taa.Feedback)
+	}
+	if loc := shader.GetUniformLocation("TAA_HISTORY_VALID"); loc >= 0 {
+		if taa.historyValid {
+			gfx.Uniform1i(loc, 1)
+		} else {
+			gfx.Uniform1i(loc, 0)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"fmt"
+)
+
+// DefaultMaxFrameTime is the longest frame time, in seconds, that a
+// FixedTimestep will simulate in one frame by default. Longer frames
+// (e.g. after a breakpoint or window drag) are clamped to avoid the
+// simulation spiraling while it tries to catch up.
+const DefaultMaxFrameTime = 0.25
+
+// FrameTimer is implemented by renderers that track the time between frames.
+type FrameTimer interface {
+	// BeginFrame marks the start of a new frame and returns the time in seconds
+	// since the start of the previous frame.
+	BeginFrame() float64
+}
+
+// FixedTimestep runs a simulation at a fixed step size while the render rate
+// varies by accumulating frame time and running as many steps as fit.
+type FixedTimestep struct {
+	// Step is the simulation step size in seconds.
+	Step float64
+
+	// MaxFrameTime is the largest frame delta, in seconds, that will be
+	// accumulated in a single frame.
+	MaxFrameTime float64
+
+	accumulator float64
+	alpha       float64
+}
+
+// NewFixedTimestep creates a new FixedTimestep with the given step size in
+// seconds. An error is returned if the step isn't positive.
+func NewFixedTimestep(step float64) (*FixedTimestep, error) {
+	if step <= 0.0 {
+		return nil, fmt.Errorf("the fixed timestep must be positive, got %v", step)
+	}
+	ts := new(FixedTimestep)
+	ts.Step = step
+	ts.MaxFrameTime = DefaultMaxFrameTime
+	return ts, nil
+}
+
+// Advance adds the frame time to the accumulator and calls update once for
+// every whole step that fits. It returns the interpolation factor in [0, 1)
+// between the previous and current simulation states for rendering. If
+// Step isn't positive, nothing is simulated and 0 is returned.
+func (ts *FixedTimestep) Advance(frameDelta float64, update func(dt float64)) float64 {
+	if ts.Step <= 0.0 {
+		return 0.0
+	}
+	if frameDelta > ts.MaxFrameTime && ts.MaxFrameTime > 0.0 {
+		frameDelta = ts.MaxFrameTime
+	}
+	if frameDelta < 0.0 {
+		frameDelta = 0.0
+	}
+
+	ts.accumulator += frameDelta
+	for ts.accumulator >= ts.Step {
+		update(ts.Step)
+		ts.accumulator -= ts.Step
+	}
+
+	ts.alpha = ts.accumulator / ts.Step
+	return ts.alpha
+}
+
+// Alpha returns the interpolation factor calculated in the last call to Advance.
+func (ts *FixedTimestep) Alpha() float64 {
+	return ts.alpha
+}
+
+// Reset clears out any accumulated time.
+func (ts *FixedTimestep) Reset() {
+	ts.accumulator = 0.0
+	ts.alpha = 0.0
+}
+
+// RunFixedLoop runs a game loop until running returns false. Each frame the
+// frame time is taken from the timer, the simulation is advanced in fixed
+// steps with update, and render is called with the interpolation factor.
+func RunFixedLoop(timer FrameTimer, ts *FixedTimestep, running func() bool,
+	update func(dt float64), render func(alpha float64)) {
+	for running() {
+		frameDelta := timer.BeginFrame()
+		alpha := ts.Advance(frameDelta, update)
+		render(alpha)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"github.com/tbogdala/fizzle"
+	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+)
+
+// VertexArrayCache maps the VAO of a RenderableCore to a VAO created in the
+// current context. Buffers, textures and shaders are shared between contexts
+// created with a shared context, but container objects like VAOs and FBOs
+// are not, so a renderer drawing into a secondary window needs its own VAOs.
+// The vertex attributes are specified on every draw, so an empty VAO works.
+type VertexArrayCache struct {
+	gfx      graphics.GraphicsProvider
+	vaos     map[uint32]uint32
+	released []uint32
+}
+
+// VertexArrayMapper is implemented by renderers that can draw in a context
+// other than the one the Renderables were created in.
+type VertexArrayMapper interface {
+	// GetVertexArrays returns the cache to use for the renderer's context or
+	// nil if the Renderable VAOs can be bound directly.
+	GetVertexArrays() *VertexArrayCache
+}
+
+// NewVertexArrayCache creates a new empty cache. It should only be used
+// while the context it's for is current.
+func NewVertexArrayCache(gfx graphics.GraphicsProvider) *VertexArrayCache {
+	c := new(VertexArrayCache)
+	c.gfx = gfx
+	c.vaos = make(map[uint32]uint32)
+	return c
+}
+
+// Get returns the context's VAO for the shared VAO, creating it on first use.
+func (c *VertexArrayCache) Get(vao uint32) uint32 {
+	local, okay := c.vaos[vao]
+	if !okay {
+		local = c.gfx.GenVertexArray()
+		c.vaos[vao] = local
+	}
+	return local
+}
+
+// Release forgets the context's VAO for the shared VAO, such as when the
+// Renderable is destroyed, so that a recycled id gets a new VAO. Since the
+// context may not be current, the VAO is only deleted on the next call to
+// DeleteReleased.
+func (c *VertexArrayCache) Release(vao uint32) {
+	local, okay := c.vaos[vao]
+	if !okay {
+		return
+	}
+	c.released = append(c.released, local)
+	delete(c.vaos, vao)
+}
+
+// DeleteReleased deletes the VAOs forgotten by Release. It should only be
+// called while the context the cache is for is current.
+func (c *VertexArrayCache) DeleteReleased() {
+	for _, local := range c.released {
+		c.gfx.DeleteVertexArray(local)
+	}
+	c.released = c.released[:0]
+}
+
+// Destroy deletes all of the VAOs in the cache.
+func (c *VertexArrayCache) Destroy() {
+	c.DeleteReleased()
+	for _, local := range c.vaos {
+		c.gfx.DeleteVertexArray(local)
+	}
+	c.vaos = make(map[uint32]uint32)
+}
+
+// getVertexArray returns the VAO to bind for the Renderable with the renderer.
+func getVertexArray(renderer Renderer, r *fizzle.Renderable) uint32 {
+	if mapper, okay := renderer.(VertexArrayMapper); okay {
+		if cache := mapper.GetVertexArrays(); cache != nil {
+			return cache.Get(r.Core.Vao)
+		}
+	}
+	return r.Core.Vao
+}
@@ -0,0 +1,113 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// AllViewLayers is the layer mask that shows the renderables of every
+// view layer.
+const AllViewLayers uint32 = 0xFFFFFFFF
+
+// Viewport is a rectangle of the window or render target that a camera's
+// view of the scene is drawn into, for split screen and multi pane views.
+// The rectangle is a fraction of the resolution so that viewports follow
+// resolution changes without being updated.
+type Viewport struct {
+	// X, Y, Width and Height are the rectangle of the viewport as fractions
+	// of the resolution from 0.0 to 1.0, with the origin in the lower left.
+	X, Y, Width, Height float32
+
+	// Camera is the camera the scene is viewed with.
+	Camera fizzle.Camera
+
+	// FieldOfView is the vertical field of view in degrees; Near and Far
+	// are the clip planes of the perspective projection.
+	FieldOfView float32
+	Near        float32
+	Far         float32
+
+	// LayerMask has the bits of the view layers that are drawn in the
+	// viewport set; renderables whose ViewLayer bit isn't set are skipped.
+	LayerMask uint32
+
+	// Clear, if true, clears the viewport's rectangle to ClearColor before
+	// the scene is drawn into it.
+	Clear      bool
+	ClearColor mgl.Vec4
+}
+
+// NewViewport creates a viewport for the rectangle with the camera, a 60
+// degree field of view and all view layers shown.
+func NewViewport(x, y, width, height float32, camera fizzle.Camera) *Viewport {
+	vp := new(Viewport)
+	vp.X = x
+	vp.Y = y
+	vp.Width = width
+	vp.Height = height
+	vp.Camera = camera
+	vp.FieldOfView = 60.0
+	vp.Near = 0.1
+	vp.Far = 1000.0
+	vp.LayerMask = AllViewLayers
+	vp.Clear = true
+	vp.ClearColor = mgl.Vec4{0.0, 0.0, 0.0, 1.0}
+	return vp
+}
+
+// NewSplitScreenViewports creates the viewports for the number of players,
+// from one to four, with the cameras in order: two players are split into
+// a top and bottom half, three and four into the quarters of the screen.
+func NewSplitScreenViewports(cameras ...fizzle.Camera) []*Viewport {
+	var rects [][4]float32
+	switch len(cameras) {
+	case 0:
+		return nil
+	case 1:
+		rects = [][4]float32{{0.0, 0.0, 1.0, 1.0}}
+	case 2:
+		rects = [][4]float32{{0.0, 0.5, 1.0, 0.5}, {0.0, 0.0, 1.0, 0.5}}
+	default:
+		rects = [][4]float32{{0.0, 0.5, 0.5, 0.5}, {0.5, 0.5, 0.5, 0.5}, {0.0, 0.0, 0.5, 0.5}, {0.5, 0.0, 0.5, 0.5}}
+	}
+
+	viewports := make([]*Viewport, 0, len(rects))
+	for i, camera := range cameras {
+		if i >= len(rects) {
+			break
+		}
+		rect := rects[i]
+		viewports = append(viewports, NewViewport(rect[0], rect[1], rect[2], rect[3], camera))
+	}
+	return viewports
+}
+
+// Pixels returns the rectangle of the viewport in pixels of the resolution.
+// The size is at least one pixel.
+func (vp *Viewport) Pixels(width, height int32) (x, y, w, h int32) {
+	x = int32(vp.X * float32(width))
+	y = int32(vp.Y * float32(height))
+	w = int32((vp.X+vp.Width)*float32(width)) - x
+	h = int32((vp.Y+vp.Height)*float32(height)) - y
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return x, y, w, h
+}
+
+// Perspective returns the projection of the viewport for its size in
+// pixels, so that the view isn't stretched.
+func (vp *Viewport) Perspective(width, height int32) mgl.Mat4 {
+	return mgl.Perspective(mgl.DegToRad(vp.FieldOfView), float32(width)/float32(height), vp.Near, vp.Far)
+}
+
+// Shows returns true if the renderable's view layer is in the layer mask.
+func (vp *Viewport) Shows(r *fizzle.Renderable) bool {
+	return vp.LayerMask&(1<<(r.ViewLayer&31)) != 0
+}
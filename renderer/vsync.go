@@ -0,0 +1,63 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package renderer
+
+import (
+	"time"
+)
+
+// SwapInterval is the number of screen refreshes to wait for before
+// swapping the buffers.
+type SwapInterval int
+
+const (
+	// SwapIntervalOff disables vsync.
+	SwapIntervalOff SwapInterval = 0
+
+	// SwapIntervalOn waits for one screen refresh before swapping.
+	SwapIntervalOn SwapInterval = 1
+
+	// SwapIntervalAdaptive waits for the refresh unless the frame is late,
+	// in which case it swaps immediately. This requires driver support for
+	// swap control tear and otherwise behaves like SwapIntervalOn.
+	SwapIntervalAdaptive SwapInterval = -1
+)
+
+// SwapIntervalFunc is the type of the function that applies the swap interval
+// to the current context, such as glfw.SwapInterval.
+type SwapIntervalFunc func(interval int)
+
+// FrameLimiter caps the frame rate by sleeping off any remaining frame time,
+// which is useful for menus and other screens that don't need to render as
+// fast as possible.
+type FrameLimiter struct {
+	// MaxFPS is the target maximum frames per second; 0 disables the limiter.
+	MaxFPS float64
+
+	lastFrame time.Time
+}
+
+// NewFrameLimiter creates a new FrameLimiter for the target frames per second.
+func NewFrameLimiter(maxFPS float64) *FrameLimiter {
+	fl := new(FrameLimiter)
+	fl.MaxFPS = maxFPS
+	return fl
+}
+
+// Wait sleeps until the target frame time has passed since the last call to Wait.
+func (fl *FrameLimiter) Wait() {
+	if fl.MaxFPS <= 0.0 {
+		fl.lastFrame = time.Now()
+		return
+	}
+
+	frameTime := time.Duration(float64(time.Second) / fl.MaxFPS)
+	if !fl.lastFrame.IsZero() {
+		elapsed := time.Since(fl.lastFrame)
+		if elapsed < frameTime {
+			time.Sleep(frameTime - elapsed)
+		}
+	}
+	fl.lastFrame = time.Now()
+}
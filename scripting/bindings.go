@@ -0,0 +1,214 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package scripting
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/input"
+	"github.com/tbogdala/fizzle/renderer/forward"
+)
+
+// Vec3 is the vector type returned to scripts as {x, y, z}.
+type Vec3 struct {
+	X float32
+	Y float32
+	Z float32
+}
+
+// newVec3 converts the mgl vector to a Vec3.
+func newVec3(v mgl.Vec3) Vec3 {
+	return Vec3{v[0], v[1], v[2]}
+}
+
+// sceneBinding is the scene object exposed to scripts.
+type sceneBinding struct {
+	renderables map[string]*Renderable
+}
+
+// Find returns the named renderable or nil if it wasn't added.
+func (s *sceneBinding) Find(name string) *Renderable {
+	return s.renderables[name]
+}
+
+// Names returns the names of all of the renderables added.
+func (s *sceneBinding) Names() []string {
+	names := make([]string, 0, len(s.renderables))
+	for name := range s.renderables {
+		names = append(names, name)
+	}
+	return names
+}
+
+// lightsBinding is the lights object exposed to scripts.
+type lightsBinding struct {
+	lights map[string]*Light
+}
+
+// Find returns the named light or nil if it wasn't added.
+func (l *lightsBinding) Find(name string) *Light {
+	return l.lights[name]
+}
+
+// inputBinding is the input object exposed to scripts.
+type inputBinding struct {
+	actions *input.ActionMap
+}
+
+// IsDown returns true if the action is held down.
+func (i *inputBinding) IsDown(name string) bool {
+	return i.actions != nil && i.actions.IsDown(name)
+}
+
+// Pressed returns true if the action went down this frame.
+func (i *inputBinding) Pressed(name string) bool {
+	return i.actions != nil && i.actions.Pressed(name)
+}
+
+// Released returns true if the action went up this frame.
+func (i *inputBinding) Released(name string) bool {
+	return i.actions != nil && i.actions.Released(name)
+}
+
+// Axis returns the value of the named axis.
+func (i *inputBinding) Axis(name string) float32 {
+	if i.actions == nil {
+		return 0.0
+	}
+	return i.actions.Axis(name)
+}
+
+// Renderable is the script binding for a fizzle.Renderable.
+type Renderable struct {
+	r *fizzle.Renderable
+}
+
+// GetLocation returns the location of the renderable.
+func (sr *Renderable) GetLocation() Vec3 {
+	return newVec3(sr.r.Location)
+}
+
+// SetLocation moves the renderable to the location.
+func (sr *Renderable) SetLocation(x, y, z float32) {
+	sr.r.Location = mgl.Vec3{x, y, z}
+}
+
+// Translate moves the renderable by the offset.
+func (sr *Renderable) Translate(x, y, z float32) {
+	sr.r.Location = sr.r.Location.Add(mgl.Vec3{x, y, z})
+}
+
+// GetScale returns the scale of the renderable.
+func (sr *Renderable) GetScale() Vec3 {
+	return newVec3(sr.r.Scale)
+}
+
+// SetScale sets the scale of the renderable.
+func (sr *Renderable) SetScale(x, y, z float32) {
+	sr.r.Scale = mgl.Vec3{x, y, z}
+}
+
+// Rotate rotates the renderable's local rotation around the axis by the
+// angle in radians.
+func (sr *Renderable) Rotate(x, y, z float32, angle float32) {
+	axis := mgl.Vec3{x, y, z}
+	if axis.Len() == 0.0 {
+		return
+	}
+	sr.r.LocalRotation = sr.r.LocalRotation.Mul(mgl.QuatRotate(angle, axis.Normalize()))
+}
+
+// SetRotation sets the renderable's rotation to the angle in radians around the axis.
+func (sr *Renderable) SetRotation(x, y, z float32, angle float32) {
+	axis := mgl.Vec3{x, y, z}
+	if axis.Len() == 0.0 {
+		return
+	}
+	sr.r.Rotation = mgl.QuatRotate(angle, axis.Normalize())
+}
+
+// IsVisible returns true if the renderable is drawn.
+func (sr *Renderable) IsVisible() bool {
+	return sr.r.IsVisible
+}
+
+// SetVisible sets whether or not the renderable is drawn.
+func (sr *Renderable) SetVisible(visible bool) {
+	sr.r.IsVisible = visible
+}
+
+// SetDiffuseColor sets the diffuse material color of the renderable. This
+// affects all renderables sharing the same core.
+func (sr *Renderable) SetDiffuseColor(r, g, b, a float32) {
+	sr.r.Core.DiffuseColor = mgl.Vec4{r, g, b, a}
+}
+
+// Light is the script binding for a forward.Light.
+type Light struct {
+	l *forward.Light
+}
+
+// GetPosition returns the position of the light.
+func (sl *Light) GetPosition() Vec3 {
+	return newVec3(sl.l.Position)
+}
+
+// SetPosition moves the light to the position.
+func (sl *Light) SetPosition(x, y, z float32) {
+	sl.l.Position = mgl.Vec3{x, y, z}
+}
+
+// GetDirection returns the direction of the light.
+func (sl *Light) GetDirection() Vec3 {
+	return newVec3(sl.l.Direction)
+}
+
+// SetDirection points the light in the direction.
+func (sl *Light) SetDirection(x, y, z float32) {
+	sl.l.Direction = mgl.Vec3{x, y, z}
+}
+
+// SetDiffuseColor sets the color of the light.
+func (sl *Light) SetDiffuseColor(r, g, b, a float32) {
+	sl.l.DiffuseColor = mgl.Vec4{r, g, b, a}
+}
+
+// SetIntensity sets the diffuse, specular and ambient intensities of the light.
+func (sl *Light) SetIntensity(diffuse, specular, ambient float32) {
+	sl.l.DiffuseIntensity = diffuse
+	sl.l.SpecularIntensity = specular
+	sl.l.AmbientIntensity = ambient
+}
+
+// Camera is the script binding for a fizzle.Camera.
+type Camera struct {
+	c fizzle.Camera
+}
+
+// GetPosition returns the position of the camera.
+func (sc *Camera) GetPosition() Vec3 {
+	return newVec3(sc.c.GetPosition())
+}
+
+// SetPosition moves the camera if it's a YawPitchCamera or sets the
+// target of an OrbitCamera.
+func (sc *Camera) SetPosition(x, y, z float32) {
+	switch c := sc.c.(type) {
+	case *fizzle.YawPitchCamera:
+		c.SetPosition(x, y, z)
+	case *fizzle.OrbitCamera:
+		c.SetTarget(mgl.Vec3{x, y, z})
+	}
+}
+
+// LookAt turns a YawPitchCamera to look at the target or sets the target
+// of an OrbitCamera.
+func (sc *Camera) LookAt(x, y, z float32) {
+	switch c := sc.c.(type) {
+	case *fizzle.YawPitchCamera:
+		c.LookAtDirect(mgl.Vec3{x, y, z})
+	case *fizzle.OrbitCamera:
+		c.SetTarget(mgl.Vec3{x, y, z})
+	}
+}
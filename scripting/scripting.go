@@ -0,0 +1,134 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The scripting module embeds a JavaScript interpreter (goja) so that gameplay
+logic and level scripts can be changed without recompiling the application.
+
+The application registers the renderables, lights, camera and input actions
+that scripts may use and then calls Update once a frame, which calls the
+script's global update(dt, time) function if one was defined. The objects
+are exposed to the scripts as:
+
+	scene.find(name)     returns the named renderable or null
+	lights.find(name)    returns the named light or null
+	camera               the camera set with SetCamera
+	input.isDown(action), input.pressed(action), input.released(action),
+	input.axis(name)     query the action map set with SetActions
+	log(message)         logs the message with groggy
+
+Go method names are exposed with a lower case first letter, so a
+renderable's SetLocation is called as setLocation from scripts.
+
+*/
+
+package scripting
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/dop251/goja"
+	"github.com/tbogdala/fizzle"
+	"github.com/tbogdala/fizzle/input"
+	"github.com/tbogdala/fizzle/renderer/forward"
+	"github.com/tbogdala/groggy"
+)
+
+// Engine is a script interpreter with the fizzle bindings set up.
+type Engine struct {
+	vm     *goja.Runtime
+	scene  *sceneBinding
+	lights *lightsBinding
+	input  *inputBinding
+}
+
+// NewEngine creates a new script engine with empty scene and lights.
+func NewEngine() *Engine {
+	e := new(Engine)
+	e.vm = goja.New()
+	e.vm.SetFieldNameMapper(goja.UncapFieldNameMapper())
+
+	e.scene = &sceneBinding{renderables: make(map[string]*Renderable)}
+	e.lights = &lightsBinding{lights: make(map[string]*Light)}
+	e.input = new(inputBinding)
+
+	e.vm.Set("scene", e.scene)
+	e.vm.Set("lights", e.lights)
+	e.vm.Set("input", e.input)
+	e.vm.Set("camera", nil)
+	e.vm.Set("log", func(msg string) {
+		groggy.Logsf("SCRIPT", "%s", msg)
+	})
+	return e
+}
+
+// AddRenderable makes the renderable available to scripts through scene.find.
+func (e *Engine) AddRenderable(name string, r *fizzle.Renderable) {
+	e.scene.renderables[name] = &Renderable{r: r}
+}
+
+// RemoveRenderable removes the named renderable from the scene binding.
+func (e *Engine) RemoveRenderable(name string) {
+	delete(e.scene.renderables, name)
+}
+
+// AddLight makes the light available to scripts through lights.find.
+func (e *Engine) AddLight(name string, l *forward.Light) {
+	e.lights.lights[name] = &Light{l: l}
+}
+
+// RemoveLight removes the named light from the lights binding.
+func (e *Engine) RemoveLight(name string) {
+	delete(e.lights.lights, name)
+}
+
+// SetCamera sets the camera exposed to scripts as camera.
+func (e *Engine) SetCamera(c fizzle.Camera) {
+	e.vm.Set("camera", &Camera{c: c})
+}
+
+// SetActions sets the action map queried by the input binding.
+func (e *Engine) SetActions(am *input.ActionMap) {
+	e.input.actions = am
+}
+
+// Set exposes any other Go value to scripts under the global name.
+func (e *Engine) Set(name string, value interface{}) {
+	e.vm.Set(name, value)
+}
+
+// RunString runs the script source; the name is used in error messages.
+func (e *Engine) RunString(name string, source string) error {
+	_, err := e.vm.RunScript(name, source)
+	if err != nil {
+		return fmt.Errorf("failed to run the script %s: %v", name, err)
+	}
+	return nil
+}
+
+// RunFile loads and runs the script file. Running a file again after
+// editing it replaces the functions it defines, including update.
+func (e *Engine) RunFile(path string) error {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the script file %s: %v", path, err)
+	}
+	return e.RunString(path, string(source))
+}
+
+// Update calls the script's global update function, if defined, with the
+// clock's scaled delta and time in seconds. It should be called once a frame.
+func (e *Engine) Update(clock *fizzle.Clock) error {
+	update, okay := goja.AssertFunction(e.vm.Get("update"))
+	if !okay {
+		return nil
+	}
+
+	_, err := update(goja.Undefined(), e.vm.ToValue(clock.Delta()), e.vm.ToValue(clock.Time()))
+	if err != nil {
+		return fmt.Errorf("script update failed: %v", err)
+	}
+	return nil
+}
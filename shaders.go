@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 
+	mgl "github.com/go-gl/mathgl/mgl32"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
 	"github.com/tbogdala/groggy"
 )
@@ -18,6 +19,23 @@ type RenderShader struct {
 	Prog      graphics.Program
 	uniCache  map[string]int32
 	attrCache map[string]int32
+
+	// source is what the program was built from, kept for Reload
+	source shaderSource
+
+	// uniValues are the values set with SetUniform, set again on Reload
+	uniValues map[string]interface{}
+}
+
+// shaderSource is the GLSL and the files a RenderShader was loaded from.
+type shaderSource struct {
+	vert    string
+	geom    string
+	frag    string
+	prelink PreLinkBinder
+
+	// baseFilename is set for shaders loaded with LoadShaderProgramFromFiles
+	baseFilename string
 }
 
 // NewRenderShader creates a new RenderShader object with the OpenGL shader specified.
@@ -46,6 +64,46 @@ func (rs *RenderShader) GetUniformLocation(name string) int32 {
 	return ul
 }
 
+// SetUniform makes the shader the current program and sets the uniform to
+// the value, which can be a float32, int32, mgl.Vec2, mgl.Vec3, mgl.Vec4
+// or mgl.Mat4. The value is remembered and set again after the shader is
+// reloaded, so uniforms that are only set once, like at startup, survive
+// a reload. An error is returned for other types.
+func (rs *RenderShader) SetUniform(name string, value interface{}) error {
+	gfx.UseProgram(rs.Prog)
+	if !rs.applyUniform(name, value) {
+		return fmt.Errorf("Unsupported type %T for the shader uniform %s", value, name)
+	}
+	if rs.uniValues == nil {
+		rs.uniValues = make(map[string]interface{})
+	}
+	rs.uniValues[name] = value
+	return nil
+}
+
+// applyUniform sets the uniform on the current program and returns false
+// if the type of the value isn't supported.
+func (rs *RenderShader) applyUniform(name string, value interface{}) bool {
+	loc := rs.GetUniformLocation(name)
+	switch v := value.(type) {
+	case float32:
+		gfx.Uniform1f(loc, v)
+	case int32:
+		gfx.Uniform1i(loc, v)
+	case mgl.Vec2:
+		gfx.Uniform2f(loc, v[0], v[1])
+	case mgl.Vec3:
+		gfx.Uniform3f(loc, v[0], v[1], v[2])
+	case mgl.Vec4:
+		gfx.Uniform4f(loc, v[0], v[1], v[2], v[3])
+	case mgl.Mat4:
+		gfx.UniformMatrix4fv(loc, 1, false, &v)
+	default:
+		return false
+	}
+	return true
+}
+
 // AssertUniformsExist attempts to get uniforms for the names passed in and returns
 // an error value if a name doesn't exist.
 func (rs *RenderShader) AssertUniformsExist(names ...string) error {
@@ -98,6 +156,64 @@ func (rs *RenderShader) Destroy() {
 	gfx.DeleteProgram(rs.Prog)
 }
 
+// GetSourceFiles returns the files the shader was loaded from, or nil if
+// it wasn't loaded with LoadShaderProgramFromFiles.
+func (rs *RenderShader) GetSourceFiles() []string {
+	if rs.source.baseFilename == "" {
+		return nil
+	}
+	return []string{rs.source.baseFilename + ".vs", rs.source.baseFilename + ".fs"}
+}
+
+// Reload recompiles the shader, reading its source files again if it was
+// loaded with LoadShaderProgramFromFiles, and swaps in the new program.
+// The cached uniform and attribute locations are cleared, so they're looked
+// up again the next time the renderers bind them, and the uniforms set
+// with SetUniform are set again on the new program. If the new program
+// fails to build, the error is returned and the old program is kept.
+func (rs *RenderShader) Reload() error {
+	src := rs.source
+	if src.baseFilename != "" {
+		vsBytes, err := ioutil.ReadFile(src.baseFilename + ".vs")
+		if err != nil {
+			return fmt.Errorf("Failed to read the vertex shader \"%s\".\n%v\n", src.baseFilename+".vs", err)
+		}
+		fsBytes, err := ioutil.ReadFile(src.baseFilename + ".fs")
+		if err != nil {
+			return fmt.Errorf("Failed to read the fragment shader \"%s\".\n%v\n", src.baseFilename+".fs", err)
+		}
+		src.vert = string(vsBytes)
+		src.frag = string(fsBytes)
+	}
+	return rs.ReloadSource(src.vert, src.geom, src.frag)
+}
+
+// ReloadSource works like Reload but builds the program from new GLSL,
+// such as an edited copy of a shader compiled into the application. The
+// geometry shader is optional.
+func (rs *RenderShader) ReloadSource(vertShader, geomShader, fragShader string) error {
+	fresh, err := LoadShaderProgramWithGeometry(vertShader, geomShader, fragShader, rs.source.prelink)
+	if err != nil {
+		return err
+	}
+
+	gfx.DeleteProgram(rs.Prog)
+	rs.Prog = fresh.Prog
+	rs.uniCache = fresh.uniCache
+	rs.attrCache = fresh.attrCache
+	rs.source.vert = vertShader
+	rs.source.geom = geomShader
+	rs.source.frag = fragShader
+
+	if len(rs.uniValues) > 0 {
+		gfx.UseProgram(rs.Prog)
+		for name, value := range rs.uniValues {
+			rs.applyUniform(name, value)
+		}
+	}
+	return nil
+}
+
 // PreLinkBinder is a prototype for a function to be called before a shader program is linked
 type PreLinkBinder func(p graphics.Program)
 
@@ -105,63 +221,109 @@ type PreLinkBinder func(p graphics.Program)
 func LoadShaderProgramFromFiles(baseFilename string, prelink PreLinkBinder) (*RenderShader, error) {
 	vsBytes, err := ioutil.ReadFile(baseFilename + ".vs")
 	if err != nil {
-		fmt.Errorf("Failed to read the vertex shader \"%s\".\n%v\n", baseFilename+".vs", err)
+		return nil, fmt.Errorf("Failed to read the vertex shader \"%s\".\n%v\n", baseFilename+".vs", err)
 	}
 	vsBuffer := bytes.NewBuffer(vsBytes)
 
 	fsBytes, err := ioutil.ReadFile(baseFilename + ".fs")
 	if err != nil {
-		fmt.Errorf("Failed to read the fragment shader \"%s\".\n%v\n", baseFilename+".fs", err)
+		return nil, fmt.Errorf("Failed to read the fragment shader \"%s\".\n%v\n", baseFilename+".fs", err)
 	}
 	fsBuffer := bytes.NewBuffer(fsBytes)
 
 	groggy.Logsf("DEBUG", "Compiling shader: %s.", baseFilename)
-	return LoadShaderProgram(vsBuffer.String(), fsBuffer.String(), prelink)
+	rs, err := LoadShaderProgram(vsBuffer.String(), fsBuffer.String(), prelink)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the shader \"%s\".\n%v", baseFilename, err)
+	}
+	rs.source.baseFilename = baseFilename
+	return rs, nil
 }
 
 // LoadShaderProgram loads shader objects, compiles and then attaches them to a new program
 func LoadShaderProgram(vertShader, fragShader string, prelink PreLinkBinder) (*RenderShader, error) {
-	// create the program
-	prog := gfx.CreateProgram()
+	return LoadShaderProgramWithGeometry(vertShader, "", fragShader, prelink)
+}
+
+// LoadShaderProgramWithGeometry works like LoadShaderProgram but also
+// attaches a geometry shader if geomShader isn't empty.
+// NOTE: geometry shaders are not supported by OpenGL ES 2.
+func LoadShaderProgramWithGeometry(vertShader, geomShader, fragShader string, prelink PreLinkBinder) (*RenderShader, error) {
+	if len(vertShader) == 0 || len(fragShader) == 0 {
+		return nil, fmt.Errorf("Empty shader source supplied for the program")
+	}
 
 	// create the vertex shader
-	var status int32
-	vs := gfx.CreateShader(graphics.VERTEX_SHADER)
-	gfx.ShaderSource(vs, vertShader)
-	gfx.CompileShader(vs)
-	gfx.GetShaderiv(vs, graphics.COMPILE_STATUS, &status)
-	if status == graphics.FALSE {
-		log := gfx.GetShaderInfoLog(vs)
-		return nil, fmt.Errorf("Failed to compile the vertex shader:\n%s", log)
+	vs, err := compileShader(graphics.VERTEX_SHADER, vertShader)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile the vertex shader:\n%v", err)
 	}
 	defer gfx.DeleteShader(vs)
 
 	// create the fragment shader
-	fs := gfx.CreateShader(graphics.FRAGMENT_SHADER)
-	gfx.ShaderSource(fs, fragShader)
-	gfx.CompileShader(fs)
-	gfx.GetShaderiv(fs, graphics.COMPILE_STATUS, &status)
-	if status == graphics.FALSE {
-		log := gfx.GetShaderInfoLog(fs)
-		return nil, fmt.Errorf("Failed to compile the fragment shader:\n%s", log)
+	fs, err := compileShader(graphics.FRAGMENT_SHADER, fragShader)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to compile the fragment shader:\n%v", err)
 	}
 	defer gfx.DeleteShader(fs)
 
+	// create the optional geometry shader
+	var gs graphics.Shader
+	if len(geomShader) > 0 {
+		gs, err = compileShader(graphics.GEOMETRY_SHADER, geomShader)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to compile the geometry shader:\n%v", err)
+		}
+		defer gfx.DeleteShader(gs)
+	}
+
+	// create the program
+	prog := gfx.CreateProgram()
+	if prog == 0 {
+		return nil, fmt.Errorf("Failed to create the shader program: %v", CheckForError("creating a shader program"))
+	}
+
 	// call the prelinker if supplied
 	if prelink != nil {
 		prelink(prog)
 	}
 
 	// attach the shaders to the program and link
+	var status int32
 	gfx.AttachShader(prog, vs)
 	gfx.AttachShader(prog, fs)
+	if gs != 0 {
+		gfx.AttachShader(prog, gs)
+	}
 	gfx.LinkProgram(prog)
 	gfx.GetProgramiv(prog, graphics.LINK_STATUS, &status)
 	if status == graphics.FALSE {
 		log := gfx.GetProgramInfoLog(prog)
+		gfx.DeleteProgram(prog)
 		return nil, fmt.Errorf("Failed to link the program!\n%s", log)
 	}
 
 	rs := NewRenderShader(prog)
+	rs.source = shaderSource{vert: vertShader, geom: geomShader, frag: fragShader, prelink: prelink}
 	return rs, nil
 }
+
+// compileShader creates and compiles a shader object, deleting it again if
+// compilation fails.
+func compileShader(shaderType graphics.Enum, source string) (graphics.Shader, error) {
+	s := gfx.CreateShader(shaderType)
+	if s == 0 {
+		return 0, fmt.Errorf("failed to create the shader object: %v", CheckForError("creating a shader"))
+	}
+
+	var status int32
+	gfx.ShaderSource(s, source)
+	gfx.CompileShader(s)
+	gfx.GetShaderiv(s, graphics.COMPILE_STATUS, &status)
+	if status == graphics.FALSE {
+		log := gfx.GetShaderInfoLog(s)
+		gfx.DeleteShader(s)
+		return 0, fmt.Errorf("%s", log)
+	}
+	return s, nil
+}
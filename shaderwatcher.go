@@ -0,0 +1,125 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package fizzle
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tbogdala/groggy"
+)
+
+// defaultShaderWatchInterval is how often, in seconds, watched shader files
+// are checked for changes by default.
+const defaultShaderWatchInterval = 0.25
+
+// ShaderWatcher is a development aid that reloads shaders when their
+// source files change on disk, so that GLSL can be tweaked while the
+// application runs. A shader that fails to build keeps drawing with its
+// old program until the files are fixed.
+type ShaderWatcher struct {
+	// Interval is how often, in seconds, the files are checked by Update.
+	Interval float32
+
+	// OnReload, if set, is called after a watched shader was rebuilt, with
+	// the error if it failed to build. The uniforms set with SetUniform are
+	// already restored by then; it's for any other setup of the program.
+	OnReload func(rs *RenderShader, err error)
+
+	watched []watchedShader
+	timer   float32
+}
+
+// watchedShader is a shader and the newest modification time of its files.
+type watchedShader struct {
+	shader  *RenderShader
+	modTime time.Time
+}
+
+// NewShaderWatcher creates a new watcher that checks its shaders four
+// times a second.
+func NewShaderWatcher() *ShaderWatcher {
+	sw := new(ShaderWatcher)
+	sw.Interval = defaultShaderWatchInterval
+	return sw
+}
+
+// Watch starts watching the source files of the shader, which has to have
+// been loaded with LoadShaderProgramFromFiles.
+func (sw *ShaderWatcher) Watch(rs *RenderShader) error {
+	files := rs.GetSourceFiles()
+	if files == nil {
+		return fmt.Errorf("The shader wasn't loaded from files and can't be watched")
+	}
+	for _, w := range sw.watched {
+		if w.shader == rs {
+			return nil
+		}
+	}
+	sw.watched = append(sw.watched, watchedShader{shader: rs, modTime: getLatestModTime(files)})
+	return nil
+}
+
+// Unwatch stops watching the shader.
+func (sw *ShaderWatcher) Unwatch(rs *RenderShader) {
+	for i, w := range sw.watched {
+		if w.shader == rs {
+			sw.watched = append(sw.watched[:i], sw.watched[i+1:]...)
+			return
+		}
+	}
+}
+
+// Update checks the watched files for changes every Interval seconds. It
+// must be called on the thread owning the GL context.
+func (sw *ShaderWatcher) Update(frameDelta float32) {
+	sw.timer += frameDelta
+	if sw.timer < sw.Interval {
+		return
+	}
+	sw.timer = 0.0
+	sw.Check()
+}
+
+// Check reloads the watched shaders whose files changed since they were
+// last loaded and returns how many were rebuilt successfully. Failures are
+// logged. It must be called on the thread owning the GL context.
+func (sw *ShaderWatcher) Check() int {
+	reloaded := 0
+	for i := range sw.watched {
+		w := &sw.watched[i]
+		files := w.shader.GetSourceFiles()
+		modTime := getLatestModTime(files)
+		if !modTime.After(w.modTime) {
+			continue
+		}
+		w.modTime = modTime
+
+		err := w.shader.Reload()
+		if err != nil {
+			groggy.Logsf("ERROR", "Failed to reload the shader %v, keeping the old program: %v", files, err)
+		} else {
+			groggy.Logsf("DEBUG", "Reloaded the shader %v.", files)
+			reloaded++
+		}
+		if sw.OnReload != nil {
+			sw.OnReload(w.shader, err)
+		}
+	}
+	return reloaded
+}
+
+// getLatestModTime returns the newest modification time of the files,
+// skipping the ones that can't be read.
+func getLatestModTime(files []string) time.Time {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
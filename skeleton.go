@@ -4,7 +4,10 @@
 package fizzle
 
 import (
+	"math"
+
 	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle/events"
 	"github.com/tbogdala/gombz"
 	"github.com/tbogdala/groggy"
 )
@@ -72,6 +75,23 @@ func (skel *Skeleton) Animate(animation *gombz.Animation, time float32) {
 	skel.updatePoseTransforms(animation)
 }
 
+// AdvanceAnimation moves the Renderable's AnimationTime forward by the clock's
+// scaled frame delta, looping at the end of the animation, and then animates
+// the skeleton at the new time. An AnimationFinished event is published on
+// events.Engine each time the animation loops.
+func (r *Renderable) AdvanceAnimation(animation *gombz.Animation, clock *Clock) {
+	if animation == nil || r.Core.Skeleton == nil {
+		return
+	}
+
+	r.AnimationTime += float32(clock.Delta())
+	if animation.Duration > 0.0 && r.AnimationTime >= animation.Duration {
+		r.AnimationTime = float32(math.Mod(float64(r.AnimationTime), float64(animation.Duration)))
+		events.Engine.Publish(AnimationFinished{Renderable: r, Animation: animation})
+	}
+	r.Core.Skeleton.Animate(animation, r.AnimationTime)
+}
+
 // getAnimationChannel returns the Channel for a given bone id or nil on error.
 func getAnimationChannel(animation *gombz.Animation, boneId int32) *gombz.AnimationChannel {
 	for _, c := range animation.Channels {
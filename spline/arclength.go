@@ -0,0 +1,80 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package spline
+
+import (
+	"sort"
+
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+const (
+	// DefaultArcLengthSamples is the default number of samples used to
+	// approximate the length of a curve.
+	DefaultArcLengthSamples = 256
+)
+
+// ArcLength maps distances along a curve to curve parameters so that
+// objects can move along it at a constant speed. The curve is sampled
+// when the table is built, so it must be rebuilt if the curve changes.
+type ArcLength struct {
+	curve     Curve
+	distances []float32
+}
+
+// NewArcLength builds the arc-length table for the curve from the number
+// of samples; DefaultArcLengthSamples is used if samples is less than 1.
+func NewArcLength(c Curve, samples int) *ArcLength {
+	if samples < 1 {
+		samples = DefaultArcLengthSamples
+	}
+
+	al := new(ArcLength)
+	al.curve = c
+	al.distances = make([]float32, samples+1)
+	prev := c.Point(0.0)
+	for i := 1; i <= samples; i++ {
+		p := c.Point(float32(i) / float32(samples))
+		al.distances[i] = al.distances[i-1] + p.Sub(prev).Len()
+		prev = p
+	}
+	return al
+}
+
+// Length returns the approximate length of the curve.
+func (al *ArcLength) Length() float32 {
+	return al.distances[len(al.distances)-1]
+}
+
+// ParamAtDistance returns the curve parameter at the distance along the
+// curve, clamped to the ends of the curve.
+func (al *ArcLength) ParamAtDistance(distance float32) float32 {
+	samples := len(al.distances) - 1
+	if distance <= 0.0 {
+		return 0.0
+	}
+	if distance >= al.Length() {
+		return 1.0
+	}
+
+	// find the first sample at or past the distance and interpolate from the one before
+	i := sort.Search(len(al.distances), func(j int) bool { return al.distances[j] >= distance })
+	span := al.distances[i] - al.distances[i-1]
+	frac := float32(0.0)
+	if span > 0.0 {
+		frac = (distance - al.distances[i-1]) / span
+	}
+	return (float32(i-1) + frac) / float32(samples)
+}
+
+// PointAtDistance returns the position at the distance along the curve.
+func (al *ArcLength) PointAtDistance(distance float32) mgl.Vec3 {
+	return al.curve.Point(al.ParamAtDistance(distance))
+}
+
+// ParamAtFraction returns the curve parameter at the fraction in [0..1] of
+// the curve's length, so evenly spaced fractions give evenly spaced points.
+func (al *ArcLength) ParamAtFraction(fraction float32) float32 {
+	return al.ParamAtDistance(fraction * al.Length())
+}
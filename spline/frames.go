@@ -0,0 +1,136 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package spline
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// Frame is an orientation along a curve.
+type Frame struct {
+	// T is the parameter of the curve the frame is at.
+	T float32
+
+	Position mgl.Vec3
+	Tangent  mgl.Vec3
+	Normal   mgl.Vec3
+	Binormal mgl.Vec3
+}
+
+// Rotation returns the frame as a rotation that turns -Z to the tangent and
+// +Y to the normal, which orients a camera or object along the curve.
+func (f *Frame) Rotation() mgl.Quat {
+	m := mgl.Mat3FromCols(f.Binormal, f.Normal, f.Tangent.Mul(-1.0))
+	return mgl.Mat4ToQuat(m.Mat4())
+}
+
+// FrameAt returns the frame at t with the normal oriented as close to the
+// up vector as possible. This is fine for paths that never point straight
+// up or down; use ComputeFrames for curves that twist or loop.
+func FrameAt(c Curve, t float32, up mgl.Vec3) Frame {
+	var f Frame
+	f.T = t
+	f.Position = c.Point(t)
+	f.Tangent = safeNormalize(c.Derivative(t), mgl.Vec3{0.0, 0.0, -1.0})
+	f.Binormal = f.Tangent.Cross(up)
+	if f.Binormal.Len() < 1e-5 {
+		f.Binormal = f.Tangent.Cross(anyPerpendicular(f.Tangent))
+	}
+	f.Binormal = f.Binormal.Normalize()
+	f.Normal = f.Binormal.Cross(f.Tangent).Normalize()
+	return f
+}
+
+// ComputeFrames returns count rotation minimizing frames spaced evenly by
+// length along the curve. The frames twist as little as possible, which
+// avoids the sudden flips a fixed up vector causes. The first frame's
+// normal is oriented towards the up vector.
+func ComputeFrames(c Curve, count int, up mgl.Vec3) []Frame {
+	if count < 2 {
+		count = 2
+	}
+	al := NewArcLength(c, count*4)
+
+	frames := make([]Frame, count)
+	frames[0] = FrameAt(c, 0.0, up)
+	for i := 1; i < count; i++ {
+		t := al.ParamAtFraction(float32(i) / float32(count-1))
+		prev := &frames[i-1]
+		cur := &frames[i]
+		cur.T = t
+		cur.Position = c.Point(t)
+		cur.Tangent = safeNormalize(c.Derivative(t), prev.Tangent)
+
+		// the double reflection method from Wang et al.
+		v1 := cur.Position.Sub(prev.Position)
+		c1 := v1.Dot(v1)
+		normal := prev.Normal
+		tangent := prev.Tangent
+		if c1 > 1e-10 {
+			normal = normal.Sub(v1.Mul(2.0 / c1 * v1.Dot(normal)))
+			tangent = tangent.Sub(v1.Mul(2.0 / c1 * v1.Dot(tangent)))
+		}
+		v2 := cur.Tangent.Sub(tangent)
+		c2 := v2.Dot(v2)
+		if c2 > 1e-10 {
+			normal = normal.Sub(v2.Mul(2.0 / c2 * v2.Dot(normal)))
+		}
+		cur.Normal = safeNormalize(normal, prev.Normal)
+		cur.Binormal = cur.Tangent.Cross(cur.Normal).Normalize()
+	}
+	return frames
+}
+
+// CreateRibbonGeometry builds a flat strip of the given width that follows
+// the frames, such as a road or river surface. The strip lies in the plane
+// of the tangent and binormal with its normals along the frame normals.
+// The U coordinate goes across the strip and V increases by one for every
+// width of length along the curve.
+func CreateRibbonGeometry(frames []Frame, width float32) *fizzle.Geometry {
+	g := new(fizzle.Geometry)
+	if len(frames) < 2 {
+		return g
+	}
+
+	half := width * 0.5
+	var v float32
+	for i, f := range frames {
+		if i > 0 {
+			v += f.Position.Sub(frames[i-1].Position).Len() / width
+		}
+		left := f.Position.Sub(f.Binormal.Mul(half))
+		right := f.Position.Add(f.Binormal.Mul(half))
+		g.Vertices = append(g.Vertices, left[0], left[1], left[2], right[0], right[1], right[2])
+		g.Normals = append(g.Normals, f.Normal[0], f.Normal[1], f.Normal[2], f.Normal[0], f.Normal[1], f.Normal[2])
+		g.UVs = append(g.UVs, 0.0, v, 1.0, v)
+		g.Tangents = append(g.Tangents, f.Binormal[0], f.Binormal[1], f.Binormal[2], f.Binormal[0], f.Binormal[1], f.Binormal[2])
+	}
+
+	for i := 0; i < len(frames)-1; i++ {
+		l0 := uint32(i * 2)
+		r0 := l0 + 1
+		l1 := l0 + 2
+		r1 := l0 + 3
+		g.Indexes = append(g.Indexes, l0, r0, r1, l0, r1, l1)
+	}
+	return g
+}
+
+// safeNormalize normalizes v or returns the fallback if v is near zero length.
+func safeNormalize(v mgl.Vec3, fallback mgl.Vec3) mgl.Vec3 {
+	l := v.Len()
+	if l < 1e-6 {
+		return fallback
+	}
+	return v.Mul(1.0 / l)
+}
+
+// anyPerpendicular returns a vector that isn't parallel to v.
+func anyPerpendicular(v mgl.Vec3) mgl.Vec3 {
+	if v[0] < 0.9 && v[0] > -0.9 {
+		return mgl.Vec3{1.0, 0.0, 0.0}
+	}
+	return mgl.Vec3{0.0, 1.0, 0.0}
+}
@@ -0,0 +1,237 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The spline module evaluates Catmull-Rom, Bezier and B-spline curves and
+provides arc-length parameterization and orientation frames along them.
+This is useful for camera paths, moving platforms and generating meshes
+like roads and rivers that follow a curve.
+
+All curves are parameterized with t in [0..1] across the whole curve.
+
+*/
+
+package spline
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+)
+
+// Curve is a parametric curve with t in [0..1].
+type Curve interface {
+	// Point returns the position on the curve at t.
+	Point(t float32) mgl.Vec3
+
+	// Derivative returns the first derivative of the curve at t, which
+	// points along the curve and isn't normalized.
+	Derivative(t float32) mgl.Vec3
+}
+
+// segment splits t into a segment index and the parameter within that segment.
+func segment(t float32, count int) (int, float32) {
+	if count <= 0 {
+		return 0, 0.0
+	}
+	if t <= 0.0 {
+		return 0, 0.0
+	}
+	if t >= 1.0 {
+		return count - 1, 1.0
+	}
+	scaled := t * float32(count)
+	i := int(scaled)
+	return i, scaled - float32(i)
+}
+
+// CatmullRom is a uniform Catmull-Rom spline that passes through all of its points.
+type CatmullRom struct {
+	Points []mgl.Vec3
+
+	// Closed connects the last point back to the first.
+	Closed bool
+}
+
+// NewCatmullRom creates a new Catmull-Rom spline through the points.
+func NewCatmullRom(points []mgl.Vec3, closed bool) *CatmullRom {
+	return &CatmullRom{Points: points, Closed: closed}
+}
+
+// segmentCount returns the number of segments in the spline.
+func (c *CatmullRom) segmentCount() int {
+	if c.Closed {
+		return len(c.Points)
+	}
+	return len(c.Points) - 1
+}
+
+// controlPoints returns the four points that influence the segment. The end
+// points of an open spline are duplicated.
+func (c *CatmullRom) controlPoints(i int) (mgl.Vec3, mgl.Vec3, mgl.Vec3, mgl.Vec3) {
+	n := len(c.Points)
+	get := func(j int) mgl.Vec3 {
+		if c.Closed {
+			return c.Points[((j%n)+n)%n]
+		}
+		if j < 0 {
+			j = 0
+		} else if j >= n {
+			j = n - 1
+		}
+		return c.Points[j]
+	}
+	return get(i - 1), get(i), get(i + 1), get(i + 2)
+}
+
+// Point returns the position on the spline at t.
+func (c *CatmullRom) Point(t float32) mgl.Vec3 {
+	if len(c.Points) < 2 {
+		return firstPoint(c.Points)
+	}
+	i, u := segment(t, c.segmentCount())
+	p0, p1, p2, p3 := c.controlPoints(i)
+	u2 := u * u
+	u3 := u2 * u
+	return p1.Mul(2.0).
+		Add(p2.Sub(p0).Mul(u)).
+		Add(p0.Mul(2.0).Sub(p1.Mul(5.0)).Add(p2.Mul(4.0)).Sub(p3).Mul(u2)).
+		Add(p1.Mul(3.0).Sub(p0).Sub(p2.Mul(3.0)).Add(p3).Mul(u3)).
+		Mul(0.5)
+}
+
+// Derivative returns the derivative of the spline at t.
+func (c *CatmullRom) Derivative(t float32) mgl.Vec3 {
+	if len(c.Points) < 2 {
+		return mgl.Vec3{}
+	}
+	count := c.segmentCount()
+	i, u := segment(t, count)
+	p0, p1, p2, p3 := c.controlPoints(i)
+	d := p2.Sub(p0).
+		Add(p0.Mul(2.0).Sub(p1.Mul(5.0)).Add(p2.Mul(4.0)).Sub(p3).Mul(2.0 * u)).
+		Add(p1.Mul(3.0).Sub(p0).Sub(p2.Mul(3.0)).Add(p3).Mul(3.0 * u * u)).
+		Mul(0.5)
+	// convert from the segment's parameter to the whole curve's
+	return d.Mul(float32(count))
+}
+
+// Bezier is a chain of cubic Bezier segments. The points are laid out as
+// start, control, control, end, control, control, end, ... so there are
+// 3n+1 points for n segments, and each segment starts where the last ended.
+type Bezier struct {
+	Points []mgl.Vec3
+}
+
+// NewBezier creates a new Bezier curve from the points.
+func NewBezier(points []mgl.Vec3) *Bezier {
+	return &Bezier{Points: points}
+}
+
+// segmentCount returns the number of complete segments in the curve.
+func (b *Bezier) segmentCount() int {
+	return (len(b.Points) - 1) / 3
+}
+
+// Point returns the position on the curve at t.
+func (b *Bezier) Point(t float32) mgl.Vec3 {
+	count := b.segmentCount()
+	if count < 1 {
+		return firstPoint(b.Points)
+	}
+	i, u := segment(t, count)
+	p := b.Points[i*3 : i*3+4]
+	v := 1.0 - u
+	return p[0].Mul(v * v * v).
+		Add(p[1].Mul(3.0 * v * v * u)).
+		Add(p[2].Mul(3.0 * v * u * u)).
+		Add(p[3].Mul(u * u * u))
+}
+
+// Derivative returns the derivative of the curve at t.
+func (b *Bezier) Derivative(t float32) mgl.Vec3 {
+	count := b.segmentCount()
+	if count < 1 {
+		return mgl.Vec3{}
+	}
+	i, u := segment(t, count)
+	p := b.Points[i*3 : i*3+4]
+	v := 1.0 - u
+	d := p[1].Sub(p[0]).Mul(3.0 * v * v).
+		Add(p[2].Sub(p[1]).Mul(6.0 * v * u)).
+		Add(p[3].Sub(p[2]).Mul(3.0 * u * u))
+	return d.Mul(float32(count))
+}
+
+// BSpline is a uniform cubic B-spline. It is smoother than a Catmull-Rom
+// spline but only approximates its points instead of passing through them.
+type BSpline struct {
+	Points []mgl.Vec3
+
+	// Closed connects the last point back to the first.
+	Closed bool
+}
+
+// NewBSpline creates a new B-spline from the points.
+func NewBSpline(points []mgl.Vec3, closed bool) *BSpline {
+	return &BSpline{Points: points, Closed: closed}
+}
+
+// segmentCount returns the number of segments in the spline.
+func (s *BSpline) segmentCount() int {
+	if s.Closed {
+		return len(s.Points)
+	}
+	return len(s.Points) - 3
+}
+
+// controlPoints returns the four points that influence the segment.
+func (s *BSpline) controlPoints(i int) (mgl.Vec3, mgl.Vec3, mgl.Vec3, mgl.Vec3) {
+	n := len(s.Points)
+	if s.Closed {
+		return s.Points[i%n], s.Points[(i+1)%n], s.Points[(i+2)%n], s.Points[(i+3)%n]
+	}
+	return s.Points[i], s.Points[i+1], s.Points[i+2], s.Points[i+3]
+}
+
+// Point returns the position on the spline at t.
+func (s *BSpline) Point(t float32) mgl.Vec3 {
+	count := s.segmentCount()
+	if count < 1 {
+		return firstPoint(s.Points)
+	}
+	i, u := segment(t, count)
+	p0, p1, p2, p3 := s.controlPoints(i)
+	v := 1.0 - u
+	u2 := u * u
+	u3 := u2 * u
+	return p0.Mul(v * v * v).
+		Add(p1.Mul(3.0*u3 - 6.0*u2 + 4.0)).
+		Add(p2.Mul(-3.0*u3 + 3.0*u2 + 3.0*u + 1.0)).
+		Add(p3.Mul(u3)).
+		Mul(1.0 / 6.0)
+}
+
+// Derivative returns the derivative of the spline at t.
+func (s *BSpline) Derivative(t float32) mgl.Vec3 {
+	count := s.segmentCount()
+	if count < 1 {
+		return mgl.Vec3{}
+	}
+	i, u := segment(t, count)
+	p0, p1, p2, p3 := s.controlPoints(i)
+	v := 1.0 - u
+	d := p0.Mul(-3.0 * v * v).
+		Add(p1.Mul(9.0*u*u - 12.0*u)).
+		Add(p2.Mul(-9.0*u*u + 6.0*u + 3.0)).
+		Add(p3.Mul(3.0 * u * u)).
+		Mul(1.0 / 6.0)
+	return d.Mul(float32(count))
+}
+
+// firstPoint returns the first point or the origin if there are none.
+func firstPoint(points []mgl.Vec3) mgl.Vec3 {
+	if len(points) == 0 {
+		return mgl.Vec3{}
+	}
+	return points[0]
+}
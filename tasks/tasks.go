@@ -0,0 +1,296 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The tasks module schedules delayed calls, repeating timers and multi-frame
+tasks that are driven by the engine clock, for things like spawn waves,
+timed sequences and scripted behaviors.
+
+A task is a function that can suspend itself with WaitSeconds, WaitFrames or
+WaitUntil and is resumed by a later Update once the wait is over. Each task
+runs on its own goroutine, but only while the scheduler is blocked waiting on
+it, so tasks can safely touch the same game state as the code calling Update.
+Because the goroutine is not the thread owning the GL context, tasks must not
+make graphics calls directly; use After(0, fn) to run those from Update.
+
+*/
+
+package tasks
+
+import (
+	"runtime"
+
+	"github.com/tbogdala/fizzle"
+)
+
+// Handle identifies a scheduled timer or task so it can be cancelled.
+type Handle uint64
+
+// TaskFunc is the type of the function run as a task.
+type TaskFunc func(t *Task)
+
+// timer is a delayed or repeating call.
+type timer struct {
+	id        Handle
+	next      float64
+	interval  float64
+	repeat    bool
+	fn        func()
+	cancelled bool
+}
+
+// Task is a function running across multiple frames.
+type Task struct {
+	id Handle
+	s  *Scheduler
+
+	resume chan bool
+	yield  chan struct{}
+
+	wakeTime  float64
+	wakeFrame uint64
+	wakeCond  func() bool
+	done      bool
+	cancelled bool
+	isWaiting bool
+}
+
+// Scheduler runs the timers and tasks as time advances.
+type Scheduler struct {
+	time    float64
+	frame   uint64
+	nextID  Handle
+	timers  []*timer
+	tasks   []*Task
+	added   []*timer
+	started []*Task
+	running *Task
+}
+
+// NewScheduler creates a new empty scheduler.
+func NewScheduler() *Scheduler {
+	s := new(Scheduler)
+	s.timers = make([]*timer, 0)
+	s.tasks = make([]*Task, 0)
+	return s
+}
+
+// Time returns the scheduler's time in seconds, the sum of all of the
+// deltas passed to Update.
+func (s *Scheduler) Time() float64 {
+	return s.time
+}
+
+// After calls fn once after the delay in seconds has passed.
+func (s *Scheduler) After(delay float64, fn func()) Handle {
+	return s.addTimer(delay, 0.0, false, fn)
+}
+
+// Every calls fn every interval seconds, starting one interval from now,
+// until cancelled. If a frame covers several intervals, fn is called once
+// for each of them.
+func (s *Scheduler) Every(interval float64, fn func()) Handle {
+	return s.addTimer(interval, interval, true, fn)
+}
+
+// Start runs the task function until it first waits or returns and then
+// resumes it from Update whenever its wait is over.
+func (s *Scheduler) Start(fn TaskFunc) Handle {
+	s.nextID++
+	t := &Task{id: s.nextID, s: s, resume: make(chan bool), yield: make(chan struct{})}
+	s.started = append(s.started, t)
+
+	go func() {
+		defer func() {
+			t.done = true
+			t.yield <- struct{}{}
+		}()
+		if !<-t.resume {
+			return
+		}
+		fn(t)
+	}()
+
+	s.step(t, true)
+	return t.id
+}
+
+// Cancel stops the timer or task. A cancelled task is unwound from the
+// point it's waiting at, so its deferred functions still run. Calling
+// Cancel on the task from within itself stops it immediately.
+func (s *Scheduler) Cancel(h Handle) {
+	for _, list := range [][]*timer{s.timers, s.added} {
+		for _, tm := range list {
+			if tm.id == h {
+				tm.cancelled = true
+				return
+			}
+		}
+	}
+
+	for _, list := range [][]*Task{s.tasks, s.started} {
+		for _, t := range list {
+			if t.id != h || t.done {
+				continue
+			}
+			t.cancelled = true
+			if s.running == t {
+				runtime.Goexit()
+			}
+			if t.isWaiting {
+				s.step(t, false)
+			}
+			return
+		}
+	}
+}
+
+// IsActive returns true if the timer or task hasn't finished or been cancelled.
+func (s *Scheduler) IsActive(h Handle) bool {
+	for _, list := range [][]*timer{s.timers, s.added} {
+		for _, tm := range list {
+			if tm.id == h {
+				return !tm.cancelled
+			}
+		}
+	}
+	for _, list := range [][]*Task{s.tasks, s.started} {
+		for _, t := range list {
+			if t.id == h {
+				return !t.done && !t.cancelled
+			}
+		}
+	}
+	return false
+}
+
+// UpdateWithClock advances the scheduler by the clock's scaled delta so
+// that timers and tasks pause along with the clock.
+func (s *Scheduler) UpdateWithClock(clock *fizzle.Clock) {
+	s.Update(clock.Delta())
+}
+
+// Update advances the scheduler's time by frameDelta seconds and runs the
+// timers and tasks that are due.
+func (s *Scheduler) Update(frameDelta float64) {
+	s.time += frameDelta
+	s.frame++
+
+	s.timers = append(s.timers, s.added...)
+	s.added = s.added[:0]
+	for _, tm := range s.timers {
+		for !tm.cancelled && tm.next <= s.time {
+			tm.fn()
+			if !tm.repeat || tm.interval <= 0.0 {
+				tm.cancelled = true
+				break
+			}
+			tm.next += tm.interval
+		}
+	}
+
+	s.tasks = append(s.tasks, s.started...)
+	s.started = s.started[:0]
+	for _, t := range s.tasks {
+		if t.done || !t.isWaiting || !t.isReady() {
+			continue
+		}
+		s.step(t, true)
+	}
+
+	// compact the lists, keeping the order things were scheduled in
+	timers := s.timers[:0]
+	for _, tm := range s.timers {
+		if !tm.cancelled {
+			timers = append(timers, tm)
+		}
+	}
+	for i := len(timers); i < len(s.timers); i++ {
+		s.timers[i] = nil
+	}
+	s.timers = timers
+
+	tasks := s.tasks[:0]
+	for _, t := range s.tasks {
+		if !t.done {
+			tasks = append(tasks, t)
+		}
+	}
+	for i := len(tasks); i < len(s.tasks); i++ {
+		s.tasks[i] = nil
+	}
+	s.tasks = tasks
+}
+
+// addTimer schedules a new timer.
+func (s *Scheduler) addTimer(delay float64, interval float64, repeat bool, fn func()) Handle {
+	s.nextID++
+	tm := &timer{id: s.nextID, next: s.time + delay, interval: interval, repeat: repeat, fn: fn}
+	s.added = append(s.added, tm)
+	return tm.id
+}
+
+// step resumes the task and blocks until it waits again or finishes.
+func (s *Scheduler) step(t *Task, proceed bool) {
+	prev := s.running
+	s.running = t
+	t.isWaiting = false
+	t.resume <- proceed
+	<-t.yield
+	s.running = prev
+}
+
+// WaitSeconds suspends the task until the scheduler's time has advanced by
+// the number of seconds.
+func (t *Task) WaitSeconds(seconds float64) {
+	t.wakeTime = t.s.time + seconds
+	t.wakeFrame = 0
+	t.wakeCond = nil
+	t.suspend()
+}
+
+// WaitFrames suspends the task for the number of calls to Update.
+func (t *Task) WaitFrames(frames int) {
+	t.wakeTime = 0.0
+	t.wakeFrame = t.s.frame + uint64(frames)
+	t.wakeCond = nil
+	t.suspend()
+}
+
+// Yield suspends the task until the next Update.
+func (t *Task) Yield() {
+	t.WaitFrames(1)
+}
+
+// WaitUntil suspends the task until cond returns true. The condition is
+// checked once per Update.
+func (t *Task) WaitUntil(cond func() bool) {
+	t.wakeTime = 0.0
+	t.wakeFrame = 0
+	t.wakeCond = cond
+	t.suspend()
+}
+
+// Handle returns the handle of the task.
+func (t *Task) Handle() Handle {
+	return t.id
+}
+
+// isReady returns true if the task's wait is over.
+func (t *Task) isReady() bool {
+	if t.wakeCond != nil {
+		return t.wakeCond()
+	}
+	return t.s.time >= t.wakeTime && t.s.frame >= t.wakeFrame
+}
+
+// suspend hands control back to the scheduler and blocks until resumed.
+// If the task was cancelled while waiting, its goroutine exits.
+func (t *Task) suspend() {
+	t.isWaiting = true
+	t.yield <- struct{}{}
+	if !<-t.resume {
+		runtime.Goexit()
+	}
+}
@@ -4,20 +4,82 @@
 package fizzle
 
 import (
+	"image"
+
+	"github.com/tbogdala/fizzle/events"
 	graphics "github.com/tbogdala/fizzle/graphicsprovider"
+	"github.com/tbogdala/groggy"
+)
+
+const (
+	// evictedTextureDivisor is how much each dimension of a streamable
+	// texture is divided by when it gets evicted to the low resolution copy.
+	evictedTextureDivisor = 8
 )
 
+// managedTexture tracks a texture in the TextureManager for budgeting.
+type managedTexture struct {
+	texture graphics.Texture
+
+	// key is the name the texture is stored under
+	key string
+
+	// size is the estimated GPU memory used by the texture right now
+	size int64
+
+	// lastUsed is the value of the manager's use counter when the
+	// texture was last accessed
+	lastUsed uint64
+
+	// internalFormat is the format the texture data is uploaded with
+	internalFormat int32
+
+	// streamable textures can be evicted to lowRes and reloaded from path
+	streamable bool
+	evicted    bool
+	path       string
+	lowRes     *image.NRGBA
+
+	// wanted is set when an evicted texture was used by a draw and gets
+	// reloaded by the next Update
+	wanted bool
+}
+
 // TextureManager provides an easy way to load textures to OpenGL and
 // to access the textures by name elsewhere.
+//
+// An optional memory budget can be set. When the textures loaded with
+// LoadStreamableTexture push the estimated GPU memory use over budget, the
+// least recently used ones are replaced with a low resolution copy in the
+// same GL texture object. They're reloaded from disk the next time
+// GetTexture is called for them, or by Update after a draw used them.
+//
+// Renderers only see the texture objects of materials, so the draws have
+// to report the textures they use with Touch or TouchMaterial for the
+// least recently used order to follow what's drawn; the forward and
+// deferred renderers do this for their Textures manager.
 type TextureManager struct {
+	// Budget is the GPU memory budget in bytes for the textures; 0 means no budget.
+	Budget int64
+
 	// storage keeps references to the OpenGL texture objects referenced by name.
-	storage map[string]graphics.Texture
+	storage map[string]*managedTexture
+
+	// byTexture finds the stored textures by their OpenGL object for Touch
+	byTexture map[graphics.Texture]*managedTexture
+
+	// usage is the estimated GPU memory used by all textures in storage
+	usage int64
+
+	// useCounter increases with every texture access to order them for eviction
+	useCounter uint64
 }
 
 // NewTextureManager creates a new TextureManager object with empty storage.
 func NewTextureManager() *TextureManager {
 	tm := new(TextureManager)
-	tm.storage = make(map[string]graphics.Texture)
+	tm.storage = make(map[string]*managedTexture)
+	tm.byTexture = make(map[graphics.Texture]*managedTexture)
 	return tm
 }
 
@@ -25,29 +87,291 @@ func NewTextureManager() *TextureManager {
 // and resets the storage map.
 func (tm *TextureManager) Destroy() {
 	for _, t := range tm.storage {
-		gfx.DeleteTexture(t)
+		gfx.DeleteTexture(t.texture)
 	}
-	tm.storage = make(map[string]graphics.Texture)
+	tm.storage = make(map[string]*managedTexture)
+	tm.byTexture = make(map[graphics.Texture]*managedTexture)
+	tm.usage = 0
 }
 
 // GetTexture attempts to access the texture by name in storage and returns
 // the OpenGL object and a bool indicating if the texture was found in storage.
+// If the texture was evicted to stay under budget, it gets reloaded at full
+// resolution into the same OpenGL object.
 func (tm *TextureManager) GetTexture(keyToUse string) (graphics.Texture, bool) {
 	// try loading from storage
-	glTexture, okay := tm.storage[keyToUse]
-	return glTexture, okay
+	mt, okay := tm.storage[keyToUse]
+	if !okay {
+		return 0, false
+	}
+
+	tm.touch(mt)
+	if mt.evicted {
+		tm.reloadAndEnforce(mt)
+	}
+
+	return mt.texture, true
+}
+
+// Touch marks the texture as used by a draw so that it's evicted after the
+// ones that weren't drawn recently. If it was evicted, it gets reloaded at
+// full resolution by the next Update; it isn't reloaded right away since
+// Touch is called while other textures are bound. Textures that aren't in
+// the manager are ignored, as is a nil manager.
+func (tm *TextureManager) Touch(tex graphics.Texture) {
+	if tm == nil || tex == 0 {
+		return
+	}
+	mt, okay := tm.byTexture[tex]
+	if !okay {
+		return
+	}
+	tm.touch(mt)
+	if mt.evicted {
+		mt.wanted = true
+	}
+}
+
+// TouchMaterial calls Touch for every texture of the material.
+func (tm *TextureManager) TouchMaterial(mat *Material) {
+	if tm == nil {
+		return
+	}
+	tm.Touch(mat.Tex0)
+	tm.Touch(mat.Tex1)
+	tm.Touch(mat.PBR.MetalRoughTex)
+	tm.Touch(mat.PBR.OcclusionTex)
+	tm.Touch(mat.PBR.EmissiveTex)
+}
+
+// Update reloads the evicted textures that were touched since the last
+// Update, evicting others if that goes over budget. It should be called
+// once per frame, outside of drawing, on the thread owning the GL context.
+// A nil manager is ignored.
+func (tm *TextureManager) Update() {
+	if tm == nil {
+		return
+	}
+	for _, mt := range tm.storage {
+		if mt.wanted {
+			mt.wanted = false
+			if mt.evicted {
+				tm.reloadAndEnforce(mt)
+			}
+		}
+	}
+}
+
+// reloadAndEnforce reloads the evicted texture and then evicts others to
+// get back within budget.
+func (tm *TextureManager) reloadAndEnforce(mt *managedTexture) {
+	err := tm.reload(mt)
+	if err != nil {
+		groggy.Logsf("ERROR", "TextureManager failed to reload %s: %v", mt.path, err)
+		return
+	}
+	tm.enforceBudget(mt)
 }
 
 // LoadTexture loads a texture specified by path into OpenGL and then
 // stores the object in the storage map under the specified keyToUse.
+// Textures loaded this way count against the budget but are never evicted.
 func (tm *TextureManager) LoadTexture(keyToUse string, path string) (graphics.Texture, error) {
+	return tm.loadTexture(keyToUse, path, graphics.RGBA)
+}
+
+// LoadTextureSRGB works like LoadTexture but creates an sRGB texture, which
+// is converted to linear colors when sampled; see LoadImageToTextureSRGB.
+func (tm *TextureManager) LoadTextureSRGB(keyToUse string, path string) (graphics.Texture, error) {
+	return tm.loadTexture(keyToUse, path, graphics.SRGB8_ALPHA8)
+}
+
+// loadTexture loads the texture with the internal format and stores it.
+func (tm *TextureManager) loadTexture(keyToUse string, path string, internalFormat int32) (graphics.Texture, error) {
 	// load the file into a GL texture
-	glTexture, err := LoadImageToTexture(path)
+	rgbaFlipped, err := loadFile(path)
 	if err != nil {
-		return glTexture, err
+		return 0, err
 	}
 
 	// store it for later
-	tm.storage[keyToUse] = glTexture
-	return glTexture, nil
+	mt := new(managedTexture)
+	mt.internalFormat = internalFormat
+	mt.texture, mt.size, err = uploadManagedTexture(rgbaFlipped, internalFormat)
+	if err != nil {
+		return 0, err
+	}
+	tm.store(keyToUse, mt)
+	tm.enforceBudget(mt)
+	return mt.texture, nil
+}
+
+// LoadStreamableTexture loads a texture specified by path into OpenGL and
+// stores it under keyToUse, allowing the manager to evict it to a low
+// resolution copy when over budget.
+func (tm *TextureManager) LoadStreamableTexture(keyToUse string, path string) (graphics.Texture, error) {
+	return tm.loadStreamableTexture(keyToUse, path, graphics.RGBA)
+}
+
+// LoadStreamableTextureSRGB works like LoadStreamableTexture but creates an
+// sRGB texture, which is converted to linear colors when sampled.
+func (tm *TextureManager) LoadStreamableTextureSRGB(keyToUse string, path string) (graphics.Texture, error) {
+	return tm.loadStreamableTexture(keyToUse, path, graphics.SRGB8_ALPHA8)
+}
+
+// loadStreamableTexture loads the streamable texture with the internal
+// format and stores it.
+func (tm *TextureManager) loadStreamableTexture(keyToUse string, path string, internalFormat int32) (graphics.Texture, error) {
+	rgbaFlipped, err := loadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	mt := new(managedTexture)
+	mt.streamable = true
+	mt.path = path
+	mt.lowRes = downsampleNRGBA(rgbaFlipped, evictedTextureDivisor)
+	mt.internalFormat = internalFormat
+	mt.texture, mt.size, err = uploadManagedTexture(rgbaFlipped, internalFormat)
+	if err != nil {
+		return 0, err
+	}
+
+	tm.store(keyToUse, mt)
+	tm.enforceBudget(mt)
+	return mt.texture, nil
+}
+
+// MemoryUsage returns the estimated GPU memory in bytes used by the textures
+// in the manager.
+func (tm *TextureManager) MemoryUsage() int64 {
+	return tm.usage
+}
+
+// store adds the texture to storage, replacing any texture already using the key.
+func (tm *TextureManager) store(keyToUse string, mt *managedTexture) {
+	if old, okay := tm.storage[keyToUse]; okay {
+		tm.usage -= old.size
+		delete(tm.byTexture, old.texture)
+	}
+	mt.key = keyToUse
+	tm.touch(mt)
+	tm.storage[keyToUse] = mt
+	tm.byTexture[mt.texture] = mt
+	tm.usage += mt.size
+}
+
+// touch marks the texture as the most recently used.
+func (tm *TextureManager) touch(mt *managedTexture) {
+	tm.useCounter++
+	mt.lastUsed = tm.useCounter
+}
+
+// enforceBudget evicts the least recently used streamable textures, other
+// than keep, until the usage is within budget or nothing else can be evicted.
+func (tm *TextureManager) enforceBudget(keep *managedTexture) {
+	if tm.Budget <= 0 {
+		return
+	}
+
+	for tm.usage > tm.Budget {
+		var lru *managedTexture
+		for _, mt := range tm.storage {
+			if mt == keep || !mt.streamable || mt.evicted {
+				continue
+			}
+			if lru == nil || mt.lastUsed < lru.lastUsed {
+				lru = mt
+			}
+		}
+		if lru == nil {
+			return
+		}
+		tm.evict(lru)
+	}
+}
+
+// evict replaces the texture data with the low resolution copy.
+func (tm *TextureManager) evict(mt *managedTexture) {
+	tm.usage -= mt.size
+	mt.size = mt.upload(mt.lowRes)
+	tm.usage += mt.size
+	mt.evicted = true
+}
+
+// reload loads the full resolution texture data back from disk and publishes
+// an AssetReloaded event on events.Engine.
+func (tm *TextureManager) reload(mt *managedTexture) error {
+	rgbaFlipped, err := loadFile(mt.path)
+	if err != nil {
+		return err
+	}
+
+	tm.usage -= mt.size
+	mt.size = mt.upload(rgbaFlipped)
+	tm.usage += mt.size
+	mt.evicted = false
+	events.Engine.Publish(events.AssetReloaded{Kind: events.AssetTexture, Name: mt.key, Path: mt.path})
+	return nil
+}
+
+// uploadManagedTexture creates the texture object the same way
+// LoadImageToTexture does and returns it with the estimated size in bytes
+// of the texture data.
+func uploadManagedTexture(img *image.NRGBA, internalFormat int32) (graphics.Texture, int64, error) {
+	tex, err := uploadImageToTexture(img, internalFormat)
+	if err != nil {
+		return 0, 0, err
+	}
+	return tex, int64(len(img.Pix)), nil
+}
+
+// upload replaces the level 0 image of the texture and returns the
+// estimated size in bytes of the texture data.
+func (mt *managedTexture) upload(img *image.NRGBA) int64 {
+	w := int32(img.Bounds().Dx())
+	h := int32(img.Bounds().Dy())
+	gfx.BindTexture(graphics.TEXTURE_2D, mt.texture)
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, mt.internalFormat, w, h, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, gfx.Ptr(img.Pix), len(img.Pix))
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+	return int64(len(img.Pix))
+}
+
+// downsampleNRGBA shrinks the image by the divisor in each dimension by
+// averaging blocks of pixels.
+func downsampleNRGBA(src *image.NRGBA, divisor int) *image.NRGBA {
+	sb := src.Bounds()
+	w := sb.Dx() / divisor
+	h := sb.Dy() / divisor
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]int
+			count := 0
+			for sy := y * divisor; sy < (y+1)*divisor && sy < sb.Dy(); sy++ {
+				for sx := x * divisor; sx < (x+1)*divisor && sx < sb.Dx(); sx++ {
+					offset := sy*src.Stride + sx*4
+					for c := 0; c < 4; c++ {
+						sum[c] += int(src.Pix[offset+c])
+					}
+					count++
+				}
+			}
+			if count == 0 {
+				continue
+			}
+			doffset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[doffset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return dst
 }
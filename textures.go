@@ -119,50 +119,88 @@ func LoadRGBToTextureExt(rgb []byte, imageSize, magFilter, minFilter, wrapS, wra
 
 // LoadImageToTexture loads an image from a file into an OpenGL texture.
 func LoadImageToTexture(filePath string) (graphics.Texture, error) {
-	tex := gfx.GenTexture()
-	gfx.ActiveTexture(graphics.TEXTURE0)
-	gfx.BindTexture(graphics.TEXTURE_2D, tex)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.REPEAT)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.REPEAT)
-
 	rgbaFlipped, err := loadFile(filePath)
 	if err != nil {
-		return tex, err
+		return 0, err
 	}
 
-	imageSize := int32(rgbaFlipped.Bounds().Max.X)
+	tex, err := uploadImageToTexture(rgbaFlipped, graphics.RGBA)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create the texture for %s: %v", filePath, err)
+	}
+	return tex, nil
+}
 
-	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA, imageSize, imageSize, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, gfx.Ptr(rgbaFlipped.Pix), len(rgbaFlipped.Pix))
+// LoadImageToTextureSRGB loads an image from a file into an sRGB texture,
+// which is converted to linear colors when sampled so that lighting can be
+// calculated in linear space. Color images like albedo maps are authored
+// in sRGB and should be loaded this way; normal maps and other data
+// textures should not.
+func LoadImageToTextureSRGB(filePath string) (graphics.Texture, error) {
+	rgbaFlipped, err := loadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+
+	tex, err := uploadImageToTexture(rgbaFlipped, graphics.SRGB8_ALPHA8)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to create the texture for %s: %v", filePath, err)
+	}
 	return tex, nil
 }
 
 // LoadPNGToTexture loads a byte slice as a PNG image and buffers it into
 // a new GL texture.
 func LoadPNGToTexture(data []byte) (graphics.Texture, error) {
-	tex := gfx.GenTexture()
-	gfx.ActiveTexture(graphics.TEXTURE0)
-	gfx.BindTexture(graphics.TEXTURE_2D, tex)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.REPEAT)
-	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.REPEAT)
+	return loadPNGToTexture(data, graphics.RGBA)
+}
+
+// LoadPNGToTextureSRGB works like LoadPNGToTexture but creates an sRGB
+// texture like LoadImageToTextureSRGB.
+func LoadPNGToTextureSRGB(data []byte) (graphics.Texture, error) {
+	return loadPNGToTexture(data, graphics.SRGB8_ALPHA8)
+}
 
+// loadPNGToTexture decodes the PNG image and buffers it into a new GL
+// texture with the internal format.
+func loadPNGToTexture(data []byte, internalFormat int32) (graphics.Texture, error) {
 	breader := bytes.NewReader(data)
 	img, err := png.Decode(breader)
 	if err != nil {
-		return tex, err
+		return 0, err
 	}
 
 	rgbaFlipped, err := loadDecodedPNG(img)
 	if err != nil {
-		return tex, err
+		return 0, err
 	}
 
-	imageSize := int32(rgbaFlipped.Bounds().Max.X)
+	return uploadImageToTexture(rgbaFlipped, internalFormat)
+}
+
+// uploadImageToTexture creates a new texture with the image and the internal
+// format, deleting the texture again if OpenGL reports an error.
+func uploadImageToTexture(rgbaFlipped *image.NRGBA, internalFormat int32) (graphics.Texture, error) {
+	tex := gfx.GenTexture()
+	if tex == 0 {
+		return 0, fmt.Errorf("Failed to generate a texture object: %v", CheckForError("generating a texture"))
+	}
+	gfx.ActiveTexture(graphics.TEXTURE0)
+	gfx.BindTexture(graphics.TEXTURE_2D, tex)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MAG_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_MIN_FILTER, graphics.LINEAR)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_S, graphics.REPEAT)
+	gfx.TexParameteri(graphics.TEXTURE_2D, graphics.TEXTURE_WRAP_T, graphics.REPEAT)
 
-	gfx.TexImage2D(graphics.TEXTURE_2D, 0, graphics.RGBA, imageSize, imageSize, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, gfx.Ptr(rgbaFlipped.Pix), len(rgbaFlipped.Pix))
+	width := int32(rgbaFlipped.Bounds().Dx())
+	height := int32(rgbaFlipped.Bounds().Dy())
+	gfx.TexImage2D(graphics.TEXTURE_2D, 0, internalFormat, width, height, 0, graphics.RGBA, graphics.UNSIGNED_BYTE, gfx.Ptr(rgbaFlipped.Pix), len(rgbaFlipped.Pix))
+	gfx.BindTexture(graphics.TEXTURE_2D, 0)
+
+	if err := CheckForError("uploading the texture"); err != nil {
+		gfx.DeleteTexture(tex)
+		return 0, err
+	}
 	return tex, nil
 }
 
@@ -188,6 +226,10 @@ func (texArray *TextureArray) LoadImageFromFiles(texName string, filePath string
 		return fmt.Errorf("Failed to load the PNG file into an image.\n%v\n", err)
 	}
 
+	if err := texArray.validateImage(rgbaFlipped, size); err != nil {
+		return fmt.Errorf("Failed to load %s into the texture array: %v", texName, err)
+	}
+
 	const levels = 1
 	const byteDepth int32 = 1
 	gfx.BindTexture(graphics.TEXTURE_2D_ARRAY, texArray.Texture)
@@ -212,6 +254,10 @@ func (texArray *TextureArray) LoadImageAsPNG(texName string, data []byte, size i
 		return fmt.Errorf("Failed to load the PNG file into a texture array image.\n%v\n", err)
 	}
 
+	if err := texArray.validateImage(rgbaFlipped, size); err != nil {
+		return fmt.Errorf("Failed to load %s into the texture array: %v", texName, err)
+	}
+
 	const levels = 1
 	const byteDepth int32 = 1
 	gfx.BindTexture(graphics.TEXTURE_2D_ARRAY, texArray.Texture)
@@ -222,3 +268,16 @@ func (texArray *TextureArray) LoadImageAsPNG(texName string, data []byte, size i
 
 	return nil
 }
+
+// validateImage checks that the image can be copied into a layer of the
+// texture array with the size.
+func (texArray *TextureArray) validateImage(img *image.NRGBA, size int32) error {
+	if texArray.Texture == 0 {
+		return fmt.Errorf("the texture array object was not created")
+	}
+	b := img.Bounds()
+	if int32(b.Dx()) != size || int32(b.Dy()) != size {
+		return fmt.Errorf("the image is %dx%d but the array layers are %dx%d", b.Dx(), b.Dy(), size, size)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package tween
+
+import (
+	"math"
+)
+
+// EaseFunc maps the linear progress of a tween in [0..1] to the eased
+// progress. The output may overshoot [0..1] for easings like Back and Elastic.
+type EaseFunc func(t float32) float32
+
+// Linear does no easing.
+func Linear(t float32) float32 {
+	return t
+}
+
+// QuadIn accelerates from zero velocity.
+func QuadIn(t float32) float32 {
+	return t * t
+}
+
+// QuadOut decelerates to zero velocity.
+func QuadOut(t float32) float32 {
+	return t * (2.0 - t)
+}
+
+// QuadInOut accelerates until halfway and then decelerates.
+func QuadInOut(t float32) float32 {
+	if t < 0.5 {
+		return 2.0 * t * t
+	}
+	return -1.0 + (4.0-2.0*t)*t
+}
+
+// CubicIn accelerates from zero velocity.
+func CubicIn(t float32) float32 {
+	return t * t * t
+}
+
+// CubicOut decelerates to zero velocity.
+func CubicOut(t float32) float32 {
+	t--
+	return t*t*t + 1.0
+}
+
+// CubicInOut accelerates until halfway and then decelerates.
+func CubicInOut(t float32) float32 {
+	if t < 0.5 {
+		return 4.0 * t * t * t
+	}
+	t = 2.0*t - 2.0
+	return 0.5*t*t*t + 1.0
+}
+
+// SineIn accelerates along a sine curve.
+func SineIn(t float32) float32 {
+	return 1.0 - float32(math.Cos(float64(t)*math.Pi*0.5))
+}
+
+// SineOut decelerates along a sine curve.
+func SineOut(t float32) float32 {
+	return float32(math.Sin(float64(t) * math.Pi * 0.5))
+}
+
+// SineInOut accelerates and then decelerates along a sine curve.
+func SineInOut(t float32) float32 {
+	return -0.5 * (float32(math.Cos(math.Pi*float64(t))) - 1.0)
+}
+
+// ExpoIn accelerates exponentially.
+func ExpoIn(t float32) float32 {
+	if t == 0.0 {
+		return 0.0
+	}
+	return float32(math.Pow(2.0, 10.0*(float64(t)-1.0)))
+}
+
+// ExpoOut decelerates exponentially.
+func ExpoOut(t float32) float32 {
+	if t == 1.0 {
+		return 1.0
+	}
+	return 1.0 - float32(math.Pow(2.0, -10.0*float64(t)))
+}
+
+// BackIn pulls back a little before moving forward.
+func BackIn(t float32) float32 {
+	const s = 1.70158
+	return t * t * ((s+1.0)*t - s)
+}
+
+// BackOut overshoots the target a little before settling.
+func BackOut(t float32) float32 {
+	const s = 1.70158
+	t--
+	return t*t*((s+1.0)*t+s) + 1.0
+}
+
+// ElasticOut overshoots and oscillates around the target like a spring.
+func ElasticOut(t float32) float32 {
+	if t == 0.0 || t == 1.0 {
+		return t
+	}
+	const p = 0.3
+	return float32(math.Pow(2.0, -10.0*float64(t))*math.Sin((float64(t)-p/4.0)*(2.0*math.Pi)/p)) + 1.0
+}
+
+// BounceOut bounces off the target like a dropped ball.
+func BounceOut(t float32) float32 {
+	switch {
+	case t < 1.0/2.75:
+		return 7.5625 * t * t
+	case t < 2.0/2.75:
+		t -= 1.5 / 2.75
+		return 7.5625*t*t + 0.75
+	case t < 2.5/2.75:
+		t -= 2.25 / 2.75
+		return 7.5625*t*t + 0.9375
+	default:
+		t -= 2.625 / 2.75
+		return 7.5625*t*t + 0.984375
+	}
+}
+
+// BounceIn is BounceOut in reverse.
+func BounceIn(t float32) float32 {
+	return 1.0 - BounceOut(1.0-t)
+}
@@ -0,0 +1,265 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The tween module animates float, vector, quaternion and color values
+towards a target over time with easing functions. Tweens can be delayed,
+chained one after another and have callbacks for updates and completion,
+which covers UI transitions and simple object animation without needing
+keyframed animation clips.
+
+*/
+
+package tween
+
+import (
+	mgl "github.com/go-gl/mathgl/mgl32"
+	"github.com/tbogdala/fizzle"
+)
+
+// Callback is the type of the function called on tween updates and completion.
+type Callback func(tw *Tween)
+
+// Tween animates one value towards a target. The starting value is read
+// when the tween begins, after any delay, so chained tweens continue from
+// wherever the previous one left the value.
+type Tween struct {
+	// Duration is the length of the tween in seconds.
+	Duration float32
+
+	// Delay is the time in seconds to wait before the tween begins.
+	Delay float32
+
+	// Ease is the easing function; Linear is used if nil.
+	Ease EaseFunc
+
+	// OnUpdate is called after the value is updated each frame.
+	OnUpdate Callback
+
+	// OnComplete is called once the tween reaches the target.
+	OnComplete Callback
+
+	// begin captures the starting value and apply sets the value for
+	// the eased progress
+	begin func()
+	apply func(t float32)
+
+	elapsed   float32
+	started   bool
+	done      bool
+	cancelled bool
+	next      []*Tween
+}
+
+// Float creates a tween of the float to the target value.
+func Float(value *float32, to float32, duration float32) *Tween {
+	var from float32
+	tw := newTween(duration)
+	tw.begin = func() { from = *value }
+	tw.apply = func(t float32) { *value = from + (to-from)*t }
+	return tw
+}
+
+// Vec3 creates a tween of the vector, such as a location or scale, to the target.
+func Vec3(value *mgl.Vec3, to mgl.Vec3, duration float32) *Tween {
+	var from mgl.Vec3
+	tw := newTween(duration)
+	tw.begin = func() { from = *value }
+	tw.apply = func(t float32) { *value = from.Add(to.Sub(from).Mul(t)) }
+	return tw
+}
+
+// Quat creates a tween of the rotation to the target using spherical
+// linear interpolation.
+func Quat(value *mgl.Quat, to mgl.Quat, duration float32) *Tween {
+	var from mgl.Quat
+	tw := newTween(duration)
+	tw.begin = func() { from = *value }
+	tw.apply = func(t float32) { *value = mgl.QuatSlerp(from, to, t) }
+	return tw
+}
+
+// Color creates a tween of the RGBA color to the target.
+func Color(value *mgl.Vec4, to mgl.Vec4, duration float32) *Tween {
+	var from mgl.Vec4
+	tw := newTween(duration)
+	tw.begin = func() { from = *value }
+	tw.apply = func(t float32) { *value = from.Add(to.Sub(from).Mul(t)) }
+	return tw
+}
+
+// Func creates a tween that calls fn with the eased progress every update,
+// for animating values the other constructors don't cover.
+func Func(fn func(t float32), duration float32) *Tween {
+	tw := newTween(duration)
+	tw.begin = func() {}
+	tw.apply = fn
+	return tw
+}
+
+// newTween creates a tween with the default settings.
+func newTween(duration float32) *Tween {
+	tw := new(Tween)
+	tw.Duration = duration
+	tw.Ease = Linear
+	return tw
+}
+
+// SetEase sets the easing function and returns the tween for chaining calls.
+func (tw *Tween) SetEase(ease EaseFunc) *Tween {
+	tw.Ease = ease
+	return tw
+}
+
+// SetDelay sets the delay in seconds and returns the tween for chaining calls.
+func (tw *Tween) SetDelay(delay float32) *Tween {
+	tw.Delay = delay
+	return tw
+}
+
+// SetOnUpdate sets the update callback and returns the tween for chaining calls.
+func (tw *Tween) SetOnUpdate(cb Callback) *Tween {
+	tw.OnUpdate = cb
+	return tw
+}
+
+// SetOnComplete sets the completion callback and returns the tween for chaining calls.
+func (tw *Tween) SetOnComplete(cb Callback) *Tween {
+	tw.OnComplete = cb
+	return tw
+}
+
+// Then starts the next tween when this one completes and returns the next
+// tween, so sequences can be written as a.Then(b).Then(c). Calling Then
+// more than once on the same tween starts all of them in parallel.
+func (tw *Tween) Then(next *Tween) *Tween {
+	tw.next = append(tw.next, next)
+	return next
+}
+
+// IsDone returns true once the tween has completed or was cancelled.
+func (tw *Tween) IsDone() bool {
+	return tw.done
+}
+
+// Cancel stops the tween where it is; the completion callback isn't called
+// and chained tweens aren't started.
+func (tw *Tween) Cancel() {
+	tw.cancelled = true
+	tw.done = true
+}
+
+// update advances the tween and returns the leftover time in seconds if it
+// completed during this update.
+func (tw *Tween) update(delta float32) (float32, bool) {
+	if tw.done {
+		return 0.0, false
+	}
+
+	tw.elapsed += delta
+	if tw.elapsed < tw.Delay {
+		return 0.0, false
+	}
+	if !tw.started {
+		tw.started = true
+		tw.begin()
+	}
+
+	t := float32(1.0)
+	if tw.Duration > 0.0 {
+		t = (tw.elapsed - tw.Delay) / tw.Duration
+	}
+	finished := t >= 1.0
+	if finished {
+		t = 1.0
+	}
+
+	ease := tw.Ease
+	if ease == nil {
+		ease = Linear
+	}
+	tw.apply(ease(t))
+	if tw.OnUpdate != nil {
+		tw.OnUpdate(tw)
+	}
+
+	if !finished {
+		return 0.0, false
+	}
+	tw.done = true
+	if tw.OnComplete != nil {
+		tw.OnComplete(tw)
+	}
+	return tw.elapsed - tw.Delay - tw.Duration, true
+}
+
+// Manager updates a set of running tweens.
+type Manager struct {
+	tweens []*Tween
+}
+
+// NewManager creates a new manager with no tweens.
+func NewManager() *Manager {
+	m := new(Manager)
+	m.tweens = make([]*Tween, 0)
+	return m
+}
+
+// Add starts running the tween and returns it.
+func (m *Manager) Add(tw *Tween) *Tween {
+	m.tweens = append(m.tweens, tw)
+	return tw
+}
+
+// Len returns the number of running tweens.
+func (m *Manager) Len() int {
+	return len(m.tweens)
+}
+
+// Clear cancels all of the running tweens.
+func (m *Manager) Clear() {
+	for _, tw := range m.tweens {
+		tw.Cancel()
+	}
+	m.tweens = m.tweens[:0]
+}
+
+// UpdateWithClock advances the tweens by the clock's scaled delta.
+func (m *Manager) UpdateWithClock(clock *fizzle.Clock) {
+	m.Update(float32(clock.Delta()))
+}
+
+// Update advances the tweens by frameDelta seconds, starting any tweens
+// chained to the ones that complete.
+func (m *Manager) Update(frameDelta float32) {
+	// chained tweens get appended by advance and are already updated
+	// with the time left over from the tween that started them
+	count := len(m.tweens)
+	for i := 0; i < count; i++ {
+		m.advance(m.tweens[i], frameDelta)
+	}
+
+	remaining := m.tweens[:0]
+	for _, tw := range m.tweens {
+		if !tw.done {
+			remaining = append(remaining, tw)
+		}
+	}
+	for i := len(remaining); i < len(m.tweens); i++ {
+		m.tweens[i] = nil
+	}
+	m.tweens = remaining
+}
+
+// advance updates the tween and starts its chained tweens on completion.
+func (m *Manager) advance(tw *Tween, delta float32) {
+	leftover, completed := tw.update(delta)
+	if !completed || tw.cancelled {
+		return
+	}
+	for _, next := range tw.next {
+		m.tweens = append(m.tweens, next)
+		m.advance(next, leftover)
+	}
+}
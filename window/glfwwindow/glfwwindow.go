@@ -0,0 +1,100 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+package glfwwindow
+
+import (
+	glfw "github.com/go-gl/glfw/v3.1/glfw"
+	"github.com/tbogdala/fizzle/window"
+)
+
+// Window wraps a GLFW window to implement the window.Window interface.
+type Window struct {
+	// GLFW is the underlying GLFW window.
+	GLFW *glfw.Window
+
+	onResize window.ResizeCallback
+
+	// prevSizeCallback is the previously bound framebuffer size callback
+	// from glfw. This is used to chain the event.
+	prevSizeCallback glfw.FramebufferSizeCallback
+}
+
+// New wraps the GLFW window. The framebuffer size callback is installed
+// here, once, and chains to any callback already set on the GLFW window.
+func New(w *glfw.Window) *Window {
+	win := new(Window)
+	win.GLFW = w
+	win.prevSizeCallback = w.SetFramebufferSizeCallback(win.framebufferSizeCallback)
+	return win
+}
+
+// CreateShared creates a new GLFW window whose context shares textures,
+// buffers and shaders with the context of the share window. The window
+// hints currently set in GLFW are used.
+func CreateShared(width, height int, title string, share *Window) (*Window, error) {
+	var shareGLFW *glfw.Window
+	if share != nil {
+		shareGLFW = share.GLFW
+	}
+	w, err := glfw.CreateWindow(width, height, title, nil, shareGLFW)
+	if err != nil {
+		return nil, err
+	}
+	return New(w), nil
+}
+
+// MakeContextCurrent makes the window's context current on the calling thread.
+func (win *Window) MakeContextCurrent() {
+	win.GLFW.MakeContextCurrent()
+}
+
+// SwapBuffers presents the rendered frame.
+func (win *Window) SwapBuffers() {
+	win.GLFW.SwapBuffers()
+}
+
+// SetSwapInterval sets the swap interval for the current context.
+func (win *Window) SetSwapInterval(interval int) {
+	glfw.SwapInterval(interval)
+}
+
+// PollEvents processes any pending events for all GLFW windows.
+func (win *Window) PollEvents() {
+	glfw.PollEvents()
+}
+
+// ShouldClose returns true if the window has been asked to close.
+func (win *Window) ShouldClose() bool {
+	return win.GLFW.ShouldClose()
+}
+
+// GetSize returns the size of the window in screen coordinates.
+func (win *Window) GetSize() (int, int) {
+	return win.GLFW.GetSize()
+}
+
+// GetFramebufferSize returns the size of the window's framebuffer in pixels.
+func (win *Window) GetFramebufferSize() (int, int) {
+	return win.GLFW.GetFramebufferSize()
+}
+
+// SetResizeCallback sets the function called when the framebuffer changes
+// size, replacing any function set by an earlier call. Any framebuffer size
+// callback that was set on the GLFW window before it was wrapped is still
+// called afterwards.
+func (win *Window) SetResizeCallback(cb window.ResizeCallback) {
+	win.onResize = cb
+}
+
+// framebufferSizeCallback is bound to the GLFW window in New.
+func (win *Window) framebufferSizeCallback(w *glfw.Window, width int, height int) {
+	if win.onResize != nil {
+		win.onResize(width, height)
+	}
+
+	// chain the event handler to the previous one if it existed.
+	if win.prevSizeCallback != nil {
+		win.prevSizeCallback(w, width, height)
+	}
+}
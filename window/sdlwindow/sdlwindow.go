@@ -0,0 +1,123 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The sdlwindow module implements window.Window on top of SDL2 for targets
+where SDL is better supported than GLFW.
+
+SDL has a single event queue for the whole application, so PollEvents
+drains it and passes every event on to the handlers added with
+AddEventHandler, such as the ones from the sdlinput module.
+
+*/
+
+package sdlwindow
+
+import (
+	"github.com/tbogdala/fizzle/window"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// EventHandler is the type of the function called for every SDL event
+// processed in PollEvents.
+type EventHandler func(event sdl.Event)
+
+// Window wraps a SDL window and its OpenGL context to implement the
+// window.Window interface.
+type Window struct {
+	// SDL is the underlying SDL window.
+	SDL *sdl.Window
+
+	// Context is the OpenGL context created for the window.
+	Context sdl.GLContext
+
+	shouldClose bool
+	onResize    window.ResizeCallback
+	handlers    []EventHandler
+}
+
+// New wraps the SDL window and the OpenGL context created for it
+// with sdl.GL_CreateContext.
+func New(w *sdl.Window, context sdl.GLContext) *Window {
+	win := new(Window)
+	win.SDL = w
+	win.Context = context
+	win.handlers = make([]EventHandler, 0)
+	return win
+}
+
+// AddEventHandler adds a function to call for every event processed in PollEvents.
+func (win *Window) AddEventHandler(handler EventHandler) {
+	win.handlers = append(win.handlers, handler)
+}
+
+// MakeContextCurrent makes the window's context current on the calling thread.
+func (win *Window) MakeContextCurrent() {
+	sdl.GL_MakeCurrent(win.SDL, win.Context)
+}
+
+// SwapBuffers presents the rendered frame.
+func (win *Window) SwapBuffers() {
+	sdl.GL_SwapWindow(win.SDL)
+}
+
+// SetSwapInterval sets the swap interval for the current context.
+func (win *Window) SetSwapInterval(interval int) {
+	sdl.GL_SetSwapInterval(interval)
+}
+
+// PollEvents drains the SDL event queue, tracking quit and resize events
+// for this window and passing every event on to the event handlers.
+func (win *Window) PollEvents() {
+	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+		switch e := event.(type) {
+		case *sdl.QuitEvent:
+			win.shouldClose = true
+		case *sdl.WindowEvent:
+			if e.WindowID == win.SDL.GetID() {
+				switch e.Event {
+				case sdl.WINDOWEVENT_CLOSE:
+					win.shouldClose = true
+				case sdl.WINDOWEVENT_SIZE_CHANGED:
+					if win.onResize != nil {
+						width, height := win.GetFramebufferSize()
+						win.onResize(width, height)
+					}
+				}
+			}
+		}
+
+		for _, handler := range win.handlers {
+			handler(event)
+		}
+	}
+}
+
+// ShouldClose returns true if the window or application has been asked to close.
+func (win *Window) ShouldClose() bool {
+	return win.shouldClose
+}
+
+// SetShouldClose sets the flag returned by ShouldClose.
+func (win *Window) SetShouldClose(value bool) {
+	win.shouldClose = value
+}
+
+// GetSize returns the size of the window in screen coordinates.
+func (win *Window) GetSize() (int, int) {
+	return win.SDL.GetSize()
+}
+
+// GetFramebufferSize returns the size of the window's drawable in pixels,
+// which differs from GetSize on high-DPI displays.
+func (win *Window) GetFramebufferSize() (int, int) {
+	var width, height int
+	sdl.GL_GetDrawableSize(win.SDL, &width, &height)
+	return width, height
+}
+
+// SetResizeCallback sets the function called when the window changes size.
+func (win *Window) SetResizeCallback(cb window.ResizeCallback) {
+	win.onResize = cb
+}
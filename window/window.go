@@ -0,0 +1,108 @@
+// Copyright 2016, Timothy Bogdala <tdb@animal-machine.com>
+// See the LICENSE file for more details.
+
+/*
+
+The window module defines a common interface to the surface that the
+renderers draw to so that they don't depend on a specific windowing
+library like GLFW. Implementations live in sub-packages such as glfwwindow.
+
+*/
+
+package window
+
+// ResizeCallback is the type of the function called when the framebuffer
+// of a Window changes size. The sizes are in pixels.
+type ResizeCallback func(width, height int)
+
+// Window is a surface with an OpenGL context that can be rendered to.
+type Window interface {
+	// MakeContextCurrent makes the window's context current on the calling thread.
+	MakeContextCurrent()
+
+	// SwapBuffers presents the rendered frame.
+	SwapBuffers()
+
+	// SetSwapInterval sets the number of screen refreshes to wait for
+	// before swapping the buffers.
+	SetSwapInterval(interval int)
+
+	// PollEvents processes any pending window and input events.
+	PollEvents()
+
+	// ShouldClose returns true if the window has been asked to close.
+	ShouldClose() bool
+
+	// GetSize returns the size of the window in screen coordinates.
+	GetSize() (int, int)
+
+	// GetFramebufferSize returns the size of the window's framebuffer in pixels.
+	GetFramebufferSize() (int, int)
+
+	// SetResizeCallback sets the function called when the framebuffer changes size.
+	SetResizeCallback(cb ResizeCallback)
+}
+
+// Offscreen is a Window implementation without an OS window, for rendering
+// into framebuffer objects with a context created elsewhere (e.g. headless
+// rendering or tests). Swapping and polling do nothing.
+type Offscreen struct {
+	width    int
+	height   int
+	closing  bool
+	onResize ResizeCallback
+}
+
+// NewOffscreen creates a new offscreen surface of the given size.
+func NewOffscreen(width, height int) *Offscreen {
+	o := new(Offscreen)
+	o.width = width
+	o.height = height
+	return o
+}
+
+// MakeContextCurrent does nothing for an offscreen surface.
+func (o *Offscreen) MakeContextCurrent() {}
+
+// SwapBuffers does nothing for an offscreen surface.
+func (o *Offscreen) SwapBuffers() {}
+
+// SetSwapInterval does nothing for an offscreen surface.
+func (o *Offscreen) SetSwapInterval(interval int) {}
+
+// PollEvents does nothing for an offscreen surface.
+func (o *Offscreen) PollEvents() {}
+
+// ShouldClose returns true once Close has been called.
+func (o *Offscreen) ShouldClose() bool {
+	return o.closing
+}
+
+// Close flags the surface as closing.
+func (o *Offscreen) Close() {
+	o.closing = true
+}
+
+// GetSize returns the size of the surface.
+func (o *Offscreen) GetSize() (int, int) {
+	return o.width, o.height
+}
+
+// GetFramebufferSize returns the size of the surface.
+func (o *Offscreen) GetFramebufferSize() (int, int) {
+	return o.width, o.height
+}
+
+// SetResizeCallback sets the function called when Resize is called.
+func (o *Offscreen) SetResizeCallback(cb ResizeCallback) {
+	o.onResize = cb
+}
+
+// Resize changes the size of the surface and calls the resize callback.
+func (o *Offscreen) Resize(width, height int) {
+	o.width = width
+	o.height = height
+	if o.onResize != nil {
+		o.onResize(width, height)
+	}
+}